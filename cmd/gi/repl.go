@@ -14,6 +14,12 @@ var ProgramName string = path.Base(os.Args[0])
 
 func main() {
 
+	if name, lua, ok := compiler.BundledProgram(); ok {
+		// made by gi build -bundle: run the program in us,
+		// leaving its arguments to it.
+		os.Exit(compiler.GiBundleMain(compiler.NewGIConfig(), name, lua, os.Args))
+	}
+
 	myflags := flag.NewFlagSet("gi", flag.ExitOnError)
 	cfg := compiler.NewGIConfig()
 	cfg.DefineFlags(myflags)
@@ -23,6 +29,35 @@ func main() {
 	if err != nil {
 		log.Fatalf("%s command line flag error: '%s'", ProgramName, err)
 	}
+	if args := myflags.Args(); len(args) > 0 && args[0] == "test" {
+		// gi test [-v] [-run regexp] [-short] [packages]
+		os.Exit(compiler.GiTestMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "run" {
+		// gi run file.go|file.lua [arguments...]
+		os.Exit(compiler.GiRunMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "build" {
+		// gi build [-o file.lua] [-bundle] [-emit bytecode] file.go
+		os.Exit(compiler.GiBuildMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "transpile" {
+		// gi transpile [-o file.lua] file.go
+		os.Exit(compiler.GiTranspileMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "explain" {
+		// gi explain [GI-W001]
+		os.Exit(compiler.GiExplainMain(args[1:]))
+	} else if len(args) > 0 && args[0] == "serve" {
+		// gi serve [-listen localhost:8998] [-expose] [-token secret]
+		os.Exit(compiler.GiServeMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "exec" {
+		// gi exec [-session NAME] -stdin-block, for literate documents
+		globals := os.Args[1 : len(os.Args)-len(args)]
+		os.Exit(compiler.GiExecMain(cfg, globals, args[1:]))
+	} else if len(args) > 0 && args[0] == "replay" {
+		// gi replay [-update] file.girepl...
+		os.Exit(compiler.GiReplayMain(cfg, args[1:]))
+	} else if len(args) > 0 && args[0] == "nvim" {
+		// gi nvim, started by Neovim as an RPC job
+		os.Exit(compiler.GiNvimMain(cfg, args[1:]))
+	}
 	if !cfg.Quiet {
 		fmt.Printf(
 			`====================
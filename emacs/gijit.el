@@ -1,6 +1,6 @@
-;;; gijit-3.92
+;;; gijit-3.93
 ;;;
-;;; (Setq *gijit-version-string* "3.92") ; update this below when changing.
+;;; (Setq *gijit-version-string* "3.93") ; update this below when changing.
 ;;;
 ;;; gijit.el     - is a pair of modes for sending lines from a 
 ;;;                  script (sender) to a comint-started inferior 
@@ -59,9 +59,10 @@
 (defvar *inferior-gijit-buffer* "*gijit*"
   "*Name of buffer for running an inferior Gijit process.")
 
-(defvar *inferior-gijit-regex-prompt* "[^\(gi>\)\|\(\.\.\.\)]*[gi> ]"
-  "Regexp to match prompts for the inferior Gijit process.")
-(setq *inferior-gijit-regex-prompt* "[^\(gi>\)\|\(\.\.\.\)]*[gi> ]")
+(defvar *inferior-gijit-regex-prompt* "^gi> "
+  "Regexp to match prompts for the inferior Gijit process.
+Under -dumb-terminal, gi's prompt is exactly \"gi> \".")
+(setq *inferior-gijit-regex-prompt* "^gi> ")
 
 (defvar *gijit-keypress-to-sendline* (kbd "C-n")
   "keypress that, when in a pure mode script, sends a line to the interpreter
@@ -73,7 +74,7 @@
 (defvar *gijit-keypress-to-send-sexp-jdev-prev* (kbd "C-p")
   "keypress that sends the previous sexp to the repl")
 
-(defvar *gijit-version-string* "3.92"
+(defvar *gijit-version-string* "3.93"
   "version of gijit currently running.")
 
 (defvar *gijit-gdb-terp-buffer* "*vlush2-gdb*"
@@ -209,34 +210,31 @@ On success, return 0.  Otherwise, go as far as possible and return -1."
     (message "No buffer named %s" *inferior-gijit-buffer*)))
 
 (defun gijit-send-region (beg end)
-  "Send current region to the inferior Gijit process."
+  "Send current region to the inferior Gijit process.
+A region of several lines is sent between lines of :{ and :},
+so that gi runs it as one input, as gi -dumb-terminal allows."
   (interactive "r")
   (inferior-gijit t)
   (let ((proc inferior-gijit-process)
-	(string (buffer-substring-no-properties beg end))
-	line)
-    (if (string-equal string "")
-	(setq string "\n"))
+	(string (replace-regexp-in-string
+		 "\n+\\'" "" (buffer-substring-no-properties beg end))))
     (with-current-buffer *inferior-gijit-buffer* 
       (setq inferior-gijit-output-list nil)
-      (while (not (string-equal string ""))
-	(if (string-match "\n" string)
-	    (setq line (substring string 0 (match-beginning 0))
-		  string (substring string (match-end 0)))
-	  (setq line string string ""))
-	(setq inferior-gijit-receive-in-progress t)
-	(inferior-gijit-send-list-and-digest (list (concat line "\n")))
-;;	(inferior-gijit-send-list-and-digest (list (concat "%cpaste\n" line "\n--\n")))
-	(while inferior-gijit-receive-in-progress
-	  (accept-process-output proc))
-	(insert-before-markers
-	 (mapconcat 'identity
-		    (append
-		     (if gijit-send-echo-input (list line) (list ""))
-		     (mapcar 'inferior-gijit-strip-ctrl-g
-			     inferior-gijit-output-list)
-		     (list inferior-gijit-output-string))
-		    "\n")))))
+      (setq inferior-gijit-receive-in-progress t)
+      (inferior-gijit-send-list-and-digest
+       (list (if (string-match "\n" string)
+		 (concat ":{\n" string "\n:}\n")
+	       (concat string "\n"))))
+      (while inferior-gijit-receive-in-progress
+	(accept-process-output proc))
+      (insert-before-markers
+       (mapconcat 'identity
+		  (append
+		   (if gijit-send-echo-input (list string) (list ""))
+		   (mapcar 'inferior-gijit-strip-ctrl-g
+			   inferior-gijit-output-list)
+		   (list inferior-gijit-output-string))
+		  "\n"))))
   (if gijit-send-show-buffer
       (gijit-eob)))
        
@@ -711,10 +709,11 @@ which is just following the next form back."
   "*Hook to be run when Inferior Gijit mode is started.")
 
 
-(defvar *inferior-gijit-startup-args* nil
-  "arguments to be given to the Inferior Gijit process on startup.")
-;(setq *inferior-gijit-startup-args* (list "console" "--nosep" "--colors=NoColor"))
-(setq *inferior-gijit-startup-args* nil) ;;(list "--pylab"))
+(defvar *inferior-gijit-startup-args* (list "-dumb-terminal")
+  "arguments to be given to the Inferior Gijit process on startup.
+-dumb-terminal turns off liner and colors, and ends the output of
+each input with a line of //gi:result ok, or //gi:result error.")
+(setq *inferior-gijit-startup-args* (list "-dumb-terminal"))
 
 ;;; Compatibility functions
 (if (not (fboundp 'comint-line-beginning-position))
@@ -810,7 +809,10 @@ startup file, `~/.emacs-gijit'."
   string)
 
 ;; test:
-;; (inferior-gijit-strip-ctrl-m "hi")
+;; (inferior-gijit-strip-ctrl-m "hi
+
+
+")
 
 
 
@@ -0,0 +1,62 @@
+;;; ob-gi.el --- org-babel functions for gi, the Go REPL
+;;;
+;;; Source blocks of Go in org documents, run by `gi exec'.
+;;; What a block prints, the values of its expressions
+;;; included, is its result:
+;;;
+;;;   #+begin_src gi :session notes
+;;;   x := 21
+;;;   #+end_src
+;;;
+;;;   #+begin_src gi :session notes
+;;;   x * 2
+;;;   #+end_src
+;;;
+;;;   #+RESULTS:
+;;;   : 42
+;;;
+;;; Blocks with the same :session run in one gi session, which
+;;; the first of them starts, so that each sees what the ones
+;;; before it declared; a block without a :session runs in a
+;;; new session of its own. `gi exec -session NAME -kill' ends
+;;; a session, as M-x org-babel-gi-kill-session does.
+;;;
+;;; how to install:
+;;;
+;;;    (load "/path/to/gi/emacs/ob-gi.el")
+;;;    (org-babel-do-load-languages
+;;;     'org-babel-load-languages
+;;;     (append org-babel-load-languages '((gi . t))))
+
+(require 'ob)
+
+(defvar org-babel-gi-command "gi"
+  "The gi program that runs gi source blocks.")
+
+(defvar org-babel-default-header-args:gi '((:results . "output"))
+  "Default header arguments of gi source blocks.")
+
+(add-to-list 'org-src-lang-modes '("gi" . go))
+
+(defun org-babel-gi-session-args (params)
+  "The gi exec flags for the :session of PARAMS."
+  (let ((session (cdr (assq :session params))))
+    (if (and session (not (string= session "none")))
+	(concat " -session " (shell-quote-argument session))
+      "")))
+
+(defun org-babel-execute:gi (body params)
+  "Run BODY, a gi source block, with gi exec; return what it printed."
+  (org-babel-eval
+   (concat org-babel-gi-command " exec"
+	   (org-babel-gi-session-args params) " -stdin-block")
+   body))
+
+(defun org-babel-gi-kill-session (session)
+  "End the gi session named SESSION."
+  (interactive "sgi session to end: ")
+  (shell-command
+   (concat org-babel-gi-command " exec -session "
+	   (shell-quote-argument session) " -kill")))
+
+(provide 'ob-gi)
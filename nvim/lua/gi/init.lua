@@ -0,0 +1,232 @@
+-- gi.nvim: drive a gi session from Neovim.
+--
+-- Neovim starts `gi nvim` as a job and speaks msgpack-RPC
+-- to it. Code sent to the session is evaluated there, and
+-- its result shown beside the code, as virtual text, with
+-- everything printed gathered in an output buffer.
+--
+-- how to install:
+--
+--   Put this nvim/ directory on the runtimepath, as with
+--
+--     set runtimepath+=/path/to/gi/nvim
+--
+--   or your plugin manager, and have `gi` on your PATH.
+--   Then, optionally, in your init.lua:
+--
+--     require("gi").setup({cmd = {"gi", "-q", "nvim"}})
+--     vim.keymap.set("n", "<leader>ge", "<cmd>GiEvalLine<cr>")
+--     vim.keymap.set("x", "<leader>ge", ":GiEval<cr>")
+--     vim.keymap.set("n", "<leader>gf", "<cmd>GiEvalFunction<cr>")
+--     vim.keymap.set("n", "<leader>gi", "<cmd>GiInterrupt<cr>")
+--
+-- commands (plugin/gi.lua):
+--
+--   :GiStart           start the session (done on first use)
+--   :GiStop            end it
+--   :[range]GiEval     evaluate the lines, by default all
+--   :GiEvalLine        evaluate the line under the cursor
+--   :GiEvalFunction    evaluate the declaration under the cursor
+--   :GiInterrupt       cancel the running evaluation
+--   :GiClear           clear the results shown in the buffer
+--   :GiVars            list the session's variables
+--   :GiOutput          show the output buffer
+--
+-- Completion from the session is given as an omnifunc:
+--
+--   setlocal omnifunc=v:lua.require'gi'.omnifunc
+
+local M = {}
+
+M.config = {
+   cmd = {"gi", "-q", "nvim"},
+   -- the highlight groups of results and errors.
+   result_hl = "Comment",
+   error_hl = "ErrorMsg",
+}
+
+local ns = vim.api.nvim_create_namespace("gi")
+local chan = nil
+local pending = {}
+local next_id = 0
+local outbuf = nil
+
+function M.setup(opts)
+   M.config = vim.tbl_deep_extend("force", M.config, opts or {})
+end
+
+function M.start()
+   if chan then
+      return chan
+   end
+   local id = vim.fn.jobstart(M.config.cmd, {
+      rpc = true,
+      on_exit = function(_, code)
+         chan = nil
+         for _, p in pairs(pending) do
+            M.mark(p.buf, p.line, "gi exited (" .. code .. ")", M.config.error_hl)
+         end
+         pending = {}
+      end,
+   })
+   if id <= 0 then
+      error("gi: cannot start " .. table.concat(M.config.cmd, " "))
+   end
+   chan = id
+   return chan
+end
+
+function M.stop()
+   if chan then
+      vim.fn.jobstop(chan)
+      chan = nil
+   end
+end
+
+-- mark shows text at the end of line, counted from 0, of
+-- buf, in place of what was there.
+function M.mark(buf, line, text, hl)
+   if not vim.api.nvim_buf_is_valid(buf) then
+      return
+   end
+   line = math.min(line, vim.api.nvim_buf_line_count(buf) - 1)
+   vim.api.nvim_buf_clear_namespace(buf, ns, line, line + 1)
+   vim.api.nvim_buf_set_extmark(buf, ns, line, 0, {
+      virt_text = {{"=> " .. text, hl}},
+      virt_text_pos = "eol",
+   })
+end
+
+local function output_buffer()
+   if outbuf and vim.api.nvim_buf_is_valid(outbuf) then
+      return outbuf
+   end
+   outbuf = vim.api.nvim_create_buf(true, true)
+   vim.api.nvim_buf_set_name(outbuf, "gi://output")
+   vim.bo[outbuf].filetype = "go"
+   return outbuf
+end
+
+local function append_output(code, res)
+   local buf = output_buffer()
+   local lines = {}
+   for l in (code .. "\n"):gmatch("(.-)\n") do
+      table.insert(lines, "// gi> " .. l)
+   end
+   for _, text in ipairs({res.output or "", res.error or ""}) do
+      if text ~= "" then
+         vim.list_extend(lines, vim.split((text:gsub("\n$", "")), "\n"))
+      end
+   end
+   local n = vim.api.nvim_buf_line_count(buf)
+   local empty = n == 1 and vim.api.nvim_buf_get_lines(buf, 0, 1, false)[1] == ""
+   vim.api.nvim_buf_set_lines(buf, empty and 0 or n, -1, false, lines)
+   for _, win in ipairs(vim.fn.win_findbuf(buf)) do
+      vim.api.nvim_win_set_cursor(win, {vim.api.nvim_buf_line_count(buf), 0})
+   end
+end
+
+-- send calls method of gi with args, as a notification that
+-- on_result answers, to show the result at line of buf.
+local function send(method, args, buf, line, code)
+   next_id = next_id + 1
+   pending[next_id] = {buf = buf, line = line, code = code}
+   M.mark(buf, line, "...", M.config.result_hl)
+   vim.rpcnotify(M.start(), method, next_id, unpack(args))
+end
+
+-- on_result is called by gi with the result of a call.
+function M.on_result(id, res)
+   local p = pending[id]
+   pending[id] = nil
+   if not p then
+      return
+   end
+   local line = p.line
+   if res["end"] then
+      line = res["end"] - 1
+   end
+   if res.error and res.error ~= "" then
+      local first = (res.summary or res.error):gsub("\n.*", "")
+      M.mark(p.buf, line, first, M.config.error_hl)
+   else
+      local summary = res.summary or ""
+      M.mark(p.buf, line, summary ~= "" and summary or "ok", M.config.result_hl)
+   end
+   append_output(p.code or "", res)
+end
+
+-- eval evaluates lines first to last, counted from 1, of
+-- the current buffer.
+function M.eval(first, last)
+   local buf = vim.api.nvim_get_current_buf()
+   local code = table.concat(vim.api.nvim_buf_get_lines(buf, first - 1, last, false), "\n")
+   send("eval", {code}, buf, last - 1, code)
+end
+
+function M.eval_line()
+   local line = vim.api.nvim_win_get_cursor(0)[1]
+   M.eval(line, line)
+end
+
+function M.eval_function()
+   local buf = vim.api.nvim_get_current_buf()
+   local line = vim.api.nvim_win_get_cursor(0)[1]
+   local text = table.concat(vim.api.nvim_buf_get_lines(buf, 0, -1, false), "\n")
+   send("eval_function", {text, line}, buf, line - 1, "(declaration at line " .. line .. ")")
+end
+
+function M.interrupt()
+   if chan then
+      vim.rpcrequest(chan, "interrupt")
+   end
+end
+
+function M.clear()
+   vim.api.nvim_buf_clear_namespace(0, ns, 0, -1)
+end
+
+function M.show_output()
+   local buf = output_buffer()
+   if #vim.fn.win_findbuf(buf) == 0 then
+      vim.cmd("botright split")
+      vim.api.nvim_win_set_buf(0, buf)
+      vim.cmd("wincmd p")
+   end
+end
+
+function M.vars()
+   local vars = vim.rpcrequest(M.start(), "vars")
+   local lines = {}
+   for _, v in ipairs(vars) do
+      local value = v.value:gsub("\n", " ")
+      table.insert(lines, v.name .. " " .. v.type .. " = " .. value)
+   end
+   if #lines == 0 then
+      lines = {"no variables"}
+   end
+   vim.notify(table.concat(lines, "\n"))
+end
+
+-- omnifunc completes from the session's names. The first
+-- call asks gi; the second, when Vim has taken base out of
+-- the line, gives what that found.
+local completion = nil
+
+function M.omnifunc(findstart, base)
+   if findstart == 1 then
+      local line = vim.api.nvim_get_current_line()
+      local col = vim.api.nvim_win_get_cursor(0)[2]
+      completion = vim.rpcrequest(M.start(), "complete", line:sub(1, col), col)
+      return completion.start
+   end
+   local found = {}
+   for _, c in ipairs(completion and completion.candidates or {}) do
+      if vim.startswith(c, base) then
+         table.insert(found, c)
+      end
+   end
+   return found
+end
+
+return M
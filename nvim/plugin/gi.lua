@@ -0,0 +1,22 @@
+-- The commands of gi.nvim; see lua/gi/init.lua.
+
+if vim.g.loaded_gi then
+   return
+end
+vim.g.loaded_gi = true
+
+local function gi()
+   return require("gi")
+end
+
+vim.api.nvim_create_user_command("GiStart", function() gi().start() end, {})
+vim.api.nvim_create_user_command("GiStop", function() gi().stop() end, {})
+vim.api.nvim_create_user_command("GiEval", function(opts)
+   gi().eval(opts.line1, opts.line2)
+end, {range = "%"})
+vim.api.nvim_create_user_command("GiEvalLine", function() gi().eval_line() end, {})
+vim.api.nvim_create_user_command("GiEvalFunction", function() gi().eval_function() end, {})
+vim.api.nvim_create_user_command("GiInterrupt", function() gi().interrupt() end, {})
+vim.api.nvim_create_user_command("GiClear", function() gi().clear() end, {})
+vim.api.nvim_create_user_command("GiVars", function() gi().vars() end, {})
+vim.api.nvim_create_user_command("GiOutput", function() gi().show_output() end, {})
@@ -0,0 +1,82 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1357TypeAliasDeclarations(t *testing.T) {
+
+	cv.Convey("type A = B makes A another name for B, of any kind of type", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type P struct{ X int }
+type A = P
+type B = A`))
+		panicOn(it.Eval(`func (a *A) Inc() { a.X++ }`))
+		panicOn(it.Eval(`a := A{X: 3}
+var p P = a
+var b B = p
+b.Inc()
+x := b.X
+var e interface{} = p
+_, isA := e.(A)`))
+		LuaMustInt64(it.lvm, "x", 4)
+		LuaMustBool(it.lvm, "isA", true)
+
+		panicOn(it.Eval(`type I = int
+type M = map[string]I
+type S = []I
+type F = func(I) I
+func dbl(x int) int { return x * 2 }
+var f F = dbl
+m := M{"a": 1}
+s := S{1, 2, 3}
+var i I = 4
+y := f(i) + m["a"] + len(s)`))
+		LuaMustInt64(it.lvm, "y", 12)
+
+		// an alias declares no type of its own.
+		tr, err := it.Translate(`type Z = P`)
+		panicOn(err)
+		cv.So(tr, cv.ShouldNotContainSubstring, "__newType")
+	})
+
+	cv.Convey("at the prompt, aliases follow their target as it is redefined, and can be redefined themselves", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type P struct{ X int }
+type A = P
+type B = A`))
+		panicOn(it.Eval(`type P struct{ X, Y int }`))
+		panicOn(it.Eval(`func (p P) Sum() int { return p.X + p.Y }`))
+		panicOn(it.Eval(`var p P = A{X: 1, Y: 2}
+var q B = p
+r := q.Sum()`))
+		LuaMustInt64(it.lvm, "r", 3)
+
+		// in the same input as the redefinition, too.
+		panicOn(it.Eval(`type P struct{ Z int }
+var z A = A{Z: 5}
+w := z.Z`))
+		LuaMustInt64(it.lvm, "w", 5)
+
+		// an input that fails leaves the aliases as they were.
+		err = it.Eval(`type P struct{ V nosuch }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		panicOn(it.Eval(`v := A{Z: 6}.Z`))
+		LuaMustInt64(it.lvm, "v", 6)
+
+		panicOn(it.Eval(`type A = string
+var s A = "hi"`))
+		LuaMustString(it.lvm, "s", "hi")
+		panicOn(it.Eval(`type B struct{ W int }
+u := B{W: 7}.W`))
+		LuaMustInt64(it.lvm, "u", 7)
+	})
+}
@@ -0,0 +1,85 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1365MultiValueAssignment(t *testing.T) {
+
+	cv.Convey("a multi-value assignment should evaluate its index operands and right side before assigning, skip _, and spread a tuple into any target", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`a, b := 1, 2
+a, b = b, a
+s := []int{10, 20, 30}
+s[0], s[2] = s[2], s[0]
+i := 0
+i, s[i] = 1, 99
+type P struct{ X int }
+p, q := P{1}, P{2}
+p, q = q, p
+px, qx := p.X, q.X
+calls := 0
+bump := func() int { calls++; return calls }
+_, c := bump(), bump()`))
+		LuaMustInt64(it.lvm, "a", 2)
+		LuaMustInt64(it.lvm, "b", 1)
+		LuaMustInt64(it.lvm, "i", 1)
+		panicOn(it.Eval(`s0, s1, s2 := s[0], s[1], s[2]`))
+		LuaMustInt64(it.lvm, "s0", 99)
+		LuaMustInt64(it.lvm, "s1", 20)
+		LuaMustInt64(it.lvm, "s2", 10)
+		LuaMustInt64(it.lvm, "px", 2)
+		LuaMustInt64(it.lvm, "qx", 1)
+		LuaMustInt64(it.lvm, "calls", 2)
+		LuaMustInt64(it.lvm, "c", 2)
+
+		// a tuple spreads into index, map and pointer targets,
+		// converts to an interface, and inside a function
+		// declares locals rather than globals.
+		panicOn(it.Eval(`func pair() (int, string) { return 7, "seven" }
+func mine() (int, *myErr) { return 3, &myErr{"bad"} }
+type myErr struct{ msg string }
+func (e *myErr) Error() string { return e.msg }
+arr := []int{0, 0}
+m := map[string]string{}
+var n int
+np := &n
+arr[1], m["k"] = pair()
+*np, _ = pair()
+var e error
+n2, e := mine()
+emsg := e.Error()
+func inner() int {
+	loc1, loc2 := pair()
+	_ = loc2
+	return loc1
+}
+got := inner()
+mk := m["k"]
+arr1 := arr[1]`))
+		LuaMustInt64(it.lvm, "arr1", 7)
+		LuaMustString(it.lvm, "mk", "seven")
+		LuaMustInt64(it.lvm, "n", 7)
+		LuaMustInt64(it.lvm, "n2", 3)
+		LuaMustString(it.lvm, "emsg", "bad")
+		LuaMustInt64(it.lvm, "got", 7)
+		LuaMustNotBeInGlobalEnv(it.lvm, "loc1")
+		LuaMustNotBeInGlobalEnv(it.lvm, "loc2")
+
+		// comma-ok forms go through the same path.
+		panicOn(it.Eval(`v, ok := m["k"]
+_, missing := m["nope"]
+var x interface{} = 5
+xi, isInt := x.(int)`))
+		LuaMustString(it.lvm, "v", "seven")
+		LuaMustBool(it.lvm, "ok", true)
+		LuaMustBool(it.lvm, "missing", false)
+		LuaMustInt64(it.lvm, "xi", 5)
+		LuaMustBool(it.lvm, "isInt", true)
+	})
+}
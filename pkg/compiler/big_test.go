@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1349BigNumbers(t *testing.T) {
+
+	cv.Convey("math/big's Int, Rat and Float compute past what doubles and int64 hold", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"fmt"
+	"math/big"
+)
+pow := new(big.Int).Exp(big.NewInt(2), big.NewInt(100), nil)
+fact := big.NewInt(1)
+for i := 1; i <= 30; i++ { fact.Mul(fact, big.NewInt(int64(i))) }
+cmp := pow.Cmp(fact)
+var z big.Int
+z.SetString("123456789012345678901234567890", 10)
+z.Add(&z, pow)
+r := big.NewRat(1, 3)
+r.Add(r, big.NewRat(1, 6))
+third := new(big.Float).SetPrec(200).SetInt64(1)
+third.Quo(third, big.NewFloat(3))
+printed := fmt.Sprintf("%v %s", pow, r)`))
+		LuaMustString(it.lvm, "printed", "1267650600228229401496703205376 1/2")
+		LuaMustInt64(it.lvm, "cmp", -1)
+
+		panicOn(it.Eval(`f := fact.String(); zs := z.String(); ts := third.Text('g', 40)`))
+		LuaMustString(it.lvm, "f", "265252859812191058636308480000000")
+		LuaMustString(it.lvm, "zs", "1391107389240575080397937773266")
+		LuaMustString(it.lvm, "ts", "0.3333333333333333333333333333333333333333")
+	})
+}
@@ -0,0 +1,282 @@
+package compiler
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+)
+
+// gi build translates a Go program, a package main in one
+// file as gi run takes, to a Lua file once, so that it can
+// be run again and again without translating it:
+//
+//	gi build -o prog.lua prog.go
+//	gi run prog.lua
+//
+// The Lua needs gi's runtime, and the packages it imports,
+// so it is run by gi run; the imports are listed in its
+// header, one --gi:import line each. Declarations that main
+// cannot reach are left out; see dce.go. With -bundle, the
+// Lua goes into a copy of gi itself instead; see bundle.go.
+//
+// With -emit=bytecode, the header is followed by LuaJIT
+// bytecode in place of the Lua, which loads faster, and
+// with -strip, leaves out the names of locals and the line
+// numbers, so that it is harder to read back; its errors
+// then have no lines to point to. Bytecode needs the same
+// LuaJIT that made it, so it runs only on the gi that built
+// it, or one of the same version. The Lua that imports run
+// can be kept as bytecode too; see importcache.go.
+
+// builtBytecodeMarker ends the header of a built file whose
+// chunk is bytecode; the bytecode follows, to the end.
+const builtBytecodeMarker = "--gi:bytecode\n"
+
+// builtImportPrefix starts each header line of a built
+// file that names an import.
+const builtImportPrefix = "--gi:import "
+
+// BuildOptions are gi build's.
+type BuildOptions struct {
+	// KeepDeadCode turns off dead code elimination.
+	KeepDeadCode bool
+
+	// Emit is what follows the header: "" or "lua" for
+	// Lua, "bytecode" for LuaJIT bytecode.
+	Emit string
+
+	// Strip leaves the debug info out of bytecode.
+	Strip bool
+}
+
+// BuildFile translates the Go program in path to Lua, in a
+// fresh Interp started from cfg. It returns the Lua, and
+// the declarations left out as unreachable.
+func BuildFile(cfg *GIConfig, path string, opts BuildOptions) (lua []byte, dropped []string, err error) {
+	switch opts.Emit {
+	case "", "lua":
+	case "bytecode":
+	default:
+		return nil, nil, fmt.Errorf("unknown -emit '%s'; gi emits lua or bytecode", opts.Emit)
+	}
+	if opts.Strip && opts.Emit != "bytecode" {
+		return nil, nil, fmt.Errorf("-strip is for -emit=bytecode")
+	}
+	s, err := parseScript(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := s.topLevel()
+	hasMain := false
+	for _, node := range nodes {
+		if isMainFunc(node) {
+			hasMain = true
+		}
+	}
+	if !hasMain {
+		return nil, nil, fmt.Errorf("%s: function main is undeclared in the main package", path)
+	}
+	units := dceUnits(nodes)
+	if opts.KeepDeadCode {
+		for _, u := range units {
+			u.live = true
+		}
+	} else {
+		dropped = eliminateDeadCode(units)
+	}
+
+	var src strings.Builder
+	src.WriteString(s.imports())
+	for _, u := range units {
+		if !u.live {
+			continue
+		}
+		switch u.node.(type) {
+		case *ast.TypeSpec:
+			src.WriteString("type ")
+		case *ast.ValueSpec:
+			src.WriteString("var ")
+		}
+		src.WriteString(s.of(u.node) + "\n")
+	}
+
+	c := *cfg
+	c.Quiet = true
+	it, err := NewInterp(&c)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer it.Close()
+	it.inc.inline = true
+	tr, err := it.Translate(src.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- %s: built by gi from %s; run it with gi run.\n",
+		strings.TrimSuffix(filepath.Base(path), ".go")+".lua", filepath.Base(path))
+	for _, spec := range s.file.Imports {
+		b.WriteString(builtImportPrefix + s.of(spec) + "\n")
+	}
+	if opts.Emit == "bytecode" {
+		bc, err := it.dumpBytecode(tr, filepath.Base(path), opts.Strip)
+		if err != nil {
+			return nil, nil, err
+		}
+		b.WriteString(builtBytecodeMarker)
+		b.Write(bc)
+		return []byte(b.String()), dropped, nil
+	}
+	b.WriteString(tr)
+	if !strings.HasSuffix(tr, "\n") {
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), dropped, nil
+}
+
+// RunBuilt runs a Lua file that gi build made, in a fresh
+// Interp started from cfg: its imports, then its Lua, then
+// its main. args are as for RunFile.
+func RunBuilt(cfg *GIConfig, path string, args []string) error {
+	by, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return runBuiltLua(cfg, path, string(by), args)
+}
+
+// runBuiltLua runs lua, as gi build made it, as the program
+// named name.
+func runBuiltLua(cfg *GIConfig, name, lua string, args []string) error {
+	header, bytecode := lua, ""
+	if i := strings.Index(lua, "\n"+builtBytecodeMarker); i >= 0 {
+		header, bytecode = lua[:i+1], lua[i+1+len(builtBytecodeMarker):]
+	}
+	var imports []string
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, builtImportPrefix) {
+			imports = append(imports, "\t"+strings.TrimPrefix(line, builtImportPrefix))
+		}
+	}
+	c := *cfg
+	c.ScriptArgs = append([]string{name}, args...)
+	it, err := NewInterp(&c)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	if len(imports) > 0 {
+		if err := it.Eval("import (\n" + strings.Join(imports, "\n") + "\n)"); err != nil {
+			return err
+		}
+	}
+	if bytecode != "" {
+		// Lua's loadstring takes bytecode as well.
+		if err := it.runBuilt(bytecode); err != nil {
+			return err
+		}
+		return it.runBuilt("main()")
+	}
+	return it.runBuilt(lua + "\nmain()\n")
+}
+
+// runBuilt runs lua, already translated, as Eval runs the
+// translation of its input.
+func (it *Interp) runBuilt(lua string) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return fmt.Errorf("Interp is closed")
+	}
+	if err := it.runGuarded(context.Background(), lua, true, nil, nil); err != nil {
+		return err
+	}
+	return it.lastEvalError()
+}
+
+// dumpBytecode compiles lua, the Lua of the file name, to
+// LuaJIT bytecode; strip leaves out its debug info.
+func (it *Interp) dumpBytecode(lua, name string, strip bool) ([]byte, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+	if !it.cfg.Policy.Allows(CapFFI) {
+		// bytecode is unchecked, and so needs what loading it needs.
+		return nil, fmt.Errorf("emitting bytecode needs the ffi capability")
+	}
+	return dumpLuaBytecode(it.lvm.goro, lua, name, strip)
+}
+
+// dumpLuaBytecode has goro compile lua, named name in its
+// errors, to LuaJIT bytecode.
+func dumpLuaBytecode(goro *Goro, lua, name string, strip bool) ([]byte, error) {
+	t := goro.newTicket(fmt.Sprintf(`
+local chunk, err = loadstring(__gi_buildLua, %q)
+__gi_buildLua = nil
+if not chunk then error(err) end
+__gi_bytecode = string.dump(chunk, %v)`, "="+name, strip), false)
+	t.regmap["__gi_buildLua"] = lua
+	t.gettyp = GetString
+	t.varname["__gi_bytecode"] = nil
+	if err := t.Do(); err != nil {
+		return nil, err
+	}
+	bc, _ := t.varname["__gi_bytecode"].(string)
+	panicOn(goro.newTicket("__gi_bytecode = nil", false).Do())
+	return []byte(bc), nil
+}
+
+// GiBuildMain implements gi build. args are those after
+// "build". It returns the exit code.
+func GiBuildMain(cfg *GIConfig, args []string) int {
+	fs := flag.NewFlagSet("gi build", flag.ContinueOnError)
+	out := fs.String("o", "", "write the Lua here; by default, the Go file's name with .lua")
+	keep := fs.Bool("nodce", false, "keep the declarations main cannot reach")
+	verbose := fs.Bool("v", false, "list the declarations left out")
+	emit := fs.String("emit", "lua", "what to write after the header: lua, or LuaJIT bytecode")
+	strip := fs.Bool("strip", false, "with -emit=bytecode, leave out the debug info")
+	bundle := fs.Bool("bundle", false, "write an executable, gi with the program in it, instead of Lua")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || !strings.HasSuffix(fs.Arg(0), ".go") {
+		fmt.Fprintf(os.Stderr, "usage: gi build [-o file.lua] [-bundle] [-emit lua|bytecode [-strip]] [-nodce] [-v] file.go\n")
+		return 2
+	}
+	path := fs.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(path, ".go")
+		if !*bundle {
+			*out += ".lua"
+		}
+	}
+	lua, dropped, err := BuildFile(cfg, path, BuildOptions{KeepDeadCode: *keep, Emit: *emit, Strip: *strip})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi build: %v\n", err)
+		return 1
+	}
+	if *verbose {
+		for _, name := range dropped {
+			fmt.Fprintf(os.Stderr, "dropped %s\n", name)
+		}
+	}
+	if *bundle {
+		err = WriteBundle(*out, filepath.Base(path), lua)
+	} else {
+		err = ioutil.WriteFile(*out, lua, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi build: %v\n", err)
+		return 1
+	}
+	return 0
+}
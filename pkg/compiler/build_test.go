@@ -0,0 +1,187 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1397BuildDropsWhatMainCannotReach(t *testing.T) {
+
+	cv.Convey("gi build translates a program to Lua that gi run runs, leaving out the funcs, methods, vars, consts and types main cannot reach", t, func() {
+		dir, err := ioutil.TempDir("", "gi-build")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "prog.go")
+		panicOn(ioutil.WriteFile(path, []byte(`package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type T struct{ n int }
+
+func (t *T) Twice() int  { return t.n * 2 }
+func (t *T) helper() int { return t.n }
+func (t *T) used() int   { return t.n + 1 }
+
+type Unused struct{ x int }
+
+func (u Unused) Get() int { return u.x }
+
+const (
+	a = iota
+	b
+)
+
+const shown = "ok"
+
+var table = []int{1, 2, 3}
+var root = math.Sqrt(16)
+
+func unreachable() int { return len(table) + a + b }
+
+func sum(xs []int) (s int) {
+	for _, x := range xs {
+		s += x
+	}
+	return
+}
+
+func main() {
+	t := &T{n: 3}
+	if sum([]int{t.Twice(), t.used()}) != 10 || root != 4 {
+		panic("wrong")
+	}
+	fmt.Println(shown)
+}
+`), 0644))
+
+		lua, dropped, err := BuildFile(NewGIConfig(), path, BuildOptions{})
+		panicOn(err)
+		cv.So(dropped, cv.ShouldResemble, []string{"T.helper", "Unused", "Unused.Get", "a, b", "table", "unreachable"})
+		cv.So(string(lua), cv.ShouldStartWith, "-- prog.lua: built by gi from prog.go")
+		cv.So(string(lua), cv.ShouldContainSubstring, "--gi:import \"math\"\n")
+		cv.So(strings.Contains(string(lua), "unreachable"), cv.ShouldBeFalse)
+
+		out := filepath.Join(dir, "prog.lua")
+		panicOn(ioutil.WriteFile(out, lua, 0644))
+		cv.So(RunBuilt(NewGIConfig(), out, nil), cv.ShouldBeNil)
+
+		all, dropped, err := BuildFile(NewGIConfig(), path, BuildOptions{KeepDeadCode: true})
+		panicOn(err)
+		cv.So(dropped, cv.ShouldBeEmpty)
+		cv.So(len(all), cv.ShouldBeGreaterThan, len(lua))
+
+		// its main still runs, and fails, as it would at the prompt.
+		panicOn(ioutil.WriteFile(out, []byte(strings.Replace(string(lua), `((root) == (4))`, `((root) == (5))`, 1)), 0644))
+		err = RunBuilt(NewGIConfig(), out, nil)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "wrong")
+	})
+}
+
+func Test1401BuildEmitsBytecode(t *testing.T) {
+
+	cv.Convey("gi build -emit=bytecode writes LuaJIT bytecode after the header, which gi run runs; -strip leaves out its debug info", t, func() {
+		dir, err := ioutil.TempDir("", "gi-build")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "prog.go")
+		panicOn(ioutil.WriteFile(path, []byte(`package main
+
+import "fmt"
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func main() {
+	if fib(10) != 55 {
+		panic("wrong")
+	}
+	fmt.Println("ok")
+}
+`), 0644))
+
+		src, _, err := BuildFile(NewGIConfig(), path, BuildOptions{})
+		panicOn(err)
+		bc, _, err := BuildFile(NewGIConfig(), path, BuildOptions{Emit: "bytecode"})
+		panicOn(err)
+		stripped, _, err := BuildFile(NewGIConfig(), path, BuildOptions{Emit: "bytecode", Strip: true})
+		panicOn(err)
+
+		header := "-- prog.lua: built by gi from prog.go; run it with gi run.\n--gi:import \"fmt\"\n--gi:bytecode\n\x1bLJ"
+		cv.So(string(bc), cv.ShouldStartWith, header)
+		cv.So(strings.Contains(string(bc), "fib(n - 1)"), cv.ShouldBeFalse)
+		cv.So(len(stripped), cv.ShouldBeLessThan, len(bc))
+		cv.So(string(src), cv.ShouldNotContainSubstring, builtBytecodeMarker)
+
+		for _, lua := range [][]byte{bc, stripped} {
+			out := filepath.Join(dir, "prog.lua")
+			panicOn(ioutil.WriteFile(out, lua, 0644))
+			cv.So(RunBuilt(NewGIConfig(), out, nil), cv.ShouldBeNil)
+		}
+
+		_, _, err = BuildFile(NewGIConfig(), path, BuildOptions{Emit: "wasm"})
+		cv.So(err.Error(), cv.ShouldContainSubstring, "unknown -emit 'wasm'")
+		_, _, err = BuildFile(NewGIConfig(), path, BuildOptions{Strip: true})
+		cv.So(err.Error(), cv.ShouldContainSubstring, "-strip is for -emit=bytecode")
+	})
+
+	cv.Convey("under -emit=bytecode, the Lua each import runs is kept in the import cache as bytecode, which later sessions load in its place", t, func() {
+		dir, err := ioutil.TempDir("", "gi-import-cache")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		cfg := NewGIConfig()
+		cfg.Emit = "bytecode"
+		cfg.ImportCache = dir
+		panicOn(cfg.ValidateConfig())
+
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		panicOn(it.Eval(`import "strings"
+a := strings.Repeat("ab", 2)`))
+		LuaMustString(it.lvm, "a", "abab")
+		it.Close()
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.ljbc"))
+		panicOn(err)
+		cv.So(len(files), cv.ShouldBeGreaterThan, 0)
+		cached, err := ioutil.ReadFile(files[0])
+		panicOn(err)
+		cv.So(string(cached), cv.ShouldStartWith, "\x1bLJ")
+
+		// what is found in the cache is what runs.
+		it2, err := NewInterp(cfg)
+		panicOn(err)
+		defer it2.Close()
+		marked, err := dumpLuaBytecode(it2.lvm.goro, "__gitestFromCache = true", "x", false)
+		panicOn(err)
+		for _, f := range files {
+			panicOn(ioutil.WriteFile(f, marked, 0600))
+		}
+		panicOn(it2.Eval(`import "strings"`))
+		LuaMustBool(it2.lvm, "__gitestFromCache", true)
+		again, err := filepath.Glob(filepath.Join(dir, "*.ljbc"))
+		panicOn(err)
+		cv.So(len(again), cv.ShouldEqual, len(files))
+
+		for _, bad := range []struct {
+			emit, allow string
+			strip       bool
+		}{{"wasm", "", false}, {"lua", "", true}, {"bytecode", "fs", false}} {
+			c := NewGIConfig()
+			c.Emit, c.SandboxAllow, c.Strip = bad.emit, bad.allow, bad.strip
+			cv.So(c.ValidateConfig(), cv.ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1388BuildConstraintsSelectFiles(t *testing.T) {
+
+	cv.Convey("gi test loads the files of a package directory that its //go:build lines and _GOOS/_GOARCH suffixes select, for -goos, -goarch and -tags", t, func() {
+		dir, err := ioutil.TempDir("", "gi-buildtags")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		for name, src := range map[string]string{
+			"plat_linux.go":   "package plat\n\nconst OS = \"linux\"\n",
+			"plat_windows.go": "package plat\n\nconst OS = \"windows\"\n",
+			"arch_arm64.go":   "package plat\n\nconst Arch = \"arm64\"\n",
+			"arch_other.go":   "//go:build !arm64\n\npackage plat\n\nconst Arch = \"other\"\n",
+			"fast.go":         "//go:build fast && !purego\n\npackage plat\n\nconst Speed = \"fast\"\n",
+			"slow.go":         "//go:build !fast || purego\n\npackage plat\n\nconst Speed = \"slow\"\n",
+			"cgo.go":          "//go:build cgo\n\npackage plat\n\nimport \"C\"\n",
+			"plat_test.go":    "package plat\n\nimport \"testing\"\n\nfunc TestPlat(t *testing.T) {\n\tt.Log(OS + \"/\" + Arch + \"/\" + Speed)\n}\n",
+		} {
+			panicOn(ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+		}
+
+		cfg := NewGIConfig()
+		cfg.GOOS, cfg.GOARCH, cfg.BuildTags = "windows", "arm64", "fast"
+		var out bytes.Buffer
+		passed, err := TestDir(cfg, &out, dir, TestOptions{Verbose: true})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeTrue)
+		cv.So(out.String(), cv.ShouldContainSubstring, "    windows/arm64/fast\n")
+
+		cfg.GOOS, cfg.GOARCH, cfg.BuildTags = "linux", "amd64", "fast,purego"
+		out.Reset()
+		passed, err = TestDir(cfg, &out, dir, TestOptions{Verbose: true})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeTrue)
+		cv.So(out.String(), cv.ShouldContainSubstring, "    linux/other/slow\n")
+
+		tp, err := loadTestDir(dir, cfg.buildContext())
+		panicOn(err)
+		var names []string
+		for name := range tp.sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cv.So(strings.Join(names, " "), cv.ShouldEqual, "arch_other.go plat_linux.go plat_test.go slow.go")
+	})
+}
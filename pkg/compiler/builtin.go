@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// REPL builtins are functions that inputs call as they call
+// len or print: they are seen from every scope, belong to no
+// package, and are shadowed by any declaration of their name.
+// The type checker is told of them with Checker.AddBuiltin;
+// their implementations live in the Lua table __gi_builtins.
+
+// AddBuiltin adds the function name, of signature sig, to the
+// REPL's builtins. kind says whether a call of it may stand
+// alone as a statement. lua is its implementation, a Lua
+// expression such as "function(x) print(x) end". Adding a
+// name again replaces the earlier function.
+func (it *Interp) AddBuiltin(name string, sig *types.Signature, kind types.BuiltinKind, lua string) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.inc.addBuiltin(name, sig, kind, lua)
+}
+
+// Builtins lists the names of the REPL's builtins, sorted.
+func (it *Interp) Builtins() []string {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	it.inc.pkgScope()
+	var names []string
+	for _, f := range it.inc.CurPkg.Arch.Check.Builtins() {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (tr *IncrState) addBuiltin(name string, sig *types.Signature, kind types.BuiltinKind, lua string) error {
+	if !isIdentifier(name) || name == "_" {
+		return fmt.Errorf("builtin %q: not an identifier", name)
+	}
+	if sig == nil || sig.Recv() != nil {
+		return fmt.Errorf("builtin %s: want a function signature, without a receiver", name)
+	}
+	err := tr.goro.newTicket(fmt.Sprintf("__gi_builtins = __gi_builtins or {}\n__gi_builtins[%q] = %s\n", name, lua), false).Do()
+	if err != nil {
+		return fmt.Errorf("builtin %s: %v", name, err)
+	}
+	tr.pkgScope()
+	tr.CurPkg.Arch.Check.AddBuiltin(name, sig, kind)
+	return nil
+}
+
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return name != "" && !token.Lookup(name).IsKeyword()
+}
+
+// addReplBuiltins adds the builtins every REPL has:
+//
+//	dump(x)  shows x, the fields of a struct or map in full.
+//	help(x)  shows the Go type of x and the methods it has.
+func (tr *IncrState) addReplBuiltins() error {
+	any := types.NewInterface(nil, nil).Complete()
+	one := types.NewTuple(types.NewVar(token.NoPos, nil, "x", any))
+	sig := types.NewSignature(nil, one, nil, false)
+	if err := tr.addBuiltin("dump", sig, types.StmtBuiltin, `__gi_dump`); err != nil {
+		return err
+	}
+	return tr.addBuiltin("help", sig, types.StmtBuiltin, `__gi_help`)
+}
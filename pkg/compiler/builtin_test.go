@@ -0,0 +1,73 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1335RegisteredBuiltinsActLikeLanguageBuiltins(t *testing.T) {
+
+	cv.Convey(`a function added with AddBuiltin is seen from every scope, is typed by the checker, runs the Lua given for it, and is shadowed by any declaration of its name`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		cv.So(it.Builtins(), cv.ShouldResemble, []string{"dump", "help"})
+
+		intT := types.Typ[types.Int]
+		twice := types.NewSignature(nil,
+			types.NewTuple(types.NewVar(token.NoPos, nil, "n", intT)),
+			types.NewTuple(types.NewVar(token.NoPos, nil, "", intT)), false)
+		panicOn(it.AddBuiltin("twice", twice, types.ExprBuiltin, `function(n) return 2*n end`))
+
+		note := types.NewSignature(nil,
+			types.NewTuple(types.NewVar(token.NoPos, nil, "s", types.Typ[types.String])), nil, false)
+		panicOn(it.AddBuiltin("note", note, types.StmtBuiltin, `function(s) __noted = s end`))
+
+		panicOn(it.Eval(`a := twice(21)`))
+		LuaMustInt64(it.lvm, "a", 42)
+
+		panicOn(it.Eval(`func f() int { return twice(2) + 1 }; b := f()`))
+		LuaMustInt64(it.lvm, "b", 5)
+
+		panicOn(it.Eval(`note("hello")`))
+		cv.So(luaGlobalString(it.lvm, "__noted"), cv.ShouldEqual, "hello")
+
+		// checked like any call.
+		err = it.Eval(`twice("x")`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, `cannot convert "x"`)
+
+		// an expression builtin's result must be used; at the
+		// prompt, it would be printed.
+		err = it.Eval(`func k() { twice(3) }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "is not used")
+
+		err = it.Eval(`c := note("x")`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "used as value")
+
+		// near misses are suggested.
+		err = it.Eval(`d := twcie(1)`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "did you mean twice?")
+
+		// a declaration shadows it, inside a function and at top level.
+		panicOn(it.Eval(`func g() int { twice := 7; return twice }; e := g()`))
+		LuaMustInt64(it.lvm, "e", 7)
+		panicOn(it.Eval(`func twice(n int) int { return 3*n }; h := twice(2)`))
+		LuaMustInt64(it.lvm, "h", 6)
+
+		// the REPL's own builtins take anything.
+		panicOn(it.Eval(`type P struct{ X int }; dump(P{1}); dump("s"); help(1)`))
+
+		err = it.AddBuiltin("func", note, types.StmtBuiltin, `print`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(strings.Contains(err.Error(), "not an identifier"), cv.ShouldBeTrue)
+	})
+}
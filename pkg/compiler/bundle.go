@@ -0,0 +1,122 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// gi build -bundle makes one executable of a program: a copy
+// of gi itself, which has LuaJIT, the prelude and the
+// shadowed packages in it already, with the Lua gi build
+// made appended. When gi starts, it looks at the end of its
+// own executable, and if it finds a program there, runs it
+// with its arguments instead of being gi:
+//
+//	gi build -bundle -o app prog.go
+//	./app arguments...
+//
+// The trailer after the Lua is the program's name, its
+// length and the Lua's, 8 bytes each, little endian, then
+// bundleMagic.
+
+// bundleMagic ends an executable that has a program in it.
+const bundleMagic = "\x00gi-bundle\x00"
+
+// bundleTrailerLen is the length of what follows the Lua
+// and the name.
+const bundleTrailerLen = 16 + len(bundleMagic)
+
+// WriteBundle writes to out a copy of the running gi with
+// lua, the program gi build made of the file name, in it.
+func WriteBundle(out, name string, lua []byte) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err := ioutil.ReadFile(self)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, appendBundle(exe, name, lua), 0755)
+}
+
+// appendBundle returns exe with the program lua, named
+// name, in it; a program exe had already is replaced.
+func appendBundle(exe []byte, name string, lua []byte) []byte {
+	if _, _, n, ok := splitBundle(exe); ok {
+		exe = exe[:n]
+	}
+	var b bytes.Buffer
+	b.Write(exe)
+	b.Write(lua)
+	b.WriteString(name)
+	var lens [16]byte
+	binary.LittleEndian.PutUint64(lens[:8], uint64(len(name)))
+	binary.LittleEndian.PutUint64(lens[8:], uint64(len(lua)))
+	b.Write(lens[:])
+	b.WriteString(bundleMagic)
+	return b.Bytes()
+}
+
+// splitBundle returns the name and the Lua of the program
+// in exe, and the length of the executable before them;
+// ok is false if exe has no program in it.
+func splitBundle(exe []byte) (name string, lua []byte, n int, ok bool) {
+	if len(exe) < bundleTrailerLen || !bytes.HasSuffix(exe, []byte(bundleMagic)) {
+		return "", nil, 0, false
+	}
+	lens := exe[len(exe)-bundleTrailerLen:]
+	nameLen := binary.LittleEndian.Uint64(lens[:8])
+	luaLen := binary.LittleEndian.Uint64(lens[8:16])
+	end := uint64(len(exe) - bundleTrailerLen)
+	if nameLen > end || luaLen > end-nameLen {
+		return "", nil, 0, false
+	}
+	start := end - nameLen - luaLen
+	return string(exe[start+luaLen : end]), exe[start : start+luaLen], int(start), true
+}
+
+// BundledProgram returns the program in the running
+// executable, if gi build -bundle put one there.
+func BundledProgram() (name string, lua []byte, ok bool) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", nil, false
+	}
+	f, err := os.Open(self)
+	if err != nil {
+		return "", nil, false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.Size() < int64(bundleTrailerLen) {
+		return "", nil, false
+	}
+	// look at the trailer before reading all of gi.
+	tail := make([]byte, len(bundleMagic))
+	if _, err := f.ReadAt(tail, fi.Size()-int64(len(tail))); err != nil || string(tail) != bundleMagic {
+		return "", nil, false
+	}
+	exe, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", nil, false
+	}
+	name, lua, _, ok = splitBundle(exe)
+	return name, lua, ok
+}
+
+// GiBundleMain runs the program lua, built from the file
+// name, that the running executable has in it; args are
+// os.Args. It returns the exit code.
+func GiBundleMain(cfg *GIConfig, name string, lua []byte, args []string) int {
+	c := *cfg
+	c.Quiet = true
+	if err := runBuiltLua(&c, args[0], string(lua), args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
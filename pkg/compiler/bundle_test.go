@@ -0,0 +1,52 @@
+package compiler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1400BundleCarriesItsProgram(t *testing.T) {
+
+	cv.Convey("gi build -bundle appends the program to a copy of gi, which finds it again at the end of its executable and runs it", t, func() {
+		dir, err := ioutil.TempDir("", "gi-bundle")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "prog.go")
+		panicOn(ioutil.WriteFile(path, []byte(`package main
+
+func main() {
+	if 6*7 != 42 {
+		panic("wrong")
+	}
+}
+`), 0644))
+		lua, _, err := BuildFile(NewGIConfig(), path, BuildOptions{})
+		panicOn(err)
+
+		exe := []byte("\x7fELF, as it were")
+		_, _, _, ok := splitBundle(exe)
+		cv.So(ok, cv.ShouldBeFalse)
+
+		app := appendBundle(exe, "prog.go", lua)
+		name, got, n, ok := splitBundle(app)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(name, cv.ShouldEqual, "prog.go")
+		cv.So(bytes.Equal(got, lua), cv.ShouldBeTrue)
+		cv.So(n, cv.ShouldEqual, len(exe))
+
+		// bundling again replaces the program, not adds to it.
+		again := appendBundle(app, "other.go", []byte("main = function() end"))
+		name, got, n, ok = splitBundle(again)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(name, cv.ShouldEqual, "other.go")
+		cv.So(string(got), cv.ShouldEqual, "main = function() end")
+		cv.So(n, cv.ShouldEqual, len(exe))
+
+		cv.So(GiBundleMain(NewGIConfig(), name, lua, []string{"app", "x"}), cv.ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,369 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/types"
+)
+
+// evalHookCount is how many VM instructions run
+// between checks for cancellation of an eval.
+const evalHookCount = 1000
+
+var (
+	// ErrInstructionLimit: the eval ran more
+	// than GIConfig.MaxEvalInstructions.
+	ErrInstructionLimit = errors.New("instruction limit exceeded")
+
+	// ErrHeapLimit: the Lua heap grew by more than
+	// GIConfig.MaxEvalHeapKB during one eval, even
+	// after a full garbage collection.
+	ErrHeapLimit = errors.New("heap growth limit exceeded")
+
+	// ErrInterrupted: the eval was interrupted
+	// by Ctrl-C at the prompt.
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// evalInterrupt carries a cancellation request from
+// any goroutine over to the Lua count hook, which
+// runs on whichever thread is executing the vm. It
+// also keeps the resource budget of the current eval.
+type evalInterrupt struct {
+	mu      sync.Mutex
+	running bool // between begin and end
+	cause   error
+	fired   bool
+
+	// budget for the current eval; zero means unlimited.
+	ticks      int64
+	maxTicks   int64
+	heapBaseKB float64
+	maxHeapKB  float64
+}
+
+// begin resets the interrupt state for a new eval.
+func (e *evalInterrupt) begin(maxTicks int64, maxHeapKB int64) {
+	e.mu.Lock()
+	e.running = true
+	e.cause = nil
+	e.fired = false
+	e.ticks = 0
+	e.maxTicks = maxTicks
+	e.heapBaseKB = 0
+	e.maxHeapKB = float64(maxHeapKB)
+	e.mu.Unlock()
+}
+
+// setHeapBase is called from Lua as __gi_interruptSetHeapBase,
+// just before the hook is installed.
+func (e *evalInterrupt) setHeapBase(kb float64) {
+	e.mu.Lock()
+	e.heapBaseKB = kb
+	e.mu.Unlock()
+}
+
+// request asks the running eval to stop, citing cause.
+func (e *evalInterrupt) request(cause error) {
+	e.mu.Lock()
+	if e.running && e.cause == nil {
+		e.cause = cause
+	}
+	e.mu.Unlock()
+}
+
+// check is called from Lua as __gi_interruptCheck, from
+// the count hook, with the current heap size in KB. It
+// returns the message to raise as an error, or "" to carry
+// on. If the heap looks too big, check asks for a full
+// collection first, by returning wantGC; the hook then calls
+// again with afterGC set. Once the eval is to stop, every
+// check until end returns the message, so that code that
+// gets past the error, as a deferred recover would try to,
+// is stopped again at the next hook.
+func (e *evalInterrupt) check(heapKB float64, afterGC bool) (msg string, wantGC bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.running {
+		return "", false
+	}
+	if !afterGC {
+		e.ticks++
+	}
+	if e.cause == nil && e.maxTicks > 0 && e.ticks > e.maxTicks {
+		e.cause = ErrInstructionLimit
+	}
+	if e.cause == nil && e.maxHeapKB > 0 && heapKB-e.heapBaseKB > e.maxHeapKB {
+		if !afterGC {
+			return "", true
+		}
+		e.cause = ErrHeapLimit
+	}
+	if e.cause == nil {
+		return "", false
+	}
+	e.fired = true
+	return "interrupted: " + e.cause.Error(), false
+}
+
+// end clears the state after an eval, and returns the
+// cause if the eval was actually interrupted. A request
+// that arrived too late to be seen is dropped, so
+// it cannot leak into the next eval; and from end on,
+// the hook raises nothing, so that it can be cleared.
+func (e *evalInterrupt) end() (cause error) {
+	e.mu.Lock()
+	e.running = false
+	if e.fired {
+		cause = e.cause
+	}
+	e.cause = nil
+	e.fired = false
+	e.maxTicks = 0
+	e.maxHeapKB = 0
+	e.mu.Unlock()
+	return
+}
+
+// ErrEvalCanceled is the error returned when an eval
+// is stopped before it finishes, either by its context
+// or by one of the per-eval resource limits. A context
+// canceled with a cause reports that cause.
+type ErrEvalCanceled struct {
+	Cause error
+}
+
+func (e *ErrEvalCanceled) Error() string {
+	return fmt.Sprintf("eval canceled: %v", e.Cause)
+}
+
+// EvalContext is like Eval, but ctx can cancel the evaluation
+// while it runs. On cancellation the running Lua is aborted
+// at its next hook check, which no recover in src can
+// stop, and definitions made by src are rolled
+// back, both in the type checker's scope and in the Lua globals,
+// so the session is left as it was before src. The limits in
+// the Interp's GIConfig are enforced the same way. If only
+// some top level parts of src type check, the others are left
+// out, and an *ErrPartialInput is returned once the rest has run.
+func (it *Interp) EvalContext(ctx context.Context, src string) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return fmt.Errorf("Interp is closed")
+	}
+
+	scope := it.inc.pkgScope()
+	snap := takeScopeSnapshot(scope)
+
+	if chunks := splitForPipeline(it.inc, []byte(src)); chunks != nil {
+		return it.evalPipelined(ctx, []byte(src), chunks, scope, snap)
+	}
+	translation, err := translateAndCatchPanic(it.inc, []byte(src))
+	partial, isPartial := err.(*ErrPartialInput)
+	if err != nil && !isPartial {
+		return err
+	}
+	if isPartial {
+		src = partial.kept
+	}
+	it.evalCount++
+
+	if it.cfg.Stats {
+		it.lastStats, err = measure(it.lvm, func() error {
+			return it.runGuarded(ctx, translation, true, scope, snap)
+		})
+	} else {
+		err = it.runGuarded(ctx, translation, true, scope, snap)
+	}
+	if err != nil {
+		return err
+	}
+	it.recordSource(src)
+	it.lastDiff = diffScope(snap, scope)
+	if err := it.lastEvalError(); err != nil {
+		return err
+	}
+	if isPartial {
+		return partial
+	}
+	return nil
+}
+
+// hasLimits reports whether any per-eval limit is set.
+func (c *GIConfig) hasLimits() bool {
+	return c.MaxEvalInstructions > 0 || c.MaxEvalHeapKB > 0 || c.MaxEvalTime > 0
+}
+
+// runGuarded runs already translated code under ctx and
+// the configured resource limits. If the run is interrupted,
+// scope is rolled back to snap, the Lua values of the names
+// (re)defined are restored, and an *ErrEvalCanceled is
+// returned. scope may be nil, as in raw Lua mode, to
+// skip the rollback. Otherwise the error from LuaRun
+// is returned, or an *ErrDeadlock if the code run on the
+// eval coroutine blocked for good. The caller must hold it.mut.
+func (it *Interp) runGuarded(ctx context.Context, lua string, useEval bool, scope *types.Scope, snap scopeSnapshot) (err error) {
+	if useEval {
+		defer func() {
+			if err == nil {
+				err = it.checkDeadlock()
+			}
+		}()
+	}
+
+	cfg := it.cfg
+	if cfg.MaxEvalTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxEvalTime)
+		defer cancel()
+	}
+	if ctx.Done() == nil && !cfg.hasLimits() {
+		// can never be interrupted, skip the hook.
+		return LuaRun(it.lvm, lua, useEval)
+	}
+
+	var changed []string
+	if scope != nil {
+		if ctx.Err() != nil {
+			snap.restore(scope)
+			it.inc.forgetLastInput()
+			return &ErrEvalCanceled{Cause: context.Cause(ctx)}
+		}
+		changed = snap.changedNames(scope)
+		panicOn(LuaRun(it.lvm, saveLuaGlobalsCode(changed, scope), false))
+	}
+
+	count := int64(evalHookCount)
+	var maxTicks int64
+	if n := cfg.MaxEvalInstructions; n > 0 {
+		if n < count {
+			count = n
+		}
+		maxTicks = (n + count - 1) / count
+	}
+	it.lvm.intr.begin(maxTicks, cfg.MaxEvalHeapKB)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				it.lvm.intr.request(context.Cause(ctx))
+			case <-stop:
+			}
+		}()
+	}
+
+	panicOn(LuaRun(it.lvm, fmt.Sprintf("__gi_setEvalHook(%d)", count), false))
+	err = LuaRun(it.lvm, lua, useEval)
+	cause := it.lvm.intr.end()
+	panicOn(LuaRun(it.lvm, "__gi_clearEvalHook()", false))
+
+	if cause == nil {
+		if scope != nil {
+			panicOn(LuaRun(it.lvm, "__gi_rollbackSaved = nil;", false))
+		}
+		return err
+	}
+	if scope != nil {
+		snap.restore(scope)
+		it.inc.forgetLastInput()
+		panicOn(LuaRun(it.lvm, restoreLuaGlobalsCode(changed), false))
+	}
+	return &ErrEvalCanceled{Cause: cause}
+}
+
+// pkgScope returns the scope of the package being
+// built up at the prompt, creating the package
+// first if nothing has been entered yet.
+func (tr *IncrState) pkgScope() *types.Scope {
+	if tr.CurPkg.Arch == nil {
+		tr.trMust(nil)
+	}
+	return tr.CurPkg.Arch.Pkg.Scope()
+}
+
+// scopeSnapshot records the objects of a package
+// scope, so that later changes can be undone.
+type scopeSnapshot map[string]types.Object
+
+func takeScopeSnapshot(s *types.Scope) scopeSnapshot {
+	snap := make(scopeSnapshot)
+	for _, name := range s.Names() {
+		snap[name] = s.Lookup(name)
+	}
+	return snap
+}
+
+// changedNames lists, sorted, the names added to s
+// or redefined in s since the snapshot was taken.
+func (snap scopeSnapshot) changedNames(s *types.Scope) (names []string) {
+	for _, name := range s.Names() {
+		if snap[name] != s.Lookup(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// restore puts s back the way it was at snapshot time.
+func (snap scopeSnapshot) restore(s *types.Scope) {
+	for _, name := range s.Names() {
+		if _, had := snap[name]; !had {
+			s.DeleteByName(name)
+		}
+	}
+	for name, obj := range snap {
+		if s.Lookup(name) != obj {
+			s.Replace(obj)
+		}
+	}
+}
+
+// luaGlobalRef returns the Lua expression holding the
+// value of the package level object named name.
+func luaGlobalRef(name string, obj types.Object) string {
+	if _, isType := obj.(*types.TypeName); isType {
+		return "__type__[" + strconv.Quote(luaName(name)) + "]"
+	}
+	return "_G[" + strconv.Quote(luaName(name)) + "]"
+}
+
+// saveLuaGlobalsCode generates Lua that stashes the current
+// values of the names about to be (re)defined. Values are
+// boxed in a table so that nil survives the trip.
+func saveLuaGlobalsCode(names []string, s *types.Scope) string {
+	return "__gi_rollbackSaved = {};\n" + addSavedLuaGlobalsCode(names, s)
+}
+
+// addSavedLuaGlobalsCode saves the values of names as well,
+// alongside those saved already.
+func addSavedLuaGlobalsCode(names []string, s *types.Scope) string {
+	var b strings.Builder
+	for _, name := range names {
+		ref := luaGlobalRef(name, s.Lookup(name))
+		fmt.Fprintf(&b, "__gi_rollbackSaved[%q] = {ref=%q, val=%s};\n", name, ref, ref)
+	}
+	return b.String()
+}
+
+// restoreLuaGlobalsCode undoes what an interrupted eval assigned.
+func restoreLuaGlobalsCode(names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "do local s = __gi_rollbackSaved[%q]; "+
+			"if s.ref:sub(1,8) == '__type__' then __type__[%q] = s.val else _G[%q] = s.val end end;\n",
+			name, name, name)
+	}
+	b.WriteString("__gi_rollbackSaved = nil;\n")
+	return b.String()
+}
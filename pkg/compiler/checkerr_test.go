@@ -0,0 +1,48 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// panickyImporter stands in for a checker bug: it
+// panics from inside the checker.
+type panickyImporter struct{}
+
+func (panickyImporter) Import(path string) (*types.Package, error) {
+	panic("lost track of " + path)
+}
+
+func Test1327CheckerPanicsBecomeDiagnostics(t *testing.T) {
+
+	cv.Convey(`a panic inside the type checker comes back as an InternalError asking for a bug report, while a panic from Config.Error is passed on`, t, func() {
+		check := func(conf *types.Config, src string) error {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "x.go", src, 0)
+			panicOn(err)
+			_, _, err = conf.Check(nil, nil, "x", fset, []*ast.File{f}, nil, nil)
+			return err
+		}
+
+		err := check(&types.Config{Importer: panickyImporter{}}, "package x\nimport \"boom\"\n")
+		ie, ok := err.(types.InternalError)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(ie.Msg, cv.ShouldEqual, "lost track of boom")
+		cv.So(len(ie.Stack), cv.ShouldBeGreaterThan, 0)
+		cv.So(strings.HasPrefix(ie.Error(), "internal error in the type checker: lost track of boom\n"), cv.ShouldBeTrue)
+		cv.So(ie.Error(), cv.ShouldContainSubstring, types.BugReportURL)
+
+		var recovered interface{}
+		func() {
+			defer func() { recovered = recover() }()
+			check(&types.Config{Error: func(error) { panic("mine") }}, "package x\nvar v int = \"s\"\n")
+		}()
+		cv.So(recovered, cv.ShouldEqual, "mine")
+	})
+}
@@ -0,0 +1,116 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The WebSocket clients of a Server share one session, as
+// for teaching or remote debugging, and see each other: each
+// has a ServeClient, which the others are told of as it
+// joins ("joined"), is renamed ("renamed"), moves its
+// cursor ("cursor"), and leaves ("left"), besides its evals
+// ("evaluated").
+//
+// A client names itself in the name query parameter of /ws,
+// or later with op "hello"; op "clients" lists them all.
+// With op "cursor", a client shares what it is typing, in
+// Code, and where, in Cursor, for the others to follow.
+
+// ServeClient is a client of a Server, as the other
+// clients see it.
+type ServeClient struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Code   string `json:"code,omitempty"`
+	Cursor int    `json:"cursor,omitempty"`
+}
+
+// join adds c, as its handshake is answered, so that it
+// misses nothing sent to the clients from then on; it
+// returns c as the others see it, for announce.
+func (s *Server) join(c *wsConn, name string) ServeClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientID++
+	if name == "" {
+		name = fmt.Sprintf("client %d", s.clientID)
+	}
+	cl := &ServeClient{ID: s.clientID, Name: name}
+	s.clients[c] = cl
+	return *cl
+}
+
+// announce tells the clients but c that c, cl, has joined.
+func (s *Server) announce(c *wsConn, cl ServeClient) {
+	s.broadcast(ServeReply{Op: "joined", Client: &cl}, c)
+}
+
+// leave removes c, and tells the others.
+func (s *Server) leave(c *wsConn) {
+	s.mu.Lock()
+	cl, ok := s.clients[c]
+	delete(s.clients, c)
+	s.mu.Unlock()
+	if ok {
+		left := ServeClient{ID: cl.ID, Name: cl.Name}
+		s.broadcast(ServeReply{Op: "left", Client: &left}, c)
+	}
+}
+
+// hello renames c, if req names it, and returns it and
+// the other clients.
+func (s *Server) hello(c *wsConn, req ServeRequest) ServeReply {
+	s.mu.Lock()
+	cl := s.clients[c]
+	renamed := req.Name != "" && req.Name != cl.Name
+	if renamed {
+		cl.Name = req.Name
+	}
+	me := *cl
+	s.mu.Unlock()
+	if renamed {
+		who := ServeClient{ID: me.ID, Name: me.Name}
+		s.broadcast(ServeReply{Op: "renamed", Client: &who}, c)
+	}
+	return ServeReply{ID: req.ID, Op: "hello", Client: &me, Clients: s.clientList()}
+}
+
+// moveCursor records what c is typing, and where, and
+// tells the others.
+func (s *Server) moveCursor(c *wsConn, req ServeRequest) {
+	s.mu.Lock()
+	cl := s.clients[c]
+	cl.Code = req.Code
+	cl.Cursor = len(req.Code)
+	if req.Cursor != nil && *req.Cursor >= 0 && *req.Cursor <= len(req.Code) {
+		cl.Cursor = *req.Cursor
+	}
+	moved := *cl
+	s.mu.Unlock()
+	s.broadcast(ServeReply{Op: "cursor", Client: &moved}, c)
+}
+
+// client returns c, as the others see it, without what it
+// is typing; nil for an HTTP client.
+func (s *Server) client(c *wsConn) *ServeClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cl, ok := s.clients[c]
+	if !ok {
+		return nil
+	}
+	return &ServeClient{ID: cl.ID, Name: cl.Name}
+}
+
+// clientList returns the clients, in the order they joined.
+func (s *Server) clientList() []ServeClient {
+	s.mu.Lock()
+	list := make([]ServeClient, 0, len(s.clients))
+	for _, cl := range s.clients {
+		list = append(list, *cl)
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
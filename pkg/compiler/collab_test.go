@@ -0,0 +1,82 @@
+package compiler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1375ClientsOfASessionSeeEachOther(t *testing.T) {
+
+	cv.Convey("the WebSocket clients of gi serve are told who joins, leaves and is renamed, where each is typing, and who ran each eval", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		s, err := NewServer(it, "sekrit")
+		panicOn(err)
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		alice := serveDial(srv, "token=sekrit&name=alice")
+		defer alice.Close()
+		bob := serveDial(srv, "token=sekrit&name=bob")
+		defer bob.Close()
+		r := wsRead(alice)
+		cv.So(r.Op, cv.ShouldEqual, "joined")
+		cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 2, Name: "bob"})
+
+		carol := serveDial(srv, "token=sekrit")
+		defer carol.Close()
+		cv.So(wsRead(alice).Client.Name, cv.ShouldEqual, "client 3")
+		cv.So(wsRead(bob).Client.Name, cv.ShouldEqual, "client 3")
+
+		panicOn(carol.WriteMessage([]byte(`{"id": 1, "op": "hello", "name": "carol"}`)))
+		r = wsRead(carol)
+		cv.So(r.ID, cv.ShouldEqual, 1)
+		cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 3, Name: "carol"})
+		cv.So(r.Clients, cv.ShouldResemble, []ServeClient{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}, {ID: 3, Name: "carol"}})
+		for _, c := range []*wsConn{alice, bob} {
+			r = wsRead(c)
+			cv.So(r.Op, cv.ShouldEqual, "renamed")
+			cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 3, Name: "carol"})
+		}
+
+		// bob types; the others follow his cursor.
+		panicOn(bob.WriteMessage([]byte(`{"op": "cursor", "code": "x := 1\ny := x + ", "cursor": 7}`)))
+		for _, c := range []*wsConn{alice, carol} {
+			r = wsRead(c)
+			cv.So(r.Op, cv.ShouldEqual, "cursor")
+			cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 2, Name: "bob", Code: "x := 1\ny := x + ", Cursor: 7})
+		}
+		panicOn(carol.WriteMessage([]byte(`{"id": 2, "op": "clients"}`)))
+		r = wsRead(carol)
+		cv.So(r.Clients[1].Code, cv.ShouldEqual, "x := 1\ny := x + ")
+
+		// alice evals; the others see it was her.
+		panicOn(alice.WriteMessage([]byte(`{"id": 3, "op": "eval", "code": "z := 3"}`)))
+		cv.So(wsRead(alice).ID, cv.ShouldEqual, 3)
+		for _, c := range []*wsConn{bob, carol} {
+			r = wsRead(c)
+			cv.So(r.Op, cv.ShouldEqual, "evaluated")
+			cv.So(r.Code, cv.ShouldEqual, "z := 3")
+			cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 1, Name: "alice"})
+		}
+
+		// an eval over HTTP is from no client.
+		_, reply := serveCall(srv, "sekrit", "eval", ServeRequest{Code: "w := 4"})
+		cv.So(reply.Error, cv.ShouldEqual, "")
+		r = wsRead(bob)
+		cv.So(r.Op, cv.ShouldEqual, "evaluated")
+		cv.So(r.Client, cv.ShouldBeNil)
+		wsRead(alice)
+		wsRead(carol)
+
+		carol.writeFrame(wsClose, nil)
+		for _, c := range []*wsConn{alice, bob} {
+			r = wsRead(c)
+			cv.So(r.Op, cv.ShouldEqual, "left")
+			cv.So(*r.Client, cv.ShouldResemble, ServeClient{ID: 3, Name: "carol"})
+		}
+	})
+}
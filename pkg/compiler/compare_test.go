@@ -0,0 +1,128 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1360ComparingStructsArraysAndInterfaces(t *testing.T) {
+
+	cv.Convey("== and != on comparable composites, and on interfaces by their dynamic types, should follow the spec, as map keys should", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type P struct{ X, Y int; S string }
+a := P{1, 2, "s"}
+b := P{1, 2, "s"}
+c := P{1, 3, "s"}
+type Q struct{ p P; arr [2]string; f float64 }
+q1 := Q{a, [2]string{"x", "y"}, 1.5}
+q2 := Q{b, [2]string{"x", "y"}, 1.5}
+x := [3]int{1, 2, 3}
+y := [3]int{1, 2, 3}
+same := a == b && a != c && q1 == q2 && x == y && x != [3]int{1, 2, 4}
+pa, pb := &a, &b
+pointers := pa == &a && pa != pb`))
+		LuaMustBool(it.lvm, "same", true)
+		LuaMustBool(it.lvm, "pointers", true)
+
+		// interfaces are equal when their dynamic types and
+		// values are.
+		panicOn(it.Eval(`var i1, i2, i3, i4 interface{} = 3, 3, "3", 3.0
+var j1, j2 interface{} = P{1, 2, "s"}, b
+var e1, e2 error
+faces := i1 == i2 && i1 != i3 && i1 != i4 && j1 == j2 && j1 == a && e1 == e2 && e1 == nil`))
+		LuaMustBool(it.lvm, "faces", true)
+
+		// map keys use the same equality.
+		panicOn(it.Eval(`m := map[P]int{}
+m[P{1, 2, "s"}] = 5
+m[b] = 6
+ma := map[[2]int]string{{1, 2}: "a"}
+mi := map[interface{}]int{}
+mi[1] = 1
+mi["1"] = 2
+mi[P{1, 2, "s"}] = 3
+mi[a] = 4
+keys := len(m)*100 + len(mi)*10 + mi[b]
+fromArray := ma[[2]int{1, 2}]`))
+		LuaMustInt64(it.lvm, "keys", 134)
+		LuaMustString(it.lvm, "fromArray", "a")
+
+		// comparing uncomparable dynamic types panics.
+		err = it.Eval(`var k1, k2 interface{} = []int{1}, []int{1}
+bad := k1 == k2`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "comparing uncomparable type []int")
+
+		err = it.Eval(`var f1 interface{} = map[int]int{}
+bad2 := f1 == f1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "comparing uncomparable type map[int]int")
+
+		err = it.Eval(`mi[k1] = 1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "hash of unhashable type []int")
+	})
+
+	cv.Convey("a named or sized basic value keeps its dynamic type in an interface, for ==, map keys, type assertions and switches", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type MyInt int
+func (m MyInt) Twice() int { return int(m) * 2 }
+type Twicer interface{ Twice() int }
+var a interface{} = MyInt(1)
+var b interface{} = 1
+ab := a == b
+wide := interface{}(1) == interface{}(int64(1))
+same := a == MyInt(1) && b == 1 && interface{}(int64(1)) == interface{}(int64(1))`))
+		LuaMustBool(it.lvm, "ab", false)
+		LuaMustBool(it.lvm, "wide", false)
+		LuaMustBool(it.lvm, "same", true)
+
+		panicOn(it.Eval(`var one64 int64 = 1
+m1 := map[interface{}]int{}
+m1[1] = 1
+m1[one64] = 2
+m2 := map[interface{}]int{}
+m2[MyInt(1)] = 1
+m2[1] = 2
+lens := len(m1)*10 + len(m2)
+got := m1[1]*1000 + m1[one64]*100 + m2[MyInt(1)]*10 + m2[1]`))
+		LuaMustInt64(it.lvm, "lens", 22)
+		LuaMustInt64(it.lvm, "got", 1212)
+
+		panicOn(it.Eval(`_, isMy := a.(MyInt)
+_, isInt := a.(int)
+tw, isTwicer := a.(Twicer)
+twice := tw.Twice()
+kind := ""
+switch v := a.(type) {
+case int:
+	kind = "int"
+case MyInt:
+	kind = "MyInt"
+	twice += int(v)
+}
+switch b.(type) {
+case MyInt, int64:
+	kind += " sized"
+case int:
+	kind += " int"
+}`))
+		LuaMustBool(it.lvm, "isMy", true)
+		LuaMustBool(it.lvm, "isInt", false)
+		LuaMustBool(it.lvm, "isTwicer", true)
+		LuaMustInt64(it.lvm, "twice", 3)
+		LuaMustString(it.lvm, "kind", "MyInt int")
+
+		// Go is given the plain value.
+		panicOn(it.Eval(`import "fmt"
+printed := fmt.Sprint(a, int64(2), 'x', float32(1.5))`))
+		LuaMustString(it.lvm, "printed", "1 2 120 1.5")
+	})
+}
@@ -19,6 +19,10 @@ import (
 //var sizes32 = &types.StdSizes{WordSize: 4, MaxAlign: 8}
 var sizes64 = &types.StdSizes{WordSize: 8, MaxAlign: 8}
 var reservedKeywords = make(map[string]bool)
+
+// luaReserved are the names Lua or gi's runtime hold, which
+// a Go name must not take in Lua; see luaName.
+var luaReserved = make(map[string]bool)
 var predeclared = make(map[string]bool)
 
 func init() {
@@ -36,11 +40,13 @@ func init() {
 	// lua reserved words
 	for _, w := range []string{"and", "break", "do", "else", "elseif", "", "end", "false", "for", "function", "if", "in", "local", "nil", "not", "or", "repeat", "return", "then", "true", "until", "while"} {
 		reservedKeywords[w] = true
+		luaReserved[w] = true
 	}
 
 	// lua/gijit system already in-use method names
 	for _, w := range []string{"_G", "_VERSION", "assert", "bit", "byte", "cmath", "collectgarbage", "complex", "complex128", "complex64", "coroutine", "debug", "dofile", "error", "float32", "float64", "gcinfo", "getfenv", "getmetatable", "golua_default_msghandler", "imag", "int", "int16", "int32", "int64", "int8", "io", "ipairs", "jit", "load", "loadfile", "loadstring", "luar", "math", "module", "newproxy", "next", "os", "package", "pairs", "panic", "pcall", "print", "rawequal", "rawget", "rawlen", "rawset", "real", "recover", "require", "select", "setfenv", "setmetatable", "string", "table", "tonumber", "tostring", "type", "uint", "uint16", "uint32", "uint64", "uint8", "unpack", "xpcall"} {
 		reservedKeywords[w] = true
+		luaReserved[w] = true
 	}
 
 }
@@ -62,6 +68,10 @@ type Archive struct {
 	Minified     bool
 	NewCodeText  [][]byte
 
+	// NewCodeStarts has where the translation of each top
+	// level node begins in NewCodeText.
+	NewCodeStarts []CodeStart
+
 	// save state so we can type incrementally
 	TypesInfo *types.Info
 	Config    *types.Config
@@ -71,6 +81,13 @@ type Archive struct {
 	FuncSrcCache map[string]string
 }
 
+// CodeStart is where the translation of a top level node
+// begins: Text indexes NewCodeText.
+type CodeStart struct {
+	Pos  token.Pos
+	Text int
+}
+
 type Decl struct {
 	FullName        string
 	Vars            []string
@@ -0,0 +1,286 @@
+package compiler
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Complete suggests completions of the identifier that
+// ends at byte offset cursor of src, a line being typed:
+// after x., the fields and methods of x, or the exported
+// members of the package x; and otherwise the names in
+// scope, those of the func being typed first, and the
+// keywords. It returns the offset where the identifier
+// starts, which the candidates replace, and the candidates
+// in order.
+func (it *Interp) Complete(src string, cursor int) (start int, candidates []string) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return cursor, nil
+	}
+	if cursor < 0 || cursor > len(src) {
+		cursor = len(src)
+	}
+	line := src[:cursor]
+	start = identStart(line)
+	prefix := line[start:]
+
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) && !seen[name] && !strings.HasPrefix(name, "__") {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+
+	scope := it.inc.pkgScope()
+	typed := it.typeLine(line)
+	if start > 0 && line[start-1] == '.' {
+		x := line[selectorStart(line[:start-1]) : start-1]
+		if typed != nil && typed.completeSelector(start-1, add) {
+			sort.Strings(candidates)
+			return start, candidates
+		}
+		if x == "" {
+			return start, nil
+		}
+		it.completeSelector(x, add)
+	} else {
+		var locals []string
+		if typed != nil {
+			locals = typed.locals(scope)
+		}
+		for _, name := range locals {
+			add(name)
+		}
+		var names []string
+		names = append(names, scope.Names()...)
+		names = append(names, types.Universe.Names()...)
+		for tok := token.BREAK; tok <= token.VAR; tok++ {
+			if tok.IsKeyword() {
+				names = append(names, tok.String())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(name)
+		}
+		return start, candidates
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// typedLine is a line being typed, type checked as far as
+// it parses in the parser's Tolerant mode.
+type typedLine struct {
+	base int       // of the line in its file set
+	end  token.Pos // of the line, before what closeOpen added
+	lit  *ast.FuncLit
+	info *types.Info
+}
+
+// typeLine type checks line as the body of a func literal:
+// that of the func being declared, if the line ends inside
+// one, else the line's statements. It returns nil if line
+// declares nothing to check.
+func (it *Interp) typeLine(line string) *typedLine {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", line, parser.Tolerant)
+	if file == nil || len(file.Nodes) == 0 {
+		return nil
+	}
+	tf := fset.File(file.Nodes[0].Pos())
+	if tf == nil {
+		return nil
+	}
+	var lit *ast.FuncLit
+	switch d := file.Nodes[len(file.Nodes)-1].(type) {
+	case *ast.FuncDecl:
+		if d.Body == nil {
+			return nil
+		}
+		ft := *d.Type
+		if d.Recv != nil {
+			// the receiver is a parameter like the others.
+			params := append(append([]*ast.Field{}, d.Recv.List...), ft.Params.List...)
+			ft.Params = &ast.FieldList{List: params}
+		}
+		lit = &ast.FuncLit{Type: &ft, Body: d.Body}
+	default:
+		body := &ast.BlockStmt{Lbrace: token.Pos(tf.Base()), Rbrace: token.Pos(tf.Base() + tf.Size())}
+		for _, n := range file.Nodes {
+			switch x := n.(type) {
+			case ast.Stmt:
+				body.List = append(body.List, x)
+			case *ast.GenDecl:
+				if x.Tok != token.IMPORT {
+					body.List = append(body.List, &ast.DeclStmt{Decl: x})
+				}
+			}
+		}
+		lit = &ast.FuncLit{Type: &ast.FuncType{Params: &ast.FieldList{}}, Body: body}
+	}
+	t := &typedLine{
+		base: tf.Base(),
+		end:  token.Pos(tf.Base() + len(line)),
+		lit:  lit,
+		info: &types.Info{
+			Types:  make(map[ast.Expr]types.TypeAndValue),
+			Defs:   make(map[*ast.Ident]types.Object),
+			Uses:   make(map[*ast.Ident]types.Object),
+			Scopes: make(map[ast.Node]*types.Scope),
+		},
+	}
+	conf := &types.Config{Error: func(error) {}}
+	types.CheckExpr(conf, fset, it.inc.CurPkg.Arch.Pkg, lit, t.info)
+	return t
+}
+
+// locals returns the names declared in the func being
+// typed, and visible at the end of the line, innermost
+// first; pkg is where they stop.
+func (t *typedLine) locals(pkg *types.Scope) (names []string) {
+	fs := t.info.Scopes[t.lit.Type]
+	if fs == nil {
+		return nil
+	}
+	for s := fs.Innermost(t.end); s != nil && s != pkg && s != types.Universe; s = s.Parent() {
+		for _, name := range s.Names() {
+			// in scope only after its declaration ends, so that
+			// x := x completes to an outer x.
+			if found, _ := s.LookupParent(name, t.end-1); found == s && name != "_" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// completeSelector adds the members of the x in x.sel,
+// where the period is at byte offset dot of the line, if
+// x was typed; it reports whether it was.
+func (t *typedLine) completeSelector(dot int, add func(string)) bool {
+	var sel *ast.SelectorExpr
+	ast.Inspect(t.lit, func(n ast.Node) bool {
+		if x, ok := n.(*ast.SelectorExpr); ok && x.X.End() == token.Pos(t.base+dot) {
+			sel = x
+		}
+		return sel == nil
+	})
+	if sel == nil {
+		return false
+	}
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if pkg, ok := t.info.Uses[id].(*types.PkgName); ok {
+			addPackageMembers(pkg, add)
+			return true
+		}
+	}
+	tv, ok := t.info.Types[sel.X]
+	if !ok || tv.Type == nil {
+		return false
+	}
+	addMembers(tv, add)
+	return true
+}
+
+// completeSelector adds the members of x, an expression
+// or a package name, that may follow x.
+func (it *Interp) completeSelector(x string, add func(string)) {
+	scope := it.inc.pkgScope()
+	if _, obj := scope.LookupParent(x, token.NoPos); obj != nil {
+		if pkg, ok := obj.(*types.PkgName); ok {
+			addPackageMembers(pkg, add)
+			return
+		}
+	}
+	tv, err := types.EvalWith(nil, it.inc.CurPkg.fileSet, it.inc.CurPkg.Arch.Pkg, token.NoPos, x)
+	if err != nil || tv.Type == nil {
+		return
+	}
+	addMembers(tv, add)
+}
+
+// addPackageMembers adds the exported members of pkg.
+func addPackageMembers(pkg *types.PkgName, add func(string)) {
+	for _, name := range pkg.Imported().Scope().Names() {
+		if ast.IsExported(name) {
+			add(name)
+		}
+	}
+}
+
+// addMembers adds the methods of tv, and its fields if it
+// is a value.
+func addMembers(tv types.TypeAndValue, add func(string)) {
+	T := tv.Type
+	if !tv.IsType() {
+		// a value x has the methods of *T too, if addressable;
+		// suggest them, as the type checker will say if not.
+		if _, isPtr := T.Underlying().(*types.Pointer); !isPtr && !types.IsInterface(T) {
+			T = types.NewPointer(T)
+		}
+	}
+	mset := types.NewMethodSet(T)
+	for i := 0; i < mset.Len(); i++ {
+		add(mset.At(i).Obj().Name())
+	}
+	if !tv.IsType() {
+		addFields(T, add, make(map[types.Type]bool))
+	}
+}
+
+// addFields adds the fields of the struct T, or that T
+// points to, and those promoted from its embedded fields.
+func addFields(T types.Type, add func(string), seen map[types.Type]bool) {
+	if p, ok := T.Underlying().(*types.Pointer); ok {
+		T = p.Elem()
+	}
+	st, ok := T.Underlying().(*types.Struct)
+	if !ok || seen[T] {
+		return
+	}
+	seen[T] = true
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		add(f.Name())
+		if f.Anonymous() {
+			addFields(f.Type(), add, seen)
+		}
+	}
+}
+
+// identStart returns the offset of the identifier, perhaps
+// empty, that line ends with.
+func identStart(line string) int {
+	i := len(line)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:i])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i -= size
+	}
+	return i
+}
+
+// selectorStart returns the offset of the selector
+// expression, as a.b.c, that line ends with.
+func selectorStart(line string) int {
+	for {
+		i := identStart(line)
+		if i == 0 || line[i-1] != '.' {
+			return i
+		}
+		line = line[:i-1]
+	}
+}
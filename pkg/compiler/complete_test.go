@@ -0,0 +1,37 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1387CompletionOfPartialInput(t *testing.T) {
+
+	cv.Convey("completion type checks what is being typed, though it does not parse yet, to complete its locals and their members", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval("import \"fmt\""))
+		panicOn(it.Eval("type Point struct{ X, Y int }\nfunc (p Point) Norm() int { return p.X*p.X + p.Y*p.Y }"))
+
+		complete := func(src string) []string {
+			_, c := it.Complete(src, len(src))
+			return c
+		}
+		cv.So(complete("func f(p Point) {\n\tp."), cv.ShouldResemble, []string{"Norm", "X", "Y"})
+		cv.So(complete("func (q Point) Twice() Point {\n\tvar r = q\n\tr.N"), cv.ShouldResemble, []string{"Norm"})
+		cv.So(complete("origin := Point{}\norigin."), cv.ShouldResemble, []string{"Norm", "X", "Y"})
+		cv.So(complete("for i := 0; i < 3; i++ {\n\tpoint := Point{X: i}\n\tfmt.Println(poi"), cv.ShouldResemble, []string{"point"})
+
+		// locals come before the session's names and the
+		// keywords, and only those declared before the cursor.
+		cv.So(complete("func g(pa int) {\n\tpb := pa\n\tP"), cv.ShouldResemble, []string{"Point"})
+		cv.So(complete("func g(pa int) {\n\tpb := pa\n\tp"), cv.ShouldResemble, []string{"pa", "pb", "package", "panic", "print", "println"})
+		cv.So(complete("func g(pa int) {\n\tpb := p"), cv.ShouldResemble, []string{"pa", "package", "panic", "print", "println"})
+
+		// what typing finds nothing for falls back as before.
+		cv.So(complete("fmt.Sprin"), cv.ShouldResemble, []string{"Sprint", "Sprintf", "Sprintln"})
+	})
+}
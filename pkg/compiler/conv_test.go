@@ -36,3 +36,79 @@ i := int(f)
 
 	})
 }
+
+func Test1361ConversionsGiveWhatGcGives(t *testing.T) {
+
+	cv.Convey("every conversion between numeric types, strings, and byte and rune slices should give gc's result", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		// integers narrow to their low bits, and floats
+		// truncate toward zero.
+		panicOn(it.Eval(`var i64 int64 = 300
+var u64 uint64 = 1<<63 + 5
+var minus int = -1
+var w uint16 = 65535
+f := 3.99
+i8 := int8(i64)
+u8 := uint8(i64)
+u16 := uint16(-1 * i64)
+asInt64 := int64(u64)
+i32 := int32(u64)
+u32 := uint32(minus)
+allOnes := uint64(minus)
+fromUint16 := int8(w)
+trunc := int(f) + int(-f)*10
+sum := uint8(int(f) + 254)
+unsigned := u8 == 44 && u16 == 65236 && u32 == 4294967295 && allOnes == 1<<64-1 && sum == 1`))
+		LuaMustInt64(it.lvm, "i8", 44)
+		LuaMustInt64(it.lvm, "asInt64", -9223372036854775803)
+		LuaMustInt64(it.lvm, "i32", 5)
+		LuaMustInt64(it.lvm, "fromUint16", -1)
+		LuaMustInt64(it.lvm, "trunc", -27)
+		LuaMustBool(it.lvm, "unsigned", true)
+
+		// float32 rounds, from a float64 or an integer.
+		panicOn(it.Eval(`var f64 = 0.1
+var n int = 16777217
+same := float64(float32(f64)) == float64(float32(0.1))
+rounded := float32(n)`))
+		LuaMustBool(it.lvm, "same", true)
+		LuaMustFloat64(it.lvm, "rounded", 16777216)
+
+		// strings, bytes and runes.
+		panicOn(it.Eval(`s := "héllo"
+b := []byte(s)
+r := []rune(s)
+nb, nr := len(b), len(r)
+back := string(b) + string(r)
+var bb byte = 'z'
+var big int64 = 1 << 40
+fromByte := string(bb)
+tooBig := string(big)
+world := string([]rune{0x4e16, 0x754c})
+bad := string([]byte{0xff, 'a'})
+badRunes := len([]rune(bad))
+replaced := []rune(bad)[0]
+type MyS string
+type MyB []byte
+mb := MyB(MyS("ab"))
+named := string(mb)`))
+		LuaMustInt(it.lvm, "nb", 6)
+		LuaMustInt(it.lvm, "nr", 5)
+		LuaMustString(it.lvm, "back", "héllohéllo")
+		LuaMustString(it.lvm, "fromByte", "z")
+		LuaMustString(it.lvm, "tooBig", "�")
+		LuaMustString(it.lvm, "world", "世界")
+		LuaMustInt(it.lvm, "badRunes", 2)
+		LuaMustInt64(it.lvm, "replaced", 0xFFFD)
+		LuaMustString(it.lvm, "named", "ab")
+
+		// and pointers, through unsafe.
+		panicOn(it.Eval(`import "unsafe"
+var fl float64 = 1.0
+bits := *(*uint64)(unsafe.Pointer(&fl)) == 0x3ff0000000000000`))
+		LuaMustBool(it.lvm, "bits", true)
+	})
+}
@@ -0,0 +1,292 @@
+package compiler
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// coverBlock is one instrumented statement, located
+// in its original source.
+type coverBlock struct {
+	file      string
+	line, col int
+	endLine   int
+}
+
+// srcOrigin maps the lines of an input, from line
+// inLine on, back to the file they were taken from.
+// gi test merges a package's files into one input.
+type srcOrigin struct {
+	inLine  int
+	file    string
+	line    int
+	noCover bool // e.g. _test.go files, which go test does not cover
+}
+
+// coverage is the statement coverage state of an
+// IncrState. When it is set, every statement is
+// translated with a Lua line marking it as run in
+// the __gi_cov table first.
+type coverage struct {
+	blocks []coverBlock
+	index  map[token.Pos]int // statement position -> block id
+
+	files   []string          // in the order first seen
+	sources map[string]string // file name -> text, for the HTML view
+
+	origins []srcOrigin // for the input being translated
+}
+
+func newCoverage() *coverage {
+	return &coverage{
+		index:   make(map[token.Pos]int),
+		sources: make(map[string]string),
+	}
+}
+
+// addSource records the text of a covered file.
+func (cv *coverage) addSource(name, src string) {
+	if _, ok := cv.sources[name]; !ok {
+		cv.files = append(cv.files, name)
+	}
+	cv.sources[name] = src
+}
+
+// beginInput prepares for the translation of src. Unless
+// setOrigins was called for it, src is its own file, name.
+func (cv *coverage) beginInput(name string, src []byte) {
+	if cv.origins != nil {
+		return
+	}
+	cv.addSource(name, string(src))
+	cv.origins = []srcOrigin{{inLine: 1, file: name, line: 1}}
+}
+
+// endInput forgets the origins of the translated input.
+func (cv *coverage) endInput() {
+	cv.origins = nil
+}
+
+// setOrigins gives the origins of the next input.
+func (cv *coverage) setOrigins(origins []srcOrigin) {
+	cv.origins = origins
+}
+
+// origin finds where line of the current input came from.
+func (cv *coverage) origin(line int) (o srcOrigin, ok bool) {
+	i := sort.Search(len(cv.origins), func(i int) bool {
+		return cv.origins[i].inLine > line
+	}) - 1
+	if i < 0 {
+		return o, false
+	}
+	o = cv.origins[i]
+	o.line += line - o.inLine
+	return o, true
+}
+
+// add registers stmt, and returns its block id,
+// or -1 if stmt is not one to cover.
+func (cv *coverage) add(fset *token.FileSet, stmt ast.Stmt) int {
+	switch stmt.(type) {
+	case *ast.BlockStmt, *ast.LabeledStmt, *ast.EmptyStmt:
+		return -1
+	}
+	pos := stmt.Pos()
+	if !pos.IsValid() || !stmt.End().IsValid() {
+		// made up during translation.
+		return -1
+	}
+	if id, ok := cv.index[pos]; ok {
+		return id
+	}
+	beg := fset.Position(pos)
+	o, ok := cv.origin(beg.Line)
+	if !ok || o.noCover {
+		return -1
+	}
+	end := fset.Position(stmt.End())
+	id := len(cv.blocks)
+	cv.blocks = append(cv.blocks, coverBlock{
+		file:    o.file,
+		line:    o.line,
+		col:     beg.Column,
+		endLine: o.line + end.Line - beg.Line,
+	})
+	cv.index[pos] = id
+	return id
+}
+
+// CoverFile is the statement coverage of one file.
+type CoverFile struct {
+	Name    string
+	Stmts   int
+	Covered int
+}
+
+// Percent returns the percentage of statements run.
+func (f CoverFile) Percent() float64 {
+	if f.Stmts == 0 {
+		return 0
+	}
+	return 100 * float64(f.Covered) / float64(f.Stmts)
+}
+
+// CoverProfile is a statement coverage report.
+type CoverProfile struct {
+	Files []CoverFile
+	Total CoverFile
+
+	blocks  []coverBlock
+	hit     []bool
+	sources map[string]string
+}
+
+// String lists the per file percentages, then the total.
+func (p *CoverProfile) String() string {
+	var b strings.Builder
+	for _, f := range p.Files {
+		fmt.Fprintf(&b, "%s\t%.1f%% of %d statements\n", f.Name, f.Percent(), f.Stmts)
+	}
+	fmt.Fprintf(&b, "total:\t%.1f%% of statements\n", p.Total.Percent())
+	return b.String()
+}
+
+// profile builds the report from the ids of the blocks run.
+func (cv *coverage) profile(hits []int) *CoverProfile {
+	p := &CoverProfile{
+		Total:   CoverFile{Name: "total"},
+		blocks:  cv.blocks,
+		hit:     make([]bool, len(cv.blocks)),
+		sources: cv.sources,
+	}
+	for _, id := range hits {
+		if id >= 0 && id < len(p.hit) {
+			p.hit[id] = true
+		}
+	}
+	perFile := make(map[string]*CoverFile)
+	for _, name := range cv.files {
+		perFile[name] = &CoverFile{Name: name}
+	}
+	for id, blk := range cv.blocks {
+		f := perFile[blk.file]
+		f.Stmts++
+		p.Total.Stmts++
+		if p.hit[id] {
+			f.Covered++
+			p.Total.Covered++
+		}
+	}
+	for _, name := range cv.files {
+		if f := perFile[name]; f.Stmts > 0 {
+			p.Files = append(p.Files, *f)
+		}
+	}
+	return p
+}
+
+// WriteHTML writes a page showing the source of each
+// file, with the lines whose statements all ran in green
+// and those holding a statement never run in red.
+func (p *CoverProfile) WriteHTML(w io.Writer) error {
+	// per file, per line: 1 covered, 2 not covered.
+	marks := make(map[string]map[int]int)
+	for id, blk := range p.blocks {
+		m := marks[blk.file]
+		if m == nil {
+			m = make(map[int]int)
+			marks[blk.file] = m
+		}
+		if !p.hit[id] {
+			m[blk.line] = 2
+		} else if m[blk.line] == 0 {
+			m[blk.line] = 1
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>gijit coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { font-family: monospace; line-height: 1.3; }
+.cov { background: #c8f0c8; }
+.miss { background: #f8c8c8; }
+.ln { color: #999; user-select: none; }
+</style></head><body>
+`)
+	fmt.Fprintf(&b, "<h1>coverage: %.1f%% of statements</h1>\n", p.Total.Percent())
+	for _, f := range p.Files {
+		fmt.Fprintf(&b, "<h2>%s: %.1f%%</h2>\n<pre>", html.EscapeString(f.Name), f.Percent())
+		for i, line := range strings.Split(p.sources[f.Name], "\n") {
+			n := i + 1
+			class := ""
+			switch marks[f.Name][n] {
+			case 1:
+				class = ` class="cov"`
+			case 2:
+				class = ` class="miss"`
+			}
+			fmt.Fprintf(&b, "<span%s><span class=\"ln\">%4d  </span>%s</span>\n", class, n, html.EscapeString(line))
+		}
+		b.WriteString("</pre>\n")
+	}
+	b.WriteString("</body></html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTMLFile writes the HTML view to path.
+func (p *CoverProfile) WriteHTMLFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteHTML(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// StartCoverage turns on statement coverage: inputs
+// evaluated from now on are instrumented. Each input
+// is reported as a file of its own.
+func (it *Interp) StartCoverage() {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.inc.cover == nil {
+		it.inc.cover = newCoverage()
+	}
+}
+
+// Coverage reports the statements run so far, among
+// those evaluated since StartCoverage. It returns nil
+// if coverage was never started.
+func (it *Interp) Coverage() (*CoverProfile, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	cv := it.inc.cover
+	if cv == nil {
+		return nil, nil
+	}
+	if err := LuaRun(it.lvm, `__gi_covHitList = __gi_coverHits()`, false); err != nil {
+		return nil, err
+	}
+	var hits []int
+	for _, s := range strings.Split(luaGlobalString(it.lvm, "__gi_covHitList"), ",") {
+		if id, err := strconv.Atoi(s); err == nil {
+			hits = append(hits, id)
+		}
+	}
+	return cv.profile(hits), nil
+}
@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1321CoverageOfGiTestAndReplInput(t *testing.T) {
+
+	cv.Convey(`gi test -cover reports the statement coverage of the package's non-test files, per file, and can write it as HTML`, t, func() {
+		dir, err := ioutil.TempDir("", "gicover")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		htmlPath := filepath.Join(dir, "cover.html")
+
+		var out bytes.Buffer
+		passed, err := TestDir(nil, &out, "testdata/gitest", TestOptions{
+			Run:       "TestSum",
+			CoverHTML: htmlPath,
+		})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeTrue)
+		s := out.String()
+		pp("gi test -cover output:\n%s", s)
+
+		// Sum's 4 statements run; Mean's 3 do not.
+		cv.So(s, cv.ShouldContainSubstring, "coverage: 57.1% of statements\n")
+		cv.So(s, cv.ShouldContainSubstring, "\tsum.go\t57.1%\n")
+		cv.So(s, cv.ShouldNotContainSubstring, "sum_test.go")
+		cv.So(s, cv.ShouldContainSubstring, "ok  \ttestdata/gitest\t")
+		cv.So(s, cv.ShouldEndWith, "\tcoverage: 57.1% of statements\n")
+
+		page, err := ioutil.ReadFile(htmlPath)
+		panicOn(err)
+		h := string(page)
+		cv.So(h, cv.ShouldContainSubstring, `<span class="cov"><span class="ln">   7  </span>`)
+		cv.So(h, cv.ShouldContainSubstring, `<span class="miss"><span class="ln">  15  </span>`)
+	})
+
+	cv.Convey(`:coverage counts the statements of REPL inputs entered after it is turned on, naming each input as a file after its place in the session`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(it.Eval(`func before() int { return 1 }`))
+
+		it.StartCoverage()
+		panicOn(it.Eval(`func pick(b bool) int {
+	if b {
+		return 1
+	}
+	return 2
+}`))
+		panicOn(it.Eval(`x := pick(true)`))
+
+		prof, err := it.Coverage()
+		panicOn(err)
+		pp("coverage:\n%v", prof)
+		cv.So(len(prof.Files), cv.ShouldEqual, 2)
+		cv.So(prof.Files[0].Name, cv.ShouldEqual, "repl[2]")
+		cv.So(prof.Files[0].Stmts, cv.ShouldEqual, 3)
+		cv.So(prof.Files[0].Covered, cv.ShouldEqual, 2)
+		cv.So(prof.Files[1].Name, cv.ShouldEqual, "repl[3]")
+		cv.So(prof.Files[1].Covered, cv.ShouldEqual, prof.Files[1].Stmts)
+		cv.So(prof.String(), cv.ShouldContainSubstring, "repl[2]\t66.7% of 3 statements\n")
+	})
+}
@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	"sort"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// gi build leaves out the declarations of a program that
+// it cannot reach. Starting from main, the init funcs, the
+// top level statements, and the vars whose initializers
+// may have effects, it follows every name their code
+// mentions to the declarations of that name, until nothing
+// new is reached. Funcs, vars, consts and types reached by
+// no name are dropped. A method is kept if its receiver
+// type is, and it is exported, since an imported package
+// may call it through an interface, or its name is
+// mentioned, as by a selector or an interface's method.
+//
+// Names are matched as written, not resolved, so a local
+// or a field that shares a top level name keeps that
+// declaration: it errs only on the side of keeping code.
+
+// dceUnit is a top level declaration that is kept or
+// dropped as a whole: a func or method, a var or type
+// spec, or a const group, since its specs share iota.
+type dceUnit struct {
+	node  ast.Node // the FuncDecl, Spec, or const GenDecl
+	names []string // declared
+	recv  string   // for a method, its receiver's type
+	root  bool
+	live  bool
+}
+
+// label names u for gi build -v.
+func (u *dceUnit) label() string {
+	if u.recv != "" {
+		return u.recv + "." + u.names[0]
+	}
+	if len(u.names) == 1 {
+		return u.names[0]
+	}
+	name := u.names[0]
+	for _, n := range u.names[1:] {
+		name += ", " + n
+	}
+	return name
+}
+
+// dceUnits splits the top level nodes into units; nodes
+// that declare nothing, statements, are roots.
+func dceUnits(nodes []ast.Node) []*dceUnit {
+	var units []*dceUnit
+	for _, node := range nodes {
+		switch d := node.(type) {
+		case *ast.FuncDecl:
+			u := &dceUnit{node: d, names: []string{d.Name.Name}}
+			if d.Recv != nil {
+				u.recv = recvTypeName(d.Recv)
+			} else {
+				u.root = d.Name.Name == "main" || d.Name.Name == "init"
+			}
+			units = append(units, u)
+		case *ast.GenDecl:
+			if d.Tok == token.CONST {
+				u := &dceUnit{node: d}
+				for _, spec := range d.Specs {
+					for _, id := range spec.(*ast.ValueSpec).Names {
+						u.names = append(u.names, id.Name)
+					}
+				}
+				units = append(units, u)
+				continue
+			}
+			for _, spec := range d.Specs {
+				u := &dceUnit{node: spec}
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					u.names = []string{sp.Name.Name}
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						u.names = append(u.names, id.Name)
+						if id.Name == "_" {
+							u.root = true
+						}
+					}
+					for _, v := range sp.Values {
+						if mayHaveEffects(v) {
+							u.root = true
+						}
+					}
+				}
+				units = append(units, u)
+			}
+		default:
+			units = append(units, &dceUnit{node: node, root: true})
+		}
+	}
+	return units
+}
+
+// mayHaveEffects reports whether evaluating x might do
+// more than compute a value: it calls, or receives.
+func mayHaveEffects(x ast.Expr) bool {
+	effects := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			effects = true
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				effects = true
+			}
+		case *ast.FuncLit:
+			// its body runs only if called.
+			return false
+		}
+		return !effects
+	})
+	return effects
+}
+
+// eliminateDeadCode marks the units reachable from the
+// roots live, and returns the labels of the others, sorted.
+func eliminateDeadCode(units []*dceUnit) (dropped []string) {
+	declaring := make(map[string][]*dceUnit)
+	methods := make(map[string][]*dceUnit)
+	for _, u := range units {
+		if u.recv != "" {
+			methods[u.recv] = append(methods[u.recv], u)
+			continue
+		}
+		for _, name := range u.names {
+			declaring[name] = append(declaring[name], u)
+		}
+	}
+
+	mentioned := make(map[string]bool)
+	var queue []*dceUnit
+	mark := func(u *dceUnit) {
+		if !u.live {
+			u.live = true
+			queue = append(queue, u)
+		}
+	}
+	for _, u := range units {
+		if u.root {
+			mark(u)
+		}
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		var names []string
+		ast.Inspect(u.node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && !mentioned[id.Name] {
+				mentioned[id.Name] = true
+				names = append(names, id.Name)
+			}
+			return true
+		})
+		for _, name := range names {
+			for _, d := range declaring[name] {
+				mark(d)
+			}
+		}
+		// a newly mentioned name may be a method's, and a
+		// newly live type brings its exported methods.
+		for _, ms := range methods {
+			for _, m := range ms {
+				if !m.live && liveType(declaring[m.recv]) &&
+					(ast.IsExported(m.names[0]) || mentioned[m.names[0]]) {
+					mark(m)
+				}
+			}
+		}
+	}
+
+	for _, u := range units {
+		if !u.live && u.names != nil {
+			dropped = append(dropped, u.label())
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// liveType reports whether one of us, the units declaring
+// a name, is a type that is live.
+func liveType(us []*dceUnit) bool {
+	for _, u := range us {
+		if _, ok := u.node.(*ast.TypeSpec); ok && u.live {
+			return true
+		}
+	}
+	return false
+}
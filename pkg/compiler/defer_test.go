@@ -340,3 +340,38 @@ println("test2() ran")
 		cv.So(true, cv.ShouldBeTrue)
 	})
 }
+
+func Test1354UncaughtPanicIsShownAsGcWould(t *testing.T) {
+
+	cv.Convey("an uncaught panic prints its value, then the goroutine's stack in Go terms", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "errors"`))
+		panicOn(it.Eval("type P struct{ X int }\nfunc f(v interface{}) {\n\tpanic(v)\n}"))
+		err = it.Eval(`f("boom")`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEqual, "panic: boom\n\ngoroutine 3 [running]:\nmain.f()\n\trepl[2]:3\nmain.repl[3]()\n\trepl[3]:1")
+
+		// values are printed as the runtime's print would.
+		for _, c := range []struct{ expr, want string }{
+			{`f(42)`, "panic: 42\n"},
+			{`f(1.5)`, "panic: +1.500000e+000\n"},
+			{`f(true)`, "panic: true\n"},
+			{`f(nil)`, "panic: panic called with nil argument\n"},
+			{`f(errors.New("bad"))`, "panic: bad\n"},
+		} {
+			err = it.Eval(c.expr)
+			cv.So(err, cv.ShouldNotBeNil)
+			cv.So(err.Error(), cv.ShouldStartWith, c.want)
+		}
+		err = it.Eval(`f(&P{X: 2})`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldStartWith, "panic: (*main.P) 0x")
+
+		// a recovered panic still yields the value itself.
+		panicOn(it.Eval("func g() (r interface{}) {\n\tdefer func() { r = recover() }()\n\tpanic(7)\n}\nr := g()"))
+		LuaMustInt64(it.lvm, "r", 7)
+	})
+}
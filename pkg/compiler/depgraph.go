@@ -0,0 +1,150 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/types"
+)
+
+// A DepGraph is the dependency graph of the declarations in
+// effect in a session: for each of its package-level objects
+// and methods, those its declaration refers to. It answers
+// what a redefinition breaks: the users of the old object go
+// on referring to it.
+type DepGraph struct {
+	deps  map[types.Object][]types.Object
+	users map[types.Object][]types.Object
+}
+
+// DepGraph returns the dependency graph of the session's
+// declarations, as the type checker recorded them.
+func (it *Interp) DepGraph() *DepGraph {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.inc.depGraph()
+}
+
+func (tr *IncrState) depGraph() *DepGraph {
+	scope := tr.pkgScope()
+	g := &DepGraph{
+		deps:  make(map[types.Object][]types.Object),
+		users: make(map[types.Object][]types.Object),
+	}
+	for obj, deps := range tr.CurPkg.Arch.Check.DepGraph() {
+		if !inEffect(scope, obj) {
+			continue
+		}
+		g.deps[obj] = deps
+		for _, dep := range deps {
+			g.users[dep] = append(g.users[dep], obj)
+		}
+	}
+	for _, users := range g.users {
+		sortByPos(users)
+	}
+	return g
+}
+
+// inEffect reports whether obj is still declared in scope:
+// for a method, whether its receiver type is.
+func inEffect(scope *types.Scope, obj types.Object) bool {
+	if f, ok := obj.(*types.Func); ok {
+		if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+			T := recv.Type()
+			if p, ok := T.(*types.Pointer); ok {
+				T = p.Elem()
+			}
+			named, ok := T.(*types.Named)
+			return ok && inEffect(scope, named.Obj())
+		}
+	}
+	return scope.Lookup(obj.Name()) == obj
+}
+
+func sortByPos(list []types.Object) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Pos() < list[j].Pos() })
+}
+
+// Objects lists the objects of g, in the order they
+// were declared.
+func (g *DepGraph) Objects() []types.Object {
+	var list []types.Object
+	for obj := range g.deps {
+		list = append(list, obj)
+	}
+	sortByPos(list)
+	return list
+}
+
+// Deps lists the objects the declaration of obj refers to.
+func (g *DepGraph) Deps(obj types.Object) []types.Object {
+	return g.deps[obj]
+}
+
+// Users lists the objects whose declarations refer to obj.
+func (g *DepGraph) Users(obj types.Object) []types.Object {
+	return g.users[obj]
+}
+
+// Affected lists the objects whose declarations refer to
+// obj, directly or through others: those that a new
+// definition of obj leaves referring to the old one.
+func (g *DepGraph) Affected(obj types.Object) []types.Object {
+	seen := map[types.Object]bool{obj: true}
+	var list []types.Object
+	work := []types.Object{obj}
+	for len(work) > 0 {
+		o := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, u := range g.users[o] {
+			if !seen[u] {
+				seen[u] = true
+				list = append(list, u)
+				work = append(work, u)
+			}
+		}
+	}
+	sortByPos(list)
+	return list
+}
+
+// Warnings returns, for each redefinition in d that changed
+// the type of a name, a warning naming what still refers to
+// the old definition.
+func (g *DepGraph) Warnings(d ScopeDiff) []string {
+	var warns []string
+	for _, r := range d.Redefined {
+		if diffTypeString(r.Old) == diffTypeString(r.New) {
+			continue
+		}
+		var names []string
+		for _, u := range g.Affected(r.Old) {
+			names = append(names, depName(u))
+		}
+		if len(names) > 0 {
+			warns = append(warns, fmt.Sprintf("warning: %s changed; still using the old %s: %s",
+				r.New.Name(), r.New.Name(), strings.Join(names, ", ")))
+		}
+	}
+	return warns
+}
+
+// depName is the name of obj as the REPL shows it:
+// for a method, (*T).M or T.M.
+func depName(obj types.Object) string {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return obj.Name()
+	}
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return obj.Name()
+	}
+	T := types.TypeString(recv.Type(), relativeToObj(obj))
+	if strings.HasPrefix(T, "*") {
+		T = "(" + T + ")"
+	}
+	return T + "." + obj.Name()
+}
@@ -0,0 +1,56 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1336DepGraphTellsWhatARedefinitionBreaks(t *testing.T) {
+
+	cv.Convey(`DepGraph records what each declaration of the session refers to, types and methods and := variables included, and warns of what still refers to the old definition of a name redefined`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type T struct{ A int }`))
+		panicOn(it.Eval(`func (t *T) Get() int { return t.A }`))
+		panicOn(it.Eval(`func mk() *T { return &T{1} }`))
+		panicOn(it.Eval(`func use() int { return mk().Get() }`))
+		panicOn(it.Eval(`x := mk()`))
+		panicOn(it.Eval(`var y = use()`))
+		panicOn(it.Eval(`const N = 3; type Arr [N]int`))
+
+		names := func(objs []types.Object) (s []string) {
+			for _, o := range objs {
+				s = append(s, depName(o))
+			}
+			return
+		}
+		g := it.DepGraph()
+		byName := make(map[string]types.Object)
+		for _, o := range g.Objects() {
+			byName[depName(o)] = o
+		}
+		cv.So(names(g.Objects()), cv.ShouldResemble, []string{"T", "(*T).Get", "mk", "use", "x", "y", "N", "Arr"})
+		cv.So(names(g.Deps(byName["use"])), cv.ShouldResemble, []string{"(*T).Get", "mk"})
+		cv.So(names(g.Deps(byName["x"])), cv.ShouldResemble, []string{"mk"})
+		cv.So(names(g.Deps(byName["Arr"])), cv.ShouldResemble, []string{"N"})
+		cv.So(names(g.Users(byName["T"])), cv.ShouldResemble, []string{"(*T).Get", "mk"})
+		cv.So(names(g.Affected(byName["T"])), cv.ShouldResemble, []string{"(*T).Get", "mk", "use", "x", "y"})
+		cv.So(g.Affected(byName["y"]), cv.ShouldBeEmpty)
+
+		// a new T that is the same type is harmless.
+		panicOn(it.Eval(`type Arr [3]int`))
+		cv.So(it.DepGraph().Warnings(it.LastScopeDiff()), cv.ShouldBeEmpty)
+
+		panicOn(it.Eval(`type T struct{ A, B int }`))
+		g = it.DepGraph()
+		cv.So(g.Warnings(it.LastScopeDiff()), cv.ShouldResemble,
+			[]string{"warning: T changed; still using the old T: mk, use, x, y"})
+
+		// the methods of the old T went with it.
+		cv.So(names(g.Objects()), cv.ShouldResemble, []string{"mk", "use", "x", "y", "N", "Arr", "T"})
+	})
+}
@@ -0,0 +1,118 @@
+package compiler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DeterministicSeed seeds math/rand, and Lua's own
+// random numbers used by select, in deterministic mode.
+const DeterministicSeed = 1
+
+// deterministicEpoch is where the logical clock of a
+// deterministic session starts: the same instant
+// the Go playground reports.
+var deterministicEpoch = time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+
+// logicalClock stands in for the wall clock in
+// deterministic mode. Time only moves forward
+// when the program sleeps, and sleeping does
+// not block.
+type logicalClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *logicalClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *logicalClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func (c *logicalClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *logicalClock) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// detWorld is the virtual environment of one
+// deterministic session: its clock and its
+// random number source.
+type detWorld struct {
+	clock *logicalClock
+	rnd   *rand.Rand
+}
+
+func newDetWorld() *detWorld {
+	return &detWorld{
+		clock: &logicalClock{now: deterministicEpoch},
+		rnd:   rand.New(rand.NewSource(DeterministicSeed)),
+	}
+}
+
+// overrides returns the members of the shadow package
+// path that are swapped for virtual versions, or nil.
+// Timers and tickers (After, Tick, NewTimer) still
+// run on the real clock.
+func (w *detWorld) overrides(path string) map[string]interface{} {
+	switch path {
+	case "time":
+		return map[string]interface{}{
+			"Now":   w.clock.Now,
+			"Sleep": w.clock.Sleep,
+			"Since": w.clock.Since,
+			"Until": w.clock.Until,
+		}
+	case "math/rand":
+		r := w.rnd
+		return map[string]interface{}{
+			"ExpFloat64":  r.ExpFloat64,
+			"Float32":     r.Float32,
+			"Float64":     r.Float64,
+			"Int":         r.Int,
+			"Int31":       r.Int31,
+			"Int31n":      r.Int31n,
+			"Int63":       r.Int63,
+			"Int63n":      r.Int63n,
+			"Intn":        r.Intn,
+			"NormFloat64": r.NormFloat64,
+			"Perm":        r.Perm,
+			"Read":        r.Read,
+			"Seed":        r.Seed,
+			"Shuffle":     r.Shuffle,
+			"Uint32":      r.Uint32,
+			"Uint64":      r.Uint64,
+		}
+	}
+	return nil
+}
+
+// overridePkgMap returns a copy of the shadow map m
+// with the entries of over replacing its own.
+func overridePkgMap(m map[string]interface{}, over map[string]interface{}) map[string]interface{} {
+	if len(over) == 0 {
+		return m
+	}
+	cp := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	for k, v := range over {
+		if _, ok := cp[k]; ok {
+			cp[k] = v
+		}
+	}
+	return cp
+}
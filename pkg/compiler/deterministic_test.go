@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1316DeterministicMode(t *testing.T) {
+
+	cv.Convey(`in deterministic mode, ranging over a map visits keys in sorted order`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Deterministic = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`m := map[string]int{"d": 4, "b": 2, "a": 1, "e": 5, "c": 3}; s := ""; for k := range m { s += k }`))
+		LuaMustString(it.lvm, "s", "abcde")
+
+		panicOn(it.Eval(`n := map[int]int{50: 1, 3: 2, 12: 3}; t := 0; for k := range n { t = t*100 + k }`))
+		LuaMustInt64(it.lvm, "t", 31250)
+	})
+
+	cv.Convey(`deterministic Interps draw the same Lua random numbers`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Deterministic = true
+		a, err := NewInterp(cfg)
+		panicOn(err)
+		defer a.Close()
+		b, err := NewInterp(cfg)
+		panicOn(err)
+		defer b.Close()
+
+		draw := `r = tostring(__builtin_math.random(1000000)) .. "," .. tostring(__builtin_math.random(1000000))`
+		panicOn(LuaRun(a.lvm, draw, false))
+		panicOn(LuaRun(b.lvm, draw, false))
+		cv.So(luaGetString(a.lvm, "r"), cv.ShouldEqual, luaGetString(b.lvm, "r"))
+	})
+
+	cv.Convey(`at the prompt, time.Now starts at deterministicEpoch, and time.Sleep moves it on without sleeping`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Deterministic = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		start := time.Now()
+		panicOn(it.Eval(`
+import (
+	"fmt"
+	"time"
+)
+
+t0 := time.Now()
+u0 := t0.Unix()
+time.Sleep(time.Hour)
+slept := fmt.Sprint(time.Since(t0))
+u1 := time.Now().Unix()
+`))
+		cv.So(time.Since(start), cv.ShouldBeLessThan, time.Minute)
+		LuaMustInt64(it.lvm, "u0", deterministicEpoch.Unix())
+		LuaMustString(it.lvm, "slept", "1h0m0s")
+		LuaMustInt64(it.lvm, "u1", deterministicEpoch.Unix()+3600)
+	})
+
+	cv.Convey(`the virtual time and rand members follow a logical clock and a fixed seed`, t, func() {
+		w1 := newDetWorld()
+		w2 := newDetWorld()
+
+		tm := w1.overrides("time")
+		now := tm["Now"].(func() time.Time)
+		sleep := tm["Sleep"].(func(time.Duration))
+		t0 := now()
+		cv.So(t0.Equal(deterministicEpoch), cv.ShouldBeTrue)
+		sleep(time.Hour)
+		cv.So(now().Sub(t0), cv.ShouldEqual, time.Hour)
+		cv.So(tm["Since"].(func(time.Time) time.Duration)(t0), cv.ShouldEqual, time.Hour)
+
+		in1 := w1.overrides("math/rand")["Intn"].(func(int) int)
+		in2 := w2.overrides("math/rand")["Intn"].(func(int) int)
+		for i := 0; i < 10; i++ {
+			cv.So(in1(1000), cv.ShouldEqual, in2(1000))
+		}
+
+		cv.So(w1.overrides("strings"), cv.ShouldBeNil)
+
+		orig := map[string]interface{}{"Now": 1, "Month": 2}
+		got := overridePkgMap(orig, map[string]interface{}{"Now": 3, "Bogus": 4})
+		cv.So(got, cv.ShouldResemble, map[string]interface{}{"Now": 3, "Month": 2})
+		cv.So(orig["Now"], cv.ShouldEqual, 1)
+	})
+}
+
+func luaGetString(lvm *LuaVm, varname string) string {
+	t := lvm.goro.newTicket("", false)
+	t.gettyp = GetString
+	t.varname[varname] = nil
+	panicOn(t.Do())
+	s, _ := t.varname[varname].(string)
+	return s
+}
@@ -0,0 +1,208 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/scanner"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// diagColors are the ANSI SGR parameters, such as "01;31",
+// of the parts of a diagnostic: the message, its location,
+// and the caret line under the source. An empty one is
+// not colored.
+type diagColors struct {
+	err, locus, caret string
+}
+
+// defaultDiagColors are those gcc uses.
+var defaultDiagColors = diagColors{err: "01;31", locus: "01", caret: "01;32"}
+
+// parseDiagColors reads a spec in the style of GCC_COLORS,
+// such as "error=01;31:locus=01:caret=01;32". Parts not
+// named keep their default colors.
+func parseDiagColors(spec string) (diagColors, error) {
+	cs := defaultDiagColors
+	for _, kv := range strings.Split(spec, ":") {
+		if kv == "" {
+			continue
+		}
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			return cs, fmt.Errorf("bad color %q: want name=sgr", kv)
+		}
+		name, sgr := kv[:eq], kv[eq+1:]
+		for _, p := range strings.Split(sgr, ";") {
+			if _, err := strconv.Atoi(p); err != nil && p != "" {
+				return cs, fmt.Errorf("bad color %q: %q is not an SGR parameter", kv, p)
+			}
+		}
+		switch name {
+		case "error":
+			cs.err = sgr
+		case "locus":
+			cs.locus = sgr
+		case "caret":
+			cs.caret = sgr
+		default:
+			return cs, fmt.Errorf("bad color %q: the parts are error, locus and caret", kv)
+		}
+	}
+	return cs, nil
+}
+
+// paint colors s with sgr.
+func paint(sgr, s string) string {
+	if sgr == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + sgr + "m" + s + "\x1b[0m"
+}
+
+// diagLocRE matches a location in an input: repl[N]:line,
+// followed by :column for the type checker's errors.
+var diagLocRE = regexp.MustCompile(`repl\[\d+\]:(\d+)(?::(\d+))?`)
+
+// FormatError renders err for a terminal. Each location in
+// an input, repl[N]:line:col, that starts a message is
+// followed by the line of the input it points to, with a
+// caret under the token at col, or under the whole line
+// for a run time error, which has no column. When color is
+// set, the parts are colored as cfg.Colors says.
+func (it *Interp) FormatError(err error, color bool) string {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	var cs diagColors
+	if color {
+		cs = it.cfg.colors
+		if cs == (diagColors{}) {
+			cs = defaultDiagColors
+		}
+	}
+	return formatDiag(err.Error(), it.inc.inputs, cs)
+}
+
+// formatDiag is FormatError, with the inputs by name and
+// the colors to use. A run time error that
+// has no location of its own is shown at the top of its
+// stack instead.
+func formatDiag(msg string, inputs map[string][]byte, cs diagColors) string {
+	var b strings.Builder
+	shown := false
+	for i, ln := range strings.Split(msg, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		var snippet string
+		last := 0
+		for _, m := range diagLocRE.FindAllStringSubmatchIndex(ln, -1) {
+			b.WriteString(ln[last:m[0]])
+			b.WriteString(paint(cs.locus, ln[m[0]:m[1]]))
+			last = m[1]
+			loc := ln[m[0]:m[1]]
+			name := loc[:strings.IndexByte(loc, ':')]
+			line, _ := strconv.Atoi(ln[m[2]:m[3]])
+			col := 0
+			if m[4] >= 0 {
+				col, _ = strconv.Atoi(ln[m[4]:m[5]])
+			}
+			if !strings.HasPrefix(ln[m[1]:], ": ") {
+				// a frame of a stack.
+				if !shown {
+					snippet = sourceSnippet(inputs[name], line, 0, cs)
+					shown = true
+				}
+				continue
+			}
+			snippet = sourceSnippet(inputs[name], line, col, cs)
+			shown = true
+			// the message is the rest of the line.
+			b.WriteString(ln[m[1] : m[1]+2])
+			b.WriteString(paint(cs.err, ln[m[1]+2:]))
+			last = len(ln)
+			break
+		}
+		b.WriteString(ln[last:])
+		if snippet != "" {
+			b.WriteByte('\n')
+			b.WriteString(snippet)
+		}
+	}
+	return b.String()
+}
+
+// sourceSnippet shows line of src, numbered, with a caret
+// line under it: a '^' at col and '~' to the end of the
+// token there, or, if col is 0, under all of the line.
+// It returns "" if src has no such line.
+func sourceSnippet(src []byte, line, col int, cs diagColors) string {
+	lines := bytes.Split(src, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := lines[line-1]
+	n := 0
+	if col == 0 {
+		trimmed := bytes.TrimLeft(text, " \t")
+		col = len(text) - len(trimmed) + 1
+		n = len(bytes.TrimRight(trimmed, " \t;"))
+	} else {
+		n = tokenLen(text, col)
+	}
+	if col > len(text)+1 {
+		return ""
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	// the caret line keeps the tabs of the source,
+	// so that it lines up however they are shown.
+	var pad []byte
+	for _, c := range text[:col-1] {
+		if c == '\t' {
+			pad = append(pad, '\t')
+		} else {
+			pad = append(pad, ' ')
+		}
+	}
+	num := strconv.Itoa(line)
+	gutter := strings.Repeat(" ", len(num))
+	caret := "^" + strings.Repeat("~", n-1)
+	return fmt.Sprintf("  %s | %s\n  %s | %s%s", num, text, gutter, pad, paint(cs.caret, caret))
+}
+
+// tokenLen is the length of the Go token that starts at
+// col in line, or 1 if no token starts there. A token that
+// runs on past the line, like a raw string, ends with it.
+func tokenLen(line []byte, col int) int {
+	fset := token.NewFileSet()
+	f := fset.AddFile("", -1, len(line))
+	var s scanner.Scanner
+	s.Init(f, line, nil, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return 1
+		}
+		off := f.Offset(pos)
+		if off > col-1 {
+			return 1
+		}
+		if off < col-1 {
+			continue
+		}
+		n := len(lit)
+		if lit == "" || tok == token.SEMICOLON {
+			n = len(tok.String())
+		}
+		if off+n > len(line) {
+			n = len(line) - off
+		}
+		return n
+	}
+}
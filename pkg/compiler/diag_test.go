@@ -0,0 +1,43 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1331DiagnosticsShowTheSourceWithACaret(t *testing.T) {
+
+	cv.Convey(`errors are shown with the line of the input they point to and a caret under the token, or the statement for a run time error, colored unless asked not to be`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval("a := 1\nb := a + \"two\"")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(it.FormatError(err, false), cv.ShouldEqual, "left out 1 part(s) of the input that did not type check:\n"+
+			"\trepl[1]:2:10: cannot convert \"two\" (untyped string constant) to int\n"+
+			"  2 | b := a + \"two\"\n"+
+			"    |          ^~~~~")
+
+		panicOn(it.Eval("func f() {\n\tpanic(\"boom\")\n}"))
+		err = it.Eval(`f()`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(it.FormatError(err, false), cv.ShouldEqual, "panic: boom\n\ngoroutine 3 [running]:\nmain.f()\n\trepl[2]:2\n"+
+			"  2 | \tpanic(\"boom\")\n"+
+			"    | \t^~~~~~~~~~~~~\n"+
+			"main.repl[3]()\n\trepl[3]:1")
+
+		colored := it.FormatError(err, true)
+		cv.So(colored, cv.ShouldContainSubstring, "\t\x1b[01mrepl[2]:2\x1b[0m\n")
+		cv.So(colored, cv.ShouldContainSubstring, "\x1b[01;32m^~~~~~~~~~~~~\x1b[0m")
+
+		cs, err := parseDiagColors("error=35:caret=")
+		panicOn(err)
+		cv.So(cs, cv.ShouldResemble, diagColors{err: "35", locus: "01", caret: ""})
+		_, err = parseDiagColors("warning=33")
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = parseDiagColors("error=red")
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
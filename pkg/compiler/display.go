@@ -0,0 +1,211 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowDisplaySrc declares the gi/display package, rich
+// outputs for notebook and web frontends, to the type
+// checker. The bodies are never run; prelude/zdisplay.lua
+// has the runtime.
+//
+//	display.HTML("<b>bold</b>")
+//	display.Table([]string{"name", "age"}, [][]string{{"ann", "41"}})
+//	display.Show(display.PNG(png))
+const shadowDisplaySrc = `package display
+
+// Data is a rich output: the same content in one or more
+// MIME types, keyed by type, for a frontend to choose
+// among. It always has "text/plain", which a terminal
+// shows. Binary content, as of "image/png", is base64
+// encoded. A Data that is the value of an expression at
+// the prompt is displayed, rather than shown as a struct.
+type Data struct {
+	MIME map[string]string
+}
+
+// Displayer is implemented by values that display
+// themselves: one shown at the prompt is displayed as the
+// Data its Display method returns.
+type Displayer interface {
+	Display() Data
+}
+
+// With returns d with content added as mimeType.
+func (d Data) With(mimeType, content string) Data { return d }
+
+// HTML is a fragment of HTML; its text is the HTML itself.
+func HTML(html string) Data { return Data{} }
+
+// Markdown is text in Markdown.
+func Markdown(md string) Data { return Data{} }
+
+// JSON is a JSON document, as text.
+func JSON(json string) Data { return Data{} }
+
+// SVG is an SVG image.
+func SVG(svg string) Data { return Data{} }
+
+// PNG is a PNG image.
+func PNG(png []byte) Data { return Data{} }
+
+// JPEG is a JPEG image.
+func JPEG(jpeg []byte) Data { return Data{} }
+
+// Table is rows of cells under a header: an HTML table,
+// and for its text, the columns aligned.
+func Table(header []string, rows [][]string) Data { return Data{} }
+
+// MIME is content of any MIME type, with text as its
+// text/plain form. Binary content must be base64 encoded.
+func MIME(mimeType, content, text string) Data { return Data{} }
+
+// Show displays d now, as if it were shown at the prompt.
+func Show(d Data) {}
+`
+
+var shadowDisplay struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowDisplayPackage returns the type checker's view of
+// gi/display, shared by every Interp.
+func shadowDisplayPackage() *types.Package {
+	shadowDisplay.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "display.go", shadowDisplaySrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "gi/display", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowDisplay.pkg = pkg
+	})
+	return shadowDisplay.pkg
+}
+
+// displayImporter gives the packages that display their
+// values, gi/plot and gi/frame, their import of gi/display.
+type displayImporter struct{}
+
+func (displayImporter) Import(path string) (*types.Package, error) {
+	if path == "gi/display" {
+		return shadowDisplayPackage(), nil
+	}
+	return nil, fmt.Errorf("cannot import %s", path)
+}
+
+// isDisplayData reports whether t is gi/display's Data.
+func isDisplayData(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "gi/display" && n.Obj().Name() == "Data"
+}
+
+// isDisplayed reports whether a value of type t is displayed
+// at the prompt, rather than shown, as a Data or a Displayer
+// is. The REPL echoes such a value even as the result of a
+// call.
+func isDisplayed(t types.Type) bool {
+	if isDisplayData(t) {
+		return true
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, "Display")
+	m, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := m.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 && isDisplayData(sig.Results().At(0).Type())
+}
+
+// DisplayData is one rich output, its content keyed by
+// MIME type, as a gi/display.Data holds it. It always has
+// "text/plain"; binary content is base64 encoded, as in
+// the display_data messages of Jupyter.
+type DisplayData map[string]string
+
+// Text returns d's text/plain form.
+func (d DisplayData) Text() string {
+	return d["text/plain"]
+}
+
+// SetRichDisplay says whether the rich outputs of gi/display
+// are kept, for a frontend to fetch with Displayed. If not,
+// as by default, their text/plain form is printed at once.
+func (it *Interp) SetRichDisplay(on bool) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return LuaRun(it.lvm, fmt.Sprintf("__gi_displayRich = %v", on), false)
+}
+
+// Displayed returns, and clears, the rich outputs of the
+// inputs since the last call, in the order displayed.
+// They are only kept under SetRichDisplay(true).
+func (it *Interp) Displayed() ([]DisplayData, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+
+	if err := LuaRun(it.lvm, `__gi_displayOut = __gi_displayDrain()`, false); err != nil {
+		return nil, err
+	}
+	out := luaGlobalString(it.lvm, "__gi_displayOut")
+	panicOn(LuaRun(it.lvm, `__gi_displayOut = nil`, false))
+	return parseDisplayed(out)
+}
+
+// parseDisplayed reads what __gi_displayDrain wrote: for
+// each output, its number of MIME types, then each type
+// and the length of its content, a line each, followed by
+// the content.
+func parseDisplayed(out string) ([]DisplayData, error) {
+	var ds []DisplayData
+	line := func() (string, error) {
+		i := strings.IndexByte(out, '\n')
+		if i < 0 {
+			return "", fmt.Errorf("display: truncated output")
+		}
+		s := out[:i]
+		out = out[i+1:]
+		return s, nil
+	}
+	for out != "" {
+		s, err := line()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("display: bad count '%s'", s)
+		}
+		d := make(DisplayData, n)
+		for i := 0; i < n; i++ {
+			mime, err := line()
+			if err != nil {
+				return nil, err
+			}
+			s, err := line()
+			if err != nil {
+				return nil, err
+			}
+			size, err := strconv.Atoi(s)
+			if err != nil || size > len(out) {
+				return nil, fmt.Errorf("display: bad length '%s' for %s", s, mime)
+			}
+			d[mime] = out[:size]
+			out = out[size:]
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
@@ -0,0 +1,56 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1370RichDisplay(t *testing.T) {
+
+	cv.Convey("gi/display values shown at the prompt, or passed to display.Show, should be kept as MIME bundles for a frontend under SetRichDisplay", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(it.SetRichDisplay(true))
+
+		panicOn(it.Eval(`import "gi/display"`))
+		panicOn(it.Eval(`display.HTML("<b>hi</b>")`))
+		panicOn(it.Eval(`t := display.Table([]string{"name", "age"}, [][]string{{"ann", "41"}, {"bob<", "7"}})
+display.Show(t)
+d := display.PNG([]byte("abcd")).With("text/latex", "$x$")
+n := len(d.MIME)`))
+		panicOn(it.Eval(`d`))
+		LuaMustInt(it.lvm, "n", 3)
+
+		ds, err := it.Displayed()
+		panicOn(err)
+		cv.So(ds, cv.ShouldResemble, []DisplayData{
+			{"text/html": "<b>hi</b>", "text/plain": "<b>hi</b>"},
+			{
+				"text/html": "<table>\n<tr><th>name</th><th>age</th></tr>\n" +
+					"<tr><td>ann</td><td>41</td></tr>\n<tr><td>bob&lt;</td><td>7</td></tr>\n</table>",
+				"text/plain": "name  age\nann   41\nbob<  7",
+			},
+			{"image/png": "YWJjZA==", "text/latex": "$x$", "text/plain": "<image/png, 4 bytes>"},
+		})
+		ds, err = it.Displayed()
+		panicOn(err)
+		cv.So(ds, cv.ShouldBeEmpty)
+
+		// a Data inside another value is shown as a struct.
+		panicOn(it.Eval(`type Q struct{ D display.Data }
+q := Q{D: display.HTML("y")}`))
+		panicOn(it.Eval(`q`))
+		ds, err = it.Displayed()
+		panicOn(err)
+		cv.So(ds, cv.ShouldBeEmpty)
+
+		// without rich display, only the text is printed.
+		panicOn(it.SetRichDisplay(false))
+		panicOn(it.Eval(`display.Markdown("*x*")`))
+		ds, err = it.Displayed()
+		panicOn(err)
+		cv.So(ds, cv.ShouldBeEmpty)
+	})
+}
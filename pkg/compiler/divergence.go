@@ -0,0 +1,336 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Divergence is an entry of the catalog of the known ways
+// in which gi's semantics differ from gc's.
+type Divergence struct {
+	Code  string // as GI-W001
+	Title string
+
+	// Explain says what gi does, what gc does, and
+	// what to do about it.
+	Explain string
+
+	// strict is set if -strict rejects code that
+	// touches it; the others are differences in what
+	// Go leaves unspecified, or in how gi is hosted.
+	strict bool
+}
+
+// Divergences is the catalog, by code.
+var Divergences = []*Divergence{
+	{Code: "GI-W001", Title: "integers smaller than 64 bits do not wrap", strict: true, Explain: `
+gi holds integers of every size in 64 bits, as LuaJIT's int64_t and
+uint64_t. Arithmetic on int8, int16, int32, uint8, uint16 and uint32
+(and so byte and rune) is done in 64 bits, and its result is not
+wrapped back into the range of the type: an int8 holding 127, plus 1,
+is 128 in gi, where gc gives -128.
+
+Do the arithmetic in int or int64, and check the range yourself, or
+mask explicitly, as in (x + y) & 0xff.`},
+
+	{Code: "GI-W002", Title: "integer conversions keep the value", strict: true, Explain: `
+A conversion between integer types, such as int8(x) or uint(x), does
+not truncate the value to the size of the new type, nor reinterpret
+its bits when the signedness changes: int8(300) of a variable is 300
+in gi, and uint8 of -1 is -1; gc gives 44 and 255.
+
+Mask or range check explicitly before converting, as in uint8(x & 0xff).`},
+
+	{Code: "GI-W003", Title: "float32 is computed in float64 precision", strict: true, Explain: `
+A float32 (and each half of a complex64) is rounded to float32 when
+it is converted from another type, but arithmetic on it is done, and
+kept, in float64 precision: float32(16777216) + 1 is 16777217 in gi,
+where gc rounds it back to 16777216. Results can differ in the last
+digits from gc, and accumulate.
+
+Convert to float32 after each operation, as in float32(a * b), where
+bit-exact results matter; or use float64.`},
+
+	{Code: "GI-W004", Title: "indexing a string yields a character", strict: true, Explain: `
+s[i] on a string gives the UTF-8 encoded character starting at byte
+i, not the byte there. For ASCII text the two agree; on "héllo", s[1]
+is "é" in gi, where gc gives the byte 0xc3. len(s) and slicing, s[i:j],
+are by bytes as in gc.
+
+Index []byte(s) instead, for bytes.`},
+
+	{Code: "GI-W005", Title: "package reflect sees Lua values", strict: true, Explain: `
+reflect is gc's own package, run on the host. reflect.TypeOf of a
+value of a type gi declared gives gi's view of that type: its name,
+kind, and struct fields with their tags. But gi holds a struct as a
+pointer to it, so T{} and &T{} have the same type there. The rest of
+reflect, ValueOf included, is given the Lua values behind Go's, as
+proxies, and describes those: two distinct named types may look the
+same.
+
+Use a type switch or type assertion, which gi implements, to tell
+types apart.`},
+
+	{Code: "GI-W006", Title: "map iteration order is not randomized", Explain: `
+Ranging over a map visits its keys in the order of the Lua table that
+holds it, which is often stable from run to run. gc randomizes the
+order, on purpose, so that code does not come to depend on it: code
+that works in gi by relying on an order can fail when built with gc.
+
+Sort the keys when order matters. Under -deterministic, gi ranges in
+key order, on purpose; it does not warn then.`},
+
+	{Code: "GI-W007", Title: "pointers are not addresses", strict: true, Explain: `
+gi's pointers are references to Lua values, not memory addresses.
+uintptr is 64 bits, as on amd64, and unsafe.Sizeof reports amd64's
+sizes, but converting an unsafe.Pointer to uintptr does not give an
+address, nor does converting a uintptr back to a pointer give the
+value at one.
+
+Avoid pointer arithmetic; use slices and indices.`},
+}
+
+// divergenceByCode returns the catalog entry for code,
+// matched without regard to case, or nil.
+func divergenceByCode(code string) *Divergence {
+	for _, d := range Divergences {
+		if strings.EqualFold(d.Code, code) {
+			return d
+		}
+	}
+	return nil
+}
+
+// ExplainDivergence returns the catalog's explanation of
+// code, as GI-W003, or, given "", the list of codes.
+func ExplainDivergence(code string) (string, error) {
+	if code == "" {
+		var b strings.Builder
+		for _, d := range Divergences {
+			fmt.Fprintf(&b, "%s  %s\n", d.Code, d.Title)
+		}
+		return b.String(), nil
+	}
+	d := divergenceByCode(code)
+	if d == nil {
+		return "", fmt.Errorf("no divergence %s; 'gi explain' lists them", code)
+	}
+	return fmt.Sprintf("%s: %s\n%s\n", d.Code, d.Title, d.Explain), nil
+}
+
+// GiExplainMain implements gi explain. args are those
+// after "explain": a code, or none to list the codes. It
+// returns the exit code.
+func GiExplainMain(args []string) int {
+	code := ""
+	if len(args) > 0 {
+		code = args[0]
+	}
+	s, err := ExplainDivergence(code)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi explain: %v\n", err)
+		return 1
+	}
+	fmt.Print(s)
+	return 0
+}
+
+// divergenceSite is a place where an input touches a
+// divergence.
+type divergenceSite struct {
+	d   *Divergence
+	pos token.Pos
+	msg string
+}
+
+// findDivergences returns the places in files where the
+// translation touches a divergence of the catalog, in
+// source order. The checks follow what the translation
+// does today. ranged is whether to report ranging over
+// maps.
+func findDivergences(files []*ast.File, info *types.Info, ranged bool) []divergenceSite {
+	var sites []divergenceSite
+	report := func(code string, pos token.Pos, format string, args ...interface{}) {
+		sites = append(sites, divergenceSite{d: divergenceByCode(code), pos: pos, msg: fmt.Sprintf(format, args...)})
+	}
+	// arith checks arithmetic yielding typ, at pos.
+	arith := func(pos token.Pos, op token.Token, typ types.Type) {
+		b, ok := underlyingBasic(typ)
+		if !ok {
+			return
+		}
+		switch {
+		case b.Info()&types.IsInteger != 0:
+			if bits := intBits(b); bits < 64 && wraps(op) {
+				report("GI-W001", pos, "%s arithmetic is done in 64 bits, and does not wrap at %d bits", typ, bits)
+			}
+		case b.Kind() == types.Float32 || b.Kind() == types.Complex64:
+			if op != token.SHL && op != token.SHR {
+				report("GI-W003", pos, "%s arithmetic is done in float64 precision", typ)
+			}
+		}
+	}
+	constant := func(e ast.Expr) bool {
+		tv, ok := info.Types[e]
+		return ok && tv.Value != nil
+	}
+
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ImportSpec:
+				if path, _ := strconv.Unquote(n.Path.Value); path == "reflect" {
+					report("GI-W005", n.Pos(), "package reflect sees gi's Lua values, not their Go types")
+				}
+			case *ast.BinaryExpr:
+				if !constant(n) {
+					arith(n.OpPos, n.Op, info.TypeOf(n))
+				}
+			case *ast.UnaryExpr:
+				// negating a float32 is exact.
+				if b, ok := underlyingBasic(info.TypeOf(n)); ok && b.Info()&types.IsInteger != 0 && n.Op == token.SUB && !constant(n) {
+					arith(n.OpPos, n.Op, info.TypeOf(n))
+				}
+			case *ast.IncDecStmt:
+				op := token.ADD
+				if n.Tok == token.DEC {
+					op = token.SUB
+				}
+				arith(n.TokPos, op, info.TypeOf(n.X))
+			case *ast.AssignStmt:
+				if op, ok := assignOps[n.Tok]; ok {
+					arith(n.TokPos, op, info.TypeOf(n.Lhs[0]))
+				}
+			case *ast.IndexExpr:
+				if b, ok := underlyingBasic(info.TypeOf(n.X)); ok && b.Info()&types.IsString != 0 && !constant(n) {
+					report("GI-W004", n.Lbrack, "indexing a string yields the character there, not its byte")
+				}
+			case *ast.RangeStmt:
+				if _, ok := underlyingMap(info.TypeOf(n.X)); ok && ranged {
+					report("GI-W006", n.For, "ranging over a map visits its keys in Lua's table order, which gc randomizes")
+				}
+			case *ast.CallExpr:
+				tv, ok := info.Types[n.Fun]
+				if !ok || !tv.IsType() || len(n.Args) != 1 || constant(n.Args[0]) {
+					break
+				}
+				if code, msg := divergentConversion(info.TypeOf(n.Args[0]), tv.Type); msg != "" {
+					report(code, n.Lparen, "%s", msg)
+				}
+			}
+			return true
+		})
+	}
+	sort.SliceStable(sites, func(i, j int) bool { return sites[i].pos < sites[j].pos })
+	return sites
+}
+
+// assignOps maps the assignment operators to their
+// arithmetic.
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN: token.ADD,
+	token.SUB_ASSIGN: token.SUB,
+	token.MUL_ASSIGN: token.MUL,
+	token.QUO_ASSIGN: token.QUO,
+	token.SHL_ASSIGN: token.SHL,
+}
+
+// wraps reports whether op on integers can leave the
+// range of the operands' type.
+func wraps(op token.Token) bool {
+	switch op {
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.SHL:
+		return true
+	}
+	return false
+}
+
+// divergentConversion says how converting a value of type
+// from to type to differs in gi, and under which code, or
+// returns "".
+func divergentConversion(from, to types.Type) (code, msg string) {
+	f, ok := underlyingBasic(from)
+	if !ok {
+		return "", ""
+	}
+	t, ok := underlyingBasic(to)
+	if !ok {
+		return "", ""
+	}
+	if (f.Kind() == types.UnsafePointer) != (t.Kind() == types.UnsafePointer) && (f.Kind() == types.Uintptr || t.Kind() == types.Uintptr) {
+		return "GI-W007", fmt.Sprintf("conversion from %s to %s: gi's pointers are not addresses", from, to)
+	}
+	if t.Info()&types.IsInteger == 0 {
+		return "", ""
+	}
+	unsigned := t.Info()&types.IsUnsigned != 0
+	switch {
+	case f.Info()&types.IsInteger != 0:
+		if unsigned != (f.Info()&types.IsUnsigned != 0) {
+			return "GI-W002", fmt.Sprintf("conversion from %s to %s keeps the value, and does not reinterpret its bits", from, to)
+		}
+		if intBits(t) < intBits(f) {
+			return "GI-W002", fmt.Sprintf("conversion from %s to %s keeps the value, and does not truncate it to %d bits", from, to, intBits(t))
+		}
+	case f.Info()&types.IsFloat != 0:
+		if unsigned || intBits(t) < 64 {
+			return "GI-W002", fmt.Sprintf("conversion from %s to %s yields an int64", from, to)
+		}
+	}
+	return "", ""
+}
+
+// intBits is the size of the integer type b in gc.
+func intBits(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32:
+		return 32
+	}
+	return 64
+}
+
+func underlyingBasic(t types.Type) (*types.Basic, bool) {
+	if t == nil {
+		return nil, false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return b, ok
+}
+
+func underlyingMap(t types.Type) (*types.Map, bool) {
+	if t == nil {
+		return nil, false
+	}
+	m, ok := t.Underlying().(*types.Map)
+	return m, ok
+}
+
+// divergenceWarnings returns a warning for each site of a
+// divergence not already warned about in this session,
+// the first site of each; later inputs stay quiet about it.
+func (tr *IncrState) divergenceWarnings(sites []divergenceSite) []string {
+	var warns []string
+	for _, s := range sites {
+		if tr.warned[s.d.Code] {
+			continue
+		}
+		if tr.warned == nil {
+			tr.warned = make(map[string]bool)
+		}
+		tr.warned[s.d.Code] = true
+		warns = append(warns, fmt.Sprintf("%s: warning: %s [%s; see: gi explain %s]",
+			tr.CurPkg.fileSet.Position(s.pos), s.msg, s.d.Code, s.d.Code))
+	}
+	return warns
+}
@@ -0,0 +1,75 @@
+package compiler
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1356DivergenceWarningsAreGivenOnceAndExplained(t *testing.T) {
+
+	cv.Convey("the first use of each known divergence from gc warns, at the use, with the code to explain it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`var f float32 = 1.5; f = f * 3`))
+		cv.So(it.Warnings(), cv.ShouldResemble, []string{
+			"repl[1]:1:28: warning: float32 arithmetic is done in float64 precision [GI-W003; see: gi explain GI-W003]",
+		})
+		LuaMustFloat64(it.lvm, "f", 4.5)
+		cv.So(it.Warnings(), cv.ShouldBeEmpty)
+
+		// once a session, per divergence.
+		panicOn(it.Eval(`f = f / 2`))
+		cv.So(it.Warnings(), cv.ShouldBeEmpty)
+
+		panicOn(it.Eval(`m := map[string]int{"a": 1}
+n := 0
+for _, v := range m {
+	n += v
+}
+var b byte = 'a'; b++`))
+		cv.So(it.Warnings(), cv.ShouldResemble, []string{
+			"repl[3]:3:1: warning: ranging over a map visits its keys in Lua's table order, which gc randomizes [GI-W006; see: gi explain GI-W006]",
+			"repl[3]:6:20: warning: byte arithmetic is done in 64 bits, and does not wrap at 8 bits [GI-W001; see: gi explain GI-W001]",
+		})
+		LuaMustInt64(it.lvm, "n", 1)
+
+		// code that gi runs as gc does is quiet.
+		panicOn(it.Eval(`var i int = 3; i = i*2 + 1; s := "abc"[1:]`))
+		cv.So(it.Warnings(), cv.ShouldBeEmpty)
+	})
+
+	cv.Convey("under -deterministic, map ranges are in key order on purpose, and do not warn", t, func() {
+		cfg := NewGIConfig()
+		cfg.Deterministic = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`m := map[int]int{1: 1}; for range m {}`))
+		cv.So(it.Warnings(), cv.ShouldBeEmpty)
+	})
+
+	cv.Convey("every divergence of the catalog has its own code, and an explanation", t, func() {
+		for i, d := range Divergences {
+			cv.So(d.Code, cv.ShouldEqual, fmt.Sprintf("GI-W%03d", i+1))
+			s, err := ExplainDivergence(d.Code)
+			panicOn(err)
+			cv.So(s, cv.ShouldStartWith, d.Code+": "+d.Title+"\n\n")
+			cv.So(len(d.Explain), cv.ShouldBeGreaterThan, 100)
+		}
+		s, err := ExplainDivergence("gi-w004")
+		panicOn(err)
+		cv.So(s, cv.ShouldContainSubstring, "[]byte(s)")
+
+		list, err := ExplainDivergence("")
+		panicOn(err)
+		cv.So(list, cv.ShouldStartWith, "GI-W001  integers smaller than 64 bits do not wrap\n")
+
+		_, err = ExplainDivergence("GI-W999")
+		cv.So(err.Error(), cv.ShouldEqual, "no divergence GI-W999; 'gi explain' lists them")
+	})
+}
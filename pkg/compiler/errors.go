@@ -0,0 +1,176 @@
+package compiler
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	golua "github.com/glycerine/golua/lua"
+	"github.com/glycerine/luar"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowErrorsSrc declares the errors package to the type
+// checker; prelude/zerrors.lua is the package, and also
+// gives fmt.Errorf its %w.
+//
+// Errors from Go packages are strings in Lua, their
+// messages, and luar remembers the Go error behind each,
+// so a chain can go from interpreted errors into Go's:
+// errors.Is(fmt.Errorf("load: %w", err), os.ErrNotExist)
+// asks Go about err. The other way, an interpreted error
+// passed to Go is a Go error with its message and what it
+// wraps.
+const shadowErrorsSrc = `package errors
+
+// New returns an error whose message is text. Each call
+// gives a distinct error.
+func New(text string) error { return nil }
+
+// Is reports whether err, or an error it wraps, is target:
+// equal to it, or saying so with an Is(error) bool method.
+func Is(err, target error) bool { return false }
+
+// As finds the first error in err's chain that can be
+// assigned to *target, sets *target to it, and reports
+// whether there was one. target must be a non-nil pointer.
+func As(err error, target interface{}) bool { return false }
+
+// Unwrap returns what err's Unwrap() error method gives,
+// or nil.
+func Unwrap(err error) error { return nil }
+
+// Join returns an error wrapping the non-nil errs, with
+// their messages on separate lines; nil if there are none.
+func Join(errs ...error) error { return nil }
+`
+
+var shadowErrors struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowErrorsPackage returns the type checker's view of
+// errors, shared by every Interp.
+func shadowErrorsPackage() *types.Package {
+	shadowErrors.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "errors.go", shadowErrorsSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "errors", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowErrors.pkg = pkg
+	})
+	return shadowErrors.pkg
+}
+
+// hostErrorIs is errors.Is for two errors from Go, by their
+// messages in Lua.
+func hostErrorIs(msg, target string) bool {
+	if msg == target {
+		return true
+	}
+	err, terr := luar.HostError(msg), luar.HostError(target)
+	if err == nil || terr == nil {
+		return false
+	}
+	return errors.Is(err, terr)
+}
+
+// hostErrorUnwrap is errors.Unwrap for an error from Go.
+func hostErrorUnwrap(msg string) error {
+	if err := luar.HostError(msg); err != nil {
+		return errors.Unwrap(err)
+	}
+	return nil
+}
+
+// luaError is an interpreted error given to Go: its message,
+// and the errors it wraps, taken when it was passed.
+type luaError struct {
+	msg  string
+	errs []error
+}
+
+func (e *luaError) Error() string { return e.msg }
+
+func (e *luaError) Unwrap() error {
+	if len(e.errs) == 1 {
+		return e.errs[0]
+	}
+	return nil
+}
+
+// luaJoinedError is a luaError wrapping several errors.
+type luaJoinedError struct{ luaError }
+
+func (e *luaJoinedError) Unwrap() []error { return e.errs }
+
+var (
+	goErrorType          = reflect.TypeOf((*error)(nil)).Elem()
+	goEmptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func init() {
+	luar.RegisterInterfaceAdapter(adaptLuaError)
+}
+
+// adaptLuaError is the luar.InterfaceAdapter for error, and
+// for interface{}, when the table is an error.
+func adaptLuaError(L *golua.State, idx int, t reflect.Type) (reflect.Value, bool) {
+	if t != goErrorType && t != goEmptyInterfaceType {
+		return reflect.Value{}, false
+	}
+	err := luaErrorAt(L, idx)
+	if err == nil {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(&err).Elem(), true
+}
+
+// luaErrorAt makes the Go error for the value at idx, or
+// returns nil if it is not an error.
+func luaErrorAt(L *golua.State, idx int) error {
+	if idx < 0 {
+		idx = L.GetTop() + idx + 1
+	}
+	switch {
+	case L.IsString(idx) && !L.IsNumber(idx):
+		return luar.ErrorOf(L.ToString(idx))
+	case !L.IsTable(idx):
+		return nil
+	}
+	// __gi_errorParts gives the message and a list of the
+	// wrapped errors, or nothing for a table that is not
+	// an error.
+	L.GetGlobal("__gi_errorParts")
+	L.PushValue(idx)
+	if err := L.Call(1, 2); err != nil {
+		L.Pop(1)
+		return nil
+	}
+	defer L.Pop(2)
+	if L.IsNil(-2) {
+		return nil
+	}
+	e := &luaError{msg: L.ToString(-2)}
+	if L.IsTable(-1) {
+		n := int(L.ObjLen(-1))
+		for i := 1; i <= n; i++ {
+			L.RawGeti(-1, i)
+			if w := luaErrorAt(L, -1); w != nil {
+				e.errs = append(e.errs, w)
+			}
+			L.Pop(1)
+		}
+	}
+	if len(e.errs) > 1 {
+		return &luaJoinedError{*e}
+	}
+	return e
+}
@@ -0,0 +1,82 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1348Errors(t *testing.T) {
+
+	cv.Convey("errors.New, Is and Unwrap follow fmt.Errorf's %w chains", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"errors"
+	"fmt"
+)
+base := errors.New("base")
+w := fmt.Errorf("ctx %d: %w", 7, base)
+msg := w.Error()
+isBase := errors.Is(w, base)
+isOther := errors.Is(w, errors.New("base"))
+unwrapped := errors.Unwrap(w) == base
+plain := errors.Unwrap(fmt.Errorf("no %v", base)) == nil`))
+		LuaMustString(it.lvm, "msg", "ctx 7: base")
+		LuaMustBool(it.lvm, "isBase", true)
+		LuaMustBool(it.lvm, "isOther", false)
+		LuaMustBool(it.lvm, "unwrapped", true)
+		LuaMustBool(it.lvm, "plain", true)
+	})
+
+	cv.Convey("errors.As finds user error types in the chain, and Join wraps several", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"errors"
+	"fmt"
+)
+type MyErr struct { Code int }
+func (e *MyErr) Error() string { return fmt.Sprintf("code %d", e.Code) }
+var e error = &MyErr{3}
+w := fmt.Errorf("outer: %w", e)
+var me *MyErr
+found := errors.As(w, &me)
+code := me.Code
+printed := fmt.Sprintf("[%v]", w)
+base := errors.New("base")
+j := errors.Join(base, nil, e)
+joined := j.Error()
+joinIs := errors.Is(j, base)
+var me2 *MyErr
+joinAs := errors.As(j, &me2)`))
+		LuaMustBool(it.lvm, "found", true)
+		LuaMustInt64(it.lvm, "code", 3)
+		LuaMustString(it.lvm, "printed", "[outer: code 3]")
+		LuaMustString(it.lvm, "joined", "base\ncode 3")
+		LuaMustBool(it.lvm, "joinIs", true)
+		LuaMustBool(it.lvm, "joinAs", true)
+	})
+
+	cv.Convey("chains reach into errors from Go packages", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"errors"
+	"fmt"
+	"os"
+)
+_, err := os.Open("/no/such/dir/gi-errors-test")
+w := fmt.Errorf("load: %w", err)
+notExist := errors.Is(w, os.ErrNotExist)
+exist := errors.Is(w, os.ErrExist)`))
+		LuaMustBool(it.lvm, "notExist", true)
+		LuaMustBool(it.lvm, "exist", false)
+	})
+}
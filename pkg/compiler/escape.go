@@ -0,0 +1,234 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/compiler/astutil"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// A struct value is a Lua table, made by its type's
+// constructor, so that
+//
+//	p := Vec{1, 2}
+//	s += p.X * p.Y
+//
+// allocates a table each time it runs, which a hot loop
+// pays for in garbage. When a local struct never escapes,
+// and is never used whole, gi keeps its fields in Lua
+// locals of their own instead:
+//
+//	local __gensym_1_p_X, __gensym_2_p_Y = 1, 2
+//	s = s + __gensym_1_p_X * __gensym_2_p_Y
+//
+// A struct is kept so when its fields are all numbers,
+// bools or strings, none embedded; it is declared by := of
+// a composite literal, or by var with no value; and every
+// use of it, outside func literals, reads or assigns one
+// of its fields. Being passed, returned, assigned, copied,
+// compared, having its address or a field's taken, having
+// a method called, or being captured by a closure, all
+// keep it a table.
+
+// scalarStruct is a local struct kept in the locals of its
+// fields.
+type scalarStruct struct {
+	fields []*types.Var
+	locals []string   // the Lua local of each field
+	init   []ast.Expr // each field's initial value; nil for zero
+}
+
+// findScalarStructs finds the locals of body, a function's,
+// that can be kept in the locals of their fields.
+func (c *funcContext) findScalarStructs(body *ast.BlockStmt) {
+	if body == nil || len(c.Flattened) != 0 {
+		return
+	}
+	found := make(map[*types.Var]*scalarStruct)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// translated as functions of their own.
+			return false
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE && len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				c.scalarCandidate(found, n.Lhs[0], n.Rhs[0], true)
+			}
+		case *ast.DeclStmt:
+			if gd, ok := n.Decl.(*ast.GenDecl); ok && gd.Tok == token.VAR && len(gd.Specs) == 1 {
+				spec := gd.Specs[0].(*ast.ValueSpec)
+				switch {
+				case len(spec.Names) == 1 && len(spec.Values) == 0:
+					c.scalarCandidate(found, spec.Names[0], nil, false)
+				case len(spec.Names) == 1 && len(spec.Values) == 1:
+					c.scalarCandidate(found, spec.Names[0], spec.Values[0], true)
+				}
+			}
+		}
+		return true
+	})
+	if len(found) == 0 {
+		return
+	}
+
+	// every use must be a field's, outside func literals.
+	fieldUse := make(map[*ast.Ident]bool)
+	var check func(n ast.Node, inFunc bool) bool
+	check = func(n ast.Node, inFunc bool) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(m ast.Node) bool { return check(m, true) })
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				if sel, ok := n.X.(*ast.SelectorExpr); ok {
+					if id, ok := sel.X.(*ast.Ident); ok {
+						delete(found, c.localVar(id))
+					}
+				}
+			}
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && !inFunc {
+				if sc := found[c.localVar(id)]; sc != nil && sc.field(n.Sel.Name) >= 0 {
+					fieldUse[id] = true
+				}
+			}
+		case *ast.Ident:
+			if v, ok := c.p.Uses[n].(*types.Var); ok && found[v] != nil && !fieldUse[n] {
+				delete(found, v)
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, func(n ast.Node) bool { return check(n, false) })
+
+	vars := make([]*types.Var, 0, len(found))
+	for v := range found {
+		vars = append(vars, v)
+	}
+	// in order, so that the same code has the same Lua.
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Pos() < vars[j].Pos() })
+	for _, v := range vars {
+		sc := found[v]
+		for _, f := range sc.fields {
+			sc.locals = append(sc.locals, c.gensym(v.Name()+"_"+f.Name()))
+		}
+		if c.scalars == nil {
+			c.scalars = make(map[*types.Var]*scalarStruct)
+		}
+		c.scalars[v] = sc
+	}
+}
+
+// scalarCandidate adds lhs, declared with the value rhs, or
+// none, to found if it is a struct that could be kept in
+// the locals of its fields.
+func (c *funcContext) scalarCandidate(found map[*types.Var]*scalarStruct, lhs, rhs ast.Expr, hasValue bool) {
+	id, ok := lhs.(*ast.Ident)
+	if !ok || isBlank(id) {
+		return
+	}
+	v, ok := c.p.Defs[id].(*types.Var)
+	if !ok {
+		return
+	}
+	st, ok := v.Type().Underlying().(*types.Struct)
+	if !ok || st.NumFields() == 0 {
+		return
+	}
+	sc := &scalarStruct{init: make([]ast.Expr, st.NumFields())}
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		b, ok := f.Type().Underlying().(*types.Basic)
+		if f.Anonymous() || !ok || b.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsString) == 0 {
+			return
+		}
+		sc.fields = append(sc.fields, f)
+	}
+	if hasValue {
+		lit, ok := astutil.RemoveParens(rhs).(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		last := -1
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				key, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					return
+				}
+				j := sc.field(key.Name)
+				if j <= last {
+					// kept in the order written, so that
+					// its values are evaluated in it.
+					return
+				}
+				sc.init[j], last = kv.Value, j
+				continue
+			}
+			sc.init[i] = elt
+		}
+	}
+	found[v] = sc
+}
+
+// field returns the index of sc's field name, or -1.
+func (sc *scalarStruct) field(name string) int {
+	for i, f := range sc.fields {
+		if f.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// localVar returns the variable id uses, if it is one.
+func (c *funcContext) localVar(id *ast.Ident) *types.Var {
+	v, _ := c.p.Uses[id].(*types.Var)
+	return v
+}
+
+// scalarDecl returns the Lua that declares the fields of
+// lhs, if it is a struct kept in them.
+func (c *funcContext) scalarDecl(lhs ast.Expr) (string, bool) {
+	id, ok := lhs.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	v, _ := c.p.Defs[id].(*types.Var)
+	sc := c.scalars[v]
+	if sc == nil {
+		return "", false
+	}
+	values := make([]string, len(sc.fields))
+	for i, f := range sc.fields {
+		x := sc.init[i]
+		if x == nil {
+			x = c.zeroValue(f.Type())
+		}
+		values[i] = c.translateImplicitConversion(x, f.Type()).String()
+	}
+	return fmt.Sprintf("local %s = %s;", strings.Join(sc.locals, ", "), strings.Join(values, ", ")), true
+}
+
+// scalarField returns the Lua local of the field e selects,
+// if its struct is kept in the locals of its fields.
+func (c *funcContext) scalarField(e *ast.SelectorExpr) (string, bool) {
+	id, ok := e.X.(*ast.Ident)
+	if !ok || c.scalars == nil {
+		return "", false
+	}
+	sc := c.scalars[c.localVar(id)]
+	if sc == nil {
+		return "", false
+	}
+	i := sc.field(e.Sel.Name)
+	if i < 0 {
+		return "", false
+	}
+	return sc.locals[i], true
+}
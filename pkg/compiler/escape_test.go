@@ -0,0 +1,94 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1404LocalStructsThatDoNotEscapeAreKeptInLocals(t *testing.T) {
+
+	cv.Convey("a local struct used only through its fields is kept in a local per field, not a table; one that is passed, copied, or has a method called stays a table", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		src := `
+type Vec struct{ X, Y float64 }
+
+type Rec struct {
+	N    int
+	Name string
+	On   bool
+}
+
+func (v Vec) Len2() float64 { return v.X*v.X + v.Y*v.Y }
+
+func len2(v Vec) float64 { return v.X*v.X + v.Y*v.Y }
+
+func sum(n int) float64 {
+	s := 0.0
+	for i := 0; i < n; i++ {
+		p := Vec{float64(i), 2}
+		p.X += 1
+		p.Y *= p.X
+		s += p.X * p.Y
+	}
+	return s
+}
+
+func zero() int {
+	var r Rec
+	r.N++
+	k := Rec{Name: "k", On: true}
+	if k.On {
+		r.N += len(k.Name)
+	}
+	return r.N
+}
+
+func method() float64 {
+	q := Vec{3, 4}
+	return q.Len2()
+}
+
+func passed() float64 {
+	q := Vec{3, 4}
+	q.X = 5
+	return len2(q)
+}
+
+func copied() float64 {
+	q := Vec{3, 4}
+	r := q
+	r.X = 1
+	return q.X + r.X
+}
+`
+		tr, err := it.Translate(src)
+		panicOn(err)
+		cv.So(tr, cv.ShouldContainSubstring, "local __gensym_1_p_X, __gensym_2_p_Y = ")
+		cv.So(tr, cv.ShouldContainSubstring, "_r_N, ")
+		cv.So(tr, cv.ShouldContainSubstring, "_k_N, ")
+		// only method's, passed's and copied's q are tables.
+		cv.So(strings.Count(tr, "__type__.Vec.ptrToNewlyConstructed("), cv.ShouldEqual, 3)
+		cv.So(strings.Count(tr, "__type__.Rec.ptrToNewlyConstructed("), cv.ShouldEqual, 0)
+
+		it2, err := NewInterp(nil)
+		panicOn(err)
+		defer it2.Close()
+		panicOn(it2.Eval(src + `
+a := sum(10)
+b := zero()
+c := method()
+d := passed()
+e := copied()
+`))
+		LuaMustFloat64(it2.lvm, "a", 770)
+		LuaMustInt64(it2.lvm, "b", 2)
+		LuaMustFloat64(it2.lvm, "c", 25)
+		LuaMustFloat64(it2.lvm, "d", 41)
+		LuaMustFloat64(it2.lvm, "e", 4)
+	})
+}
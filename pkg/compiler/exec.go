@@ -0,0 +1,335 @@
+package compiler
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gi exec runs a block of Go and prints just what it
+// printed, the values of its expressions included, for
+// literate documents: org-babel's gi source blocks, through
+// emacs/ob-gi.el, or any tool that pipes a block through a
+// command.
+//
+//	gi exec -stdin-block < block.go
+//	gi exec -session NAME -stdin-block < block.go
+//	gi exec -session NAME -kill
+//
+// Without -session, each block runs in a new session. With
+// it, the blocks of one NAME run in the same session, held
+// by a background gi that the first of them starts, so that
+// each sees what the ones before declared; -kill ends it.
+// The background gi listens on a Unix socket in a directory
+// only its user can reach, and speaks ServeRequests and
+// ServeReplies as lines of JSON; it exits after -idle
+// without a block.
+//
+// An error in a block is printed on standard error, and
+// gi exec exits 1.
+
+// sessionNameRE is what a session name may be, as it names
+// the socket file.
+var sessionNameRE = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// sessionSocket returns the path of the socket of the named
+// session, making its directory if need be.
+func sessionSocket(name string) (string, error) {
+	if !sessionNameRE.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("bad session name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("gi-sessions-%d", os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 {
+		return "", fmt.Errorf("session directory '%s' must be a directory of mode 0700", dir)
+	}
+	return filepath.Join(dir, name+".sock"), nil
+}
+
+// ServeExecSession runs the blocks sent to l in it, until a
+// client asks it to stop, or, if idle is not zero, no block
+// has come for that long.
+func ServeExecSession(it *Interp, l net.Listener, idle time.Duration) error {
+	var evalMu sync.Mutex
+	var intr interruptWatcher
+	var stopOnce sync.Once
+	stopped := make(chan struct{})
+	stop := func() {
+		stopOnce.Do(func() {
+			close(stopped)
+			l.Close()
+		})
+	}
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.AfterFunc(idle, stop)
+	}
+
+	serve := func(c net.Conn) {
+		defer c.Close()
+		dec := json.NewDecoder(bufio.NewReader(c))
+		enc := json.NewEncoder(c)
+		for {
+			var req ServeRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			reply := ServeReply{ID: req.ID, Op: req.Op}
+			switch req.Op {
+			case "eval":
+				evalMu.Lock()
+				select {
+				case <-stopped:
+					evalMu.Unlock()
+					return
+				default:
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				res := evalCaptured(it, &intr, req.Code)
+				if timer != nil {
+					timer.Reset(idle)
+				}
+				evalMu.Unlock()
+				reply.Output, reply.Error, reply.Warnings = res.Output, res.Error, res.Warnings
+			case "interrupt":
+				reply.Interrupted, _ = intr.interrupt()
+			case "kill":
+				intr.interrupt()
+				evalMu.Lock()
+				stop()
+				evalMu.Unlock()
+				enc.Encode(reply)
+				return
+			default:
+				reply.Error = fmt.Sprintf("unknown op '%s'", req.Op)
+			}
+			if err := enc.Encode(reply); err != nil {
+				return
+			}
+		}
+	}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			select {
+			case <-stopped:
+				// let a block still running finish.
+				evalMu.Lock()
+				evalMu.Unlock()
+				return nil
+			default:
+			}
+			return err
+		}
+		go serve(c)
+	}
+}
+
+// dialSession connects to the named session, starting it
+// with a gi run with globalArgs if it is not running.
+func dialSession(name string, globalArgs []string, idle time.Duration) (net.Conn, error) {
+	path, err := sessionSocket(name)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		return c, nil
+	}
+	// not running, or left behind by one that died.
+	os.Remove(path)
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	logf, err := os.OpenFile(strings.TrimSuffix(path, ".sock")+".log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer logf.Close()
+	args := append(append([]string{}, globalArgs...), "exec", "-session", name, "-idle", idle.String(), "-serve-session")
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); {
+		if c, err := net.Dial("unix", path); err == nil {
+			return c, nil
+		}
+		select {
+		case err := <-exited:
+			return nil, fmt.Errorf("session '%s' did not start (%v); see %s", name, err, logf.Name())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil, fmt.Errorf("session '%s' did not start in 30s; see %s", name, logf.Name())
+}
+
+// execRemote sends req to the session at c, and returns its
+// reply.
+func execRemote(c net.Conn, req ServeRequest) (ServeReply, error) {
+	var reply ServeReply
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return reply, err
+	}
+	err := json.NewDecoder(c).Decode(&reply)
+	return reply, err
+}
+
+// GiExecMain implements gi exec; it returns the exit code.
+// globalArgs are gi's flags before "exec", for a session it
+// starts to be run with.
+func GiExecMain(cfg *GIConfig, globalArgs, args []string) int {
+	fs := flag.NewFlagSet("gi exec", flag.ContinueOnError)
+	session := fs.String("session", "", "run in the persistent session of this name, starting it if need be")
+	stdinBlock := fs.Bool("stdin-block", false, "read the block to run from standard input, to its end")
+	kill := fs.Bool("kill", false, "end the -session")
+	idle := fs.Duration("idle", 2*time.Hour, "end a -session started now after this long without a block; 0 means never")
+	serveSession := fs.Bool("serve-session", false, "be the background gi of the -session; gi exec starts it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	// only the block's own output is printed.
+	cfg.Quiet = true
+	var code string
+	switch {
+	case *serveSession || *kill:
+		if *session == "" || *stdinBlock || fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "usage: gi exec -session NAME -kill\n")
+			return 2
+		}
+	case *stdinBlock && fs.NArg() == 0:
+		by, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+			return 1
+		}
+		code = string(by)
+	case !*stdinBlock && fs.NArg() > 0:
+		code = strings.Join(fs.Args(), "\n")
+	default:
+		fmt.Fprintf(os.Stderr, "usage: gi exec [-session NAME] (-stdin-block | code...)\n")
+		return 2
+	}
+
+	if *serveSession {
+		return serveExecSessionMain(cfg, *session, *idle)
+	}
+	if *session == "" {
+		it, err := NewInterp(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+			return 1
+		}
+		defer it.Close()
+		var intr interruptWatcher
+		res := evalCaptured(it, &intr, code)
+		return printExecReply(ServeReply{Output: res.Output, Error: res.Error, Warnings: res.Warnings})
+	}
+
+	path, err := sessionSocket(*session)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	if *kill {
+		c, err := net.Dial("unix", path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gi exec: no session '%s'\n", *session)
+			return 1
+		}
+		defer c.Close()
+		execRemote(c, ServeRequest{Op: "kill"})
+		return 0
+	}
+	c, err := dialSession(*session, globalArgs, *idle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	// Ctrl-C interrupts the block, over a second connection.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+			if ic, err := net.Dial("unix", path); err == nil {
+				execRemote(ic, ServeRequest{Op: "interrupt"})
+				ic.Close()
+			}
+		}
+	}()
+	reply, err := execRemote(c, ServeRequest{Op: "eval", Code: code})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: session '%s': %v\n", *session, err)
+		return 1
+	}
+	return printExecReply(reply)
+}
+
+func printExecReply(reply ServeReply) int {
+	fmt.Print(reply.Output)
+	for _, w := range reply.Warnings {
+		fmt.Fprintf(os.Stderr, "%s\n", w)
+	}
+	if reply.Error != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", strings.TrimRight(reply.Error, "\n"))
+		return 1
+	}
+	return 0
+}
+
+// serveExecSessionMain is the background gi of a session.
+func serveExecSessionMain(cfg *GIConfig, name string, idle time.Duration) int {
+	// a Ctrl-C meant for a gi exec reaches its whole process
+	// group, this one too; the interrupt op is how to stop a
+	// block.
+	signal.Ignore(os.Interrupt)
+
+	path, err := sessionSocket(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	it, err := NewInterp(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	defer it.Close()
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	defer os.Remove(path)
+	if err := ServeExecSession(it, l, idle); err != nil {
+		fmt.Fprintf(os.Stderr, "gi exec: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1378ExecSessions(t *testing.T) {
+
+	cv.Convey("a gi exec session runs the blocks sent to it in one session, until killed", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		dir, err := ioutil.TempDir("", "gi-exec-test")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		l, err := net.Listen("unix", filepath.Join(dir, "s.sock"))
+		panicOn(err)
+		done := make(chan error)
+		go func() { done <- ServeExecSession(it, l, 0) }()
+
+		block := func(op, code string) ServeReply {
+			c, err := net.Dial("unix", filepath.Join(dir, "s.sock"))
+			panicOn(err)
+			defer c.Close()
+			reply, err := execRemote(c, ServeRequest{Op: op, Code: code})
+			panicOn(err)
+			return reply
+		}
+		cv.So(block("eval", "import \"fmt\"\nx := 21\n"), cv.ShouldResemble, ServeReply{Op: "eval"})
+		cv.So(block("eval", "fmt.Println(x * 2)\nx + 1\n").Output, cv.ShouldEqual, "42\n22\n")
+		cv.So(block("eval", "y := nope").Error, cv.ShouldContainSubstring, "undeclared name: nope")
+		cv.So(block("frob", "").Error, cv.ShouldEqual, "unknown op 'frob'")
+
+		// an interrupt, over another connection, stops a block.
+		c, err := net.Dial("unix", filepath.Join(dir, "s.sock"))
+		panicOn(err)
+		replies := make(chan ServeReply)
+		go func() {
+			r, err := execRemote(c, ServeRequest{Op: "eval", Code: "for {}"})
+			panicOn(err)
+			replies <- r
+		}()
+		var interrupted bool
+		for i := 0; i < 100 && !interrupted; i++ {
+			time.Sleep(20 * time.Millisecond)
+			interrupted = block("interrupt", "").Interrupted
+		}
+		cv.So(interrupted, cv.ShouldBeTrue)
+		cv.So((<-replies).Error, cv.ShouldContainSubstring, "interrupted")
+		c.Close()
+
+		cv.So(block("kill", ""), cv.ShouldResemble, ServeReply{Op: "kill"})
+		cv.So(<-done, cv.ShouldBeNil)
+	})
+
+	cv.Convey("a session with an idle limit ends by itself", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		dir, err := ioutil.TempDir("", "gi-exec-test")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		l, err := net.Listen("unix", filepath.Join(dir, "s.sock"))
+		panicOn(err)
+		cv.So(ServeExecSession(it, l, 50*time.Millisecond), cv.ShouldBeNil)
+	})
+
+	cv.Convey("session names must be safe as file names", t, func() {
+		for _, bad := range []string{"", "..", "a/b", "a b", "../x"} {
+			_, err := sessionSocket(bad)
+			cv.So(err, cv.ShouldNotBeNil)
+		}
+		path, err := sessionSocket("notes-1.v2")
+		panicOn(err)
+		cv.So(filepath.Base(path), cv.ShouldEqual, "notes-1.v2.sock")
+		fi, err := os.Stat(filepath.Dir(path))
+		panicOn(err)
+		cv.So(fi.Mode().Perm(), cv.ShouldEqual, os.FileMode(0700))
+	})
+}
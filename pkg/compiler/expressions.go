@@ -21,7 +21,6 @@ import (
 
 var _ = debug.Stack
 
-var debugNilCount int
 var pp = verb.PP
 var p1 = verb.P
 
@@ -161,12 +160,6 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		}
 	}
 	pp("obj is '%#v'", obj)
-	if obj == nil {
-		debugNilCount++
-		if debugNilCount > 2 {
-			//panic("where obj nil?")
-		}
-	}
 
 	if obj != nil && typesutil.IsJsPackage(obj.Pkg()) {
 		switch obj.Name() {
@@ -282,6 +275,11 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 					}
 				}
 			}
+			// Go's own structs are made by Go, their
+			// unexported fields having no Lua types.
+			if named, ok := exprType.(*types.Named); ok && len(e.Elts) == 0 && isShadowPkg(named.Obj().Pkg()) {
+				return c.formatExpr("%s.ptrToNewlyConstructed()", c.typeName(0, exprType))
+			}
 			//flds := structFieldTypes(t)
 			sele := "nil"
 			if len(elements) > 0 {
@@ -350,7 +348,10 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 			case *ast.Ident:
 				obj := c.p.Uses[x].(*types.Var)
 				if c.p.escapingVars[obj] {
-					return c.formatExpr("(%2s(function() return this.__target[0]; end, function(__v) this.__target[0] = __v; end, %1s))", c.p.objectNames[obj], c.typeName(0, exprType))
+					// the variable is boxed; the pointer keeps the box
+					// it was made with, as the variable may be boxed
+					// anew, on the next iteration of a loop.
+					return c.formatExpr("(function(__b) return %2s(function() return __b[0]; end, function(__v) __b[0] = __v; end, __b); end)(%1s)", c.p.objectNames[obj], c.typeName(0, exprType))
 					// return c.formatExpr("(%1s.__ptr || (%1s.__ptr = new %2s(function() { return this.__target[0]; }, function(__v) { this.__target[0] = __v; }, %1s)))", c.p.objectNames[obj], c.typeName(exprType))
 				}
 
@@ -617,6 +618,9 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 			//   just give us nils back silently instead of complaining about
 			//   out of bounds access.
 			pp("expressions.go:484 slice, e.X='%#v', e.Index='%#v'", e.X, e.Index)
+			if flat, ok := c.flatIndex(e); ok {
+				return c.formatExpr("%s", flat)
+			}
 			return c.formatExpr(rangeCheck("%1e[%2f]", c.p.Types[e.Index].Value != nil, false), e.X, e.Index)
 			// return c.formatExpr(rangeCheck("%1e.__array[%1e.__offset + %2f]", c.p.Types[e.Index].Value != nil, false), e.X, e.Index)
 		case *types.Map:
@@ -663,16 +667,17 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		if b, isBasic := c.p.TypeOf(e.X).Underlying().(*types.Basic); isBasic && isString(b) {
 			switch {
 			// e is a slice expression, the slice is from [Low:High).
+			// string.sub wants Lua numbers, not int cdata.
 			case e.Low == nil && e.High == nil:
 				return c.translateExpr(e.X, nil)
 			case e.Low == nil:
-				return c.formatExpr("string.sub(%e, 1, %f)", e.X, e.High)
+				return c.formatExpr("string.sub(%e, 1, tonumber(%f))", e.X, e.High)
 				//return c.formatExpr("__substring(%e, 0, %f)", e.X, e.High)
 			case e.High == nil:
-				return c.formatExpr("string.sub(%e, %f+1)", e.X, e.Low)
+				return c.formatExpr("string.sub(%e, tonumber(%f)+1)", e.X, e.Low)
 				//return c.formatExpr("__substring(%e, %f)", e.X, e.Low)
 			default:
-				return c.formatExpr("string.sub(%e, %f+1, %f)", e.X, e.Low, e.High)
+				return c.formatExpr("string.sub(%e, tonumber(%f)+1, tonumber(%f))", e.X, e.Low, e.High)
 				//return c.formatExpr("__substring(%e, %f, %f)", e.X, e.Low, e.High)
 			}
 		}
@@ -695,6 +700,9 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		}
 
 	case *ast.SelectorExpr:
+		if name, ok := c.scalarField(e); ok {
+			return c.formatExpr("%s", name)
+		}
 		sel, ok := c.p.SelectionOf(e)
 		if !ok {
 			// qualified identifier
@@ -735,6 +743,9 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		}
 
 	case *ast.CallExpr:
+		if x, ok := c.inlineCall(e); ok {
+			return x
+		}
 		plainFun := astutil.RemoveParens(e.Fun)
 
 		if astutil.IsTypeExpr(plainFun, c.p.Info.Info) {
@@ -859,6 +870,18 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 					}
 				}
 
+				if isReflectStructTag(declaredFuncRecv) {
+					// a tag is a Lua string; see prelude/zreflect.lua.
+					switch sel.Obj().Name() {
+					case "Get", "Lookup":
+						return c.formatExpr("__gi_structTag%s(%s, %e)", sel.Obj().Name(), recv, e.Args[0])
+					}
+				}
+				if isStringsBuilder(declaredFuncRecv) {
+					// made in Lua; see prelude/zstrings.lua.
+					return c.translateCall(e, sig, c.formatExpr("%s:%s", recv, sel.Obj().Name()))
+				}
+
 				methodName := sel.Obj().Name()
 				if reservedKeywords[methodName] {
 					methodName += "_"
@@ -906,7 +929,7 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		}
 		if c1, isCall := e.X.(*ast.CallExpr); isCall && len(c1.Args) == 1 {
 			if c2, isCall := c1.Args[0].(*ast.CallExpr); isCall && len(c2.Args) == 1 && types.Identical(c.p.TypeOf(c2.Fun), types.Typ[types.UnsafePointer]) {
-				if unary, isUnary := c2.Args[0].(*ast.UnaryExpr); isUnary && unary.Op == token.AND {
+				if unary, isUnary := c2.Args[0].(*ast.UnaryExpr); isUnary && unary.Op == token.AND && types.Identical(exprType, c.p.TypeOf(unary.X)) {
 					return c.translateExpr(unary.X, nil) // unsafe conversion
 				}
 			}
@@ -937,6 +960,9 @@ func (c *funcContext) translateExpr(expr ast.Expr, desiredType types.Type) (xprn
 		if e.Name == "_" {
 			panic("Tried to translate underscore identifier.")
 		}
+		if arg, ok := c.inlineArg(obj); ok {
+			return arg
+		}
 		pp("under *ast.Ident, obj='%#v'/%T", obj, obj)
 		switch o := obj.(type) {
 		case *types.Var, *types.Const:
@@ -1171,6 +1197,25 @@ func (c *funcContext) translateBuiltin(name string, sig *types.Signature, args [
 		keyType := c.p.TypeOf(args[0]).Underlying().(*types.Map).Key()
 		pp("delete, keyType='%v'", keyType)
 		return c.formatExpr(`%e("delete",%s)`, args[0], c.translateImplicitConversion(args[1], keyType))
+	case "clear":
+		if _, isMap := c.p.TypeOf(args[0]).Underlying().(*types.Map); isMap {
+			return c.formatExpr("__clearMap(%e)", args[0])
+		}
+		return c.formatExpr("__clearSlice(%e)", args[0])
+	case "min", "max":
+		// a constant result was folded by translateExpr;
+		// this is the rest, nested pairwise.
+		t := sig.Results().At(0).Type()
+		fn := "__" + name
+		if basic, isBasic := t.Underlying().(*types.Basic); isBasic && isFloat(basic) {
+			fn = "__f" + name
+		}
+		argStr := c.translateArgs(sig, args, ellipsis)
+		x := argStr[0]
+		for _, y := range argStr[1:] {
+			x = fmt.Sprintf("%s(%s, %s)", fn, x, y)
+		}
+		return c.formatExpr("%s", x)
 	case "copy":
 		if basic, isBasic := c.p.TypeOf(args[1]).Underlying().(*types.Basic); isBasic && isString(basic) {
 			return c.formatExpr("__copyString(%e, %e)", args[0], args[1])
@@ -1254,36 +1299,28 @@ func (c *funcContext) translateConversion(expr ast.Expr, desiredType types.Type)
 	switch t := desiredType.Underlying().(type) {
 	case *types.Basic:
 		switch {
+		case isInteger(t) && types.Identical(exprType, types.Typ[types.UnsafePointer]):
+			return c.formatExpr("__gi_unsafeAddr(%e)", expr)
 		case isInteger(t):
 			basicExprType := exprType.Underlying().(*types.Basic)
 			switch {
-			case is64Bit(t):
-				if !is64Bit(basicExprType) {
-					if basicExprType.Kind() == types.Uintptr { // this might be an Object returned from reflect.Value.Pointer()
-						return c.formatExpr("%1s(%2e)", c.typeName(0, desiredType), expr)
-						//return c.formatExpr("%1s(0, %2e.constructor == Number ? %2e : 1)", c.typeName(0, desiredType), expr)
-					}
-					return c.formatExpr("%s(%e)", c.typeName(0, desiredType), expr)
-				}
-				return c.formatExpr("%s(%e)", c.typeName(0, desiredType), expr)
-			case is64Bit(basicExprType):
-				if !isUnsigned(t) && !isUnsigned(basicExprType) {
-					return c.formatParenExpr("%e", expr)
-				}
-				return c.formatExpr("%s", c.translateExpr(expr, nil), t)
-			case isFloat(basicExprType):
-				// jea
-				//return c.formatParenExpr("%e >> 0", expr)
-				return c.formatParenExpr("int(%e)", expr)
 			case types.Identical(exprType, types.Typ[types.UnsafePointer]):
 				return c.translateExpr(expr, nil)
-			default:
-				// 201 not here
-				return c.fixNumber(c.translateExpr(expr, nil), t)
-			}
+			case basicExprType.Kind() == types.Uintptr && is64Bit(t):
+				// this might be an Object returned from reflect.Value.Pointer()
+				return c.formatExpr("%1s(%2e)", c.typeName(0, desiredType), expr)
+			case isInteger(basicExprType) && isUnsigned(basicExprType) == isUnsigned(t) &&
+				sizes64.Sizeof(basicExprType) <= sizes64.Sizeof(t):
+				// every value of the one is one of the other.
+				return c.formatParenExpr("%e", expr)
+			}
+			return c.fixInteger(c.translateExpr(expr, nil), t)
 		case isFloat(t):
-			if t.Kind() == types.Float32 && exprType.Underlying().(*types.Basic).Kind() == types.Float64 {
-				return c.formatExpr("__fround(%e)", expr) // fround returns the nearest 32-bit single precision float representation of a Number.
+			if t.Kind() == types.Float32 {
+				if isFloat(exprType.Underlying().(*types.Basic)) {
+					return c.formatExpr("__fround(%e)", expr) // fround returns the nearest 32-bit single precision float representation of a Number.
+				}
+				return c.formatExpr("__fround(tonumber(%e))", expr)
 			}
 			return c.formatExpr("tonumber(%f)", expr)
 		case isComplex(t):
@@ -1292,9 +1329,6 @@ func (c *funcContext) translateConversion(expr ast.Expr, desiredType types.Type)
 			value := c.translateExpr(expr, nil)
 			switch et := exprType.Underlying().(type) {
 			case *types.Basic:
-				if is64Bit(et) {
-					value = c.formatExpr("%s.__low", value)
-				}
 				if isNumeric(et) {
 					return c.formatExpr("__encodeRune(%s)", value)
 				}
@@ -1309,6 +1343,9 @@ func (c *funcContext) translateConversion(expr ast.Expr, desiredType types.Type)
 				panic(fmt.Sprintf("Unhandled conversion: %v\n", et))
 			}
 		case t.Kind() == types.UnsafePointer:
+			if b, ok := exprType.Underlying().(*types.Basic); ok && isInteger(b) {
+				return c.formatExpr("__gi_unsafeFromAddr(%e)", expr)
+			}
 			if unary, isUnary := expr.(*ast.UnaryExpr); isUnary && unary.Op == token.AND {
 				if indexExpr, isIndexExpr := unary.X.(*ast.IndexExpr); isIndexExpr {
 					return c.formatExpr("__sliceToArray(%s)", c.translateConversionToSlice(indexExpr.X, types.NewSlice(types.Typ[types.Uint8])))
@@ -1351,6 +1388,9 @@ func (c *funcContext) translateConversion(expr ast.Expr, desiredType types.Type)
 		}
 
 	case *types.Pointer:
+		if types.Identical(exprType, types.Typ[types.UnsafePointer]) && c.p.Pkg.Path() != "syscall" {
+			return c.formatExpr("__gi_unsafePointerTo(%e, %s)", expr, c.typeName(0, desiredType))
+		}
 		switch u := t.Elem().Underlying().(type) {
 		case *types.Array:
 			return c.translateExpr(expr, nil)
@@ -1456,6 +1496,16 @@ func (c *funcContext) translateImplicitConversion(expr ast.Expr, desiredType typ
 			// references to functions arrive here.
 			return c.formatExpr("%e", expr)
 		}
+		if isBoxed(exprType) {
+			if named, ok := exprType.(*types.Named); ok && isPkgLevel(named.Obj()) && named.Obj().Pkg() != c.p.Pkg {
+				// a type bound from Go has no Lua type
+				// to box with, and its values stay bare.
+				o := named.Obj()
+				c.p.dependencies[o] = true
+				return c.formatExpr("__gi_box(%s, %s, %e)", strconv.Quote(omitAnyShadowPathPrefix(o.Pkg().Path())), strconv.Quote(o.Name()), expr)
+			}
+			return c.formatExpr("%s(%e)", c.typeName(0, exprType), expr)
+		}
 		pp("!isWrapped for exprType='%#v'", exprType)
 		if _, isStruct := exprType.Underlying().(*types.Struct); isStruct {
 			pp("YYY 7 translateImplicitConversion exiting early")
@@ -1578,6 +1628,24 @@ func (c *funcContext) fixNumber(value *expression, basic *types.Basic) (xprn *ex
 	}
 }
 
+// fixInteger converts value, an integer or a float, to
+// the integer type basic by Go's rules: a float truncates
+// toward zero, and an integer keeps those of its low bits
+// that fit. gi holds every signed integer in an int64 and
+// every unsigned one in a uint64, so the narrower types
+// pass through their own LuaJIT ctype on the way.
+func (c *funcContext) fixInteger(value *expression, basic *types.Basic) *expression {
+	wide := "int64"
+	if isUnsigned(basic) {
+		wide = "uint64"
+	}
+	if size := sizes64.Sizeof(basic); size < 8 {
+		narrow := fmt.Sprintf("%s%d", wide[:len(wide)-2], size*8)
+		return c.formatExpr("%s(%s(%s))", wide, narrow, value)
+	}
+	return c.formatExpr("%s(%s)", wide, value)
+}
+
 func (c *funcContext) asFloat64(value *expression, basic *types.Basic) (xprn *expression) {
 	pp("top of asFloat64 with value='%s'", x2s(value))
 	defer func() {
@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowFFISrc declares the gi/ffi package, a bridge to C
+// through LuaJIT's ffi, for the type checker. The bodies
+// are never run: the implementation is in prelude/zffi.lua.
+//
+//	ffi.Cdef(`size_t strlen(const char *s);`)
+//	var strlen func(s string) int
+//	ffi.C.Bind(&strlen, "strlen")
+//	n := strlen("hello")
+const shadowFFISrc = `package ffi
+
+// Lib is a shared library, or the C namespace of the process.
+type Lib struct{}
+
+// C is the C library and everything else the process
+// links, as ffi.C is.
+var C *Lib
+
+// Cdef declares C functions, structs and types, as ffi.cdef
+// does, for Bind to find.
+func Cdef(decls string) {}
+
+// Load loads the shared library name, as ffi.load does.
+func Load(name string) *Lib { return nil }
+
+// Bind sets fn, a pointer to a func variable, to call the
+// C function name, declared with Cdef. The arguments and
+// result convert as the func's type says: a Go string
+// result is read from a const char*, a struct from the
+// fields of the same names. Variadic arguments are spread,
+// for C functions such as printf.
+func (l *Lib) Bind(fn interface{}, name string) {}
+
+// Sizeof is the size in bytes of the C type ctype.
+func Sizeof(ctype string) int { return 0 }
+`
+
+var shadowFFI struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowFFIPackage returns the type checker's view of
+// gi/ffi, shared by every Interp.
+func shadowFFIPackage() *types.Package {
+	shadowFFI.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "ffi.go", shadowFFISrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "gi/ffi", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowFFI.pkg = pkg
+	})
+	return shadowFFI.pkg
+}
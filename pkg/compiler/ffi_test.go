@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1338FFIBindsCFunctionsToGoFuncs(t *testing.T) {
+
+	cv.Convey("gi/ffi declares C functions with Cdef, binds them to Go func variables, and converts arguments and results as the Go types say", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "gi/ffi"`))
+		panicOn(it.Eval("ffi.Cdef(`size_t strlen(const char *s); int abs(int); typedef struct { int quot; int rem; } div_t; div_t div(int, int); char *getenv(const char *);`)"))
+
+		panicOn(it.Eval(`var strlen func(s string) int; ffi.C.Bind(&strlen, "strlen"); n := strlen("hello")`))
+		LuaMustInt64(it.lvm, "n", 5)
+
+		panicOn(it.Eval(`var abs func(int32) int32; ffi.C.Bind(&abs, "abs"); a := abs(-7) == 7`))
+		LuaMustBool(it.lvm, "a", true)
+
+		// a struct result, field by field.
+		panicOn(it.Eval(`type divT struct{ quot, rem int32 }; var div func(a, b int32) divT; ffi.C.Bind(&div, "div"); d := div(17, 5); ok := d.quot == 3 && d.rem == 2`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		// a NULL const char* is "".
+		panicOn(it.Eval(`var getenv func(string) string; ffi.C.Bind(&getenv, "getenv"); v := getenv("GI_FFI_TEST_NO_SUCH_VAR")`))
+		LuaMustString(it.lvm, "v", "")
+
+		panicOn(it.Eval(`sz := ffi.Sizeof("div_t")`))
+		LuaMustInt64(it.lvm, "sz", 8)
+
+		err = it.Eval(`var nope func(); ffi.C.Bind(&nope, "gi_ffi_test_undeclared")`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "ffi: cannot bind gi_ffi_test_undeclared: missing declaration")
+
+		// checked like any Go.
+		err = it.Eval(`x := strlen(3)`)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("gi/ffi needs the ffi capability", t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapNone)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "gi/ffi"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "denied by sandbox policy")
+	})
+}
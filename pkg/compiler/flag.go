@@ -0,0 +1,238 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/compiler/shadow/os"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowFlagSrc declares the flag package to the type
+// checker. The flag sets are Lua tables, in
+// prelude/zflag.lua, so the pointers that String, Int
+// and the rest return are ordinary gi pointers; the
+// bodies here are never run.
+//
+// The command line that flag.Parse reads is that of the
+// script under gi run, its os.Args; see RunFile. At the
+// prompt there is none, gi's own flags being gi's.
+const shadowFlagSrc = `package flag
+
+// ErrHelp is what Parse returns for -help or -h,
+// when they are not defined.
+var ErrHelp error
+
+type ErrorHandling int
+
+const (
+	ContinueOnError ErrorHandling = iota
+	ExitOnError
+	PanicOnError
+)
+
+// Value is the interface to the value kept by a flag;
+// see Var.
+type Value interface {
+	String() string
+	Set(string) error
+}
+
+type Flag struct {
+	Name     string
+	Usage    string
+	Value    Value
+	DefValue string
+}
+
+type FlagSet struct {
+	// Usage is called when parsing fails, or for -help.
+	Usage func()
+}
+
+func NewFlagSet(name string, errorHandling ErrorHandling) *FlagSet { return nil }
+
+func (f *FlagSet) Init(name string, errorHandling ErrorHandling) {}
+func (f *FlagSet) Name() string                                  { return "" }
+func (f *FlagSet) ErrorHandling() ErrorHandling                  { return 0 }
+
+func (f *FlagSet) Bool(name string, value bool, usage string) *bool                 { return nil }
+func (f *FlagSet) BoolVar(p *bool, name string, value bool, usage string)           {}
+func (f *FlagSet) Int(name string, value int, usage string) *int                    { return nil }
+func (f *FlagSet) IntVar(p *int, name string, value int, usage string)              {}
+func (f *FlagSet) Int64(name string, value int64, usage string) *int64              { return nil }
+func (f *FlagSet) Int64Var(p *int64, name string, value int64, usage string)        {}
+func (f *FlagSet) Uint(name string, value uint, usage string) *uint                 { return nil }
+func (f *FlagSet) UintVar(p *uint, name string, value uint, usage string)           {}
+func (f *FlagSet) Uint64(name string, value uint64, usage string) *uint64           { return nil }
+func (f *FlagSet) Uint64Var(p *uint64, name string, value uint64, usage string)     {}
+func (f *FlagSet) Float64(name string, value float64, usage string) *float64        { return nil }
+func (f *FlagSet) Float64Var(p *float64, name string, value float64, usage string)  {}
+func (f *FlagSet) String(name string, value string, usage string) *string           { return nil }
+func (f *FlagSet) StringVar(p *string, name string, value string, usage string)     {}
+func (f *FlagSet) Func(name, usage string, fn func(string) error)                   {}
+func (f *FlagSet) BoolFunc(name, usage string, fn func(string) error)               {}
+func (f *FlagSet) Var(value Value, name string, usage string)                       {}
+
+// Parse parses arguments, which should not include the
+// command name. It stops at the first non-flag argument,
+// or after a terminating "--".
+func (f *FlagSet) Parse(arguments []string) error { return nil }
+func (f *FlagSet) Parsed() bool                   { return false }
+func (f *FlagSet) Args() []string                 { return nil }
+func (f *FlagSet) Arg(i int) string               { return "" }
+func (f *FlagSet) NArg() int                      { return 0 }
+func (f *FlagSet) NFlag() int                     { return 0 }
+func (f *FlagSet) Lookup(name string) *Flag       { return nil }
+func (f *FlagSet) Set(name, value string) error   { return nil }
+func (f *FlagSet) Visit(fn func(*Flag))           {}
+func (f *FlagSet) VisitAll(fn func(*Flag))        {}
+func (f *FlagSet) PrintDefaults()                 {}
+
+// CommandLine is the flag set of the script's command
+// line, os.Args[1:].
+var CommandLine *FlagSet
+
+// Usage prints the usage message of CommandLine.
+var Usage func()
+
+func Bool(name string, value bool, usage string) *bool                { return nil }
+func BoolVar(p *bool, name string, value bool, usage string)          {}
+func Int(name string, value int, usage string) *int                   { return nil }
+func IntVar(p *int, name string, value int, usage string)             {}
+func Int64(name string, value int64, usage string) *int64             { return nil }
+func Int64Var(p *int64, name string, value int64, usage string)       {}
+func Uint(name string, value uint, usage string) *uint                { return nil }
+func UintVar(p *uint, name string, value uint, usage string)          {}
+func Uint64(name string, value uint64, usage string) *uint64          { return nil }
+func Uint64Var(p *uint64, name string, value uint64, usage string)    {}
+func Float64(name string, value float64, usage string) *float64       { return nil }
+func Float64Var(p *float64, name string, value float64, usage string) {}
+func String(name string, value string, usage string) *string          { return nil }
+func StringVar(p *string, name string, value string, usage string)    {}
+func Func(name, usage string, fn func(string) error)                  {}
+func BoolFunc(name, usage string, fn func(string) error)              {}
+func Var(value Value, name string, usage string)                      {}
+
+func Parse()                           {}
+func Parsed() bool                     { return false }
+func Args() []string                   { return nil }
+func Arg(i int) string                 { return "" }
+func NArg() int                        { return 0 }
+func NFlag() int                       { return 0 }
+func Lookup(name string) *Flag         { return nil }
+func Set(name, value string) error     { return nil }
+func Visit(fn func(*Flag))             {}
+func VisitAll(fn func(*Flag))          {}
+func PrintDefaults()                   {}
+`
+
+var shadowFlag struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowFlagPackage returns the type checker's view of
+// the flag package, shared by every Interp.
+func shadowFlagPackage() *types.Package {
+	shadowFlag.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "flag.go", shadowFlagSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "flag", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowFlag.pkg = pkg
+	})
+	return shadowFlag.pkg
+}
+
+// the errors that flag.Value Set methods give, as
+// Go's flag package words them.
+var (
+	errFlagParse = errors.New("parse error")
+	errFlagRange = errors.New("value out of range")
+)
+
+func flagNumError(err error) error {
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return errFlagRange
+	}
+	return errFlagParse
+}
+
+// The parsers of flag values, for zflag.lua.
+
+func flagParseBool(s string) (bool, error) {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errFlagParse
+	}
+	return b, nil
+}
+
+func flagParseInt(s string, bits int) (int64, error) {
+	n, err := strconv.ParseInt(s, 0, bits)
+	if err != nil {
+		return 0, flagNumError(err)
+	}
+	return n, nil
+}
+
+func flagParseUint(s string, bits int) (uint64, error) {
+	n, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return 0, flagNumError(err)
+	}
+	return n, nil
+}
+
+func flagParseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, flagNumError(err)
+	}
+	return f, nil
+}
+
+// flagOutput is where flag sets print their errors
+// and usage, as in Go.
+func flagOutput(s string) {
+	fmt.Fprint(os.Stderr, s)
+}
+
+// flagExit ends a script whose flag set, made with
+// ExitOnError, failed to parse.
+func flagExit(code int) {
+	os.Exit(code)
+}
+
+// scriptArgs returns the command line of flag.CommandLine:
+// the script's under gi run, and at the prompt gi's name
+// alone.
+func (cfg *GIConfig) scriptArgs() []string {
+	if cfg.ScriptArgs != nil {
+		return cfg.ScriptArgs
+	}
+	return os.Args[:1]
+}
+
+// osPkg is the os package of the session: under gi run,
+// its Args are the script's.
+func (cfg *GIConfig) osPkg() map[string]interface{} {
+	if cfg.ScriptArgs == nil {
+		return shadow_os.Pkg
+	}
+	pkg := make(map[string]interface{}, len(shadow_os.Pkg))
+	for k, v := range shadow_os.Pkg {
+		pkg[k] = v
+	}
+	pkg["Args"] = cfg.ScriptArgs
+	return pkg
+}
@@ -0,0 +1,91 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1351FlagPackageReadsTheScriptsCommandLine(t *testing.T) {
+
+	cv.Convey("flag.Parse reads the script's os.Args, with Go's syntax for flags, and stops at the first argument", t, func() {
+		cfg := NewGIConfig()
+		cfg.ScriptArgs = []string{"tool.go", "-n", "5", "-name=bob", "--v", "-u", "0x10", "rest", "-x"}
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import ("flag"; "os")`))
+		panicOn(it.Eval("n := flag.Int(\"n\", 1, \"how many\"); name := flag.String(\"name\", \"x\", \"the `who` to greet\"); var v bool; flag.BoolVar(&v, \"v\", false, \"verbose\"); u := flag.Uint(\"u\", 0, \"u\")"))
+		panicOn(it.Eval(`flag.Parse(); nn := *n; nm := *name; uu := *u; na := flag.NArg(); a0 := flag.Arg(0); nargs := len(os.Args); set := flag.NFlag()`))
+		LuaMustInt64(it.lvm, "nn", 5)
+		LuaMustString(it.lvm, "nm", "bob")
+		LuaMustBool(it.lvm, "v", true)
+		LuaMustInt64(it.lvm, "na", 2)
+		LuaMustString(it.lvm, "a0", "rest")
+		LuaMustInt(it.lvm, "nargs", 9)
+		LuaMustInt64(it.lvm, "set", 4)
+		panicOn(it.Eval(`us := flag.Lookup("u").Value.String(); def := flag.Lookup("name").DefValue`))
+		LuaMustString(it.lvm, "us", "16")
+		LuaMustString(it.lvm, "def", "x")
+	})
+
+	cv.Convey("a FlagSet with ContinueOnError returns Go's errors, and takes a flag.Value of the script's own", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "flag"
+type list []string
+func (l *list) String() string {
+	s := ""
+	for i, t := range *l {
+		if i > 0 {
+			s += ","
+		}
+		s += t
+	}
+	return s
+}
+func (l *list) Set(s string) error { *l = append(*l, s); return nil }
+fs := flag.NewFlagSet("sub", flag.ContinueOnError)
+fs.Usage = func() {}
+q := fs.Float64("q", 1.5, "q")
+var tags list
+fs.Var(&tags, "tag", "a tag; repeatable")`))
+		panicOn(it.Eval(`err := fs.Parse([]string{"-tag", "a", "-tag=b", "-q", "abc"}); es := err.Error(); joined := tags.String()`))
+		LuaMustString(it.lvm, "es", `invalid value "abc" for flag -q: parse error`)
+		LuaMustString(it.lvm, "joined", "a,b")
+		panicOn(it.Eval(`err = fs.Parse([]string{"-nope"}); es = err.Error()`))
+		LuaMustString(it.lvm, "es", "flag provided but not defined: -nope")
+		panicOn(it.Eval(`err = fs.Parse([]string{"-h"}); help := err == flag.ErrHelp; err = fs.Parse([]string{"-q", "2", "--", "-q"}); qq := *q; rest := fs.Arg(0)`))
+		LuaMustBool(it.lvm, "help", true)
+		LuaMustFloat64(it.lvm, "qq", 2)
+		LuaMustString(it.lvm, "rest", "-q")
+	})
+
+	cv.Convey("gi run evaluates a package main file and calls main, with the arguments after it", t, func() {
+		dir, err := ioutil.TempDir("", "gi-run")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "tool.go")
+		panicOn(ioutil.WriteFile(path, []byte(`package main
+
+import "flag"
+
+var count = flag.Int("count", 1, "how many")
+
+func main() {
+	flag.Parse()
+	if *count != 3 || flag.Arg(0) != "in.txt" {
+		panic("wrong command line")
+	}
+}
+`), 0644))
+		cv.So(RunFile(NewGIConfig(), path, []string{"-count", "3", "in.txt"}), cv.ShouldBeNil)
+		cv.So(RunFile(NewGIConfig(), path, []string{"-count", "2", "in.txt"}), cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,238 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/constant"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// A slice is a table whose metatable finds its elements in
+// the table __array, from __offset, and checks each index
+// against its length. In a tight numeric loop that costs a
+// metamethod call, an int64 index and a bounds check per
+// element, none of which LuaJIT can compile away.
+//
+// So a loop over the indices of a slice of numbers,
+//
+//	for i := 0; i < len(xs); i++ {
+//		s += xs[i]
+//	}
+//
+// is translated to a numeric for, whose index is a Lua
+// number, reading and writing xs's __array directly:
+//
+//	local arr, off = xs.__array, tonumber(xs.__offset)
+//	for i = 0, tonumber(#xs) - 1 do
+//		s = s + arr[off + i]
+//	end
+//
+// That is only the same program if i is only ever an index
+// of xs, and xs stays the same slice while the loop runs:
+// see flatLoop for what is checked. Its bounds need no check,
+// since i runs below the length xs had at the start. A range
+// over a slice of numbers reads its elements the same way;
+// a range evaluates its slice once, so that needs no checks.
+
+// flatSlice is a slice whose elements a loop reaches
+// directly, through locals holding its __array and
+// __offset.
+type flatSlice struct {
+	slice    *types.Var
+	arr, off string
+}
+
+// flatLoop reports whether the for statement s loops over
+// the indices of a slice of numbers such that it can be
+// translated to a numeric for; if so, it returns the index
+// and the slice.
+func (c *funcContext) flatLoop(s *ast.ForStmt) (i, xs *types.Var, ok bool) {
+	if c.body == nil || c.Flattened[s] {
+		return nil, nil, false
+	}
+
+	// i := 0
+	init, ok := s.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || len(init.Rhs) != 1 {
+		return nil, nil, false
+	}
+	id, ok := init.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, false
+	}
+	i, ok = c.p.Defs[id].(*types.Var)
+	if !ok || !types.Identical(i.Type(), types.Typ[types.Int]) {
+		return nil, nil, false
+	}
+	if v := c.p.Types[init.Rhs[0]].Value; v == nil || constant.Sign(v) != 0 {
+		return nil, nil, false
+	}
+
+	// i < len(xs)
+	cond, ok := s.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.LSS || !c.isVar(cond.X, i) {
+		return nil, nil, false
+	}
+	call, ok := cond.Y.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, nil, false
+	}
+	if fun, ok := call.Fun.(*ast.Ident); !ok || c.p.Uses[fun] != types.Universe.Lookup("len") {
+		return nil, nil, false
+	}
+	arg, ok := call.Args[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, false
+	}
+	xs, ok = c.p.Uses[arg].(*types.Var)
+	if !ok || !isNumericSlice(xs.Type()) || !c.declaresHere(xs) {
+		return nil, nil, false
+	}
+
+	// i++
+	post, ok := s.Post.(*ast.IncDecStmt)
+	if !ok || post.Tok != token.INC || !c.isVar(post.X, i) {
+		return nil, nil, false
+	}
+
+	// the body uses i only as an index of xs, and leaves
+	// the loop only by break or return.
+	indexes := make(map[*ast.Ident]bool)
+	ok = true
+	ast.Inspect(s.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit, *ast.LabeledStmt:
+			ok = false
+		case *ast.BranchStmt:
+			if n.Tok != token.BREAK || n.Label != nil {
+				ok = false
+			}
+		case *ast.IndexExpr:
+			if id, isId := n.Index.(*ast.Ident); isId && c.isVar(n.X, xs) {
+				indexes[id] = true
+			}
+		case *ast.Ident:
+			if c.p.Uses[n] == i && !indexes[n] {
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok || c.mayChange(xs, s.Body) {
+		return nil, nil, false
+	}
+	return i, xs, true
+}
+
+// translateFlatLoop translates s, which flatLoop found
+// loops over the indices of xs with i, to a numeric for.
+func (c *funcContext) translateFlatLoop(s *ast.ForStmt, i, xs *types.Var) {
+	f := flatSlice{slice: xs, arr: c.gensym("arr"), off: c.gensym("off")}
+	slice := c.objectName(xs)
+	c.Printf("do local %s, %s = %s.__array, tonumber(%s.__offset);", f.arr, f.off, slice, slice)
+	c.Printf("for %s = 0, tonumber(#%s) - 1 do", c.objectName(i), slice)
+	if c.flat == nil {
+		c.flat = make(map[*types.Var]flatSlice)
+	}
+	c.flat[i] = f
+	c.Indent(func() {
+		prevEV := c.p.escapingVars
+		c.handleEscapingVars(s.Body)
+		c.translateStmtList(s.Body.List)
+		c.p.escapingVars = prevEV
+	})
+	delete(c.flat, i)
+	c.Printf("end end;")
+}
+
+// flatIndex returns the Lua of e, an index of a slice, if
+// it is one a flat loop reaches directly.
+func (c *funcContext) flatIndex(e *ast.IndexExpr) (string, bool) {
+	id, ok := e.Index.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	i, ok := c.p.Uses[id].(*types.Var)
+	if !ok {
+		return "", false
+	}
+	f, ok := c.flat[i]
+	if !ok || !c.isVar(e.X, f.slice) {
+		return "", false
+	}
+	return fmt.Sprintf("%s[%s + %s]", f.arr, f.off, c.objectName(i)), true
+}
+
+// isVar reports whether x is an identifier of v.
+func (c *funcContext) isVar(x ast.Expr, v *types.Var) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && c.p.Uses[id] == v
+}
+
+// isNumericSlice reports whether t is a slice of integers
+// or floats.
+func isNumericSlice(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsInteger|types.IsFloat) != 0
+}
+
+// declaresHere reports whether v is a parameter or local of
+// the function being translated, so that everything that
+// could change it is in its text.
+func (c *funcContext) declaresHere(v *types.Var) bool {
+	return c.typ != nil && v.Pos() >= c.typ.Pos() && v.Pos() < c.body.End()
+}
+
+// mayChange reports whether the variable v may be assigned,
+// or have its address taken, while body runs: in body, or
+// in a func literal of the function, which body might call.
+func (c *funcContext) mayChange(v *types.Var, body *ast.BlockStmt) bool {
+	changes := false
+	var inspect func(n ast.Node, assigns bool) bool
+	inspect = func(n ast.Node, assigns bool) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(m ast.Node) bool {
+				return inspect(m, true)
+			})
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND && c.isVar(n.X, v) {
+				changes = true
+			}
+		case *ast.IncDecStmt:
+			if assigns && c.isVar(n.X, v) {
+				changes = true
+			}
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				if id, ok := l.(*ast.Ident); ok && assigns && c.p.ObjectOf(id) == v {
+					changes = true
+				}
+			}
+		case *ast.RangeStmt:
+			for _, l := range []ast.Expr{n.Key, n.Value} {
+				if id, ok := l.(*ast.Ident); ok && assigns && c.p.ObjectOf(id) == v {
+					changes = true
+				}
+			}
+		}
+		return !changes
+	}
+	ast.Inspect(c.body, func(n ast.Node) bool {
+		if n == body {
+			ast.Inspect(body, func(m ast.Node) bool {
+				return inspect(m, true)
+			})
+			return false
+		}
+		return inspect(n, false)
+	})
+	return changes
+}
@@ -0,0 +1,101 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1403NumericLoopsReachTheirSlicesDirectly(t *testing.T) {
+
+	cv.Convey("a loop over the indices of a slice of numbers is a numeric for reading its __array, and a range over one reads it directly; loops that could see the slice change are left alone", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		src := `
+func sum(xs []float64) float64 {
+	s := 0.0
+	for i := 0; i < len(xs); i++ {
+		s += xs[i]
+	}
+	return s
+}
+
+func scale(xs []int, k int) {
+	for i := range xs {
+		xs[i] *= k
+	}
+}
+
+func total(xs []int) (n int) {
+	for _, x := range xs {
+		n += x
+	}
+	return
+}
+
+func dot(a, b []float64) float64 {
+	s := 0.0
+	for i := 0; i < len(a); i++ {
+		for j := 0; j < len(b); j++ {
+			if s > 10 {
+				break
+			}
+			s += a[i] * b[j]
+		}
+	}
+	return s
+}
+
+func shrinks(xs []int) int {
+	n := 0
+	for i := 0; i < len(xs); i++ {
+		n += xs[i]
+		xs = xs[:len(xs)-1]
+	}
+	return n
+}
+
+func counts(xs []int) int {
+	n := 0
+	for i := 0; i < len(xs); i++ {
+		n += i
+	}
+	return n
+}
+`
+		tr, err := it.Translate(src)
+		panicOn(err)
+		cv.So(tr, cv.ShouldContainSubstring, "for i = 0, tonumber(#xs) - 1 do")
+		cv.So(tr, cv.ShouldContainSubstring, "for j = 0, tonumber(#b) - 1 do")
+		// one each in sum, scale, total and dot's two loops.
+		cv.So(strings.Count(tr, ".__array, tonumber("), cv.ShouldEqual, 5)
+		// shrinks's xs changes in its loop; counts's i is more
+		// than an index.
+		cv.So(strings.Count(tr, "while (true) do"), cv.ShouldEqual, 2)
+		cv.So(strings.Count(tr, "__gi_GetRangeCheck"), cv.ShouldEqual, 1)
+
+		it2, err := NewInterp(nil)
+		panicOn(err)
+		defer it2.Close()
+		panicOn(it2.Eval(src + `
+fs := []float64{0, 1.5, 2.5, 3}
+a := sum(fs[1:3])
+ns := []int{1, 2, 3}
+scale(ns[1:], 10)
+b := total(ns)
+c := dot([]float64{1, 2}, []float64{3, 4})
+d := shrinks([]int{1, 2, 3, 4})
+e := counts([]int{5, 5, 5})
+f := sum(nil)
+`))
+		LuaMustFloat64(it2.lvm, "a", 4)
+		LuaMustInt64(it2.lvm, "b", 51)
+		LuaMustFloat64(it2.lvm, "c", 13)
+		LuaMustInt64(it2.lvm, "d", 3)
+		LuaMustInt64(it2.lvm, "e", 3)
+		LuaMustFloat64(it2.lvm, "f", 0)
+	})
+}
@@ -0,0 +1,29 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/gijit/gi/pkg/types"
+)
+
+// foreignMethod allows, under -monkey-patch, a method to be
+// declared at the prompt on a type of an imported package,
+// and warns the first time each type is patched: the method
+// joins the type for every session in the process. The types
+// bound from Go are refused, as their values come from Go and
+// never see methods added in Lua. See types.Config.ForeignMethods.
+func (tr *IncrState) foreignMethod(recv *types.Named) error {
+	if isShadowPkg(recv.Obj().Pkg()) {
+		return fmt.Errorf("%s.%s is bound from Go, and cannot be given methods", recv.Obj().Pkg().Name(), recv.Obj().Name())
+	}
+	key := "foreign:" + recv.String()
+	if !tr.warned[key] {
+		if tr.warned == nil {
+			tr.warned = make(map[string]bool)
+		}
+		tr.warned[key] = true
+		tr.warnings = append(tr.warnings, fmt.Sprintf("warning: adding methods to imported type %s.%s, for every session in this process",
+			recv.Obj().Pkg().Name(), recv.Obj().Name()))
+	}
+	return nil
+}
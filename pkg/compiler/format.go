@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/printer"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// An input accepted at the prompt is formatted as gofmt
+// would before it is run, so that what is kept of it, in the
+// history, a session image and what :source shows, reads the
+// same however it was typed. :fmt shows the formatting of a
+// block without running it.
+
+// inputPrinter prints as gofmt does.
+var inputPrinter = printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+
+// formatInput returns src, an input of top level
+// statements and declarations, formatted. It fails if src
+// does not parse; its comments are kept.
+func formatInput(src string) (formatted string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("formatting failed: %v", r)
+		}
+	}()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+
+	var b bytes.Buffer
+	prevEnd := token.NoPos
+	// sep starts what begins at pos: on the line it follows
+	// if it is a comment there, else on a new line, after a
+	// blank line if there was one.
+	sep := func(pos token.Pos, comment bool) {
+		switch {
+		case !prevEnd.IsValid():
+		case comment && line(pos) == line(prevEnd):
+			b.WriteString(" ")
+		case line(pos) > line(prevEnd)+1:
+			b.WriteString("\n\n")
+		default:
+			b.WriteString("\n")
+		}
+	}
+	var comments []*ast.Comment
+	for _, g := range file.Comments {
+		comments = append(comments, g.List...)
+	}
+	groups := file.Comments
+	for _, n := range file.Nodes {
+		for len(comments) > 0 && comments[0].Pos() < n.Pos() {
+			sep(comments[0].Pos(), true)
+			b.WriteString(comments[0].Text)
+			prevEnd = comments[0].End()
+			comments = comments[1:]
+		}
+		// those within n are printed with it.
+		inner := []*ast.CommentGroup{}
+		for len(groups) > 0 && groups[0].Pos() < n.End() {
+			if groups[0].Pos() >= n.Pos() {
+				inner = append(inner, groups[0])
+			}
+			groups = groups[1:]
+		}
+		for len(comments) > 0 && comments[0].Pos() < n.End() {
+			comments = comments[1:]
+		}
+		switch d := n.(type) {
+		case *ast.FuncDecl:
+			d.Doc = nil
+		case *ast.GenDecl:
+			d.Doc = nil
+		}
+		sep(n.Pos(), false)
+		err = inputPrinter.Fprint(&b, fset, &printer.CommentedNode{Node: n, Comments: inner})
+		if err != nil {
+			return "", err
+		}
+		prevEnd = n.End()
+	}
+	for _, c := range comments {
+		sep(c.Pos(), true)
+		b.WriteString(c.Text)
+		prevEnd = c.End()
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,65 @@
+package compiler
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1383InputsAreFormatted(t *testing.T) {
+
+	cv.Convey("formatInput formats top level statements and declarations as gofmt would, keeping comments", t, func() {
+		f, err := formatInput("// c\nx:=1 // trailing\n\n\ny:=2; z := 3\nfunc f( ) int {\n// body\nreturn x+1}\nx +2")
+		panicOn(err)
+		cv.So(f, cv.ShouldEqual, "// c\nx := 1 // trailing\n\ny := 2\nz := 3\nfunc f() int {\n\t// body\n\treturn x + 1\n}\nx + 2\n")
+
+		f, err = formatInput("// doc\ntype T struct{A int;Bb string}")
+		panicOn(err)
+		cv.So(f, cv.ShouldEqual, "// doc\ntype T struct {\n\tA  int\n\tBb string\n}\n")
+
+		_, err = formatInput("x := ")
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("an input is kept, in the history and for :source, formatted; :fmt formats without running", t, func() {
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal"}))
+		panicOn(cfg.ValidateConfig())
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		r.reader = bufio.NewReader(strings.NewReader("func f( ) int {return 2}\n:fmt\nw:=1;v:=2\n:}\n:fmt  nope( 1,2 )\n:source f\n"))
+		pr, pw, err := os.Pipe()
+		panicOn(err)
+		saved := os.Stdout
+		os.Stdout = pw
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(pr)
+			out <- string(b)
+		}()
+		r.Loop()
+		os.Stdout = saved
+		pw.Close()
+		got := <-out
+
+		cv.So(got, cv.ShouldEqual, "gi> //gi:result ok\ngi> w := 1\nv := 2\n//gi:result ok\ngi> nope(1, 2)\n//gi:result ok\n"+
+			"gi> // f, from input 1 (repl[1])\nfunc f() int { return 2 }\n//gi:result ok\ngi> [EOF]\n")
+		cv.So(r.history[len(r.history)-1], cv.ShouldEqual, "func f() int { return 2 }")
+		cv.So(r.interp.inc.pkgScope().Lookup("w"), cv.ShouldBeNil)
+	})
+}
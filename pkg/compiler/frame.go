@@ -0,0 +1,444 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowFrameSrc declares the gi/frame package, tables
+// read from CSV and JSON for a quick look at data, to the
+// type checker. prelude/zframe.lua keeps a Frame; the
+// parsing and the schemas are done here, in Go.
+//
+//	f, err := frame.ReadCSV("people.csv")
+//	f.Schema("Person")         // type Person struct { ... }
+//	var people []Person
+//	err = f.Into(&people)
+//	frame.Of(people)           // shown as a table
+const shadowFrameSrc = `package frame
+
+import "gi/display"
+
+// Frame is a table: named columns, each of an inferred Go
+// type, and rows of cells, kept as text. A Frame shown at
+// the prompt is displayed as a table, of at most as many
+// rows as :set maxwidth allows.
+type Frame struct {
+	Columns []string
+	// Types are the columns' types, int, float64, bool or
+	// string: the narrowest every cell but empty ones fits.
+	Types []string
+	Rows  [][]string
+}
+
+// ReadCSV reads the CSV file at path, its first record
+// the names of the columns.
+func ReadCSV(path string) (*Frame, error) { return nil, nil }
+
+// ReadJSON reads the JSON file at path: an array of
+// objects, or a stream of them, one a row. The columns
+// are the keys, in the order first seen. A cell of an
+// object or array keeps its JSON; a null is empty.
+func ReadJSON(path string) (*Frame, error) { return nil, nil }
+
+// Of makes a Frame of rows, a slice of structs or maps, as
+// encoding/json encodes them; it panics if rows is not.
+func Of(rows interface{}) *Frame { return nil }
+
+// Len is the number of rows.
+func (f *Frame) Len() int { return 0 }
+
+// Head returns a Frame of the first n rows.
+func (f *Frame) Head(n int) *Frame { return f }
+
+// Column returns the cells of the column named name; it
+// panics if there is none.
+func (f *Frame) Column(name string) []string { return nil }
+
+// Floats returns the column named name as numbers, NaN
+// for a cell that is not one, as gi/plot takes them.
+func (f *Frame) Floats(name string) []float64 { return nil }
+
+// Schema returns the declaration of a struct type, called
+// name, of a field for each column, tagged for Into.
+func (f *Frame) Schema(name string) string { return "" }
+
+// Into stores the rows in rows, a pointer to a slice of
+// structs or maps, by way of encoding/json: a cell goes to
+// the field its column's name, or json tag, matches.
+func (f *Frame) Into(rows interface{}) error { return nil }
+
+// Display makes a Frame a display.Displayer.
+func (f *Frame) Display() display.Data { return display.Data{} }
+`
+
+var shadowFrame struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowFramePackage returns the type checker's view of
+// gi/frame, shared by every Interp.
+func shadowFramePackage() *types.Package {
+	shadowFrame.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "frame.go", shadowFrameSrc, 0)
+		panicOn(err)
+		conf := &types.Config{Importer: displayImporter{}}
+		pkg, _, err := conf.Check(nil, nil, "gi/frame", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowFrame.pkg = pkg
+	})
+	return shadowFrame.pkg
+}
+
+// frameData is a Frame as it passes between zframe.lua
+// and Go, JSON encoded.
+type frameData struct {
+	Columns []string
+	Types   []string
+	Rows    [][]string
+}
+
+func (f *frameData) encode() string {
+	b, err := json.Marshal(f)
+	panicOn(err)
+	return string(b)
+}
+
+// frameRead reads the file at path, as format csv or
+// json, into a Frame, for zframe.lua; it returns the
+// Frame, or an error message. It needs the fs capability.
+func frameRead(policy *Policy, path, format string) (string, string) {
+	if !policy.Allows(CapFilesystem) {
+		return "", "frame: reading a file needs the fs capability"
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "frame: " + err.Error()
+	}
+	return frameParse(string(b), format)
+}
+
+// frameParse reads text, as format csv or json, into a
+// Frame.
+func frameParse(text, format string) (string, string) {
+	var f *frameData
+	var err error
+	switch format {
+	case "csv":
+		f, err = parseCSVFrame(text)
+	case "json":
+		f, err = parseJSONFrame(text)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+	if err != nil {
+		return "", "frame: " + err.Error()
+	}
+	return f.encode(), ""
+}
+
+func parseCSVFrame(text string) (*frameData, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	recs, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	f := &frameData{Columns: []string{}, Rows: [][]string{}}
+	if len(recs) == 0 {
+		f.Types = []string{}
+		return f, nil
+	}
+	f.Columns = recs[0]
+	for i, rec := range recs[1:] {
+		if len(rec) != len(f.Columns) {
+			return nil, fmt.Errorf("record %d has %d fields, not %d", i+2, len(rec), len(f.Columns))
+		}
+		f.Rows = append(f.Rows, rec)
+	}
+	for j := range f.Columns {
+		f.Types = append(f.Types, csvColumnType(f.Rows, j))
+	}
+	return f, nil
+}
+
+// csvColumnType infers the type of column j from the text
+// of its cells.
+func csvColumnType(rows [][]string, j int) string {
+	isInt, isFloat, isBool, any := true, true, true, false
+	for _, row := range rows {
+		s := strings.TrimSpace(row[j])
+		if s == "" {
+			continue
+		}
+		any = true
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			isInt = false
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			isFloat = false
+		}
+		if _, err := strconv.ParseBool(s); err != nil {
+			isBool = false
+		}
+	}
+	switch {
+	case !any:
+		return "string"
+	case isInt:
+		return "int"
+	case isFloat:
+		return "float64"
+	case isBool:
+		return "bool"
+	}
+	return "string"
+}
+
+func parseJSONFrame(text string) (*frameData, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var objs []json.RawMessage
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			var elems []json.RawMessage
+			if err := json.Unmarshal(raw, &elems); err != nil {
+				return nil, err
+			}
+			objs = append(objs, elems...)
+		} else {
+			objs = append(objs, raw)
+		}
+	}
+
+	f := &frameData{Columns: []string{}, Types: []string{}, Rows: [][]string{}}
+	index := map[string]int{}
+	var kinds []map[string]bool
+	var cells []map[int]string
+	for i, raw := range objs {
+		row := map[int]string{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return nil, fmt.Errorf("row %d is not an object", i+1)
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := t.(string)
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			j, ok := index[key]
+			if !ok {
+				j = len(f.Columns)
+				index[key] = j
+				f.Columns = append(f.Columns, key)
+				kinds = append(kinds, map[string]bool{})
+			}
+			cell, kind := jsonCell(v)
+			row[j] = cell
+			if kind != "" {
+				kinds[j][kind] = true
+			}
+		}
+		cells = append(cells, row)
+	}
+	for _, row := range cells {
+		r := make([]string, len(f.Columns))
+		for j, cell := range row {
+			r[j] = cell
+		}
+		f.Rows = append(f.Rows, r)
+	}
+	for _, k := range kinds {
+		typ := "string"
+		switch {
+		case len(k) == 1 && k["int"]:
+			typ = "int"
+		case len(k) == 1 && k["bool"]:
+			typ = "bool"
+		case len(k) >= 1 && len(k) <= 2 && k["float64"] && (len(k) == 1 || k["int"]):
+			typ = "float64"
+		}
+		f.Types = append(f.Types, typ)
+	}
+	return f, nil
+}
+
+// jsonCell gives the text of a JSON value as a cell, and
+// its kind: int, float64, bool, string, json for an object
+// or an array, or "" for null.
+func jsonCell(v json.RawMessage) (cell, kind string) {
+	s := string(v)
+	switch {
+	case s == "null":
+		return "", ""
+	case s == "true" || s == "false":
+		return s, "bool"
+	case s[0] == '"':
+		var str string
+		panicOn(json.Unmarshal(v, &str))
+		return str, "string"
+	case s[0] == '{' || s[0] == '[':
+		var b bytes.Buffer
+		panicOn(json.Compact(&b, v))
+		return b.String(), "json"
+	case strings.ContainsAny(s, ".eE"):
+		return s, "float64"
+	}
+	return s, "int"
+}
+
+// frameRecords turns a Frame into a JSON array of objects,
+// a cell a member named for its column and typed as it, for
+// Into. An empty cell, or one that is not of its column's
+// type, is left out.
+func frameRecords(frame string) (string, string) {
+	var f frameData
+	if err := json.Unmarshal([]byte(frame), &f); err != nil {
+		return "", "frame: " + err.Error()
+	}
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, row := range f.Rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		n := 0
+		for j, cell := range row {
+			if j >= len(f.Columns) {
+				break
+			}
+			v, ok := jsonValue(cell, f.Types[j])
+			if !ok {
+				continue
+			}
+			if n > 0 {
+				b.WriteByte(',')
+			}
+			n++
+			key, _ := json.Marshal(f.Columns[j])
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	return b.String(), ""
+}
+
+// jsonValue encodes the cell s as JSON of type typ.
+func jsonValue(s, typ string) ([]byte, bool) {
+	if typ != "string" {
+		s = strings.TrimSpace(s)
+	}
+	if s == "" {
+		return nil, false
+	}
+	switch typ {
+	case "int":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		return []byte(strconv.FormatInt(n, 10)), true
+	case "float64":
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, false
+		}
+		return []byte(strconv.FormatFloat(x, 'g', -1, 64)), true
+	case "bool":
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, false
+		}
+		return []byte(strconv.FormatBool(v)), true
+	}
+	b, _ := json.Marshal(s)
+	return b, true
+}
+
+// frameSchema declares a struct type called name, with a
+// field for each column of the Frame, gofmt'ed.
+func frameSchema(frame, name string) (string, string) {
+	var f frameData
+	if err := json.Unmarshal([]byte(frame), &f); err != nil {
+		return "", "frame: " + err.Error()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	seen := map[string]bool{}
+	for j, col := range f.Columns {
+		field := columnFieldName(col, j)
+		for k := 2; seen[field]; k++ {
+			field = fmt.Sprintf("%s%d", columnFieldName(col, j), k)
+		}
+		seen[field] = true
+		tag := "`json:" + strconv.Quote(col) + "`"
+		if strings.Contains(col, "`") {
+			tag = strconv.Quote("json:" + strconv.Quote(col))
+		}
+		fmt.Fprintf(&b, "\t%s %s %s\n", field, f.Types[j], tag)
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return "", "frame: " + err.Error()
+	}
+	return string(src), ""
+}
+
+// columnFieldName makes an exported Go name of the name of
+// column j: "first name" becomes FirstName.
+func columnFieldName(col string, j int) string {
+	var b strings.Builder
+	up := true
+	for _, r := range col {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			up = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('F')
+		}
+		if up {
+			r = unicode.ToUpper(r)
+			up = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return fmt.Sprintf("Col%d", j+1)
+	}
+	return b.String()
+}
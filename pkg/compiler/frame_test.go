@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1372FrameReadsCSVAndJSON(t *testing.T) {
+
+	cv.Convey("gi/frame should read CSV and JSON into a Frame of inferred column types, declare a struct for it, fill a []struct from it, and display it as a table", t, func() {
+		dir, err := ioutil.TempDir("", "gi-frame")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		csvPath := filepath.Join(dir, "people.csv")
+		jsonPath := filepath.Join(dir, "people.json")
+		panicOn(ioutil.WriteFile(csvPath, []byte("first name,age,score,ok\nann,41,1.5,true\nbob,7,,false\n"), 0644))
+		panicOn(ioutil.WriteFile(jsonPath, []byte(`[{"name":"ann","age":41,"tags":["a"]},{"name":"bob","age":7.5,"x":null}]`), 0644))
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(it.SetRichDisplay(true))
+
+		panicOn(it.Eval(`import "gi/frame"
+f, err := frame.ReadCSV("` + csvPath + `")
+ok := err == nil
+n := f.Len()
+schema := f.Schema("Person")
+scores := f.Floats("score")
+names := f.Column("first name")`))
+		LuaMustBool(it.lvm, "ok", true)
+		LuaMustInt64(it.lvm, "n", 2)
+		cv.So(luaGlobalString(it.lvm, "schema"), cv.ShouldEqual, "type Person struct {\n"+
+			"\tFirstName string  `json:\"first name\"`\n"+
+			"\tAge       int     `json:\"age\"`\n"+
+			"\tScore     float64 `json:\"score\"`\n"+
+			"\tOk        bool    `json:\"ok\"`\n"+
+			"}\n")
+
+		panicOn(it.Eval(`import "math"
+nan := math.IsNaN(scores[1])
+first := names[0] + " " + names[1]`))
+		LuaMustBool(it.lvm, "nan", true)
+		LuaMustString(it.lvm, "first", "ann bob")
+
+		panicOn(it.Eval(`type Person struct {
+	FirstName string  ` + "`json:\"first name\"`" + `
+	Age       int
+	Score     float64
+	Ok        bool
+}
+var ps []Person
+err = f.Into(&ps)
+ok = err == nil && len(ps) == 2 && ps[0].FirstName == "ann" && ps[0].Age == 41 && ps[0].Score == 1.5 && ps[0].Ok &&
+	ps[1].FirstName == "bob" && ps[1].Age == 7 && ps[1].Score == 0 && !ps[1].Ok`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		panicOn(it.Eval(`f`))
+		panicOn(it.Eval(`frame.Of(ps[1:])`))
+		ds, err := it.Displayed()
+		panicOn(err)
+		cv.So(len(ds), cv.ShouldEqual, 2)
+		cv.So(ds[0].Text(), cv.ShouldEqual, "first name  age  score  ok\nann         41   1.5    true\nbob         7           false")
+		cv.So(ds[0]["text/html"], cv.ShouldStartWith, "<table>\n<tr><th>first name</th>")
+		cv.So(ds[1].Text(), cv.ShouldEqual, "first name  Age  Score  Ok\nbob         7    0      false")
+
+		// the rows beyond :set maxwidth are counted.
+		panicOn(it.SetShowLimits(8, 1))
+		panicOn(it.Eval(`f`))
+		ds, err = it.Displayed()
+		panicOn(err)
+		cv.So(ds[0].Text(), cv.ShouldEqual, "first name  age  score  ok\nann         41   1.5    true\n... (1 more rows)")
+
+		panicOn(it.Eval(`g, err := frame.ReadJSON("` + jsonPath + `")
+ok = err == nil
+cols := len(g.Columns)
+types := g.Types[0] + " " + g.Types[1] + " " + g.Types[2] + " " + g.Types[3]
+tags := g.Rows[0][2]`))
+		LuaMustBool(it.lvm, "ok", true)
+		LuaMustInt(it.lvm, "cols", 4)
+		LuaMustString(it.lvm, "types", "string float64 string string")
+		LuaMustString(it.lvm, "tags", `["a"]`)
+
+		panicOn(it.Eval(`_, err = frame.ReadCSV("` + filepath.Join(dir, "nope.csv") + `")
+msg := err.Error()`))
+		LuaMustString(it.lvm, "msg", "frame: open "+filepath.Join(dir, "nope.csv")+": no such file or directory")
+
+		err = it.Eval(`frame.Of([]int{1})`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "frame: row 1 is not an object")
+	})
+}
@@ -205,7 +205,15 @@ func structTemplate(o *os.File, obj types.Object, nm, pkgName string, oty, under
 	*/
 	switch obj.(type) {
 	case *types.TypeName:
-		*atEnd = append(*atEnd, fmt.Sprintf(`
+		if holdsLock(oty, nil) {
+			// copying it would copy the lock, as go vet says.
+			*atEnd = append(*atEnd, fmt.Sprintf(`
+func GijitShadow_NewStruct_%[2]s(src *%[1]s.%[2]s) *%[1]s.%[2]s {
+    return new(%[1]s.%[2]s)
+}
+`, pkgName, nm))
+		} else {
+			*atEnd = append(*atEnd, fmt.Sprintf(`
 func GijitShadow_NewStruct_%[2]s(src *%[1]s.%[2]s) *%[1]s.%[2]s {
     if src == nil {
 	   return &%[1]s.%[2]s{}
@@ -214,6 +222,7 @@ func GijitShadow_NewStruct_%[2]s(src *%[1]s.%[2]s) *%[1]s.%[2]s {
     return &a
 }
 `, pkgName, nm))
+		}
 		ctor(o, nm, pkgName)
 	default:
 		direct(o, nm, pkgName)
@@ -221,6 +230,41 @@ func GijitShadow_NewStruct_%[2]s(src *%[1]s.%[2]s) *%[1]s.%[2]s {
 
 }
 
+// holdsLock reports whether a value of t holds a lock, a
+// value with Lock and Unlock methods, as a sync.Mutex does,
+// that must not be copied. seen stops a type that holds
+// itself, through an array, from being looked in forever.
+func holdsLock(t types.Type, seen map[types.Type]bool) bool {
+	if seen == nil {
+		seen = make(map[types.Type]bool)
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if _, ok := t.(*types.Named); ok {
+		p := types.NewPointer(t)
+		lock, _, _ := types.LookupFieldOrMethod(p, false, nil, "Lock")
+		unlock, _, _ := types.LookupFieldOrMethod(p, false, nil, "Unlock")
+		if _, ok := lock.(*types.Func); ok {
+			if _, ok := unlock.(*types.Func); ok {
+				return true
+			}
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if holdsLock(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return holdsLock(u.Elem(), seen)
+	}
+	return false
+}
+
 func ifaceTemplate(o *os.File, obj types.Object, nm, pkgName string, oty, under types.Type, atEnd *[]string) {
 
 	//pp("ifaceTemplate:: we see Named '%s'\n. oty:'%#v',\n under:'%#v',\n, obj='%#v', \n", nm, oty, under, obj)
@@ -254,6 +298,10 @@ __type__.%[1]s.%[2]s = {
  __call = function(t, src)
    return __ctor__%[1]s.%[2]s(src)
  end,
+ -- a composite literal, &%[1]s.%[2]s{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__%[1]s.%[2]s(nil)
+ end,
 };
 setmetatable(__type__.%[1]s.%[2]s, __type__.%[1]s.%[2]s);
 
@@ -0,0 +1,492 @@
+package compiler
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/gostd/build"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowTestingSrc declares the shadow testing package
+// for the type checker. The bodies are never run: the
+// implementation is in prelude/testing.lua.
+const shadowTestingSrc = `package testing
+
+type T struct{}
+
+func (t *T) Run(name string, f func(t *T)) bool { return false }
+func (t *T) Parallel()                          {}
+func (t *T) Name() string                       { return "" }
+func (t *T) Fail()                              {}
+func (t *T) FailNow()                           {}
+func (t *T) Failed() bool                       { return false }
+func (t *T) Error(args ...interface{})          {}
+func (t *T) Errorf(format string, args ...interface{}) {}
+func (t *T) Fatal(args ...interface{})          {}
+func (t *T) Fatalf(format string, args ...interface{}) {}
+func (t *T) Log(args ...interface{})            {}
+func (t *T) Logf(format string, args ...interface{})   {}
+func (t *T) Skip(args ...interface{})           {}
+func (t *T) Skipf(format string, args ...interface{})  {}
+func (t *T) SkipNow()                           {}
+func (t *T) Skipped() bool                      { return false }
+func (t *T) Helper()                            {}
+func (t *T) Cleanup(f func())                   {}
+
+type B struct {
+	N int
+}
+
+func (b *B) Run(name string, f func(b *B)) bool { return false }
+func (b *B) ResetTimer()                        {}
+func (b *B) StartTimer()                        {}
+func (b *B) StopTimer()                         {}
+func (b *B) ReportAllocs()                      {}
+func (b *B) SetBytes(n int64)                   {}
+func (b *B) Name() string                       { return "" }
+func (b *B) Fail()                              {}
+func (b *B) FailNow()                           {}
+func (b *B) Failed() bool                       { return false }
+func (b *B) Error(args ...interface{})          {}
+func (b *B) Errorf(format string, args ...interface{}) {}
+func (b *B) Fatal(args ...interface{})          {}
+func (b *B) Fatalf(format string, args ...interface{}) {}
+func (b *B) Log(args ...interface{})            {}
+func (b *B) Logf(format string, args ...interface{})   {}
+func (b *B) Skip(args ...interface{})           {}
+func (b *B) Skipf(format string, args ...interface{})  {}
+func (b *B) SkipNow()                           {}
+func (b *B) Skipped() bool                      { return false }
+func (b *B) Helper()                            {}
+func (b *B) Cleanup(f func())                   {}
+
+func Short() bool   { return false }
+func Verbose() bool { return false }
+`
+
+var shadowTesting struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowTestingPackage returns the type checker's view of
+// the shadow testing package. It holds only types, so one
+// copy is shared by every Interp.
+func shadowTestingPackage() *types.Package {
+	shadowTesting.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "testing.go", shadowTestingSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "testing", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowTesting.pkg = pkg
+	})
+	return shadowTesting.pkg
+}
+
+// TestOptions control a gi test run. They mirror
+// the go test flags of the same names.
+type TestOptions struct {
+	Verbose bool   // -v: report every test, and its log
+	Run     string // -run: regexp selecting top-level tests
+	Short   bool   // -short: testing.Short() returns true
+
+	Bench     string        // -bench: regexp selecting benchmarks; none run if empty
+	BenchTime time.Duration // -benchtime: run each benchmark this long; default 1s
+	BenchMem  bool          // -benchmem: report B/op and allocs/op for all benchmarks
+
+	Cover     bool   // -cover: report statement coverage of the non-test files
+	CoverHTML string // -coverhtml: also write the coverage as HTML to this file
+}
+
+// RunTests runs the named test functions, already
+// defined in the Interp, as go test would, writing the
+// report to w. Tests run in order; subtests that call
+// t.Parallel run concurrently on the goroutine scheduler.
+// If the tests pass, the named benchmarks run next.
+func (it *Interp) RunTests(w io.Writer, tests, benchmarks []string, opts TestOptions) (passed bool, err error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return false, fmt.Errorf("Interp is closed")
+	}
+
+	benchTime := opts.BenchTime
+	if benchTime <= 0 {
+		benchTime = time.Second
+	}
+	luaList := func(b *strings.Builder, names []string) {
+		b.WriteString("{")
+		for _, name := range names {
+			fmt.Fprintf(b, "{%q, %s}, ", name, name)
+		}
+		b.WriteString("}")
+	}
+	var b strings.Builder
+	b.WriteString("__gi_testPassed = tostring(__gi_runTests(")
+	luaList(&b, tests)
+	b.WriteString(", ")
+	luaList(&b, benchmarks)
+	fmt.Fprintf(&b, ", %v, %v, %v, %v)); __gi_testOutput = table.concat(__testingLines, \"\\n\")",
+		opts.Verbose, opts.Short, benchTime.Seconds(), opts.BenchMem)
+	if err := LuaRun(it.lvm, b.String(), true); err != nil {
+		return false, err
+	}
+	passed = luaGlobalString(it.lvm, "__gi_testPassed") == "true"
+	if out := luaGlobalString(it.lvm, "__gi_testOutput"); out != "" {
+		fmt.Fprintln(w, out)
+	}
+	if passed {
+		fmt.Fprintln(w, "PASS")
+	} else {
+		fmt.Fprintln(w, "FAIL")
+	}
+	return passed, nil
+}
+
+// testPackage is one directory's Go files, merged
+// for evaluation as a single input.
+type testPackage struct {
+	src     string
+	tests   []string // TestXxx functions, in file order
+	benches []string // BenchmarkXxx functions
+	skipped []string // files not loaded, and why
+
+	// origins map the lines of src back to the files,
+	// named by sources, for coverage.
+	origins []srcOrigin
+	sources map[string]string
+}
+
+// loadTestDir reads the .go files in dir that ctxt selects,
+// test files included, and merges them: the imports of
+// every file first, then the rest of the declarations, so
+// the files may refer to each other in any order. Files of
+// an external test package, like foo_test, cannot be loaded
+// into the same session, and are skipped.
+func loadTestDir(dir string, ctxt *build.Context) (*testPackage, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	tp := &testPackage{sources: make(map[string]string)}
+	type decl struct {
+		text   string
+		origin srcOrigin
+	}
+	var imports []string
+	var decls []decl
+	seenImport := make(map[string]bool)
+	pkgName := ""
+	for _, path := range paths {
+		// as go test would, by //go:build line and by
+		// _GOOS and _GOARCH name suffix.
+		match, err := ctxt.MatchFile(dir, filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		by, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		src := string(by)
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		name := file.Name.Name
+		if strings.HasSuffix(name, "_test") {
+			tp.skipped = append(tp.skipped, filepath.Base(path)+": external test package "+name+" is not supported")
+			continue
+		}
+		if pkgName == "" {
+			pkgName = name
+		} else if name != pkgName {
+			return nil, fmt.Errorf("found packages %s and %s in %s", pkgName, name, dir)
+		}
+		base := filepath.Base(path)
+		tp.sources[base] = src
+		text := func(n ast.Node) string {
+			return src[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset]
+		}
+		for _, spec := range file.Imports {
+			imp := text(spec)
+			if !seenImport[imp] {
+				seenImport[imp] = true
+				imports = append(imports, imp)
+			}
+		}
+		isTestFile := strings.HasSuffix(path, "_test.go")
+		for _, node := range file.Nodes {
+			if ds, ok := node.(*ast.DeclStmt); ok {
+				node = ds.Decl
+			}
+			if gd, ok := node.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			decls = append(decls, decl{
+				text: text(node),
+				origin: srcOrigin{
+					file:    base,
+					line:    fset.Position(node.Pos()).Line,
+					noCover: isTestFile,
+				},
+			})
+			fd, ok := node.(*ast.FuncDecl)
+			if !ok || !isTestFile {
+				continue
+			}
+			switch {
+			case isTestFunc(fd, "Test", "T"):
+				tp.tests = append(tp.tests, fd.Name.Name)
+			case isTestFunc(fd, "Benchmark", "B"):
+				tp.benches = append(tp.benches, fd.Name.Name)
+			}
+		}
+	}
+
+	var b strings.Builder
+	if len(imports) > 0 {
+		b.WriteString("import (\n")
+		for _, imp := range imports {
+			b.WriteString("\t" + imp + "\n")
+		}
+		b.WriteString(")\n")
+	}
+	line := 1 + strings.Count(b.String(), "\n")
+	for _, d := range decls {
+		d.origin.inLine = line
+		tp.origins = append(tp.origins, d.origin)
+		b.WriteString(d.text + "\n")
+		line += 1 + strings.Count(d.text, "\n")
+	}
+	tp.src = b.String()
+	return tp, nil
+}
+
+// isTestFunc reports whether fd is a test of the kind
+// given by prefix and param: with "Test" and "T", a
+// func TestXxx(t *testing.T), where Xxx does not
+// start with a lower case letter.
+func isTestFunc(fd *ast.FuncDecl, prefix, param string) bool {
+	if fd.Recv != nil || !strings.HasPrefix(fd.Name.Name, prefix) {
+		return false
+	}
+	if rest := fd.Name.Name[len(prefix):]; rest != "" {
+		if c := rest[0]; 'a' <= c && c <= 'z' {
+			return false
+		}
+	}
+	params := fd.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 || fd.Type.Results != nil {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == param
+}
+
+// matching returns the names that re matches, or
+// all of them if pattern is empty.
+func matching(names []string, pattern string) ([]string, error) {
+	if pattern == "" {
+		return names, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var m []string
+	for _, name := range names {
+		if re.MatchString(name) {
+			m = append(m, name)
+		}
+	}
+	return m, nil
+}
+
+// TestDir runs the tests of the package in dir, in a
+// fresh Interp started from cfg, and reports like go test.
+func TestDir(cfg *GIConfig, w io.Writer, dir string, opts TestOptions) (passed bool, err error) {
+	t0 := time.Now()
+	tp, err := loadTestDir(dir, cfg.buildContext())
+	if err != nil {
+		return false, err
+	}
+	for _, s := range tp.skipped {
+		fmt.Fprintf(w, "gi test: skipping %s\n", s)
+	}
+	tests, err := matching(tp.tests, opts.Run)
+	if err != nil {
+		return false, fmt.Errorf("invalid -run regexp: %v", err)
+	}
+	var benches []string
+	if opts.Bench != "" {
+		benches, err = matching(tp.benches, opts.Bench)
+		if err != nil {
+			return false, fmt.Errorf("invalid -bench regexp: %v", err)
+		}
+	}
+	if len(tp.tests) == 0 && len(tp.benches) == 0 {
+		fmt.Fprintf(w, "?   \t%s\t[no test files]\n", dir)
+		return true, nil
+	}
+
+	it, err := NewInterp(cfg)
+	if err != nil {
+		return false, err
+	}
+	defer it.Close()
+	if opts.Cover || opts.CoverHTML != "" {
+		it.inc.cover = newCoverage()
+		for name, src := range tp.sources {
+			if !strings.HasSuffix(name, "_test.go") {
+				it.inc.cover.addSource(name, src)
+			}
+		}
+		sort.Strings(it.inc.cover.files)
+		it.inc.cover.setOrigins(tp.origins)
+	}
+	if err := it.Eval(tp.src); err != nil {
+		fmt.Fprintf(w, "# %s\n%v\nFAIL\t%s [build failed]\n", dir, err, dir)
+		return false, nil
+	}
+	passed, err = it.RunTests(w, tests, benches, opts)
+	if err != nil {
+		return false, err
+	}
+	coverMsg := ""
+	if it.inc.cover != nil {
+		prof, err := it.Coverage()
+		if err != nil {
+			return false, err
+		}
+		coverMsg = fmt.Sprintf("\tcoverage: %.1f%% of statements", prof.Total.Percent())
+		fmt.Fprintf(w, "coverage: %.1f%% of statements\n", prof.Total.Percent())
+		for _, f := range prof.Files {
+			fmt.Fprintf(w, "\t%s\t%.1f%%\n", f.Name, f.Percent())
+		}
+		if opts.CoverHTML != "" {
+			if err := prof.WriteHTMLFile(opts.CoverHTML); err != nil {
+				return false, err
+			}
+		}
+	}
+	elapsed := strconv.FormatFloat(time.Since(t0).Seconds(), 'f', 3, 64)
+	if passed {
+		fmt.Fprintf(w, "ok  \t%s\t%ss%s\n", dir, elapsed, coverMsg)
+	} else {
+		fmt.Fprintf(w, "FAIL\t%s\t%ss%s\n", dir, elapsed, coverMsg)
+	}
+	return passed, nil
+}
+
+// ExpandTestPatterns turns gi test arguments into
+// directories. A pattern ending in /... matches the
+// directory and every directory below it holding .go
+// files, except vendor and testdata trees, and those
+// starting with . or _ as go test does. No patterns
+// means the current directory.
+func ExpandTestPatterns(patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	var dirs []string
+	for _, pat := range patterns {
+		if !strings.HasSuffix(pat, "...") {
+			dirs = append(dirs, pat)
+			continue
+		}
+		root := strings.TrimSuffix(strings.TrimSuffix(pat, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			base := info.Name()
+			if path != root && (base == "vendor" || base == "testdata" ||
+				strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			if goFiles, _ := filepath.Glob(filepath.Join(path, "*.go")); len(goFiles) > 0 {
+				if root == "." && path != "." {
+					path = "./" + path
+				}
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dirs, nil
+}
+
+// GiTestMain implements gi test. args are those after
+// "test": the flags -v, -run, -short, -bench, -benchtime,
+// -benchmem, -cover and -coverhtml, then package
+// directories or patterns like ./... . It returns the
+// exit code.
+func GiTestMain(cfg *GIConfig, args []string) int {
+	fs := flag.NewFlagSet("gi test", flag.ContinueOnError)
+	var opts TestOptions
+	fs.BoolVar(&opts.Verbose, "v", false, "verbose: report every test and its log")
+	fs.StringVar(&opts.Run, "run", "", "run only the top-level tests matching this regexp")
+	fs.BoolVar(&opts.Short, "short", false, "tell long running tests to shorten their run time")
+	fs.StringVar(&opts.Bench, "bench", "", "run the benchmarks matching this regexp; . for all")
+	fs.DurationVar(&opts.BenchTime, "benchtime", time.Second, "run each benchmark for about this long")
+	fs.BoolVar(&opts.BenchMem, "benchmem", false, "report B/op and allocs/op for every benchmark")
+	fs.BoolVar(&opts.Cover, "cover", false, "report the statement coverage of the package's non-test files")
+	fs.StringVar(&opts.CoverHTML, "coverhtml", "", "write the coverage report as HTML to this file; implies -cover")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	dirs, err := ExpandTestPatterns(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi test: %v\n", err)
+		return 1
+	}
+	code := 0
+	for _, dir := range dirs {
+		passed, err := TestDir(cfg, os.Stdout, dir, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "FAIL\t%s [setup failed]: %v\n", dir, err)
+			code = 1
+			continue
+		}
+		if !passed {
+			code = 1
+		}
+	}
+	return code
+}
@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1319GiTestRunsTestFunctions(t *testing.T) {
+
+	cv.Convey(`gi test loads a package with its _test.go files, finds the TestXxx functions, and runs them with go test's reporting, subtests, parallel subtests, Fatal, Skip and Cleanup`, t, func() {
+		var out bytes.Buffer
+		passed, err := TestDir(nil, &out, "testdata/gitest", TestOptions{Verbose: true, Short: true})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeFalse)
+		s := out.String()
+		pp("gi test output:\n%s", s)
+
+		for _, want := range []string{
+			"=== RUN   TestSum\n",
+			"--- PASS: TestSum (",
+			"    sum ok\n",
+			"--- FAIL: TestFails (",
+			"    first problem, 42\n    fatal 7\n",
+			"=== RUN   TestSubtests/small\n",
+			"    --- PASS: TestSubtests/small (",
+			"    --- FAIL: TestSubtests/bad (",
+			"        bad sub\n",
+			"=== PAUSE TestParallel/a\n",
+			"=== CONT  TestParallel/b\n",
+			"--- PASS: TestParallel (",
+			"--- SKIP: TestSkipped (",
+			"    short mode\n",
+			"--- FAIL: TestPanics (",
+			"\nFAIL\nFAIL\ttestdata/gitest\t",
+		} {
+			cv.So(s, cv.ShouldContainSubstring, want)
+		}
+		cv.So(s, cv.ShouldNotContainSubstring, "never reached")
+
+		// not verbose: only failures are reported, and -run selects.
+		out.Reset()
+		passed, err = TestDir(nil, &out, "testdata/gitest", TestOptions{Run: "Sum|Parallel"})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeTrue)
+		cv.So(out.String(), cv.ShouldStartWith, "PASS\nok  \ttestdata/gitest\t")
+
+		// the parallel subtests waited for their parent's body.
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		tp, err := loadTestDir("testdata/gitest", NewGIConfig().buildContext())
+		panicOn(err)
+		panicOn(it.Eval(tp.src))
+		_, err = it.RunTests(&out, []string{"TestParallel"}, nil, TestOptions{})
+		panicOn(err)
+		panicOn(it.Eval(`o := order[0] + "," + order[1] + "," + order[2] + "," + order[3]`))
+		LuaMustString(it.lvm, "o", "body,a,b,cleanup")
+
+		dirs, err := ExpandTestPatterns([]string{"testdata/..."})
+		panicOn(err)
+		cv.So(strings.Join(dirs, " "), cv.ShouldEqual, "testdata/gitest")
+	})
+}
+
+func Test1320GiTestBenchmarks(t *testing.T) {
+
+	cv.Convey(`gi test -bench runs BenchmarkXxx functions with b.N scaled to the bench time, reporting ns/op, and B/op and allocs/op when asked`, t, func() {
+		var out bytes.Buffer
+		passed, err := TestDir(nil, &out, "testdata/gitest", TestOptions{
+			Run:       "^$",
+			Bench:     ".",
+			BenchTime: 50 * time.Millisecond,
+		})
+		panicOn(err)
+		cv.So(passed, cv.ShouldBeTrue)
+		s := out.String()
+		pp("gi test -bench output:\n%s", s)
+
+		lines := strings.Split(s, "\n")
+		cv.So(len(lines), cv.ShouldBeGreaterThan, 4)
+		sum := strings.Fields(lines[0])
+		cv.So(sum[0], cv.ShouldEqual, "BenchmarkSum")
+		n, err := strconv.Atoi(sum[1])
+		panicOn(err)
+		cv.So(n, cv.ShouldBeGreaterThan, 1)
+		cv.So(sum[3], cv.ShouldEqual, "ns/op")
+		cv.So(len(sum), cv.ShouldEqual, 4)
+
+		alloc := strings.Fields(lines[1])
+		cv.So(alloc[0], cv.ShouldEqual, "BenchmarkAlloc")
+		cv.So(alloc[5], cv.ShouldEqual, "B/op")
+		bop, err := strconv.Atoi(alloc[4])
+		panicOn(err)
+		cv.So(bop, cv.ShouldBeGreaterThan, 100)
+		cv.So(alloc[7], cv.ShouldEqual, "allocs/op")
+
+		// only the sub-benchmark is measured.
+		cv.So(strings.Fields(lines[2])[0], cv.ShouldEqual, "BenchmarkSizes/n=1")
+		cv.So(lines[3], cv.ShouldEqual, "PASS")
+	})
+}
@@ -0,0 +1,159 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Goroutine describes a live goroutine of an Interp.
+type Goroutine struct {
+	ID int
+
+	// State is named as Go's runtime names it: running,
+	// runnable, chan receive, chan send, select, select
+	// (no cases), or sleep for a receive with a timeout.
+	State string
+
+	// Waiting is how long it has been blocked.
+	Waiting time.Duration
+
+	// Stack holds the Go frames, innermost first.
+	Stack []Frame
+
+	// CreatedBy is the go statement that started it,
+	// or nil for the goroutine running an input.
+	CreatedBy *Frame
+}
+
+// String formats g like a goroutine of a Go traceback.
+func (g *Goroutine) String() string {
+	var b strings.Builder
+	state := g.State
+	if g.Waiting >= time.Minute {
+		state += fmt.Sprintf(", %d minutes", int(g.Waiting/time.Minute))
+	}
+	fmt.Fprintf(&b, "goroutine %d [%s]:\n", g.ID, state)
+	writeStack(&b, g.Stack)
+	if g.CreatedBy != nil {
+		fmt.Fprintf(&b, "created by %s\n\t%s:%d\n", g.CreatedBy.Func, g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	return b.String()
+}
+
+// goroutineMaxDepth is the deepest stack shown.
+const goroutineMaxDepth = 64
+
+// Goroutines lists the goroutines that have not finished,
+// by id: those started by go statements, and those of
+// inputs still blocked.
+func (it *Interp) Goroutines() ([]Goroutine, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.goroutines()
+}
+
+func (it *Interp) goroutines() ([]Goroutine, error) {
+	if err := LuaRun(it.lvm, fmt.Sprintf("__gi_goroutinesOut = __gi_goroutines(%d)", goroutineMaxDepth), false); err != nil {
+		return nil, err
+	}
+	out := luaGlobalString(it.lvm, "__gi_goroutinesOut")
+	panicOn(LuaRun(it.lvm, `__gi_goroutinesOut = nil`, false))
+	return parseGoroutines(it.inc.srcMap, out), nil
+}
+
+// parseGoroutines reads the lines of __gi_goroutines,
+// resolving their stacks with sm, and sorts them by id.
+func parseGoroutines(sm *luaSourceMap, out string) []Goroutine {
+	var gs []Goroutine
+	for _, ln := range strings.Split(out, "\n") {
+		fld := strings.Split(ln, "\t")
+		if len(fld) != 5 {
+			continue
+		}
+		id, err := strconv.Atoi(fld[0])
+		if err != nil {
+			continue
+		}
+		ns, _ := strconv.ParseInt(fld[2], 10, 64)
+		g := Goroutine{ID: id, State: fld[1], Waiting: time.Duration(ns)}
+		if fld[3] != "" {
+			if f, isGo := sm.frame(fld[3]); isGo {
+				g.CreatedBy = &f
+			}
+		}
+		g.Stack = sm.goStack(fld[4])
+		gs = append(gs, g)
+	}
+	sort.Slice(gs, func(i, j int) bool { return gs[i].ID < gs[j].ID })
+	return gs
+}
+
+// KillGoroutine stops goroutine id for good: it is never
+// resumed, and its deferred calls do not run. Go has no
+// such thing; it is for goroutines stuck at the prompt.
+func (it *Interp) KillGoroutine(id int) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.killGoroutine(id)
+}
+
+func (it *Interp) killGoroutine(id int) error {
+	if err := LuaRun(it.lvm, fmt.Sprintf("__gi_killOut = __gi_killGoroutine(%d)", id), false); err != nil {
+		return err
+	}
+	msg := luaGlobalString(it.lvm, "__gi_killOut")
+	panicOn(LuaRun(it.lvm, `__gi_killOut = nil`, false))
+	if msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// ErrDeadlock is returned for an input whose goroutine
+// blocked with every other goroutine asleep as well, so that
+// nothing could ever wake it. Go would crash. gijit instead
+// drops the input's goroutine, and keeps the others, which
+// later inputs may yet wake up.
+type ErrDeadlock struct {
+	// Goroutines are as they were when the deadlock was found.
+	Goroutines []Goroutine
+}
+
+func (e *ErrDeadlock) Error() string {
+	var b strings.Builder
+	b.WriteString("fatal error: all goroutines are asleep - deadlock!\n")
+	for i := range e.Goroutines {
+		b.WriteString("\n")
+		b.WriteString(e.Goroutines[i].String())
+	}
+	return b.String()
+}
+
+// checkDeadlock returns an *ErrDeadlock if the last __eval
+// left its goroutine asleep for good; see __gi_asleep.
+func (it *Interp) checkDeadlock() error {
+	id, err := strconv.Atoi(luaGlobalString(it.lvm, "__gi_deadlockGoid"))
+	if err != nil {
+		return nil
+	}
+	panicOn(LuaRun(it.lvm, `__gi_deadlockGoid = ""`, false))
+	gs, err := it.goroutines()
+	if err != nil {
+		return err
+	}
+	if err := it.killGoroutine(id); err != nil {
+		return err
+	}
+	// like Go, show the stuck goroutine first.
+	for i := range gs {
+		if gs[i].ID == id {
+			gs[0], gs[i] = gs[i], gs[0]
+			sort.Slice(gs[1:], func(i, j int) bool { return gs[1+i].ID < gs[1+j].ID })
+			break
+		}
+	}
+	return &ErrDeadlock{Goroutines: gs}
+}
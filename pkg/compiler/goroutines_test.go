@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1323GoroutinesListsStateAndGoStack(t *testing.T) {
+
+	cv.Convey(`:goroutines lists the live goroutines with their state, their stack in Go terms, and where they were started; one can be killed`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`ch := make(chan int)
+ch2 := make(chan int)
+func worker(c chan int) {
+	v := <-c
+	_ = v
+}
+func sender(c chan int) {
+	c <- 1
+}`))
+		panicOn(it.Eval(`go worker(ch)`))
+		panicOn(it.Eval(`go sender(ch2)`))
+		panicOn(it.Eval(`go func() {
+	select {}
+}()`))
+
+		gs, err := it.Goroutines()
+		panicOn(err)
+		cv.So(len(gs), cv.ShouldEqual, 3)
+
+		w := gs[0]
+		cv.So(w.State, cv.ShouldEqual, "chan receive")
+		cv.So(w.Stack, cv.ShouldResemble, []Frame{{Func: "main.worker", File: "repl[1]", Line: 4}})
+		cv.So(*w.CreatedBy, cv.ShouldResemble, Frame{Func: "main.repl[2]", File: "repl[2]", Line: 1})
+		cv.So(w.String(), cv.ShouldStartWith, "goroutine ")
+		cv.So(w.String(), cv.ShouldEndWith, " [chan receive]:\nmain.worker()\n\trepl[1]:4\ncreated by main.repl[2]\n\trepl[2]:1\n")
+
+		cv.So(gs[1].State, cv.ShouldEqual, "chan send")
+		cv.So(gs[1].Stack[0].Func, cv.ShouldEqual, "main.sender")
+		cv.So(gs[1].Stack[0].Line, cv.ShouldEqual, 8)
+		cv.So(gs[2].State, cv.ShouldEqual, "select (no cases)")
+		cv.So(gs[2].Stack[0].Func, cv.ShouldEqual, "main.repl[4].func1")
+
+		panicOn(it.KillGoroutine(gs[1].ID))
+		cv.So(it.KillGoroutine(gs[1].ID), cv.ShouldNotBeNil)
+		gs2, err := it.Goroutines()
+		panicOn(err)
+		cv.So(len(gs2), cv.ShouldEqual, 2)
+		cv.So(gs2[1].ID, cv.ShouldEqual, gs[2].ID)
+
+		// the worker still runs to completion when sent to.
+		panicOn(it.Eval(`ch <- 7`))
+		gs3, err := it.Goroutines()
+		panicOn(err)
+		cv.So(len(gs3), cv.ShouldEqual, 1)
+	})
+}
+
+func Test1324DeadlockIsReportedNotHung(t *testing.T) {
+
+	cv.Convey(`an input that blocks with every goroutine asleep gets Go's deadlock error, with the stacks, and the session carries on`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`ch := make(chan int)
+func worker(c chan int) {
+	c <- 1
+}
+go worker(ch)`))
+
+		err = it.Eval(`ch2 := make(chan int)
+a := 1
+b := <-ch2`)
+		cv.So(err, cv.ShouldNotBeNil)
+		dl, ok := err.(*ErrDeadlock)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(len(dl.Goroutines), cv.ShouldEqual, 2)
+		cv.So(dl.Goroutines[0].State, cv.ShouldEqual, "chan receive")
+		cv.So(dl.Goroutines[0].Stack[0], cv.ShouldResemble, Frame{Func: "main.repl[2]", File: "repl[2]", Line: 3})
+		cv.So(dl.Goroutines[1].Stack[0].Func, cv.ShouldEqual, "main.worker")
+		cv.So(err.Error(), cv.ShouldStartWith, "fatal error: all goroutines are asleep - deadlock!\n\ngoroutine ")
+		cv.So(err.Error(), cv.ShouldContainSubstring, " [chan receive]:\nmain.repl[2]()\n\trepl[2]:3\n")
+
+		// the statements before the block did run; the
+		// stuck goroutine is gone, the worker is not.
+		LuaMustInt64(it.lvm, "a", 1)
+		gs, err := it.Goroutines()
+		panicOn(err)
+		cv.So(len(gs), cv.ShouldEqual, 1)
+
+		// a receive some goroutine can satisfy is no deadlock.
+		panicOn(it.Eval(`c := <-ch`))
+		LuaMustInt64(it.lvm, "c", 1)
+
+		_, isDeadlock := it.Eval(`select {}`).(*ErrDeadlock)
+		cv.So(isDeadlock, cv.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowGRPCSrc declares the gi/grpc package, a gRPC client
+// for the REPL, to the type checker. The messages are the
+// struct types of .proto generated Go code, which may be
+// pasted in as they are: their fields' protobuf tags say how
+// to encode them. The bodies are never run; prelude/zgrpc.lua
+// has the protobuf codec, and grpc_transport.go the HTTP/2
+// calls.
+//
+//	conn, err := grpc.Dial("localhost:50051")
+//	resp := &HelloReply{}
+//	err = conn.Invoke("/helloworld.Greeter/SayHello", &HelloRequest{Name: "gi"}, resp)
+const shadowGRPCSrc = `package grpc
+
+// Conn is a connection to a gRPC server.
+type Conn struct{}
+
+// Dial connects to the server at target, as host:port for
+// plain text HTTP/2, or as an https:// URL for TLS.
+func Dial(target string) (*Conn, error) { return nil, nil }
+
+// Invoke makes the unary call method, of the form
+// "/package.Service/Method", sending req and decoding the
+// reply into resp. Both are pointers to message structs.
+// A failed call returns a *Status.
+func (c *Conn) Invoke(method string, req, resp interface{}) error { return nil }
+
+// SetTimeout bounds each call, in milliseconds; 0, the
+// default, waits as long as the server takes.
+func (c *Conn) SetTimeout(ms int) {}
+
+// Close closes the connection.
+func (c *Conn) Close() error { return nil }
+
+// Status is the error a failed call returns.
+type Status struct {
+	Code    int
+	Message string
+}
+
+func (s *Status) Error() string { return "" }
+
+// Marshal encodes msg, a pointer to a message struct, in
+// the protobuf wire format.
+func Marshal(msg interface{}) ([]byte, error) { return nil, nil }
+
+// Unmarshal decodes b into msg, a pointer to a message
+// struct, replacing the fields it had.
+func Unmarshal(b []byte, msg interface{}) error { return nil }
+`
+
+var shadowGRPC struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowGRPCPackage returns the type checker's view of
+// gi/grpc, shared by every Interp.
+func shadowGRPCPackage() *types.Package {
+	shadowGRPC.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "grpc.go", shadowGRPCSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "gi/grpc", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowGRPC.pkg = pkg
+	})
+	return shadowGRPC.pkg
+}
+
+// the gRPC status codes the client itself gives.
+const (
+	grpcDeadlineExceeded = 4
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+)
@@ -0,0 +1,114 @@
+//go:build go1.24
+// +build go1.24
+
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// startGRPCEcho serves, over plain text HTTP/2, an Echo
+// method that replies with the request message, and a
+// Missing method that fails with NOT_FOUND.
+func startGRPCEcho() (addr string, stop func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test.Echo/Echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+	})
+	mux.HandleFunc("/test.Echo/Missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "no%20such%20thing")
+		w.WriteHeader(http.StatusOK)
+	})
+	var protos http.Protocols
+	protos.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: mux, Protocols: &protos}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	panicOn(err)
+	go srv.Serve(ln)
+	return ln.Addr().String(), func() { srv.Close() }
+}
+
+const grpcTestMessages = "type Inner struct {\n" +
+	"	Ok bool `protobuf:\"varint,1,opt,name=ok,proto3\" json:\"ok,omitempty\"`\n" +
+	"}\n" +
+	"type Msg struct {\n" +
+	"	Name  string           `protobuf:\"bytes,1,opt,name=name,proto3\" json:\"name,omitempty\"`\n" +
+	"	Times int32            `protobuf:\"varint,2,opt,name=times,proto3\" json:\"times,omitempty\"`\n" +
+	"	Ids   []int64          `protobuf:\"varint,3,rep,packed,name=ids,proto3\" json:\"ids,omitempty\"`\n" +
+	"	Inner *Inner           `protobuf:\"bytes,4,opt,name=inner,proto3\" json:\"inner,omitempty\"`\n" +
+	"	Tags  []string         `protobuf:\"bytes,5,rep,name=tags,proto3\" json:\"tags,omitempty\"`\n" +
+	"	Delta int32            `protobuf:\"zigzag32,6,opt,name=delta,proto3\" json:\"delta,omitempty\"`\n" +
+	"	Ratio float64          `protobuf:\"fixed64,7,opt,name=ratio,proto3\" json:\"ratio,omitempty\"`\n" +
+	"	Raw   []byte           `protobuf:\"bytes,8,opt,name=raw,proto3\" json:\"raw,omitempty\"`\n" +
+	"	Count map[string]int32 `protobuf:\"bytes,9,rep,name=count,proto3\" json:\"count,omitempty\" protobuf_key:\"bytes,1,opt,name=key,proto3\" protobuf_val:\"varint,2,opt,name=value,proto3\"`\n" +
+	"	XXX_unrecognized []byte `json:\"-\"`\n" +
+	"}\n"
+
+func Test1341GRPCUnaryCalls(t *testing.T) {
+
+	cv.Convey("gi/grpc encodes messages by their protobuf tags, in the protobuf wire format", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "gi/grpc"`))
+		panicOn(it.Eval(grpcTestMessages))
+
+		panicOn(it.Eval(`b, err := grpc.Marshal(&Msg{Name: "gi", Times: 150, Ids: []int64{1, 300}, Inner: &Inner{Ok: true}, Delta: -2}); s := string(b); ok := err == nil`))
+		LuaMustBool(it.lvm, "ok", true)
+		// name, times, packed ids, inner, zigzag delta.
+		cv.So(fmt.Sprintf("%x", luaGlobalString(it.lvm, "s")), cv.ShouldEqual, "0a026769"+"109601"+"1a0301ac02"+"22020801"+"3003")
+
+		panicOn(it.Eval(`var m Msg; err = grpc.Unmarshal(b, &m); ok = err == nil && m.Name == "gi" && m.Times == 150 && len(m.Ids) == 2 && m.Ids[1] == 300 && m.Inner.Ok && m.Delta == -2`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		err = it.Eval(`err = grpc.Unmarshal([]byte{0x0a, 5, 'x'}, &m); es := err.Error()`)
+		panicOn(err)
+		LuaMustString(it.lvm, "es", "rpc error: code = Internal desc = grpc: the message is cut short")
+	})
+
+	cv.Convey("gi/grpc makes unary calls over HTTP/2, and a failed call returns a *grpc.Status", t, func() {
+		addr, stop := startGRPCEcho()
+		defer stop()
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "gi/grpc"`))
+		panicOn(it.Eval(grpcTestMessages))
+		panicOn(it.Eval(fmt.Sprintf(`conn, err := grpc.Dial(%q)`, addr)))
+		panicOn(it.Eval(`req := &Msg{Name: "hi", Tags: []string{"a", "b"}, Ratio: 0.5, Raw: []byte("raw"), Count: map[string]int32{"x": 7}}; resp := &Msg{Times: 9}; err = conn.Invoke("/test.Echo/Echo", req, resp)`))
+		panicOn(it.Eval(`ok := err == nil && resp.Name == "hi" && resp.Times == 0 && len(resp.Tags) == 2 && resp.Tags[1] == "b" && resp.Ratio == 0.5 && string(resp.Raw) == "raw" && resp.Count["x"] == 7`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		panicOn(it.Eval(`err = conn.Invoke("/test.Echo/Missing", req, resp); st := err.(*grpc.Status); code := st.Code; es := err.Error()`))
+		LuaMustInt64(it.lvm, "code", 5)
+		LuaMustString(it.lvm, "es", "rpc error: code = NotFound desc = no such thing")
+		panicOn(it.Eval(`conn.Close()`))
+	})
+
+	cv.Convey("gi/grpc needs the net capability", t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapNone)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "gi/grpc"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "denied by sandbox policy")
+	})
+}
@@ -0,0 +1,154 @@
+//go:build go1.24
+// +build go1.24
+
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// grpcConn is a gi/grpc connection: an HTTP/2 client for
+// one server.
+type grpcConn struct {
+	base   string // scheme and host, e.g. http://localhost:50051
+	client *http.Client
+}
+
+var grpcConns = struct {
+	mu   sync.Mutex
+	next int
+	m    map[int]*grpcConn
+}{m: make(map[int]*grpcConn)}
+
+// grpcDial makes a connection to target, and returns its id.
+// Nothing is sent until the first call.
+func grpcDial(target string) (id int, errmsg string) {
+	base := target
+	var protos http.Protocols
+	if strings.HasPrefix(target, "https://") {
+		protos.SetHTTP2(true)
+	} else {
+		base = "http://" + strings.TrimPrefix(target, "http://")
+		protos.SetUnencryptedHTTP2(true)
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return 0, fmt.Sprintf("grpc: bad target '%s'", target)
+	}
+	tr := &http.Transport{
+		Protocols:       &protos,
+		TLSClientConfig: &tls.Config{},
+	}
+	c := &grpcConn{
+		base:   u.Scheme + "://" + u.Host,
+		client: &http.Client{Transport: tr},
+	}
+	grpcConns.mu.Lock()
+	grpcConns.next++
+	id = grpcConns.next
+	grpcConns.m[id] = c
+	grpcConns.mu.Unlock()
+	return id, ""
+}
+
+// grpcInvoke makes the unary call method on connection id
+// with the encoded request req. It returns the encoded
+// reply, or the call's gRPC status code and message.
+func grpcInvoke(id int, method, req string, timeoutMs int) (resp string, code int, msg string) {
+	grpcConns.mu.Lock()
+	c := grpcConns.m[id]
+	grpcConns.mu.Unlock()
+	if c == nil {
+		return "", grpcUnavailable, "grpc: the connection is closed"
+	}
+
+	// a message is framed by a compressed flag, which
+	// is 0, and its length.
+	frame := make([]byte, 5, 5+len(req))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(req)))
+	frame = append(frame, req...)
+
+	ctx := context.Background()
+	if timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	hreq, err := http.NewRequestWithContext(ctx, "POST", c.base+method, bytes.NewReader(frame))
+	if err != nil {
+		return "", grpcInternal, err.Error()
+	}
+	hreq.Header.Set("Content-Type", "application/grpc")
+	hreq.Header.Set("TE", "trailers")
+	if timeoutMs > 0 {
+		hreq.Header.Set("Grpc-Timeout", strconv.Itoa(timeoutMs)+"m")
+	}
+	hresp, err := c.client.Do(hreq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", grpcDeadlineExceeded, err.Error()
+		}
+		return "", grpcUnavailable, err.Error()
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		return "", grpcUnavailable, fmt.Sprintf("grpc: HTTP status %s", hresp.Status)
+	}
+	body, err := ioutil.ReadAll(hresp.Body)
+	if err != nil && err != io.EOF {
+		return "", grpcUnavailable, err.Error()
+	}
+
+	// the status is in the trailers, or in the headers
+	// of a reply with no body.
+	st := hresp.Trailer.Get("Grpc-Status")
+	sm := hresp.Trailer.Get("Grpc-Message")
+	if st == "" {
+		st = hresp.Header.Get("Grpc-Status")
+		sm = hresp.Header.Get("Grpc-Message")
+	}
+	if st == "" {
+		return "", grpcInternal, "grpc: the reply has no grpc-status"
+	}
+	code, err = strconv.Atoi(st)
+	if err != nil {
+		return "", grpcInternal, fmt.Sprintf("grpc: bad grpc-status '%s'", st)
+	}
+	if code != 0 {
+		sm, _ = url.PathUnescape(sm)
+		return "", code, sm
+	}
+	if len(body) < 5 {
+		return "", grpcInternal, "grpc: the reply has no message"
+	}
+	if body[0] != 0 {
+		return "", grpcUnimplemented, "grpc: compressed replies are not supported"
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	if int(n) > len(body)-5 {
+		return "", grpcInternal, "grpc: the reply message is cut short"
+	}
+	return string(body[5 : 5+n]), 0, ""
+}
+
+func grpcClose(id int) {
+	grpcConns.mu.Lock()
+	c := grpcConns.m[id]
+	delete(grpcConns.m, id)
+	grpcConns.mu.Unlock()
+	if c != nil {
+		c.client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !go1.24
+// +build !go1.24
+
+package compiler
+
+// gi/grpc needs the plain text HTTP/2 client of Go 1.24.
+
+func grpcDial(target string) (id int, errmsg string) {
+	return 0, "grpc: gijit must be built with Go 1.24 or later for gi/grpc"
+}
+
+func grpcInvoke(id int, method, req string, timeoutMs int) (resp string, code int, msg string) {
+	return "", grpcUnimplemented, "grpc: gijit must be built with Go 1.24 or later for gi/grpc"
+}
+
+func grpcClose(id int) {}
@@ -0,0 +1,64 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1350HashAndEncoding(t *testing.T) {
+
+	cv.Convey("sha256 and md5 digests, whole and streamed, match Go's", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+sum := sha256.Sum256([]byte("abc"))
+whole := hex.EncodeToString(sum[:])
+first := sum[0]
+m := md5.Sum([]byte("abc"))
+md := hex.EncodeToString(m[:])
+h := sha256.New()
+h.Write([]byte("ab"))
+io.WriteString(h, "c")
+streamed := hex.EncodeToString(h.Sum(nil))
+size := h.Size()`))
+		LuaMustString(it.lvm, "whole", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+		LuaMustString(it.lvm, "streamed", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+		LuaMustString(it.lvm, "md", "900150983cd24fb0d6963f7d28e17f72")
+		LuaMustInt64(it.lvm, "first", 0xba)
+		LuaMustInt64(it.lvm, "size", 32)
+	})
+
+	cv.Convey("hex and base64 encode and decode", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"encoding/base64"
+	"encoding/hex"
+)
+enc := base64.StdEncoding.EncodeToString([]byte("hello, gi"))
+dec, err := base64.StdEncoding.DecodeString(enc)
+back := string(dec)
+url := base64.URLEncoding.EncodeToString([]byte{0xfb, 0xff})
+raw, err2 := hex.DecodeString("6869")
+hi := string(raw)
+_, bad := hex.DecodeString("zz")
+failed := bad != nil`))
+		LuaMustString(it.lvm, "enc", "aGVsbG8sIGdp")
+		LuaMustString(it.lvm, "back", "hello, gi")
+		LuaMustString(it.lvm, "url", "-_8=")
+		LuaMustString(it.lvm, "hi", "hi")
+		LuaMustBeNil(it.lvm, "err")
+		LuaMustBeNil(it.lvm, "err2")
+		LuaMustBool(it.lvm, "failed", true)
+	})
+}
@@ -0,0 +1,640 @@
+package compiler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/constant"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// imageVersion is bumped whenever the
+// session image format changes.
+const imageVersion = 1
+
+// sessionImage is what SaveImage writes. Rather than
+// dumping the LuaJIT heap, which holds closures and
+// cdata that cannot be written out, an image keeps
+// the session's declarations as Go source, and the
+// values of its variables as data. Restoring type
+// checks the declarations and assigns the values
+// back, without re-running the code that computed them.
+type sessionImage struct {
+	Version int
+	Imports []string
+	Decls   string
+	Vars    []imageVar
+
+	// Skipped lists the variables whose values
+	// could not be saved, e.g. funcs and channels.
+	// They come back as zero values.
+	Skipped []string
+}
+
+type imageVar struct {
+	Name  string
+	Type  string
+	Value json.RawMessage
+}
+
+// recordSource keeps src, which has been translated
+// and run, so SaveImage can find the source of
+// function and type declarations later.
+// The caller must hold it.mut.
+func (it *Interp) recordSource(src string) {
+	it.srcLog = append(it.srcLog, src)
+}
+
+// SaveImageFile is SaveImage to the file at path.
+func (it *Interp) SaveImageFile(path string) (skipped []string, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	skipped, err = it.SaveImage(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return
+}
+
+// LoadImageFile is LoadImage from the file at path.
+func (it *Interp) LoadImageFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return it.LoadImage(f)
+}
+
+// SaveImage writes the current session, its imports, types,
+// constants, functions, methods and variables, to w. The
+// variables whose values cannot be saved are returned in skipped,
+// with the reason; they are restored with their zero value.
+// Values are copied, so pointers shared between variables
+// come back as separate copies.
+func (it *Interp) SaveImage(w io.Writer) (skipped []string, err error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+
+	img := &sessionImage{Version: imageVersion}
+	scope := it.inc.pkgScope()
+	pkg := it.inc.CurPkg.Arch.Pkg
+	qual := imageQualifier(pkg)
+	src := newDeclSource(it.srcLog)
+
+	for path := range it.inc.CurPkg.importContext.Packages {
+		if path != it.inc.CurPkg.pack.ImportPath {
+			img.Imports = append(img.Imports, path)
+		}
+	}
+	sort.Strings(img.Imports)
+
+	var types_, consts, funcs, vars []string
+	for _, name := range scope.Names() {
+		if it.baseNames[name] || strings.HasPrefix(name, "__") || name == "_" {
+			continue
+		}
+		switch obj := scope.Lookup(name).(type) {
+		case *types.TypeName:
+			decl, ok := src.typeDecl(name)
+			if !ok {
+				decl = "type " + name + " " + types.TypeString(obj.Type().Underlying(), qual)
+			}
+			types_ = append(types_, decl)
+			if named, isNamed := obj.Type().(*types.Named); isNamed {
+				for i := 0; i < named.NumMethods(); i++ {
+					m := named.Method(i).Name()
+					decl, ok := src.methodDecl(name, m)
+					if !ok {
+						return nil, fmt.Errorf("cannot find the source of method %s.%s", name, m)
+					}
+					funcs = append(funcs, decl)
+				}
+			}
+		case *types.Const:
+			consts = append(consts, constDecl(obj, qual))
+		case *types.Func:
+			decl, ok := src.funcDecl(name)
+			if !ok {
+				return nil, fmt.Errorf("cannot find the source of func %s", name)
+			}
+			funcs = append(funcs, decl)
+		case *types.Var:
+			typ := types.TypeString(obj.Type(), qual)
+			js, err := it.encodeLuaGlobal(name)
+			if err != nil {
+				return nil, err
+			}
+			if why := unsupportedInImage(js); why != "" {
+				// declared here, at its zero value; the
+				// others are declared with their values.
+				vars = append(vars, "var "+name+" "+typ)
+				img.Skipped = append(img.Skipped, name+": "+why)
+				continue
+			}
+			img.Vars = append(img.Vars, imageVar{Name: name, Type: typ, Value: json.RawMessage(js)})
+		}
+	}
+
+	var b strings.Builder
+	for _, path := range img.Imports {
+		fmt.Fprintf(&b, "import %q\n", path)
+	}
+	for _, part := range [][]string{types_, consts, funcs, vars} {
+		for _, decl := range part {
+			b.WriteString(decl)
+			b.WriteString("\n")
+		}
+	}
+	img.Decls = b.String()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return img.Skipped, enc.Encode(img)
+}
+
+// LoadImage restores a session saved by SaveImage into it,
+// normally a fresh Interp. Names already defined in it are
+// replaced by those from the image.
+func (it *Interp) LoadImage(r io.Reader) error {
+	var img sessionImage
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&img); err != nil {
+		return fmt.Errorf("reading session image: %v", err)
+	}
+	if img.Version != imageVersion {
+		return fmt.Errorf("session image version %d, but this gijit reads version %d", img.Version, imageVersion)
+	}
+
+	if strings.TrimSpace(img.Decls) != "" {
+		if err := it.Eval(img.Decls); err != nil {
+			return fmt.Errorf("restoring declarations: %v", err)
+		}
+	}
+
+	it.mut.Lock()
+	pkg := it.inc.CurPkg.Arch.Pkg
+	gen := &imageLitGen{pkg: pkg, fset: it.inc.CurPkg.fileSet, qual: imageQualifier(pkg)}
+	var b strings.Builder
+	for _, v := range img.Vars {
+		tv, err := types.Eval(gen.fset, pkg, token.NoPos, v.Type)
+		if err != nil || !tv.IsType() {
+			it.mut.Unlock()
+			return fmt.Errorf("restoring %s: cannot find its type %s", v.Name, v.Type)
+		}
+		val, err := decodeImageValue(v.Value)
+		if err != nil {
+			it.mut.Unlock()
+			return fmt.Errorf("restoring %s: %v", v.Name, err)
+		}
+		lit, err := gen.lit(tv.Type, val)
+		if err != nil {
+			it.mut.Unlock()
+			return fmt.Errorf("restoring %s: %v", v.Name, err)
+		}
+		fmt.Fprintf(&b, "var %s %s = %s\n", v.Name, v.Type, lit)
+	}
+	it.mut.Unlock()
+
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := it.Eval(b.String()); err != nil {
+		return fmt.Errorf("restoring values: %v", err)
+	}
+	return nil
+}
+
+// encodeLuaGlobal returns the image encoding
+// of the Lua global holding Go variable name.
+func (it *Interp) encodeLuaGlobal(name string) (string, error) {
+	code := fmt.Sprintf("__gi_imageOut = __gi_imageEncode(_G[%q])", luaName(name))
+	if err := LuaRun(it.lvm, code, false); err != nil {
+		return "", err
+	}
+	t := it.lvm.goro.newTicket("", false)
+	t.gettyp = GetString
+	t.varname["__gi_imageOut"] = nil
+	if err := t.Do(); err != nil {
+		return "", err
+	}
+	js, _ := t.varname["__gi_imageOut"].(string)
+	panicOn(LuaRun(it.lvm, "__gi_imageOut = nil", false))
+	return js, nil
+}
+
+// unsupportedInImage returns why the encoded value js cannot
+// be restored, or "" if it can.
+func unsupportedInImage(js string) string {
+	// cheap test first: the encoder marks what it
+	// cannot handle with an "x" key.
+	if !strings.Contains(js, `{"x":`) {
+		return ""
+	}
+	i := strings.Index(js, `{"x":"`)
+	rest := js[i+len(`{"x":"`):]
+	if j := strings.Index(rest, `"`); j >= 0 {
+		return "cannot save a value of Lua type " + rest[:j]
+	}
+	return "cannot save value"
+}
+
+func imageQualifier(pkg *types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+}
+
+func constDecl(c *types.Const, qual types.Qualifier) string {
+	var val string
+	if c.Val().Kind() == constant.String {
+		val = strconv.Quote(constant.StringVal(c.Val()))
+	} else {
+		val = c.Val().ExactString()
+	}
+	if b, ok := c.Type().(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+		return "const " + c.Name() + " = " + val
+	}
+	return "const " + c.Name() + " " + types.TypeString(c.Type(), qual) + " = " + val
+}
+
+// declSource finds the latest source text of the
+// package level declarations in a session's inputs.
+type declSource struct {
+	types   map[string]string
+	funcs   map[string]string
+	methods map[string]string // key is "Type.Method"
+}
+
+func newDeclSource(log []string) *declSource {
+	d := &declSource{
+		types:   make(map[string]string),
+		funcs:   make(map[string]string),
+		methods: make(map[string]string),
+	}
+	for _, src := range log {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "", src, 0)
+		if err != nil {
+			continue
+		}
+		text := func(n ast.Node) string {
+			beg := fset.Position(n.Pos()).Offset
+			end := fset.Position(n.End()).Offset
+			return src[beg:end]
+		}
+		for _, node := range file.Nodes {
+			if ds, ok := node.(*ast.DeclStmt); ok {
+				node = ds.Decl
+			}
+			switch x := node.(type) {
+			case *ast.GenDecl:
+				if x.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range x.Specs {
+					ts := spec.(*ast.TypeSpec)
+					d.types[ts.Name.Name] = "type " + text(ts)
+				}
+			case *ast.FuncDecl:
+				if x.Recv == nil {
+					d.funcs[x.Name.Name] = text(x)
+					continue
+				}
+				if recv := recvTypeName(x.Recv); recv != "" {
+					d.methods[recv+"."+x.Name.Name] = text(x)
+				}
+			}
+		}
+	}
+	return d
+}
+
+func recvTypeName(fl *ast.FieldList) string {
+	if len(fl.List) != 1 {
+		return ""
+	}
+	t := fl.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+func (d *declSource) typeDecl(name string) (string, bool) {
+	s, ok := d.types[name]
+	return s, ok
+}
+
+func (d *declSource) funcDecl(name string) (string, bool) {
+	s, ok := d.funcs[name]
+	return s, ok
+}
+
+func (d *declSource) methodDecl(typ, method string) (string, bool) {
+	s, ok := d.methods[typ+"."+method]
+	return s, ok
+}
+
+func decodeImageValue(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// imageLitGen writes decoded image values
+// as Go literals of a given type.
+type imageLitGen struct {
+	pkg  *types.Package
+	fset *token.FileSet
+	qual types.Qualifier
+}
+
+func (g *imageLitGen) typeString(t types.Type) string {
+	return types.TypeString(t, g.qual)
+}
+
+// lit returns a Go expression of type t with value v.
+func (g *imageLitGen) lit(t types.Type, v interface{}) (string, error) {
+	if tag, ok := v.(map[string]interface{}); ok {
+		if _, isPtr := t.Underlying().(*types.Pointer); !isPtr {
+			// slices of structs hold pointers to their elements.
+			if inner, ok := tag["p"]; ok {
+				return g.lit(t, inner)
+			}
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		s, err := g.basic(u, v)
+		if err != nil {
+			return "", err
+		}
+		if _, named := t.(*types.Named); named {
+			return g.typeString(t) + "(" + s + ")", nil
+		}
+		if u.Info()&types.IsUntyped != 0 {
+			return s, nil
+		}
+		return g.typeString(t) + "(" + s + ")", nil
+
+	case *types.Slice:
+		if v == nil {
+			return "nil", nil
+		}
+		elems, err := g.list(v, "slice", u.Elem())
+		if err != nil {
+			return "", err
+		}
+		return g.typeString(t) + "{" + elems + "}", nil
+
+	case *types.Array:
+		elems, err := g.list(v, "array", u.Elem())
+		if err != nil {
+			return "", err
+		}
+		return g.typeString(t) + "{" + elems + "}", nil
+
+	case *types.Map:
+		if v == nil {
+			return "nil", nil
+		}
+		tag, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected a map, got %v", v)
+		}
+		pairs, _ := tag["map"].([]interface{})
+		var parts []string
+		for _, p := range pairs {
+			kv, ok := p.([]interface{})
+			if !ok || len(kv) != 2 {
+				return "", fmt.Errorf("bad map entry %v", p)
+			}
+			k, err := g.mapKey(u.Key(), kv[0])
+			if err != nil {
+				return "", err
+			}
+			e, err := g.lit(u.Elem(), kv[1])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, k+": "+e)
+		}
+		sort.Strings(parts)
+		return g.typeString(t) + "{" + strings.Join(parts, ", ") + "}", nil
+
+	case *types.Pointer:
+		if v == nil {
+			return "nil", nil
+		}
+		tag, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected a pointer, got %v", v)
+		}
+		inner, err := g.lit(u.Elem(), tag["p"])
+		if err != nil {
+			return "", err
+		}
+		switch u.Elem().Underlying().(type) {
+		case *types.Struct, *types.Array, *types.Slice, *types.Map:
+			return "&" + inner, nil
+		}
+		et := g.typeString(u.Elem())
+		return "func() *" + et + " { v := " + et + "(" + inner + "); return &v }()", nil
+
+	case *types.Struct:
+		tag, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected a struct, got %v", v)
+		}
+		fields, _ := tag["struct"].([]interface{})
+		if len(fields) != u.NumFields() {
+			return "", fmt.Errorf("struct %s has %d fields, the image has %d", g.typeString(t), u.NumFields(), len(fields))
+		}
+		var parts []string
+		for i, f := range fields {
+			s, err := g.lit(u.Field(i).Type(), f)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return g.typeString(t) + "{" + strings.Join(parts, ", ") + "}", nil
+
+	case *types.Interface:
+		return g.dynamic(v)
+	}
+	return "", fmt.Errorf("cannot restore a value of type %s", g.typeString(t))
+}
+
+// list writes the elements of a slice or array.
+func (g *imageLitGen) list(v interface{}, key string, elem types.Type) (string, error) {
+	tag, ok := v.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected a %s, got %v", key, v)
+	}
+	items, _ := tag[key].([]interface{})
+	parts := make([]string, len(items))
+	for i, e := range items {
+		s, err := g.lit(elem, e)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// dynamic writes the value held in an interface. The image
+// records the dynamic type of Go composite values; plain
+// numbers and strings come back as their default types.
+func (g *imageLitGen) dynamic(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case json.Number:
+		return x.String(), nil
+	case string:
+		return strconv.Quote(x), nil
+	case map[string]interface{}:
+		if ts, ok := x["t"].(string); ok {
+			tv, err := types.Eval(g.fset, g.pkg, token.NoPos, strings.Replace(ts, g.pkg.Name()+".", "", -1))
+			if err != nil || !tv.IsType() {
+				return "", fmt.Errorf("cannot find the type %s", ts)
+			}
+			return g.lit(tv.Type, v)
+		}
+		switch {
+		case x["i"] != nil:
+			return x["i"].(string), nil
+		case x["u"] != nil:
+			return "uint64(" + x["u"].(string) + ")", nil
+		case x["s"] != nil:
+			return g.basic(types.Typ[types.String], v)
+		case x["c"] != nil:
+			return g.basic(types.Typ[types.Complex128], v)
+		}
+	}
+	return "", fmt.Errorf("cannot restore the interface value %v", v)
+}
+
+// basic writes a boolean, number, or string.
+func (g *imageLitGen) basic(b *types.Basic, v interface{}) (string, error) {
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		if x, ok := v.(bool); ok {
+			return strconv.FormatBool(x), nil
+		}
+	case info&types.IsInteger != 0:
+		switch x := v.(type) {
+		case json.Number:
+			return x.String(), nil
+		case map[string]interface{}:
+			if s, ok := x["i"].(string); ok {
+				return s, nil
+			}
+			if s, ok := x["u"].(string); ok {
+				return s, nil
+			}
+		}
+	case info&types.IsFloat != 0:
+		if x, ok := v.(json.Number); ok {
+			return x.String(), nil
+		}
+	case info&types.IsComplex != 0:
+		if x, ok := v.(map[string]interface{}); ok {
+			if c, ok := x["c"].([]interface{}); ok && len(c) == 2 {
+				return fmt.Sprintf("complex(%v, %v)", c[0], c[1]), nil
+			}
+		}
+	case info&types.IsString != 0:
+		switch x := v.(type) {
+		case string:
+			return strconv.Quote(x), nil
+		case map[string]interface{}:
+			if h, ok := x["s"].(string); ok {
+				by, err := hex.DecodeString(h)
+				if err != nil {
+					return "", err
+				}
+				return strconv.Quote(string(by)), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot restore %v as a %s", v, b.Name())
+}
+
+// mapKey writes a map key, as stored by keyFor in tsys.lua.
+func (g *imageLitGen) mapKey(t types.Type, v interface{}) (string, error) {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return "", fmt.Errorf("cannot restore maps with %s keys", g.typeString(t))
+	}
+	var raw string
+	switch x := v.(type) {
+	case string:
+		raw = x
+	case json.Number:
+		raw = x.String()
+	case bool:
+		raw = strconv.FormatBool(x)
+	case map[string]interface{}:
+		h, _ := x["s"].(string)
+		by, err := hex.DecodeString(h)
+		if err != nil {
+			return "", err
+		}
+		raw = string(by)
+	default:
+		return "", fmt.Errorf("bad map key %v", v)
+	}
+	var s string
+	info := b.Info()
+	switch {
+	case info&types.IsString != 0:
+		s = strconv.Quote(raw)
+	case info&types.IsInteger != 0:
+		s = strings.TrimSuffix(strings.TrimSuffix(raw, "LL"), "U")
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+				return "", fmt.Errorf("bad integer map key %q", raw)
+			}
+		}
+	case info&types.IsFloat != 0:
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return "", fmt.Errorf("cannot restore the float map key %q", raw)
+		}
+		s = raw
+	case info&types.IsBoolean != 0:
+		s = raw
+	default:
+		return "", fmt.Errorf("cannot restore maps with %s keys", b.Name())
+	}
+	return g.typeString(t) + "(" + s + ")", nil
+}
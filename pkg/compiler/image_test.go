@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1317SessionImageSaveAndRestore(t *testing.T) {
+
+	cv.Convey(`a session image restores types, funcs, methods, consts and data into a fresh Interp, without re-running the inputs`, t, func() {
+		a, err := NewInterp(nil)
+		panicOn(err)
+		defer a.Close()
+
+		panicOn(a.Eval(`type Point struct{ X, Y int; Label string }`))
+		panicOn(a.Eval(`func (p Point) Sum() int { return p.X + p.Y }`))
+		panicOn(a.Eval(`func double(x int) int { return 2 * x }`))
+		panicOn(a.Eval(`const scale = 10`))
+		panicOn(a.Eval(`runs := 0`))
+		panicOn(a.Eval(`pts := []Point{{1, 2, "a"}, {3, 4, "b\n"}}; m := map[string]int{"one": 1, "two": 2}; f := 2.5; arr := [3]int{7, 8, 9}; pp := &Point{5, 6, "p"}; runs++`))
+		panicOn(a.Eval(`var e interface{} = Point{9, 9, "e"}; g := func() int { return 1 }`))
+
+		var buf bytes.Buffer
+		skipped, err := a.SaveImage(&buf)
+		panicOn(err)
+		cv.So(len(skipped), cv.ShouldEqual, 1)
+		cv.So(strings.HasPrefix(skipped[0], "g:"), cv.ShouldBeTrue)
+
+		b, err := NewInterp(nil)
+		panicOn(err)
+		defer b.Close()
+		panicOn(b.LoadImage(&buf))
+
+		// runs was only incremented once, so nothing was re-run.
+		panicOn(b.Eval(`r := runs; s := pts[1].Sum() + double(scale); l := pts[1].Label; n := m["two"]; af := f; a2 := arr[2]; ps := pp.Sum()`))
+		LuaMustInt64(b.lvm, "r", 1)
+		LuaMustInt64(b.lvm, "s", 27)
+		LuaMustString(b.lvm, "l", "b\n")
+		LuaMustInt64(b.lvm, "n", 2)
+		LuaMustFloat64(b.lvm, "af", 2.5)
+		LuaMustInt64(b.lvm, "a2", 9)
+		LuaMustInt64(b.lvm, "ps", 11)
+
+		// the struct inside the interface kept its dynamic type.
+		ea, err := a.encodeLuaGlobal("e")
+		panicOn(err)
+		eb, err := b.encodeLuaGlobal("e")
+		panicOn(err)
+		cv.So(eb, cv.ShouldEqual, ea)
+		cv.So(strings.Contains(eb, `"t":"main.Point"`), cv.ShouldBeTrue)
+	})
+}
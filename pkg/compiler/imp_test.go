@@ -24,7 +24,7 @@ func Test050CallFmtSprintf(t *testing.T) {
 		//fmt.Printf("go:'%#v'  -->  '%#v' in lua\n", src, translation)
 
 		cv.So(string(translation), matchesLuaSrc,
-			`a = fmt.Sprintf("hello no-args");`)
+			`a = __packages["fmt"].Sprintf("hello no-args");`)
 
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -53,7 +53,7 @@ func Test051CallFmtSprintf(t *testing.T) {
 		//fmt.Printf("go:'%#v'  -->  '%#v' in lua\n", src, translation)
 
 		cv.So(string(translation), matchesLuaSrc,
-			`a = fmt.Sprintf("hello one: %v", 1LL);`)
+			`a = __packages["fmt"].Sprintf("hello one: %v", 1LL);`)
 
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -78,7 +78,7 @@ func Test052CallFmtSprintf(t *testing.T) {
 		//fmt.Printf("go:'%#v'  -->  '%#v' in lua\n", src, translation)
 
 		cv.So(string(translation), matchesLuaSrc,
-			`a = fmt.Sprintf("hello %v %v", 3LL, 4LL);`)
+			`a = __packages["fmt"].Sprintf("hello %v %v", 3LL, 4LL);`)
 
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -102,7 +102,7 @@ func Test058CallFmtIncr(t *testing.T) {
 		pp("go:'%s'  -->  '%s' in lua\n", src, string(translation))
 
 		cv.So(string(translation), matchesLuaSrc,
-			`a = gitesting.Incr(1LL);`)
+			`a = __packages["gitesting"].Incr(1LL);`)
 
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -128,7 +128,7 @@ func Test059CallFmtSummer(t *testing.T) {
 		pp("go:'%s'  -->  '%s' in lua\n", src, string(translation))
 
 		cv.So(string(translation), matchesLuaSrc,
-			`a = gitesting.SummerAny(1LL, 2LL, 3LL);`)
+			`a = __packages["gitesting"].SummerAny(1LL, 2LL, 3LL);`)
 
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -157,7 +157,7 @@ func Test061CallFmtSummerWithDots(t *testing.T) {
 		cv.So(string(translation), matchesLuaSrc, `
   	__type__.anon_sliceType = __sliceType(__type__.int); -- 'IMMEDIATE' anon type printing.
   	b = __type__.anon_sliceType({[0]=8LL, 9LL});
-  	a = gitesting.SummerAny(__lazy_ellipsis(b));
+  	a = __packages["gitesting"].SummerAny(__lazy_ellipsis(b));
 `)
 
 		LoadAndRunTestHelper(t, vm, translation)
@@ -185,7 +185,7 @@ func Test062SprintfOneSlice(t *testing.T) {
 		cv.So(string(translation), matchesLuaSrc, `
 __type__.anon_sliceType = __sliceType(__type__.int); -- 'IMMEDIATE' anon type printing.
 
-  	a = fmt.Sprintf("yip %#v eee\n", __type__.anon_sliceType({[0]=4LL, 5LL, 6LL}));`)
+  	a = __packages["fmt"].Sprintf("yip %#v eee\n", __type__.anon_sliceType({[0]=4LL, 5LL, 6LL}));`)
 		LoadAndRunTestHelper(t, vm, translation)
 
 		LuaMustString(vm, "a", "yip []interface {}{4, 5, 6} eee\n")
@@ -210,7 +210,7 @@ func Test063SprintfOneSlice(t *testing.T) {
 		cv.So(string(translation), matchesLuaSrc, `
   	__type__.anon_sliceType = __sliceType(__type__.emptyInterface);
 
-     a = fmt.Sprintf("yee %v %v %v haw\n", __lazy_ellipsis(__type__.anon_sliceType({[0]=4LL, 5LL, 6LL})));
+     a = __packages["fmt"].Sprintf("yee %v %v %v haw\n", __lazy_ellipsis(__type__.anon_sliceType({[0]=4LL, 5LL, 6LL})));
 			`)
 
 		LoadAndRunTestHelper(t, vm, translation)
@@ -236,7 +236,7 @@ func Test064SprintfOneSlice(t *testing.T) {
 
 		cv.So(string(translation), matchesLuaSrc, `
   	     __type__.anon_sliceType = __sliceType(__type__.int);
-      	 a = fmt.Sprintf("%v %v\n", "hello", __type__.anon_sliceType({[0]=4LL, 5LL, 6LL}));
+      	 a = __packages["fmt"].Sprintf("%v %v\n", "hello", __type__.anon_sliceType({[0]=4LL, 5LL, 6LL}));
         `)
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -263,7 +263,7 @@ func Test065PrintfItselfAndOneSlice(t *testing.T) {
 		cv.So(string(translation), matchesLuaSrc, `
      	__type__.anon_sliceType = __sliceType(__type__.int);
 
-     	fmt.Printf("heya %#v %v\n", "hello", __type__.anon_sliceType({[0]=55LL, 56LL}), fmt.Printf);
+     	__packages["fmt"].Printf("heya %#v %v\n", "hello", __type__.anon_sliceType({[0]=55LL, 56LL}), __packages["fmt"].Printf);
         `)
 		LoadAndRunTestHelper(t, vm, translation)
 
@@ -297,7 +297,7 @@ func Test087ShadowRegexp(t *testing.T) {
 		// expressions.go:864-869 for ':' versus '.' in method calls.
 		cv.So(string(translation), matchesLuaSrc,
 			`
-	a = regexp.MustCompile("llo");
+	a = __packages["regexp"].MustCompile("llo");
 	loc = a.FindStringIndex("hello");
 	lenloc =  #loc;
 	a0 = __gi_GetRangeCheck(loc, 0);
@@ -0,0 +1,330 @@
+package compiler
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/importer"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// An input's imports, and theirs in turn, are imported one
+// at a time, as the type checker meets them; but most of the
+// work of each needs nothing of the session: reading the
+// host's types of a shadow package, or parsing and type
+// checking the API of a shim. So before an input is type
+// checked, that work is done for every package it brings in
+// that the session has not imported yet, on goroutines of
+// the host, as many at once as there are CPUs; a shim waits
+// for the packages it imports to be prepared first. The
+// imports the type checker then asks for find their types
+// ready, and are left only to install them in the session,
+// running their Lua, one at a time and in the order they
+// always were.
+//
+// A shadow package is made lazily, as its members are
+// first used (see ActuallyImportPackage), so only its
+// host's types are read ahead, unless a shim imports it, to
+// be checked against it. A package that cannot be prepared,
+// such as one of gi's own, or a shim importing one, is
+// imported as before, and any error is reported then, as
+// before.
+
+// preparedImport is a package whose types are being made
+// ready, before the session installs it.
+type preparedImport struct {
+	path    string
+	shim    *shimSrc // nil for a shadow
+	imports []string // for a shim, the packages it imports
+
+	done chan struct{} // closed once pkg is set, or not
+	pkg  *types.Package
+}
+
+// giPackages are the standard packages gi implements
+// itself, instead of binding the host's.
+var giPackages = map[string]bool{
+	"database/sql":  true,
+	"encoding/json": true,
+	"errors":        true,
+	"flag":          true,
+	"sort":          true,
+	"testing":       true,
+}
+
+// prepareImports starts preparing the packages that files
+// import, and that they import, if the session has not
+// imported them yet.
+func (ic *IncrState) prepareImports(files []*ast.File) {
+	// g has what to prepare of each path, or nil; have, the
+	// session's packages that those prepared import, since
+	// the type checker adds to the session's meanwhile.
+	g := make(map[string]*preparedImport)
+	have := make(map[string]*types.Package)
+	var visit func(path string)
+	visit = func(path string) {
+		if _, seen := g[path]; seen {
+			return
+		}
+		if pkg := ic.CurPkg.importContext.Packages[path]; pkg != nil {
+			have[path] = pkg
+			return
+		}
+		p := ic.importToPrepare(path)
+		g[path] = p
+		if p == nil {
+			return
+		}
+		for _, imp := range p.imports {
+			visit(imp)
+		}
+	}
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				visit(path)
+			}
+		}
+	}
+
+	var paths []string
+	for path, p := range g {
+		if p != nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+	ic.prepared = make(map[string]*preparedImport)
+	for _, path := range paths {
+		ic.prepared[path] = g[path]
+	}
+	for _, path := range cyclicImports(g, paths) {
+		// the type checker will say why.
+		p := g[path]
+		delete(ic.prepared, path)
+		close(p.done)
+	}
+	// a shadow that no shim imports is imported lazily,
+	// its types only read ahead for its first use to find.
+	shimDeps := make(map[string]bool)
+	for _, p := range g {
+		if p != nil && p.shim != nil {
+			for _, imp := range p.imports {
+				shimDeps[imp] = true
+			}
+		}
+	}
+	for _, path := range paths {
+		if p := ic.prepared[path]; p != nil && p.shim == nil && !shimDeps[path] {
+			importer.Prefetch(path)
+			delete(ic.prepared, path)
+			close(p.done)
+		}
+	}
+
+	work := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for _, path := range paths {
+		p := ic.prepared[path]
+		if p == nil {
+			continue
+		}
+		go func(p *preparedImport) {
+			defer close(p.done)
+			deps := make(map[string]*types.Package)
+			for _, imp := range p.imports {
+				pkg, ok := preparedDep(g, have, imp)
+				if !ok {
+					return
+				}
+				deps[imp] = pkg
+			}
+			work <- struct{}{}
+			defer func() { <-work }()
+			if p.shim != nil {
+				p.pkg = prepareShim(p, deps)
+			} else {
+				p.pkg = ic.prepareShadow(p.path)
+			}
+		}(p)
+	}
+}
+
+// importToPrepare returns what to prepare of path, or nil if
+// it cannot be prepared.
+func (ic *IncrState) importToPrepare(path string) *preparedImport {
+	if path == "unsafe" || path == "gitesting" || giPackages[path] || strings.HasPrefix(path, "gi/") || isLuaModPath(path) || ic.cfg.Plugins[path] != "" {
+		return nil
+	}
+	if ic.cfg.Policy.CheckImport(path) != nil {
+		return nil
+	}
+	p := &preparedImport{path: path, done: make(chan struct{})}
+	s, err := ic.findShim(path)
+	if err != nil {
+		return nil
+	}
+	if s == nil {
+		return p
+	}
+	p.shim = s
+	fset := token.NewFileSet()
+	seen := make(map[string]bool)
+	for name, src := range s.files {
+		f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly)
+		if err != nil {
+			return nil
+		}
+		for _, spec := range f.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil
+			}
+			if !seen[imp] {
+				seen[imp] = true
+				p.imports = append(p.imports, imp)
+			}
+		}
+	}
+	sort.Strings(p.imports)
+	return p
+}
+
+// cyclicImports returns those of paths, the packages of g to
+// prepare, that import themselves, directly or not; waiting
+// on them would never end.
+func cyclicImports(g map[string]*preparedImport, paths []string) []string {
+	const (
+		visiting = 1
+		finished = 2
+	)
+	state := make(map[string]int)
+	cyclic := make(map[string]bool)
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		p := g[path]
+		if p == nil {
+			return false
+		}
+		switch state[path] {
+		case visiting:
+			return true
+		case finished:
+			return cyclic[path]
+		}
+		state[path] = visiting
+		for _, imp := range p.imports {
+			if walk(imp) {
+				cyclic[path] = true
+			}
+		}
+		state[path] = finished
+		return cyclic[path]
+	}
+	var res []string
+	for _, path := range paths {
+		if walk(path) {
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
+// preparedDep waits for path, a package that one being
+// prepared imports, and returns its types, from the session
+// or from its preparation; it reports false if it has none.
+func preparedDep(g map[string]*preparedImport, have map[string]*types.Package, path string) (*types.Package, bool) {
+	if path == "unsafe" {
+		return types.Unsafe, true
+	}
+	p := g[path]
+	if p == nil {
+		pkg := have[path]
+		return pkg, pkg != nil
+	}
+	<-p.done
+	return p.pkg, p.pkg != nil
+}
+
+// prepareShadow reads the host's types of the package path,
+// less the members the sandbox policy denies, as
+// ActuallyImportPackage would; it returns nil if it cannot.
+func (ic *IncrState) prepareShadow(path string) *types.Package {
+	imp, ok := importer.Default().(types.ImporterFrom)
+	if !ok {
+		return nil
+	}
+	pkg, err := imp.ImportFrom(path, "", 0)
+	if err != nil {
+		return nil
+	}
+	pkg.SetPath("github.com/gijit/gi/pkg/compiler/shadow/" + path)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if _, isType := scope.Lookup(name).(*types.TypeName); !isType && !ic.cfg.Policy.AllowsMember(path, name) {
+			scope.DeleteByName(name)
+		}
+	}
+	return pkg
+}
+
+// prepareShim parses and type checks the API of the shim p,
+// with deps the types of the packages it imports; it returns
+// nil if it cannot.
+func prepareShim(p *preparedImport, deps map[string]*types.Package) *types.Package {
+	var names []string
+	for name := range p.shim.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, name, p.shim.files[name], 0)
+		if err != nil {
+			return nil
+		}
+		files = append(files, f)
+	}
+	conf := &types.Config{Importer: depImporter(deps)}
+	pkg, _, err := conf.Check(nil, nil, p.path, fset, files, nil, nil)
+	if err != nil {
+		return nil
+	}
+	return pkg
+}
+
+// depImporter imports the packages a shim being prepared
+// imports, from their types already made ready.
+type depImporter map[string]*types.Package
+
+func (d depImporter) Import(path string) (*types.Package, error) {
+	if pkg := d[path]; pkg != nil {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("%s was not prepared", path)
+}
+
+// takePrepared returns the types prepared for path, waiting
+// for them if need be, or nil if there are none; they are
+// taken once, by the import that installs them.
+func (ic *IncrState) takePrepared(path string) *preparedImport {
+	p := ic.prepared[path]
+	if p == nil {
+		return nil
+	}
+	delete(ic.prepared, path)
+	<-p.done
+	if p.pkg == nil {
+		return nil
+	}
+	return p
+}
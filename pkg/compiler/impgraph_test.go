@@ -0,0 +1,120 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/shim"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func init() {
+	shim.Register("example.com/gitest/graph/top", shim.Shim{
+		Go: `package top
+
+import (
+	"strings"
+
+	"example.com/gitest/graph/unit"
+)
+
+// Twice doubles u.
+func Twice(u unit.U) unit.U { return u }
+
+// Apply has r replace in s.
+func Apply(r *strings.Replacer, s string) string { return s }
+
+// Seen is what was imported first.
+var Seen string
+`,
+		Lua: `
+top.Twice = function(u) return 2LL * u end
+top.Apply = function(r, s) return r.Replace(s) end
+top.Seen = __gi_graphSeen
+`,
+	})
+	shim.Register("example.com/gitest/graph/unit", shim.Shim{
+		Go: `package unit
+
+// U is a unit.
+type U int
+
+// One is one U.
+func One() U { return 1 }
+`,
+		Lua: `
+__gi_graphSeen = "unit first"
+unit.One = function() return 1LL end
+`,
+	})
+}
+
+func Test1407ImportGraphsArePreparedTogether(t *testing.T) {
+
+	cv.Convey("the packages an input brings in, and those they import, have their types made ready together before it is type checked, and are installed in the order the type checker asks for them", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "", `package main
+
+import (
+	"fmt"
+	"unsafe"
+	"gi/ffi"
+	"example.com/gitest/graph/top"
+)
+`, parser.ImportsOnly)
+		panicOn(err)
+		ic := it.inc
+		ic.prepareImports([]*ast.File{file})
+		var paths []string
+		for path := range ic.prepared {
+			paths = append(paths, path)
+		}
+		cv.So(len(paths), cv.ShouldEqual, 3)
+		for _, path := range []string{"strings", "example.com/gitest/graph/top", "example.com/gitest/graph/unit"} {
+			cv.So(ic.prepared[path], cv.ShouldNotBeNil)
+		}
+		// imported lazily, its types only read ahead.
+		cv.So(ic.prepared["fmt"], cv.ShouldBeNil)
+		top := ic.takePrepared("example.com/gitest/graph/top")
+		cv.So(top, cv.ShouldNotBeNil)
+		cv.So(top.imports, cv.ShouldResemble, []string{"example.com/gitest/graph/unit", "strings"})
+		unit := ic.takePrepared("example.com/gitest/graph/unit")
+		cv.So(unit, cv.ShouldNotBeNil)
+		// top was checked against the unit prepared.
+		twice := top.pkg.Scope().Lookup("Twice").Type().(*types.Signature)
+		cv.So(twice.Params().At(0).Type().(*types.Named).Obj(), cv.ShouldEqual, unit.pkg.Scope().Lookup("U"))
+		ic.prepared = nil
+
+		panicOn(it.Eval(`import (
+	"strings"
+	"example.com/gitest/graph/top"
+	"example.com/gitest/graph/unit"
+)
+a := top.Twice(unit.One())
+b := top.Apply(strings.NewReplacer("x", "y"), "axb")
+seen := top.Seen
+`))
+		LuaMustInt64(it.lvm, "a", 2)
+		LuaMustString(it.lvm, "b", "ayb")
+		LuaMustString(it.lvm, "seen", "unit first")
+		cv.So(ic.prepared, cv.ShouldBeNil)
+	})
+
+	cv.Convey("packages that import themselves are not prepared, rather than waited on forever", t, func() {
+		g := map[string]*preparedImport{
+			"a": {imports: []string{"b"}},
+			"b": {imports: []string{"c"}},
+			"c": {imports: []string{"b"}},
+			"d": {imports: []string{"e"}},
+			"e": nil,
+		}
+		cv.So(cyclicImports(g, []string{"a", "b", "c", "d"}), cv.ShouldResemble, []string{"a", "b", "c"})
+	})
+}
@@ -3,7 +3,9 @@ package compiler
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gijit/gi/pkg/importer"
 	"github.com/gijit/gi/pkg/token"
@@ -12,6 +14,7 @@ import (
 	"github.com/glycerine/luar"
 
 	// shadow_ imports: available inside the REPL
+	"github.com/gijit/gi/pkg/compiler/shadow/bufio"
 	"github.com/gijit/gi/pkg/compiler/shadow/bytes"
 	"github.com/gijit/gi/pkg/compiler/shadow/fmt"
 	"github.com/gijit/gi/pkg/compiler/shadow/io"
@@ -19,16 +22,26 @@ import (
 	shadow_sync "github.com/gijit/gi/pkg/compiler/shadow/sync"
 	shadow_sync_atomic "github.com/gijit/gi/pkg/compiler/shadow/sync/atomic"
 
+	shadow_md5 "github.com/gijit/gi/pkg/compiler/shadow/crypto/md5"
+	shadow_sha256 "github.com/gijit/gi/pkg/compiler/shadow/crypto/sha256"
+	shadow_base64 "github.com/gijit/gi/pkg/compiler/shadow/encoding/base64"
+	shadow_hex "github.com/gijit/gi/pkg/compiler/shadow/encoding/hex"
+
 	shadow_io_ioutil "github.com/gijit/gi/pkg/compiler/shadow/io/ioutil"
 	"io/ioutil"
 
 	"github.com/gijit/gi/pkg/compiler/shadow/math"
+	shadow_math_big "github.com/gijit/gi/pkg/compiler/shadow/math/big"
 	shadow_math_rand "github.com/gijit/gi/pkg/compiler/shadow/math/rand"
 	"github.com/gijit/gi/pkg/compiler/shadow/os"
+	shadow_exec "github.com/gijit/gi/pkg/compiler/shadow/os/exec"
+	shadow_filepath "github.com/gijit/gi/pkg/compiler/shadow/path/filepath"
 	shadow_reflect "github.com/gijit/gi/pkg/compiler/shadow/reflect"
 	"github.com/gijit/gi/pkg/compiler/shadow/regexp"
 	shadow_runtime "github.com/gijit/gi/pkg/compiler/shadow/runtime"
 	shadow_runtime_debug "github.com/gijit/gi/pkg/compiler/shadow/runtime/debug"
+	"github.com/gijit/gi/pkg/compiler/shadow/strconv"
+	"github.com/gijit/gi/pkg/compiler/shadow/strings"
 	"github.com/gijit/gi/pkg/compiler/shadow/time"
 
 	// gonum
@@ -92,6 +105,15 @@ func registerLuarReqs(vm *golua.State) {
 
 	registerBasicReflectTypes(vm)
 
+	// errors.Is and Unwrap ask Go about Go's errors.
+	luar.Register(vm, "", luar.Map{
+		"__gi_hostErrorIs":     hostErrorIs,
+		"__gi_hostErrorUnwrap": hostErrorUnwrap,
+	})
+
+	// []byte values live in buffers shared with Go.
+	luar.RegisterSharedBytes(vm)
+
 	/*
 		luar.Register(vm, "", luar.Map{
 			"__tobytes": func(a interface{}) []byte {
@@ -120,15 +142,39 @@ func (ic *IncrState) EnableImportsFromLua() {
 	*/
 }
 
-func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
+func (ic *IncrState) GiImportFunc(path string) (arch *Archive, err error) {
 
 	// `import "fmt"` means that path == "fmt", for example.
 	//fmt.Printf("GiImportFunc called with path = '%s'... TODO: pure Lua packages. No go/binary/luar based stuff for now\n", path)
 
 	//return nil, nil
 
+	if err := ic.cfg.Policy.CheckImport(path); err != nil {
+		return nil, err
+	}
+	if pkg := ic.CurPkg.importContext.Packages[path]; pkg != nil {
+		// installed already, as by a shim that imports
+		// it: the same package, for its types to be.
+		return &Archive{
+			Name:       pkg.Name(),
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	}
+
 	var pkg *types.Package
+	start := time.Now()
 	t0 := ic.goro.newTicket("", true)
+	defer func() {
+		// for :packages.
+		ic.noteImport(path, start, t0.run, arch, err)
+	}()
+
+	if s, err := ic.findShim(path); err != nil {
+		return nil, err
+	} else if s != nil {
+		return ic.importShim(path, s, t0)
+	}
 
 	switch path {
 	case "gitesting":
@@ -152,11 +198,12 @@ func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
 			incr := getFunForIncr(pkg)
 			scope.Insert(incr)
 
-			t0.regns = "gitesting"
+			t0.regns = "__gi_importing"
 			t0.regmap["SumArrayInt64"] = sumArrayInt64
 			t0.regmap["Summer"] = Summer
 			t0.regmap["SummerAny"] = SummerAny
 			t0.regmap["Incr"] = Incr
+			t0.run = append(t0.run, "__packages.gitesting = __gi_importing; __gi_importing = nil\n"...)
 			panicOn(t0.Do())
 
 			ic.CurPkg.importContext.Packages[path] = pkg
@@ -167,6 +214,113 @@ func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
 		}
 
 		// gen-gijit-shadow outputs to pkg/compiler/shadow/...
+	case "testing":
+		pkg := shadowTestingPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "gi/ffi":
+		pkg := shadowFFIPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "gi/grpc":
+		t0.regmap["__gi_grpcDial"] = grpcDial
+		t0.regmap["__gi_grpcInvoke"] = grpcInvoke
+		t0.regmap["__gi_grpcClose"] = grpcClose
+		panicOn(t0.Do())
+		pkg := shadowGRPCPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "gi/display":
+		pkg := shadowDisplayPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "gi/plot":
+		policy := ic.cfg.Policy
+		t0.regmap["__gi_plotRender"] = plotRender
+		t0.regmap["__gi_plotSave"] = func(spec, path string) (string, string) {
+			return plotSave(policy, spec, path)
+		}
+		panicOn(t0.Do())
+		pkg := shadowPlotPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "gi/frame":
+		policy := ic.cfg.Policy
+		t0.regmap["__gi_frameRead"] = func(path, format string) (string, string) {
+			return frameRead(policy, path, format)
+		}
+		t0.regmap["__gi_frameParse"] = frameParse
+		t0.regmap["__gi_frameRecords"] = frameRecords
+		t0.regmap["__gi_frameSchema"] = frameSchema
+		panicOn(t0.Do())
+		pkg := shadowFramePackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "database/sql":
+		pkg := shadowSQLPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "errors":
+		pkg := shadowErrorsPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "flag":
+		t0.regmap["__gi_flagArgs"] = ic.cfg.scriptArgs
+		t0.regmap["__gi_flagParseBool"] = flagParseBool
+		t0.regmap["__gi_flagParseInt"] = flagParseInt
+		t0.regmap["__gi_flagParseUint"] = flagParseUint
+		t0.regmap["__gi_flagParseFloat"] = flagParseFloat
+		t0.regmap["__gi_flagOutput"] = flagOutput
+		t0.regmap["__gi_flagExit"] = flagExit
+		panicOn(t0.Do())
+		pkg := shadowFlagPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "encoding/json":
+		pkg := shadowJSONPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "sort":
+		pkg := shadowSortPackage()
+		ic.CurPkg.importContext.Packages[path] = pkg
+		return &Archive{
+			ImportPath: path,
+			Pkg:        pkg,
+		}, nil
+	case "bufio":
+		t0.regmap["bufio"] = shadow_bufio.Pkg
+		t0.regmap["__ctor__bufio"] = shadow_bufio.Ctor
+		t0.run = append(t0.run, shadow_bufio.InitLua()...)
 	case "bytes":
 		t0.regmap["bytes"] = shadow_bytes.Pkg
 		t0.regmap["__ctor__bytes"] = shadow_bytes.Ctor
@@ -183,25 +337,50 @@ func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
 		t0.regmap["math"] = shadow_math.Pkg
 		t0.regmap["__ctor__math"] = shadow_math.Ctor
 		t0.run = append(t0.run, shadow_math.InitLua()...)
+	case "math/big":
+		t0.regmap["big"] = shadow_math_big.Pkg
+		t0.regmap["__ctor__big"] = shadow_math_big.Ctor
+		t0.run = append(t0.run, shadow_math_big.InitLua()...)
 	case "math/rand":
 		t0.regmap["rand"] = shadow_math_rand.Pkg
 		t0.regmap["__ctor__math_rand"] = shadow_math_rand.Ctor
 		t0.run = append(t0.run, shadow_math_rand.InitLua()...)
 	case "os":
-		t0.regmap["os"] = shadow_os.Pkg
+		t0.regmap["os"] = ic.cfg.osPkg()
 		t0.regmap["__ctor__os"] = shadow_os.Ctor
 		t0.run = append(t0.run, shadow_os.InitLua()...)
+	case "os/exec":
+		t0.regmap["exec"] = shadow_exec.Pkg
+		t0.regmap["__ctor__exec"] = shadow_exec.Ctor
+		t0.run = append(t0.run, shadow_exec.InitLua()...)
+	case "path/filepath":
+		t0.regmap["filepath"] = shadow_filepath.Pkg
+		t0.regmap["__ctor__filepath"] = shadow_filepath.Ctor
+		t0.run = append(t0.run, shadow_filepath.InitLua()...)
 
 	case "reflect":
 		t0.regmap["reflect"] = shadow_reflect.Pkg
 		t0.regmap["__ctor__reflect"] = shadow_reflect.Ctor
 		t0.run = append(t0.run, shadow_reflect.InitLua()...)
+		t0.run = append(t0.run, "\nreflect = __gi_reflectShim(reflect)\n"...)
 
 	case "regexp":
 		t0.regmap["regexp"] = shadow_regexp.Pkg
 		t0.regmap["__ctor__regexp"] = shadow_regexp.Ctor
 		t0.run = append(t0.run, shadow_regexp.InitLua()...)
 
+	case "strconv":
+		t0.regmap["strconv"] = shadow_strconv.Pkg
+		t0.regmap["__ctor__strconv"] = shadow_strconv.Ctor
+		t0.run = append(t0.run, shadow_strconv.InitLua()...)
+
+	case "strings":
+		t0.regmap["strings"] = shadow_strings.Pkg
+		t0.regmap["__ctor__strings"] = shadow_strings.Ctor
+		t0.run = append(t0.run, shadow_strings.InitLua()...)
+		// a Builder is made in Lua; see strbuild.go.
+		t0.run = append(t0.run, "\n__type__.strings.Builder.ptrToNewlyConstructed = __gi_newBuilder\n"...)
+
 	case "sync":
 		t0.regmap["sync"] = shadow_sync.Pkg
 		t0.regmap["__ctor__sync"] = shadow_sync.Ctor
@@ -220,13 +399,34 @@ func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
 	case "runtime":
 		t0.regmap["runtime"] = shadow_runtime.Pkg
 		t0.regmap["__ctor__runtime"] = shadow_runtime.Ctor
+		t0.regmap["__gi_runtimeCaller"] = ic.runtimeCaller
+		t0.regmap["__gi_runtimeStack"] = ic.runtimeStack
+		t0.regmap["__gi_runtimeStacks"] = ic.runtimeStacks
 		t0.run = append(t0.run, shadow_runtime.InitLua()...)
+		// gi's goroutines and stacks, in front of the host's.
+		t0.run = append(t0.run, "\nruntime = __gi_runtimeShim(runtime)\n"...)
 
 	case "runtime/debug":
 		t0.regmap["debug"] = shadow_runtime_debug.Pkg
 		t0.regmap["__ctor__debug"] = shadow_runtime_debug.Ctor
 		t0.run = append(t0.run, shadow_runtime_debug.InitLua()...)
 
+	case "crypto/md5":
+		t0.regmap["md5"] = shadow_md5.Pkg
+		t0.regmap["__ctor__md5"] = shadow_md5.Ctor
+		t0.run = append(t0.run, shadow_md5.InitLua()...)
+	case "crypto/sha256":
+		t0.regmap["sha256"] = shadow_sha256.Pkg
+		t0.regmap["__ctor__sha256"] = shadow_sha256.Ctor
+		t0.run = append(t0.run, shadow_sha256.InitLua()...)
+	case "encoding/base64":
+		t0.regmap["base64"] = shadow_base64.Pkg
+		t0.regmap["__ctor__base64"] = shadow_base64.Ctor
+		t0.run = append(t0.run, shadow_base64.InitLua()...)
+	case "encoding/hex":
+		t0.regmap["hex"] = shadow_hex.Pkg
+		t0.regmap["__ctor__hex"] = shadow_hex.Ctor
+		t0.run = append(t0.run, shadow_hex.InitLua()...)
 	case "io/ioutil":
 		t0.regmap["ioutil"] = shadow_io_ioutil.Pkg
 		t0.regmap["__ctor__ioutil"] = shadow_io_ioutil.Ctor
@@ -255,18 +455,120 @@ func (ic *IncrState) GiImportFunc(path string) (*Archive, error) {
 		t0.regmap["unit"] = shadow_unit.Pkg
 
 	default:
+		if file := ic.cfg.Plugins[path]; file != "" {
+			if err := loadPlugin(ic.cfg.Policy, path, path[strings.LastIndex(path, "/")+1:], file, t0); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if isLuaModPath(path) {
+			return ic.importLuaModule(path)
+		}
 		// need to run gen-gijit-shadow-import
 		return nil, fmt.Errorf("erro: package '%s' unknown, or not shadowed. To shadow it, run gen-gijit-shadow-import on the package, add a case and import above, and recompile gijit.", path)
 	}
+
+	// hide the members the sandbox policy denies,
+	// from both Lua and the type checker, and in
+	// deterministic mode swap in the virtual clock
+	// and random source. fmt's Print functions write
+	// to the Interp's output.
+	var denied []string
+	for k, v := range t0.regmap {
+		if m, ok := v.(map[string]interface{}); ok && !strings.HasPrefix(k, "__ctor__") {
+			d := ic.cfg.Policy.deniedMembers(path, m)
+			denied = append(denied, d...)
+			m = filterPkgMap(m, d)
+			if ic.det != nil {
+				m = overridePkgMap(m, ic.det.overrides(path))
+			}
+			m = overridePkgMap(m, ic.out.overrides(path))
+			t0.regmap[k] = m
+		}
+	}
+	// Luar registers the package in __gi_importing, not as
+	// a global. Its Lua runs with the package, its
+	// constructors and its types in locals, named as that Lua
+	// names them; the code at the prompt finds the package in
+	// __packages and its types in __type__, by path.
+	name := path[strings.LastIndex(path, "/")+1:]
+	ctor := "nil"
+	var hooks strings.Builder
+	var keys []string
+	for k := range t0.regmap {
+		keys = append(keys, k)
+	}
+	// in order, for the import cache to find the same Lua.
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch {
+		case strings.HasPrefix(k, "__ctor__"):
+			ctor = fmt.Sprintf("__gi_importing[%q]", k)
+		case strings.HasPrefix(k, "__"):
+			// gi's own, which the prelude finds as globals.
+			fmt.Fprintf(&hooks, "%s = __gi_importing[%q]\n", k, k)
+		default:
+			name = k
+		}
+	}
+	t0.regns = "__gi_importing"
+	t0.run = append([]byte(fmt.Sprintf("do\nlocal __type__ = __gi_typesOf(%q, %q)\nlocal %s, __ctor__%s = __gi_importing[%q], %s\n%s__gi_importing = nil\n",
+		path, name, name, name, name, ctor, hooks.String())), t0.run...)
+	t0.run = append(t0.run, fmt.Sprintf("\n__packages[%q] = %s\nend\n", path, name)...)
+	t0.run = ic.cachedImportLua(path, t0.run)
 	panicOn(t0.Do())
 
 	// loading from real GOROOT/GOPATH.
 	// Omit vendor support for now, for sanity.
 	shadowPath := "github.com/gijit/gi/pkg/compiler/shadow/" + path
-	return ic.ActuallyImportPackage(path, "", shadowPath)
+	arch, err = ic.ActuallyImportPackage(path, "", shadowPath, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range denied {
+		arch.Pkg.Scope().DeleteByName(name)
+	}
+	return arch, nil
 	//return ic.ActuallyImportPackage(path, "", path)
 }
 
+// isShadowPkg reports whether pkg is bound from Go through
+// a shadow package, so its values are Go's, not Lua's.
+func isShadowPkg(pkg *types.Package) bool {
+	return pkg != nil && strings.HasPrefix(pkg.Path(), "github.com/gijit/gi/pkg/compiler/shadow/")
+}
+
+// isGoValueType reports whether t is a struct or array type
+// bound from Go, whose values luar hands to Lua.
+func isGoValueType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || !isShadowPkg(named.Obj().Pkg()) {
+		return false
+	}
+	switch named.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
+
+// isReflectStructTag reports whether t is reflect.StructTag,
+// whose methods gi runs in Lua, on the tag's string.
+func isReflectStructTag(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return omitAnyShadowPathPrefix(named.Obj().Pkg().Path()) == "reflect" && named.Obj().Name() == "StructTag"
+}
+
+// packageRef is the Lua that reaches the table of pkg's
+// members: its entry in __packages, under the path it was
+// imported by.
+func packageRef(pkg *types.Package) string {
+	return fmt.Sprintf("__packages[%q]", omitAnyShadowPathPrefix(pkg.Path()))
+}
+
 func omitAnyShadowPathPrefix(path string) string {
 	const prefix = "github.com/gijit/gi/pkg/compiler/shadow/"
 	if strings.HasPrefix(path, prefix) {
@@ -420,23 +722,36 @@ func getFunForIncr(pkg *types.Package) *types.Func {
 // go/loader from tools/x, to be most up to date.
 // However, the binary loader is *much* faster.
 //
+// Unless it is vendored, the package, named name, is read
+// only when a member of it is first used, and each member
+// made only then: importing a big package costs next to
+// nothing, and using it only what it uses; see
+// importer.Lazy.
+//
 // dir provides where to import from, to honor vendored packages.
-func (ic *IncrState) ActuallyImportPackage(path, dir, shadowPath string) (*Archive, error) {
+func (ic *IncrState) ActuallyImportPackage(path, dir, shadowPath, name string) (*Archive, error) {
 	var pkg *types.Package
 
-	//imp := importer.For("source", nil) // Default()
-	// faster than source importing is reading the binary.
-	imp := importer.Default()
-	imp2, ok := imp.(types.ImporterFrom)
-	if !ok {
-		panic("importer.ImportFrom not available, vendored packages would be lost")
-	}
-	var mode types.ImportMode
-	var err error
-	pkg, err = imp2.ImportFrom(path, dir, mode)
+	if p := ic.takePrepared(path); p != nil && p.shim == nil {
+		// read already; see impgraph.go.
+		pkg = p.pkg
+	} else if dir == "" {
+		pkg = importer.Lazy(path, name)
+	} else {
+		//imp := importer.For("source", nil) // Default()
+		// faster than source importing is reading the binary.
+		imp := importer.Default()
+		imp2, ok := imp.(types.ImporterFrom)
+		if !ok {
+			panic("importer.ImportFrom not available, vendored packages would be lost")
+		}
+		var mode types.ImportMode
+		var err error
+		pkg, err = imp2.ImportFrom(path, dir, mode)
 
-	if err != nil {
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	pkgName := pkg.Name()
@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Under -emit=bytecode, the Lua an import runs, such as the
+// Lua side of a shadow package's types or a shim's Lua, is
+// kept in the import cache compiled to LuaJIT bytecode, so
+// that an import of the package in a later session loads it
+// without parsing it. An entry is named for a hash of the
+// Lua, of the LuaJIT that compiled it and of -strip: a
+// package whose Lua has changed, or a new LuaJIT, misses,
+// and is compiled afresh. Bytecode is loaded unchecked, so
+// the cache needs the ffi capability, as raw Lua does, and
+// its directory should be writable by its owner alone. A
+// cache that cannot be read or written is passed over, and
+// the Lua run as it is.
+
+// importCacheDir is the directory of c's import cache, or
+// "" if there is none to be had.
+func (c *GIConfig) importCacheDir() string {
+	if c.ImportCache != "" {
+		return c.ImportCache
+	}
+	if d := os.Getenv("GI_IMPORT_CACHE"); d != "" {
+		return d
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".gijit.cache", "imports")
+	}
+	return ""
+}
+
+// cachedImportLua returns what the import of path runs in
+// place of lua: under -emit=bytecode, its bytecode, from the
+// cache, or compiled now and stored there; else lua itself.
+func (ic *IncrState) cachedImportLua(path string, lua []byte) []byte {
+	if ic.cfg.Emit != "bytecode" || !ic.cfg.Policy.Allows(CapFFI) {
+		return lua
+	}
+	dir := ic.cfg.importCacheDir()
+	if dir == "" {
+		return lua
+	}
+	if ic.jitVersion == "" {
+		t := ic.goro.newTicket("__gi_jitVersion = jit.version", false)
+		t.gettyp = GetString
+		t.varname["__gi_jitVersion"] = nil
+		if err := t.Do(); err != nil {
+			return lua
+		}
+		ic.jitVersion, _ = t.varname["__gi_jitVersion"].(string)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "gi import cache 1\x00%s\x00%v\x00", ic.jitVersion, ic.cfg.Strip)
+	h.Write(lua)
+	file := filepath.Join(dir, hex.EncodeToString(h.Sum(nil))+".ljbc")
+
+	if bc, err := ioutil.ReadFile(file); err == nil && len(bc) > 0 && bc[0] == 27 {
+		return bc
+	}
+	bc, err := dumpLuaBytecode(ic.goro, string(lua), "import "+path, ic.cfg.Strip)
+	if err != nil {
+		// run the Lua, for the error to come out as it would.
+		return lua
+	}
+	writeImportCache(dir, file, bc)
+	return bc
+}
+
+// writeImportCache stores bc in file, in dir, whole or not at
+// all, so that a session reading it at the same time never
+// finds it half written.
+func writeImportCache(dir, file string, bc []byte) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(bc)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -39,6 +39,14 @@ func addPreludeToNewPkg(pkg *types.Package) {
 }
 
 func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, fileSet *token.FileSet, importContext *ImportContext, minify bool) (*Archive, error) {
+	return incrementallyCompile(a, importPath, files, fileSet, importContext, minify, nil, false, false)
+}
+
+// incrementallyCompile is IncrementallyCompile, with the
+// statements instrumented for coverage when cover is set,
+// and marked with their positions for the luaSourceMap
+// when markStmts is; with inline, small funcs are inlined.
+func incrementallyCompile(a *Archive, importPath string, files []*ast.File, fileSet *token.FileSet, importContext *ImportContext, minify bool, cover *coverage, markStmts, inline bool) (*Archive, error) {
 
 	pp("jea debug, top of incrementallyCompile()."+
 		" importPath='%s' here is what files has:", importPath)
@@ -51,6 +59,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	}
 
 	var newCodeText [][]byte
+	var newCodeStarts []CodeStart
 	var funcSrcCache map[string]string
 
 	var typesInfo *types.Info
@@ -79,6 +88,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	} else {
 		config = &types.Config{
 			DisableUnusedImportCheck: true, // jea add
+			ForeignMethods:           importContext.ForeignMethods,
 			Importer: packageImporter{
 				importContext: importContext,
 				importError:   &importError,
@@ -86,7 +96,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 			//Sizes: sizes32,
 			Sizes: sizes64,
 			Error: func(err error) {
-				panic(fmt.Sprintf("where error? err = '%v'", err))
+				panic(typeCheckError{err})
 				if previousErr != nil && previousErr.Error() == err.Error() {
 					return
 				}
@@ -182,6 +192,8 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 			minify:       minify,
 			fileSet:      fileSet,
 			files:        files,
+			cover:        cover,
+			markStmts:    markStmts,
 		},
 		allVars:      make(map[string]int),
 		flowDatas:    map[*types.Label]*flowData{nil: {}},
@@ -192,6 +204,9 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	for name := range reservedKeywords {
 		c.allVars[name] = 1
 	}
+	if inline {
+		c.p.inline = findInlineFuncs(pkgInfo.Info, simplifiedFiles)
+	}
 	pp("got past AnalyzePkg")
 
 	// ==============================
@@ -199,8 +214,9 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	// modifications start here
 	// ==============================
 
-	// imports
-	var importDecls []*Decl
+	// imports: each package is reached through its table
+	// in __packages, not through a global of its name; see
+	// prelude/prelude.lua.
 	var importedPaths []string
 	for _, importedPkg := range pkg.Imports() {
 		if importedPkg == types.Unsafe {
@@ -208,21 +224,10 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 			// but now we do it here to maintain previous behavior.
 			continue
 		}
-		c.p.pkgVars[importedPkg.Path()] = c.newVariableWithLevel(importedPkg.Name(), true, false)
+		c.p.pkgVars[importedPkg.Path()] = packageRef(importedPkg)
 		importedPaths = append(importedPaths, importedPkg.Path())
 	}
 	sort.Strings(importedPaths)
-	for _, impPath := range importedPaths {
-		id := c.newIdent(fmt.Sprintf(`%s._init`, c.p.pkgVars[impPath]), types.NewSignature(nil, nil, nil, false))
-		call := &ast.CallExpr{Fun: id}
-		c.Blocking[call] = true
-		c.Flattened[call] = true
-		importDecls = append(importDecls, &Decl{
-			Vars:     []string{c.p.pkgVars[impPath]},
-			DeclCode: []byte(fmt.Sprintf("\t%s = __packages[\"%s\"];\n", c.p.pkgVars[impPath], impPath)),
-			InitCode: c.CatchOutput(1, func() { c.translateStmt(&ast.ExprStmt{X: call}, nil) }),
-		})
-	}
 
 	collectDependencies := func(f func()) []string {
 		c.p.dependencies = make(map[types.Object]bool)
@@ -271,6 +276,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	for _, file := range simplifiedFiles {
 		pp("file.Nodes has %v elements", len(file.Nodes))
 		for _, decl := range file.Nodes {
+			newCodeStarts = append(newCodeStarts, CodeStart{Pos: decl.Pos(), Text: len(newCodeText)})
 
 			// fill out vars and functions
 
@@ -351,12 +357,23 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 							de.DceMethodFilter = o.Name() + "___tilde_" //jea: "~"
 						}
 					}
+					var hoisted []byte
+					c.p.hoistedAnon = &hoisted
 					de.DceDeps = collectDependencies(func() {
 						de.DeclCode = c.translateToplevelFunction(fun, funcInfo)
 					})
+					c.p.hoistedAnon = nil
 					funcDecls = append(funcDecls, &de)
 					pp("place3, appending to newCodeText: de.DeclCode='%s'", string(de.DeclCode))
+					if len(hoisted) > 0 {
+						newCodeText = append(newCodeText, hoisted)
+					}
 					newCodeText = append(newCodeText, de.DeclCode)
+					if len(de.InitCode) > 0 {
+						// a func init, as of a whole file
+						// pasted in, runs once declared.
+						newCodeText = append(newCodeText, de.InitCode)
+					}
 
 					// end of function codegen now
 				}
@@ -386,6 +403,11 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 					pp("we're in the token.TYPE!")
 					for _, spec := range d.Specs {
 						o := c.p.Defs[spec.(*ast.TypeSpec).Name].(*types.TypeName)
+						if o.IsAlias() {
+							// uses of an alias are translated
+							// as uses of the type it denotes.
+							continue
+						}
 						c.p.typeNames = append(c.p.typeNames, o)
 						c.objectName(o) // register toplevel name
 
@@ -518,10 +540,23 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 
 					// *ast.ExprStmt
 					wrapWithPrint := true
+					// struct values are held by pointer, so
+					// __gi_show is told to show them as structs.
+					deref := ""
 					switch y := d.(type) {
 					case *ast.ExprStmt:
+						t := c.p.TypeOf(y.X)
+						if t != nil {
+							if _, isStruct := t.Underlying().(*types.Struct); isStruct {
+								deref = ", true"
+							}
+						}
 						switch z := y.X.(type) {
 						case *ast.CallExpr:
+							if t != nil && isDisplayed(t) {
+								// display.HTML(...) at the prompt shows.
+								break
+							}
 							switch id := z.Fun.(type) {
 							case *ast.Ident:
 								//fmt.Printf("z.Fun is Ident: %#v\n", id)
@@ -548,7 +583,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 					if !wrapWithPrint || strings.HasPrefix(ele, "print") {
 						tmp = ele + ";"
 					} else {
-						pp("wrapping last line of '%s' in print at the repl", ele)
+						pp("wrapping last line of '%s' in __gi_show at the repl", ele)
 						key := fmt.Sprintf("%s", ele)
 						fsrc, haveSrc := funcSrcCache[key]
 						if haveSrc {
@@ -567,9 +602,9 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 								nsplit = len(splt)
 							}
 							if nsplit <= 1 {
-								tmp = fmt.Sprintf(`print(%s);`, ele)
+								tmp = fmt.Sprintf(`__gi_show(%s%s);`, ele, deref)
 							} else {
-								tmp = fmt.Sprintf("%s;\nprint(%s);", strings.Join(splt[:nsplit-1], "\n"), splt[nsplit-1])
+								tmp = fmt.Sprintf("%s;\n__gi_show(%s%s);", strings.Join(splt[:nsplit-1], "\n"), splt[nsplit-1], deref)
 							}
 						}
 					}
@@ -672,7 +707,7 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 	typeDecls, _ = c.anonymousTypes(typeDecls, collectDependencies)
 
 	var allDecls []*Decl
-	for _, d := range append(append(append(importDecls, typeDecls...), varDecls...), funcDecls...) {
+	for _, d := range append(append(typeDecls, varDecls...), funcDecls...) {
 		d.DeclCode = removeWhitespace(d.DeclCode, minify)
 		d.MethodListCode = removeWhitespace(d.MethodListCode, minify)
 		d.TypeInitCode = removeWhitespace(d.TypeInitCode, minify)
@@ -686,24 +721,26 @@ func IncrementallyCompile(a *Archive, importPath string, files []*ast.File, file
 
 	if a == nil {
 		return &Archive{
-			ImportPath:   importPath,
-			Name:         pkg.Name(),
-			Imports:      importedPaths,
-			ExportData:   exportData,
-			Declarations: allDecls,
-			FileSet:      encodedFileSet.Bytes(),
-			Minified:     minify,
-			NewCodeText:  newCodeText,
-			TypesInfo:    typesInfo,
-			Config:       config,
-			Pkg:          pkg,
-			Check:        check,
-			FuncSrcCache: funcSrcCache,
+			ImportPath:    importPath,
+			Name:          pkg.Name(),
+			Imports:       importedPaths,
+			ExportData:    exportData,
+			Declarations:  allDecls,
+			FileSet:       encodedFileSet.Bytes(),
+			Minified:      minify,
+			NewCodeText:   newCodeText,
+			NewCodeStarts: newCodeStarts,
+			TypesInfo:     typesInfo,
+			Config:        config,
+			Pkg:           pkg,
+			Check:         check,
+			FuncSrcCache:  funcSrcCache,
 		}, nil
 	} else {
 		a.Pkg = pkg
 		a.Check = check
 		a.NewCodeText = newCodeText
+		a.NewCodeStarts = newCodeStarts
 		a.FuncSrcCache = funcSrcCache
 	}
 	return a, nil
@@ -962,3 +999,13 @@ func (c *funcContext) getMethodDetailsSig(method *types.Func) (entry string) {
 	t := method.Type().(*types.Signature)
 	return fmt.Sprintf(`{prop= "%s", __name= "%s", __pkg="%s", __typ= __funcType(%s)}`, name, method.Name(), pkgPath, c.initArgs(t))
 }
+
+// typeCheckError carries the first type error of an
+// input out of the checker, which stops there.
+type typeCheckError struct {
+	err error
+}
+
+func (e typeCheckError) Error() string {
+	return fmt.Sprintf("where error? err = '%v'", e.err)
+}
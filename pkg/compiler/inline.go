@@ -0,0 +1,369 @@
+package compiler
+
+import (
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/compiler/astutil"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Go code that keeps its fields behind accessors,
+//
+//	func (p *Point) X() float64 { return p.x }
+//	func (p *Point) SetX(x float64) { p.x = x }
+//
+// pays a Lua call for every field it reads that way, and a
+// value receiver a copy of the struct besides. So when a
+// whole program is translated at once, as by gi build, run
+// and transpile, a call of a small func or method declared
+// in it has the func's body put in its place:
+//
+//	local d = p:X() - q:X();      -- becomes
+//	local d = p.x - q.x;
+//
+// A func is small enough when its body is one return of one
+// expression of at most inlineMaxNodes nodes, or, for a
+// method with a pointer receiver, one assignment to a field
+// of the receiver; the expression may use the parameters,
+// fields, package-level names, operators and conversions,
+// but call nothing else. A func marked //go:noinline, as for
+// gc, is left alone. The arguments of a call inlined must
+// be names, constants or fields of them, which it can do no
+// harm to evaluate again, or not at all; and no name the
+// body uses may be hidden where it is called, by a local of
+// the caller that the body put there would find instead.
+//
+// At the prompt, nothing is inlined: a func redeclared
+// there replaces the old one for the callers it already
+// has, which a body put in their place would not see.
+
+// inlineMaxNodes is the most nodes the expression of an
+// inlined func may have.
+const inlineMaxNodes = 12
+
+// inlineFunc is a func whose calls can be replaced by its
+// body.
+type inlineFunc struct {
+	recv   *types.Var // nil for a func
+	params []*types.Var
+	sig    *types.Signature
+	result ast.Expr        // for a getter, what it returns
+	assign *ast.AssignStmt // for a setter, the assignment
+
+	// the package-level names the body uses.
+	globals []types.Object
+}
+
+// findInlineFuncs finds the funcs of files whose calls can
+// be inlined.
+func findInlineFuncs(info *types.Info, files []*ast.File) map[*types.Func]*inlineFunc {
+	inl := make(map[*types.Func]*inlineFunc)
+	for _, file := range files {
+		for _, node := range file.Nodes {
+			fd, ok := node.(*ast.FuncDecl)
+			if !ok || fd.Body == nil || len(fd.Body.List) != 1 || noInline(fd) {
+				continue
+			}
+			fn, ok := info.Defs[fd.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			f := &inlineFunc{sig: fn.Type().(*types.Signature)}
+			if f.sig.Variadic() {
+				continue
+			}
+			f.recv = f.sig.Recv()
+			for i := 0; i < f.sig.Params().Len(); i++ {
+				f.params = append(f.params, f.sig.Params().At(i))
+			}
+			if !f.small(info, fd.Body.List[0]) {
+				continue
+			}
+			inl[fn] = f
+		}
+	}
+	return inl
+}
+
+// noInline reports whether fd is marked //go:noinline.
+func noInline(fd *ast.FuncDecl) bool {
+	if fd.Doc == nil {
+		return false
+	}
+	for _, c := range fd.Doc.List {
+		if c.Text == "//go:noinline" {
+			return true
+		}
+	}
+	return false
+}
+
+// small reports whether stmt, the body of f, is one that
+// its calls can be replaced with, and records it in f.
+func (f *inlineFunc) small(info *types.Info, stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		if len(s.Results) != 1 || f.sig.Results().Len() != 1 || isValueType(f.sig.Results().At(0).Type()) {
+			// a struct or array returned is a copy.
+			return false
+		}
+		f.result = s.Results[0]
+		return f.simple(info, s.Results[0])
+	case *ast.AssignStmt:
+		if f.recv == nil || f.sig.Results().Len() != 0 || s.Tok != token.ASSIGN || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			return false
+		}
+		if _, ok := f.recv.Type().(*types.Pointer); !ok {
+			// sets a field of a copy.
+			return false
+		}
+		lhs, ok := s.Lhs[0].(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		sel := info.Selections[lhs]
+		if id, ok := lhs.X.(*ast.Ident); !ok || info.Uses[id] != f.recv || sel == nil || sel.Kind() != types.FieldVal || len(sel.Index()) != 1 {
+			return false
+		}
+		f.assign = s
+		return f.simple(info, s.Rhs[0])
+	}
+	return false
+}
+
+// simple reports whether x is an expression that a call of
+// f can be replaced with.
+func (f *inlineFunc) simple(info *types.Info, x ast.Expr) bool {
+	n := 0
+	return f.simpleExpr(info, x, &n) && n <= inlineMaxNodes
+}
+
+// simpleExpr is simple, counting the nodes of x in n.
+func (f *inlineFunc) simpleExpr(info *types.Info, x ast.Expr, n *int) bool {
+	*n++
+	if info.Types[x].Value != nil {
+		// translated as its value.
+		return true
+	}
+	switch e := x.(type) {
+	case *ast.ParenExpr:
+		return f.simpleExpr(info, e.X, n)
+	case *ast.BinaryExpr:
+		return f.simpleExpr(info, e.X, n) && f.simpleExpr(info, e.Y, n)
+	case *ast.UnaryExpr:
+		return e.Op != token.AND && e.Op != token.ARROW && f.simpleExpr(info, e.X, n)
+	case *ast.StarExpr:
+		return f.simpleExpr(info, e.X, n)
+	case *ast.Ident:
+		return f.param(info.Uses[e]) || f.global(info.Uses[e])
+	case *ast.SelectorExpr:
+		sel := info.Selections[e]
+		if sel == nil {
+			// a qualified identifier.
+			return isPackageLevel(info.Uses[e.Sel])
+		}
+		return sel.Kind() == types.FieldVal && len(sel.Index()) == 1 && f.simpleExpr(info, e.X, n)
+	case *ast.CallExpr:
+		// only a conversion.
+		fun := astutil.RemoveParens(e.Fun)
+		if id, ok := fun.(*ast.Ident); ok {
+			f.global(info.Uses[id])
+		}
+		return len(e.Args) == 1 && astutil.IsTypeExpr(fun, info) && f.simpleExpr(info, e.Args[0], n)
+	}
+	return false
+}
+
+// global reports whether obj is package-level, and records
+// it in f.globals if so.
+func (f *inlineFunc) global(obj types.Object) bool {
+	if !isPackageLevel(obj) {
+		return false
+	}
+	f.globals = append(f.globals, obj)
+	return true
+}
+
+// param reports whether obj is the receiver or a
+// parameter of f.
+func (f *inlineFunc) param(obj types.Object) bool {
+	if obj == nil {
+		return false
+	}
+	if f.recv != nil && obj == types.Object(f.recv) {
+		return true
+	}
+	for _, p := range f.params {
+		if obj == types.Object(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPackageLevel reports whether obj is declared at the top
+// of a package, or is predeclared.
+func isPackageLevel(obj types.Object) bool {
+	if obj == nil {
+		return false
+	}
+	if obj.Parent() == types.Universe {
+		return true
+	}
+	return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope()
+}
+
+// isValueType reports whether t is a struct or an array,
+// which Go copies when it is passed or returned.
+func isValueType(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
+
+// inlineTarget returns the func that call calls, and the
+// Lua of each of the values it binds the func's receiver
+// and parameters to, if call can be inlined.
+func (c *funcContext) inlineTarget(call *ast.CallExpr) (*inlineFunc, map[types.Object]string, bool) {
+	if c.p.inline == nil || call.Ellipsis.IsValid() {
+		return nil, nil, false
+	}
+	var fn *types.Func
+	var recv ast.Expr
+	switch fun := astutil.RemoveParens(call.Fun).(type) {
+	case *ast.Ident:
+		fn, _ = c.p.Uses[fun].(*types.Func)
+	case *ast.SelectorExpr:
+		sel, ok := c.p.SelectionOf(fun)
+		if !ok {
+			fn, _ = c.p.Uses[fun.Sel].(*types.Func)
+			break
+		}
+		if sel.Kind() != types.MethodVal || len(sel.Index()) != 1 {
+			return nil, nil, false
+		}
+		fn, _ = sel.Obj().(*types.Func)
+		recv = fun.X
+	}
+	f := c.p.inline[fn]
+	if f == nil || (f.recv == nil) != (recv == nil) || len(call.Args) != len(f.params) || c.hides(call, f) {
+		return nil, nil, false
+	}
+	args := make(map[types.Object]string, len(f.params)+1)
+	if recv != nil {
+		if !c.pure(recv) {
+			return nil, nil, false
+		}
+		// p.M() with p a T, for a method of *T, is
+		// (&p).M(), and with p a *T, for a method of T,
+		// (*p).M(): both the same table, if T is a struct.
+		rt := c.p.TypeOf(recv)
+		if !types.Identical(rt, f.recv.Type()) && !isStructOrPointerToOne(rt) {
+			return nil, nil, false
+		}
+		args[f.recv] = c.translateExpr(recv, nil).StringWithParens()
+	}
+	for i, arg := range call.Args {
+		if !c.pure(arg) {
+			return nil, nil, false
+		}
+		args[f.params[i]] = c.translateImplicitConversion(arg, f.params[i].Type()).StringWithParens()
+	}
+	return f, args, true
+}
+
+// hides reports whether a name declared where call is hides
+// a package-level one that the body of f uses.
+func (c *funcContext) hides(call *ast.CallExpr, f *inlineFunc) bool {
+	if len(f.globals) == 0 {
+		return false
+	}
+	scope := c.p.Pkg.Scope().Innermost(call.Pos())
+	if scope == nil {
+		return true
+	}
+	for _, obj := range f.globals {
+		if _, found := scope.LookupParent(obj.Name(), call.Pos()); found != obj {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineCall returns the Lua of call with the body of the
+// func it calls in its place, if it can be inlined.
+func (c *funcContext) inlineCall(call *ast.CallExpr) (*expression, bool) {
+	f, args, ok := c.inlineTarget(call)
+	if !ok || f.result == nil {
+		return nil, false
+	}
+	prev := c.inlineArgs
+	c.inlineArgs = args
+	defer func() { c.inlineArgs = prev }()
+	x := c.translateImplicitConversion(f.result, f.sig.Results().At(0).Type())
+	return c.formatExpr("(%s)", x), true
+}
+
+// inlineSetter returns the Lua of the statement call, with
+// the assignment of the method it calls in its place, if it
+// can be inlined.
+func (c *funcContext) inlineSetter(call *ast.CallExpr) (string, bool) {
+	f, args, ok := c.inlineTarget(call)
+	if !ok || f.assign == nil {
+		return "", false
+	}
+	prev := c.inlineArgs
+	c.inlineArgs = args
+	defer func() { c.inlineArgs = prev }()
+	return c.translateAssign(f.assign.Lhs[0], f.assign.Rhs[0], false), true
+}
+
+// inlineArg returns the Lua of the value that obj, a
+// parameter of a func being inlined, is bound to.
+func (c *funcContext) inlineArg(obj types.Object) (*expression, bool) {
+	if obj == nil || c.inlineArgs == nil {
+		return nil, false
+	}
+	arg, ok := c.inlineArgs[obj]
+	if !ok {
+		return nil, false
+	}
+	return c.formatExpr("%s", arg), true
+}
+
+// pure reports whether evaluating x more than once, or not
+// at all, is the same as evaluating it once: whether it is
+// a constant, a name, or a field of one.
+func (c *funcContext) pure(x ast.Expr) bool {
+	x = astutil.RemoveParens(x)
+	if c.p.Types[x].Value != nil {
+		return true
+	}
+	switch e := x.(type) {
+	case *ast.Ident:
+		switch c.p.Uses[e].(type) {
+		case *types.Var, *types.Const, *types.Nil:
+			return true
+		}
+	case *ast.SelectorExpr:
+		sel, ok := c.p.SelectionOf(e)
+		if !ok {
+			_, isVar := c.p.Uses[e.Sel].(*types.Var)
+			return isVar
+		}
+		return sel.Kind() == types.FieldVal && c.pure(e.X)
+	}
+	return false
+}
+
+// isStructOrPointerToOne reports whether t is a struct, or
+// a pointer to one.
+func isStructOrPointerToOne(t types.Type) bool {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
@@ -0,0 +1,109 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1405SmallFuncsAreInlinedInAWholeProgram(t *testing.T) {
+
+	cv.Convey("in a program translated at once, calls of getters, setters and one-line funcs are replaced by their bodies, unless marked //go:noinline or given an argument that is a call; at the prompt, nothing is inlined", t, func() {
+		src := `
+type Point struct{ x, y float64 }
+
+func (p *Point) X() float64     { return p.x }
+func (p *Point) SetX(x float64) { p.x = x }
+func (p Point) Y() float64      { return p.y }
+
+func sq(x float64) float64 { return x * x }
+
+//go:noinline
+func cube(x float64) float64 { return x * x * x }
+
+func three() float64 { return 3 }
+
+func run() (a, b, c, d float64) {
+	p := &Point{1, 2}
+	q := Point{3, 4}
+	p.SetX(5)
+	q.SetX(-6)
+	a = p.X() - q.X() + p.Y() + q.Y()
+	b = sq(a)
+	c = sq(three())
+	d = cube(2)
+	return
+}
+
+a, b, c, d := run()
+`
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		it.inc.inline = true
+		tr, err := it.Translate(src)
+		panicOn(err)
+		cv.So(tr, cv.ShouldContainSubstring, "p.x = 5;")
+		cv.So(tr, cv.ShouldContainSubstring, "q.x = -6;")
+		cv.So(tr, cv.ShouldContainSubstring, "(p.x)) - ((q.x))")
+		cv.So(tr, cv.ShouldContainSubstring, "b = (((a) * (a)));")
+		cv.So(tr, cv.ShouldContainSubstring, "c = sq((3));")
+		cv.So(tr, cv.ShouldContainSubstring, "cube(2)")
+
+		it2, err := NewInterp(nil)
+		panicOn(err)
+		defer it2.Close()
+		it2.inc.inline = true
+		panicOn(it2.Eval(src))
+		LuaMustFloat64(it2.lvm, "a", 17)
+		LuaMustFloat64(it2.lvm, "b", 289)
+		LuaMustFloat64(it2.lvm, "c", 9)
+		LuaMustFloat64(it2.lvm, "d", 8)
+
+		// at the prompt, a func redeclared later is still
+		// what its callers call.
+		it3, err := NewInterp(nil)
+		panicOn(err)
+		defer it3.Close()
+		tr, err = it3.Translate(src)
+		panicOn(err)
+		cv.So(strings.Count(tr, ":SetX("), cv.ShouldEqual, 2)
+		panicOn(it3.Eval("func one() int { return 1 }\nfunc two() int { return one() + one() }"))
+		panicOn(it3.Eval("func one() int { return 10 }\ne := two()"))
+		LuaMustInt64(it3.lvm, "e", 20)
+	})
+
+	cv.Convey("a call is not inlined where a local of the caller hides a package-level name that the func uses", t, func() {
+		src := `
+var scale = 2
+
+func f(x int) int { return x * scale }
+
+func g() int {
+	scale := 3
+	return f(1) + scale
+}
+
+func h() int { return f(1) }
+
+r1 := g()
+r2 := h()
+`
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		it.inc.inline = true
+		tr, err := it.Translate(src)
+		panicOn(err)
+		cv.So(strings.Count(tr, "f(1LL)"), cv.ShouldEqual, 1)
+
+		it2, err := NewInterp(nil)
+		panicOn(err)
+		defer it2.Close()
+		it2.inc.inline = true
+		panicOn(it2.Eval(src))
+		LuaMustInt64(it2.lvm, "r1", 5)
+		LuaMustInt64(it2.lvm, "r2", 2)
+	})
+}
@@ -0,0 +1,36 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1329EachInputIsItsOwnFile(t *testing.T) {
+
+	cv.Convey(`each input is parsed as its own file, repl[N], and both type errors and run time errors are located by that name and the line within the input`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`a := 1`))
+		_, err = it.Translate("\nc := \"x\" + 1")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "repl[2]:2:6: cannot convert")
+
+		panicOn(it.Eval(`func f(n int) int {
+	if n == 0 {
+		panic("boom")
+	}
+	return n
+}`))
+		err = it.Eval("x := 2\ny := f(0)")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEqual, "panic: boom\n\ngoroutine 3 [running]:\nmain.f()\n\trepl[3]:3\nmain.repl[4]()\n\trepl[4]:2")
+
+		// an error raised in the prelude is placed by its stack.
+		err = it.Eval("var g func()\n\ng()")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEndWith, "\n\nmain.repl[5]()\n\trepl[5]:3")
+	})
+}
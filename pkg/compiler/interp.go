@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Interp is one isolated gijit interpreter: it owns
+// its own LuaJIT state, its own incremental type
+// checker, and its own package scope tree.
+//
+// Any number of Interps may live in the same host
+// process, for example one per notebook in a
+// multi-tenant server. Eval and friends serialize
+// callers on the Interp's own mutex, so one Interp
+// may be shared between goroutines, and different
+// Interps never block each other.
+type Interp struct {
+	cfg *GIConfig
+	lvm *LuaVm
+	inc *IncrState
+
+	mut       sync.Mutex
+	evalCount int
+	closed    bool
+
+	// srcLog holds every input run so far, for
+	// SaveImage. baseNames are the names in the
+	// package scope before the first input.
+	srcLog    []string
+	baseNames map[string]bool
+
+	// lastStats is measured when cfg.Stats is set.
+	lastStats EvalStats
+
+	// lastDiff is what the last Eval changed in scope.
+	lastDiff ScopeDiff
+
+	prof profState
+
+	// outputHooked is set once print is made to write
+	// through Go; see SetOutput.
+	outputHooked bool
+}
+
+// NewInterp starts a fresh LuaJIT vm with the prelude
+// loaded, and a fresh type checker on top of it.
+// cfg may be nil for the defaults. The cfg is copied,
+// so one GIConfig can be used to start several Interps.
+// If cfg.Policy is set, the Lua standard library functions
+// it denies are removed from the new vm. If cfg.Deterministic
+// is set, the vm's map iteration and random numbers are fixed.
+// Its translations carry a source map, so that Lua stacks
+// can be shown as the Go functions and lines they came from.
+func NewInterp(cfg *GIConfig) (*Interp, error) {
+	var mycfg GIConfig
+	if cfg == nil {
+		mycfg = *NewGIConfig()
+	} else {
+		mycfg = *cfg
+	}
+	return newInterp(&mycfg)
+}
+
+// newInterp is NewInterp, but with cfg its own, uncopied,
+// for the REPL to share.
+func newInterp(cfg *GIConfig) (*Interp, error) {
+	lvm, err := NewLuaVmWithPrelude(cfg)
+	if err != nil {
+		return nil, err
+	}
+	inc := NewIncrState(lvm, cfg)
+	inc.srcMap = &luaSourceMap{}
+	setup := cfg.Policy.luaLockdown()
+	if !cfg.NoPrelude {
+		setup += showLimitsLua(cfg.ShowMaxDepth, cfg.ShowMaxWidth)
+	}
+	if cfg.Deterministic {
+		setup += fmt.Sprintf("__gi_deterministic = true; __builtin_math.randomseed(%d);\n", DeterministicSeed)
+	}
+	if setup != "" {
+		if err := LuaRun(lvm, setup, false); err != nil {
+			lvm.Close()
+			return nil, err
+		}
+	}
+	if err := inc.addReplBuiltins(); err != nil {
+		lvm.Close()
+		return nil, err
+	}
+	baseNames := make(map[string]bool)
+	for _, name := range inc.pkgScope().Names() {
+		baseNames[name] = true
+	}
+	return &Interp{
+		cfg:       cfg,
+		lvm:       lvm,
+		inc:       inc,
+		baseNames: baseNames,
+	}, nil
+}
+
+// Close shuts down the Interp's LuaJIT vm.
+// The Interp cannot be used afterwards.
+func (it *Interp) Close() {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return
+	}
+	it.closed = true
+	if it.prof.running {
+		// stop sampling a lua_State about to be freed.
+		LuaRun(it.lvm, `__gi_profStop()`, false)
+		it.releaseProfiler()
+	}
+	it.lvm.Close()
+}
+
+// Config returns the Interp's private copy of its configuration.
+func (it *Interp) Config() *GIConfig {
+	return it.cfg
+}
+
+// EvalCount returns the number of inputs evaluated so far.
+func (it *Interp) EvalCount() int {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.evalCount
+}
+
+// Warnings returns, and clears, the warnings of the inputs
+// since the last call: one for each divergence from gc, of
+// the catalog in Divergences, the first time an input
+// touches it.
+func (it *Interp) Warnings() []string {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	w := it.inc.warnings
+	it.inc.warnings = nil
+	return w
+}
+
+// Translate type checks the Go source src and returns
+// its Lua translation, without running it. Definitions in
+// src are recorded in the Interp's scope, just as with Eval.
+func (it *Interp) Translate(src string) (string, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return "", fmt.Errorf("Interp is closed")
+	}
+	return translateAndCatchPanic(it.inc, []byte(src))
+}
+
+// Eval type checks, translates, and runs src, a complete
+// sequence of Go statements and declarations.
+// Both compile errors and run time errors are returned.
+// The Interp's per-eval resource limits apply; see EvalContext.
+func (it *Interp) Eval(src string) error {
+	return it.EvalContext(context.Background(), src)
+}
+
+// lastEvalError fetches the error, if any, recorded by
+// __errHandlerForEval during the most recent __eval. Its
+// locations, and the stack it was raised on, are given
+// in Go, as the input and line, repl[N]:L, they were
+// translated from. The caller must hold it.mut.
+func (it *Interp) lastEvalError() error {
+	t := it.lvm.goro.newTicket("", false)
+	t.gettyp = GetString
+	t.varname["__lastEvalErr"] = nil
+	if err := t.Do(); err != nil {
+		return err
+	}
+	msg, _ := t.varname["__lastEvalErr"].(string)
+	if strings.TrimSpace(msg) == "" {
+		return nil
+	}
+	var b strings.Builder
+	stack := it.inc.srcMap.goStack(luaGlobalString(it.lvm, "__lastEvalStack"))
+	if goid := luaGlobalString(it.lvm, "__lastEvalPanicGoid"); goid != "" {
+		// an uncaught panic, shown as gc shows one: the
+		// value, then the goroutine it unwound.
+		fmt.Fprintf(&b, "%s\n\ngoroutine %s [running]:\n", msg, goid)
+		writeStack(&b, stack)
+		return errors.New(strings.TrimSuffix(b.String(), "\n"))
+	}
+	fmt.Fprintf(&b, "run error: %s", it.inc.srcMap.goLocs(msg))
+	if len(stack) > 0 {
+		b.WriteString("\n\n")
+		writeStack(&b, stack)
+	}
+	return errors.New(strings.TrimSuffix(b.String(), "\n"))
+}
@@ -0,0 +1,207 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1312MultipleIsolatedInterps(t *testing.T) {
+
+	cv.Convey(`two Interps in one process have separate scopes and separate LuaJIT states`, t, func() {
+
+		a, err := NewInterp(nil)
+		panicOn(err)
+		defer a.Close()
+		b, err := NewInterp(nil)
+		panicOn(err)
+		defer b.Close()
+
+		panicOn(a.Eval(`x := 1; type T struct{ A int }`))
+		panicOn(b.Eval(`x := "hello"; type T struct{ B string }`))
+
+		LuaMustInt64(a.lvm, "x", 1)
+		LuaMustString(b.lvm, "x", "hello")
+
+		// each checker only knows its own T
+		panicOn(a.Eval(`t := T{A: 2}; y := t.A`))
+		LuaMustInt64(a.lvm, "y", 2)
+		_, err = b.Translate(`t := T{A: 2}`)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		cv.So(a.EvalCount(), cv.ShouldEqual, 2)
+		cv.So(b.EvalCount(), cv.ShouldEqual, 1)
+	})
+
+	cv.Convey(`Interps can be driven concurrently from different goroutines`, t, func() {
+
+		const n = 4
+		interps := make([]*Interp, n)
+		for i := range interps {
+			it, err := NewInterp(nil)
+			panicOn(err)
+			defer it.Close()
+			interps[i] = it
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := range interps {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					src := fmt.Sprintf(`v%d := %d * %d`, j, i, j)
+					if err := interps[i].Eval(src); err != nil {
+						errs[i] = err
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		for i := range interps {
+			cv.So(errs[i], cv.ShouldBeNil)
+			LuaMustInt64(interps[i].lvm, "v9", int64(i*9))
+		}
+	})
+
+	cv.Convey(`Interp.Eval reports run time errors`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(`a := []int{1}; b := a[3]`)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// and the session survives
+		panicOn(it.Eval(`c := 5`))
+		LuaMustInt64(it.lvm, "c", 5)
+	})
+}
+
+func Test1313EvalContextCancelsAndRollsBack(t *testing.T) {
+
+	cv.Convey(`EvalContext aborts an infinite loop when its context times out, and undoes the partial definitions`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`x := 1`))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		err = it.EvalContext(ctx, `x := "two"; y := 3; for { y++ }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+
+		// the redefined x is back to the int 1, and
+		// y is gone from the checker.
+		LuaMustInt64(it.lvm, "x", 1)
+		_, err = it.Translate(`z := y`)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// and the session carries on as usual.
+		panicOn(it.EvalContext(context.Background(), `y := 10; w := x + y`))
+		LuaMustInt64(it.lvm, "w", 11)
+	})
+
+	cv.Convey(`a deferred recover does not stop a cancelled eval`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		t0 := time.Now()
+		err = it.EvalContext(ctx, `func spin() { defer func() { recover() }(); for {} }; spin(); for {}`)
+		cv.So(time.Since(t0), cv.ShouldBeLessThan, 5*time.Second)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause == context.DeadlineExceeded, cv.ShouldBeTrue)
+
+		panicOn(it.Eval(`v := 1`))
+		LuaMustInt64(it.lvm, "v", 1)
+	})
+}
+
+func Test1314PerEvalResourceLimits(t *testing.T) {
+
+	cv.Convey(`an Interp with MaxEvalInstructions stops a runaway loop, and rolls back`, t, func() {
+		cfg := NewGIConfig()
+		cfg.MaxEvalInstructions = 1000000
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		// small evals fit in the budget.
+		panicOn(it.Eval(`n := 0; for i := 0; i < 100; i++ { n += i }`))
+		LuaMustInt64(it.lvm, "n", 4950)
+
+		err = it.Eval(`m := 0; for { m++ }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause, cv.ShouldEqual, ErrInstructionLimit)
+		_, err = it.Translate(`q := m`)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// the budget is per eval, not cumulative.
+		panicOn(it.Eval(`n = 7`))
+		LuaMustInt64(it.lvm, "n", 7)
+	})
+
+	cv.Convey(`an Interp with MaxEvalHeapKB stops an eval that keeps allocating`, t, func() {
+		cfg := NewGIConfig()
+		cfg.MaxEvalHeapKB = 8 * 1024
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(`s := []string{}; for { s = append(s, "hello") }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause, cv.ShouldEqual, ErrHeapLimit)
+
+		// garbage does not count against the budget.
+		panicOn(it.Eval(`k := 0; for i := 0; i < 200000; i++ { k += len([]int{i, i}) }`))
+		LuaMustInt64(it.lvm, "k", 400000)
+	})
+
+	cv.Convey(`an Interp with MaxEvalTime stops an eval after that long`, t, func() {
+		cfg := NewGIConfig()
+		cfg.MaxEvalTime = 100 * time.Millisecond
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(`for {}`)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause == context.DeadlineExceeded, cv.ShouldBeTrue)
+	})
+
+	cv.Convey(`user code that recovers the limit's error is still stopped`, t, func() {
+		cfg := NewGIConfig()
+		cfg.MaxEvalInstructions = 100000
+		cfg.MaxEvalTime = 2 * time.Second
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		t0 := time.Now()
+		err = it.Eval(`n := 0; func spin() { defer func() { recover() }(); for { n++ } }; spin(); for i := 0; i < 200000000; i++ { n++ }`)
+		cv.So(time.Since(t0), cv.ShouldBeLessThan, 5*time.Second)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause, cv.ShouldEqual, ErrInstructionLimit)
+
+		panicOn(it.Eval(`k := 3`))
+		LuaMustInt64(it.lvm, "k", 3)
+	})
+}
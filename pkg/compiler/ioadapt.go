@@ -0,0 +1,174 @@
+package compiler
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"sync"
+
+	golua "github.com/glycerine/golua/lua"
+	"github.com/glycerine/luar"
+)
+
+// luaIO lets Go code take a value of an interpreted type, one
+// with Read, Write, or Close methods, as an io.Reader,
+// io.Writer, io.Closer, or one of their combinations: a
+// bufio.Scanner over a user's reader, or io.Copy into a
+// user's writer, calls the methods in Lua through the
+// helpers in prelude/zio.lua.
+//
+// The methods run on the Lua state, so they must be called
+// while the Go code that received the value has the Lua
+// state, during the call from Lua; a Go goroutine that keeps
+// the value, as exec.Cmd does with a Stdin, cannot use it.
+type luaIO struct {
+	L   *golua.State
+	ref int
+}
+
+// the interfaces luaIO stands in for, and the Lua methods
+// each needs.
+var luaIOInterfaces = map[reflect.Type]string{
+	reflect.TypeOf((*io.Reader)(nil)).Elem():          "Read",
+	reflect.TypeOf((*io.Writer)(nil)).Elem():          "Write",
+	reflect.TypeOf((*io.Closer)(nil)).Elem():          "Close",
+	reflect.TypeOf((*io.ReadCloser)(nil)).Elem():      "Read,Close",
+	reflect.TypeOf((*io.WriteCloser)(nil)).Elem():     "Write,Close",
+	reflect.TypeOf((*io.ReadWriter)(nil)).Elem():      "Read,Write",
+	reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem(): "Read,Write,Close",
+}
+
+// luaIOUnref holds the references of collected luaIOs, to
+// be dropped on the Lua state's own goroutine, which
+// finalizers do not run on.
+var luaIOUnref struct {
+	mu   sync.Mutex
+	refs map[*golua.State][]int
+}
+
+func init() {
+	luar.RegisterInterfaceAdapter(adaptLuaIO)
+}
+
+// adaptLuaIO is the luar.InterfaceAdapter for the io
+// interfaces.
+func adaptLuaIO(L *golua.State, idx int, t reflect.Type) (reflect.Value, bool) {
+	methods, ok := luaIOInterfaces[t]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	freeLuaIORefs(L)
+	if idx < 0 {
+		idx = L.GetTop() + idx + 1
+	}
+
+	L.GetGlobal("__gi_ioHas")
+	L.PushValue(idx)
+	L.PushString(methods)
+	if err := L.Call(2, 1); err != nil {
+		L.Pop(1)
+		return reflect.Value{}, false
+	}
+	has := L.ToBoolean(-1)
+	L.Pop(1)
+	if !has {
+		return reflect.Value{}, false
+	}
+
+	L.PushValue(idx)
+	r := &luaIO{L: L, ref: L.Ref(golua.LUA_REGISTRYINDEX)}
+	runtime.SetFinalizer(r, func(r *luaIO) {
+		luaIOUnref.mu.Lock()
+		if luaIOUnref.refs == nil {
+			luaIOUnref.refs = make(map[*golua.State][]int)
+		}
+		luaIOUnref.refs[r.L] = append(luaIOUnref.refs[r.L], r.ref)
+		luaIOUnref.mu.Unlock()
+	})
+	return reflect.ValueOf(r), true
+}
+
+func freeLuaIORefs(L *golua.State) {
+	luaIOUnref.mu.Lock()
+	refs := luaIOUnref.refs[L]
+	delete(luaIOUnref.refs, L)
+	luaIOUnref.mu.Unlock()
+	for _, ref := range refs {
+		L.Unref(golua.LUA_REGISTRYINDEX, ref)
+	}
+}
+
+// call calls the Lua helper fn on the object and the
+// argument pushed by push, leaving nres results.
+func (r *luaIO) call(fn string, push func(), nres int) error {
+	L := r.L
+	L.GetGlobal(fn)
+	L.RawGeti(golua.LUA_REGISTRYINDEX, r.ref)
+	nargs := 1
+	if push != nil {
+		push()
+		nargs++
+	}
+	return L.Call(nargs, nres)
+}
+
+// toError converts the Lua error value at idx: a Go error
+// passes through, so io.EOF stays io.EOF, and an interpreted
+// one is known by its Error method.
+func (r *luaIO) toError(idx int) error {
+	L := r.L
+	if idx < 0 {
+		idx = L.GetTop() + idx + 1
+	}
+	switch {
+	case L.IsNil(idx):
+		return nil
+	case L.IsUserdata(idx):
+		var err error
+		if _, cerr := luar.LuaToGo(L, idx, &err); cerr == nil && err != nil {
+			return err
+		}
+	}
+	L.GetGlobal("__gi_ioErrorString")
+	L.PushValue(idx)
+	if err := L.Call(1, 1); err != nil {
+		L.Pop(1)
+		return err
+	}
+	msg := L.ToString(-1)
+	L.Pop(1)
+	return errors.New(msg)
+}
+
+func (r *luaIO) Read(p []byte) (n int, err error) {
+	L := r.L
+	if err := r.call("__gi_ioRead", func() { L.PushInteger(int64(len(p))) }, 2); err != nil {
+		return 0, err
+	}
+	defer L.Pop(2)
+	n = copy(p, L.ToString(-2))
+	return n, r.toError(-1)
+}
+
+func (r *luaIO) Write(p []byte) (n int, err error) {
+	L := r.L
+	if err := r.call("__gi_ioWrite", func() { L.PushString(string(p)) }, 2); err != nil {
+		return 0, err
+	}
+	defer L.Pop(2)
+	n = int(L.ToNumber(-2))
+	err = r.toError(-1)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+func (r *luaIO) Close() error {
+	if err := r.call("__gi_ioClose", nil, 1); err != nil {
+		return err
+	}
+	defer r.L.Pop(1)
+	return r.toError(-1)
+}
@@ -0,0 +1,104 @@
+package compiler
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/glycerine/luar"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+const ioadaptTestTypes = `
+type lines struct { s string; pos int }
+func (r *lines) Read(p []byte) (int, error) {
+	if r.pos >= len(r.s) {
+		return 0, &eof{}
+	}
+	n := copy(p, r.s[r.pos:])
+	r.pos += n
+	return n, nil
+}
+type eof struct{}
+func (e *eof) Error() string { return "the end" }
+
+type upper struct { got string; closed bool }
+func (w *upper) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	for i, c := range p {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		b[i] = c
+	}
+	w.got += string(b)
+	return len(p), nil
+}
+func (w *upper) Close() error { w.closed = true; return nil }
+`
+
+func Test1344IoInterfacesOnInterpretedValues(t *testing.T) {
+
+	cv.Convey("an interpreted type with a Read method is an io.Reader to Go, and a bufio.Scanner reads it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(ioadaptTestTypes))
+		panicOn(it.Eval(`r := &lines{s: "one\ntwo\nthree"}`))
+
+		vm := it.lvm.vm
+		vm.GetGlobal("r")
+		var r io.Reader
+		_, err = luar.LuaToGo(vm, -1, &r)
+		vm.Pop(1)
+		panicOn(err)
+
+		sc := bufio.NewScanner(r)
+		var got []string
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		cv.So(got, cv.ShouldResemble, []string{"one", "two", "three"})
+		// an interpreted error arrives with its message.
+		cv.So(sc.Err().Error(), cv.ShouldEqual, "the end")
+		panicOn(it.Eval(`pos := r.pos`))
+		LuaMustInt64(it.lvm, "pos", 13)
+	})
+
+	cv.Convey("an interpreted type with Write and Close methods is an io.WriteCloser to Go", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(ioadaptTestTypes))
+		panicOn(it.Eval(`w := &upper{}`))
+
+		vm := it.lvm.vm
+		vm.GetGlobal("w")
+		var w io.WriteCloser
+		_, err = luar.LuaToGo(vm, -1, &w)
+		vm.Pop(1)
+		panicOn(err)
+
+		n, err := io.Copy(w, strings.NewReader("hello, gi"))
+		panicOn(err)
+		cv.So(n, cv.ShouldEqual, 9)
+		panicOn(w.Close())
+		panicOn(it.Eval(`got := w.got; closed := w.closed`))
+		LuaMustString(it.lvm, "got", "HELLO, GI")
+		LuaMustBool(it.lvm, "closed", true)
+
+		// a reader is not a writer.
+		vm.GetGlobal("w")
+		var rd io.Reader
+		_, err = luar.LuaToGo(vm, -1, &rd)
+		vm.Pop(1)
+		if err == nil {
+			_, err = ioutil.ReadAll(rd)
+		}
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
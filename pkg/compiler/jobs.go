@@ -0,0 +1,112 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// backgroundTick is how often the prompt
+	// lets background goroutines run.
+	backgroundTick = 20 * time.Millisecond
+
+	// backgroundSlice is how many goroutines
+	// are resumed on each tick.
+	backgroundSlice = 50
+)
+
+// SetBackgroundGoroutines sets whether goroutines keep
+// running in the background. When on, an eval returns as
+// soon as its input is done, and the goroutines it started
+// wait for RunBackground or Foreground to run them. When
+// off, the default, an eval also runs every goroutine it
+// wakes until all of them are done or blocked.
+func (it *Interp) SetBackgroundGoroutines(on bool) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	panicOn(LuaRun(it.lvm, fmt.Sprintf("__gi_backgroundGoroutines = %v", on), false))
+}
+
+// BackgroundDue reports whether a goroutine is ready to run.
+func (it *Interp) BackgroundDue() (bool, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if err := LuaRun(it.lvm, `__gi_bgDue = tostring(__gi_backgroundDue())`, false); err != nil {
+		return false, err
+	}
+	return luaGlobalString(it.lvm, "__gi_bgDue") == "true", nil
+}
+
+// RunBackground resumes at most n goroutines, each running
+// until it blocks, then reports whether more can run. The
+// error is that of a goroutine that failed.
+func (it *Interp) RunBackground(n int) (more bool, err error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if err := LuaRun(it.lvm, fmt.Sprintf(`__gi_bgDue = tostring(__gi_runBackground(%d))`, n), false); err != nil {
+		return false, err
+	}
+	return luaGlobalString(it.lvm, "__gi_bgDue") == "true", nil
+}
+
+// Foreground runs goroutines until goroutine id finishes
+// or blocks, or with id 0, until no goroutine can run.
+// Like EvalContext, it stops with an *ErrEvalCanceled if
+// ctx is canceled; the goroutine running then is dropped.
+func (it *Interp) Foreground(ctx context.Context, id int) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.runGuarded(ctx, fmt.Sprintf("__gi_foreground(%d)", id), false, nil, nil)
+}
+
+// jobLine sums up g on one line, for :jobs.
+func jobLine(g *Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%d] %-14s", g.ID, g.State)
+	if len(g.Stack) > 0 {
+		f := g.Stack[0]
+		fmt.Fprintf(&b, " %s (%s:%d)", f.Func, f.File, f.Line)
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(&b, ", started at %s:%d", g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	return b.String()
+}
+
+// watchBackground runs background goroutines while liner
+// waits at the prompt. Each tick is an interjection: what
+// the goroutines print goes above the prompt, and liner
+// then redraws the prompt and the line being typed. The
+// Interp, made behind the prompt, lets its goroutines run
+// in the background once it is ready; see startInterp.
+func (r *Repl) watchBackground() {
+	ch := make(chan func(clear func()))
+	r.prompter.prompter.SetInterjector(ch)
+	go func() {
+		tick := time.NewTicker(backgroundTick)
+		defer tick.Stop()
+		for range tick.C {
+			ch <- r.runBackground
+		}
+	}()
+}
+
+// runBackground is one tick of watchBackground.
+func (r *Repl) runBackground(clear func()) {
+	if !r.interpReady() {
+		return
+	}
+	due, err := r.interp.BackgroundDue()
+	if err == nil && !due {
+		return
+	}
+	clear()
+	if err == nil {
+		_, err = r.interp.RunBackground(backgroundSlice)
+	}
+	if err != nil {
+		fmt.Printf("goroutine error: '%v'\n", err)
+	}
+}
@@ -0,0 +1,78 @@
+package compiler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1326BackgroundGoroutinesRunBetweenInputs(t *testing.T) {
+
+	cv.Convey(`with background goroutines on, an input returns once it is done, and the goroutines it started run in slices, or in the foreground`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		it.SetBackgroundGoroutines(true)
+
+		panicOn(it.Eval(`sum := 0
+ch := make(chan int)
+go func() {
+	for i := 0; i < 100; i++ {
+		ch <- i
+	}
+}()
+go func() {
+	for i := 0; i < 100; i++ {
+		sum += <-ch
+	}
+}()`))
+		// nothing has run yet.
+		LuaMustInt64(it.lvm, "sum", 0)
+		due, err := it.BackgroundDue()
+		panicOn(err)
+		cv.So(due, cv.ShouldBeTrue)
+
+		more, err := it.RunBackground(4)
+		panicOn(err)
+		cv.So(more, cv.ShouldBeTrue)
+
+		panicOn(it.Foreground(context.Background(), 0))
+		LuaMustInt64(it.lvm, "sum", 4950)
+		due, err = it.BackgroundDue()
+		panicOn(err)
+		cv.So(due, cv.ShouldBeFalse)
+		gs, err := it.Goroutines()
+		panicOn(err)
+		cv.So(gs, cv.ShouldBeEmpty)
+
+		// a spinning goroutine can be stopped from the
+		// foreground, and the scheduler carries on.
+		panicOn(it.Eval(`spin := 0
+go func() {
+	for {
+		spin++
+	}
+}()`))
+		gs, err = it.Goroutines()
+		panicOn(err)
+		cv.So(len(gs), cv.ShouldEqual, 1)
+		cv.So(jobLine(&gs[0]), cv.ShouldStartWith, "[")
+		cv.So(jobLine(&gs[0]), cv.ShouldContainSubstring, "runnable")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err = it.Foreground(ctx, gs[0].ID)
+		_, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		gs, err = it.Goroutines()
+		panicOn(err)
+		cv.So(gs, cv.ShouldBeEmpty)
+
+		panicOn(it.Eval(`c2 := make(chan int, 1)
+go func() { c2 <- 7 }()
+got := <-c2`))
+		LuaMustInt64(it.lvm, "got", 7)
+	})
+}
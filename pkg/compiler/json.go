@@ -0,0 +1,75 @@
+package compiler
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowJSONSrc declares encoding/json to the type
+// checker. Encoding and decoding run in Lua, in
+// prelude/zjson.lua, from gi's type descriptors, which
+// carry each struct field's tag; the bodies here are
+// never run.
+//
+//	type Point struct {
+//		X int `json:"x"`
+//		Y int `json:"y,omitempty"`
+//	}
+//	b, err := json.Marshal(Point{X: 1}) // {"x":1}
+//
+// The errors are Go's, by their messages; they are not
+// of the types *SyntaxError and *UnmarshalTypeError.
+// There are no Encoder and Decoder.
+const shadowJSONSrc = `package json
+
+// Marshaler is implemented by types that encode
+// themselves.
+type Marshaler interface {
+	MarshalJSON() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode
+// themselves; UnmarshalJSON is given the value's JSON.
+type Unmarshaler interface {
+	UnmarshalJSON([]byte) error
+}
+
+// Marshal returns the JSON encoding of v.
+func Marshal(v interface{}) ([]byte, error) { return nil, nil }
+
+// MarshalIndent is Marshal, with each element on a line
+// of its own, starting with prefix and indented by
+// indent for each level of nesting.
+func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) { return nil, nil }
+
+// Unmarshal decodes the JSON data into the value that
+// v points to.
+func Unmarshal(data []byte, v interface{}) error { return nil }
+
+// Valid reports whether data is valid JSON.
+func Valid(data []byte) bool { return false }
+`
+
+var shadowJSON struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowJSONPackage returns the type checker's view of
+// encoding/json, shared by every Interp.
+func shadowJSONPackage() *types.Package {
+	shadowJSON.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "json.go", shadowJSONSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "encoding/json", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowJSON.pkg = pkg
+	})
+	return shadowJSON.pkg
+}
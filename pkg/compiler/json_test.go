@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1359StructTagsReachReflectAndJSON(t *testing.T) {
+
+	cv.Convey("struct tags are kept in gi's type descriptors, and reflect reads them", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval("import \"reflect\"\ntype User struct {\n\tName string `json:\"name\" db:\"user_name\"`\n\tAge  int    `json:\"age,omitempty\"`\n\tid   int\n}"))
+		panicOn(it.Eval(`t := reflect.TypeOf(User{})
+n := t.NumField()
+name := t.Name()
+isStruct := t.Kind() == reflect.Struct
+js := t.Field(0).Tag.Get("json")
+db, hasDB := t.Field(0).Tag.Lookup("db")
+_, hasXML := t.Field(1).Tag.Lookup("xml")
+f, found := t.FieldByName("Age")
+age := f.Tag.Get("json")
+ptrElem := reflect.TypeOf(&User{}).Elem().Name()
+direct := reflect.StructTag(` + "`a:\"1\" b:\"2\"`" + `).Get("b")`))
+		LuaMustInt64(it.lvm, "n", 3)
+		LuaMustString(it.lvm, "name", "User")
+		LuaMustBool(it.lvm, "isStruct", true)
+		LuaMustString(it.lvm, "js", "name")
+		LuaMustString(it.lvm, "db", "user_name")
+		LuaMustBool(it.lvm, "hasDB", true)
+		LuaMustBool(it.lvm, "hasXML", false)
+		LuaMustBool(it.lvm, "found", true)
+		LuaMustString(it.lvm, "age", "age,omitempty")
+		LuaMustString(it.lvm, "ptrElem", "User")
+		LuaMustString(it.lvm, "direct", "2")
+	})
+
+	cv.Convey("encoding/json names, omits, quotes and promotes fields as their tags say, both ways, with Go's output and errors", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval("import \"encoding/json\"\ntype Base struct {\n\tID int `json:\"id\"`\n}\ntype Item struct {\n\tBase\n\tName   string            `json:\"name\"`\n\tPrice  float64           `json:\"price,omitempty\"`\n\tCount  int               `json:\"count,string\"`\n\tSecret string            `json:\"-\"`\n\tTags   []string          `json:\"tags\"`\n\tAttrs  map[string]bool   `json:\"attrs,omitempty\"`\n\tRaw    []byte\n\tNext   *Base             `json:\"next,omitempty\"`\n\tnote   string\n}"))
+		panicOn(it.Eval(`b, err := json.Marshal(Item{Base: Base{ID: 7}, Name: "<a&b>", Count: 3, Secret: "x", Raw: []byte("hi"), note: "n"})
+out := string(b)
+ok := err == nil`))
+		LuaMustString(it.lvm, "out", `{"id":7,"name":"\u003ca\u0026b\u003e","count":"3","tags":null,"Raw":"aGk="}`)
+		LuaMustBool(it.lvm, "ok", true)
+
+		panicOn(it.Eval(`var item Item
+err = json.Unmarshal([]byte(` + "`" + `{"id": 9, "NAME": "w", "price": 2.5, "count": "12", "Secret": "s",
+	"tags": ["a", "b"], "attrs": {"on": true, "off": false}, "Raw": "aGk=", "next": {"id": 1}}` + "`" + `), &item)
+got := item.ID + item.Count + item.Next.ID
+name := item.Name + item.Tags[1] + item.Secret + string(item.Raw)
+price := item.Price
+on, off := item.Attrs["on"], item.Attrs["off"]
+ok = err == nil`))
+		LuaMustInt64(it.lvm, "got", 22)
+		LuaMustString(it.lvm, "name", "wbhi")
+		LuaMustFloat64(it.lvm, "price", 2.5)
+		LuaMustBool(it.lvm, "on", true)
+		LuaMustBool(it.lvm, "off", false)
+		LuaMustBool(it.lvm, "ok", true)
+
+		// each struct of a slice or map decodes into its own value.
+		panicOn(it.Eval(`var bases []Base
+var byName map[string]Base
+err = json.Unmarshal([]byte(` + "`" + `[{"id": 1}, {"id": 2}]` + "`" + `), &bases)
+err2 := json.Unmarshal([]byte(` + "`" + `{"a": {"id": 3}, "b": {"id": 4}}` + "`" + `), &byName)
+bases[0].ID += 10
+ok = err == nil && err2 == nil && bases[0].ID == 11 && bases[1].ID == 2 && byName["a"].ID == 3 && byName["b"].ID == 4`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		panicOn(it.Eval(`b, _ = json.MarshalIndent(map[string]interface{}{"b": []int{1, 2}, "a": 1.5e-7, "c": map[string]int{}}, "", "  ")
+indented := string(b)`))
+		LuaMustString(it.lvm, "indented", "{\n  \"a\": 1.5e-7,\n  \"b\": [\n    1,\n    2\n  ],\n  \"c\": {}\n}")
+
+		panicOn(it.Eval(`e1 := json.Unmarshal([]byte(` + "`" + `{"id": "nine"}` + "`" + `), &item).Error()
+e2 := json.Unmarshal([]byte(` + "`" + `{"id": 1,}` + "`" + `), &item).Error()
+e3 := json.Unmarshal([]byte(` + "`" + `[1, 2` + "`" + `), &item).Error()
+var small struct{ N int8 }
+e4 := json.Unmarshal([]byte(` + "`" + `{"N": 300}` + "`" + `), &small).Error()
+valid := json.Valid([]byte(` + "`" + `{"a": [true, null]}` + "`" + `))`))
+		LuaMustString(it.lvm, "e1", "json: cannot unmarshal string into Go struct field Item.id of type int")
+		LuaMustString(it.lvm, "e2", "invalid character '}' looking for beginning of object key string")
+		LuaMustString(it.lvm, "e3", "unexpected end of JSON input")
+		LuaMustString(it.lvm, "e4", "json: cannot unmarshal number 300 into Go struct field .N of type int8")
+		LuaMustBool(it.lvm, "valid", true)
+	})
+}
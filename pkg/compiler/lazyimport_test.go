@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// countingScope makes int vars, counting those it made.
+type countingScope struct {
+	pkg      *types.Package
+	names    []string
+	err      error
+	resolved []string
+}
+
+func (c *countingScope) Names() ([]string, error) { return c.names, c.err }
+
+func (c *countingScope) Resolve(name string) types.Object {
+	c.resolved = append(c.resolved, name)
+	return types.NewVar(token.NoPos, c.pkg, name, types.Typ[types.Int])
+}
+
+type onePackage struct{ pkg *types.Package }
+
+func (o onePackage) Import(path string) (*types.Package, error) { return o.pkg, nil }
+
+func checkAgainst(pkg *types.Package, src string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", src, 0)
+	panicOn(err)
+	conf := &types.Config{Importer: onePackage{pkg}}
+	_, _, err = conf.Check(nil, nil, "a", fset, []*ast.File{f}, nil, nil)
+	return err
+}
+
+func newCounted(names ...string) (*types.Package, *countingScope) {
+	pkg := types.NewPackage("lazy", "lazy")
+	c := &countingScope{pkg: pkg, names: names}
+	pkg.Scope().SetLazy(c)
+	pkg.MarkComplete()
+	return pkg, c
+}
+
+func Test1408LazyPackagesMakeTheirMembersOnFirstUse(t *testing.T) {
+
+	cv.Convey("a lazy package makes only the members that are used, when they are first looked up", t, func() {
+		pkg, c := newCounted("A", "B", "C")
+		cv.So(checkAgainst(pkg, "package a\nimport \"lazy\"\nvar x = lazy.A + lazy.A\n"), cv.ShouldBeNil)
+		cv.So(c.resolved, cv.ShouldResemble, []string{"A"})
+		cv.So(pkg.Scope().Names(), cv.ShouldResemble, []string{"A", "B", "C"})
+		cv.So(c.resolved, cv.ShouldResemble, []string{"A"})
+		cv.So(pkg.Scope().Lookup("D"), cv.ShouldBeNil)
+
+		// a dot import needs them all.
+		pkg, c = newCounted("A", "B", "C")
+		cv.So(checkAgainst(pkg, "package a\nimport . \"lazy\"\nvar x = B\n"), cv.ShouldBeNil)
+		cv.So(c.resolved, cv.ShouldResemble, []string{"A", "B", "C"})
+	})
+
+	cv.Convey("a member deleted before the package is read stays deleted, without reading it", t, func() {
+		pkg, c := newCounted("A", "B")
+		pkg.Scope().DeleteByName("B")
+		cv.So(c.resolved, cv.ShouldBeEmpty)
+		err := checkAgainst(pkg, "package a\nimport \"lazy\"\nvar x = lazy.B\n")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "B not declared by package lazy")
+		cv.So(pkg.Scope().Names(), cv.ShouldResemble, []string{"A"})
+	})
+
+	cv.Convey("a package that cannot be read says so where it is used", t, func() {
+		pkg, c := newCounted()
+		c.err = errors.New("could not import lazy (no Go here)")
+		err := checkAgainst(pkg, "package a\nimport \"lazy\"\nvar x = lazy.A\n")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "a.go:3:9: could not import lazy (no Go here)")
+	})
+
+	cv.Convey("a shadow package is imported lazily, with its types read ahead", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "regexp"`))
+		panicOn(it.Eval(`ok := regexp.MustCompile("a+").MatchString("caab")`))
+		LuaMustBool(it.lvm, "ok", true)
+		pkg := it.inc.CurPkg.importContext.Packages["regexp"]
+		cv.So(pkg.Path(), cv.ShouldEqual, "github.com/gijit/gi/pkg/compiler/shadow/regexp")
+		cv.So(pkg.Scope().Lookup("Regexp"), cv.ShouldNotBeNil)
+	})
+}
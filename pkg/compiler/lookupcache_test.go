@@ -0,0 +1,69 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1411LookupsAreForgottenWhenTheirEmbeddedChainChanges(t *testing.T) {
+
+	cv.Convey("a remembered field or method lookup is forgotten once a type it went through gets another method", t, func() {
+		pkg := types.NewPackage("a", "a")
+		newNamed := func(name string, u types.Type) *types.Named {
+			return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), u, nil)
+		}
+		in := newNamed("In", types.NewStruct(nil, nil))
+		out := newNamed("Out", types.NewStruct([]*types.Var{
+			types.NewField(token.NoPos, pkg, "In", in, true),
+		}, nil))
+		method := func(recv types.Type, name string) *types.Func {
+			sig := types.NewSignature(types.NewParam(token.NoPos, pkg, "i", recv), nil, nil, false)
+			return types.NewFunc(token.NoPos, pkg, name, sig)
+		}
+
+		obj, _, _ := types.LookupFieldOrMethod(out, false, pkg, "B")
+		cv.So(obj, cv.ShouldBeNil)
+		b := method(in, "B")
+		in.AddMethod(b)
+		obj, index, _ := types.LookupFieldOrMethod(out, false, pkg, "B")
+		cv.So(obj, cv.ShouldEqual, b)
+		cv.So(index, cv.ShouldResemble, []int{0, 0})
+		_, again, _ := types.LookupFieldOrMethod(out, false, pkg, "B")
+		cv.So(&again[0] == &index[0], cv.ShouldBeTrue)
+
+		// a pointer method needs an addressable Out, or a *Out.
+		c := method(types.NewPointer(in), "C")
+		in.AddMethod(c)
+		obj, _, indirect := types.LookupFieldOrMethod(out, false, pkg, "C")
+		cv.So(obj, cv.ShouldBeNil)
+		cv.So(indirect, cv.ShouldBeTrue)
+		obj, _, _ = types.LookupFieldOrMethod(out, true, pkg, "C")
+		cv.So(obj, cv.ShouldEqual, c)
+		obj, _, indirect = types.LookupFieldOrMethod(types.NewPointer(out), false, pkg, "C")
+		cv.So(obj, cv.ShouldEqual, c)
+		cv.So(indirect, cv.ShouldBeTrue)
+	})
+
+	cv.Convey("at the REPL, a method added to an embedded type, and an embedded type redefined, are selected anew", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval("type In struct{}\nfunc (i In) A() int { return 1 }\ntype Out struct{ In }\nvar o Out\na := o.A()"))
+		LuaMustInt64(it.lvm, "a", 1)
+
+		err = it.Eval("b := o.B()")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "has no field or method B")
+
+		panicOn(it.Eval("func (i In) B() int { return 2 }"))
+		panicOn(it.Eval("b := o.B()"))
+		LuaMustInt64(it.lvm, "b", 2)
+
+		panicOn(it.Eval("type In struct{ X int }\ntype Out struct{ In }\nvar o2 Out\no2.X = 4\nx := o2.X"))
+		LuaMustInt64(it.lvm, "x", 4)
+	})
+}
@@ -32,7 +32,10 @@ const reserveMainThread = false
 // to turn on -vv very verbose debug printing.
 var dbg = &verb.VerboseVerbose
 
+// nyc is loaded once, by whichever LuaVm starts first,
+// so that concurrent vms don't race on it.
 var nyc *time.Location
+var nycOnce sync.Once
 
 type LuaVm struct {
 	cfg *GIConfig
@@ -40,6 +43,9 @@ type LuaVm struct {
 
 	goro *Goro
 	mut  sync.Mutex
+
+	// intr lets EvalContext interrupt a running eval.
+	intr evalInterrupt
 }
 
 func (lvm *LuaVm) Close() {
@@ -54,17 +60,9 @@ func (lvm *LuaVm) Close() {
 func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 
 	var vm *golua.State
-	var useStaticPrelude bool
+	useStaticPrelude := usesStaticPrelude(cfg)
 	lvm = &LuaVm{}
 
-	// cfg == nil means under test.
-	// cfg.Dev means `gi -d` was invoked.
-	if cfg == nil || cfg.Dev || cfg.IsTestMode {
-		useStaticPrelude = false
-	} else {
-		useStaticPrelude = true
-	}
-
 	if cfg == nil {
 		cfg = NewGIConfig()
 	}
@@ -115,8 +113,8 @@ func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 	// load prelude
 	var files []string
 	if useStaticPrelude {
-		if !cfg.Quiet {
-			fmt.Printf("Using static prelude.\n")
+		if !cfg.Quiet && !cfg.preludeAnnounced {
+			fmt.Print(preludeNotice(cfg, true))
 		}
 		// static version, compiled into prelude_static.go
 
@@ -134,13 +132,15 @@ func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 			// also load timezone, for windows
 			if nm == "zoneinfo" {
 				//fmt.Printf("loading zoneinfo/\n")
-				f, err := preludeFiles.Open("zoneinfo/America/New_York")
-				panicOn(err)
-				nyctzdata, err := ioutil.ReadAll(f)
-				panicOn(err)
-				nyc, err = time.LoadLocationFromTZData("America/New_York", nyctzdata)
-				panicOn(err)
-				//fmt.Printf("nyc is '%s'\n", nyc)
+				nycOnce.Do(func() {
+					f, err := preludeFiles.Open("zoneinfo/America/New_York")
+					panicOn(err)
+					nyctzdata, err := ioutil.ReadAll(f)
+					panicOn(err)
+					nyc, err = time.LoadLocationFromTZData("America/New_York", nyctzdata)
+					panicOn(err)
+					//fmt.Printf("nyc is '%s'\n", nyc)
+				})
 			} else {
 				if !fi.IsDir() && fi.Size() > 0 && strings.HasSuffix(nm, ".lua") {
 					if !strings.HasSuffix(nm, "_test.lua") {
@@ -171,8 +171,8 @@ func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 		}
 
 	} else {
-		if !cfg.Quiet {
-			fmt.Printf("Dynamic prelude '%s'\n", cfg.PreludePath)
+		if !cfg.Quiet && !cfg.preludeAnnounced {
+			fmt.Print(preludeNotice(cfg, false))
 		}
 		//fmt.Printf("cfg = '%#v'\n", cfg)
 		files, err = FetchPreludeFilenames(cfg.PreludePath, cfg.Quiet)
@@ -202,7 +202,10 @@ func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 	}
 
 	luar.Register(vm, "", luar.Map{
-		"__lua2go": lua2GoProxy,
+		"__lua2go":                  lua2GoProxy,
+		"__gi_interruptCheck":       lvm.intr.check,
+		"__gi_interruptSetHeapBase": lvm.intr.setHeapBase,
+		"__gi_goMallocs":            goMallocs,
 	})
 	//fmt.Printf("registered __lua2go with luar.\n")
 	// only now that __eval is available can we start heartbeat.
@@ -221,6 +224,26 @@ func NewLuaVmWithPrelude(cfg *GIConfig) (lvm *LuaVm, err error) {
 	return lvm, err
 }
 
+// usesStaticPrelude reports whether a vm made for cfg loads
+// the prelude compiled into prelude_static.go, rather than
+// the files of the prelude directory.
+func usesStaticPrelude(cfg *GIConfig) bool {
+	// cfg == nil means under test.
+	// cfg.Dev means `gi -d` was invoked.
+	return cfg != nil && !cfg.Dev && !cfg.IsTestMode
+}
+
+// preludeNotice is what a vm made for cfg says, unless
+// cfg.Quiet, of the prelude it loads.
+func preludeNotice(cfg *GIConfig, static bool) string {
+	if static {
+		return "Using static prelude.\n"
+	}
+	cwd, err := os.Getwd()
+	panicOn(err)
+	return fmt.Sprintf("Dynamic prelude '%s'\n", cwd+"/prelude")
+}
+
 func LuaDoPreludeFiles(lvm *LuaVm, files []string) error {
 	for _, f := range files {
 		pp("LuaDoFiles, f = '%s'", f)
@@ -460,7 +483,6 @@ func LuaMustBeNil(lvm *LuaVm, varname string) {
 		panic(fmt.Sprintf("expected varname '%s' to "+
 			"be nil, but was '%s' instead.", varname, alt))
 	}
-	vm.Pop(1)
 }
 func LuaIsNil(lvm *LuaVm, varname string) (bool, string) {
 
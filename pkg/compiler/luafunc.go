@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// A Lua func is a //gi:lua input that declares a Go func
+// whose body is Lua:
+//
+//	//gi:lua
+//	func hypot(x, y float64) float64 {
+//	    return math.sqrt(x*x + y*y)
+//	}
+//
+// The first line, up to the { that ends it, is the func's Go
+// signature, which the type checker holds callers to; the
+// lines up to a line of } that is not indented are the body,
+// run as Lua with the parameters under their Go names. The
+// body must return what the signature declares, as gi
+// represents it: an int as an int64 cdata, a string as a Lua
+// string, several results as several values.
+
+// luaFunc is a Go func with a Lua body.
+type luaFunc struct {
+	name   string
+	goDecl string // the func, with a stub for a body
+	lua    string // the statement that installs the Lua body
+}
+
+// parseLuaFunc returns the Lua func that src, a //gi:lua
+// input without its pragmas, declares, or nil if src does
+// not start with func.
+func parseLuaFunc(src string) (*luaFunc, error) {
+	if !startsLuaFunc(src) {
+		return nil, nil
+	}
+	lines := strings.Split(strings.TrimSpace(src), "\n")
+	header := strings.TrimSpace(lines[0])
+	last := len(lines) - 1
+	if !strings.HasSuffix(header, "{") || last < 1 || !endsLuaFunc(lines[last]) {
+		return nil, fmt.Errorf("a Lua func is its Go signature on the first line, ending in {, then the Lua body, then a line of }")
+	}
+
+	fset := token.NewFileSet()
+	stub := header + "\n\tpanic(\"the body of this func is Lua; a session image keeps only its signature\")\n}"
+	file, err := parser.ParseFile(fset, "", stub, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd, ok := file.Nodes[0].(*ast.FuncDecl)
+	if !ok || len(file.Nodes) != 1 {
+		return nil, fmt.Errorf("a Lua func must start with a func declaration")
+	}
+	if fd.Recv != nil {
+		return nil, fmt.Errorf("a Lua func cannot be a method")
+	}
+	lf := &luaFunc{name: fd.Name.Name, goDecl: stub}
+	if reservedKeywords[lf.name] {
+		return nil, fmt.Errorf("a Lua func cannot be named %s, a name Lua or gi reserves", lf.name)
+	}
+	var params []string
+	for _, f := range fd.Type.Params.List {
+		if len(f.Names) == 0 {
+			params = append(params, "_")
+		}
+		for _, id := range f.Names {
+			if reservedKeywords[id.Name] {
+				return nil, fmt.Errorf("parameter %s of %s is a name Lua or gi reserves", id.Name, lf.name)
+			}
+			params = append(params, id.Name)
+		}
+	}
+	lf.lua = fmt.Sprintf("%s = function(%s)\n%s\nend\n", lf.name, strings.Join(params, ", "), strings.Join(lines[1:last], "\n"))
+	return lf, nil
+}
+
+// startsLuaFunc reports whether src, a //gi:lua input
+// without its pragmas, starts a Lua func.
+func startsLuaFunc(src string) bool {
+	src = strings.TrimSpace(src)
+	return strings.HasPrefix(src, "func ") || strings.HasPrefix(src, "func\t")
+}
+
+// endsLuaFunc reports whether line ends a Lua func.
+func endsLuaFunc(line string) bool {
+	return strings.TrimRight(line, " \t\r\n") == "}"
+}
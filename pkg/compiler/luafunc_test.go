@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1390LuaFuncsHaveGoSignatures(t *testing.T) {
+
+	cv.Convey("a //gi:lua func has a Go signature, which callers are checked against, and a Lua body", t, func() {
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal"}))
+		panicOn(cfg.ValidateConfig())
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		r.reader = bufio.NewReader(strings.NewReader(`//gi:lua
+func hypot(x, y float64) float64 {
+	local s = x*x + y*y
+
+	return math.sqrt(s)
+}
+h := hypot(3, 4)
+println(h)
+//gi:lua
+func upper(s string) (string, float64) {
+	return s:upper(), #s
+}
+u, n := upper("abc")
+println(u, n)
+hypot("a", 1)
+//gi:lua
+func (p P) m() {
+}
+`))
+		pr, pw, err := os.Pipe()
+		panicOn(err)
+		saved := os.Stdout
+		os.Stdout = pw
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(pr)
+			out <- string(b)
+		}()
+		r.Loop()
+		os.Stdout = saved
+		pw.Close()
+		got := <-out
+		pp("got:\n%s", got)
+
+		cv.So(got, cv.ShouldContainSubstring, "5\n//gi:result ok\n")
+		cv.So(got, cv.ShouldContainSubstring, "ABC\t3\n//gi:result ok\n")
+		cv.So(got, cv.ShouldContainSubstring, "cannot convert \"a\"")
+		cv.So(got, cv.ShouldContainSubstring, "oops: a Lua func cannot be a method\n")
+
+		// the history keeps the Lua, to replay.
+		cv.So(strings.Join(r.history, "\n"), cv.ShouldContainSubstring, "return s:upper(), #s")
+	})
+}
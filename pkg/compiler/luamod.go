@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// luaModPrefix starts the import paths of Lua modules:
+// `import "lua/socket"` requires the module socket, and
+// "lua/socket/http" the module socket.http. The package
+// is named for the last element, as Go's are.
+const luaModPrefix = "lua/"
+
+func isLuaModPath(path string) bool {
+	return strings.HasPrefix(path, luaModPrefix) && len(path) > len(luaModPrefix)
+}
+
+// importLuaModule requires the Lua module for path and
+// builds the type checker's view of it: each function as
+// a func(args ...interface{}) interface{}, and each other
+// value as an interface{} variable. prelude/zluamod.lua
+// makes the Lua side to match.
+func (ic *IncrState) importLuaModule(path string) (*Archive, error) {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if !isIdentifier(name) {
+		return nil, fmt.Errorf("import of '%s': '%s' is not a Go package name", path, name)
+	}
+	pkg := types.NewPackage(path, name)
+	if err := ic.requireLuaModule(pkg, false); err != nil {
+		return nil, err
+	}
+	pkg.MarkComplete()
+
+	ic.CurPkg.importContext.Packages[path] = pkg
+	return &Archive{
+		ImportPath: path,
+		Pkg:        pkg,
+	}, nil
+}
+
+// requireLuaModule requires the Lua module of pkg, loading
+// it again from its file if reload, and declares its members
+// in the scope of pkg, in place of those there before.
+func (ic *IncrState) requireLuaModule(pkg *types.Package, reload bool) error {
+	path := pkg.Path()
+	mod := strings.Replace(strings.TrimPrefix(path, luaModPrefix), "/", ".", -1)
+
+	t := ic.goro.newTicket(fmt.Sprintf("__gi_requireLua(%q, %q, %v)", path, mod, reload), false)
+	t.varname = map[string]interface{}{"__gi_luaModMembers": nil}
+	t.gettyp = GetString
+	if err := t.Do(); err != nil {
+		return fmt.Errorf("import of '%s': %v", path, err)
+	}
+	members, _ := t.varname["__gi_luaModMembers"].(string)
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		scope.DeleteByName(name)
+	}
+	any := types.NewInterface(nil, nil).Complete()
+	for _, m := range strings.Fields(members) {
+		eq := strings.Index(m, "=")
+		if eq < 0 {
+			continue
+		}
+		member, kind := m[:eq], m[eq+1:]
+		switch kind {
+		case "func":
+			params := types.NewTuple(types.NewVar(token.NoPos, pkg, "args", types.NewSlice(any)))
+			results := types.NewTuple(types.NewVar(token.NoPos, pkg, "", any))
+			scope.Insert(types.NewFunc(token.NoPos, pkg, member, types.NewSignature(nil, params, results, true)))
+		case "var":
+			scope.Insert(types.NewVar(token.NoPos, pkg, member, any))
+		}
+	}
+	return nil
+}
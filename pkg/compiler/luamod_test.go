@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1339ImportLuaModules(t *testing.T) {
+
+	cv.Convey(`import "lua/..." requires a Lua module and shows its members to Go, upper cased, as dynamically typed funcs and vars`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(LuaRun(it.lvm, `package.preload["gitest.greet"] = function()
+  return {
+    hello = function(who, n) return "hello " .. who .. string.rep("!", n or 0) end,
+    add = function(a, b) return a + b end,
+    pair = function() return 1, 2 end,
+    nothing = function() end,
+    version = "1.0",
+  }
+end`, false))
+
+		panicOn(it.Eval(`import "lua/gitest/greet"`))
+
+		// Go integers go to Lua as numbers.
+		panicOn(it.Eval(`s := greet.Hello("gopher", 3).(string)`))
+		LuaMustString(it.lvm, "s", "hello gopher!!!")
+
+		panicOn(it.Eval(`a := greet.Add(2, 3).(float64)`))
+		LuaMustFloat64(it.lvm, "a", 5)
+
+		panicOn(it.Eval(`xs := []interface{}{"x", 1}; b := greet.Hello(xs...)`))
+		LuaMustString(it.lvm, "b", "hello x!")
+
+		// only the first result comes back.
+		panicOn(it.Eval(`p := greet.Pair()`))
+		LuaMustFloat64(it.lvm, "p", 1)
+
+		panicOn(it.Eval(`z := greet.Nothing() == nil`))
+		LuaMustBool(it.lvm, "z", true)
+
+		panicOn(it.Eval(`v := greet.Version`))
+		LuaMustString(it.lvm, "v", "1.0")
+
+		// members the module lacks are caught by the checker.
+		err = it.Eval(`greet.Goodbye()`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "Goodbye not declared by package greet")
+
+		// a module already loaded, such as the string
+		// library, keeps working for the runtime's own use.
+		panicOn(it.Eval(`import "lua/string"`))
+		panicOn(it.Eval(`u := string.Upper("abc")`))
+		LuaMustString(it.lvm, "u", "ABC")
+		panicOn(it.Eval(`import "lua/string"`))
+		panicOn(it.Eval(`u2 := string.Rep("ab", 2)`))
+		LuaMustString(it.lvm, "u2", "abab")
+
+		err = it.Eval(`import "lua/gitest/no_such_module"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "module 'gitest.no_such_module' not found")
+	})
+
+	cv.Convey(`Lua modules need the ffi capability`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapNone)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "lua/string"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "denied by sandbox policy")
+	})
+}
@@ -0,0 +1,87 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1366MethodsDeclaredInLaterInputs(t *testing.T) {
+
+	cv.Convey("a method declared in a later input should extend the method set of a type from an earlier one, for values made before it too, and a redeclaration should replace it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type S struct{ x int }`))
+		panicOn(it.Eval(`s := S{x: 4}
+ps := &S{x: 5}`))
+		panicOn(it.Eval(`func (s S) Get() int { return s.x }`))
+		panicOn(it.Eval(`func (s *S) Set(v int) { s.x = v }`))
+		panicOn(it.Eval(`a := s.Get()
+ps.Set(8)
+b := ps.Get()`))
+		LuaMustInt64(it.lvm, "a", 4)
+		LuaMustInt64(it.lvm, "b", 8)
+
+		panicOn(it.Eval(`type Getter interface{ Get() int }
+var g Getter = s
+c := g.Get()`))
+		LuaMustInt64(it.lvm, "c", 4)
+
+		panicOn(it.Eval(`func (s S) Get() int { return s.x * 10 }`))
+		panicOn(it.Eval(`d := s.Get()
+e := g.Get()`))
+		LuaMustInt64(it.lvm, "d", 40)
+		LuaMustInt64(it.lvm, "e", 40)
+	})
+}
+
+func Test1366MethodsOnImportedTypes(t *testing.T) {
+
+	cv.Convey("methods on an imported type should be refused by default, and under MonkeyPatch be added with a warning, unless the type is bound from Go", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "flag"`))
+		err = it.Eval(`func (f *flag.FlagSet) UsageOf(name string) string { return f.Lookup(name).Usage }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "type not defined in this package")
+
+		cfg := NewGIConfig()
+		cfg.MonkeyPatch = true
+		mp, err := NewInterp(cfg)
+		panicOn(err)
+		defer mp.Close()
+
+		panicOn(mp.Eval(`import "flag"`))
+		panicOn(mp.Eval(`func (f *flag.FlagSet) UsageOf(name string) string { return f.Lookup(name).Usage }`))
+		cv.So(mp.Warnings(), cv.ShouldResemble, []string{
+			"warning: adding methods to imported type flag.FlagSet, for every session in this process",
+		})
+		panicOn(mp.Eval(`fs := flag.NewFlagSet("prog", flag.ContinueOnError)
+fs.Bool("v", false, "verbose")
+a := fs.UsageOf("v")
+type User interface{ UsageOf(string) string }
+var u User = fs
+b := u.UsageOf("v")`))
+		LuaMustString(mp.lvm, "a", "verbose")
+		LuaMustString(mp.lvm, "b", "verbose")
+
+		// the type is only warned about once.
+		panicOn(mp.Eval(`func (f *flag.FlagSet) UsageOf(name string) string { return "[" + f.Lookup(name).Usage + "]" }`))
+		cv.So(mp.Warnings(), cv.ShouldBeEmpty)
+		panicOn(mp.Eval(`c := fs.UsageOf("v")`))
+		LuaMustString(mp.lvm, "c", "[verbose]")
+
+		panicOn(mp.Eval(`import "time"`))
+		err = mp.Eval(`func (d time.Duration) Half() time.Duration { return d / 2 }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "time.Duration is bound from Go")
+
+		err = mp.Eval(`func (v flag.Value) Twice() {}`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "pointer or interface type")
+	})
+}
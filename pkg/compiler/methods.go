@@ -0,0 +1,150 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Methods lists the method set of the type typ, as T or *T,
+// for :methods: a line for each method, with its signature,
+// and for a method promoted from an embedded field, the
+// fields it was promoted through. For a T that is not a
+// pointer or interface, the methods only *T has are named
+// after, since they are a common reason for T not to
+// satisfy an interface.
+func (it *Interp) Methods(typ string) (string, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+
+	T, err := it.evalType(typ)
+	if err != nil {
+		return "", err
+	}
+	return formatMethodSet(T, imageQualifier(it.inc.CurPkg.Arch.Pkg)), nil
+}
+
+// evalType type checks the type expression src in the
+// session's scope.
+func (it *Interp) evalType(src string) (types.Type, error) {
+	it.inc.pkgScope()
+	tv, err := types.EvalWith(nil, it.inc.CurPkg.fileSet, it.inc.CurPkg.Arch.Pkg, token.NoPos, src)
+	if err != nil {
+		return nil, err
+	}
+	if !tv.IsType() {
+		return nil, fmt.Errorf("%s is not a type", src)
+	}
+	return tv.Type, nil
+}
+
+func formatMethodSet(T types.Type, qual types.Qualifier) string {
+	var b bytes.Buffer
+	name := types.TypeString(T, qual)
+	mset := types.NewMethodSet(T)
+	switch mset.Len() {
+	case 0:
+		fmt.Fprintf(&b, "%s has no methods.\n", name)
+	case 1:
+		fmt.Fprintf(&b, "%s has 1 method:\n", name)
+	default:
+		fmt.Fprintf(&b, "%s has %d methods:\n", name, mset.Len())
+	}
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+		b.WriteString("  " + methodString(sel.Obj().(*types.Func), qual))
+		if path := promotedThrough(T, sel.Index()); path != "" {
+			fmt.Fprintf(&b, "  (promoted from %s)", path)
+		}
+		b.WriteByte('\n')
+	}
+
+	if _, isPtr := T.Underlying().(*types.Pointer); isPtr || types.IsInterface(T) {
+		return b.String()
+	}
+	var ptrOnly []string
+	pset := types.NewMethodSet(types.NewPointer(T))
+	for i := 0; i < pset.Len(); i++ {
+		m := pset.At(i).Obj()
+		if mset.Lookup(m.Pkg(), m.Name()) == nil {
+			ptrOnly = append(ptrOnly, m.Name())
+		}
+	}
+	if len(ptrOnly) > 0 {
+		fmt.Fprintf(&b, "*%s also has: %s\n", name, strings.Join(ptrOnly, ", "))
+	}
+	return b.String()
+}
+
+// promotedThrough names the embedded fields, as A.B, that
+// the selection with index leads through from T, or is ""
+// for a method declared on T itself.
+func promotedThrough(T types.Type, index []int) string {
+	var path []string
+	for _, i := range index[:len(index)-1] {
+		if p, ok := T.Underlying().(*types.Pointer); ok {
+			T = p.Elem()
+		}
+		f := T.Underlying().(*types.Struct).Field(i)
+		path = append(path, f.Name())
+		T = f.Type()
+	}
+	return strings.Join(path, ".")
+}
+
+// Implements reports, for :implements, whether the type typ
+// satisfies the interface type iface. If not, report names
+// each method of iface that typ is missing, noting those only
+// *typ has, and each it has with another signature, with
+// both signatures.
+func (it *Interp) Implements(typ, iface string) (ok bool, report string, err error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+
+	T, err := it.evalType(typ)
+	if err != nil {
+		return false, "", err
+	}
+	I, err := it.evalType(iface)
+	if err != nil {
+		return false, "", err
+	}
+	in, isIface := I.Underlying().(*types.Interface)
+	if !isIface {
+		return false, "", fmt.Errorf("%s is not an interface type", iface)
+	}
+
+	qual := imageQualifier(it.inc.CurPkg.Arch.Pkg)
+	name, iname := types.TypeString(T, qual), types.TypeString(I, qual)
+	missing, wrong := types.MissingMethods(T, in, true)
+	if len(missing) == 0 && len(wrong) == 0 {
+		return true, fmt.Sprintf("%s implements %s.\n", name, iname), nil
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s does not implement %s:\n", name, iname)
+	for _, m := range missing {
+		fmt.Fprintf(&b, "  missing method %s", methodString(m, qual))
+		if _, _, indirect := types.LookupFieldOrMethod(T, false, m.Pkg(), m.Name()); indirect {
+			fmt.Fprintf(&b, "; *%s has it, with a pointer receiver", name)
+		}
+		b.WriteByte('\n')
+	}
+	for _, m := range wrong {
+		have, _, _ := types.LookupFieldOrMethod(T, false, m.Pkg(), m.Name())
+		fmt.Fprintf(&b, "  wrong signature for %s:\n    have %s\n    want %s\n",
+			m.Name(), methodString(have.(*types.Func), qual), methodString(m, qual))
+	}
+	return false, b.String(), nil
+}
+
+// methodString is m's name and signature, as in an interface.
+func methodString(m *types.Func, qual types.Qualifier) string {
+	var b bytes.Buffer
+	b.WriteString(m.Name())
+	types.WriteSignature(&b, m.Type().(*types.Signature), qual)
+	return b.String()
+}
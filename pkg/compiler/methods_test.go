@@ -0,0 +1,104 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1367MethodsListsTheMethodSet(t *testing.T) {
+
+	cv.Convey(`:methods lists the method set of T or *T with signatures, the fields a method was promoted through, and what only *T has`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type Inner struct{ n int }
+func (i Inner) N() int { return i.n }
+func (i *Inner) Bump(by int) { i.n += by }
+type Mid struct{ Inner }
+type Outer struct {
+	Mid
+	name string
+}
+func (o Outer) Name() string { return o.name }
+func (o *Outer) Rename(s string) (old string, err error) { return o.name, nil }
+type Plain struct{}`))
+
+		ms, err := it.Methods(`Outer`)
+		panicOn(err)
+		cv.So(ms, cv.ShouldEqual, `Outer has 2 methods:
+  N() int  (promoted from Mid.Inner)
+  Name() string
+*Outer also has: Bump, Rename
+`)
+
+		ms, err = it.Methods(`*Outer`)
+		panicOn(err)
+		cv.So(ms, cv.ShouldEqual, `*Outer has 4 methods:
+  Bump(by int)  (promoted from Mid.Inner)
+  N() int  (promoted from Mid.Inner)
+  Name() string
+  Rename(s string) (old string, err error)
+`)
+
+		ms, err = it.Methods(`Plain`)
+		panicOn(err)
+		cv.So(ms, cv.ShouldEqual, "Plain has no methods.\n")
+
+		ms, err = it.Methods(`error`)
+		panicOn(err)
+		cv.So(ms, cv.ShouldEqual, "error has 1 method:\n  Error() string\n")
+
+		_, err = it.Methods(`Outer{}`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEqual, "Outer{} is not a type")
+
+		_, err = it.Methods(`Nope`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "undeclared name: Nope")
+	})
+}
+
+func Test1368ImplementsNamesWhatIsMissing(t *testing.T) {
+
+	cv.Convey(`:implements tells whether a type satisfies an interface, and if not, each method missing, those only *T has, and each with the wrong signature`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "io"
+type F struct{}
+func (f *F) Read(p []byte) (n int, err error) { return 0, nil }
+func (f F) Write(p []byte) int { return 0 }
+type RWC interface {
+	io.Reader
+	Write(p []byte) (n int, err error)
+	Close() error
+}`))
+
+		ok, report, err := it.Implements(`*F`, `io.Reader`)
+		panicOn(err)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(report, cv.ShouldEqual, "*F implements io.Reader.\n")
+
+		ok, report, err = it.Implements(`F`, `RWC`)
+		panicOn(err)
+		cv.So(ok, cv.ShouldBeFalse)
+		cv.So(report, cv.ShouldEqual, `F does not implement RWC:
+  missing method Close() error
+  missing method Read(p []byte) (n int, err error); *F has it, with a pointer receiver
+  wrong signature for Write:
+    have Write(p []byte) int
+    want Write(p []byte) (n int, err error)
+`)
+
+		ok, _, err = it.Implements(`RWC`, `io.Reader`)
+		panicOn(err)
+		cv.So(ok, cv.ShouldBeTrue)
+
+		_, _, err = it.Implements(`F`, `F`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEqual, "F is not an interface type")
+	})
+}
@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1363MinMaxAndClearBuiltins(t *testing.T) {
+
+	cv.Convey("min and max should order any number of values, folding constants, and clear should zero a slice and empty a map", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`a, b, c := 3, -7, 12
+lo := min(a, b, c)
+hi := max(a, b, c)
+one := max(a)
+const k = max(1, 9, 4)
+var arr [k]int
+n := len(arr)
+s := min("pear", "apple", "fig")`))
+		LuaMustInt64(it.lvm, "lo", -7)
+		LuaMustInt64(it.lvm, "hi", 12)
+		LuaMustInt64(it.lvm, "one", 3)
+		LuaMustInt64(it.lvm, "n", 9)
+		LuaMustString(it.lvm, "s", "apple")
+
+		// a NaN wins, and -0 is less than 0.
+		panicOn(it.Eval(`import "math"
+var zero float64
+negz := math.Copysign(0, -1)
+nan := max(1.5, math.NaN()) != max(1.5, math.NaN())
+negLow := math.Signbit(min(zero, negz))
+posHigh := !math.Signbit(max(negz, zero))
+f := min(2.5, float64(a))`))
+		LuaMustBool(it.lvm, "nan", true)
+		LuaMustBool(it.lvm, "negLow", true)
+		LuaMustBool(it.lvm, "posHigh", true)
+		LuaMustFloat64(it.lvm, "f", 2.5)
+
+		panicOn(it.Eval(`type P struct{ X, Y int }
+ps := []P{{1, 2}, {3, 4}}
+nums := []int{5, 6, 7}
+clear(nums[1:])
+clear(ps)
+m := map[string]int{"a": 1, "b": 2}
+clear(m)
+var nilm map[string]int
+clear(nilm)
+numsSum := nums[0] + nums[1] + nums[2]
+psSum := ps[0].X + ps[1].Y
+mlen := len(m)
+m["c"] = 3
+mlen2 := len(m)`))
+		LuaMustInt64(it.lvm, "numsSum", 5)
+		LuaMustInt64(it.lvm, "psSum", 0)
+		LuaMustInt(it.lvm, "mlen", 0)
+		LuaMustInt(it.lvm, "mlen2", 1)
+
+		err = it.Eval(`_ = min(1, "a")`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "mismatched types")
+	})
+}
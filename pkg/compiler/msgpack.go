@@ -0,0 +1,301 @@
+package compiler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// A minimal MessagePack codec, for the msgpack-RPC that
+// Neovim speaks to gi nvim. Values decode to nil, bool,
+// int64, uint64 (only when too large for an int64),
+// float64, string (str and bin both), []interface{},
+// map[string]interface{} and msgpackExt; and encode from
+// those, int, []string, []map[string]interface{} and
+// []byte (as bin).
+
+// msgpackExt is an extension value, as Neovim sends its
+// buffer, window and tabpage handles.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// msgpackMaxLen bounds a string or container, so a bad
+// length cannot make the decoder allocate without end.
+const msgpackMaxLen = 64 << 20
+
+type msgpackDecoder struct {
+	r *bufio.Reader
+}
+
+func newMsgpackDecoder(r io.Reader) *msgpackDecoder {
+	return &msgpackDecoder{r: bufio.NewReader(r)}
+}
+
+func (d *msgpackDecoder) bytes(n int) ([]byte, error) {
+	if n > msgpackMaxLen {
+		return nil, fmt.Errorf("msgpack: length %d too large", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.bytes(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Decode reads the next value.
+func (d *msgpackDecoder) Decode() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapOf(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.arrayOf(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[c]
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		if n > math.MaxInt64 {
+			return n, err
+		}
+		return int64(n), err
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unknown type byte 0x%02x", c)
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.bytes(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) ext(n int) (interface{}, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.bytes(n)
+	return msgpackExt{Type: int8(t), Data: b}, err
+}
+
+func (d *msgpackDecoder) arrayOf(n int) (interface{}, error) {
+	if n > msgpackMaxLen {
+		return nil, fmt.Errorf("msgpack: length %d too large", n)
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) mapOf(n int) (interface{}, error) {
+	if n > msgpackMaxLen {
+		return nil, fmt.Errorf("msgpack: length %d too large", n)
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
+
+// msgpackAppend appends the encoding of v to b. Map keys
+// are written in order, so an encoding is reproducible.
+func msgpackAppend(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return msgpackAppendInt(b, int64(v)), nil
+	case int64:
+		return msgpackAppendInt(b, v), nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return msgpackAppendInt(b, int64(v)), nil
+		}
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+		}
+		return append(b, v...), nil
+	case []byte:
+		n := len(v)
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+		}
+		return append(b, v...), nil
+	case []interface{}:
+		b = msgpackAppendLen(b, len(v), 0x90, 0xdc)
+		var err error
+		for _, e := range v {
+			if b, err = msgpackAppend(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []string:
+		a := make([]interface{}, len(v))
+		for i, s := range v {
+			a[i] = s
+		}
+		return msgpackAppend(b, a)
+	case []map[string]interface{}:
+		a := make([]interface{}, len(v))
+		for i, m := range v {
+			a[i] = m
+		}
+		return msgpackAppend(b, a)
+	case map[string]interface{}:
+		b = msgpackAppendLen(b, len(v), 0x80, 0xde)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var err error
+		for _, k := range keys {
+			b, _ = msgpackAppend(b, k)
+			if b, err = msgpackAppend(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("msgpack: cannot encode %T", v)
+}
+
+func msgpackAppendInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(int8(n)))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(int32(n)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+// msgpackAppendLen appends the header of an array or map
+// of n elements: fix, the fixarray or fixmap type, or else
+// the 16 bit type, or the 32 bit one that follows it.
+func msgpackAppendLen(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, wide), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, wide+1), uint32(n))
+}
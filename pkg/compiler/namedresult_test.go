@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1364NamedResultsAndBareReturns(t *testing.T) {
+
+	cv.Convey("named results should start at their zero values, be returned by a bare return, and be seen and changed by deferred functions", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`func divmod(a, b int) (q, r int) {
+	q = a / b
+	r = a % b
+	return
+}
+func zeros() (n int, s string, ok bool, p *int) {
+	return
+}
+func pick() (_ int, x int) {
+	x = 7
+	return
+}
+q, r := divmod(17, 5)
+n, s, ok, p := zeros()
+pnil := p == nil
+b0, b1 := pick()`))
+		LuaMustInt64(it.lvm, "q", 3)
+		LuaMustInt64(it.lvm, "r", 2)
+		LuaMustInt64(it.lvm, "n", 0)
+		LuaMustString(it.lvm, "s", "")
+		LuaMustBool(it.lvm, "ok", false)
+		LuaMustBool(it.lvm, "pnil", true)
+		LuaMustInt64(it.lvm, "b0", 0)
+		LuaMustInt64(it.lvm, "b1", 7)
+
+		// a deferred closure sees the value the return
+		// statement gave, and can change it, even after a
+		// recovered panic; the results stay out of the
+		// globals, so recursion keeps them apart.
+		panicOn(it.Eval(`import "errors"
+func double(x int) (y int) {
+	defer func() { y *= 2 }()
+	return x + 1
+}
+func safe(fail bool) (res string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered")
+		}
+	}()
+	res = "partial"
+	if fail {
+		panic("boom")
+	}
+	return "done", nil
+}
+func fact(n int) (f int) {
+	defer func() {}()
+	if n <= 1 {
+		return 1
+	}
+	f = n * fact(n-1)
+	return
+}
+func plain(x int) int {
+	defer func() {}()
+	return x * 3
+}
+d := double(4)
+res1, err1 := safe(false)
+res2, err2 := safe(true)
+err1nil := err1 == nil
+err2msg := err2.Error()
+f5 := fact(5)
+p3 := plain(3)`))
+		LuaMustInt64(it.lvm, "d", 10)
+		LuaMustString(it.lvm, "res1", "done")
+		LuaMustBool(it.lvm, "err1nil", true)
+		LuaMustString(it.lvm, "res2", "partial")
+		LuaMustString(it.lvm, "err2msg", "recovered")
+		LuaMustInt64(it.lvm, "f5", 120)
+		LuaMustInt64(it.lvm, "p3", 9)
+		LuaMustNotBeInGlobalEnv(it.lvm, "f")
+		LuaMustNotBeInGlobalEnv(it.lvm, "err")
+
+		// a method's receiver reaches a body with defers.
+		panicOn(it.Eval(`type Acc struct{ total int }
+func (a *Acc) Add(x int) (sum int) {
+	defer func() { a.total = sum }()
+	sum = a.total + x
+	return
+}
+acc := &Acc{}
+acc.Add(2)
+s5 := acc.Add(3)
+tot := acc.total`))
+		LuaMustInt64(it.lvm, "s5", 5)
+		LuaMustInt64(it.lvm, "tot", 5)
+	})
+}
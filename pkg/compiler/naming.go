@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Names in the generated Lua follow one scheme, so that the
+// same input translates to the same Lua in every run, and
+// a name seen in a Lua error or stack leads back to the Go.
+//
+// A Go variable, constant, func or type declared at the
+// prompt keeps its Go name, as a Lua global; a type's is a
+// key of __type__. Unicode names stay as they are, since
+// LuaJIT reads UTF-8 identifiers.
+//
+// A Go name that Lua or gi reserves is written __go_name:
+// the Lua keywords, such as end or repeat, and the names of
+// Lua's libraries and gi's runtime, such as string or print.
+// So `end := 1` is __go_end in Lua, and `print := 2` leaves
+// Lua's print alone. Go names beginning with two
+// underscores are gi's own, such as __gijit_ans, and are
+// kept; code at the prompt should not declare them.
+//
+// The members of an imported package are in its table,
+// __packages["path"].Name; its types are __type__["path"].Name.
+// Methods are found through their receiver type's
+// prototype: __type__.T.prototype.M, and .ptr.prototype.M
+// for a pointer receiver. A method named for a reserved
+// word gets a trailing underscore, and such a field, or a
+// blank one, two and its index: then__0.
+//
+// The Lua names gi makes up start with two underscores, so
+// they never meet a Go name:
+//
+//	__gensym_N_name   a temporary, N counting from 1 in each func
+//	__tmp_N           a value the simplifier holds, N counting
+//	                  from 1 in each declaration
+//	__type__.anon_kindType, __type__.anon_kindType_N
+//	                  the anonymous types an input uses, such
+//	                  as []int, N counting from 1 in each input
+//
+// Declaring a name again at the prompt, a type included,
+// reuses its Lua name, so the new definition replaces the
+// old for the code that follows. Values made from the old
+// keep their old type table, and still work.
+
+// luaName is the Lua name of the Go identifier name; see
+// above.
+func luaName(name string) string {
+	if luaReserved[name] {
+		return "__go_" + name
+	}
+	return name
+}
+
+// isSimplifierTemp reports whether o is a temporary that
+// astrewrite.Simplify made up, as for a switch's tag; it
+// names them _1, _2 ... which are Go names too.
+func isSimplifierTemp(o types.Object) bool {
+	v, ok := o.(*types.Var)
+	return ok && v.Pkg() == nil && v.Parent() == nil && v.Pos() == token.NoPos &&
+		strings.HasPrefix(v.Name(), "_") && v.Name() != "_"
+}
+
+// encodeIdent makes name a Lua identifier: letters, digits,
+// underscores and dots are kept, Unicode letters included;
+// any other byte is written _XX, in hex.
+func encodeIdent(name string) string {
+	var b strings.Builder
+	for i, w := 0, 0; i < len(name); i += w {
+		r, n := utf8.DecodeRuneInString(name[i:])
+		w = n
+		if r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteString(name[i : i+w])
+			continue
+		}
+		for _, c := range []byte(name[i : i+w]) {
+			fmt.Fprintf(&b, "_%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1396NamesInTheLuaAreStableAndDoNotCollide(t *testing.T) {
+
+	cv.Convey("Go names that Lua or gi reserve are written __go_name, the simplifier's temporaries are gi's own, and an input translates the same in every session", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`func repeat(until int) int { return until + 1 }
+end := repeat(1)
+print := end * 10
+_1 := 5
+switch y := 3; y { case 3: _1++ }
+π := _1`))
+		LuaMustInt64(it.lvm, "__go_end", 2)
+		LuaMustInt64(it.lvm, "__go_print", 20)
+		LuaMustInt64(it.lvm, "π", 6)
+		panicOn(LuaRun(it.lvm, `ok = (type(print) == "function")`, false))
+		LuaMustBool(it.lvm, "ok", true)
+
+		src := `type S struct{ then int }
+func (s *S) Sum(xs ...int) (n int) { for _, x := range xs { n += x + s.then }; return }
+a := []struct{ p int }{{1}}`
+		var lua []string
+		for i := 0; i < 2; i++ {
+			it2, err := NewInterp(nil)
+			panicOn(err)
+			panicOn(it2.Eval(`x := 1`))
+			tr, err := it2.Translate(src)
+			panicOn(err)
+			lua = append(lua, tr)
+			it2.Close()
+		}
+		cv.So(lua[1], cv.ShouldEqual, lua[0])
+		cv.So(lua[0], cv.ShouldContainSubstring, "then__0")
+	})
+}
@@ -0,0 +1,281 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// gi nvim speaks msgpack-RPC on its standard input and
+// output, as Neovim does to a job started with rpc = true;
+// nvim/lua/gi/init.lua is the plugin. The methods are
+//
+//	eval(code)                 run code
+//	eval_function(text, line)  run the declaration of the
+//	                           Go source text at line,
+//	                           with text's imports
+//	complete(line, col)        completions at byte col
+//	interrupt()                cancel the running eval
+//	vars()                     the session's variables
+//
+// An eval's result is a map of its "output", "error" and
+// "summary", a line to show beside the code it ran; for
+// eval_function, also the "start" and "end" lines of the
+// declaration run, counted from 1.
+//
+// Called as a request, a method is answered in the usual
+// way. Sent as a notification, with an id before its
+// arguments, it is answered by calling
+// require("gi").on_result(id, result) in Neovim, so that the
+// editor need not wait for an eval, and can interrupt it.
+// Evals run one at a time, in the order they arrive.
+
+// nvimCallback is the Lua that gi nvim runs in Neovim,
+// with the id and result, to answer a notification.
+const nvimCallback = `return require("gi").on_result(...)`
+
+type rpcSession struct {
+	it   *Interp
+	intr interruptWatcher
+
+	wmu sync.Mutex
+	w   io.Writer
+}
+
+// ServeMsgpackRPC answers the msgpack-RPC calls read from
+// r, writing to w, until r ends.
+func ServeMsgpackRPC(it *Interp, r io.Reader, w io.Writer) error {
+	s := &rpcSession{it: it, w: w}
+	dec := newMsgpackDecoder(r)
+
+	type call struct {
+		id     interface{}
+		notify bool
+		method string
+		args   []interface{}
+	}
+	evals := make(chan call, 64)
+	drained := make(chan struct{})
+	defer func() {
+		close(evals)
+		<-drained
+	}()
+	answer := func(c call) {
+		result, err := s.call(c.method, c.args)
+		switch {
+		case !c.notify:
+			var e interface{}
+			if err != nil {
+				e = err.Error()
+			}
+			s.write([]interface{}{int64(1), c.id, e, result})
+		case c.id != nil:
+			if err != nil {
+				result = map[string]interface{}{"error": err.Error()}
+			}
+			s.write([]interface{}{int64(2), "nvim_exec_lua", []interface{}{nvimCallback, []interface{}{c.id, result}}})
+		}
+	}
+	go func() {
+		for c := range evals {
+			answer(c)
+		}
+		close(drained)
+	}()
+
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg, ok := v.([]interface{})
+		if !ok || len(msg) < 3 {
+			return fmt.Errorf("msgpack-rpc: bad message %v", v)
+		}
+		var c call
+		switch kind, _ := msg[0].(int64); {
+		case kind == 0 && len(msg) == 4:
+			c.id = msg[1]
+			c.method, _ = msg[2].(string)
+			c.args, _ = msg[3].([]interface{})
+		case kind == 2 && len(msg) == 3:
+			c.notify = true
+			c.method, _ = msg[1].(string)
+			c.args, _ = msg[2].([]interface{})
+			if len(c.args) > 0 {
+				c.id = c.args[0]
+				c.args = c.args[1:]
+			}
+		default:
+			// a response; gi nvim makes no requests.
+			continue
+		}
+		switch c.method {
+		case "eval", "eval_function":
+			evals <- c
+		case "interrupt":
+			answer(c)
+		default:
+			go answer(c)
+		}
+	}
+}
+
+func (s *rpcSession) write(msg interface{}) {
+	b, err := msgpackAppend(nil, msg)
+	panicOn(err)
+	s.wmu.Lock()
+	s.w.Write(b)
+	s.wmu.Unlock()
+}
+
+func (s *rpcSession) call(method string, args []interface{}) (interface{}, error) {
+	str := func(i int) string {
+		if i < len(args) {
+			s, _ := args[i].(string)
+			return s
+		}
+		return ""
+	}
+	num := func(i int) int {
+		if i < len(args) {
+			n, _ := args[i].(int64)
+			return int(n)
+		}
+		return 0
+	}
+	switch method {
+	case "eval":
+		return s.eval(str(0)), nil
+	case "eval_function":
+		code, start, end, err := declarationAt(str(0), num(1))
+		if err != nil {
+			return nil, err
+		}
+		res := s.eval(code)
+		res["start"] = start
+		res["end"] = end
+		return res, nil
+	case "complete":
+		start, candidates := s.it.Complete(str(0), num(1))
+		if candidates == nil {
+			candidates = []string{}
+		}
+		return map[string]interface{}{"start": start, "candidates": candidates}, nil
+	case "interrupt":
+		canceled, _ := s.intr.interrupt()
+		return canceled, nil
+	case "vars":
+		vs, err := s.it.Vars()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]map[string]interface{}, len(vs))
+		for i, v := range vs {
+			list[i] = map[string]interface{}{"name": v.Name, "type": v.Type, "value": v.Value}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unknown method '%s'", method)
+}
+
+func (s *rpcSession) eval(code string) map[string]interface{} {
+	res := evalCaptured(s.it, &s.intr, code)
+	output := res.Output
+	for _, w := range res.Warnings {
+		output += w + "\n"
+	}
+	return map[string]interface{}{
+		"output":  output,
+		"error":   res.Error,
+		"summary": evalSummary(res),
+	}
+}
+
+// evalSummary is the line an editor shows beside the code
+// of an eval: the first line of its error, or else the last
+// line it printed.
+func evalSummary(res capturedEval) string {
+	if res.Error != "" {
+		return strings.SplitN(res.Error, "\n", 2)[0]
+	}
+	lines := strings.Split(strings.TrimRight(res.Output, "\n"), "\n")
+	return lines[len(lines)-1]
+}
+
+// declarationAt returns the top level declaration of the
+// Go source text that takes in line, counted from 1, with
+// the imports of text before it, and the lines it spans.
+func declarationAt(text string, line int) (code string, start, end int, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "buffer.go", text, parser.ParseComments)
+	if file == nil {
+		return "", 0, 0, err
+	}
+	of := func(n ast.Node) string {
+		return text[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset]
+	}
+	for _, node := range file.Nodes {
+		if ds, ok := node.(*ast.DeclStmt); ok {
+			node = ds.Decl
+		}
+		if gd, ok := node.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		start, end = fset.Position(node.Pos()).Line, fset.Position(node.End()).Line
+		if line < start || line > end {
+			continue
+		}
+		if _, bad := node.(*ast.BadDecl); bad {
+			break
+		}
+		var b strings.Builder
+		if len(file.Imports) > 0 {
+			b.WriteString("import (\n")
+			for _, spec := range file.Imports {
+				b.WriteString("\t" + of(spec) + "\n")
+			}
+			b.WriteString(")\n")
+		}
+		b.WriteString(of(node))
+		return b.String(), start, end, nil
+	}
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return "", 0, 0, fmt.Errorf("no declaration at line %d", line)
+}
+
+// GiNvimMain implements gi nvim, for Neovim to start as a
+// job; it returns the exit code. What the session prints
+// outside of evals goes to standard error, to keep it out
+// of the RPC stream.
+func GiNvimMain(cfg *GIConfig, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "usage: gi nvim\n")
+		return 2
+	}
+	rpcOut := os.Stdout
+	os.Stdout = os.Stderr
+
+	it, err := NewInterp(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi nvim: %v\n", err)
+		return 1
+	}
+	defer it.Close()
+	if err := ServeMsgpackRPC(it, os.Stdin, rpcOut); err != nil {
+		fmt.Fprintf(os.Stderr, "gi nvim: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1376NeovimMsgpackRPC(t *testing.T) {
+
+	cv.Convey("the msgpack codec reads back what it writes, and reads Neovim's extension handles", t, func() {
+		v := []interface{}{int64(0), int64(-1), int64(-33), int64(200), int64(-70000), int64(1 << 40), uint64(1 << 63),
+			1.5, "", strings.Repeat("é", 40), strings.Repeat("x", 70000), []byte{1, 2}, nil, true, false,
+			map[string]interface{}{"a": []interface{}{int64(1)}, "b": map[string]interface{}{}},
+			make([]interface{}, 20)}
+		b, err := msgpackAppend(nil, v)
+		panicOn(err)
+		got, err := newMsgpackDecoder(bytes.NewReader(b)).Decode()
+		panicOn(err)
+		want := append([]interface{}{}, v...)
+		want[11] = "\x01\x02" // bin reads as a string
+		cv.So(got, cv.ShouldResemble, want)
+
+		got, err = newMsgpackDecoder(bytes.NewReader([]byte{0xd4, 0x00, 0x07})).Decode()
+		panicOn(err)
+		cv.So(got, cv.ShouldResemble, msgpackExt{Type: 0, Data: []byte{7}})
+		_, err = newMsgpackDecoder(bytes.NewReader([]byte{0xdb, 0xff, 0xff, 0xff, 0xff})).Decode()
+		cv.So(err.Error(), cv.ShouldContainSubstring, "too large")
+	})
+
+	cv.Convey("gi nvim answers requests, and answers notifications by calling back into Neovim, running evals in order and interrupting them", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+		done := make(chan error)
+		go func() {
+			done <- ServeMsgpackRPC(it, inR, outW)
+			outW.Close()
+		}()
+		dec := newMsgpackDecoder(outR)
+		send := func(msg ...interface{}) {
+			b, err := msgpackAppend(nil, msg)
+			panicOn(err)
+			inW.Write(b)
+		}
+		recv := func() []interface{} {
+			v, err := dec.Decode()
+			panicOn(err)
+			return v.([]interface{})
+		}
+
+		send(int64(0), int64(1), "eval", []interface{}{"import \"fmt\"\nx := 20\nfmt.Println(x + 1)\nfmt.Println(x * 2)"})
+		cv.So(recv(), cv.ShouldResemble, []interface{}{int64(1), int64(1), nil,
+			map[string]interface{}{"output": "21\n40\n", "error": "", "summary": "40"}})
+
+		send(int64(0), int64(2), "eval", []interface{}{"y := nope"})
+		r := recv()
+		res := r[3].(map[string]interface{})
+		cv.So(res["summary"], cv.ShouldEqual, "problem detected during Go static type checking: 'where error? err = 'repl[2]:1:6: undeclared name: nope''")
+
+		// a notification is answered by nvim_exec_lua.
+		send(int64(2), "eval", []interface{}{int64(7), "fmt.Println(\"hi\")"})
+		cv.So(recv(), cv.ShouldResemble, []interface{}{int64(2), "nvim_exec_lua", []interface{}{nvimCallback,
+			[]interface{}{int64(7), map[string]interface{}{"output": "hi\n", "error": "", "summary": "hi"}}}})
+
+		buffer := "package main\n\nimport \"fmt\"\n\n// Double doubles.\nfunc Double(n int) int {\n\treturn 2 * n\n}\n\nvar z = 1\n"
+		send(int64(0), int64(3), "eval_function", []interface{}{buffer, int64(7)})
+		res = recv()[3].(map[string]interface{})
+		cv.So(res["error"], cv.ShouldEqual, "")
+		cv.So(res["start"], cv.ShouldEqual, int64(6))
+		cv.So(res["end"], cv.ShouldEqual, int64(8))
+		send(int64(0), int64(4), "eval", []interface{}{"fmt.Println(Double(21))"})
+		cv.So(recv()[3].(map[string]interface{})["output"], cv.ShouldEqual, "42\n")
+		send(int64(0), int64(5), "eval_function", []interface{}{buffer, int64(2)})
+		cv.So(recv()[2], cv.ShouldEqual, "no declaration at line 2")
+
+		send(int64(0), int64(6), "complete", []interface{}{"fmt.Sprin", int64(9)})
+		cv.So(recv()[3], cv.ShouldResemble, map[string]interface{}{"start": int64(4),
+			"candidates": []interface{}{"Sprint", "Sprintf", "Sprintln"}})
+		send(int64(0), int64(7), "vars", []interface{}{})
+		cv.So(recv()[3], cv.ShouldResemble, []interface{}{map[string]interface{}{"name": "x", "type": "int", "value": "20"}})
+		send(int64(0), int64(8), "frobnicate", []interface{}{})
+		cv.So(recv()[2], cv.ShouldEqual, "unknown method 'frobnicate'")
+
+		// an eval queued behind a long one runs after it is
+		// interrupted.
+		send(int64(2), "eval", []interface{}{int64(10), "for {}"})
+		send(int64(2), "eval", []interface{}{int64(11), "fmt.Println(\"after\")"})
+		var interrupted bool
+		for i := 0; i < 100 && !interrupted; i++ {
+			time.Sleep(20 * time.Millisecond)
+			send(int64(0), int64(100+i), "interrupt", []interface{}{})
+			interrupted = recv()[3] == true
+		}
+		cv.So(interrupted, cv.ShouldBeTrue)
+		r = recv()[2].([]interface{})[1].([]interface{})
+		cv.So(r[0], cv.ShouldEqual, int64(10))
+		cv.So(r[1].(map[string]interface{})["error"], cv.ShouldContainSubstring, "interrupted")
+		r = recv()[2].([]interface{})[1].([]interface{})
+		cv.So(r[0], cv.ShouldEqual, int64(11))
+		cv.So(r[1].(map[string]interface{})["output"], cv.ShouldEqual, "after\n")
+
+		inW.Close()
+		cv.So(<-done, cv.ShouldBeNil)
+	})
+}
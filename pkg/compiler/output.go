@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// redirectPrintLua makes Lua's print, which println becomes,
+// write through Go, where fmt writes too, so that the two
+// reach an output set by SetOutput in order.
+const redirectPrintLua = `
+print = function(...)
+   local n = select("#", ...)
+   local s = {}
+   for i = 1, n do
+      s[i] = tostring((select(i, ...)))
+   end
+   __gi_output(table.concat(s, "\t") .. "\n")
+end
+`
+
+// SetOutput sends what the session prints, by println or
+// the fmt package, to w rather than to standard output,
+// while Eval runs; nil sends it back. A server uses it to
+// return each eval's output to the client that sent it.
+//
+// The output is the Interp's own, so Interps in one process
+// can each have theirs. Go code that writes to os.Stdout
+// itself, as fmt.Fprintln(os.Stdout) does, is not caught.
+func (it *Interp) SetOutput(w io.Writer) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return fmt.Errorf("Interp is closed")
+	}
+	if w != nil {
+		if err := it.hookPrint(); err != nil {
+			return err
+		}
+	}
+	it.inc.out.set(w)
+	return nil
+}
+
+// hookPrint makes Lua's print write through the Interp's
+// output, once; it.mut is held. The REPL needs this under
+// -dumb-terminal too, as C's stdout, when a pipe, is not
+// flushed by line.
+func (it *Interp) hookPrint() error {
+	if it.outputHooked || it.cfg.NoPrelude {
+		return nil
+	}
+	out := it.inc.out
+	t := it.lvm.goro.newTicket(redirectPrintLua, false)
+	t.regmap["__gi_output"] = func(s string) {
+		io.WriteString(out, s)
+	}
+	if err := t.Do(); err != nil {
+		return err
+	}
+	it.outputHooked = true
+	return nil
+}
+
+// outputSwitch writes to the output set by SetOutput,
+// or else to os.Stdout, as chosen at each write.
+type outputSwitch struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *outputSwitch) set(w io.Writer) {
+	o.mu.Lock()
+	o.w = w
+	o.mu.Unlock()
+}
+
+func (o *outputSwitch) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.w == nil {
+		return os.Stdout.Write(p)
+	}
+	return o.w.Write(p)
+}
+
+// overrides returns the members of the shadow package
+// path that are swapped for ones writing to o, or nil.
+func (o *outputSwitch) overrides(path string) map[string]interface{} {
+	if path != "fmt" {
+		return nil
+	}
+	return map[string]interface{}{
+		"Print": func(a ...interface{}) (int, error) {
+			return fmt.Fprint(o, a...)
+		},
+		"Printf": func(format string, a ...interface{}) (int, error) {
+			return fmt.Fprintf(o, format, a...)
+		},
+		"Println": func(a ...interface{}) (int, error) {
+			return fmt.Fprintln(o, a...)
+		},
+	}
+}
@@ -36,6 +36,24 @@ type pkgContext struct {
 	fileSet      *token.FileSet
 	files        []*ast.File
 	errList      ErrorList
+
+	// hoistedAnon, when not nil, collects the anonymous
+	// types first met in the body of a top-level func, to
+	// be declared before the func rather than inside it:
+	// the func may be called after code that uses them.
+	hoistedAnon *[]byte
+
+	// cover, if not nil, has every statement
+	// instrumented for coverage.
+	cover *coverage
+
+	// markStmts has every statement preceded by a
+	// comment holding its position; see luaSourceMap.
+	markStmts bool
+
+	// inline, when not nil, has the funcs whose calls are
+	// replaced by their bodies; see inline.go.
+	inline map[*types.Func]*inlineFunc
 }
 
 func (p *pkgContext) SelectionOf(e *ast.SelectorExpr) (selection, bool) {
@@ -93,6 +111,27 @@ type funcContext struct {
 	TypeNameSetting typeNameSetting
 
 	topLevelRepl bool
+
+	// typ and body are the function's, nil at top level.
+	typ  *ast.FuncType
+	body *ast.BlockStmt
+
+	// flat has the slices that the flat loops being
+	// translated reach directly, by index; see flatloop.go.
+	flat map[*types.Var]flatSlice
+
+	// scalars has the local structs kept in the locals of
+	// their fields; see escape.go.
+	scalars map[*types.Var]*scalarStruct
+
+	// inlineArgs, while the body of a func is inlined,
+	// has the Lua its parameters are bound to.
+	inlineArgs map[types.Object]string
+
+	// strBufs has the string locals whose loops being
+	// translated keep what they add in tables; see
+	// strbuild.go.
+	strBufs map[*types.Var]stringBuffer
 }
 
 type flowData struct {
@@ -104,6 +143,10 @@ type flowData struct {
 type ImportContext struct {
 	Packages map[string]*types.Package
 	Import   func(string) (*Archive, error)
+
+	// ForeignMethods, if set, lets the REPL declare methods
+	// on imported types; see types.Config.ForeignMethods.
+	ForeignMethods func(recv *types.Named) error
 }
 
 // packageImporter implements go/types.Importer interface.
@@ -246,7 +289,7 @@ func (c *funcContext) translateToplevelFunction(fun *ast.FuncDecl, info *analysi
 	if isPointer {
 		namedRecvType = ptr.Elem().(*types.Named)
 	}
-	typeName := "__type__." + c.objectName(namedRecvType.Obj())
+	typeName := c.typeRef(namedRecvType.Obj())
 	funName := fun.Name.Name
 	if reservedKeywords[funName] {
 		funName += "_"
@@ -285,8 +328,17 @@ func (c *funcContext) translateToplevelFunction(fun *ast.FuncDecl, info *analysi
 	if isWrapped(recvType) {
 		value = fmt.Sprintf("%s(%s)", typeName, value)
 	}
+	jp := ", " + joinedParams
+	if joinedParams == "" {
+		jp = ""
+	}
 	code.Write(primaryFunction(false, typeName+".prototype."+funName))
-	fmt.Fprintf(code, "\t__ptrType(%s).prototype.%s = function(%s) return %s.%s(%s); end;\n", typeName, funName, joinedParams, value, funName, joinedParams)
+	fmt.Fprintf(code, "\t__ptrType(%s).prototype.%s = function(this%s) return %s:%s(%s); end;\n", typeName, funName, jp, value, funName, joinedParams)
+	if _, isBasic := namedRecvType.Underlying().(*types.Basic); isBasic {
+		// a boxed value is asserted to an interface by
+		// its type's method set.
+		fmt.Fprintf(code, "\ttable.insert(%s.methods, %s);\n", typeName, c.getMethodDetailsSig(o))
+	}
 	return code.Bytes()
 }
 
@@ -311,6 +363,8 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 		flowDatas:   map[*types.Label]*flowData{nil: {}},
 		caseCounter: 1,
 		labelCases:  make(map[*types.Label]int),
+		typ:         typ,
+		body:        body,
 	}
 	for k, v := range outerContext.allVars {
 		c.allVars[k] = v
@@ -333,6 +387,8 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 		}
 	}
 
+	c.findScalarStructs(body)
+
 	bodyOutput := string(c.CatchOutput(1, func() {
 		if len(c.Blocking) != 0 {
 			c.p.Scopes[body] = c.p.Scopes[typ]
@@ -343,6 +399,12 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 			c.resultNames = make([]ast.Expr, c.sig.Results().Len())
 			for i := 0; i < c.sig.Results().Len(); i++ {
 				result := c.sig.Results().At(i)
+				if result.Name() == "_" {
+					// each blank result is its own variable,
+					// returned as the zero value a bare
+					// return gives it.
+					c.p.objectNames[result] = c.gensym("_r")
+				}
 				objName := c.objectName(result)
 				zeroV := c.translateExpr(c.zeroValue(result.Type()), nil).String()
 
@@ -363,6 +425,15 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 				c.p.Uses[id] = result
 				c.resultNames[i] = c.setType(id, result.Type())
 			}
+		} else if c.HasDefer && c.sig != nil {
+			// unnamed results are named here, so that
+			// __actuallyCall can hand back what the
+			// return statement gave once the defers have run.
+			for i := 0; i < c.sig.Results().Len(); i++ {
+				result := c.sig.Results().At(i)
+				preComputedNamedNames = append(preComputedNamedNames, `"`+c.gensym("_r")+`"`)
+				preComputedZeroRet = append(preComputedZeroRet, c.translateExpr(c.zeroValue(result.Type()), nil).String())
+			}
 		}
 
 		if recv != nil && !isBlank(recv) {
@@ -377,6 +448,10 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 			if isWrapped(c.p.TypeOf(recv)) {
 				c.Printf("%[1]s = %[1]s.__val; -- isWrapped(recv) true at package.go:361\n", recvName)
 			}
+		} else if sig.Recv() != nil {
+			// an unnamed receiver, as in func (T) M(), still
+			// takes the first argument.
+			recvName = "_"
 		}
 
 		c.translateStmtList(body.List)
@@ -453,10 +528,8 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 
 		// jea: compute namedNames, we need!
 		if c.HasDefer {
-			if c.resultNames != nil {
-				namedNames = strings.Join(preComputedNamedNames, ", ") //fmt.Sprintf("{%s}", c.translateResultsAllQuoted(c.resultNames))
-				zeroret = strings.Join(preComputedZeroRet, ", ")
-			}
+			namedNames = strings.Join(preComputedNamedNames, ", ") //fmt.Sprintf("{%s}", c.translateResultsAllQuoted(c.resultNames))
+			zeroret = strings.Join(preComputedZeroRet, ", ")
 		}
 	}
 
@@ -471,6 +544,14 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
 
 	c.p.escapingVars = prevEV
 
+	recvInsert := ""
+	if recvName != "" {
+		recvInsert = recvName
+		if formals != "" {
+			recvInsert = recvInsert + ","
+		}
+	}
+
 	if c.HasDefer {
 		pp("jea TODO: prefix is '%s'... should we not discard?", prefix)
 		//		prefix = prefix + ...
@@ -480,13 +561,13 @@ func translateFunction(typ *ast.FuncType, recv *ast.Ident, body *ast.BlockStmt,
    local __defers={}
    local __zeroret = {%s}
    local __namedNames = {%s}
-   local __actual=function(%s)
+   local __actual=function(%s%s)
       %s
    end
    return __actuallyCall("%s", __actual, __namedNames, __zeroret, __defers, __orig)
 end
 `,
-			functionWord, functionName, zeroret, namedNames, formals,
+			functionWord, functionName, zeroret, namedNames, recvInsert, formals,
 			bodyOutput, functionName), recvName
 
 		//prefix = prefix + " __deferred = []; __deferred.index = __curGoroutine.deferStack.length; __curGoroutine.deferStack.push(__deferred);"
@@ -504,13 +585,6 @@ end
 		//bodyOutput = fmt.Sprintf("%svar %s;\n", strings.Repeat("\t", c.p.indentation+1), strings.Join(c.localVars, ", ")) + bodyOutput
 	}
 
-	recvInsert := ""
-	if recvName != "" {
-		recvInsert = recvName
-		if formals != "" {
-			recvInsert = recvInsert + ","
-		}
-	}
 	return params, fmt.Sprintf("%s%s(%s%s) \n%s%s end",
 			functionWord, functionName, recvInsert, formals,
 			bodyOutput, strings.Repeat("\t", c.p.indentation)),
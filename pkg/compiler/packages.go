@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// ImportedPackage describes a package that the session
+// imported, for :packages.
+type ImportedPackage struct {
+	Path string
+
+	// From says where the package came from: "shadow" for
+	// a Go package gi was built with, bound to Lua through
+	// its shadow; "gi" for one gi implements itself, such as
+	// gi/ffi or testing; "lua" for a Lua module; "shim" for
+	// a package imported from its shim, as pkg/shim tells;
+	// "plugin" for one loaded from a Go plugin.
+	From string
+
+	// Version is that of the Go toolchain for the standard
+	// library, that of its module for another shadow, "gi"
+	// for gi's own, and "-" if not known, or for a shim or
+	// a plugin.
+	Version string
+
+	Time     time.Duration // to import it
+	LuaBytes int           // of Lua run to bind it
+}
+
+func (p *ImportedPackage) String() string {
+	size := "-"
+	if p.LuaBytes > 0 {
+		size = byteSize(uint64(p.LuaBytes))
+	}
+	return fmt.Sprintf("%-28s %-6s %-12s %10v %10s", p.Path, p.From, p.Version, p.Time.Round(time.Microsecond), size)
+}
+
+// Packages lists the packages imported so far, in the order
+// they were first imported.
+func (it *Interp) Packages() []ImportedPackage {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return append([]ImportedPackage(nil), it.inc.imported...)
+}
+
+// noteImport records the import of path, begun at start, if
+// it succeeded and is the first of path. lua is the Lua that
+// bound it.
+func (ic *IncrState) noteImport(path string, start time.Time, lua []byte, arch *Archive, err error) {
+	if err != nil || arch == nil {
+		return
+	}
+	for _, p := range ic.imported {
+		if p.Path == path {
+			return
+		}
+	}
+	p := ImportedPackage{Path: path, Time: time.Since(start), LuaBytes: len(lua)}
+	switch {
+	case ic.shimmed[path] != "":
+		p.From, p.Version = "shim", "-"
+	case ic.cfg != nil && ic.cfg.Plugins[path] != "":
+		p.From, p.Version = "plugin", "-"
+	case isLuaModPath(path):
+		p.From, p.Version = "lua", "-"
+	case isShadowPkg(arch.Pkg):
+		p.From, p.Version = "shadow", moduleVersion(path)
+	default:
+		p.From, p.Version = "gi", "gi"
+	}
+	ic.imported = append(ic.imported, p)
+}
+
+// moduleVersion is the version of the Go package path that
+// gi was built with.
+func moduleVersion(path string) string {
+	if !strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+		return runtime.Version()
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, m := range bi.Deps {
+			if path == m.Path || strings.HasPrefix(path, m.Path+"/") {
+				return m.Version
+			}
+		}
+	}
+	return "-"
+}
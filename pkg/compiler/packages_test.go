@@ -0,0 +1,38 @@
+package compiler
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1391PackagesListsTheImports(t *testing.T) {
+
+	cv.Convey(":packages lists each package imported once, where it came from, its version, and the Lua that bound it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		cv.So(it.Packages(), cv.ShouldBeEmpty)
+		panicOn(it.Eval(`import "bytes"`))
+		panicOn(it.Eval(`import "errors"`))
+		panicOn(it.Eval(`import "bytes"`))
+		cv.So(it.Eval(`import "no/such/pkg"`), cv.ShouldNotBeNil)
+
+		ps := it.Packages()
+		cv.So(len(ps), cv.ShouldEqual, 2)
+		cv.So(ps[0].Path, cv.ShouldEqual, "bytes")
+		cv.So(ps[0].From, cv.ShouldEqual, "shadow")
+		cv.So(ps[0].Version, cv.ShouldEqual, runtime.Version())
+		cv.So(ps[0].LuaBytes, cv.ShouldBeGreaterThan, 0)
+		cv.So(ps[0].Time, cv.ShouldBeGreaterThan, 0)
+		cv.So(ps[1].Path, cv.ShouldEqual, "errors")
+		cv.So(ps[1].From, cv.ShouldEqual, "gi")
+
+		line := ps[1].String()
+		cv.So(strings.Fields(line)[:3], cv.ShouldResemble, []string{"errors", "gi", "gi"})
+		cv.So(strings.HasSuffix(line, " -"), cv.ShouldBeTrue)
+	})
+}
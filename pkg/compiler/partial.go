@@ -0,0 +1,117 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// ErrPartialInput is returned for an input of which some
+// top level declarations or statements did not type check.
+// Those were left out; the rest was translated, and is run,
+// as usual.
+type ErrPartialInput struct {
+	// Errs holds the error of each part left out,
+	// with its position in the input.
+	Errs []error
+
+	// kept is the input, less the parts left out.
+	kept string
+}
+
+func (e *ErrPartialInput) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "left out %d part(s) of the input that did not type check:", len(e.Errs))
+	for _, err := range e.Errs {
+		b.WriteString("\n\t")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// translateSkipping translates src without the top level
+// part holding typeErr, and then without the part holding
+// the next error, if any, and so on. A part that used one
+// left out fails in turn. Parts are blanked out rather than
+// cut, so positions in the errors are those of src. If the
+// errors cannot be pinned on parts, or every part fails,
+// firstErr is returned and scope is left as in snap.
+func translateSkipping(inc *IncrState, src []byte, snap scopeSnapshot, typeErr *types.Error, firstErr error) (string, error) {
+	if !bytes.Equal(inc.prependAns(src), src) {
+		// calculator input, rewritten before checking.
+		return "", firstErr
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil || len(file.Nodes) < 2 {
+		return "", firstErr
+	}
+	type part struct {
+		beg, end int
+		err      error // why it was left out
+	}
+	parts := make([]part, len(file.Nodes))
+	for i, n := range file.Nodes {
+		beg := fset.Position(n.Pos()).Offset
+		end := fset.Position(n.End()).Offset
+		// take along a ';' that ends it on the same line.
+		for j := end; j < len(src) && src[j] != '\n'; j++ {
+			if src[j] == ';' {
+				end = j + 1
+				break
+			}
+			if src[j] != ' ' && src[j] != '\t' {
+				break
+			}
+		}
+		parts[i] = part{beg: beg, end: end}
+	}
+
+	scope := inc.pkgScope()
+	nInput := inc.nInput
+	rest := append([]byte(nil), src...)
+	left := len(parts)
+	for {
+		off := typeErr.Fset.Position(typeErr.Pos).Offset
+		k := -1
+		for i := range parts {
+			if parts[i].err == nil && parts[i].beg <= off && off < parts[i].end {
+				k = i
+			}
+		}
+		if k < 0 {
+			return "", firstErr
+		}
+		parts[k].err = *typeErr
+		left--
+		for j := parts[k].beg; j < parts[k].end; j++ {
+			if rest[j] != '\n' {
+				rest[j] = ' '
+			}
+		}
+		if left == 0 {
+			return "", firstErr
+		}
+
+		inc.nInput = nInput - 1 // still the same input.
+		translation, te, err := translateOnce(inc, rest)
+		if err == nil {
+			e := &ErrPartialInput{kept: string(rest)}
+			for _, pt := range parts {
+				if pt.err != nil {
+					e.Errs = append(e.Errs, pt.err)
+				}
+			}
+			return translation, e
+		}
+		snap.restore(scope)
+		if te == nil {
+			return "", firstErr
+		}
+		typeErr = te
+	}
+}
@@ -0,0 +1,45 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1328BadDeclarationDoesNotSinkTheInput(t *testing.T) {
+
+	cv.Convey(`when one declaration of a paste fails to type check, the others are installed, the failures are reported with their positions, and nothing of them is left in scope`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(`func A() int { return B() }
+func B() int { return "x" }
+func C() int { return 3 }
+var c = C() + 2; d := "a" + 1; e := 5`)
+		partial, ok := err.(*ErrPartialInput)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(len(partial.Errs), cv.ShouldEqual, 3)
+		// in input order; A fails for want of B.
+		cv.So(partial.Errs[0].Error(), cv.ShouldEqual, "repl[1]:1:23: undeclared name: B")
+		cv.So(partial.Errs[1].Error(), cv.ShouldStartWith, "repl[1]:2:23: cannot convert")
+		cv.So(partial.Errs[2].Error(), cv.ShouldStartWith, "repl[1]:4:23: cannot convert")
+
+		panicOn(it.Eval(`z := C() + c + e`))
+		LuaMustInt64(it.lvm, "z", 13)
+		for _, name := range []string{"A", "B", "d"} {
+			cv.So(it.inc.pkgScope().Lookup(name), cv.ShouldBeNil)
+		}
+
+		// the scope is as it was after a plain failure too.
+		_, err = it.Translate(`f := "a" + 1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(it.inc.pkgScope().Lookup("f"), cv.ShouldBeNil)
+
+		// the ones left out can be entered again, fixed.
+		panicOn(it.Eval(`func B() int { return 10 }
+func A() int { return B() }
+w := A()`))
+		LuaMustInt64(it.lvm, "w", 10)
+	})
+}
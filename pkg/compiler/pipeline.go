@@ -0,0 +1,405 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Under -pipeline, a large input, such as a pasted file, is
+// not translated whole before any of it runs.
+// splitForPipeline cuts it into chunks of top level
+// declarations, each using only what it and the chunks
+// before it declare. runPipelined translates the chunks in
+// order on a goroutine, and runs each as soon as it is
+// translated, while the next one is.
+//
+// So a chunk that fails to translate, for a reason other
+// than a type error, is found only once the chunks before
+// it have run, not before any of the input has, as it is
+// when the input is translated whole. What they declared is
+// undone then, but not what they did, such as print.
+
+const (
+	// pipelineMinParts is the fewest top level parts of
+	// an input that is pipelined.
+	pipelineMinParts = 16
+
+	// pipelineChunks is the most chunks an input is cut into.
+	pipelineChunks = 8
+)
+
+// chunkSpan is a chunk of an input src: the parts in
+// src[beg:end].
+type chunkSpan struct {
+	beg, end int
+}
+
+// blank returns src with all but the chunk's parts blanked
+// out, so that positions in it are those in src.
+func (s chunkSpan) blank(src []byte) []byte {
+	chunk := make([]byte, len(src))
+	for j, c := range src {
+		if c == '\n' || (s.beg <= j && j < s.end) {
+			chunk[j] = c
+		} else {
+			chunk[j] = ' '
+		}
+	}
+	return chunk
+}
+
+// splitForPipeline returns src cut into chunks to pipeline,
+// or nil if it is to be translated whole. The imports all go
+// in the first chunk; the statements, and what follows them,
+// in the last.
+func splitForPipeline(inc *IncrState, src []byte) []chunkSpan {
+	if inc.cfg == nil || !inc.cfg.Pipeline || inc.cfg.CalculatorMode || inc.cover != nil || inc.inline {
+		return nil
+	}
+	if !bytes.Equal(inc.prependAns(src), src) {
+		return nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil || len(file.Nodes) < pipelineMinParts {
+		return nil
+	}
+	nodes := file.Nodes
+	n := len(nodes)
+
+	// no cut comes before first, the part after the last
+	// import, nor after last, the first statement.
+	first, last := 0, n
+	declared := make(map[string]int) // name -> the last part declaring it
+	declare := func(id *ast.Ident, i int) {
+		if id != nil && id.Name != "_" {
+			declared[id.Name] = i
+		}
+	}
+	for i, nd := range nodes {
+		switch d := nd.(type) {
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ImportSpec:
+					if s.Name != nil && s.Name.Name == "." {
+						return nil
+					}
+					first = i + 1
+				case *ast.TypeSpec:
+					declare(s.Name, i)
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						declare(id, i)
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				// a method adds to its receiver's type.
+				declare(recvBaseIdent(d.Recv.List[0].Type), i)
+			} else {
+				declare(d.Name, i)
+			}
+		default:
+			if last == n {
+				last = i
+			}
+			if as, ok := nd.(*ast.AssignStmt); ok && as.Tok == token.DEFINE {
+				for _, lhs := range as.Lhs {
+					id, _ := lhs.(*ast.Ident)
+					declare(id, i)
+				}
+			}
+		}
+	}
+
+	// reach[i] is the last part that part i uses a name of.
+	// A cut after part i is good if no part up to i reaches
+	// past it.
+	reach := make([]int, n)
+	for i, nd := range nodes {
+		reach[i] = i
+		ast.Inspect(nd, func(x ast.Node) bool {
+			if id, ok := x.(*ast.Ident); ok {
+				if j, ok := declared[id.Name]; ok && j > reach[i] {
+					reach[i] = j
+				}
+			}
+			return true
+		})
+	}
+	size := (n + pipelineChunks - 1) / pipelineChunks
+	var ends []int // the last part of each chunk
+	far, beg := 0, 0
+	for i := 0; i < last && i+1 < n; i++ {
+		if reach[i] > far {
+			far = reach[i]
+		}
+		if far == i && i+1 >= first && i+1-beg >= size {
+			ends = append(ends, i)
+			beg = i + 1
+		}
+	}
+	if len(ends) == 0 {
+		return nil
+	}
+
+	chunks := make([]chunkSpan, 0, len(ends)+1)
+	start := 0
+	for k := 0; k <= len(ends); k++ {
+		stop := len(src)
+		if k < len(ends) {
+			stop = partEnd(src, fset.Position(nodes[ends[k]].End()).Offset)
+		}
+		chunks = append(chunks, chunkSpan{start, stop})
+		start = stop
+	}
+	return chunks
+}
+
+// partEnd takes the end of a part at offset end in src along
+// to a ';' that ends it on the same line.
+func partEnd(src []byte, end int) int {
+	for j := end; j < len(src) && src[j] != '\n'; j++ {
+		if src[j] == ';' {
+			return j + 1
+		}
+		if src[j] != ' ' && src[j] != '\t' {
+			break
+		}
+	}
+	return end
+}
+
+// recvBaseIdent returns the name of the base type of a
+// method's receiver type, or nil.
+func recvBaseIdent(typ ast.Expr) *ast.Ident {
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t
+		default:
+			return nil
+		}
+	}
+}
+
+// pipedChunk is a chunk, translated.
+type pipedChunk struct {
+	lua   string
+	saved []string // the names it is the first chunk to change
+	save  string   // Lua that saves their values
+	kept  string   // the chunk, less the parts left out; empty if all were
+	errs  []error  // the type errors of the parts left out
+	err   error    // it failed to translate for another reason
+}
+
+// translateChunk translates a chunk as translateAndCatchPanic
+// does an input, leaving out the parts that do not type check,
+// and the whole chunk if none do.
+func translateChunk(inc *IncrState, chunk []byte) (pc pipedChunk) {
+	defer func() {
+		if r := recover(); r != nil {
+			pc = pipedChunk{err: fmt.Errorf("%v", r)}
+		}
+	}()
+	scope := inc.pkgScope()
+	snap := takeScopeSnapshot(scope)
+	translation, typeErr, err := translateOnce(inc, chunk)
+	if err == nil {
+		return pipedChunk{lua: translation, kept: string(chunk)}
+	}
+	snap.restore(scope)
+	if typeErr == nil {
+		return pipedChunk{err: err}
+	}
+	translation, err = translateSkipping(inc, chunk, snap, typeErr, err)
+	if partial, ok := err.(*ErrPartialInput); ok {
+		return pipedChunk{lua: translation, kept: partial.kept, errs: partial.Errs}
+	}
+	return pipedChunk{errs: []error{*typeErr}}
+}
+
+// pipelined is what runPipelined made of an input.
+type pipelined struct {
+	kept    string           // the input, less the parts left out
+	partial *ErrPartialInput // nil unless parts were left out
+	last    string           // the translation run last
+	trErr   error            // the input failed to translate, and has been undone
+}
+
+// runPipelined translates the chunks of src, from
+// splitForPipeline, on a goroutine, and runs each under ctx
+// as soon as it is translated; a chunk is blanked out of src
+// only when it is its turn to be. A part that does not type
+// check is left out, as by translateAndCatchPanic. Should a
+// chunk fail to translate otherwise, or the run be
+// interrupted, the chunks translated and run are undone, and
+// scope is rolled back to snap. A chunk that panics at run
+// time is the last run. The caller must hold it.mut.
+func (it *Interp) runPipelined(ctx context.Context, src []byte, chunks []chunkSpan, scope *types.Scope, snap scopeSnapshot) (res pipelined, err error) {
+	inc := it.inc
+	if d := it.cfg.MaxEvalTime; d > 0 {
+		// for the whole input, not each chunk.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	nInput := inc.nInput + 1
+	if inc.inputs == nil {
+		inc.inputs = make(map[string][]byte)
+	}
+	inc.inputs[fmt.Sprintf("repl[%d]", nInput)] = append([]byte(nil), src...)
+
+	out := make(chan pipedChunk, 1)
+	stop := make(chan struct{})
+	var files []*token.File // of the chunks translated
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(out)
+		saved := make(map[string]bool)
+		for _, chunk := range chunks {
+			inc.nInput = nInput - 1 // all the same input.
+			pc := translateChunk(inc, chunk.blank(src))
+			if pc.err == nil && pc.kept != "" {
+				files = append(files, inc.lastFile)
+				for _, name := range snap.changedNames(scope) {
+					if !saved[name] {
+						saved[name] = true
+						pc.saved = append(pc.saved, name)
+					}
+				}
+				pc.save = addSavedLuaGlobalsCode(pc.saved, scope)
+			}
+			select {
+			case out <- pc:
+			case <-stop:
+				return
+			}
+			if pc.err != nil {
+				return
+			}
+		}
+	}()
+	// halt stops the translator, and waits until it is
+	// done with scope.
+	halt := func() {
+		close(stop)
+		wg.Wait()
+	}
+	var restorable []string // the names saved so far
+	rollback := func() {
+		snap.restore(scope)
+		for _, f := range files {
+			inc.lastFile = f
+			inc.forgetLastInput()
+		}
+		panicOn(LuaRun(it.lvm, restoreLuaGlobalsCode(restorable), false))
+	}
+
+	panicOn(LuaRun(it.lvm, "__gi_rollbackSaved = {};", false))
+	kept := bytes.Repeat([]byte{' '}, len(src))
+	for j, c := range src {
+		if c == '\n' {
+			kept[j] = c
+		}
+	}
+	var errs []error
+	nkept := 0
+	for pc := range out {
+		if pc.err != nil {
+			halt()
+			rollback()
+			return pipelined{trErr: pc.err}, nil
+		}
+		errs = append(errs, pc.errs...)
+		if pc.kept == "" {
+			continue
+		}
+		nkept++
+		for j := range pc.kept {
+			if pc.kept[j] != ' ' {
+				kept[j] = pc.kept[j]
+			}
+		}
+		if ctx.Err() != nil {
+			halt()
+			rollback()
+			return res, &ErrEvalCanceled{Cause: context.Cause(ctx)}
+		}
+		panicOn(LuaRun(it.lvm, pc.save, false))
+		restorable = append(restorable, pc.saved...)
+		res.last = pc.lua
+		if err = it.runGuarded(ctx, pc.lua, true, nil, nil); err != nil {
+			halt()
+			if _, ok := err.(*ErrEvalCanceled); ok {
+				rollback()
+			} else {
+				panicOn(LuaRun(it.lvm, "__gi_rollbackSaved = nil;", false))
+			}
+			return res, err
+		}
+		if luaGlobalString(it.lvm, "__lastEvalErr") != "" {
+			// it panicked: as in an input run whole, what
+			// follows does not run.
+			halt()
+			break
+		}
+	}
+	wg.Wait()
+	panicOn(LuaRun(it.lvm, "__gi_rollbackSaved = nil;", false))
+	if nkept == 0 {
+		// nothing type checked.
+		return pipelined{trErr: errs[0]}, nil
+	}
+	res.kept = string(kept)
+	if len(errs) > 0 {
+		res.partial = &ErrPartialInput{Errs: errs, kept: res.kept}
+	}
+	return res, nil
+}
+
+// evalPipelined is EvalContext for an input cut into chunks.
+func (it *Interp) evalPipelined(ctx context.Context, src []byte, chunks []chunkSpan, scope *types.Scope, snap scopeSnapshot) error {
+	var res pipelined
+	run := func() (err error) {
+		res, err = it.runPipelined(ctx, src, chunks, scope, snap)
+		return
+	}
+	var err error
+	if it.cfg.Stats {
+		it.lastStats, err = measure(it.lvm, run)
+	} else {
+		err = run()
+	}
+	if res.trErr != nil {
+		return res.trErr
+	}
+	it.evalCount++
+	if err != nil {
+		return err
+	}
+	it.recordSource(res.kept)
+	it.lastDiff = diffScope(snap, scope)
+	if err := it.lastEvalError(); err != nil {
+		return err
+	}
+	if res.partial != nil {
+		return res.partial
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package compiler
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// pastedFuncs is a paste of n funcs, fN returning N, between
+// an import and a statement; mid is put after f(n/2).
+func pastedFuncs(n int, mid string) string {
+	var b strings.Builder
+	b.WriteString("import \"strings\"\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "func f%d() int { return %d }\n", i, i)
+		if i == n/2 {
+			b.WriteString(mid + "\n")
+		}
+	}
+	b.WriteString("func g() int { return len(strings.Repeat(\"a\", 3)) }\n")
+	b.WriteString("x := f3() + f19() + g()\n")
+	return b.String()
+}
+
+func Test1412LargeInputsArePipelinedChunkByChunk(t *testing.T) {
+
+	cv.Convey("under -pipeline, a large input is cut where no declaration uses a later one, and each chunk runs once it is translated", t, func() {
+		src := pastedFuncs(20, "")
+		it0, err := NewInterp(nil)
+		panicOn(err)
+		defer it0.Close()
+		cv.So(splitForPipeline(it0.inc, []byte(src)), cv.ShouldBeNil)
+
+		cfg := NewGIConfig()
+		cfg.Pipeline = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		chunks := splitForPipeline(it.inc, []byte(src))
+		cv.So(len(chunks), cv.ShouldBeGreaterThan, 1)
+		at := 0
+		for _, c := range chunks {
+			cv.So(c.beg, cv.ShouldEqual, at)
+			cv.So(c.end, cv.ShouldBeGreaterThan, c.beg)
+			at = c.end
+		}
+		cv.So(at, cv.ShouldEqual, len(src))
+		cv.So(src[chunks[0].beg:chunks[0].end], cv.ShouldContainSubstring, `import "strings"`)
+		last := chunks[len(chunks)-1]
+		cv.So(src[last.beg:last.end], cv.ShouldContainSubstring, "x := f3() + f19() + g()")
+		blank := string(last.blank([]byte(src)))
+		cv.So(len(blank), cv.ShouldEqual, len(src))
+		cv.So(strings.Count(blank, "\n"), cv.ShouldEqual, strings.Count(src, "\n"))
+		cv.So(blank, cv.ShouldNotContainSubstring, "import")
+
+		// a small input is translated whole, and no cut comes
+		// between a use and a later declaration.
+		cv.So(splitForPipeline(it.inc, []byte("func a() int { return 1 }\nb := a()")), cv.ShouldBeNil)
+		fsrc := strings.Replace(src, "{ return 0 }", "{ return f19() }", 1)
+		forward := splitForPipeline(it.inc, []byte(fsrc))
+		cv.So(len(forward), cv.ShouldBeGreaterThan, 1)
+		cv.So(fsrc[forward[0].beg:forward[0].end], cv.ShouldContainSubstring, "func f19()")
+
+		panicOn(it.Eval(src))
+		LuaMustInt64(it.lvm, "x", 25)
+		panicOn(it.Eval("y := f10()"))
+		LuaMustInt64(it.lvm, "y", 10)
+	})
+
+	cv.Convey("a part that does not type check is left out of its chunk, and a chunk that fails otherwise undoes the input", t, func() {
+		cfg := NewGIConfig()
+		cfg.Pipeline = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(pastedFuncs(20, `func bad() int { return "s" }`))
+		partial, ok := err.(*ErrPartialInput)
+		cv.So(ok, cv.ShouldBeTrue)
+		cv.So(len(partial.Errs), cv.ShouldEqual, 1)
+		cv.So(partial.Errs[0].Error(), cv.ShouldContainSubstring, `"s"`)
+		LuaMustInt64(it.lvm, "x", 25)
+
+		it2, err := NewInterp(cfg)
+		panicOn(err)
+		defer it2.Close()
+		err = it2.Eval(pastedFuncs(20, "func h() { var int int; _ = int }"))
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "bad identifier")
+		err = it2.Eval("z := f0()")
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "f0")
+	})
+
+	cv.Convey("at the REPL, under -pipeline, a pasted file is pipelined too, and what it defined is there for the next input", t, func() {
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal", "-pipeline"}))
+		panicOn(cfg.ValidateConfig())
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		panicOn(r.Eval(pastedFuncs(20, "")))
+		LuaMustInt64(r.lvm, "x", 25)
+		panicOn(r.Eval("y := f17() + x"))
+		LuaMustInt64(r.lvm, "y", 42)
+	})
+}
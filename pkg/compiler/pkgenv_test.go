@@ -0,0 +1,101 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/shim"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func init() {
+	shim.Register("example.com/gitest/counted", shim.Shim{
+		Go:  "package counted\n\nvar Loads int\n",
+		Lua: "__gitestCountedLoads = (__gitestCountedLoads or 0) + 1\ncounted.Loads = int64(__gitestCountedLoads)\n",
+	})
+}
+
+func Test1395PackagesHaveTablesOfTheirOwn(t *testing.T) {
+
+	cv.Convey("each imported package is reached through its own table in __packages, so packages of the same name do not collide, and a Lua module leaves the globals alone", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(LuaRun(it.lvm, `
+package.preload["gitest.sort"] = function()
+  return { ints = function(t) return "lua sort" end }
+end
+package.preload["gitest.rand"] = function()
+  return { intn = function(n) return n - 1 end }
+end`, false))
+
+		panicOn(it.Eval(`import (
+	"sort"
+	"math/rand"
+	lsort "lua/gitest/sort"
+	lrand "lua/gitest/rand"
+)
+xs := []int{3, 1, 2}
+sort.Ints(xs)
+a := xs[0]
+b := lsort.Ints(xs).(string)
+c := rand.Intn(1)
+d := lrand.Intn(5).(float64)`))
+		LuaMustInt64(it.lvm, "a", 1)
+		LuaMustString(it.lvm, "b", "lua sort")
+		LuaMustInt64(it.lvm, "c", 0)
+		LuaMustFloat64(it.lvm, "d", 4)
+
+		// the module's members go in its table, not in Lua's string.
+		panicOn(it.Eval(`import lstring "lua/string"
+e := lstring.Upper("go").(string)`))
+		LuaMustString(it.lvm, "e", "GO")
+		panicOn(LuaRun(it.lvm, `f = (rawget(string, "Upper") == nil)`, false))
+		LuaMustBool(it.lvm, "f", true)
+	})
+
+	cv.Convey("an import leaves the globals alone, and the types of a package are kept in __type__ by its path", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+r := rand.New(rand.NewSource(1))
+ok := r.Intn(10) >= 0
+var d time.Duration = 3 * time.Second
+secs := int64(d / time.Second)
+debug.SetGCPercent(100)`))
+		LuaMustBool(it.lvm, "ok", true)
+		LuaMustInt64(it.lvm, "secs", 3)
+		panicOn(LuaRun(it.lvm, `
+noGlobals = rawget(_G, "rand") == nil and rawget(_G, "time") == nil and rawget(_G, "__ctor__time") == nil
+luaDebug = type(debug.traceback) == "function"
+byPath = __type__["math/rand"].Rand ~= nil and __type__["time"].Location ~= nil and rawget(__type__, "rand") == nil`, false))
+		LuaMustBool(it.lvm, "noGlobals", true)
+		LuaMustBool(it.lvm, "luaDebug", true)
+		LuaMustBool(it.lvm, "byPath", true)
+
+		tr, err := it.inc.Tr([]byte("var p *rand.Rand"))
+		panicOn(err)
+		cv.So(string(tr), cv.ShouldContainSubstring, `__type__["math/rand"].Rand`)
+	})
+
+	cv.Convey("a package is initialized once, by its Lua, when it is first imported; the code that imports it calls no _init", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		tr, err := it.inc.Tr([]byte(`import "example.com/gitest/counted"`))
+		panicOn(err)
+		cv.So(string(tr), cv.ShouldNotContainSubstring, "_init")
+		panicOn(it.Eval(`import "example.com/gitest/counted"
+a := counted.Loads`))
+		panicOn(it.Eval(`import "example.com/gitest/counted"
+b := counted.Loads`))
+		LuaMustInt64(it.lvm, "a", 1)
+		LuaMustInt64(it.lvm, "b", 1)
+	})
+}
@@ -0,0 +1,583 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowPlotSrc declares the gi/plot package, charts for a
+// quick look at data, to the type checker. prelude/zplot.lua
+// keeps the series; the drawing is done here, in Go.
+//
+//	plot.Line(ys)
+//	plot.New("latency").Histogram("ms", ms, 20).Save("latency.png")
+const shadowPlotSrc = `package plot
+
+import "gi/display"
+
+// Plot is a chart of one or more series on shared axes.
+// Width and Height are in pixels; 0 means 640 by 400.
+type Plot struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Width  int
+	Height int
+}
+
+// New returns an empty Plot with the title.
+func New(title string) *Plot { return nil }
+
+// Line adds a series drawn as a line through the points
+// (xs[i], ys[i]); name, if not empty, is its legend.
+func (p *Plot) Line(name string, xs, ys []float64) *Plot { return p }
+
+// Scatter adds a series drawn as a dot at each point.
+func (p *Plot) Scatter(name string, xs, ys []float64) *Plot { return p }
+
+// Histogram adds a histogram of values, in bins of equal
+// width; bins <= 0 chooses their number by Sturges' rule.
+func (p *Plot) Histogram(name string, values []float64, bins int) *Plot { return p }
+
+// SVG draws the plot as an SVG image.
+func (p *Plot) SVG() string { return "" }
+
+// PNG draws the plot as a PNG image. Its only text is the
+// numbers on the axes; the title, the axis labels and the
+// legend are drawn in the SVG.
+func (p *Plot) PNG() []byte { return nil }
+
+// Save writes the plot to path: as SVG if path ends in
+// .svg, and otherwise as PNG.
+func (p *Plot) Save(path string) error { return nil }
+
+// Display makes a Plot a display.Displayer: shown at the
+// prompt, it is displayed as SVG and PNG by a frontend, or
+// in a terminal, saved as gi-plot-N.png in the current
+// directory.
+func (p *Plot) Display() display.Data { return display.Data{} }
+
+// Line plots ys against their indexes.
+func Line(ys []float64) *Plot { return nil }
+
+// Scatter plots the points (xs[i], ys[i]).
+func Scatter(xs, ys []float64) *Plot { return nil }
+
+// Histogram plots a histogram of values; see Plot.Histogram.
+func Histogram(values []float64, bins int) *Plot { return nil }
+`
+
+var shadowPlot struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowPlotPackage returns the type checker's view of
+// gi/plot, shared by every Interp.
+func shadowPlotPackage() *types.Package {
+	shadowPlot.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "plot.go", shadowPlotSrc, 0)
+		panicOn(err)
+		conf := &types.Config{Importer: displayImporter{}}
+		pkg, _, err := conf.Check(nil, nil, "gi/plot", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowPlot.pkg = pkg
+	})
+	return shadowPlot.pkg
+}
+
+// plotSpec is a Plot as zplot.lua describes it to Go: a
+// line each for the title, the axis labels and the size,
+// tab separated, then a line for each series, of its kind,
+// name, bins, and its xs and ys, comma separated.
+type plotSpec struct {
+	title, xlabel, ylabel string
+	width, height         int
+	series                []plotSeries
+}
+
+type plotSeries struct {
+	kind   string // line, scatter or hist
+	name   string
+	xs, ys []float64
+	bins   int
+}
+
+func parsePlotSpec(s string) (*plotSpec, error) {
+	p := &plotSpec{width: 640, height: 400}
+	floats := func(s string) ([]float64, error) {
+		var fs []float64
+		for _, f := range strings.Split(s, ",") {
+			if f == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, err
+			}
+			fs = append(fs, v)
+		}
+		return fs, nil
+	}
+	for _, ln := range strings.Split(s, "\n") {
+		fld := strings.Split(ln, "\t")
+		switch fld[0] {
+		case "":
+		case "title":
+			p.title = fld[1]
+		case "xlabel":
+			p.xlabel = fld[1]
+		case "ylabel":
+			p.ylabel = fld[1]
+		case "size":
+			w, _ := strconv.Atoi(fld[1])
+			h, _ := strconv.Atoi(fld[2])
+			if w > 0 {
+				p.width = w
+			}
+			if h > 0 {
+				p.height = h
+			}
+		case "line", "scatter", "hist":
+			if len(fld) != 5 {
+				return nil, fmt.Errorf("plot: bad series '%s'", ln)
+			}
+			sr := plotSeries{kind: fld[0], name: fld[1]}
+			sr.bins, _ = strconv.Atoi(fld[2])
+			var err error
+			if sr.xs, err = floats(fld[3]); err != nil {
+				return nil, err
+			}
+			if sr.ys, err = floats(fld[4]); err != nil {
+				return nil, err
+			}
+			if sr.kind == "hist" {
+				sr.xs, sr.ys = histogramBins(sr.xs, sr.bins)
+			}
+			p.series = append(p.series, sr)
+		default:
+			return nil, fmt.Errorf("plot: bad line '%s'", ln)
+		}
+	}
+	return p, nil
+}
+
+// histogramBins counts values in bins of equal width; xs
+// holds the bins' edges, one more than the counts in ys.
+func histogramBins(values []float64, bins int) (xs, ys []float64) {
+	var finite []float64
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			finite = append(finite, v)
+		}
+	}
+	if len(finite) == 0 {
+		return nil, nil
+	}
+	if bins <= 0 {
+		bins = int(math.Ceil(math.Log2(float64(len(finite))))) + 1
+	}
+	lo, hi := finite[0], finite[0]
+	for _, v := range finite {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if lo == hi {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	width := (hi - lo) / float64(bins)
+	ys = make([]float64, bins)
+	for _, v := range finite {
+		i := int((v - lo) / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		ys[i]++
+	}
+	for i := 0; i <= bins; i++ {
+		xs = append(xs, lo+float64(i)*width)
+	}
+	return xs, ys
+}
+
+// plotFrame maps the data onto the image: the ranges of x
+// and y, widened to whole ticks, and the rectangle of the
+// plot area, in pixels.
+type plotFrame struct {
+	xlo, xhi, xstep float64
+	ylo, yhi, ystep float64
+
+	left, right, top, bottom float64
+}
+
+func (p *plotSpec) frame() *plotFrame {
+	f := &plotFrame{}
+	xlo, xhi := math.Inf(1), math.Inf(-1)
+	ylo, yhi := math.Inf(1), math.Inf(-1)
+	see := func(v float64, lo, hi *float64) {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			*lo, *hi = math.Min(*lo, v), math.Max(*hi, v)
+		}
+	}
+	for _, sr := range p.series {
+		for _, x := range sr.xs {
+			see(x, &xlo, &xhi)
+		}
+		for _, y := range sr.ys {
+			see(y, &ylo, &yhi)
+		}
+		if sr.kind == "hist" {
+			see(0, &ylo, &yhi)
+		}
+	}
+	f.xlo, f.xhi, f.xstep = niceRange(xlo, xhi)
+	f.ylo, f.yhi, f.ystep = niceRange(ylo, yhi)
+
+	f.left, f.right = 64, float64(p.width)-20
+	f.top, f.bottom = 20, float64(p.height)-40
+	if p.title != "" {
+		f.top = 36
+	}
+	return f
+}
+
+// niceRange widens [lo, hi] to whole steps of 1, 2 or 5
+// times a power of ten, about five of them.
+func niceRange(lo, hi float64) (nlo, nhi, step float64) {
+	if lo > hi {
+		lo, hi = 0, 1
+	}
+	if lo == hi {
+		d := math.Max(math.Abs(lo)/2, 1)
+		lo, hi = lo-d, hi+d
+	}
+	raw := (hi - lo) / 5
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step = mag * 10
+	for _, m := range []float64{1, 2, 5} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	return math.Floor(lo/step) * step, math.Ceil(hi/step) * step, step
+}
+
+func (f *plotFrame) px(x float64) float64 {
+	return f.left + (x-f.xlo)/(f.xhi-f.xlo)*(f.right-f.left)
+}
+
+func (f *plotFrame) py(y float64) float64 {
+	return f.bottom - (y-f.ylo)/(f.yhi-f.ylo)*(f.bottom-f.top)
+}
+
+// ticks lists the tick values from lo to hi, with their
+// labels.
+func ticks(lo, hi, step float64) (vs []float64, labels []string) {
+	prec := 0
+	if step < 1 {
+		prec = int(math.Ceil(-math.Log10(step) - 1e-9))
+	}
+	for i := 0; ; i++ {
+		v := lo + float64(i)*step
+		if v > hi+step/2 {
+			break
+		}
+		s := strconv.FormatFloat(v, 'f', prec, 64)
+		if strings.Trim(s, "-0.") == "" {
+			s = "0"
+		}
+		vs = append(vs, v)
+		labels = append(labels, s)
+	}
+	return vs, labels
+}
+
+var plotColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+}
+
+func plotColor(i int) color.RGBA {
+	return plotColors[i%len(plotColors)]
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (p *plotSpec) svg() string {
+	f := p.frame()
+	var b bytes.Buffer
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	text := func(x, y float64, anchor string, size int, s string, extra string) {
+		fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="%s" font-family="sans-serif" font-size="%d"%s>%s</text>`+"\n",
+			num(x), num(y), anchor, size, extra, html.EscapeString(s))
+	}
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		p.width, p.height, p.width, p.height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", p.width, p.height)
+	if p.title != "" {
+		text(float64(p.width)/2, 22, "middle", 14, p.title, "")
+	}
+
+	xs, xlabels := ticks(f.xlo, f.xhi, f.xstep)
+	for i, x := range xs {
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#e0e0e0"/>`+"\n", num(f.px(x)), num(f.top), num(f.px(x)), num(f.bottom))
+		text(f.px(x), f.bottom+14, "middle", 10, xlabels[i], "")
+	}
+	ys, ylabels := ticks(f.ylo, f.yhi, f.ystep)
+	for i, y := range ys {
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#e0e0e0"/>`+"\n", num(f.left), num(f.py(y)), num(f.right), num(f.py(y)))
+		text(f.left-6, f.py(y)+3.5, "end", 10, ylabels[i], "")
+	}
+	fmt.Fprintf(&b, `<path d="M%s %sV%sH%s" fill="none" stroke="black"/>`+"\n", num(f.left), num(f.top), num(f.bottom), num(f.right))
+	if p.xlabel != "" {
+		text((f.left+f.right)/2, f.bottom+32, "middle", 12, p.xlabel, "")
+	}
+	if p.ylabel != "" {
+		text(14, (f.top+f.bottom)/2, "middle", 12, p.ylabel, fmt.Sprintf(` transform="rotate(-90 14 %s)"`, num((f.top+f.bottom)/2)))
+	}
+
+	for i, sr := range p.series {
+		c := hexColor(plotColor(i))
+		switch sr.kind {
+		case "line":
+			var pts []string
+			for j := range sr.xs {
+				pts = append(pts, num(f.px(sr.xs[j]))+","+num(f.py(sr.ys[j])))
+			}
+			fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n", c, strings.Join(pts, " "))
+		case "scatter":
+			for j := range sr.xs {
+				fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="2.5" fill="%s"/>`+"\n", num(f.px(sr.xs[j])), num(f.py(sr.ys[j])), c)
+			}
+		case "hist":
+			for j, n := range sr.ys {
+				x0, x1 := f.px(sr.xs[j]), f.px(sr.xs[j+1])
+				y0, y1 := f.py(n), f.py(0)
+				fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s" fill-opacity="0.6" stroke="%s"/>`+"\n",
+					num(x0), num(y0), num(x1-x0), num(y1-y0), c, c)
+			}
+		}
+	}
+
+	// the legend, in the top right corner.
+	ly := f.top + 14
+	for i, sr := range p.series {
+		if sr.name == "" {
+			continue
+		}
+		c := hexColor(plotColor(i))
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="10" height="10" fill="%s"/>`+"\n", num(f.right-110), num(ly-9), c)
+		text(f.right-96, ly, "start", 11, sr.name, "")
+		ly += 16
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// plotGlyphs is a 3 by 5 pixel font for the numbers on
+// the axes of a PNG, a row of 3 bits to each byte.
+var plotGlyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'.': {0, 0, 0, 0, 2},
+	'-': {0, 0, 7, 0, 0},
+	'+': {0, 2, 7, 2, 0},
+	'e': {0, 7, 7, 4, 7},
+}
+
+// plotCanvas draws on an RGBA image.
+type plotCanvas struct {
+	*image.RGBA
+}
+
+func (c plotCanvas) dot(x, y int, col color.RGBA) {
+	if image.Pt(x, y).In(c.Rect) {
+		c.SetRGBA(x, y, col)
+	}
+}
+
+// line draws from (x0, y0) to (x1, y1), two pixels wide.
+func (c plotCanvas) line(x0, y0, x1, y1 float64, col color.RGBA, wide bool) {
+	n := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+	for i := 0; i <= n; i++ {
+		t := float64(i) / float64(n)
+		x := int(math.Round(x0 + t*(x1-x0)))
+		y := int(math.Round(y0 + t*(y1-y0)))
+		c.dot(x, y, col)
+		if wide {
+			c.dot(x+1, y, col)
+			c.dot(x, y+1, col)
+		}
+	}
+}
+
+// rect fills [x0, x1) by [y0, y1), blending col in by alpha.
+func (c plotCanvas) rect(x0, y0, x1, y1 int, col color.RGBA, alpha float64) {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if !image.Pt(x, y).In(c.Rect) {
+				continue
+			}
+			o := c.RGBAAt(x, y)
+			mix := func(a, b uint8) uint8 { return uint8(float64(a)*(1-alpha) + float64(b)*alpha + 0.5) }
+			c.SetRGBA(x, y, color.RGBA{mix(o.R, col.R), mix(o.G, col.G), mix(o.B, col.B), 0xff})
+		}
+	}
+}
+
+// text draws s, of the characters in plotGlyphs, at twice
+// their size, with its top left corner at (x, y).
+func (c plotCanvas) text(x, y int, s string, col color.RGBA) {
+	for _, r := range s {
+		g := plotGlyphs[r]
+		for row := 0; row < 5; row++ {
+			for bit := 0; bit < 3; bit++ {
+				if g[row]&(4>>uint(bit)) != 0 {
+					c.rect(x+2*bit, y+2*row, x+2*bit+2, y+2*row+2, col, 1)
+				}
+			}
+		}
+		x += 8
+	}
+}
+
+func plotTextWidth(s string) int {
+	return 8*len(s) - 2
+}
+
+func (p *plotSpec) png() ([]byte, error) {
+	f := p.frame()
+	c := plotCanvas{image.NewRGBA(image.Rect(0, 0, p.width, p.height))}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	black := color.RGBA{0, 0, 0, 0xff}
+	grid := color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	c.rect(0, 0, p.width, p.height, white, 1)
+
+	xs, xlabels := ticks(f.xlo, f.xhi, f.xstep)
+	for i, x := range xs {
+		c.line(f.px(x), f.top, f.px(x), f.bottom, grid, false)
+		c.text(int(f.px(x))-plotTextWidth(xlabels[i])/2, int(f.bottom)+6, xlabels[i], black)
+	}
+	ys, ylabels := ticks(f.ylo, f.yhi, f.ystep)
+	for i, y := range ys {
+		c.line(f.left, f.py(y), f.right, f.py(y), grid, false)
+		c.text(int(f.left)-6-plotTextWidth(ylabels[i]), int(f.py(y))-5, ylabels[i], black)
+	}
+	c.line(f.left, f.top, f.left, f.bottom, black, false)
+	c.line(f.left, f.bottom, f.right, f.bottom, black, false)
+
+	for i, sr := range p.series {
+		col := plotColor(i)
+		switch sr.kind {
+		case "line":
+			for j := 1; j < len(sr.xs); j++ {
+				c.line(f.px(sr.xs[j-1]), f.py(sr.ys[j-1]), f.px(sr.xs[j]), f.py(sr.ys[j]), col, true)
+			}
+		case "scatter":
+			for j := range sr.xs {
+				x, y := int(math.Round(f.px(sr.xs[j]))), int(math.Round(f.py(sr.ys[j])))
+				c.rect(x-2, y-2, x+3, y+3, col, 1)
+			}
+		case "hist":
+			for j, n := range sr.ys {
+				x0, x1 := int(math.Round(f.px(sr.xs[j]))), int(math.Round(f.px(sr.xs[j+1])))
+				y0, y1 := int(math.Round(f.py(n))), int(math.Round(f.py(0)))
+				c.rect(x0, y0, x1, y1, col, 0.6)
+				c.line(float64(x0), float64(y0), float64(x0), float64(y1), col, false)
+				c.line(float64(x0), float64(y0), float64(x1), float64(y0), col, false)
+				c.line(float64(x1), float64(y0), float64(x1), float64(y1), col, false)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, c.RGBA); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// plotRender draws the Plot spec describes, for zplot.lua:
+// as format svg, png, or png64, base64 encoded; it returns
+// the image, or an error message.
+func plotRender(spec, format string) (string, string) {
+	p, err := parsePlotSpec(spec)
+	if err != nil {
+		return "", err.Error()
+	}
+	switch format {
+	case "svg":
+		return p.svg(), ""
+	case "png", "png64":
+		img, err := p.png()
+		if err != nil {
+			return "", err.Error()
+		}
+		if format == "png64" {
+			return base64.StdEncoding.EncodeToString(img), ""
+		}
+		return string(img), ""
+	}
+	return "", "plot: unknown format " + format
+}
+
+// plotSave writes the Plot spec describes to path, or if
+// path is "", to the first gi-plot-N.png not yet in the
+// current directory, and returns the path, or an error
+// message. It needs the fs capability.
+func plotSave(policy *Policy, spec, path string) (string, string) {
+	if !policy.Allows(CapFilesystem) {
+		return "", "plot: saving a plot needs the fs capability"
+	}
+	if path == "" {
+		for n := 1; ; n++ {
+			path = fmt.Sprintf("gi-plot-%d.png", n)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+		}
+	}
+	format := "png"
+	if strings.HasSuffix(strings.ToLower(path), ".svg") {
+		format = "svg"
+	}
+	img, msg := plotRender(spec, format)
+	if msg != "" {
+		return "", msg
+	}
+	if err := ioutil.WriteFile(path, []byte(img), 0644); err != nil {
+		return "", "plot: " + err.Error()
+	}
+	return path, ""
+}
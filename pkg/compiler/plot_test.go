@@ -0,0 +1,110 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1371PlotRendersCharts(t *testing.T) {
+
+	cv.Convey("gi/plot should draw line, scatter and histogram charts as SVG and PNG, display them as both under SetRichDisplay, and otherwise save them as gi-plot-N.png", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		dir, err := ioutil.TempDir("", "gi-plot")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		wd, err := os.Getwd()
+		panicOn(err)
+		panicOn(os.Chdir(dir))
+		defer os.Chdir(wd)
+
+		panicOn(it.Eval(`import "gi/plot"
+p := plot.New("demo").
+	Line("squares", []float64{0, 1, 2, 3}, []float64{0, 1, 4, 9}).
+	Scatter("", []float64{0.5}, []float64{2}).
+	Histogram("h", []float64{1, 2, 2, 3}, 3)
+p.XLabel = "t"
+svg := p.SVG()
+img := p.PNG()
+err := p.Save("p.svg")
+ok := err == nil`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		svg := luaGlobalString(it.lvm, "svg")
+		cv.So(svg, cv.ShouldStartWith, `<svg xmlns="http://www.w3.org/2000/svg" width="640" height="400"`)
+		cv.So(svg, cv.ShouldContainSubstring, ">demo</text>")
+		cv.So(svg, cv.ShouldContainSubstring, ">squares</text>")
+		cv.So(strings.Count(svg, "<polyline"), cv.ShouldEqual, 1)
+		cv.So(strings.Count(svg, "<circle"), cv.ShouldEqual, 1)
+		cv.So(strings.Count(svg, `fill-opacity="0.6"`), cv.ShouldEqual, 3)
+		saved, err := ioutil.ReadFile("p.svg")
+		panicOn(err)
+		cv.So(string(saved), cv.ShouldEqual, svg)
+
+		panicOn(it.Eval(`s := string(img)`))
+		m, err := png.Decode(strings.NewReader(luaGlobalString(it.lvm, "s")))
+		panicOn(err)
+		cv.So(m.Bounds().Dx(), cv.ShouldEqual, 640)
+		cv.So(m.Bounds().Dy(), cv.ShouldEqual, 400)
+
+		// the lengths of xs and ys must agree.
+		err = it.Eval(`p.Line("bad", []float64{1}, nil)`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "plot: line bad has 1 xs but 0 ys")
+
+		// in a terminal, a plot shown is saved.
+		panicOn(it.Eval(`plot.Histogram([]float64{1, 2, 3}, 0)`))
+		saves, err := filepath.Glob("gi-plot-*.png")
+		panicOn(err)
+		cv.So(saves, cv.ShouldResemble, []string{"gi-plot-1.png"})
+
+		panicOn(it.SetRichDisplay(true))
+		panicOn(it.Eval(`q := plot.Line([]float64{3, 1, 2})
+q.Title = "q"
+q`))
+		ds, err := it.Displayed()
+		panicOn(err)
+		cv.So(len(ds), cv.ShouldEqual, 1)
+		cv.So(ds[0].Text(), cv.ShouldEqual, "<plot q>")
+		cv.So(ds[0]["image/svg+xml"], cv.ShouldContainSubstring, ">q</text>")
+		b, err := base64.StdEncoding.DecodeString(ds[0]["image/png"])
+		panicOn(err)
+		_, err = png.Decode(bytes.NewReader(b))
+		panicOn(err)
+		saves, err = filepath.Glob("gi-plot-*.png")
+		panicOn(err)
+		cv.So(len(saves), cv.ShouldEqual, 1)
+	})
+}
+
+func Test1371UserDisplayerIsDisplayed(t *testing.T) {
+
+	cv.Convey("a value of a type with a Display() display.Data method should be displayed at the prompt as the Data it returns", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(it.SetRichDisplay(true))
+
+		panicOn(it.Eval(`import "gi/display"
+type Money struct{ cents int }
+func (m *Money) Display() display.Data { return display.HTML("<i>$1.50</i>") }
+m := &Money{150}
+var dd display.Displayer = m`))
+		panicOn(it.Eval(`m`))
+		ds, err := it.Displayed()
+		panicOn(err)
+		cv.So(ds, cv.ShouldResemble, []DisplayData{
+			{"text/html": "<i>$1.50</i>", "text/plain": "<i>$1.50</i>"},
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A package can be imported from a Go plugin, built by gc
+// with -buildmode=plugin, so that a heavy dependency runs
+// natively while the code at the prompt that calls it is
+// interpreted. The plugin is the shadow that
+// gen-gijit-shadow-import makes for the package, with its
+// package clause changed to main:
+//
+//	gen-gijit-shadow-import github.com/foo/fast
+//	sed -i 's/^package shadow_fast$/package main/' fast.genimp.go
+//	go build -buildmode=plugin -o fast.so fast.genimp.go
+//	gi -plugins github.com/foo/fast=fast.so
+//
+// Like a shadow compiled into gi, it exports Pkg and Ctor,
+// the package's members and struct constructors, which Luar
+// bridges to Lua by reflection, and InitLua, the Lua side
+// of its types. The type checker reads the package's API
+// from its export data or source, as for any shadow. A
+// plugin must be built by the same Go, from the same
+// versions of the packages it shares with gi.
+//
+// Loading plugins makes gi a dynamically linked program,
+// which the LuaJIT it embeds must then be built for, with
+// -fPIC; so it is left out unless gi is built with
+// -tags giplugin.
+
+// parsePlugins parses the -plugins flag: comma separated
+// path=file pairs.
+func parsePlugins(spec string) (map[string]string, error) {
+	plugins := make(map[string]string)
+	for _, w := range strings.Split(spec, ",") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		eq := strings.Index(w, "=")
+		if eq <= 0 || eq == len(w)-1 {
+			return nil, fmt.Errorf("-plugins wants import/path=file.so pairs, not '%s'", w)
+		}
+		plugins[w[:eq]] = w[eq+1:]
+	}
+	return plugins, nil
+}
+
+// loadPlugin opens the plugin file for path and has t
+// register its members under the package name name, as
+// the case of a shadow compiled into gi does. A plugin is
+// native code, which policy must allow as it allows FFI.
+func loadPlugin(policy *Policy, path, name, file string, t *ticket) error {
+	if !policy.Allows(CapFFI) {
+		return fmt.Errorf("import of '%s' from plugin denied by sandbox policy: needs capability '%v'", path, CapFFI)
+	}
+	lookup, err := openPlugin(file)
+	if err != nil {
+		return fmt.Errorf("import of '%s' from plugin: %v", path, err)
+	}
+	sym := func(s string) (interface{}, error) {
+		v, err := lookup(s)
+		if err != nil {
+			return nil, fmt.Errorf("import of '%s': plugin %s is not a gi shadow: %v", path, file, err)
+		}
+		return v, nil
+	}
+	pkg, err := sym("Pkg")
+	if err != nil {
+		return err
+	}
+	ctor, err := sym("Ctor")
+	if err != nil {
+		return err
+	}
+	initLua, err := sym("InitLua")
+	if err != nil {
+		return err
+	}
+	pm, ok1 := pkg.(*map[string]interface{})
+	cm, ok2 := ctor.(*map[string]interface{})
+	il, ok3 := initLua.(func() string)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("import of '%s': plugin %s is not a gi shadow: Pkg, Ctor or InitLua has the wrong type", path, file)
+	}
+	t.regmap[name] = *pm
+	t.regmap["__ctor__"+name] = *cm
+	t.run = append(t.run, il()...)
+	return nil
+}
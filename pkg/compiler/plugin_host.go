@@ -0,0 +1,19 @@
+//go:build giplugin
+// +build giplugin
+
+package compiler
+
+import "plugin"
+
+// openPlugin opens the Go plugin file, returning the
+// lookup of its exported symbols.
+func openPlugin(file string) (lookup func(string) (interface{}, error), err error) {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) (interface{}, error) {
+		sym, err := p.Lookup(name)
+		return sym, err
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !giplugin
+// +build !giplugin
+
+package compiler
+
+import "fmt"
+
+// Without -tags giplugin, gi stays statically linked, and
+// cannot load Go plugins; see plugin.go.
+
+func openPlugin(file string) (lookup func(string) (interface{}, error), err error) {
+	return nil, fmt.Errorf("gijit was built without plugin support; rebuild it with -tags giplugin, against a LuaJIT built with -fPIC, to load %s", file)
+}
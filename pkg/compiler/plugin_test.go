@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1394PackagesFromGoPlugins(t *testing.T) {
+
+	cv.Convey("-plugins maps import paths to the Go plugins their packages load from, and imports them from there, in place of gi's own", t, func() {
+		cfg := NewGIConfig()
+		cfg.PluginSpec = "unicode/utf8=/no/such/utf8.so, github.com/foo/fast=fast.so"
+		panicOn(cfg.ValidateConfig())
+		cv.So(cfg.Plugins, cv.ShouldResemble, map[string]string{
+			"unicode/utf8":        "/no/such/utf8.so",
+			"github.com/foo/fast": "fast.so",
+		})
+
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "unicode/utf8"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "import of 'unicode/utf8' from plugin: ")
+		cv.So(it.Packages(), cv.ShouldBeEmpty)
+
+		for _, bad := range []string{"fast.so", "=fast.so", "github.com/foo/fast="} {
+			cfg := NewGIConfig()
+			cfg.PluginSpec = bad
+			cv.So(cfg.ValidateConfig(), cv.ShouldNotBeNil)
+		}
+	})
+
+	cv.Convey("a sandbox policy without the ffi capability refuses plugins, which are native code, before opening them", t, func() {
+		cfg := NewGIConfig()
+		cfg.SandboxAllow = "fs,env"
+		cfg.PluginSpec = "unicode/utf8=utf8.so"
+		err := cfg.ValidateConfig()
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "ffi")
+
+		cfg = NewGIConfig()
+		cfg.Policy = NewPolicy(CapAll &^ CapFFI)
+		cfg.Plugins = map[string]string{"unicode/utf8": "/no/such/utf8.so"}
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "unicode/utf8"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "denied by sandbox policy: needs capability 'ffi'")
+	})
+}
@@ -0,0 +1,293 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Capability is one class of access to the host
+// that Go code at the prompt can be given. Capabilities
+// are bits; combine them with |.
+type Capability uint
+
+const (
+	CapFilesystem Capability = 1 << iota // read and write files
+	CapNetwork                           // open sockets
+	CapExec                              // start, signal, or exit processes
+	CapEnv                               // read and change environment variables
+	CapFFI                               // native code: unsafe, cgo, raw Lua
+
+	CapNone Capability = 0
+	CapAll  Capability = CapFilesystem | CapNetwork | CapExec | CapEnv | CapFFI
+)
+
+var capNames = []struct {
+	c    Capability
+	name string
+}{
+	{CapFilesystem, "fs"},
+	{CapNetwork, "net"},
+	{CapExec, "exec"},
+	{CapEnv, "env"},
+	{CapFFI, "ffi"},
+}
+
+func (c Capability) String() string {
+	if c == CapNone {
+		return "none"
+	}
+	var s []string
+	for _, cn := range capNames {
+		if c&cn.c != 0 {
+			s = append(s, cn.name)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// ParseCapabilities parses a comma separated list
+// of capability names, e.g. "fs,env". The names
+// are fs, net, exec, env, ffi, all, and none.
+func ParseCapabilities(s string) (Capability, error) {
+	var c Capability
+	for _, w := range strings.Split(s, ",") {
+		w = strings.TrimSpace(strings.ToLower(w))
+		switch w {
+		case "", "none":
+			continue
+		case "all":
+			c |= CapAll
+			continue
+		}
+		found := false
+		for _, cn := range capNames {
+			if cn.name == w {
+				c |= cn.c
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown capability '%s'; use fs, net, exec, env, ffi, all, or none", w)
+		}
+	}
+	return c, nil
+}
+
+// packageCaps gives the capabilities needed to
+// import a package at all. Packages not listed
+// need none.
+var packageCaps = map[string]Capability{
+	"io/ioutil":     CapFilesystem,
+	"os/exec":       CapExec,
+	"os/signal":     CapExec,
+	"net":           CapNetwork,
+	"net/http":      CapNetwork,
+	"net/rpc":       CapNetwork,
+	"net/smtp":      CapNetwork,
+	"crypto/tls":    CapNetwork,
+	"database/sql":  CapFilesystem, // the bundled driver is SQLite's
+	"gi/grpc":       CapNetwork,
+	"plugin":        CapFFI,
+	"unsafe":        CapFFI,
+	"gi/ffi":        CapFFI,
+	"C":             CapFFI,
+	"syscall":       CapAll,
+	"runtime/debug": CapFilesystem, // WriteHeapDump
+}
+
+// memberCaps refines the check for packages that mix
+// harmless and dangerous members. The "" entry is the
+// default for members not listed.
+var memberCaps = map[string]map[string]Capability{
+	"os": {
+		"": CapFilesystem,
+
+		"Args": CapNone, "DevNull": CapNone,
+		"ErrClosed": CapNone, "ErrDeadlineExceeded": CapNone, "ErrExist": CapNone,
+		"ErrInvalid": CapNone, "ErrNoDeadline": CapNone, "ErrNotExist": CapNone,
+		"ErrPermission": CapNone, "ErrProcessDone": CapNone,
+		"Expand": CapNone, "Getpagesize": CapNone, "IsExist": CapNone,
+		"IsNotExist": CapNone, "IsPathSeparator": CapNone, "IsPermission": CapNone,
+		"IsTimeout": CapNone, "NewSyscallError": CapNone, "PathListSeparator": CapNone,
+		"PathSeparator": CapNone, "Stderr": CapNone, "Stdin": CapNone, "Stdout": CapNone,
+
+		"Clearenv": CapEnv, "Environ": CapEnv, "ExpandEnv": CapEnv, "Getenv": CapEnv,
+		"LookupEnv": CapEnv, "Setenv": CapEnv, "Unsetenv": CapEnv,
+		"UserCacheDir": CapEnv, "UserConfigDir": CapEnv, "UserHomeDir": CapEnv,
+
+		"Exit": CapExec, "FindProcess": CapExec, "Interrupt": CapExec,
+		"Kill": CapExec, "StartProcess": CapExec,
+	},
+	"path/filepath": {
+		"": CapNone,
+
+		"Abs": CapFilesystem, "EvalSymlinks": CapFilesystem,
+		"Glob": CapFilesystem, "Walk": CapFilesystem, "WalkDir": CapFilesystem,
+	},
+}
+
+// luaCaps lists the Lua standard library functions
+// that each capability guards. They are removed from
+// the Lua globals of an Interp whose Policy denies it.
+var luaCaps = []struct {
+	c     Capability
+	names []string
+}{
+	{CapFilesystem, []string{"io.open", "io.lines", "io.input", "io.output",
+		"os.remove", "os.rename", "os.tmpname", "dofile", "loadfile",
+		`__packages["database/sql"]`}},
+	{CapExec, []string{"os.execute", "os.exit", "io.popen"}},
+	{CapEnv, []string{"os.getenv"}},
+	// the loaders take bytecode, which is unchecked; debug
+	// reaches the upvalues and registry where the prelude
+	// keeps ffi; and require and package reach ffi itself.
+	{CapFFI, []string{"package.loadlib", "package", "require",
+		"load", "loadstring", "dofile", "loadfile", "debug",
+		"__ffi", "__gi_requireLua", `__packages["gi/ffi"]`}},
+}
+
+// Policy is a capability based sandbox policy for one
+// Interp. Every shadow package import is checked against
+// it, and members of a partially allowed package, like
+// os, that need a denied capability are left out.
+//
+// A nil *Policy allows everything. A Policy is
+// read-only once in use, so several Interps may share one.
+type Policy struct {
+	// Allow holds the granted capabilities.
+	Allow Capability
+
+	// DenyPackages refuses these import paths,
+	// whatever their capabilities.
+	DenyPackages []string
+
+	// AllowPackages admits these import paths in
+	// full, whatever their capabilities. Deny wins
+	// if a path is in both.
+	AllowPackages []string
+}
+
+// NewPolicy returns a Policy granting only allow.
+func NewPolicy(allow Capability) *Policy {
+	return &Policy{Allow: allow}
+}
+
+// Allows reports whether all of c is granted.
+func (p *Policy) Allows(c Capability) bool {
+	if p == nil {
+		return true
+	}
+	return p.Allow&c == c
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckImport returns an error if path may not be imported.
+func (p *Policy) CheckImport(path string) error {
+	if p == nil {
+		return nil
+	}
+	if containsString(p.DenyPackages, path) {
+		return fmt.Errorf("import of '%s' denied by sandbox policy", path)
+	}
+	if containsString(p.AllowPackages, path) {
+		return nil
+	}
+	need := packageCaps[path]
+	if isLuaModPath(path) {
+		need = CapFFI
+	}
+	if !p.Allows(need) {
+		return fmt.Errorf("import of '%s' denied by sandbox policy: needs capability '%v'",
+			path, need&^p.Allow)
+	}
+	return nil
+}
+
+// AllowsMember reports whether the package level
+// member name of package path is visible.
+func (p *Policy) AllowsMember(path, name string) bool {
+	if p == nil || containsString(p.AllowPackages, path) {
+		return true
+	}
+	mc, ok := memberCaps[path]
+	if !ok {
+		return true
+	}
+	need, ok := mc[name]
+	if !ok {
+		need = mc[""]
+	}
+	return p.Allows(need)
+}
+
+// deniedMembers returns, sorted, the names in pkgMap that
+// p hides from package path. pkgMap is a shadow Pkg map.
+func (p *Policy) deniedMembers(path string, pkgMap map[string]interface{}) (names []string) {
+	if p == nil {
+		return nil
+	}
+	for name := range pkgMap {
+		if !p.AllowsMember(path, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// filterPkgMap returns a copy of the shadow map m without
+// the names in drop. The shadow maps are shared by
+// every Interp, so they are never changed in place.
+func filterPkgMap(m map[string]interface{}, drop []string) map[string]interface{} {
+	if len(drop) == 0 {
+		return m
+	}
+	cp := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	for _, name := range drop {
+		delete(cp, name)
+	}
+	return cp
+}
+
+// luaLockdown returns Lua code removing the standard
+// library functions whose capabilities p denies, and the
+// prelude's own ways to them. The prelude files that need
+// one keep it in a local, where raw Lua cannot reach it.
+func (p *Policy) luaLockdown() string {
+	if p == nil {
+		return ""
+	}
+	var b strings.Builder
+	if !p.Allows(CapFFI) {
+		b.WriteString("package.cpath = \"\";\n")
+		b.WriteString("package.loaded.ffi = nil; package.preload.ffi = nil;\n")
+		b.WriteString("if __gi_denyBytecode then __gi_denyBytecode() end;\n")
+	}
+	for _, lc := range luaCaps {
+		if p.Allows(lc.c) {
+			continue
+		}
+		for _, name := range lc.names {
+			if strings.HasPrefix(name, "__packages[") {
+				// the prelude's, which -np leaves out.
+				fmt.Fprintf(&b, "if __packages then %s = nil end;\n", name)
+				continue
+			}
+			fmt.Fprintf(&b, "%s = nil;\n", name)
+		}
+	}
+	return b.String()
+}
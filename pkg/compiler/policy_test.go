@@ -0,0 +1,111 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1315SandboxPolicy(t *testing.T) {
+
+	cv.Convey(`capability lists parse and print`, t, func() {
+		c, err := ParseCapabilities("fs, env")
+		panicOn(err)
+		cv.So(c, cv.ShouldEqual, CapFilesystem|CapEnv)
+		cv.So(c.String(), cv.ShouldEqual, "fs,env")
+
+		c, err = ParseCapabilities("none")
+		panicOn(err)
+		cv.So(c, cv.ShouldEqual, CapNone)
+
+		_, err = ParseCapabilities("fs,rocket")
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey(`a Policy gates whole packages, and single members of mixed ones like os`, t, func() {
+		p := NewPolicy(CapNone)
+		cv.So(p.CheckImport("strings"), cv.ShouldBeNil)
+		cv.So(p.CheckImport("math"), cv.ShouldBeNil)
+		cv.So(p.CheckImport("os/exec"), cv.ShouldNotBeNil)
+		cv.So(p.CheckImport("net"), cv.ShouldNotBeNil)
+
+		cv.So(p.CheckImport("os"), cv.ShouldBeNil)
+		cv.So(p.AllowsMember("os", "Stdout"), cv.ShouldBeTrue)
+		cv.So(p.AllowsMember("os", "Open"), cv.ShouldBeFalse)
+		cv.So(p.AllowsMember("os", "Getenv"), cv.ShouldBeFalse)
+		cv.So(p.AllowsMember("os", "StartProcess"), cv.ShouldBeFalse)
+
+		env := NewPolicy(CapEnv)
+		cv.So(env.AllowsMember("os", "Getenv"), cv.ShouldBeTrue)
+		cv.So(env.AllowsMember("os", "Open"), cv.ShouldBeFalse)
+
+		p.AllowPackages = []string{"net"}
+		p.DenyPackages = []string{"math", "net"}
+		cv.So(p.CheckImport("math"), cv.ShouldNotBeNil)
+		cv.So(p.CheckImport("net"), cv.ShouldNotBeNil)
+
+		var none *Policy
+		cv.So(none.CheckImport("os/exec"), cv.ShouldBeNil)
+		cv.So(none.AllowsMember("os", "Exit"), cv.ShouldBeTrue)
+
+		drop := p.deniedMembers("os", map[string]interface{}{"Stdout": 1, "Exit": 2, "Open": 3})
+		cv.So(drop, cv.ShouldResemble, []string{"Exit", "Open"})
+	})
+
+	cv.Convey(`an Interp with a Policy refuses denied imports, and loses the matching Lua functions`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapEnv)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		err = it.Eval(`import "io/ioutil"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(strings.Contains(err.Error(), "sandbox policy"), cv.ShouldBeTrue)
+
+		panicOn(LuaRun(it.lvm, `hasExecute = (os.execute ~= nil); hasGetenv = (os.getenv ~= nil)`, false))
+		LuaMustBool(it.lvm, "hasExecute", false)
+		LuaMustBool(it.lvm, "hasGetenv", true)
+
+		// ordinary code is unaffected.
+		panicOn(it.Eval(`a := 6 * 7`))
+		LuaMustInt64(it.lvm, "a", 42)
+	})
+
+	cv.Convey(`without the ffi capability, raw Lua cannot reach ffi another way: not by require, package, the loaders, bytecode or debug`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapAll &^ CapFFI)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		for _, name := range []string{"require", "package", "load", "loadstring", "dofile", "loadfile", "debug", "__ffi", `__packages["gi/ffi"]`} {
+			panicOn(LuaRun(it.lvm, "gone = ("+name+" == nil)", false))
+			LuaMustBool(it.lvm, "gone", true)
+		}
+		err = it.Eval(`import "gi/ffi"`)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// bytecode handed to the eval entry point is refused, not run.
+		panicOn(LuaRun(it.lvm, `ran = false; __gijitMainEval(string.dump(function() ran = true end))`, false))
+		LuaMustBool(it.lvm, "ran", false)
+		err = it.lastEvalError()
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "bytecode is denied")
+		_, err = it.dumpBytecode("return 1", "x.go", false)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// the prelude keeps what it needs: goroutines, channels,
+		// defer and panics still work.
+		panicOn(it.Eval(`
+ch := make(chan int)
+go func() { ch <- 6 * 7 }()
+a := <-ch
+func f() (r int) { defer func() { r = recover().(int) }(); panic(5) }
+b := f()
+`))
+		LuaMustInt64(it.lvm, "a", 42)
+		LuaMustInt64(it.lvm, "b", 5)
+	})
+}
@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+const pooledSrc = `package a
+
+var m = map[string]int{"a": 1}
+var s = []int{1, 2}
+
+func two() (int, string) { return 1, "b" }
+
+func f() {
+	v, ok := m["a"]
+	n := len(s)
+	s = append(s, v, n)
+	i, str := two()
+	if ok && i > 0 {
+		_ = str
+	}
+}
+`
+
+// checkTypes checks src, returning the types recorded for the
+// expressions of src that print as one of exprs, and the error.
+func checkTypes(src string, exprs ...string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", src, 0)
+	panicOn(err)
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := &types.Config{}
+	_, _, err = conf.Check(nil, nil, "a", fset, []*ast.File{f}, info, nil)
+	want := make(map[string]bool)
+	for _, e := range exprs {
+		want[e] = true
+	}
+	got := make(map[string]string)
+	for e, tv := range info.Types {
+		if k := types.ExprString(e); want[k] && tv.Type != nil {
+			got[k] = tv.Type.String()
+		}
+	}
+	return got, err
+}
+
+func Test1410PooledOperandsLeaveTheRecordedTypesIntact(t *testing.T) {
+
+	cv.Convey("with the checker's operands pooled, comma-ok, builtin and multi-value types are recorded as before, from any goroutine", t, func() {
+		exprs := []string{`m["a"]`, "len", "append", "two()", "ok && i > 0"}
+		expect := map[string]string{
+			`m["a"]`:      "(int, bool)",
+			"len":         "func([]int) int",
+			"append":      "func([]int, ...int) []int",
+			"two()":       "(int, string)",
+			"ok && i > 0": "bool",
+		}
+		got, err := checkTypes(pooledSrc, exprs...)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(got, cv.ShouldResemble, expect)
+
+		// an error prints the operand, after it went back.
+		_, err = checkTypes("package a\nvar x int\nvar y = x + \"s\"\n")
+		cv.So(fmt.Sprint(err), cv.ShouldContainSubstring, `"s" (untyped string constant)`)
+
+		var wg sync.WaitGroup
+		res := make([]map[string]string, 4)
+		for g := range res {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				res[g], _ = checkTypes(pooledSrc, exprs...)
+			}(g)
+		}
+		wg.Wait()
+		for _, r := range res {
+			cv.So(r, cv.ShouldResemble, expect)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pragmas are comment lines of the form //gi:name at the top
+// of an input, before its first line of code, that change
+// how that one input is run:
+//
+//	//gi:nocache  run the input, but keep it out of the
+//	              history, as of a password.
+//	//gi:lua      run the input as raw Lua, as under :r; or,
+//	              if it starts with func, declare a Go func
+//	              with a Lua body, as luafunc.go describes.
+//	//gi:timeit   time the input over many runs, as :timeit
+//	              does, instead of running it once.
+//
+// Like //go: directives, a pragma has no space after the //.
+// A Lua input ends at a blank line, a Lua func at its closing
+// }, and either at ctrl-d.
+
+// pragmas are the pragmas of an input.
+type pragmas struct {
+	nocache bool
+	lua     bool
+	timeit  bool
+}
+
+func (p pragmas) any() bool {
+	return p.nocache || p.lua || p.timeit
+}
+
+const pragmaPrefix = "//gi:"
+
+// parsePragmas returns the pragmas at the top of src, and
+// src without their lines. It fails on a pragma it does not
+// know, or on //gi:lua with //gi:timeit.
+func parsePragmas(src string) (p pragmas, body string, err error) {
+	lines := strings.Split(src, "\n")
+	var kept []string
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		if !strings.HasPrefix(t, "//") && t != "" {
+			kept = append(kept, lines[i:]...)
+			break
+		}
+		if !strings.HasPrefix(t, pragmaPrefix) {
+			kept = append(kept, line)
+			continue
+		}
+		switch name := strings.TrimSpace(t[len(pragmaPrefix):]); name {
+		case "nocache":
+			p.nocache = true
+		case "lua":
+			p.lua = true
+		case "timeit":
+			p.timeit = true
+		default:
+			return p, src, fmt.Errorf("unknown pragma %s%s; the pragmas are //gi:nocache, //gi:lua and //gi:timeit", pragmaPrefix, name)
+		}
+	}
+	if p.lua && p.timeit {
+		return p, src, fmt.Errorf("//gi:timeit times Go; it cannot be used with //gi:lua")
+	}
+	return p, strings.Join(kept, "\n"), nil
+}
@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1389PragmasChangeHowAnInputRuns(t *testing.T) {
+
+	cv.Convey("//gi:nocache, //gi:lua and //gi:timeit at the top of an input keep it out of the history, run it as Lua, or time it", t, func() {
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal"}))
+		panicOn(cfg.ValidateConfig())
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		r.reader = bufio.NewReader(strings.NewReader(`//gi:nocache
+secret := "hunter2"
+//gi:lua
+local n = 1 + 2
+print("from lua " .. n)
+
+//gi:timeit
+for i := 0; i < 3; i++ {
+	_ = i * 2
+}
+//gi:bogus
+println(len(secret))
+`))
+		pr, pw, err := os.Pipe()
+		panicOn(err)
+		saved := os.Stdout
+		os.Stdout = pw
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(pr)
+			out <- string(b)
+		}()
+		r.Loop()
+		os.Stdout = saved
+		pw.Close()
+		got := <-out
+
+		cv.So(got, cv.ShouldContainSubstring, "from lua 3\n//gi:result ok\n")
+		cv.So(got, cv.ShouldContainSubstring, " loops, best of 5: ")
+		cv.So(got, cv.ShouldContainSubstring, "oops: unknown pragma //gi:bogus; the pragmas are //gi:nocache, //gi:lua and //gi:timeit\n//gi:result error\n")
+		cv.So(got, cv.ShouldContainSubstring, "7\n//gi:result ok\n")
+
+		hist := strings.Join(r.history, "\n")
+		cv.So(hist, cv.ShouldNotContainSubstring, "hunter2")
+		cv.So(hist, cv.ShouldContainSubstring, "//gi:lua\n")
+		cv.So(hist, cv.ShouldContainSubstring, "print(\"from lua \" .. n)")
+	})
+
+	cv.Convey("only the pragmas above the code count, and //gi:lua does not go with //gi:timeit", t, func() {
+		p, body, err := parsePragmas("// a comment\n//gi:lua\nprint(1)")
+		panicOn(err)
+		cv.So(p, cv.ShouldResemble, pragmas{lua: true})
+		cv.So(body, cv.ShouldEqual, "// a comment\nprint(1)")
+
+		_, _, err = parsePragmas("//gi:lua\n//gi:timeit\nprint(1)")
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// only those before the code count.
+		p, _, err = parsePragmas("x := 1\n//gi:nocache")
+		panicOn(err)
+		cv.So(p.any(), cv.ShouldBeFalse)
+	})
+}
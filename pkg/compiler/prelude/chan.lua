@@ -69,6 +69,8 @@
 ----------------------------------------------------------------------------
 
 
+local debug = debug -- luaLockdown may remove the global
+
 local __M = {}
 
 -- Constants
@@ -80,6 +82,7 @@ local TIMEOUT = {err = "TIMEOUT"}
 -- Global objects for scheduler
 local tasks_runnable = {}       -- list of coroutines ready to be resumed
 local tasks_to = {}             -- all the timeout tasks
+local tasks_yielded = setmetatable({}, {__mode = "k"}) -- runnable after a Gosched
 local altexec
 
 __all_coro = {} -- array
@@ -102,8 +105,15 @@ end
 
 -- value.__loc gives location in __all_coro array.
 -- value.__name readable name
+-- value.__goid the goroutine id, for :goroutines
+-- value.__wait what it is blocked on, and __since when
+--  it blocked: the alt_array of a select, or a string.
+-- value.__created where the go statement was.
 __coro2notes = {} 
 
+-- __gi_lastGoid counts the goroutines started.
+__gi_lastGoid = 0
+
 -- return coroutine status as a string
 __costring=function(co)
    local v=__coro2notes[co]
@@ -131,6 +141,24 @@ __coro2notes[main_coro]={__loc=#__all_coro, __name="main"}
 
 local scheduler_co
 local __resume_scheduler
+
+-- __gi_schedSlice, when set, is the most goroutines the
+-- scheduler resumes before it returns, so that background
+-- work runs in slices between keystrokes at the prompt.
+__gi_schedSlice = nil
+
+-- __gi_schedUntil, when set, is a coroutine the scheduler
+-- returns as soon as it is dead. The Repl sets it to the
+-- eval coroutine, so goroutines an input starts carry on in
+-- the background once the input itself is done.
+__gi_schedUntil = nil
+
+-- __gi_backgroundGoroutines turns the above on for evals.
+__gi_backgroundGoroutines = false
+
+-- __gi_schedErr is the error of a goroutine that
+-- failed, kept until the scheduler has returned.
+local __gi_schedErr
 local task_park
 ----------------------------------------------------------------------------
 --- Helpers
@@ -255,9 +283,28 @@ local function scheduler()
          --print("scheduler: no more runnable tasks")
          break
       end
+      if __gi_schedSlice and i >= __gi_schedSlice then
+         break
+      end
+      if __gi_schedUntil and coroutine.status(__gi_schedUntil) == "dead" then
+         break
+      end
       -- jea: pick one at random
       local k = __builtin_math.random(nr)
+      if next(tasks_yielded) ~= nil then
+         -- those that called Gosched go after the others.
+         local others = {}
+         for i, r in ipairs(tasks_runnable) do
+            if not tasks_yielded[r] then
+               others[#others+1] = i
+            end
+         end
+         if #others > 0 then
+            k = others[__builtin_math.random(#others)]
+         end
+      end
       local co = table.remove(tasks_runnable, k)
+      tasks_yielded[co] = nil
       tasks_to[co] = nil
 
       -- and resume co
@@ -268,7 +315,10 @@ local function scheduler()
       local okay, emsg = unpack(back)
       if not okay then
          print(debug.traceback(emsg))
-         error(emsg)
+         -- raised by __resume_scheduler, once this
+         -- coroutine is suspended, and so still usable.
+         __gi_schedErr = emsg
+         break
       end
       i = i + 1
       --print("scheduler: resume was okay, i is now = ", i)      
@@ -320,7 +370,8 @@ local function spawn(fun, args)
    local co = coroutine.create(f)
    table.insert(__all_coro, co)
    local n=#__all_coro
-   __coro2notes[co]={__loc=n, __name="spawn #"..tostring(n)}
+   __gi_lastGoid = __gi_lastGoid + 1
+   __coro2notes[co]={__loc=n, __name="spawn #"..tostring(n), __goid=__gi_lastGoid, __created=__gi_callerLoc(3)}
    
    __task_ready(co)
    
@@ -373,10 +424,6 @@ local function altcopy(a, b)
          r.alt_array.value = TIMEOUT
          r.alt_array.resolved = 1
          return true
-      elseif r.closed then
-         r.alt_array.value = nil
-         r.alt_array.resolved = 1
-         return true
       else
          r.alt_array.value = c._buf:pop()
       end
@@ -400,6 +447,11 @@ end
 -- Can this Alt be execed without blocking?
 local function altcanexec(a)
    local c, op = a.c, a.op
+   if c.closed and op ~= NOP then
+      -- a receive gets what is left, then the zero value;
+      -- a send panics.
+      return true
+   end
    if c._buf.size == 0 then
       if op ~= NOP then
          return c:_get_other_alts(op):len() > 0
@@ -416,6 +468,16 @@ end
 -- Alt can be execed so find a counterpart Alt and exec it!
 altexec = function (a)
    local c, op = a.c, a.op
+   if c.closed then
+      if op == SEND then
+         __throwRuntimeError("send on closed channel")
+      end
+      if c._buf:len() == 0 then
+         a.alt_array.value = c:_zero()
+         a.alt_array.closed = true
+         return
+      end
+   end
 
    --print("top of altexec, a=")
    --__st(a,"a")
@@ -500,6 +562,7 @@ select_inner = function(alt_array)
 
       local thisCo = coroutine.running()
       task_park(thisCo)
+      __gi_setWait(thisCo, "select (no cases)")
       coroutine.yield() -- go back to scheduler
    end
 
@@ -590,10 +653,15 @@ select_inner = function(alt_array)
    local current_co, is_main = coroutine.running()  
    --print("about to yield from (is_main? ",is_main," co=", current_co, " / ", __costring(current_co))
    
+   __gi_setWait(current_co, alt_array)
    local who = coroutine.yield()
+   __gi_setWait(current_co, nil)
    --print("select: resumed by who='"..who.."'")
    
    assert(alt_array.resolved > 0)
+   if alt_array.sendClosed then
+      __throwRuntimeError("send on closed channel")
+   end
 
    local r = alt_array.resolved
    local res = {int(r-1), {alt_array.value, alt_array.closed == nil}}
@@ -639,14 +707,42 @@ local Channel = {
       return select({{c = self, op = RECV}}, false)
    end,
 
+   -- close wakes those waiting on the channel: its
+   -- receivers with the zero value, and its senders to
+   -- panic.
    close = function(self)
-      local alts = self:_get_alts(RECV)
-      for _, v in ipairs(alts.l) do
-         v.closed = true
-         altexec(v)
+      if self.closed then
+         __throwRuntimeError("close of closed channel")
+      end
+      self.closed = true
+      local waiting = {}
+      for _, v in ipairs(self._recv_alts.l) do
+         table.insert(waiting, v)
+      end
+      for _, v in ipairs(self._send_alts.l) do
+         table.insert(waiting, v)
+      end
+      for _, v in ipairs(waiting) do
+         local alt_array = v.alt_array
+         altalldequeue(alt_array)
+         if v.op == RECV then
+            alt_array.value = self:_zero()
+            alt_array.closed = true
+         else
+            alt_array.sendClosed = true
+         end
+         alt_array.resolved = v.alt_index
+         __task_ready(alt_array.task)
       end
    end,
 
+   _zero = function(self)
+      if self.__elemTyp == nil then
+         return nil
+      end
+      return self.__elemTyp.zero()
+   end,
+
    _get_alts = function(self, op)
       if op == RECV then
          return self._recv_alts
@@ -717,10 +813,266 @@ __resume_scheduler = function()
       __stacks()
       error(err)
    end
+   if __gi_schedErr ~= nil then
+      local gerr = __gi_schedErr
+      __gi_schedErr = nil
+      error(gerr, 0)
+   end
    return ok, err
 end
 
 
+----------------------------------------------------------------------------
+-- Goroutine inspection, for :goroutines; see goroutines.go.
+
+__gi_setWait = function(co, what)
+   local notes = __coro2notes[co]
+   if notes then
+      notes.__wait = what
+      notes.__since = what and __abs_now() or nil
+   end
+end
+
+-- frameLoc formats a debug.getinfo "Sl" result as
+-- "chunk:line", the way jit.profile's dumpstack does.
+local function frameLoc(info)
+   local src = info.source
+   if info.what == "C" then
+      return "[C]"
+   elseif src:sub(1,1) == "=" or src:sub(1,1) == "@" then
+      src = src:sub(2):match("[^/\\]*$")
+   else
+      src = "[string]"
+   end
+   return src..":"..tostring(info.currentline)
+end
+
+-- __gi_callerLoc gives "chunk:line" for the function
+-- at level of the calling stack.
+__gi_callerLoc = function(level)
+   local info = debug.getinfo(level, "Sl")
+   if info == nil then
+      return ""
+   end
+   return frameLoc(info)
+end
+
+-- coroStack gives the stack of co, leaf first, as
+-- "chunk:line" frames separated by ';'. dumpstack is not
+-- used: its buffer is shared by every lua_State in the
+-- process, and is only safe inside the profiler.
+local function coroStack(co, depth)
+   local frames = {}
+   for level = 0, depth-1 do
+      local info = debug.getinfo(co, level, "Sl")
+      if info == nil then
+         break
+      end
+      frames[#frames+1] = frameLoc(info)
+   end
+   return table.concat(frames, ";")
+end
+
+-- __gi_stack gives the stack of the running coroutine
+-- from level on, the way coroStack does.
+__gi_stack = function(level, depth)
+   local frames = {}
+   for lv = level, level+depth-1 do
+      local info = debug.getinfo(lv, "Sl")
+      if info == nil then
+         break
+      end
+      frames[#frames+1] = frameLoc(info)
+   end
+   return table.concat(frames, ";")
+end
+
+local function goroutineState(co, notes)
+   local st = coroutine.status(co)
+   if st == "running" or st == "normal" then
+      return "running"
+   end
+   local w = notes.__wait
+   if type(w) == "string" then
+      return w
+   elseif type(w) == "table" then
+      if #w == 1 then
+         local a = w[1]
+         if a.to then
+            return "sleep"
+         elseif a.op == RECV then
+            return "chan receive"
+         elseif a.op == SEND then
+            return "chan send"
+         end
+      end
+      return "select"
+   end
+   for _, r in ipairs(tasks_runnable) do
+      if r == co then
+         return "runnable"
+      end
+   end
+   return "waiting"
+end
+
+-- __gi_goroutines describes the live goroutines, a line
+-- each, with tab separated fields: id, state, the ns it
+-- has been blocked, where it was created, and its stack
+-- as "chunk:line" frames, leaf first, separated by ';'.
+function __gi_goroutines(depth)
+   local now = __abs_now()
+   local lines = {}
+   for _, co in ipairs(__all_coro) do
+      local notes = __coro2notes[co]
+      if notes and notes.__goid and coroutine.status(co) ~= "dead" then
+         local since = 0
+         if notes.__since then
+            since = tonumber(now - notes.__since)
+         end
+         lines[#lines+1] = table.concat({
+               tostring(notes.__goid),
+               goroutineState(co, notes),
+               string.format("%.0f", since),
+               notes.__created or "",
+               coroStack(co, depth)}, "\t")
+      end
+   end
+   return table.concat(lines, "\n")
+end
+
+-- __gi_asleep reports whether co, and so every goroutine,
+-- is asleep for good: co is blocked, nothing can run, and
+-- no timeout is pending that could wake anything up.
+function __gi_asleep(co)
+   return coroutine.status(co) == "suspended" and #tasks_runnable == 0 and next(tasks_to) == nil
+end
+
+-- __gi_numGoroutine counts the goroutines that have not
+-- finished, the running input's included.
+function __gi_numGoroutine()
+   local n = 0
+   for _, co in ipairs(__all_coro) do
+      local notes = __coro2notes[co]
+      if notes and notes.__goid and coroutine.status(co) ~= "dead" then
+         n = n + 1
+      end
+   end
+   return n
+end
+
+-- __gi_goroutineNotes gives the notes of the running
+-- goroutine, or nil outside of one.
+function __gi_goroutineNotes()
+   local co = coroutine.running()
+   local notes = co and __coro2notes[co]
+   if notes and notes.__goid then
+      return notes
+   end
+   return nil
+end
+
+-- __gi_gosched lets the other goroutines run before the
+-- running one carries on. Outside of a goroutine, it runs
+-- the scheduler once.
+function __gi_gosched()
+   local co, is_main = coroutine.running()
+   if is_main or co == nil or __gi_goroutineNotes() == nil then
+      __resume_scheduler()
+      return
+   end
+   tasks_yielded[co] = true
+   __task_ready(co)
+   coroutine.yield()
+end
+
+-- __gi_backgroundDue reports whether a goroutine can run
+-- now, or has a timeout that has come due.
+function __gi_backgroundDue()
+   if #tasks_runnable > 0 then
+      return true
+   end
+   local now = __abs_now()
+   for _, alt in pairs(tasks_to) do
+      if alt and now >= alt.to then
+         return true
+      end
+   end
+   return false
+end
+
+-- __gi_runBackground runs at most n goroutines,
+-- then reports whether there is more to run.
+function __gi_runBackground(n)
+   __gi_schedSlice = n
+   local ok, err = pcall(__resume_scheduler)
+   __gi_schedSlice = nil
+   if not ok then
+      error(err, 0)
+   end
+   return #tasks_runnable > 0
+end
+
+-- __gi_foreground runs goroutines until goroutine id
+-- finishes or blocks; with id 0, until none can run.
+function __gi_foreground(id)
+   local target
+   if id ~= 0 then
+      for _, co in ipairs(__all_coro) do
+         local notes = __coro2notes[co]
+         if notes and notes.__goid == id then
+            target = co
+         end
+      end
+      if target == nil or coroutine.status(target) == "dead" then
+         error("no goroutine "..tostring(id), 0)
+      end
+   end
+   while __gi_runBackground(100) do
+      if target then
+         if coroutine.status(target) == "dead" then
+            break
+         end
+         local runnable = false
+         for _, r in ipairs(tasks_runnable) do
+            if r == target then
+               runnable = true
+            end
+         end
+         if not runnable then
+            break
+         end
+      end
+   end
+end
+
+-- __gi_killGoroutine drops the goroutine id: it is
+-- taken off the run queue and the channels it waits on,
+-- and never resumed. Its deferred calls do not run.
+function __gi_killGoroutine(id)
+   for i, co in ipairs(__all_coro) do
+      local notes = __coro2notes[co]
+      if notes and notes.__goid == id and coroutine.status(co) ~= "dead" then
+         local st = coroutine.status(co)
+         if st == "running" or st == "normal" then
+            return "goroutine "..tostring(id).." is running"
+         end
+         task_park(co)
+         tasks_to[co] = nil
+         if type(notes.__wait) == "table" then
+            altalldequeue(notes.__wait)
+         end
+         table.remove(__all_coro, i)
+         __coro2notes[co] = nil
+         for j = i, #__all_coro do
+            __coro2notes[__all_coro[j]].__loc = j
+         end
+         return ""
+      end
+   end
+   return "no goroutine "..tostring(id)
+end
+
 ----------------------------------------------------------------------------
 -- Public interface
 
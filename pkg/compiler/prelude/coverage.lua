@@ -0,0 +1,15 @@
+-- coverage.lua: statement coverage; see pkg/compiler/coverage.go.
+--
+-- Instrumented code sets __gi_cov[id] = true
+-- before running the statement with block id.
+
+__gi_cov = {}
+
+-- __gi_coverHits returns the ids of the statements run, comma separated.
+function __gi_coverHits()
+   local ids = {}
+   for id in pairs(__gi_cov) do
+      ids[#ids+1] = tostring(id)
+   end
+   return table.concat(ids, ",")
+end
@@ -1,5 +1,7 @@
 -- deferinit.lua : global setup for defer handling
 
+local debug = debug -- luaLockdown may remove the global
+
 -- utility: table show
 function __ts(t)
    if t == nil then
@@ -20,8 +22,56 @@ end
 -- can we have one global definition of panic and recover?
 -- This would be preferred to repeating them in every function.
 
+-- goFloat formats a float64 as the runtime's print
+-- does: +1.500000e+000.
+local function goFloat(v)
+   if v ~= v then
+      return "NaN"
+   elseif v == 1/0 then
+      return "+Inf"
+   elseif v == -1/0 then
+      return "-Inf"
+   end
+   local m, e = string.match(string.format("%+.6e", v), "^(.-)e([-+]%d+)$")
+   local ex = tonumber(e)
+   return string.format("%se%s%03d", m, ex < 0 and "-" or "+", ex < 0 and -ex or ex)
+end
+
+-- __gi_panicString formats a panic value as gc's runtime
+-- prints it after "panic: ": an error by its Error method,
+-- a Stringer by its String, a basic value as print would,
+-- and anything else by its type and address.
+function __gi_panicString(v)
+   local t = type(v)
+   if v == nil then
+      return "panic called with nil argument"
+   elseif t == "string" then
+      -- Go's errors reach Lua as their messages, too.
+      return v
+   elseif t == "number" then
+      return goFloat(v)
+   elseif t == "cdata" then
+      return (string.gsub(tostring(v), "U?LL$", ""))
+   elseif t == "table" then
+      if type(v.Error) == "function" then
+         return v:Error()
+      elseif type(v.String) == "function" then
+         return v:String()
+      end
+      local typ = rawget(v, "__typ") or v.__typ
+      local name = type(typ) == "table" and typ.__str or "?"
+      -- the address, from under any __tostring.
+      local mt = getmetatable(v)
+      setmetatable(v, nil)
+      local addr = string.match(tostring(v), "0x%x+") or "0x0"
+      setmetatable(v, mt)
+      return "(" .. tostring(name) .. ") " .. addr
+   end
+   return tostring(v)
+end
+
 -- string viewing of panic value
-__recovMT = {__tostring = function(v) return 'a-panic-value:' .. tostring(v[1]) end}
+__recovMT = {__tostring = function(v) return 'panic: ' .. __gi_panicString(v[1]) end}
 
 -- __recoverVal will be nill if no panic,
 --              or if panic happened and
@@ -79,12 +129,21 @@ __panicHandler = function(err, defers)
    --print("__panicHandler running with defers:", tostring(defers))
    
    __recoverVal = err
+   if __gi_isInterrupt(err) then
+      -- the eval is being stopped: the defers do not
+      -- run, and __processDefers raises err again.
+      return err
+   end
    if defers ~= nil then
       
       --print(debug.traceback(), " __panicHandler running with err =", err, " and #defer = ", #defers)      
       --print(" __panicHandler running with err =", err, " and #defer = ", #defers)  
       for __i = #defers, 1, -1 do
          local dcall = {xpcall(defers[__i], __handler2)}
+         if __gi_isInterrupt(dcall[2]) then
+            __recoverVal = dcall[2]
+            return dcall[2]
+         end
          for i,v in pairs(dcall) do print("__panicHandler: panic path defer call result: i=",i, "  v=",v) end
       end
    else
@@ -116,14 +175,13 @@ __processDefers = function(who, defers, __res, __namedNames, actEnv)
       --print(who,": __processDefers: call had no panic")
       -- call had no panic. run defers with the nil recover
 
-      if #__res > 1 then
+      -- count, rather than #, as a result may be nil.
+      if __res.n > 1 then
          --for k,v in pairs(__res) do print(who, " __processDefers: __res k=", k, " val=", v) end
 
          -- explicit return, so fill the named vals before defers see them.
-         local unp = {table.unpack(__res, 2)}
-         --print("unp is: ", tostring(unp))
-         for i, k in pairs(__namedNames) do
-             actEnv[k] = unp[i]
+         for i, k in ipairs(__namedNames) do
+             rawset(actEnv, k, __res[i+1])
          end
 
          --print(who, " __processDefers: post fill: ret0 = ", ret0, " and ret1=", ret1)
@@ -132,6 +190,9 @@ __processDefers = function(who, defers, __res, __namedNames, actEnv)
       assert(recoverVal == nil)
       for __i = #defers, 1, -1 do
         local dcall = {xpcall(defers[__i], __handler2)}
+        if __gi_isInterrupt(dcall[2]) then
+           error(dcall[2])
+        end
         for i,v in pairs(dcall) do
             --print(who," __processDefers: normal path defer call result: i=",i, "  v=",v)
         end
@@ -151,18 +212,17 @@ __processDefers = function(who, defers, __res, __namedNames, actEnv)
   end
   -- put the named return values in order
   local orderedReturns={}
-  for i, k in pairs(__namedNames) do
+  for i, k in ipairs(__namedNames) do
      --print("debug: fetching from function env k=",k," which we see has value ", actEnv[k], "in actEnv", tostring(actEnv))
-     orderedReturns[i] = actEnv[k]
+     orderedReturns[i] = rawget(actEnv, k)
   end
-  local debug= true
-  if debug then
-     print("orderedReturns is len ", #orderedReturns)
-     for i,v in pairs(orderedReturns) do
-        print(who," __processDefers: orderedReturns: i=",i, "  v=",v)
-     end
-  end
-  return unpack(orderedReturns)
+  return unpack(orderedReturns, 1, #__namedNames)
+end
+
+-- __pack is table.pack: the values, with their count
+-- in n, trailing nils included.
+__pack = function(...)
+   return {n = select("#", ...), ...}
 end
 
 
@@ -177,20 +237,46 @@ __actuallyCall = function(who, __actual, __namedNames, __zeroret, __defers, __or
    -- so that named return variables can
    -- be written/read from this env.
    
+   -- __actual was made in the env of its caller: _G,
+   -- or, for a func literal, the env of the function
+   -- around it, whose named results it may use.
+   local parent = getfenv(__actual)
    local actEnv = {}
    local mt = {
-      __index = _G, -- read through to globals.
-      __newindex = _G, -- write to closure-capture globals too.
+      __index = parent, -- read through to globals.
+      __newindex = parent, -- write to closure-capture globals too.
    }
+   if #__namedNames > 0 then
+      -- the named results live in actEnv, even while
+      -- they hold nil, so that neither recursion nor
+      -- another goroutine sees them in _G.
+      local named = {}
+      for _, k in ipairs(__namedNames) do
+         named[k] = true
+      end
+      mt.__index = function(t, k)
+         if named[k] then
+            return nil
+         end
+         return parent[k]
+      end
+      mt.__newindex = function(t, k, v)
+         if named[k] then
+            rawset(t, k, v)
+            return
+         end
+         parent[k] = v
+      end
+   end
    setmetatable(actEnv,mt)
    setfenv(__actual, actEnv)
 
-  for i,k in pairs(__namedNames) do
+  for i,k in ipairs(__namedNames) do
      --print("filling actEnv[k='"..tostring(k).."'] = '"..tostring(actEnv[k]).."' with __zeroret[i='"..tostring(i).."']='",tostring(__zeroret[i]),"'")
-     actEnv[k] = __zeroret[i]
+     rawset(actEnv, k, __zeroret[i])
   end  
   local myPanic = function(err) __panicHandler(err, __defers) end
-  local __res = {xpcall(__actual, myPanic, unpack(__orig))}
+  local __res = __pack(xpcall(__actual, myPanic, unpack(__orig)))
 
   --print("debug: back from xpcall in __actuallyCall. __res = ")
   --__st(__res)
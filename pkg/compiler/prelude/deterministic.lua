@@ -0,0 +1,64 @@
+-- deterministic.lua: support for `gi -deterministic`.
+--
+-- When __gi_deterministic is true, ranging over a
+-- map visits its keys in sorted order, so that
+-- output does not depend on the hash layout.
+
+__gi_deterministic = false
+
+-- maps store integer keys as strings like "12LL",
+-- (see keyFor in tsys.lua), so __detNumKey recovers
+-- the number, or returns nil for other strings.
+local function __detNumKey(s)
+   local digits = s:match("^(-?%d+)U?LL$") or s:match("^(-?%d+)$")
+   if digits == nil then
+      return nil
+   end
+   return tonumber(digits)
+end
+
+-- __detKeyLess orders map keys of mixed types:
+-- first by Lua type name, then by value. Integer
+-- keys compare as numbers.
+function __detKeyLess(a, b)
+   local ta, tb = type(a), type(b)
+   if ta ~= tb then
+      return ta < tb
+   end
+   if ta == "number" then
+      return a < b
+   end
+   if ta == "string" then
+      local na, nb = __detNumKey(a), __detNumKey(b)
+      if na ~= nil and nb ~= nil and na ~= nb then
+         return na < nb
+      end
+      return a < b
+   end
+   if ta == "cdata" then
+      local ok, lt = pcall(function() return a < b end)
+      if ok then
+         return lt
+      end
+   end
+   return tostring(a) < tostring(b)
+end
+
+-- __sortedPairs iterates over the raw table tbl
+-- in key order, like pairs() does in hash order.
+function __sortedPairs(tbl)
+   local keys = {}
+   for k in pairs(tbl) do
+      keys[#keys+1] = k
+   end
+   table.sort(keys, __detKeyLess)
+   local i = 0
+   return function()
+      i = i + 1
+      local k = keys[i]
+      if k == nil then
+         return nil
+      end
+      return k, tbl[k]
+   end
+end
@@ -7,6 +7,8 @@
 --  leaf types before the compound
 --  types that need them defined.
 
+local debug = debug -- luaLockdown may remove the global
+
 local __dfsTestMode = false
 
 
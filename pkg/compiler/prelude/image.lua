@@ -0,0 +1,165 @@
+-- image.lua: encoding of live Go values for
+-- session images; see pkg/compiler/image.go.
+--
+-- __gi_imageEncode turns a value into JSON that
+-- keeps just enough layout for the Go side, which
+-- knows the static types, to write the value back
+-- out as a Go composite literal:
+--
+--   nil, true, false, numbers: as JSON
+--   strings:        "abc", or {"s":"<hex>"} if not plain ASCII
+--   int64/uint64:   {"i":"12"} / {"u":"12"}
+--   complex:        {"c":[re,im]}
+--   inf and nan:    {"f":"+Inf"}, {"f":"-Inf"}, {"f":"NaN"}
+--   slice, array:   {"t":"[]int","slice":[...]}, {"t":..,"array":[...]}
+--   map:            {"t":..,"map":[[k,v],...]}, with the stored keys
+--   pointer:        {"t":..,"p":<target>}
+--   struct:         {"t":..,"struct":[<fields in order>]}
+--   anything else:  {"x":"<why>"}
+--
+-- Shared and cyclic references are not preserved.
+
+local __imgHex = function(s)
+   return (s:gsub(".", function(c) return string.format("%02x", string.byte(c)) end))
+end
+
+local __imgEnc
+
+local __imgString = function(s, out)
+   if s:find('[^ -~]') or s:find('["\\]') then
+      out[#out+1] = '{"s":"'..__imgHex(s)..'"}'
+   else
+      out[#out+1] = '"'..s..'"'
+   end
+end
+
+local __imgNumber = function(n, out)
+   if n ~= n then
+      out[#out+1] = '{"f":"NaN"}'
+   elseif n == math.huge then
+      out[#out+1] = '{"f":"+Inf"}'
+   elseif n == -math.huge then
+      out[#out+1] = '{"f":"-Inf"}'
+   elseif n == math.floor(n) and math.abs(n) < 1e15 then
+      out[#out+1] = string.format("%d", n)
+   else
+      out[#out+1] = string.format("%.17g", n)
+   end
+end
+
+local __imgList = function(tag, typ, n, get, out, seen)
+   out[#out+1] = '{"t":'
+   __imgString(typ.__str, out)
+   out[#out+1] = ',"'..tag..'":['
+   for i = 0, n-1 do
+      if i > 0 then
+         out[#out+1] = ","
+      end
+      __imgEnc(get(i), out, seen)
+   end
+   out[#out+1] = "]}"
+end
+
+__imgEnc = function(v, out, seen)
+   local ty = type(v)
+   if v == nil then
+      out[#out+1] = "null"
+   elseif ty == "boolean" then
+      out[#out+1] = tostring(v)
+   elseif ty == "number" then
+      __imgNumber(v, out)
+   elseif ty == "string" then
+      __imgString(v, out)
+   elseif ty == "cdata" then
+      local s = tostring(v)
+      local i = s:match("^(-?%d+)LL$")
+      local u = s:match("^(%d+)ULL$")
+      if i then
+         out[#out+1] = '{"i":"'..i..'"}'
+      elseif u then
+         out[#out+1] = '{"u":"'..u..'"}'
+      else
+         local ok, re, im = pcall(function() return v.re, v.im end)
+         if ok and type(re) == "number" then
+            out[#out+1] = '{"c":['
+            __imgNumber(re, out)
+            out[#out+1] = ","
+            __imgNumber(im, out)
+            out[#out+1] = "]}"
+         else
+            out[#out+1] = '{"x":"cdata"}'
+         end
+      end
+   elseif ty == "table" then
+      local typ = rawget(v, "__typ") or (getmetatable(v) ~= nil and v.__typ)
+      if type(typ) ~= "table" or typ.kind == nil then
+         out[#out+1] = '{"x":"table"}'
+         return
+      end
+      if seen[v] then
+         out[#out+1] = '{"x":"cycle"}'
+         return
+      end
+      seen[v] = true
+      local kind = typ.kind
+      if kind == __kindSlice then
+         __imgList("slice", typ, __lenz(v), function(i) return v[i] end, out, seen)
+      elseif kind == __kindArray then
+         __imgList("array", typ, typ.len, function(i) return v[i] end, out, seen)
+      elseif kind == __kindMap then
+         out[#out+1] = '{"t":'
+         __imgString(typ.__str, out)
+         out[#out+1] = ',"map":['
+         local first = true
+         for k, e in pairs(v.__val) do
+            if not first then
+               out[#out+1] = ","
+            end
+            first = false
+            if e == __intentionalNilValue then
+               e = nil
+            end
+            out[#out+1] = "["
+            __imgEnc(k, out, seen)
+            out[#out+1] = ","
+            __imgEnc(e, out, seen)
+            out[#out+1] = "]"
+         end
+         out[#out+1] = "]}"
+      elseif kind == __kindPtr then
+         local target
+         if rawget(v, "__val") ~= nil then
+            target = rawget(v, "__val")
+         elseif rawget(v, "__get") ~= nil then
+            target = v.__get()
+         end
+         out[#out+1] = '{"t":'
+         __imgString(typ.__str, out)
+         out[#out+1] = ',"p":'
+         __imgEnc(target, out, seen)
+         out[#out+1] = "}"
+      elseif kind == __kindStruct then
+         out[#out+1] = '{"t":'
+         __imgString(typ.__str, out)
+         out[#out+1] = ',"struct":['
+         for i, f in ipairs(typ.fields) do
+            if i > 1 then
+               out[#out+1] = ","
+            end
+            __imgEnc(v[f.__prop], out, seen)
+         end
+         out[#out+1] = "]}"
+      else
+         out[#out+1] = '{"x":"kind '..tostring(kind)..'"}'
+      end
+      seen[v] = nil
+   else
+      out[#out+1] = '{"x":"'..ty..'"}'
+   end
+end
+
+function __gi_imageEncode(v)
+   local out = {}
+   __imgEnc(v, out, {})
+   return table.concat(out)
+end
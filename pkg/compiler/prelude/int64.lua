@@ -51,38 +51,54 @@ float32 = ffi.typeof("float")
 ----------------------------------
 ----------------------------------
 
--- __newByteArray is created from vals, which
--- can be a table, an empty table, or
--- a string. If it is a string, then to
--- quote from the ffi docs at http://luajit.org/ext_ffi_semantics.html
---
--- "Byte arrays may also be initialized with
---  a Lua string. This copies the whole string
---  plus a terminating zero-byte. The copy stops
---  early only if the array has a known, fixed size."
+-- __newByteArray makes the backing array of a []byte: a
+-- buffer of bytes that Go allocated and lends to Lua, so
+-- that passing the []byte to Go shares it rather than
+-- copying it; see luar/sharedbytes.go. vals is the length
+-- of a zeroed buffer, or the string or table of bytes to
+-- fill it with.
 --
+-- Like a table backed array, it is indexed from 0, and an
+-- element reads as a uint8.
+local byteArrayMT = {
+   __index = function(me, i)
+      return uint8(me.__bytes[i])
+   end,
+   __newindex = function(me, i, v)
+      me.__bytes[i] = v
+   end,
+   __len = function(me)
+      return me.__sz
+   end,
+   __tostring = function(me)
+      return ffi.string(me.__bytes, me.__sz)
+   end,
+}
+
 function __newByteArray(vals)
-   vals = vals or {}
-   local sz = #vals
-   local res= {
-      __bytes = ffi.new("char["..sz.."]", vals),
-      __sz=sz,
-      __name="__valueByteArray",
+   vals = vals or 0
+   local sz = vals
+   if type(vals) ~= "number" then
+      sz = #vals
+   end
+   local addr = __gi_newSharedBytes(sz)
+   local bytes = ffi.gc(ffi.cast("uint8_t*", addr), function()
+      __gi_freeSharedBytes(addr)
+   end)
+   if type(vals) == "string" then
+      ffi.copy(bytes, vals, sz)
+   elseif type(vals) == "table" then
+      for i = 1, sz do
+         bytes[i-1] = vals[i]
+      end
+   end
+   local res = {
+      __bytes = bytes,
+      __sz = sz,
+      __addr = addr,
+      __name = "__valueByteArray",
    }
-   setmetatable(res, {
-                   __index = function(me, i)
-                      return me.__bytes[i]
-                   end,
-                   __len=function(me)
-                      --print("__length on byteArray called")
-                      return me.__sz
-                   end,
-                   __tostring=function(me)
-                      --print("__tostring on byteArray called")
-                      return string.sub(ffi.string(me.__bytes), 1, me.__sz)
-                   end,
-   })
-   return res
+   return setmetatable(res, byteArrayMT)
 end
 
 __stringToBytes = function(str)
@@ -90,12 +106,21 @@ __stringToBytes = function(str)
 end;
 
 __bytesToString = function(ba)
-   if ba.__array ~= nil then
-      return string.sub(ffi.string(ba.__array.__bytes), 1, ba.__array.__sz)
-   end
    if type(ba) == "userdata" then
       -- most likely a proxy
       return getmetatable(ba).__proxy_byteslice_tostring(ba)
    end
-   error("__bytesToString error: TODO/unknown how to get string out of "..type(ba))
+   local a = ba.__array
+   if a == nil then
+      error("__bytesToString error: TODO/unknown how to get string out of "..type(ba))
+   end
+   if a.__bytes ~= nil then
+      return ffi.string(a.__bytes + ba.__offset, ba.__length)
+   end
+   -- a table backed []byte, such as a literal makes.
+   local chars = {}
+   for i = 0, ba.__length - 1 do
+      chars[i+1] = string.char(tonumber(a[ba.__offset + i]))
+   end
+   return table.concat(chars)
 end;
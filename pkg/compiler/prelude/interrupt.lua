@@ -0,0 +1,50 @@
+-- interrupt.lua: support for cancelling an eval
+-- that is already running, e.g. an infinite loop,
+-- and for per-eval instruction and heap budgets.
+--
+-- While a guarded eval runs, the host installs
+-- __gi_evalHook as a count hook. __gi_interruptCheck
+-- is registered from Go; it returns the empty string
+-- until the eval should stop, and then the reason, at
+-- every hook until the eval is over.
+--
+-- The reason is raised as an __gi_interruptMT table,
+-- which is not a panic: no deferred call runs for it,
+-- and recover does not see it; see __panicHandler.
+--
+-- LuaJIT does not call hooks from inside compiled
+-- traces, and will happily compile a hot infinite
+-- loop, so the JIT is off while the hook is set.
+-- Traces compiled earlier, e.g. for the prelude,
+-- keep running at full speed.
+
+local debug = debug -- luaLockdown may remove the global
+
+__gi_interruptMT = {__tostring = function(e) return e[1] end}
+
+function __gi_isInterrupt(err)
+   return type(err) == "table" and getmetatable(err) == __gi_interruptMT
+end
+
+__gi_evalHook = function()
+   local msg, wantGC = __gi_interruptCheck(collectgarbage("count"), false)
+   if wantGC then
+      -- only count live data against the heap budget.
+      collectgarbage("collect")
+      msg = __gi_interruptCheck(collectgarbage("count"), true)
+   end
+   if msg ~= "" then
+      error(setmetatable({msg}, __gi_interruptMT))
+   end
+end
+
+function __gi_setEvalHook(count)
+   __gi_interruptSetHeapBase(collectgarbage("count"))
+   jit.off()
+   debug.sethook(__gi_evalHook, "", count)
+end
+
+function __gi_clearEvalHook()
+   debug.sethook()
+   jit.on()
+end
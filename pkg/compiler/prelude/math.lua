@@ -26,7 +26,19 @@ __truncateToInt = function(x)
    return x + (-x % 1)
 end
 
+-- LuaJIT's 64-bit integers don't raise on division by
+-- zero, they give the most negative int64 (2^63 unsigned,
+-- which compares equal). Only division of that value by
+-- 1 or -1 gives it too.
+local __int64Min = -9223372036854775807LL - 1
+
 __integerByZeroCheck = function(x)
+   if type(x) == "cdata" then
+      if x == __int64Min then
+         error("integer divide by zero")
+      end
+      return x
+   end
    if not __builtin_math.finite(x) then
       error("integer divide by zero")
    end
@@ -50,3 +62,44 @@ function __min(a,b)
    end
    return b
 end
+
+-- __fmin and __fmax are the builtin min and max on
+-- floats, where, as in Go, a NaN wins over any number,
+-- and -0 is less than 0.
+function __fmin(a,b)
+   if a ~= a then
+      return a
+   end
+   if b ~= b then
+      return b
+   end
+   if a == 0 and b == 0 then
+      if 1/a < 0 then
+         return a
+      end
+      return b
+   end
+   if a < b then
+      return a
+   end
+   return b
+end
+
+function __fmax(a,b)
+   if a ~= a then
+      return a
+   end
+   if b ~= b then
+      return b
+   end
+   if a == 0 and b == 0 then
+      if 1/a > 0 then
+         return a
+      end
+      return b
+   end
+   if a > b then
+      return a
+   end
+   return b
+end
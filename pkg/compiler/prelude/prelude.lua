@@ -1,6 +1,49 @@
 -- prelude defines things that should
 -- be available before any user code is run.
 
+local debug = debug -- luaLockdown may remove the global
+
+-- __packages holds the table of each package imported, by
+-- its import path. Go code reaches a package only through
+-- it, never through a global named for the package, so
+-- that two packages of one name, or a name declared at the
+-- prompt, do not collide; and dropping a package is one
+-- entry. Their types are kept the same way, in
+-- __type__[path]. __gi_package returns the table of path, making it
+-- if need be, for the prelude files that implement gi's
+-- packages, which load in no particular order.
+__packages = __packages or {}
+
+function __gi_package(path)
+   local pkg = __packages[path]
+   if pkg == nil then
+      pkg = {}
+      __packages[path] = pkg
+   end
+   return pkg
+end
+
+-- __gi_typesOf returns what the Lua of a shadow package
+-- sees as __type__: that Lua names its own types
+-- __type__.name, and those go to __type__[path].
+function __gi_typesOf(path, name)
+   return setmetatable({}, {
+      __index = function(_, k)
+         if k == name then
+            return __type__[path]
+         end
+         return __type__[k]
+      end,
+      __newindex = function(_, k, v)
+         if k == name then
+            __type__[path] = v
+         else
+            __type__[k] = v
+         end
+      end,
+   })
+end
+
 function __gi_GetRangeCheck(x, i)
    if i == nil then
       print(debug.traceback())
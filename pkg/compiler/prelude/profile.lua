@@ -0,0 +1,32 @@
+-- profile.lua: the sampling profiler behind :profile; see
+-- pkg/compiler/profile.go.
+--
+-- Samples are counted by stack. A stack is a string
+-- of "chunk:line" frames, leaf first, separated by ';'.
+
+local require = require -- luaLockdown may remove the global
+
+__gi_profStacks = nil
+
+function __gi_profStart(ms, depth)
+   local profile = require("jit.profile")
+   local dumpstack = profile.dumpstack
+   local stacks = {}
+   __gi_profStacks = stacks
+   profile.start("li"..tostring(ms), function(th, samples, vmstate)
+      local s = dumpstack(th, "lZ;", depth)
+      stacks[s] = (stacks[s] or 0) + samples
+   end)
+end
+
+-- __gi_profStop ends sampling, and returns a line per
+-- stack: the number of samples, a space, and the stack.
+function __gi_profStop()
+   require("jit.profile").stop()
+   local lines = {}
+   for s, n in pairs(__gi_profStacks or {}) do
+      lines[#lines+1] = tostring(n).." "..s
+   end
+   __gi_profStacks = nil
+   return table.concat(lines, "\n")
+end
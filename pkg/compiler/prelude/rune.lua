@@ -1,51 +1,48 @@
-function __decodeRune(s, i)
-   return {__utf8.sub(s, i+1, i+1), 1}
-end
+-- rune.lua: UTF-8, as Go's strings hold it.
+--
+-- from gopherjs, ported to read and write the bytes of
+-- Lua strings with the bit ops.
 
--- from gopherjs, ported to use bit ops.
 __bit =require("bit")
 
---[[
-
--- js op precedence: higher precendence = tighter binding.
---
--- arshift/lshift: 12 left-to-right   
--- band: 9    left-to-right
--- bor : 7    left-to-right
-
+local band, bor, lshift, rshift = __bit.band, __bit.bor, __bit.lshift, __bit.rshift
 
+-- __decodeRune decodes the rune starting at the 0-based
+-- byte pos of str, returning {rune, width}. An invalid
+-- or short encoding gives {0xFFFD, 1}, as
+-- utf8.DecodeRuneInString does.
 __decodeRune = function(str, pos)
-  local c0 = str.charCodeAt(pos);
+  local c0, c1, c2, c3 = string.byte(str, pos + 1, pos + 4)
+  if c0 == nil then
+    return {0xFFFD, 1};
+  end
 
   if c0 < 0x80 then
     return {c0, 1};
   end
 
-  if c0 ~= c0  or  c0 < 0xC0 then
+  if c0 < 0xC0 then
     return {0xFFFD, 1};
   end
 
-  local c1 = str.charCodeAt(pos + 1);
-  if c1 ~= c1  or  c1 < 0x80  or  0xC0 <= c1 then
+  if c1 == nil  or  c1 < 0x80  or  0xC0 <= c1 then
     return {0xFFFD, 1};
   end
 
   if c0 < 0xE0 then
-     local r = __bit.bor(__bit.lshift(__bit.band(c0, 0x1F), 6), __bit.band(c1, 0x3F));
+    local r = bor(lshift(band(c0, 0x1F), 6), band(c1, 0x3F));
     if r <= 0x7F then
       return {0xFFFD, 1};
     end
     return {r, 2};
   end
 
-  local c2 = str.charCodeAt(pos + 2);
-  if c2 ~= c2  or  c2 < 0x80  or  0xC0 <= c2 then
+  if c2 == nil  or  c2 < 0x80  or  0xC0 <= c2 then
     return {0xFFFD, 1};
   end
 
   if c0 < 0xF0 then
-   local r = __bit.bor(__bit.bor(__bit.lshift(__bit.band(c0, 0x0F), 12), __bit.lshift(__bit.band(c1, 0x3F), 6)), __bit.band(c2, 0x3F));
-   
+    local r = bor(lshift(band(c0, 0x0F), 12), lshift(band(c1, 0x3F), 6), band(c2, 0x3F));
     if r <= 0x7FF then
       return {0xFFFD, 1};
     end
@@ -55,14 +52,12 @@ __decodeRune = function(str, pos)
     return {r, 3};
   end
 
-  local c3 = str.charCodeAt(pos + 3);
-  if c3 ~= c3  or  c3 < 0x80  or  0xC0 <= c3 then
+  if c3 == nil  or  c3 < 0x80  or  0xC0 <= c3 then
     return {0xFFFD, 1};
   end
 
   if c0 < 0xF8 then
-    local r = __bit.bor(__bit.bor(__bit.bor(__bit.lshift(__bit.band(c0, 0x07),18), __bit.lshift(__bit.band(c1, 0x3F), 12)), __bit.lshift(__bit.band(c2, 0x3F), 6), __bit.band(c3, 0x3F)));
-
+    local r = bor(lshift(band(c0, 0x07), 18), lshift(band(c1, 0x3F), 12), lshift(band(c2, 0x3F), 6), band(c3, 0x3F));
     if r <= 0xFFFF  or  0x10FFFF < r then
       return {0xFFFD, 1};
     end
@@ -72,20 +67,27 @@ __decodeRune = function(str, pos)
   return {0xFFFD, 1};
 end;
 
+-- __encodeRune is string(r) for an integer r, of any of
+-- gi's integer representations. Values that are not
+-- valid code points give "�".
 __encodeRune = function(r)
+  if type(r) == "cdata" then
+    if r < 0  or  r > 0x10FFFF then
+      r = 0xFFFD
+    end
+    r = tonumber(r)
+  end
   if r < 0  or  r > 0x10FFFF  or  (0xD800 <= r  and  r <= 0xDFFF) then
     r = 0xFFFD;
   end
   if r <= 0x7F then
-    return String.fromCharCode(r);
+    return string.char(r);
   end
   if r <= 0x7FF then
-    return String.fromCharCode(__bit.bor(0xC0, __bit.arshift(r,6)), __bit.bor(0x80, __bit.band(r, 0x3F)));
+    return string.char(bor(0xC0, rshift(r, 6)), bor(0x80, band(r, 0x3F)));
   end
   if r <= 0xFFFF then
-   return String.fromCharCode(__bit.bor(0xE0, __bit.arshift(r,12)), __bit.bor(0x80, (__bit.band(__bit.arshift(r,6), 0x3F))), __bit.bor(0x80, __bit.band(r, 0x3F)));
+    return string.char(bor(0xE0, rshift(r, 12)), bor(0x80, band(rshift(r, 6), 0x3F)), bor(0x80, band(r, 0x3F)));
   end
-   return String.fromCharCode(__bit.bor(0xF0, __bit.arshift(r, 18)), __bit.bor(0x80, __bit.band(__bit.arshift(r,12),0x3F)), __bit.bor(0x80, __bit.band(__bit.arshift(r,6), 0x3F)), __bit.bor(0x80, __bit.band(r, 0x3F)));
+  return string.char(bor(0xF0, rshift(r, 18)), bor(0x80, band(rshift(r, 12), 0x3F)), bor(0x80, band(rshift(r, 6), 0x3F)), bor(0x80, band(r, 0x3F)));
 end;
-
---]]
@@ -1,40 +1,41 @@
 
+-- __stringToRunes is []rune(str): the runes of str, as
+-- the int32s gi holds in int64s, in a table indexed from
+-- 0 for a slice type to take.
 __stringToRunes = function(str)
-  local array = Int32Array(#str);
-  local rune, j = 0;
-  local i = 0
+  local array = {};
+  local i, j = 0, 0
   local n = #str
-  while true do
-     if i >= n then
-        break
-     end
-     
-     rune = __decodeRune(str, i);
-     array[j] = rune[1];
-     
+  while i < n do
+     local rune = __decodeRune(str, i);
+     array[j] = int64(rune[1]);
      i = i + rune[2]
      j = j + 1
   end
-  -- in js, a subarray is like a slice, a view on a shared ArrayBuffer.
-  return array.subarray(0, j);
+  return array;
 end;
 
+-- __runesToString is string(slice), for a []rune.
 __runesToString = function(slice)
-  if slice.__length == 0 then
-    return "";
+  local parts = {};
+  for i = 0, slice.__length - 1 do
+    parts[i + 1] = __encodeRune(slice.__array[slice.__offset + i]);
   end
-  local str = "";
-  for i = 0,#slice-1 do
-    str = str .. __encodeRune(slice.__array[slice.__offset + i]);
-  end
-  return str;
+  return table.concat(parts);
 end;
 
 
 __copyString = function(dst, src)
   local n = __min(#src, dst.__length);
   for i = 0,n-1 do
-    dst.__array[dst.__offset + i] = src.charCodeAt(i);
+    dst.__array[dst.__offset + i] = string.byte(src, i+1);
   end
   return n;
 end;
+
+-- luar hands a Go error to Lua as its message, so a string
+-- answers Error() with itself, and err.Error() works on the
+-- errors Go packages return.
+string.Error = function(s)
+  return s;
+end;
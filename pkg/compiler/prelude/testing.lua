@@ -0,0 +1,472 @@
+-- testing.lua: the runtime for the shadow testing
+-- package used by gi test; see pkg/compiler/gitest.go.
+--
+-- Each test, and each subtest started with t:Run,
+-- runs in its own goroutine. t:Run waits until the
+-- subtest either finishes or calls t:Parallel; a
+-- parallel subtest then waits for its parent's body
+-- to return before going on, as in Go.
+--
+-- Output follows go test: "=== RUN" lines as tests
+-- start (verbose only), then "--- PASS/FAIL/SKIP"
+-- reports with the test's log indented beneath, nested
+-- under the parent's report. The lines collect in
+-- __testingLines, for the Go side to print.
+
+local testing = __gi_package("testing")
+
+__testingVerbose = false
+__testingShort = false
+__testingLines = {}
+
+-- raised by FailNow and SkipNow, and caught in __testingRunT.
+local __testingFailNow = {}
+local __testingSkipNow = {}
+
+local __testingStr = function(v)
+   if v == nil then
+      return "<nil>"
+   end
+   if type(v) == "cdata" then
+      local s = tostring(v)
+      local n = s:match("^(-?%d+)U?LL$")
+      if n then
+         return n
+      end
+      return s
+   end
+   return tostring(v)
+end
+
+-- __testingSprintf formats like fmt.Sprintf, for
+-- the verbs that tests commonly use.
+__testingSprintf = function(format, ...)
+   local args = {...}
+   local n = select("#", ...)
+   local i = 0
+   local s = format:gsub("%%([-+# 0]*%d*%.?%d*)([%a%%])", function(flags, verb)
+      if verb == "%" then
+         return "%"
+      end
+      i = i + 1
+      if i > n then
+         return "%!"..verb.."(MISSING)"
+      end
+      local a = args[i]
+      if verb == "v" or verb == "s" then
+         return string.format("%"..flags.."s", __testingStr(a))
+      elseif verb == "d" then
+         return string.format("%"..flags.."s", __testingStr(a))
+      elseif verb == "q" then
+         return string.format("%q", __testingStr(a))
+      elseif verb == "t" then
+         return tostring(a)
+      elseif verb == "T" then
+         if type(a) == "table" and a.__typ ~= nil then
+            return a.__typ.__str
+         end
+         return type(a)
+      end
+      local ok, r = pcall(string.format, "%"..flags..verb, tonumber(a))
+      if ok then
+         return r
+      end
+      return "%!"..verb.."("..__testingStr(a)..")"
+   end)
+   return s
+end
+
+-- __testingSprint joins its arguments with spaces, like fmt.Sprintln.
+local __testingSprint = function(...)
+   local parts = {}
+   for i = 1, select("#", ...) do
+      parts[i] = __testingStr((select(i, ...)))
+   end
+   return table.concat(parts, " ")
+end
+
+local __testingNow = function()
+   return tonumber(__abs_now()) / 1e9
+end
+
+local __testingEmit = function(line)
+   __testingLines[#__testingLines+1] = line
+end
+
+local __testingT = {}
+__testingT.__index = __testingT
+
+local __testingNewT = function(name, parent)
+   local t = setmetatable({}, __testingT)
+   t.name = name
+   t.parent = parent
+   t.failed = false
+   t.skipped = false
+   t.isParallel = false
+   t.output = {}
+   t.cleanups = {}
+   t.parallelSubs = {}
+   t.subNames = {}
+   t.signal = __Chan(__type__.bool, 1)
+   t.done = __Chan(__type__.bool, 1)
+   t.barrier = __Chan(__type__.bool, 0)
+   return t
+end
+
+function __testingT:log(msg)
+   for line in (msg.."\n"):gmatch("(.-)\n") do
+      self.output[#self.output+1] = "    "..line
+   end
+end
+
+function __testingT:Name() return self.name end
+function __testingT:Helper() end
+function __testingT:Fail() self.failed = true end
+function __testingT:Failed() return self.failed end
+function __testingT:Skipped() return self.skipped end
+
+function __testingT:FailNow()
+   self.failed = true
+   error(__testingFailNow, 0)
+end
+
+function __testingT:SkipNow()
+   self.skipped = true
+   error(__testingSkipNow, 0)
+end
+
+function __testingT:Log(...) self:log(__testingSprint(...)) end
+function __testingT:Logf(format, ...) self:log(__testingSprintf(format, ...)) end
+function __testingT:Error(...) self:log(__testingSprint(...)); self:Fail() end
+function __testingT:Errorf(format, ...) self:log(__testingSprintf(format, ...)); self:Fail() end
+function __testingT:Fatal(...) self:log(__testingSprint(...)); self:FailNow() end
+function __testingT:Fatalf(format, ...) self:log(__testingSprintf(format, ...)); self:FailNow() end
+function __testingT:Skip(...) self:log(__testingSprint(...)); self:SkipNow() end
+function __testingT:Skipf(format, ...) self:log(__testingSprintf(format, ...)); self:SkipNow() end
+
+function __testingT:Cleanup(f)
+   self.cleanups[#self.cleanups+1] = f
+end
+
+function __testingT:Parallel()
+   if self.isParallel then
+      error("testing: t.Parallel called multiple times")
+   end
+   self.isParallel = true
+   if __testingVerbose then
+      __testingEmit("=== PAUSE "..self.name)
+   end
+   -- let Run return in the parent, then wait for
+   -- the parent's body to finish.
+   __send(self.signal, true)
+   __recv(self.parent.barrier)
+   if __testingVerbose then
+      __testingEmit("=== CONT  "..self.name)
+   end
+   self.start = __testingNow()
+end
+
+-- report returns the lines reporting t's result.
+function __testingT:report()
+   local status = "PASS"
+   if self.failed then
+      status = "FAIL"
+   elseif self.skipped then
+      status = "SKIP"
+   end
+   local lines = {string.format("--- %s: %s (%.2fs)", status, self.name, self.elapsed)}
+   for _, line in ipairs(self.output) do
+      lines[#lines+1] = line
+   end
+   return lines
+end
+
+-- flush passes t's report up to its parent, or out
+-- at the top level. Passing tests are only reported
+-- when verbose.
+function __testingT:flush()
+   if self.parent == nil or not (__testingVerbose or self.failed) then
+      return
+   end
+   local lines = self:report()
+   local parent = self.parent
+   if parent.parent == nil then
+      for _, line in ipairs(lines) do
+         __testingEmit(line)
+      end
+      return
+   end
+   for _, line in ipairs(lines) do
+      parent.output[#parent.output+1] = "    "..line
+   end
+end
+
+-- __testingRunT is the body of a test's goroutine.
+local __testingRunT = function(t, f)
+   t.start = __testingNow()
+   local ok, err = pcall(f, t)
+   if not ok and err ~= __testingFailNow and err ~= __testingSkipNow then
+      t.failed = true
+      t:log("panic: "..__testingStr(err))
+   end
+
+   -- wait for the parallel subtests.
+   __close(t.barrier)
+   for _, sub in ipairs(t.parallelSubs) do
+      __recv(sub.done)
+   end
+
+   for i = #t.cleanups, 1, -1 do
+      local ok, err = pcall(t.cleanups[i])
+      if not ok then
+         t.failed = true
+         t:log("panic in Cleanup: "..__testingStr(err))
+      end
+   end
+
+   t.elapsed = __testingNow() - t.start
+   if t.failed and t.parent ~= nil then
+      t.parent.failed = true
+   end
+   t:flush()
+   if t.isParallel then
+      __send(t.done, true)
+   else
+      __send(t.signal, true)
+   end
+end
+
+-- subName makes a subtest's full name, unique among its siblings.
+function __testingT:subName(name)
+   name = name:gsub(" ", "_")
+   local full = name
+   if self.parent ~= nil then
+      full = self.name.."/"..name
+   end
+   local seen = self.subNames[full]
+   self.subNames[full] = (seen or 0) + 1
+   if seen ~= nil then
+      full = string.format("%s#%02d", full, seen)
+   end
+   return full
+end
+
+function __testingT:Run(name, f)
+   local sub = __testingNewT(self:subName(name), self)
+   if __testingVerbose then
+      __testingEmit("=== RUN   "..sub.name)
+   end
+   __task.spawn(__testingRunT, {sub, f})
+   __recv(sub.signal)
+   if sub.isParallel then
+      self.parallelSubs[#self.parallelSubs+1] = sub
+      return true
+   end
+   return not sub.failed
+end
+
+testing.Short = function() return __testingShort end
+testing.Verbose = function() return __testingVerbose end
+
+-- Benchmarks.
+--
+-- A *testing.B shares T's logging and failure methods.
+-- Each benchmark is run with b.N = 1, then with b.N
+-- scaled up from the time taken, until one run lasts
+-- the bench time. Lua cannot count its allocations, so
+-- B/op is the growth of the Lua heap per op, measured
+-- with the collector stopped, and allocs/op counts the
+-- Go heap objects allocated, e.g. by imported packages.
+
+__testingBenchTime = 1
+__testingBenchMem = false
+
+local __testingB = setmetatable({}, {__index = __testingT})
+__testingB.__index = __testingB
+
+local __testingNewB = function(name, parent)
+   local b = __testingNewT(name, parent)
+   setmetatable(b, __testingB)
+   b.N = int64(1)
+   b.timerOn = false
+   b.ns = 0
+   b.heapKB = 0
+   b.mallocs = 0
+   b.bytes = 0
+   b.showAllocs = __testingBenchMem
+   b.hasSub = false
+   return b
+end
+
+function __testingB:StartTimer()
+   if not self.timerOn then
+      self.timerOn = true
+      self.t0 = __testingNow()
+      self.heap0 = collectgarbage("count")
+      self.mallocs0 = __gi_goMallocs()
+   end
+end
+
+function __testingB:StopTimer()
+   if self.timerOn then
+      self.timerOn = false
+      self.ns = self.ns + (__testingNow() - self.t0) * 1e9
+      self.heapKB = self.heapKB + collectgarbage("count") - self.heap0
+      self.mallocs = self.mallocs + __gi_goMallocs() - self.mallocs0
+   end
+end
+
+function __testingB:ResetTimer()
+   if self.timerOn then
+      self.t0 = __testingNow()
+      self.heap0 = collectgarbage("count")
+      self.mallocs0 = __gi_goMallocs()
+   end
+   self.ns = 0
+   self.heapKB = 0
+   self.mallocs = 0
+end
+
+function __testingB:ReportAllocs() self.showAllocs = true end
+function __testingB:SetBytes(n) self.bytes = tonumber(n) end
+
+-- runN runs the benchmark function once, with b.N = n.
+function __testingB:runN(f, n)
+   collectgarbage("collect")
+   if self.showAllocs then
+      collectgarbage("stop")
+   end
+   self.N = int64(n)
+   self:ResetTimer()
+   self:StartTimer()
+   local ok, err = pcall(f, self)
+   self:StopTimer()
+   collectgarbage("restart")
+   if not ok and err ~= __testingFailNow and err ~= __testingSkipNow then
+      self.failed = true
+      self:log("panic: "..__testingStr(err))
+   end
+   return ok
+end
+
+-- launch grows b.N until a run takes the bench time.
+function __testingB:launch(f)
+   local n = 1
+   local ok = self:runN(f, n)
+   if self.hasSub then
+      -- only the sub-benchmarks are measured.
+      return
+   end
+   local goal = __testingBenchTime * 1e9
+   while ok and self.ns < goal and n < 1e9 do
+      local last = n
+      local prev = math.max(self.ns, 1)
+      n = math.floor(goal * last / prev)
+      n = n + math.floor(n / 5)
+      n = math.min(n, 100 * last)
+      n = math.max(n, last + 1)
+      n = math.min(n, 1e9)
+      ok = self:runN(f, n)
+   end
+end
+
+local __testingPad = function(s, width)
+   return s..string.rep(" ", width - #s)
+end
+
+-- result formats a benchmark's line, like go test's.
+function __testingB:result()
+   local n = tonumber(self.N)
+   local nsop = self.ns / n
+   local nsfmt = "%10.0f ns/op"
+   if nsop < 10 then
+      nsfmt = "%10.2f ns/op"
+   elseif nsop < 100 then
+      nsfmt = "%10.1f ns/op"
+   end
+   local parts = {string.format("%8d", n), string.format(nsfmt, nsop)}
+   if self.bytes > 0 and self.ns > 0 then
+      parts[#parts+1] = string.format("%7.2f MB/s", self.bytes * n / self.ns * 1e3)
+   end
+   if self.showAllocs then
+      parts[#parts+1] = string.format("%8d B/op", math.floor(math.max(self.heapKB, 0) * 1024 / n))
+      parts[#parts+1] = string.format("%8d allocs/op", math.floor(self.mallocs / n))
+   end
+   return __testingPad(self.name, __testingNameWidth).."\t"..table.concat(parts, "\t")
+end
+
+-- runBench runs one benchmark and reports it.
+local __testingRunBench = function(b, f)
+   b.start = __testingNow()
+   b:launch(f)
+   b.elapsed = __testingNow() - b.start
+   if b.failed then
+      if b.parent ~= nil then
+         b.parent.failed = true
+      end
+      __testingEmit(b:report()[1])
+      for _, line in ipairs(b.output) do
+         __testingEmit(line)
+      end
+      return
+   end
+   if b.skipped then
+      if __testingVerbose then
+         for _, line in ipairs(b:report()) do
+            __testingEmit(line)
+         end
+      end
+      return
+   end
+   if not b.hasSub then
+      __testingEmit(b:result())
+   end
+   if #b.output > 0 then
+      __testingEmit(b:report()[1])
+      for _, line in ipairs(b.output) do
+         __testingEmit(line)
+      end
+   end
+end
+
+function __testingB:Run(name, f)
+   self.hasSub = true
+   local sub = __testingNewB(self:subName(name), self)
+   sub.showAllocs = self.showAllocs
+   __testingRunBench(sub, f)
+   return not sub.failed
+end
+
+__testingNameWidth = 0
+
+-- __gi_runTests runs the top-level tests, a list of
+-- {name, func} pairs, and then if they all passed, the
+-- benchmarks, another such list. It returns true if
+-- everything passed.
+function __gi_runTests(tests, benchmarks, verbose, short, benchtime, benchmem)
+   __testingVerbose = verbose
+   __testingShort = short
+   __testingBenchTime = benchtime
+   __testingBenchMem = benchmem
+   __testingLines = {}
+   local root = __testingNewT("", nil)
+   __task.spawn(__testingRunT, {root, function(t)
+      for _, test in ipairs(tests) do
+         t:Run(test[1], test[2])
+      end
+   end})
+   __recv(root.signal)
+   if root.failed then
+      return false
+   end
+
+   __testingNameWidth = 0
+   for _, bench in ipairs(benchmarks) do
+      __testingNameWidth = math.max(__testingNameWidth, #bench[1])
+   end
+   local broot = __testingNewB("", nil)
+   for _, bench in ipairs(benchmarks) do
+      __testingRunBench(__testingNewB(bench[1], broot), bench[2])
+   end
+   return not broot.failed
+end
@@ -15,6 +15,7 @@
 --
 __minifs = {}
 __ffi = require "ffi"
+local __ffi, debug, loadstring = __ffi, debug, loadstring -- luaLockdown may remove the globals
 local __osname = __ffi.os == "Windows" and "windows" or "unix"
 
 -- __top_of_defer just marks our position on
@@ -254,7 +255,6 @@ __bit = require("bit")
 __type__ ={}; -- global repo of types
 __global ={};
 __module ={};
-__packages = {}
 __idCounter = 0;
 __pkg = {};
 
@@ -305,9 +305,10 @@ __parseFloat = function(f)
    return tonumber(f);
 end;
 
--- __fround returns nearest float32
+-- __fround returns nearest float32, as the Lua number
+-- gi holds float32s in.
 __fround = function(x)
-   return float32(x)
+   return tonumber(float32(x))
 end;
 
 --[[
@@ -719,6 +720,37 @@ __copySlice = function(dst, src)
    return int(n);
 end;
 
+-- __clearSlice is the builtin clear on a slice: each of
+-- its elements becomes the zero value.
+__clearSlice = function(s)
+   local elem = s.__constructor.elem
+   local zero = elem.zero
+   if elem.kind == __kindStruct then
+      -- a struct value is held as a pointer to it, and
+      -- its tfun, given no fields, zeroes each of them.
+      zero = function()
+         return elem.ptr(elem.tfun())
+      end
+   end
+   local a, o = s.__array, s.__offset
+   for i = 0, s.__length - 1 do
+      a[o + i] = zero()
+   end
+end;
+
+-- __clearMap is the builtin clear on a map, a no-op on a
+-- nil one, which gi holds as false.
+__clearMap = function(m)
+   if not m then
+      return
+   end
+   -- rawset, as the map's __newindex stores entries.
+   rawset(m, "__val", {})
+   rawset(m, "nilKeyStored", false)
+   rawset(m, "nilValue", nil)
+   rawset(m, "len", 0)
+end;
+
 --
 
 __copyArray = function(dst, src, dstOffset, srcOffset, n, elem)
@@ -734,6 +766,14 @@ __copyArray = function(dst, src, dstOffset, srcOffset, n, elem)
       return;
    end
 
+   -- between two byte buffers, one memory copy.
+   local db = type(dst) == "table" and rawget(dst, "__bytes")
+   local sb = type(src) == "table" and rawget(src, "__bytes")
+   if db and sb and dst ~= src then
+      __ffi.copy(db + dstOffset, sb + srcOffset, n)
+      return;
+   end
+
    local sw = elem.kind
    if sw == __kindArray or sw == __kindStruct then
       
@@ -773,6 +813,15 @@ __clone = function(src, typ)
    if typ.__name == "native_Go_struct_type_wrapper" then
       return typ(src) -- if src is nil, return zero value, else copy of src.
    end
+   if typ.kind == __kindArray and type(src) == "table" and getmetatable(src) == nil then
+      -- an array returned from Go, which luar gives
+      -- as a plain table, indexed from 1.
+      local array = {}
+      for i = 1, typ.len do
+         array[i-1] = src[i]
+      end
+      return typ(array)
+   end
    local clone = typ()
    typ.copy(clone, src);
    return clone;
@@ -870,13 +919,18 @@ __internalAppend = function(slice, array, offset, length)
       end
       newCapacity = __max(newLength, tmpCap);
 
-      newArray = {}
-      local w = slice.__offset
-      for i = 0,slice.__length do
-         newArray[i] = slice.__array[i + w]
-      end
-      for i = #slice,newCapacity-1 do
-         newArray[i] = elem.zero();
+      if elem.kind == __kindUint8 then
+         newArray = __newByteArray(newCapacity)
+         __copyArray(newArray, slice.__array, 0, slice.__offset, slice.__length, elem)
+      else
+         newArray = {}
+         local w = slice.__offset
+         for i = 0,slice.__length do
+            newArray[i] = slice.__array[i + w]
+         end
+         for i = #slice,newCapacity-1 do
+            newArray[i] = elem.zero();
+         end
       end
       
    end
@@ -921,8 +975,23 @@ end;
 
 --
 
+-- __valueBasicMT is the metatable of a basic value boxed
+-- with its type, {__val=v, __typ=T}, as a named or sized
+-- basic value is when it goes into an interface. T's
+-- methods take the bare value.
 __valueBasicMT = {
    __name = "__valueBasicMT",
+   __index = function(self, k)
+      local typ = rawget(self, "__typ")
+      local proto = typ and rawget(typ, "prototype")
+      local meth = proto and proto[k]
+      if type(meth) ~= "function" then
+         return nil
+      end
+      return function(this, ...)
+         return meth(rawget(this, "__val"), ...)
+      end
+   end,
    __tostring = function(self, ...)
       --print("__tostring called from __valueBasicMT")
       if type(self.__val) == "string" then
@@ -945,6 +1014,18 @@ __valueBasicMT = {
    end,
 }
 
+-- __gi_box boxes v with the type name of the package at
+-- path, or leaves v bare if that type is bound from Go,
+-- whose values come from luar bare.
+__gi_box = function(path, name, v)
+   local pkgTypes = rawget(__type__, path)
+   local typ = type(pkgTypes) == "table" and rawget(pkgTypes, name)
+   if type(typ) ~= "table" or typ.kind == nil then
+      return v
+   end
+   return typ(v)
+end
+
 -- use for slices and arrays
 __valueSliceIpairs = function(t)
    
@@ -1075,7 +1156,14 @@ __valueSliceMT = {
       if ktype == "string" then
          --print("we have string key, doing rawget on t")
          --__st(t, "t")
-         return rawget(t,k)
+         local v = rawget(t,k)
+         if v ~= nil then
+            return v
+         end
+         -- the methods of a named slice type.
+         local typ = rawget(t, "__constructor")
+         local proto = typ and rawget(typ, "prototype")
+         return proto and proto[k]
       elseif ktype == "table" then
          print("callstack:"..tostring(debug.traceback()))
          error("table as key not supported in __valueSliceMT")
@@ -1221,6 +1309,15 @@ __valuePointerMT = {
 
    __index = function(t, k)
       --print("__valuePointerMT: __index called, doing get()")       
+      if type(k) == "string" then
+         -- the methods of *T, for T not a struct.
+         local typ = rawget(t, "__typ")
+         local proto = typ and rawget(typ, "prototype")
+         local meth = proto and proto[k]
+         if meth ~= nil then
+            return meth
+         end
+      end
       return t.__get()
    end,
 
@@ -1272,12 +1369,64 @@ __synthesizeMethods = function()
    __methodSynthesizers = nil;
 end;
 
+-- __dynamicType gives the gi type of v, a value held in
+-- an interface, or nil when v is one of the plain Lua
+-- values gi keeps its basic types in. A named or sized
+-- basic value is boxed, and gives its type. A struct
+-- value, held as a pointer to it, gives the struct type.
+__dynamicType = function(v)
+   if type(v) ~= "table" then
+      return nil
+   end
+   local ok, typ = pcall(function()
+      return rawget(v, "__typ") or (getmetatable(v) ~= nil and v.__typ)
+   end)
+   if not ok or type(typ) ~= "table" or typ.kind == nil then
+      return nil
+   end
+   if typ.kind == __kindPtr and rawget(v, "__name") == "__pointerToStructValue" then
+      return typ.elem
+   end
+   return typ
+end;
+
+-- __basicTypeName names, for messages, the basic type of
+-- a plain Lua value.
+__basicTypeName = function(v)
+   local ty = type(v)
+   if ty == "cdata" then
+      return (tostring(__ffi.typeof(v)):gsub("^ctype<(.*)>$", "%1"))
+   elseif ty == "number" then
+      return "float64"
+   elseif ty == "function" then
+      return "func"
+   end
+   return ty
+end;
+
+-- __ifaceKeyFor is the map key of an interface value: its
+-- dynamic type and that type's own key for it, so that
+-- 1 and "1" are different keys, and equal structs the
+-- same one.
 __ifaceKeyFor = function(x)
-   if x == __ifaceNil then
+   if x == __ifaceNil or x == nil then
       return 'nil';
    end
-   local c = x.constructor;
-   return c.__str .. '__' .. c.keyFor(x.__val);
+   local typ = __dynamicType(x)
+   if typ == nil then
+      local ty = type(x)
+      if ty == "function" or ty == "table" then
+         __throwRuntimeError("hash of unhashable type " .. __basicTypeName(x));
+      end
+      return __basicTypeName(x) .. '__' .. tostring(x);
+   end
+   if not typ.comparable then
+      __throwRuntimeError("hash of unhashable type " .. typ.__str);
+   end
+   if typ.kind == __kindStruct or getmetatable(x) == __valueBasicMT then
+      return typ.__str .. '__' .. typ.keyFor(x.__val);
+   end
+   return typ.__str .. '__' .. typ.keyFor(x);
 end;
 
 __identity = function(x) return x; end;
@@ -1330,6 +1479,7 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
       end;
       typ.wrapped = true;
       typ.keyFor = function(x) return tostring(x); end;
+      typ.prototype = {}
 
    elseif kind == __kindString then
       
@@ -1344,6 +1494,7 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
       end;
       typ.wrapped = true;
       typ.keyFor = __identity; -- function(x) return "_" .. x; end;
+      typ.prototype = {}
 
    elseif kind == __kindFloat32 or
    kind == __kindFloat64 then
@@ -1357,19 +1508,26 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
       end;
       typ.wrapped = true;
       typ.keyFor = function(x) return __floatKey(x); end;
+      typ.prototype = {}
 
 
    elseif kind ==  __kindComplex64 then
 
       typ.tfun = function(re, im)
          local this = {};
-         this.__val = re + im*complex(0,1);
+         if im == nil then
+            -- boxing a complex value
+            this.__val = re;
+         else
+            this.__val = re + im*complex(0,1);
+         end
          this.__typ = typ;
          setmetatable(this, __valueBasicMT);
          return this;
       end;
       typ.wrapped = true;
       typ.keyFor = function(x) return tostring(x); end;
+      typ.prototype = {}
       
       --    typ.tfun = function(real, imag)
       --      local this={};
@@ -1384,13 +1542,18 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
 
       typ.tfun = function(re, im)
          local this = {}
-         this.__val = re + im*complex(0,1);
+         if im == nil then
+            this.__val = re;
+         else
+            this.__val = re + im*complex(0,1);
+         end
          this.__typ = typ
          setmetatable(this, __valueBasicMT)
          return this
       end;
       typ.wrapped = true;
       typ.keyFor = function(x) return tostring(x); end;
+      typ.prototype = {}
       
       --     typ.tfun = function(real, imag)
       --        local this={};
@@ -1429,6 +1592,8 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
             return this;
          end;
       typ.keyFor = __idKey;
+      -- method set of *T; a pointer to struct replaces it.
+      typ.prototype = {}
       
       typ.init = function(elem)
          --print("init(elem) for pointer type called.")
@@ -1445,6 +1610,11 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
          --print(debug.traceback())
          --print("slice tfun for type '"..__addressof(typ).."' called with array = ")
          --__st(array)
+         if type(array) == "table" and rawget(array, "__name") == "__arrayValue" then
+            -- slicing an array, a[:], shares its storage;
+            -- Go, through luar, reads that directly.
+            array = array.__val
+         end
          this.__array = array;
          this.__offset = 0;
          this.__length = __lenz(array)
@@ -1464,6 +1634,8 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
          setmetatable(this, __valueSliceMT)
          return this
       end;
+      -- method set, for named slice types.
+      typ.prototype = {}
       typ.init = function(elem)
          typ.elem = elem;
          typ.comparable = false;
@@ -1594,18 +1766,18 @@ __newType = function(size, kind, str, named, pkg, exported, constructor)
          this.__typ = typ         
          this.__val = {}; --no meta names, so clean. No accidental collisions.
 
-         local kff = typ.elem.keyFor
+         local kff = typ.key.keyFor
          this.nilKeyStored = false
          
          local len=0
          for k, e in pairs(entries) do
             if k == nil then
                this.nilKeyStored = true
-               this.nilValue = e or __intentionalNilValue
+               this.nilValue = (e == nil) and __intentionalNilValue or e
             else 
                local key = tostring(kff(k)) -- must be a string!
                --print("using key ", key, " for k=", k)
-               this.__val[key] = e or __intentionalNilValue;
+               this.__val[key] = (e == nil) and __intentionalNilValue or e;
             end
             len=len+1;
          end
@@ -2091,7 +2263,12 @@ __ptrType = function(elem)
       error("internal error: cannot call __ptrType() will nil elem")
    end
    local typ = elem.ptr;
-   if typ == nil then
+   if typ == nil and elem.__name == "native_Go_struct_type_wrapper" then
+      -- pointers to Go's structs are luar proxies, and
+      -- their nil is Lua's, which luar gives Go as nil.
+      typ = {kind = __kindPtr, elem = elem, __str = "*" .. elem.__native_type};
+      elem.ptr = typ;
+   elseif typ == nil then
       typ = __newType(4, __kindPtr, "*" .. elem.__str, false, "", elem.exported, nil);
       __dfsGlobal:addChild(typ, elem)
       elem.ptr = typ;
@@ -2331,8 +2508,8 @@ end;
 
 function __interfaceStrHelper(m)
    local s = ""
-   if m.pkg ~= "" then
-      s = m.pkg .. "."
+   if (m.__pkg or "") ~= "" then
+      s = m.__pkg .. "."
    end
    return s .. m.__name .. string.sub(m.__typ.__str, 6) -- sub for removing "__kind"
 end
@@ -2341,7 +2518,7 @@ __interfaceTypes = {};
 __interfaceType = function(methods)
    
    local typeKey = __mapAndJoinStrings("_", methods, function(m)
-                                          return m.pkg .. "," .. m.__name .. "," .. m.__typ.id;
+                                          return (m.__pkg or "") .. "," .. m.__name .. "," .. m.__typ.id;
    end)
    local typ = __interfaceTypes[typeKey];
    if typ == nil then
@@ -2452,7 +2629,7 @@ __valueMapMT = {
 
       -- invar: k is not nil
 
-      local ks = t.__typ.elem.keyFor(k)
+      local ks = t.__typ.key.keyFor(k)
       --local ks = tostring(k)
       if v ~= nil then
          if t.__val[ks] == nil then
@@ -2495,7 +2672,7 @@ __valueMapMT = {
       
       -- k is not nil.
 
-      local ks = t.__typ.elem.keyFor(k)      
+      local ks = t.__typ.key.keyFor(k)      
       --local ks = tostring(k)
       
       local val = t.__val[ks]
@@ -2520,7 +2697,11 @@ __valueMapMT = {
          kquo = '"'
       end
       
-      for i, v in pairs(r) do
+      local iter = pairs
+      if __gi_deterministic then
+         iter = __sortedPairs
+      end
+      for i, v in iter(r) do
          s = s .. kquo..tostring(i)..kquo.. ": " .. vquo..tostring(v) ..vquo.. ", "
       end
       return s .. "}"
@@ -2536,6 +2717,10 @@ __valueMapMT = {
      --print("map __pairs called!")
       -- this makes a map work in a for k,v in pairs() do loop.
 
+      if __gi_deterministic then
+         return __sortedPairs(t.__val)
+      end
+
       -- Iterator function takes the table and an index and returns the next index and associated value
       -- or nil to end iteration
 
@@ -2606,7 +2791,7 @@ __valueMapMT = {
          end
          
          -- k is not nil.
-         local ks = tostring(k)      
+         local ks = t.__typ.key.keyFor(k)
          local val = t.__val[ks]
          if val == __intentionalNilValue then
             --print("val is the __intentionalNilValue")
@@ -2639,7 +2824,7 @@ __valueMapMT = {
             return
          end
 
-         local ks = tostring(k)           
+         local ks = t.__typ.key.keyFor(k)
          if t.__val[ks] == nil then
             -- key not present
             return
@@ -2746,7 +2931,12 @@ __makeSlice = function(typ, length, capacity)
    if capacity < 0  or  capacity < length  or  capacity > 9007199254740992 then
       __throwRuntimeError("makeslice: cap out of range: "..tostring(capacity));
    end
-   local array = __newAnyArrayValue(typ.elem, capacity)
+   local array
+   if typ.elem.kind == __kindUint8 then
+      array = __newByteArray(capacity)
+   else
+      array = __newAnyArrayValue(typ.elem, capacity)
+   end
    local slice = typ(array);
    slice.__length = length;
    return slice;
@@ -2838,53 +3028,37 @@ __equal = function(a, b, typ)
 end;
 
 __interfaceIsEqual = function(a, b)
-   print("top of __interfaceIsEqual! a is:")
-   __st(a,"a")
-   print("top of __interfaceIsEqual! b is:")   
-   __st(b,"b")
    if a == nil or b == nil then
-      print("one or both is nil")
-      if a == nil and b == nil then
-         print("both are nil")
-         return true
-      else
-         print("one is nil, one is not")
-         return false
-      end
+      return a == nil and b == nil
    end
-   print("neither is raw nil. comparing to __ifaceNil")
-   __st(__ifaceNil, "__ifaceNil")
-   
    if a == __ifaceNil  or  b == __ifaceNil then
-      print("one or both is __ifaceNil")
       return a == b;
    end
-   print("neither is nil")
-   
-   local tya = type(a)
-   local tyb = type(b)
-   
-   if tya == "table" and tyb ~= "table" then
-      return false
-   elseif tya ~= "table" and tyb == "table" then
-      return false
-   elseif tya ~= "table" and tyb ~= "table" then
+   local ta = __dynamicType(a)
+   local tb = __dynamicType(b)
+   if ta == nil and tb == nil then
+      local tya = type(a)
+      if tya ~= type(b) or (tya == "cdata" and __ffi.typeof(a) ~= __ffi.typeof(b)) then
+         return false
+      end
+      if tya == "function" then
+         __throwRuntimeError("comparing uncomparable type func");
+      end
       return a == b
    end
-   
-   if a.constructor ~= b.constructor then
-      print("constructors not equal.")
+   if ta ~= tb then
       return false;
    end
-   if a.constructor == __jsObjectPtr then
+   if ta == __jsObjectPtr then
       return a.object == b.object;
    end
-   if  not a.constructor.comparable then
-      __throwRuntimeError("comparing uncomparable type "  ..  a.constructor.__str);
+   if not ta.comparable then
+      __throwRuntimeError("comparing uncomparable type " .. ta.__str);
    end
-
-   --print("calling __equal on a.__val and b.__val with a.constructor = ")
-   return __equal(a.__val, b.__val, a.constructor);
+   if ta.kind == __kindStruct or getmetatable(a) == __valueBasicMT then
+      return __equal(a.__val, b.__val, ta);
+   end
+   return __equal(a, b, ta);
 end;
 
 
@@ -2898,13 +3072,17 @@ __assertType = function(value, typ, returnTuple)
    elseif  not isInterface then
       local knd = __basicValue2kind(value)
       if knd ~= __kindUnknown then
-         -- known basic type
-         if typ.kind == knd then
+         -- a bare basic value is of the predeclared type
+         -- it stands for; named and sized ones are boxed.
+         if typ.kind == knd and typ.pkg == "" then
             return value, true
          end
-      else
+      elseif type(value) == "table" then
          ok = value.__typ == typ;
       end
+   elseif type(value) ~= "table" then
+      -- a bare basic value has no methods.
+      ok = #typ.methods == 0
    else
       local valueTypeString = value.__typ.__str;
 
@@ -2961,7 +3139,8 @@ __assertType = function(value, typ, returnTuple)
       end
       local msg = ""
       if value ~= __ifaceNil then
-         msg = value.__typ.__str
+         local vt = __dynamicType(value)
+         msg = vt and vt.__str or __basicTypeName(value)
       end
       --__panic(__packages["runtime"].TypeAssertionError.ptr("", msg, typ.__str, missingMethod));
       error("type-assertion-error: could not '"..msg.."' -> '"..typ.__str.."', missing method '"..missingMethod.."'")
@@ -3081,21 +3260,330 @@ function __lazy_ellipsis(t)
    return r
 end
 
-function __printHelper(v)
+-- __gi_spreadArgs returns the variadic arguments of a call
+-- as a table, and their number: f(a, b) passes them one by
+-- one, and f(xs...) as a lazy ellipsis of the slice.
+function __gi_spreadArgs(...)
+   local a = {...}
+   local n = select("#", ...)
+   local e = a[1]
+   if n == 1 and type(e) == "table" and e.__name == "__lazy_ellipsis_instance" then
+      local s = e()
+      a, n = {}, s.__length
+      for i = 1, n do
+         a[i] = s.__array[s.__offset + i - 1]
+      end
+   end
+   return a, n
+end
+
+-- __gi_showMaxDepth and __gi_showMaxWidth limit what
+-- __gi_show prints: a struct, map, slice or array nested
+-- deeper than __gi_showMaxDepth is elided as T{...}, and
+-- one with more than __gi_showMaxWidth elements shows that
+-- many, and counts the rest. Zero means no limit. They are
+-- set by :set maxdepth and :set maxwidth.
+__gi_showMaxDepth = 8
+__gi_showMaxWidth = 100
+
+-- __gi_showColumns is how wide a value may be shown on
+-- one line before __gi_show breaks it over several.
+__gi_showColumns = 80
+
+-- __gi_quote quotes s as Go's %q would, escaping quotes,
+-- backslashes and control bytes.
+function __gi_quote(s)
+   return '"' .. string.gsub(s, '[%c"\\]', function(c)
+      if c == '"' then
+         return '\\"'
+      elseif c == '\\' then
+         return '\\\\'
+      elseif c == '\n' then
+         return '\\n'
+      elseif c == '\t' then
+         return '\\t'
+      elseif c == '\r' then
+         return '\\r'
+      end
+      return string.format('\\x%02x', string.byte(c))
+   end) .. '"'
+end
 
-   local tv = type(v)
-   if tv == "string" then
-      print("\""..v.."\"") -- used to be backticks
-   elseif tv == "table" then
-      if v.__name == "__lazy_ellipsis_instance" then
-         local expand = v()
-         for _,c in pairs(expand) do
-            __printHelper(c)
+-- __gi_showFloat formats f as Go's %v does: in the fewest
+-- digits that read back as f, with an exponent when it is
+-- below -4 or above 5.
+local function __gi_showFloat(f)
+   if f ~= f then
+      return "NaN"
+   elseif f == __Infinity then
+      return "+Inf"
+   elseif f == -__Infinity then
+      return "-Inf"
+   elseif f == 0 then
+      if 1/f < 0 then
+         return "-0"
+      end
+      return "0"
+   end
+   local s, prec
+   for p = 0, 16 do
+      s, prec = string.format("%." .. p .. "e", f), p
+      if tonumber(s) == f then
+         break
+      end
+   end
+   local exp = tonumber(string.match(s, "e([-+]%d+)$"))
+   if exp < -4 or exp >= 6 then
+      return s
+   end
+   local decimals = prec - exp
+   if decimals < 0 then
+      decimals = 0
+   end
+   return string.format("%." .. decimals .. "f", f)
+end
+
+local function __gi_showCdata(v)
+   local s = tostring(v)
+   local n = string.match(s, "^(%-?%d+)U?LL$")
+   if n then
+      return n
+   end
+   if string.sub(s, -1) == "i" then
+      -- complex
+      return "(" .. s .. ")"
+   end
+   local f = tonumber(v)
+   if f then
+      return __gi_showFloat(f)
+   end
+   return s
+end
+
+-- __gi_showComposite lays out the shown elements of a
+-- value of type name, nested depth deep: on one line if
+-- they fit, or else one to a line; or, for the elements
+-- of a slice or array that fit on a line themselves, as
+-- many to a line as fit.
+local function __gi_showComposite(name, items, more, depth, pack)
+   if more > 0 then
+      table.insert(items, "... (" .. more .. " more)")
+   end
+   local multiline = false
+   for _, item in ipairs(items) do
+      if string.find(item, "\n", 1, true) then
+         multiline = true
+         break
+      end
+   end
+   if not multiline then
+      local line = name .. "{" .. table.concat(items, ", ") .. "}"
+      if 2*depth + #line <= __gi_showColumns then
+         return line
+      end
+   end
+
+   local pad = string.rep("  ", depth+1)
+   local lines = {}
+   if pack and not multiline then
+      local line = ""
+      for _, item in ipairs(items) do
+         if line ~= "" and #pad + #line + #item + 2 > __gi_showColumns then
+            table.insert(lines, pad .. line .. ",")
+            line = ""
+         end
+         if line == "" then
+            line = item
+         else
+            line = line .. ", " .. item
          end
-         return
+      end
+      table.insert(lines, pad .. line .. ",")
+   else
+      for _, item in ipairs(items) do
+         table.insert(lines, pad .. item .. ",")
       end
    end
-   print(v)
+   return name .. "{\n" .. table.concat(lines, "\n") .. "\n" .. string.rep("  ", depth) .. "}"
+end
+
+-- __gi_showString formats x for the REPL, much as Go's
+-- %#v would: a struct, map, slice or array with its type
+-- and contents, laid out over several lines when long,
+-- map keys in order, and a value reached again from
+-- inside itself as <cycle T>. Since struct values are
+-- held by pointer, deref says to show a pointer to a
+-- struct as the struct. maxDepth and maxWidth default to
+-- __gi_showMaxDepth and __gi_showMaxWidth.
+function __gi_showString(x, deref, maxDepth, maxWidth)
+   maxDepth = maxDepth or __gi_showMaxDepth
+   maxWidth = maxWidth or __gi_showMaxWidth
+   local onPath = {}
+
+   -- width is how many of n elements are shown.
+   local function width(n)
+      if maxWidth > 0 and n > maxWidth then
+         return maxWidth
+      end
+      return n
+   end
+
+   local show
+   show = function(v, typ, depth)
+      if v == nil or v == __ifaceNil or v == __intentionalNilValue then
+         return "nil"
+      end
+      local tv = type(v)
+      if tv == "string" then
+         return __gi_quote(v)
+      elseif tv == "number" then
+         return __gi_showFloat(v)
+      elseif tv == "cdata" then
+         return __gi_showCdata(v)
+      elseif tv == "function" then
+         return "func"
+      elseif tv ~= "table" then
+         return tostring(v)
+      end
+
+      if typ ~= nil and typ.kind == __kindPtr and v == typ.__nil then
+         return "nil"
+      end
+      local mt = getmetatable(v)
+      if mt == nil and rawget(v, "__val") == v and next(v, next(v)) == nil then
+         -- the nil pointer to a struct.
+         return "nil"
+      end
+      if mt == __valuePointerMT then
+         local ok, target = pcall(rawget(v, "__get"))
+         if not ok then
+            return "nil"
+         end
+         return "&" .. show(target, typ and typ.elem, depth)
+      end
+      if mt == __valueSliceMT or mt == __valueArrayMT then
+         local vtyp = rawget(v, "__constructor")
+         local name = vtyp.__str
+         if onPath[v] then
+            return "<cycle " .. name .. ">"
+         end
+         if maxDepth > 0 and depth >= maxDepth then
+            return name .. "{...}"
+         end
+         local array, off = rawget(v, "__array"), tonumber(rawget(v, "__offset"))
+         local n = tonumber(rawget(v, "__length"))
+         local items = {}
+         onPath[v] = true
+         for i = 0, width(n)-1 do
+            items[i+1] = show(array[off+i], vtyp.elem, depth+1)
+         end
+         onPath[v] = nil
+         return __gi_showComposite(name, items, n-width(n), depth, true)
+      end
+
+      if mt == __valueMapMT then
+         local vtyp = rawget(v, "__typ")
+         local name = vtyp.__str
+         if onPath[v] then
+            return "<cycle " .. name .. ">"
+         end
+         if maxDepth > 0 and depth >= maxDepth then
+            return name .. "{...}"
+         end
+         local kind = vtyp.key.kind
+         local numeric = kind >= __kindInt and kind <= __kindFloat64
+         local keys = {}
+         for k in pairs(rawget(v, "__val")) do
+            local shown = k
+            if kind == __kindString then
+               shown = __gi_quote(k)
+            elseif numeric then
+               shown = string.match(k, "^(%-?%d+)U?LL$") or k
+            end
+            table.insert(keys, {key = k, shown = shown, num = numeric and tonumber(shown)})
+         end
+         table.sort(keys, function(a, b)
+            if a.num and b.num then
+               return a.num < b.num
+            end
+            return a.shown < b.shown
+         end)
+         local val = rawget(v, "__val")
+         local n = #keys
+         local items = {}
+         onPath[v] = true
+         if rawget(v, "nilKeyStored") then
+            n = n + 1
+            table.insert(items, "nil: " .. show(rawget(v, "nilValue"), vtyp.elem, depth+1))
+         end
+         for i = 1, width(n) - #items do
+            local k = keys[i]
+            table.insert(items, k.shown .. ": " .. show(val[k.key], vtyp.elem, depth+1))
+         end
+         onPath[v] = nil
+         return __gi_showComposite(name, items, n-width(n), depth, false)
+      end
+
+      local target, styp, amp
+      local vname = rawget(v, "__name")
+      if vname == "__pointerToStructValue" then
+         target, styp = rawget(v, "__target"), rawget(v, "__typ").elem
+         if typ ~= nil then
+            amp = typ.kind ~= __kindStruct
+         else
+            amp = not deref
+         end
+      elseif vname == "__structValue" then
+         target, styp, amp = v, rawget(v, "__typ"), false
+      end
+      if target == nil or styp == nil or styp.fields == nil then
+         return tostring(v)
+      end
+      local name = styp.__str
+      if amp then
+         name = "&" .. name
+      end
+      if onPath[target] then
+         return "<cycle " .. name .. ">"
+      end
+      if maxDepth > 0 and depth >= maxDepth then
+         return name .. "{...}"
+      end
+      local fields = styp.fields
+      local items = {}
+      onPath[target] = true
+      for i = 1, width(#fields) do
+         local fld = fields[i]
+         items[i] = fld.__name .. ": " .. show(rawget(target, fld.__prop), fld.__typ, depth+1)
+      end
+      onPath[target] = nil
+      return __gi_showComposite(name, items, #fields-width(#fields), depth, false)
+   end
+
+   return show(x, nil, 0)
+end
+
+-- __gi_show prints x as __gi_showString formats it, or
+-- displays it if it is a gi/display Data or Displayer. The
+-- REPL echoes the value of an expression with it.
+function __gi_show(x, deref)
+   local d = __gi_displayOf(x)
+   if d ~= nil then
+      __gi_display(d)
+      return
+   end
+   print(__gi_showString(x, deref))
+end
+
+function __printHelper(v)
+   if type(v) == "table" and v.__name == "__lazy_ellipsis_instance" then
+      local expand = v()
+      for _,c in pairs(expand) do
+         __printHelper(c)
+      end
+      return
+   end
+   __gi_show(v)
 end
 
 function __gijit_printQuoted(...)
@@ -3109,6 +3597,57 @@ function __gijit_printQuoted(...)
    end
 end
 
+-- __gi_dump backs the REPL builtin dump(x): it shows
+-- x, and a struct, map or slice in full.
+function __gi_dump(x)
+   print(__gi_showString(x, false, 0, 0))
+end
+
+-- __gi_help backs the REPL builtin help(x): it shows the
+-- type x has at run time and the methods of that type.
+function __gi_help(x)
+   if x == nil or x == __ifaceNil then
+      print("nil")
+      return
+   end
+   local tx = type(x)
+   if tx == "function" then
+      print("func")
+      return
+   end
+   if tx == "boolean" then
+      print("bool")
+      return
+   end
+   if tx == "string" then
+      print("string")
+      return
+   end
+   local typ = tx == "table" and x.__typ
+   if not typ then
+      local k = __basicValue2kind(x)
+      if k == __kindUnknown then
+         print(tx)
+      else
+         print(string.lower(string.sub(__kind2str[k], 7)))
+      end
+      return
+   end
+   print(typ.__str)
+   local names = {}
+   for _, m in ipairs(__methodSet(typ)) do
+      local ms = m.__name
+      if m.__typ ~= nil and m.__typ.__str ~= nil then
+         ms = ms .. string.sub(m.__typ.__str, 5)
+      end
+      table.insert(names, ms)
+   end
+   table.sort(names)
+   for _, ms in ipairs(names) do
+      print("   " .. ms)
+   end
+end
+
 -- last thing, so we store all types/vars defined so far
 __storeBuiltins()
 
@@ -3237,27 +3776,53 @@ __throw = function(err)  error(err); end;
 __lastEvalErr = ""
 
 __errHandlerForEval = function(err)
-   __lastEvalErr = err
-   print("error! __errHandlerForEval sees err =", err)
-   print(debug.traceback(coroutine.running(), err))
+   __lastEvalErr = tostring(err)
+   -- kept for Go to map back to the inputs; see lastEvalError.
+   __lastEvalStack = __gi_stack(2, 64)
+   -- a panic is shown on the goroutine it unwound.
+   if type(err) == "table" and getmetatable(err) == __recovMT then
+      local notes = __gi_goroutineNotes()
+      __lastEvalPanicGoid = notes and tostring(notes.__goid) or "1"
+   end
    return err
 end
 
 -- The main eval procedure for the gijit REPL
 -- It only compiles and runs 'code', then exits.
 --
+-- noBytecode is set by __gi_denyBytecode, under a sandbox
+-- policy without FFI: loaded bytecode is unchecked, and can
+-- do whatever FFI can.
+local noBytecode = false
+
+function __gi_denyBytecode()
+   noBytecode = true
+end
+
 __gijitMainEval = function(code)
    --print("top of __gijitMainEval")
    __lastEvalErr = ""
+   __lastEvalStack = ""
+   __lastEvalPanicGoid = ""
    
    local chunk, err, ok
    --print("top of main loop: while true...")
    -- compile chunk to bytecode
-   chunk, err = loadstring(code);
+   local id = code:match("^%-%-gi#(%d+)\n")
+   if noBytecode and code:byte(1) == 27 then
+      err = "bytecode is denied by the sandbox policy"
+   elseif id then
+      -- a chunk with a source map; see srcmap.go.
+      -- The name tells chunks apart in stacks.
+      chunk, err = loadstring(code, "=gi#"..id)
+   else
+      chunk, err = loadstring(code);
+   end
    --print("back from loadstring of code '"..code.."'  we have err=",err," and chunk=", chunk)
    if err ~= nil then
       
       err = "load error: "..tostring(err)
+      __lastEvalErr = err
       --print("main loop had err= ",err)
       
    else
@@ -3301,7 +3866,8 @@ __eval = function(code)
 
    __gijitEvalCoro = coroutine.create(function() __gijitMainEval(code) end)
    table.insert(__all_coro, __gijitEvalCoro)
-   __coro2notes[__gijitEvalCoro]={__loc=#__all_coro, __name="co-eval-"..tostring(__eval_next_count)}
+   __gi_lastGoid = __gi_lastGoid + 1
+   __coro2notes[__gijitEvalCoro]={__loc=#__all_coro, __name="co-eval-"..tostring(__eval_next_count), __goid=__gi_lastGoid}
    __eval_next_count = __eval_next_count+1
 
    -- we need the scheduler to resume this goroutine,
@@ -3310,7 +3876,21 @@ __eval = function(code)
    -- the actual receive during chan receive <- ops.
 
    __task_ready(__gijitEvalCoro)
-   __task.resume_scheduler()
+   if __gi_backgroundGoroutines then
+      __gi_schedUntil = __gijitEvalCoro
+   end
+   local ok, err = pcall(__task.resume_scheduler)
+   __gi_schedUntil = nil
+   if not ok then
+      error(err, 0)
+   end
+
+   -- if the input blocked with nothing left to wake
+   -- it, tell the Interp which goroutine is stuck.
+   __gi_deadlockGoid = ""
+   if __gi_asleep(__gijitEvalCoro) then
+      __gi_deadlockGoid = tostring(__coro2notes[__gijitEvalCoro].__goid)
+   end
 
    __cleanupDeadCoro()   
    --print("end of __eval, returning")
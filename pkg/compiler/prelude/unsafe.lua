@@ -0,0 +1,137 @@
+-- unsafe.lua: conversions through unsafe.Pointer; see
+-- translateConversion in pkg/compiler/expressions.go.
+--
+-- An unsafe.Pointer is the pointer converted to it, and nil
+-- is 0. Converted on to another pointer type, it becomes a
+-- view of the same variable: a struct is seen through the
+-- fields of the new type, matched up in order, and a number
+-- through the bits of the new type, as LuaJIT's ffi lays
+-- both out in memory.
+
+local __unsafeBits = __ffi.new([[union {
+   int8_t i8; int16_t i16; int32_t i32; int64_t i64;
+   uint8_t u8; uint16_t u16; uint32_t u32; uint64_t u64;
+   float f32; double f64;
+}]])
+
+-- the member of __unsafeBits for each kind of number.
+local __unsafeMember = {
+   [__kindInt]="i64", [__kindInt8]="i8", [__kindInt16]="i16",
+   [__kindInt32]="i32", [__kindInt64]="i64",
+   [__kindUint]="u64", [__kindUint8]="u8", [__kindUint16]="u16",
+   [__kindUint32]="u32", [__kindUint64]="u64", [__kindUintptr]="u64",
+   [__kindFloat32]="f32", [__kindFloat64]="f64",
+}
+
+-- integers are cdata of their own ctype; floats are numbers.
+__gi_kindCtype = {
+   [__kindInt]=int, [__kindInt8]=int8, [__kindInt16]=int16,
+   [__kindInt32]=int32, [__kindInt64]=int64,
+   [__kindUint]=uint, [__kindUint8]=uint8, [__kindUint16]=uint16,
+   [__kindUint32]=uint32, [__kindUint64]=uint64, [__kindUintptr]=uint64,
+}
+
+-- __unsafeRetype reads the bits of v, a number of kind
+-- from, as a number of kind to.
+local function __unsafeRetype(v, from, to)
+   local u = __unsafeBits
+   u.u64 = 0
+   u[__unsafeMember[from]] = v
+   local r = u[__unsafeMember[to]]
+   local ct = __gi_kindCtype[to]
+   if ct == nil then
+      return tonumber(r)
+   end
+   return ct(r)
+end
+
+-- __unsafeStructView shows the struct value src, of type
+-- from, with the fields of type to.
+local function __unsafeStructView(src, from, to)
+   local field = {}
+   for i, f in ipairs(to.fields) do
+      local g = from.fields[i]
+      if g ~= nil then
+         field[f.__prop] = g.__prop
+      end
+   end
+   return setmetatable({}, {
+      __index = function(t, k)
+         local p = field[k]
+         if p ~= nil then
+            return src[p]
+         end
+         return to.prototype[k]
+      end,
+      __newindex = function(t, k, v)
+         local p = field[k]
+         if p ~= nil then
+            src[p] = v
+         else
+            rawset(t, k, v)
+         end
+      end,
+      __tostring = to.prototype.__tostring,
+   })
+end
+
+-- __gi_unsafePointerTo converts p, an unsafe.Pointer, to
+-- the pointer type typ.
+function __gi_unsafePointerTo(p, typ)
+   if p == 0 or p == nil then
+      return typ.__nil
+   end
+   if type(p) ~= "table" or p.__typ == nil or p.__typ.elem == nil then
+      return p
+   end
+   local from, to = p.__typ.elem, typ.elem
+   if from == to then
+      return p
+   end
+   if from.kind == __kindStruct and to.kind == __kindStruct then
+      return typ(__unsafeStructView(p.__get(), from, to))
+   end
+   if __unsafeMember[from.kind] and __unsafeMember[to.kind] then
+      local fk, tk = from.kind, to.kind
+      return typ(function() return __unsafeRetype(p.__get(), fk, tk); end,
+         function(v) p.__set(__unsafeRetype(v, tk, fk)); end,
+         p.__target)
+   end
+   return p
+end
+
+-- uintptrs stand in for addresses: each variable an
+-- unsafe.Pointer points to gets its own, for as long as
+-- it lives.
+local __unsafeAddrOf = setmetatable({}, {__mode="k"})
+local __unsafeAt = setmetatable({}, {__mode="v"})
+local __unsafeNextAddr = 0xc000010000
+
+-- __gi_unsafeAddr converts p, an unsafe.Pointer, to uintptr.
+function __gi_unsafeAddr(p)
+   if p == 0 or p == nil or type(p) ~= "table" then
+      return 0ULL
+   end
+   if p.__typ ~= nil and p == p.__typ.__nil then
+      return 0ULL
+   end
+   -- pointers to the same struct are one address.
+   local key = p
+   if type(p.__target) == "table" then
+      key = p.__target
+   end
+   local a = __unsafeAddrOf[key]
+   if a == nil then
+      a = __unsafeNextAddr
+      __unsafeNextAddr = __unsafeNextAddr + 0x100
+      __unsafeAddrOf[key] = a
+      __unsafeAt[a] = p
+   end
+   return uint64(a)
+end
+
+-- __gi_unsafeFromAddr converts a uintptr to unsafe.Pointer:
+-- back to the pointer __gi_unsafeAddr gave it for, or nil.
+function __gi_unsafeFromAddr(a)
+   return __unsafeAt[tonumber(a)] or 0
+end
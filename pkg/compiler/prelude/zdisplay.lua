@@ -0,0 +1,242 @@
+-- zdisplay.lua: the runtime for the gi/display package,
+-- rich outputs for notebook and web frontends; see
+-- pkg/compiler/display.go. It loads after tsys.lua, whose
+-- types it needs.
+--
+-- A Data holds a map from MIME type to content. Displaying
+-- one either prints its text/plain, in a terminal, or,
+-- under Interp.SetRichDisplay, keeps it for the frontend,
+-- which fetches it with Interp.Displayed.
+
+local display = __gi_package("gi/display")
+__type__["gi/display"] = __type__["gi/display"] or {}
+
+-- __gi_displayRich says whether displayed Data is kept in
+-- __gi_displayed, rather than its text printed.
+__gi_displayRich = false
+__gi_displayed = {}
+
+local mimeMap = __mapType(__type__.string, __type__.string)
+
+local Data = __newType(0, __kindStruct, "display.Data", true, "gi/display", true, nil)
+Data.init("", {
+   {__prop="MIME", __name="MIME", __anonymous=false, __exported=true, __typ=mimeMap, __tag=""},
+})
+Data.__constructor = function(m)
+   return {MIME = m or false}
+end
+__type__["gi/display"].Data = Data
+
+-- newData makes a Data of bundle, a Lua table from MIME
+-- type to content.
+local function newData(bundle)
+   return Data.ptrToNewlyConstructed(__makeMap(bundle, __type__.string, __type__.string, mimeMap))
+end
+
+-- bundleOf returns the MIME types and contents of the
+-- Data d as a Lua table; it is __gi_displayBundle to the
+-- other packages that make Data.
+local function bundleOf(d)
+   local target = rawget(d, "__target") or d
+   local bundle = {}
+   local m = rawget(target, "MIME")
+   if m then
+      for k, v in pairs(rawget(m, "__val")) do
+         if v ~= __intentionalNilValue then
+            bundle[k] = v
+         end
+      end
+   end
+   if bundle["text/plain"] == nil then
+      bundle["text/plain"] = ""
+   end
+   return bundle
+end
+__gi_displayBundle = bundleOf
+
+-- __gi_displayOf returns the Data x displays as: x itself,
+-- or what its Display method returns, if it is a Displayer;
+-- or else nil.
+function __gi_displayOf(x)
+   if type(x) ~= "table" then
+      return nil
+   end
+   local typ = rawget(x, "__typ")
+   if typ == Data or typ == Data.ptr then
+      return x
+   end
+   local name = rawget(x, "__name")
+   if name ~= "__pointerToStructValue" and name ~= "__structValue" then
+      return nil
+   end
+   for _, m in ipairs(__methodSet(typ)) do
+      local ft = m.__typ
+      if m.__name == "Display" and ft ~= nil and #ft.params == 0 and
+      #ft.results == 1 and ft.results[1] == Data then
+         return x:Display()
+      end
+   end
+   return nil
+end
+
+-- __gi_display displays the Data d.
+function __gi_display(d)
+   local bundle = bundleOf(d)
+   if __gi_displayRich then
+      table.insert(__gi_displayed, bundle)
+   else
+      print(bundle["text/plain"])
+   end
+end
+
+-- __gi_displayDrain returns, and clears, the Data kept
+-- since the last call, as parseDisplayed in display.go
+-- reads them.
+function __gi_displayDrain()
+   local out = {}
+   for _, bundle in ipairs(__gi_displayed) do
+      local types = {}
+      for t in pairs(bundle) do
+         table.insert(types, t)
+      end
+      table.sort(types)
+      table.insert(out, #types .. "\n")
+      for _, t in ipairs(types) do
+         table.insert(out, t .. "\n" .. #bundle[t] .. "\n" .. bundle[t])
+      end
+   end
+   __gi_displayed = {}
+   return table.concat(out)
+end
+
+local b64chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+-- base64 encodes s in standard base64, with padding.
+local function base64(s)
+   local out = {}
+   for i = 1, #s, 3 do
+      local a, b, c = string.byte(s, i, i+2)
+      local n = a * 65536 + (b or 0) * 256 + (c or 0)
+      local q = {}
+      for j = 1, 4 do
+         local k = __builtin_math.floor(n / 2^(6*(4-j))) % 64
+         q[j] = string.sub(b64chars, k+1, k+1)
+      end
+      if c == nil then
+         q[4] = "="
+      end
+      if b == nil then
+         q[3] = "="
+      end
+      table.insert(out, table.concat(q))
+   end
+   return table.concat(out)
+end
+
+local function escapeHTML(s)
+   return (string.gsub(s, '[&<>"]', {["&"]="&amp;", ["<"]="&lt;", [">"]="&gt;", ['"']="&quot;"}))
+end
+
+-- stringList returns the []string s as a Lua list.
+local function stringList(s)
+   local list = {}
+   for i = 0, s.__length - 1 do
+      list[i+1] = s.__array[s.__offset + i]
+   end
+   return list
+end
+
+Data.ptr.prototype.With = function(this, mimeType, content)
+   local bundle = bundleOf(this)
+   bundle[mimeType] = content
+   return newData(bundle)
+end
+Data.prototype.With = Data.ptr.prototype.With
+
+display.HTML = function(html)
+   return newData({["text/html"] = html, ["text/plain"] = html})
+end
+
+display.Markdown = function(md)
+   return newData({["text/markdown"] = md, ["text/plain"] = md})
+end
+
+display.JSON = function(json)
+   return newData({["application/json"] = json, ["text/plain"] = json})
+end
+
+display.SVG = function(svg)
+   return newData({["image/svg+xml"] = svg, ["text/plain"] = "<image/svg+xml, " .. #svg .. " bytes>"})
+end
+
+local function image(mimeType, b)
+   local s = __bytesToString(b)
+   return newData({[mimeType] = base64(s), ["text/plain"] = "<" .. mimeType .. ", " .. #s .. " bytes>"})
+end
+
+display.PNG = function(png)
+   return image("image/png", png)
+end
+
+display.JPEG = function(jpeg)
+   return image("image/jpeg", jpeg)
+end
+
+display.Table = function(header, rows)
+   local head = stringList(header)
+   local body = {}
+   for i = 0, rows.__length - 1 do
+      body[i+1] = stringList(rows.__array[rows.__offset + i])
+   end
+
+   local html = {"<table>\n<tr>"}
+   for _, h in ipairs(head) do
+      table.insert(html, "<th>" .. escapeHTML(h) .. "</th>")
+   end
+   table.insert(html, "</tr>\n")
+   for _, row in ipairs(body) do
+      table.insert(html, "<tr>")
+      for _, cell in ipairs(row) do
+         table.insert(html, "<td>" .. escapeHTML(cell) .. "</td>")
+      end
+      table.insert(html, "</tr>\n")
+   end
+   table.insert(html, "</table>")
+
+   -- the text aligns the columns, as text/tabwriter would
+   -- with a padding of two.
+   local widths = {}
+   local function measure(row)
+      for j, cell in ipairs(row) do
+         widths[j] = __builtin_math.max(widths[j] or 0, __utf8.len(cell) or #cell)
+      end
+   end
+   measure(head)
+   for _, row in ipairs(body) do
+      measure(row)
+   end
+   local lines = {}
+   local function line(row)
+      local cells = {}
+      for j, cell in ipairs(row) do
+         if j < #row then
+            cell = cell .. string.rep(" ", widths[j] - (__utf8.len(cell) or #cell) + 2)
+         end
+         cells[j] = cell
+      end
+      table.insert(lines, table.concat(cells))
+   end
+   line(head)
+   for _, row in ipairs(body) do
+      line(row)
+   end
+   return newData({["text/html"] = table.concat(html), ["text/plain"] = table.concat(lines, "\n")})
+end
+
+display.MIME = function(mimeType, content, text)
+   return newData({[mimeType] = content, ["text/plain"] = text})
+end
+
+display.Show = function(d)
+   __gi_display(d)
+end
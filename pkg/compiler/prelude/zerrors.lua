@@ -0,0 +1,302 @@
+-- zerrors.lua: the runtime for the errors package, and
+-- fmt.Errorf with %w; see pkg/compiler/errors.go. It loads
+-- after tsys.lua, whose types it needs.
+--
+-- An error from Go is a string, its message, which answers
+-- Error() by string.lua; whether two of them are the same
+-- error, and what one wraps, Go is asked.
+
+local errors = __gi_package("errors")
+__type__.errors = __type__.errors or {}
+
+local errorSlice = __sliceType(__error)
+
+local function errorMethod(name, results)
+   return {prop=name, __name=name, __pkg="", __typ=__funcType({}, results, false)}
+end
+
+-- errorType makes a struct type, used through pointers,
+-- with Error and, if unwrap is given, Unwrap.
+local function errorType(name, fields, errorFn, unwrap, unwrapResult)
+   local t = __newType(0, __kindStruct, "errors." .. name, true, "errors", false, nil)
+   local fs = {}
+   for i, f in ipairs(fields) do
+      fs[i] = {__prop=f[1], __name=f[1], __anonymous=false, __exported=false, __typ=f[2], __tag=""}
+   end
+   t.init("", fs)
+   t.__constructor = function(...)
+      local self = {}
+      for i, f in ipairs(fields) do
+         self[f[1]] = select(i, ...)
+      end
+      return self
+   end
+   -- the methods are on the pointer; as for compiled
+   -- structs, a struct value reaching Go has them too.
+   t.ptr.prototype.Error = errorFn
+   t.prototype.Error = function(this) return errorFn(this.__val) end
+   t.ptr.__addToMethods(errorMethod("Error", {__type__.string}))
+   if unwrap ~= nil then
+      t.ptr.prototype.Unwrap = unwrap
+      t.prototype.Unwrap = function(this) return unwrap(this.__val) end
+      t.ptr.__addToMethods(errorMethod("Unwrap", {unwrapResult}))
+   end
+   __type__.errors[name] = t
+   return t
+end
+
+local function msgOf(this)
+   return this.msg
+end
+
+local errorString = errorType("errorString", {{"msg", __type__.string}}, msgOf)
+
+local wrapError = errorType("wrapError", {{"msg", __type__.string}, {"err", __error}}, msgOf,
+   function(this) return this.err end, __error)
+
+local wrapErrors = errorType("wrapErrors", {{"msg", __type__.string}, {"errs", errorSlice}}, msgOf,
+   function(this) return this.errs end, errorSlice)
+
+local joinError = errorType("joinError", {{"errs", errorSlice}},
+   function(this)
+      local msgs = {}
+      local s = this.errs
+      for i = 0, s.__length - 1 do
+         msgs[#msgs+1] = s.__array[s.__offset + i]:Error()
+      end
+      return table.concat(msgs, "\n")
+   end,
+   function(this) return this.errs end, errorSlice)
+
+local function isSlice(v)
+   return type(v) == "table" and rawget(v, "__array") ~= nil and rawget(v, "__offset") ~= nil
+end
+
+-- unwrapOf returns what err's Unwrap gives: one error, or
+-- a Lua list of them for Unwrap() []error.
+local function unwrapOf(err)
+   if type(err) == "string" then
+      return __gi_hostErrorUnwrap(err)
+   end
+   if type(err) ~= "table" then
+      return nil
+   end
+   local u = err.Unwrap
+   if type(u) ~= "function" then
+      return nil
+   end
+   local r = u(err)
+   if isSlice(r) then
+      local list = {}
+      for i = 0, r.__length - 1 do
+         list[#list+1] = r.__array[r.__offset + i]
+      end
+      return nil, list
+   end
+   return r
+end
+
+errors.New = function(text)
+   return errorString.ptrToNewlyConstructed(text)
+end
+
+errors.Unwrap = function(err)
+   local u = unwrapOf(err)
+   return u
+end
+
+local function is(err, target)
+   while err ~= nil do
+      if type(err) == type(target) and err == target then
+         return true
+      end
+      if type(err) == "string" and type(target) == "string" and __gi_hostErrorIs(err, target) then
+         return true
+      end
+      if type(err) == "table" then
+         local m = err.Is
+         if type(m) == "function" and m(err, target) then
+            return true
+         end
+      end
+      local u, list = unwrapOf(err)
+      if list ~= nil then
+         for _, e in ipairs(list) do
+            if is(e, target) then
+               return true
+            end
+         end
+         return false
+      end
+      err = u
+   end
+   return false
+end
+
+errors.Is = function(err, target)
+   if err == nil or target == nil then
+      return err == target
+   end
+   return is(err, target)
+end
+
+-- assignable reports whether err can be stored in a
+-- variable of type typ.
+local function assignable(err, typ)
+   if type(err) == "string" then
+      -- Go's own errors have only their Error method here.
+      if typ.kind ~= __kindInterface then
+         return false
+      end
+      for _, m in ipairs(typ.methods) do
+         if m.__name ~= "Error" then
+            return false
+         end
+      end
+      return true
+   end
+   if type(err) ~= "table" then
+      return false
+   end
+   if typ.kind == __kindInterface then
+      local _, ok = __assertType(err, typ, true)
+      return ok
+   end
+   return err.__typ == typ
+end
+
+local function hasErrorMethod(typ)
+   for _, m in ipairs(__methodSet(typ)) do
+      if m.__name == "Error" then
+         return true
+      end
+   end
+   return false
+end
+
+local function as(err, target, typ)
+   while err ~= nil do
+      if assignable(err, typ) then
+         target.__set(err)
+         return true
+      end
+      if type(err) == "table" then
+         local m = err.As
+         if type(m) == "function" and m(err, target) then
+            return true
+         end
+      end
+      local u, list = unwrapOf(err)
+      if list ~= nil then
+         for _, e in ipairs(list) do
+            if as(e, target, typ) then
+               return true
+            end
+         end
+         return false
+      end
+      err = u
+   end
+   return false
+end
+
+errors.As = function(err, target)
+   local ptyp = type(target) == "table" and rawget(target, "__typ")
+   if not ptyp or ptyp.kind ~= __kindPtr or target == ptyp.__nil then
+      __panic("errors: target must be a non-nil pointer")
+   end
+   local typ = ptyp.elem
+   if typ.kind ~= __kindInterface and not hasErrorMethod(typ) then
+      __panic("errors: *target must be interface or implement error")
+   end
+   if err == nil then
+      return false
+   end
+   return as(err, target, typ)
+end
+
+errors.Join = function(...)
+   local a, n = __gi_spreadArgs(...)
+   local errs = {}
+   local k = 0
+   for i = 1, n do
+      if a[i] ~= nil then
+         errs[k] = a[i]
+         k = k + 1
+      end
+   end
+   if k == 0 then
+      return nil
+   end
+   return joinError.ptrToNewlyConstructed(errorSlice(errs))
+end
+
+-- __gi_errorParts gives Go an interpreted error: its
+-- message, and a list of the errors it wraps. It returns
+-- nothing for a table that is not an error.
+function __gi_errorParts(v)
+   if isSlice(v) or type(v.Error) ~= "function" or v.__typ == nil then
+      return nil
+   end
+   local u, list = unwrapOf(v)
+   if list == nil then
+      list = {u}
+   end
+   return v:Error(), list
+end
+
+------------------------------
+-- fmt.Errorf
+------------------------------
+
+-- the verbs that print an error by its Error method.
+local errorVerbs = {v=true, s=true, q=true, w=true}
+
+-- __gi_errorf is fmt.Errorf, which the compiler calls in
+-- its place: Go's fmt formats the message, with the errors
+-- given to %w, printed as %v, wrapped.
+function __gi_errorf(format, ...)
+   local a, n = __gi_spreadArgs(...)
+   local wrapped = {}
+   local argi = 0
+   local pos = 1
+   while true do
+      local s = string.find(format, "%", pos, true)
+      if s == nil then
+         break
+      end
+      if string.sub(format, s + 1, s + 1) == "%" then
+         pos = s + 2
+      else
+         -- flags, width and precision; a * takes an argument.
+         local spec = string.match(format, "^[%+%-# 0]*[%d%*]*%.?[%d%*]*", s + 1)
+         local _, stars = string.gsub(spec, "%*", "")
+         argi = argi + stars
+         local e = s + 1 + #spec
+         local verb = string.sub(format, e, e)
+         argi = argi + 1
+         local arg = a[argi]
+         if verb == "w" then
+            if arg ~= nil then
+               wrapped[#wrapped+1] = arg
+            end
+            format = string.sub(format, 1, e - 1) .. "v" .. string.sub(format, e + 1)
+         end
+         if errorVerbs[verb] and type(arg) == "table" and not isSlice(arg) and type(arg.Error) == "function" then
+            a[argi] = arg:Error()
+         end
+         pos = e + 1
+      end
+   end
+   local msg = fmt.Sprintf(format, unpack(a, 1, n))
+   if #wrapped == 0 then
+      return errorString.ptrToNewlyConstructed(msg)
+   elseif #wrapped == 1 then
+      return wrapError.ptrToNewlyConstructed(msg, wrapped[1])
+   end
+   local errs = {}
+   for i, w in ipairs(wrapped) do
+      errs[i - 1] = w
+   end
+   return wrapErrors.ptrToNewlyConstructed(msg, errorSlice(errs))
+end
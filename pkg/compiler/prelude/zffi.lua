@@ -0,0 +1,123 @@
+-- zffi.lua: the runtime for the gi/ffi package, a bridge
+-- to C through LuaJIT's ffi; see pkg/compiler/ffi.go. It
+-- loads after tsys.lua, whose types it needs.
+--
+-- A C function is bound to a Go func variable, whose type
+-- says how to convert: Go arguments go to C as ffi would
+-- take them, a struct as a table of its fields, and the C
+-- result comes back as the Go result type, a const char*
+-- as a string and a struct field by field.
+
+local __ffi = __ffi -- luaLockdown may remove the global
+
+local ffi = __gi_package("gi/ffi")
+__type__["gi/ffi"] = __type__["gi/ffi"] or {}
+
+local Lib = __newType(0, __kindStruct, "ffi.Lib", true, "gi/ffi", true, nil)
+Lib.init("", {})
+Lib.__constructor = function(clib)
+   return {__clib = clib}
+end
+__type__["gi/ffi"].Lib = Lib
+
+local function newLib(clib)
+   return Lib.ptr(Lib(clib))
+end
+
+-- toC converts a Go value to one ffi takes.
+local function toC(v)
+   if type(v) ~= "table" then
+      return v
+   end
+   if v == __ifaceNil then
+      return nil
+   end
+   local typ = v.__typ
+   if typ ~= nil and typ.kind == __kindPtr and typ.elem.kind == __kindStruct then
+      local t = {}
+      for _, f in ipairs(typ.elem.fields) do
+         t[f.__name] = toC(v[f.__prop])
+      end
+      return t
+   end
+   return v
+end
+
+-- toGo converts r, a result from C, to the Go type typ.
+local function toGo(r, typ)
+   local k = typ.kind
+   if k == __kindString then
+      if r == nil then
+         return ""
+      end
+      return __ffi.string(r)
+   elseif k == __kindBool then
+      return r == true or (type(r) ~= "boolean" and r ~= nil and r ~= 0)
+   elseif k == __kindFloat32 or k == __kindFloat64 then
+      return tonumber(r)
+   elseif k == __kindStruct then
+      local vals = {}
+      for i, f in ipairs(typ.fields) do
+         vals[i] = toGo(r[f.__name], f.__typ)
+      end
+      return typ.ptrToNewlyConstructed(unpack(vals, 1, #typ.fields))
+   end
+   local ct = __gi_kindCtype[k]
+   if ct ~= nil then
+      return ct(r)
+   end
+   return r
+end
+
+-- Bind sets fn, a pointer to a func variable, to call the
+-- C function name of the library, declared with Cdef.
+Lib.ptr.prototype.Bind = function(this, fn, name)
+   local ftyp = type(fn) == "table" and fn.__typ
+   if not ftyp or ftyp.kind ~= __kindPtr or ftyp.elem.kind ~= __kindFunc then
+      error("ffi: Bind needs a pointer to a func variable, not " .. tostring(fn), 2)
+   end
+   local ok, cf = pcall(function() return this.__clib[name] end)
+   if not ok then
+      local msg = string.gsub(tostring(cf), "^.-:%d+: ", "")
+      error("ffi: cannot bind " .. name .. ": " .. msg, 2)
+   end
+   local sig = ftyp.elem
+   local nparams = #sig.params
+   local res = sig.results[1]
+   fn.__set(function(...)
+      local args = {...}
+      local cargs = {}
+      local m = 0
+      for i = 1, select("#", ...) do
+         if sig.variadic and i == nparams then
+            -- a C vararg function: spread the slice.
+            local s = args[i]
+            for j = 0, s.__length - 1 do
+               m = m + 1
+               cargs[m] = toC(s.__array[s.__offset + j])
+            end
+         else
+            m = m + 1
+            cargs[m] = toC(args[i])
+         end
+      end
+      local r = cf(unpack(cargs, 1, m))
+      if res ~= nil then
+         return toGo(r, res)
+      end
+   end)
+end
+
+ffi.C = newLib(__ffi.C)
+
+ffi.Cdef = function(decls)
+   __ffi.cdef(decls)
+end
+
+ffi.Load = function(name)
+   return newLib(__ffi.load(name))
+end
+
+ffi.Sizeof = function(ctype)
+   return int(__ffi.sizeof(ctype))
+end
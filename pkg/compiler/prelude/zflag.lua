@@ -0,0 +1,558 @@
+-- zflag.lua: the runtime for the flag package; see
+-- pkg/compiler/flag.go. It loads after tsys.lua, whose
+-- types it needs, and zerrors.lua.
+--
+-- It follows Go's flag package: the same syntax, -x,
+-- --x, -x=v and -x v, but not -x v for booleans; the
+-- same messages; and the same PrintDefaults layout.
+-- Parsing the values is Go's strconv, through the
+-- __gi_flagParse* functions the import registers.
+
+local flag = __gi_package("flag")
+local errors = __gi_package("errors")
+__type__.flag = __type__.flag or {}
+
+local stringSlice = __sliceType(__type__.string)
+
+flag.ContinueOnError = 0LL
+flag.ExitOnError = 1LL
+flag.PanicOnError = 2LL
+
+flag.ErrHelp = errors.New("flag: help requested")
+
+local function method(name, params, results)
+   return {__prop=name, __name=name, __pkg="", __typ=__funcType(params, results, false)}
+end
+
+local function errorText(err)
+   if type(err) == "string" then
+      return err
+   end
+   return err:Error()
+end
+
+------------------------------
+-- the values
+------------------------------
+
+-- valueType makes the flag.Value kept by the flags of
+-- one kind: a pointer to where the flag's value lives,
+-- and the conversions to and from its text. typeName is
+-- what PrintDefaults calls the kind.
+local function valueType(name, typeName, parse, format, isBool)
+   local t = __newType(0, __kindStruct, "flag." .. name, true, "flag", false, nil)
+   t.init("", {})
+   t.__constructor = function(p)
+      return {p = p}
+   end
+   t.ptr.prototype.String = function(this)
+      return format(this.p.__get())
+   end
+   t.ptr.prototype.Set = function(this, s)
+      local v, err = parse(s)
+      if err ~= nil then
+         return err
+      end
+      this.p.__set(v)
+      return nil
+   end
+   t.ptr.__addToMethods(method("String", {}, {__type__.string}))
+   t.ptr.__addToMethods(method("Set", {__type__.string}, {__error}))
+   if isBool then
+      t.ptr.prototype.IsBoolFlag = function(this) return true end
+      t.ptr.__addToMethods(method("IsBoolFlag", {}, {__type__.bool}))
+   end
+   t.__flagTypeName = typeName
+   __type__.flag[name] = t
+   return t
+end
+
+local function formatGo(v)
+   return fmt.Sprint(v)
+end
+
+local boolValue = valueType("boolValue", "", function(s)
+   return __gi_flagParseBool(s)
+end, tostring, true)
+
+local intValue = valueType("intValue", "int", function(s)
+   return __gi_flagParseInt(s, 64)
+end, formatGo)
+
+local int64Value = valueType("int64Value", "int", function(s)
+   return __gi_flagParseInt(s, 64)
+end, formatGo)
+
+local uintValue = valueType("uintValue", "uint", function(s)
+   return __gi_flagParseUint(s, 64)
+end, formatGo)
+
+local uint64Value = valueType("uint64Value", "uint", function(s)
+   return __gi_flagParseUint(s, 64)
+end, formatGo)
+
+local float64Value = valueType("float64Value", "float", function(s)
+   return __gi_flagParseFloat(s)
+end, formatGo)
+
+local stringValue = valueType("stringValue", "string", function(s)
+   return s, nil
+end, function(v) return v end)
+
+-- funcValue is the flag.Value of Func and BoolFunc.
+local function funcType(name, isBool)
+   local t = __newType(0, __kindStruct, "flag." .. name, true, "flag", false, nil)
+   t.init("", {})
+   t.__constructor = function(fn)
+      return {fn = fn}
+   end
+   t.ptr.prototype.String = function(this) return "" end
+   t.ptr.prototype.Set = function(this, s) return this.fn(s) end
+   t.ptr.__addToMethods(method("String", {}, {__type__.string}))
+   t.ptr.__addToMethods(method("Set", {__type__.string}, {__error}))
+   if isBool then
+      t.ptr.prototype.IsBoolFlag = function(this) return true end
+      t.ptr.__addToMethods(method("IsBoolFlag", {}, {__type__.bool}))
+   end
+   t.__flagTypeName = isBool and "" or "value"
+   __type__.flag[name] = t
+   return t
+end
+
+local funcValue = funcType("funcValue", false)
+local boolFuncValue = funcType("boolFuncValue", true)
+
+local function isBoolFlag(v)
+   return type(v) == "table" and type(v.IsBoolFlag) == "function" and v:IsBoolFlag()
+end
+
+------------------------------
+-- Flag and FlagSet
+------------------------------
+
+local Value = __newType(8, __kindInterface, "flag.Value", true, "flag", true, nil)
+Value.init({
+   method("Set", {__type__.string}, {__error}),
+   method("String", {}, {__type__.string}),
+})
+__type__.flag.Value = Value
+
+local Flag = __newType(0, __kindStruct, "flag.Flag", true, "flag", true, nil)
+Flag.init("", {
+   {__prop="Name", __name="Name", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+   {__prop="Usage", __name="Usage", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+   {__prop="Value", __name="Value", __anonymous=false, __exported=true, __typ=Value, __tag=""},
+   {__prop="DefValue", __name="DefValue", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+})
+Flag.__constructor = function(name, usage, value, defValue)
+   return {Name = name or "", Usage = usage or "", Value = value, DefValue = defValue or ""}
+end
+__type__.flag.Flag = Flag
+
+local FlagSet = __newType(0, __kindStruct, "flag.FlagSet", true, "flag", true, nil)
+FlagSet.init("", {
+   {__prop="Usage", __name="Usage", __anonymous=false, __exported=true, __typ=__funcType({}, {}, false), __tag=""},
+})
+FlagSet.__constructor = function(name, errorHandling)
+   return {
+      Usage = nil,
+      __name = name or "",
+      __errorHandling = errorHandling or flag.ContinueOnError,
+      __formal = {},
+      __actual = {},
+      __nactual = 0,
+      __args = stringSlice({}),
+      __parsed = false,
+   }
+end
+__type__.flag.FlagSet = FlagSet
+
+local proto = FlagSet.ptr.prototype
+
+flag.NewFlagSet = function(name, errorHandling)
+   return FlagSet.ptrToNewlyConstructed(name, errorHandling)
+end
+
+proto.Init = function(f, name, errorHandling)
+   f.__name = name
+   f.__errorHandling = errorHandling
+end
+
+proto.Name = function(f)
+   return f.__name
+end
+
+proto.ErrorHandling = function(f)
+   return f.__errorHandling
+end
+
+proto.Var = function(f, value, name, usage)
+   if string.sub(name, 1, 1) == "-" then
+      __panic("flag " .. string.format("%q", name) .. " begins with -")
+   elseif string.find(name, "=", 1, true) then
+      __panic("flag " .. string.format("%q", name) .. " contains =")
+   end
+   if f.__formal[name] ~= nil then
+      local msg
+      if f.__name == "" then
+         msg = "flag redefined: " .. name
+      else
+         msg = f.__name .. " flag redefined: " .. name
+      end
+      __gi_flagOutput(msg .. "\n")
+      __panic(msg)
+   end
+   f.__formal[name] = Flag.ptrToNewlyConstructed(name, usage, value, value:String())
+end
+
+-- define makes the flag name, of the kind given by vt,
+-- keeping its value at p, which starts as value.
+local function define(f, vt, ptyp, p, name, value, usage)
+   if p == nil then
+      p = __newDataPointer(value, ptyp)
+   else
+      p.__set(value)
+   end
+   f:Var(vt.ptrToNewlyConstructed(p), name, usage)
+   return p
+end
+
+local kinds = {
+   {"Bool", boolValue, __type__.bool},
+   {"Int", intValue, __type__.int},
+   {"Int64", int64Value, __type__.int64},
+   {"Uint", uintValue, __type__.uint},
+   {"Uint64", uint64Value, __type__.uint64},
+   {"Float64", float64Value, __type__.float64},
+   {"String", stringValue, __type__.string},
+}
+
+for _, k in ipairs(kinds) do
+   local name, vt, ptyp = k[1], k[2], __ptrType(k[3])
+   proto[name] = function(f, flagName, value, usage)
+      return define(f, vt, ptyp, nil, flagName, value, usage)
+   end
+   proto[name .. "Var"] = function(f, p, flagName, value, usage)
+      define(f, vt, ptyp, p, flagName, value, usage)
+   end
+end
+
+proto.Func = function(f, name, usage, fn)
+   f:Var(funcValue.ptrToNewlyConstructed(fn), name, usage)
+end
+
+proto.BoolFunc = function(f, name, usage, fn)
+   f:Var(boolFuncValue.ptrToNewlyConstructed(fn), name, usage)
+end
+
+proto.Lookup = function(f, name)
+   return f.__formal[name]
+end
+
+proto.Set = function(f, name, value)
+   local fl = f.__formal[name]
+   if fl == nil then
+      return errors.New("no such flag -" .. name)
+   end
+   local err = fl.Value:Set(value)
+   if err ~= nil then
+      return err
+   end
+   if f.__actual[name] == nil then
+      f.__actual[name] = fl
+      f.__nactual = f.__nactual + 1
+   end
+   return nil
+end
+
+local function sortedFlags(m)
+   local list = {}
+   for _, fl in pairs(m) do
+      list[#list+1] = fl
+   end
+   table.sort(list, function(a, b) return a.Name < b.Name end)
+   return list
+end
+
+proto.VisitAll = function(f, fn)
+   for _, fl in ipairs(sortedFlags(f.__formal)) do
+      fn(fl)
+   end
+end
+
+proto.Visit = function(f, fn)
+   for _, fl in ipairs(sortedFlags(f.__actual)) do
+      fn(fl)
+   end
+end
+
+proto.NFlag = function(f)
+   return int64(f.__nactual)
+end
+
+proto.Parsed = function(f)
+   return f.__parsed
+end
+
+proto.Args = function(f)
+   return f.__args
+end
+
+proto.NArg = function(f)
+   return int64(f.__args.__length)
+end
+
+proto.Arg = function(f, i)
+   i = tonumber(i)
+   local a = f.__args
+   if i < 0 or i >= a.__length then
+      return ""
+   end
+   return a.__array[a.__offset + i]
+end
+
+-- unquoteUsage gives the name of a flag's value, from
+-- the first `quoted` word of its usage, or else its kind,
+-- and the usage with the quotes taken out.
+local function unquoteUsage(fl)
+   local usage = fl.Usage
+   local a, b, name = string.find(usage, "`([^`]*)`")
+   if a ~= nil then
+      return name, string.sub(usage, 1, a - 1) .. name .. string.sub(usage, b + 1)
+   end
+   local vt = fl.Value.__typ and fl.Value.__typ.elem
+   if vt ~= nil and vt.__flagTypeName ~= nil then
+      return vt.__flagTypeName, usage
+   end
+   if isBoolFlag(fl.Value) then
+      return "", usage
+   end
+   return "value", usage
+end
+
+-- isZeroValue reports whether def is the text of the
+-- zero value of fl's kind, which PrintDefaults leaves out.
+local function isZeroValue(fl, def)
+   local vt = fl.Value.__typ and fl.Value.__typ.elem
+   if vt == nil or vt.__flagTypeName == nil then
+      return def == ""
+   end
+   if vt == boolValue then
+      return def == "false"
+   elseif vt == stringValue then
+      return def == ""
+   elseif vt == funcValue or vt == boolFuncValue then
+      return true
+   end
+   return def == "0"
+end
+
+proto.PrintDefaults = function(f)
+   local out = {}
+   f:VisitAll(function(fl)
+      local b = "  -" .. fl.Name
+      local name, usage = unquoteUsage(fl)
+      if #name > 0 then
+         b = b .. " " .. name
+      end
+      if #b <= 4 then
+         b = b .. "\t"
+      else
+         b = b .. "\n    \t"
+      end
+      b = b .. string.gsub(usage, "\n", "\n    \t")
+      if not isZeroValue(fl, fl.DefValue) then
+         if fl.Value.__typ ~= nil and fl.Value.__typ.elem == stringValue then
+            b = b .. " (default " .. fmt.Sprintf("%q", fl.DefValue) .. ")"
+         else
+            b = b .. " (default " .. fl.DefValue .. ")"
+         end
+      end
+      out[#out+1] = b .. "\n"
+   end)
+   __gi_flagOutput(table.concat(out))
+end
+
+local function usage(f)
+   if f.Usage ~= nil then
+      f.Usage()
+      return
+   end
+   if f.__name == "" then
+      __gi_flagOutput("Usage:\n")
+   else
+      __gi_flagOutput("Usage of " .. f.__name .. ":\n")
+   end
+   f:PrintDefaults()
+end
+
+local function failf(f, msg)
+   local err = errors.New(msg)
+   __gi_flagOutput(msg .. "\n")
+   usage(f)
+   return err
+end
+
+local function nextArg(f)
+   local a = f.__args
+   local s = a.__array[a.__offset]
+   f.__args = __subslice(a, 1)
+   return s
+end
+
+-- parseOne parses one flag, and reports whether there
+-- was one.
+local function parseOne(f)
+   if f.__args.__length == 0 then
+      return false
+   end
+   local s = f.__args.__array[f.__args.__offset]
+   if #s < 2 or string.sub(s, 1, 1) ~= "-" then
+      return false
+   end
+   local minuses = 1
+   if string.sub(s, 2, 2) == "-" then
+      minuses = 2
+      if #s == 2 then
+         nextArg(f)
+         return false
+      end
+   end
+   local name = string.sub(s, minuses + 1)
+   local c = string.sub(name, 1, 1)
+   if #name == 0 or c == "-" or c == "=" then
+      return false, failf(f, "bad flag syntax: " .. s)
+   end
+   nextArg(f)
+
+   local hasValue = false
+   local value = ""
+   local eq = string.find(name, "=", 2, true)
+   if eq ~= nil then
+      value = string.sub(name, eq + 1)
+      hasValue = true
+      name = string.sub(name, 1, eq - 1)
+   end
+
+   local fl = f.__formal[name]
+   if fl == nil then
+      if name == "help" or name == "h" then
+         usage(f)
+         return false, flag.ErrHelp
+      end
+      return false, failf(f, "flag provided but not defined: -" .. name)
+   end
+
+   if isBoolFlag(fl.Value) then
+      if hasValue then
+         local err = fl.Value:Set(value)
+         if err ~= nil then
+            return false, failf(f, "invalid boolean value " .. fmt.Sprintf("%q", value) ..
+                                   " for -" .. name .. ": " .. errorText(err))
+         end
+      else
+         local err = fl.Value:Set("true")
+         if err ~= nil then
+            return false, failf(f, "invalid boolean flag " .. name .. ": " .. errorText(err))
+         end
+      end
+   else
+      if not hasValue and f.__args.__length > 0 then
+         hasValue = true
+         value = nextArg(f)
+      end
+      if not hasValue then
+         return false, failf(f, "flag needs an argument: -" .. name)
+      end
+      local err = fl.Value:Set(value)
+      if err ~= nil then
+         return false, failf(f, "invalid value " .. fmt.Sprintf("%q", value) ..
+                                " for flag -" .. name .. ": " .. errorText(err))
+      end
+   end
+   if f.__actual[name] == nil then
+      f.__actual[name] = fl
+      f.__nactual = f.__nactual + 1
+   end
+   return true
+end
+
+proto.Parse = function(f, arguments)
+   f.__parsed = true
+   f.__args = arguments
+   while true do
+      local seen, err = parseOne(f)
+      if seen then
+         -- next flag
+      elseif err == nil then
+         break
+      else
+         local h = f.__errorHandling
+         if h == flag.ExitOnError then
+            if err == flag.ErrHelp then
+               __gi_flagExit(0)
+            end
+            __gi_flagExit(2)
+         elseif h == flag.PanicOnError then
+            __panic(err)
+         end
+         return err
+      end
+   end
+   return nil
+end
+
+------------------------------
+-- the command line
+------------------------------
+
+-- commandLineArgs gives the arguments of the script's
+-- command line, after its name.
+local function commandLineArgs()
+   local p = __gi_flagArgs()
+   local a = {}
+   for i = 1, #p - 1 do
+      a[i - 1] = p[i]
+   end
+   return stringSlice(a)
+end
+
+local function commandLineName()
+   local p = __gi_flagArgs()
+   if #p == 0 then
+      return ""
+   end
+   return p[0]
+end
+
+flag.CommandLine = flag.NewFlagSet("", flag.ExitOnError)
+flag.CommandLine.__name = nil
+
+flag.Usage = function()
+   __gi_flagOutput("Usage of " .. commandLineName() .. ":\n")
+   flag.PrintDefaults()
+end
+
+-- the command line's usage is flag.Usage, which the
+-- script may replace.
+flag.CommandLine.Usage = function()
+   flag.Usage()
+end
+
+flag.Parse = function()
+   local f = flag.CommandLine
+   if f.__name == nil then
+      f.__name = commandLineName()
+   end
+   f:Parse(commandLineArgs())
+end
+
+-- the rest of the package functions are CommandLine's
+-- methods.
+for name, m in pairs(proto) do
+   if flag[name] == nil and name ~= "Init" and name ~= "Name" and name ~= "ErrorHandling" then
+      flag[name] = function(...)
+         return m(flag.CommandLine, ...)
+      end
+   end
+end
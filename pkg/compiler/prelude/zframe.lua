@@ -0,0 +1,165 @@
+-- zframe.lua: the runtime for the gi/frame package, tables
+-- of data read from CSV and JSON; see pkg/compiler/frame.go,
+-- which parses them. It loads after zdisplay.lua, whose Data
+-- a Frame displays as.
+--
+-- A Frame passes to and from Go JSON encoded, by the
+-- encoding/json of zjson.lua, so Into is json.Unmarshal.
+
+local frame = __gi_package("gi/frame")
+local errors = __gi_package("errors")
+local json = __gi_package("encoding/json")
+local display = __gi_package("gi/display")
+__type__["gi/frame"] = __type__["gi/frame"] or {}
+
+local strings = __sliceType(__type__.string)
+local rowsType = __sliceType(strings)
+local byteSlice = __sliceType(__type__.uint8)
+
+local Frame = __newType(0, __kindStruct, "frame.Frame", true, "gi/frame", true, nil)
+Frame.init("", {
+   {__prop="Columns", __name="Columns", __anonymous=false, __exported=true, __typ=strings, __tag=""},
+   {__prop="Types", __name="Types", __anonymous=false, __exported=true, __typ=strings, __tag=""},
+   {__prop="Rows", __name="Rows", __anonymous=false, __exported=true, __typ=rowsType, __tag=""},
+})
+Frame.__constructor = function(columns, types, rows)
+   return {Columns = columns or strings.__nil, Types = types or strings.__nil, Rows = rows or rowsType.__nil}
+end
+__type__["gi/frame"].Frame = Frame
+
+-- decode makes a Frame of the JSON s, from Go.
+local function decode(s)
+   local f = Frame.ptrToNewlyConstructed()
+   local err = json.Unmarshal(byteSlice(__stringToBytes(s)), f)
+   if err ~= nil then
+      error(err:Error(), 0)
+   end
+   return f
+end
+
+-- encode gives the JSON of the Frame f, for Go.
+local function encode(f)
+   local b, err = json.Marshal(f)
+   if err ~= nil then
+      error(err:Error(), 0)
+   end
+   return __bytesToString(b)
+end
+
+local function read(path, format)
+   local s, errmsg = __gi_frameRead(path, format)
+   if errmsg ~= "" then
+      return Frame.ptr.__nil, errors.New(errmsg)
+   end
+   return decode(s), nil
+end
+
+frame.ReadCSV = function(path)
+   return read(path, "csv")
+end
+
+frame.ReadJSON = function(path)
+   return read(path, "json")
+end
+
+frame.Of = function(rows)
+   local b, err = json.Marshal(rows)
+   if err ~= nil then
+      error("frame: " .. err:Error(), 0)
+   end
+   local s, errmsg = __gi_frameParse(__bytesToString(b), "json")
+   if errmsg ~= "" then
+      error(errmsg, 0)
+   end
+   return decode(s)
+end
+
+-- columnIndex returns the index of the column named name.
+local function columnIndex(f, name)
+   local cols = f.Columns
+   for i = 0, cols.__length - 1 do
+      if cols.__array[cols.__offset + i] == name then
+         return i
+      end
+   end
+   error("frame: no column " .. name, 0)
+end
+
+local function row(f, i)
+   local rows = f.Rows
+   return rows.__array[rows.__offset + i]
+end
+
+Frame.ptr.prototype.Len = function(this)
+   return int(this.Rows.__length)
+end
+
+Frame.ptr.prototype.Head = function(this, n)
+   n = __builtin_math.max(0, __builtin_math.min(tonumber(n), this.Rows.__length))
+   return Frame.ptrToNewlyConstructed(this.Columns, this.Types, __subslice(this.Rows, 0, n))
+end
+
+Frame.ptr.prototype.Column = function(this, name)
+   local j = columnIndex(this, name)
+   local a = {}
+   for i = 0, this.Rows.__length - 1 do
+      local r = row(this, i)
+      a[i] = r.__array[r.__offset + j]
+   end
+   return strings(a)
+end
+
+Frame.ptr.prototype.Floats = function(this, name)
+   local j = columnIndex(this, name)
+   local a = {}
+   for i = 0, this.Rows.__length - 1 do
+      local r = row(this, i)
+      a[i] = tonumber(r.__array[r.__offset + j]) or 0/0
+   end
+   return __sliceType(__type__.float64)(a)
+end
+
+Frame.ptr.prototype.Schema = function(this, name)
+   local s, errmsg = __gi_frameSchema(encode(this), name)
+   if errmsg ~= "" then
+      error(errmsg, 0)
+   end
+   return s
+end
+
+Frame.ptr.prototype.Into = function(this, rows)
+   local s, errmsg = __gi_frameRecords(encode(this))
+   if errmsg ~= "" then
+      return errors.New(errmsg)
+   end
+   return json.Unmarshal(byteSlice(__stringToBytes(s)), rows)
+end
+
+-- Display shows a table of the first __gi_showMaxWidth rows,
+-- and counts the rest.
+Frame.ptr.prototype.Display = function(this)
+   local n = this.Rows.__length
+   local shown = n
+   if __gi_showMaxWidth > 0 and n > __gi_showMaxWidth then
+      shown = __gi_showMaxWidth
+   end
+   local d = display.Table(this.Columns, __subslice(this.Rows, 0, shown))
+   if shown == n then
+      return d
+   end
+   local bundle = __gi_displayBundle(d)
+   local more = "... (" .. (n - shown) .. " more rows)"
+   return d:With("text/html", bundle["text/html"] .. "\n<p>" .. more .. "</p>"):
+      With("text/plain", bundle["text/plain"] .. "\n" .. more)
+end
+
+local function method(name, params, results)
+   return {__prop=name, __name=name, __pkg="", __typ=__funcType(params, results, false)}
+end
+Frame.ptr.__addToMethods(method("Len", {}, {__type__.int}))
+Frame.ptr.__addToMethods(method("Head", {__type__.int}, {Frame.ptr}))
+Frame.ptr.__addToMethods(method("Column", {__type__.string}, {strings}))
+Frame.ptr.__addToMethods(method("Floats", {__type__.string}, {__sliceType(__type__.float64)}))
+Frame.ptr.__addToMethods(method("Schema", {__type__.string}, {__type__.string}))
+Frame.ptr.__addToMethods(method("Into", {__type__.emptyInterface}, {__error}))
+Frame.ptr.__addToMethods(method("Display", {}, {__type__["gi/display"].Data}))
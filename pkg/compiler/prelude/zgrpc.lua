@@ -0,0 +1,523 @@
+-- zgrpc.lua: the runtime for the gi/grpc package, a gRPC
+-- client; see pkg/compiler/grpc.go. It loads after
+-- tsys.lua, whose types it needs.
+--
+-- Messages are Go structs encoded by the protobuf tags on
+-- their fields, as .proto generated code has them:
+--
+--    Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+--
+-- gives the encoding, the field number, and whether it is
+-- repeated or packed; a map field has protobuf_key and
+-- protobuf_val tags for its entries. Fields without a tag,
+-- such as XXX_unrecognized, are skipped.
+
+local grpc = __gi_package("gi/grpc")
+__type__["gi/grpc"] = __type__["gi/grpc"] or {}
+
+local bit = require("bit")
+local ffi = __ffi
+
+local __pbBits = ffi.new([[union {
+   uint8_t b[8]; uint32_t u32; uint64_t u64; float f32; double f64;
+}]])
+
+local __pbWire = {
+   varint = 0, zigzag32 = 0, zigzag64 = 0,
+   fixed64 = 1, bytes = 2, fixed32 = 5,
+}
+
+-- __pbParseTag parses one protobuf tag into the encoding,
+-- the field number, and whether the field is repeated and
+-- packed.
+local function __pbParseTag(tag)
+   local spec = {}
+   local i = 0
+   for part in string.gmatch(tag, "[^,]+") do
+      i = i + 1
+      if i == 1 then
+         spec.enc = part
+      elseif i == 2 then
+         spec.num = tonumber(part)
+      elseif part == "rep" then
+         spec.rep = true
+      elseif part == "packed" then
+         spec.packed = true
+      end
+   end
+   if spec.enc == "group" then
+      error("grpc: groups are not supported", 0)
+   end
+   if __pbWire[spec.enc] == nil or spec.num == nil then
+      error("grpc: bad protobuf tag '" .. tag .. "'", 0)
+   end
+   return spec
+end
+
+-- __pbFields gives the tagged fields of the struct type typ,
+-- by number and in order; it is kept on the type.
+local function __pbFields(typ)
+   local pf = rawget(typ, "__pbFields")
+   if pf ~= nil then
+      return pf
+   end
+   pf = {byNum = {}, list = {}}
+   for _, f in ipairs(typ.fields) do
+      local tag = string.match(f.__tag or "", 'protobuf:"([^"]*)"')
+      if tag ~= nil then
+         local spec = __pbParseTag(tag)
+         spec.prop = f.__prop
+         spec.typ = f.__typ
+         if f.__typ.kind == __kindMap then
+            spec.key = __pbParseTag(string.match(f.__tag, 'protobuf_key:"([^"]*)"') or "bytes,1,opt")
+            spec.val = __pbParseTag(string.match(f.__tag, 'protobuf_val:"([^"]*)"') or "bytes,2,opt")
+            spec.key.typ = f.__typ.key
+            spec.val.typ = f.__typ.elem
+         end
+         pf.byNum[spec.num] = spec
+         table.insert(pf.list, spec)
+      elseif string.match(f.__tag or "", "protobuf_oneof:") then
+         error("grpc: oneof field " .. f.__name .. " is not supported", 0)
+      end
+   end
+   rawset(typ, "__pbFields", pf)
+   return pf
+end
+
+local function __pbIsMessage(typ)
+   return typ.kind == __kindPtr and typ.elem.kind == __kindStruct
+end
+
+local function __pbIsBytes(typ)
+   return typ.kind == __kindSlice and typ.elem.kind == __kindUint8
+end
+
+------------------------------
+-- encoding
+------------------------------
+
+local function __pbPutVarint(out, u)
+   u = ffi.cast("uint64_t", u)
+   while u >= 0x80 do
+      out[#out+1] = string.char(tonumber(bit.bor(bit.band(u, 0x7f), 0x80)))
+      u = bit.rshift(u, 7)
+   end
+   out[#out+1] = string.char(tonumber(u))
+end
+
+local function __pbPutKey(out, num, wire)
+   __pbPutVarint(out, num * 8 + wire)
+end
+
+local function __pbPutBytes(out, s)
+   __pbPutVarint(out, #s)
+   out[#out+1] = s
+end
+
+local function __pbIsFloat(kind)
+   return kind == __kindFloat32 or kind == __kindFloat64
+end
+
+-- __pbPutScalar writes x, a Go number or bool of the type
+-- typ, in the encoding enc, without its key.
+local function __pbPutScalar(out, enc, x, typ)
+   local kind = typ.kind
+   if kind == __kindBool then
+      x = x and 1 or 0
+   end
+   if enc == "varint" then
+      __pbPutVarint(out, ffi.cast("int64_t", x))
+   elseif enc == "zigzag32" or enc == "zigzag64" then
+      local n = ffi.cast("int64_t", x)
+      __pbPutVarint(out, bit.bxor(bit.lshift(n, 1), bit.arshift(n, 63)))
+   elseif enc == "fixed32" then
+      if __pbIsFloat(kind) then
+         __pbBits.f32 = x
+      else
+         __pbBits.u32 = ffi.cast("uint32_t", ffi.cast("int64_t", x))
+      end
+      out[#out+1] = ffi.string(__pbBits.b, 4)
+   elseif enc == "fixed64" then
+      if __pbIsFloat(kind) then
+         __pbBits.f64 = x
+      else
+         __pbBits.u64 = ffi.cast("uint64_t", x)
+      end
+      out[#out+1] = ffi.string(__pbBits.b, 8)
+   else
+      error("grpc: cannot encode a " .. typ.__str .. " as " .. enc, 0)
+   end
+end
+
+local function __pbIsZero(x, typ)
+   local kind = typ.kind
+   if kind == __kindString then
+      return x == ""
+   elseif kind == __kindBool then
+      return x == false
+   end
+   return x == 0
+end
+
+local __pbEncode
+
+-- __pbPutValue writes x, of the type typ, as field spec,
+-- key and all.
+local function __pbPutValue(out, spec, x, typ)
+   if __pbIsMessage(typ) then
+      __pbPutKey(out, spec.num, 2)
+      __pbPutBytes(out, __pbEncode(x, typ.elem))
+   elseif typ.kind == __kindString then
+      __pbPutKey(out, spec.num, 2)
+      __pbPutBytes(out, x)
+   elseif __pbIsBytes(typ) then
+      __pbPutKey(out, spec.num, 2)
+      __pbPutBytes(out, __bytesToString(x))
+   else
+      __pbPutKey(out, spec.num, __pbWire[spec.enc])
+      __pbPutScalar(out, spec.enc, x, typ)
+   end
+end
+
+-- __pbEncode encodes v, a struct of type typ, or a pointer
+-- to one.
+__pbEncode = function(v, typ)
+   local out = {}
+   for _, spec in ipairs(__pbFields(typ).list) do
+      local x = v[spec.prop]
+      local ftyp = spec.typ
+      if __pbIsMessage(ftyp) then
+         if x ~= ftyp.__nil and x ~= nil then
+            __pbPutValue(out, spec, x, ftyp)
+         end
+      elseif ftyp.kind == __kindPtr then
+         -- proto2 optional scalars are pointers.
+         if x ~= ftyp.__nil and x ~= nil then
+            __pbPutValue(out, spec, x.__get(), ftyp.elem)
+         end
+      elseif __pbIsBytes(ftyp) then
+         if x ~= ftyp.__nil and x.__length > 0 then
+            __pbPutValue(out, spec, x, ftyp)
+         end
+      elseif ftyp.kind == __kindSlice then
+         local etyp = ftyp.elem
+         if x ~= ftyp.__nil and x.__length > 0 then
+            if spec.packed then
+               local p = {}
+               for i = 0, x.__length - 1 do
+                  __pbPutScalar(p, spec.enc, x.__array[x.__offset + i], etyp)
+               end
+               __pbPutKey(out, spec.num, 2)
+               __pbPutBytes(out, table.concat(p))
+            else
+               for i = 0, x.__length - 1 do
+                  __pbPutValue(out, spec, x.__array[x.__offset + i], etyp)
+               end
+            end
+         end
+      elseif ftyp.kind == __kindMap then
+         if x ~= nil and x ~= false then
+            for k, e in pairs(x) do
+               local entry = {}
+               __pbPutValue(entry, spec.key, k, spec.key.typ)
+               __pbPutValue(entry, spec.val, e, spec.val.typ)
+               __pbPutKey(out, spec.num, 2)
+               __pbPutBytes(out, table.concat(entry))
+            end
+         end
+      elseif not __pbIsZero(x, ftyp) then
+         __pbPutValue(out, spec, x, ftyp)
+      end
+   end
+   return table.concat(out)
+end
+
+------------------------------
+-- decoding
+------------------------------
+
+-- __pbVarint reads the varint at pos of s, returning it as a
+-- uint64 and the position after it.
+local function __pbVarint(s, pos)
+   local r = ffi.new("uint64_t", 0)
+   local shift = 0
+   while true do
+      local b = string.byte(s, pos)
+      if b == nil then
+         error("grpc: the message is cut short", 0)
+      end
+      pos = pos + 1
+      r = bit.bor(r, bit.lshift(ffi.cast("uint64_t", bit.band(b, 0x7f)), shift))
+      if b < 0x80 then
+         return r, pos
+      end
+      shift = shift + 7
+      if shift > 63 then
+         error("grpc: bad varint", 0)
+      end
+   end
+end
+
+local function __pbFixed(s, pos, n)
+   if pos + n - 1 > #s then
+      error("grpc: the message is cut short", 0)
+   end
+   ffi.copy(__pbBits.b, string.sub(s, pos, pos + n - 1), n)
+   return pos + n
+end
+
+-- __pbScalar reads a number or bool of type typ in the
+-- encoding enc at pos of s.
+local function __pbScalar(s, pos, enc, typ)
+   local kind = typ.kind
+   local r
+   if enc == "varint" or enc == "zigzag32" or enc == "zigzag64" then
+      r, pos = __pbVarint(s, pos)
+      if enc ~= "varint" then
+         r = bit.bxor(bit.rshift(r, 1), -bit.band(r, 1))
+      end
+   elseif enc == "fixed32" then
+      pos = __pbFixed(s, pos, 4)
+      if __pbIsFloat(kind) then
+         return tonumber(__pbBits.f32), pos
+      end
+      r = __pbBits.u32
+      if kind == __kindInt32 or kind == __kindInt then
+         r = ffi.cast("int32_t", r)
+      end
+   elseif enc == "fixed64" then
+      pos = __pbFixed(s, pos, 8)
+      if __pbIsFloat(kind) then
+         return tonumber(__pbBits.f64), pos
+      end
+      r = __pbBits.u64
+   else
+      error("grpc: cannot decode " .. enc .. " as a " .. typ.__str, 0)
+   end
+   if kind == __kindBool then
+      return r ~= 0, pos
+   end
+   local ct = __gi_kindCtype[kind]
+   if ct == nil then
+      return tonumber(ffi.cast("int64_t", r)), pos
+   end
+   return ct(r), pos
+end
+
+-- __pbSkip skips a field of wire type wire at pos of s.
+local function __pbSkip(s, pos, wire)
+   if wire == 0 then
+      local _, p = __pbVarint(s, pos)
+      return p
+   elseif wire == 1 then
+      return pos + 8
+   elseif wire == 2 then
+      local n, p = __pbVarint(s, pos)
+      return p + tonumber(n)
+   elseif wire == 5 then
+      return pos + 4
+   end
+   error("grpc: unsupported wire type " .. tostring(wire), 0)
+end
+
+local __pbDecodeInto
+
+-- __pbValue reads a value of type typ, as field spec of
+-- wire type wire, at pos of s.
+local function __pbValue(s, pos, wire, spec, typ)
+   if wire == 2 then
+      local n, p = __pbVarint(s, pos)
+      n = tonumber(n)
+      if p + n - 1 > #s then
+         error("grpc: the message is cut short", 0)
+      end
+      local sub = string.sub(s, p, p + n - 1)
+      if __pbIsMessage(typ) then
+         local m = typ.elem.ptrToNewlyConstructed()
+         __pbDecodeInto(sub, m, typ.elem)
+         return m, p + n
+      elseif typ.kind == __kindString then
+         return sub, p + n
+      elseif __pbIsBytes(typ) then
+         return typ(__stringToBytes(sub)), p + n
+      end
+      error("grpc: cannot decode bytes as a " .. typ.__str, 0)
+   end
+   return __pbScalar(s, pos, spec.enc, typ)
+end
+
+-- __pbDecodeInto decodes s into v, a pointer to a struct of
+-- type typ, after zeroing its tagged fields.
+__pbDecodeInto = function(s, v, typ)
+   local fields = __pbFields(typ)
+   for _, spec in ipairs(fields.list) do
+      v[spec.prop] = spec.typ.zero()
+   end
+   local pos = 1
+   while pos <= #s do
+      local key
+      key, pos = __pbVarint(s, pos)
+      local num = tonumber(bit.rshift(key, 3))
+      local wire = tonumber(bit.band(key, 7))
+      local spec = fields.byNum[num]
+      if spec == nil then
+         pos = __pbSkip(s, pos, wire)
+      else
+         local ftyp = spec.typ
+         local prop = spec.prop
+         if ftyp.kind == __kindMap then
+            local n, p = __pbVarint(s, pos)
+            pos = p + tonumber(n)
+            local entry = string.sub(s, p, pos - 1)
+            local k, e = spec.key.typ.zero(), spec.val.typ.zero()
+            local q = 1
+            while q <= #entry do
+               local ek
+               ek, q = __pbVarint(entry, q)
+               local en, ew = tonumber(bit.rshift(ek, 3)), tonumber(bit.band(ek, 7))
+               if en == 1 then
+                  k, q = __pbValue(entry, q, ew, spec.key, spec.key.typ)
+               elseif en == 2 then
+                  e, q = __pbValue(entry, q, ew, spec.val, spec.val.typ)
+               else
+                  q = __pbSkip(entry, q, ew)
+               end
+            end
+            local m = v[prop]
+            if m == nil or m == false then
+               m = __makeMap({}, ftyp.key, ftyp.elem)
+               v[prop] = m
+            end
+            m[k] = e
+         elseif ftyp.kind == __kindSlice and not __pbIsBytes(ftyp) then
+            local etyp = ftyp.elem
+            if wire == 2 and __pbWire[spec.enc] ~= 2 then
+               -- packed scalars, which may come for any
+               -- repeated scalar field.
+               local n, p = __pbVarint(s, pos)
+               pos = p + tonumber(n)
+               local q = p
+               while q < pos do
+                  local x
+                  x, q = __pbScalar(s, q, spec.enc, etyp)
+                  v[prop] = __append(v[prop], x)
+               end
+            else
+               local x
+               x, pos = __pbValue(s, pos, wire, spec, etyp)
+               v[prop] = __append(v[prop], x)
+            end
+         elseif ftyp.kind == __kindPtr and not __pbIsMessage(ftyp) then
+            local x
+            x, pos = __pbValue(s, pos, wire, spec, ftyp.elem)
+            v[prop] = __newDataPointer(x, ftyp)
+         else
+            v[prop], pos = __pbValue(s, pos, wire, spec, ftyp)
+         end
+      end
+   end
+   if pos ~= #s + 1 then
+      error("grpc: the message is cut short", 0)
+   end
+end
+
+-- __pbMessageType checks that msg is a pointer to a message
+-- struct, and returns the struct type.
+local function __pbMessageType(msg, what)
+   local typ = type(msg) == "table" and msg.__typ
+   if not typ or not __pbIsMessage(typ) then
+      error("grpc: " .. what .. " must be a pointer to a message struct, not " .. tostring(msg), 0)
+   end
+   return typ.elem
+end
+
+------------------------------
+-- the package
+------------------------------
+
+local __grpcCodeNames = {
+   [0]="OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded",
+   "NotFound", "AlreadyExists", "PermissionDenied", "ResourceExhausted",
+   "FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented",
+   "Internal", "Unavailable", "DataLoss", "Unauthenticated",
+}
+
+local Status = __newType(0, __kindStruct, "grpc.Status", true, "gi/grpc", true, nil)
+Status.init("", {
+   {__prop="Code", __name="Code", __anonymous=false, __exported=true, __typ=__type__.int, __tag=""},
+   {__prop="Message", __name="Message", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+})
+Status.__constructor = function(code, msg)
+   return {Code = code or int(0), Message = msg or ""}
+end
+Status.ptr.prototype.Error = function(this)
+   local code = tonumber(this.Code)
+   return "rpc error: code = " .. (__grpcCodeNames[code] or ("Code(" .. code .. ")")) ..
+      " desc = " .. this.Message
+end
+__type__["gi/grpc"].Status = Status
+
+local function newStatus(code, msg)
+   return Status.ptrToNewlyConstructed(int(code), msg)
+end
+
+local Conn = __newType(0, __kindStruct, "grpc.Conn", true, "gi/grpc", true, nil)
+Conn.init("", {})
+Conn.__constructor = function(id)
+   return {__id = tonumber(id), __timeout = 0}
+end
+__type__["gi/grpc"].Conn = Conn
+
+Conn.ptr.prototype.Invoke = function(this, method, req, resp)
+   local ok, err = pcall(function()
+      local rtyp = __pbMessageType(resp, "the reply")
+      local body = __pbEncode(req, __pbMessageType(req, "the request"))
+      local out, code, msg = __gi_grpcInvoke(this.__id, method, body, this.__timeout)
+      code = tonumber(code)
+      if code ~= 0 then
+         return newStatus(code, msg)
+      end
+      __pbDecodeInto(out, resp, rtyp)
+   end)
+   if not ok then
+      return newStatus(13, tostring(err))
+   end
+   return err
+end
+
+Conn.ptr.prototype.SetTimeout = function(this, ms)
+   this.__timeout = tonumber(ms)
+end
+
+Conn.ptr.prototype.Close = function(this)
+   __gi_grpcClose(this.__id)
+   return nil
+end
+
+grpc.Dial = function(target)
+   local id, errmsg = __gi_grpcDial(target)
+   if errmsg ~= "" then
+      return Conn.ptr.__nil, newStatus(14, errmsg)
+   end
+   return Conn.ptrToNewlyConstructed(id), nil
+end
+
+grpc.Marshal = function(msg)
+   local ok, r = pcall(function()
+      return __pbEncode(msg, __pbMessageType(msg, "the message"))
+   end)
+   if not ok then
+      return __sliceType(__type__.uint8).__nil, newStatus(13, tostring(r))
+   end
+   return __sliceType(__type__.uint8)(__stringToBytes(r)), nil
+end
+
+grpc.Unmarshal = function(b, msg)
+   local ok, err = pcall(function()
+      __pbDecodeInto(__bytesToString(b), msg, __pbMessageType(msg, "the message"))
+   end)
+   if not ok then
+      return newStatus(13, tostring(err))
+   end
+   return nil
+end
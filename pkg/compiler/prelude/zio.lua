@@ -0,0 +1,45 @@
+-- zio.lua: the Lua half of the io interfaces that Go code
+-- sees on interpreted values; see pkg/compiler/ioadapt.go.
+-- It loads after tsys.lua, whose types it needs.
+
+-- __gi_ioHas says whether obj has each of the methods in
+-- the comma separated list names.
+function __gi_ioHas(obj, names)
+   for name in string.gmatch(names, "[^,]+") do
+      local ok, m = pcall(function() return obj[name] end)
+      if not ok or type(m) ~= "function" then
+         return false
+      end
+   end
+   return true
+end
+
+-- __gi_ioRead calls obj:Read with a buffer of n bytes, and
+-- returns what it read, as a string, and its error.
+function __gi_ioRead(obj, n)
+   local typ = __sliceType(__type__.uint8)
+   local p = __makeSlice(typ, n)
+   local k, err = obj:Read(p)
+   k = tonumber(k)
+   if k < 0 or k > n then
+      error("Read returned a count of " .. k .. " for a buffer of " .. n)
+   end
+   return __bytesToString(__subslice(p, 0, k)), err
+end
+
+-- __gi_ioWrite calls obj:Write with the bytes of s.
+function __gi_ioWrite(obj, s)
+   local k, err = obj:Write(__sliceType(__type__.uint8)(__stringToBytes(s)))
+   return tonumber(k), err
+end
+
+function __gi_ioClose(obj)
+   return obj:Close()
+end
+
+function __gi_ioErrorString(err)
+   if type(err) == "table" and err.Error ~= nil then
+      return err:Error()
+   end
+   return tostring(err)
+end
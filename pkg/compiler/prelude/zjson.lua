@@ -0,0 +1,1399 @@
+-- zjson.lua: the runtime for encoding/json; see
+-- pkg/compiler/json.go. It loads after tsys.lua, whose
+-- types it needs, and zerrors.lua.
+--
+-- It works from gi's own type descriptors, so struct
+-- fields are named, renamed, skipped and promoted as
+-- their json tags say, as in Go: `json:"name,omitempty"`,
+-- `json:"-"` and `json:",string"`. The output, and the
+-- messages of the errors, are Go's.
+
+local json = __gi_package("encoding/json")
+local errors = __gi_package("errors")
+__type__["encoding/json"] = __type__["encoding/json"] or {}
+
+local byteSlice = __sliceType(__type__.uint8)
+local anySlice = __sliceType(__type__.emptyInterface)
+local anyMap = __mapType(__type__.string, __type__.emptyInterface)
+
+local function method(name, params, results)
+   return {__prop=name, __name=name, __pkg="", __typ=__funcType(params, results, false)}
+end
+
+local Marshaler = __newType(8, __kindInterface, "json.Marshaler", true, "encoding/json", true, nil)
+Marshaler.init({
+   method("MarshalJSON", {}, {byteSlice, __error}),
+})
+__type__["encoding/json"].Marshaler = Marshaler
+
+local Unmarshaler = __newType(8, __kindInterface, "json.Unmarshaler", true, "encoding/json", true, nil)
+Unmarshaler.init({
+   method("UnmarshalJSON", {byteSlice}, {__error}),
+})
+__type__["encoding/json"].Unmarshaler = Unmarshaler
+
+-- failures inside the encoder and decoder are thrown as
+-- a jsonFailure, and turned into the error returned at
+-- the top.
+local jsonFailure = {}
+
+local function fail(msg)
+   error(setmetatable({msg = msg}, jsonFailure), 0)
+end
+
+-- failure gives the error that e, thrown inside pcall,
+-- stands for, or throws e on if it is not a jsonFailure.
+local function failure(e)
+   if getmetatable(e) == jsonFailure then
+      return errors.New(e.msg)
+   end
+   error(e, 0)
+end
+
+------------------------------
+-- kinds
+------------------------------
+
+local intBits = {
+   [__kindInt] = 64, [__kindInt8] = 8, [__kindInt16] = 16, [__kindInt32] = 32, [__kindInt64] = 64,
+}
+local uintBits = {
+   [__kindUint] = 64, [__kindUint8] = 8, [__kindUint16] = 16, [__kindUint32] = 32, [__kindUint64] = 64,
+   [__kindUintptr] = 64,
+}
+
+local function isFloat(kind)
+   return kind == __kindFloat32 or kind == __kindFloat64
+end
+
+-- isStructValue reports whether v is a struct, which gi
+-- holds as a pointer to the struct's fields.
+local function isStructValue(v)
+   return type(v) == "table" and rawget(v, "__name") == "__pointerToStructValue" and rawget(v, "__val") ~= nil
+end
+
+-- dynamicType is the type of v, a value held in an
+-- interface, or nil for nil.
+local function dynamicType(v)
+   local t = type(v)
+   if v == nil or v == __ifaceNil then
+      return nil
+   elseif t == "boolean" then
+      return __type__.bool
+   elseif t == "number" then
+      return __type__.float64
+   elseif t == "string" then
+      return __type__.string
+   elseif t == "cdata" then
+      local s = tostring(v)
+      if s:match("ULL$") then
+         return __type__.uint64
+      elseif s:match("LL$") then
+         return __type__.int64
+      end
+      return __type__.complex128
+   elseif t == "table" then
+      local typ = rawget(v, "__typ") or (getmetatable(v) ~= nil and v.__typ)
+      if type(typ) == "table" and typ.kind ~= nil then
+         if typ.kind == __kindPtr and isStructValue(v) then
+            return typ.elem
+         end
+         return typ
+      end
+      if rawget(v, "__val") == v then
+         -- a nil pointer to a struct.
+         return nil
+      end
+   elseif t == "function" then
+      return __funcType({}, {}, false)
+   end
+   fail("json: unsupported type: " .. t)
+end
+
+-- shortName is the name of a type without its package,
+-- as Go's reflect.Type.Name gives it; "" if unnamed.
+local function shortName(typ)
+   if not typ.named then
+      return ""
+   end
+   return (typ.__str:gsub("^.*%.", ""))
+end
+
+------------------------------
+-- struct fields
+------------------------------
+
+-- validName reports whether name can be a key given by
+-- a json tag.
+local function validName(name)
+   if name == "" then
+      return false
+   end
+   return not name:find("[^%w!#$%%&()*+%-./:;<=>?@%[%]^_{|}~ \128-\255]")
+end
+
+-- fieldsOf lists the fields that json encodes of the
+-- struct type typ, embedded structs' fields promoted,
+-- with Go's rules for which of those with a same name
+-- wins: the shallowest, or the tagged one at the same
+-- depth; otherwise none. Each has the path of __prop
+-- through the embedded structs to it.
+local fieldCache = setmetatable({}, {__mode = "k"})
+
+local function fieldsOf(typ)
+   local cached = fieldCache[typ]
+   if cached ~= nil then
+      return cached
+   end
+   local all = {}
+   local function walk(st, depth, path, visiting)
+      visiting[st] = true
+      for _, f in ipairs(st.fields) do
+         local tag = __gi_structTagLookup(f.__tag, "json")
+         if tag ~= "-" then
+            local name, opts = tag:match("^([^,]*)(.*)$")
+            if not validName(name) then
+               name = ""
+            end
+            local ft = f.__typ
+            local inner = ft
+            if ft.kind == __kindPtr then
+               inner = ft.elem
+            end
+            local p = {unpack(path)}
+            p[#p+1] = f.__prop
+            if f.__anonymous and name == "" and inner.kind == __kindStruct then
+               if not visiting[inner] then
+                  walk(inner, depth + 1, p, visiting)
+               end
+            elseif f.__exported then
+               all[#all+1] = {
+                  name = name ~= "" and name or f.__name,
+                  tagged = name ~= "",
+                  depth = depth,
+                  path = p,
+                  typ = ft,
+                  omitEmpty = opts:find(",omitempty") ~= nil,
+                  quoted = opts:find(",string") ~= nil,
+               }
+            end
+         end
+      end
+      visiting[st] = nil
+   end
+   walk(typ, 0, {}, {})
+
+   local byName = {}
+   for _, f in ipairs(all) do
+      local g = byName[f.name]
+      if g == nil then
+         g = {}
+         byName[f.name] = g
+      end
+      g[#g+1] = f
+   end
+   local fields = {}
+   for _, f in ipairs(all) do
+      local win = nil
+      local g = byName[f.name]
+      local top, ntop, tagged, ntagged = nil, 0, nil, 0
+      for _, h in ipairs(g) do
+         if top == nil or h.depth < top then
+            top, ntop, tagged, ntagged = h.depth, 0, nil, 0
+         end
+         if h.depth == top then
+            ntop = ntop + 1
+            win = h
+            if h.tagged then
+               ntagged = ntagged + 1
+               tagged = h
+            end
+         end
+      end
+      if ntop > 1 then
+         win = ntagged == 1 and tagged or nil
+      end
+      if win == f then
+         fields[#fields+1] = f
+      end
+   end
+   fieldCache[typ] = fields
+   return fields
+end
+
+-- fieldValue follows f's path from the struct v; nil if
+-- it goes through a nil embedded pointer.
+local function fieldValue(v, f)
+   for i = 1, #f.path - 1 do
+      v = v[f.path[i]]
+      if v == nil or not isStructValue(v) then
+         return nil, false
+      end
+   end
+   return v[f.path[#f.path]], true
+end
+
+------------------------------
+-- encoding
+------------------------------
+
+local function utf8Rune(s, i)
+   local c = s:byte(i)
+   local n, r
+   if c < 0xc2 or c > 0xf4 then
+      return nil, 1
+   elseif c < 0xe0 then
+      n, r = 2, c - 0xc0
+   elseif c < 0xf0 then
+      n, r = 3, c - 0xe0
+   else
+      n, r = 4, c - 0xf0
+   end
+   for j = i + 1, i + n - 1 do
+      local d = s:byte(j)
+      if d == nil or d < 0x80 or d > 0xbf then
+         return nil, 1
+      end
+      r = r * 64 + (d - 0x80)
+   end
+   if (n == 3 and r < 0x800) or (n == 4 and (r < 0x10000 or r > 0x10ffff)) or (r >= 0xd800 and r <= 0xdfff) then
+      return nil, 1
+   end
+   return r, n
+end
+
+local shortEscapes = {
+   [0x22] = '\\"', [0x5c] = "\\\\", [0x08] = "\\b", [0x0c] = "\\f",
+   [0x0a] = "\\n", [0x0d] = "\\r", [0x09] = "\\t",
+}
+
+-- quote writes s as a JSON string, escaping as Go's
+-- Marshal does, HTML's <, > and & included; invalid
+-- UTF-8 becomes U+FFFD.
+local function quote(s, out)
+   local b = {'"'}
+   local i, n = 1, #s
+   while i <= n do
+      local c = s:byte(i)
+      if c < 0x80 then
+         local e = shortEscapes[c]
+         if e ~= nil then
+            b[#b+1] = e
+         elseif c < 0x20 or c == 0x3c or c == 0x3e or c == 0x26 then
+            b[#b+1] = string.format("\\u%04x", c)
+         else
+            b[#b+1] = string.char(c)
+         end
+         i = i + 1
+      else
+         local r, size = utf8Rune(s, i)
+         if r == nil then
+            b[#b+1] = "\\ufffd"
+         elseif r == 0x2028 or r == 0x2029 then
+            b[#b+1] = string.format("\\u%04x", r)
+         else
+            b[#b+1] = s:sub(i, i + size - 1)
+         end
+         i = i + size
+      end
+   end
+   b[#b+1] = '"'
+   out[#out+1] = table.concat(b)
+end
+
+-- formatFloat formats f as Go's Marshal does: the
+-- shortest decimal that reads back as f, in exponent
+-- form only when it is very large or small.
+local function formatFloat(f, bits)
+   if f ~= f or f == __builtin_math.huge or f == -__builtin_math.huge then
+      local s = f ~= f and "NaN" or (f > 0 and "+Inf" or "-Inf")
+      fail("json: unsupported value: " .. s)
+   end
+   if f == 0 then
+      return (1 / f < 0) and "-0" or "0"
+   end
+   local same = function(s)
+      local g = tonumber(s)
+      if bits == 32 then
+         g = tonumber(float32(g))
+      end
+      return g == f
+   end
+   local mant, exp
+   for prec = 0, 16 do
+      local s = string.format("%." .. prec .. "e", f)
+      if same(s) or prec == 16 then
+         mant, exp = s:match("^(-?[%d.]+)e([-+]%d+)$")
+         break
+      end
+   end
+   local neg = mant:sub(1, 1) == "-"
+   local digits = mant:gsub("[-.]", ""):gsub("0+$", "")
+   if digits == "" then
+      digits = "0"
+   end
+   exp = tonumber(exp)
+   local abs = __builtin_math.abs(f)
+   local s
+   if abs < 1e-6 or abs >= 1e21 then
+      s = digits:sub(1, 1)
+      if #digits > 1 then
+         s = s .. "." .. digits:sub(2)
+      end
+      if exp < 0 then
+         s = s .. "e-" .. tostring(-exp)
+      else
+         s = s .. "e+" .. (exp < 10 and "0" or "") .. tostring(exp)
+      end
+   elseif exp >= 0 then
+      if #digits <= exp + 1 then
+         s = digits .. string.rep("0", exp + 1 - #digits)
+      else
+         s = digits:sub(1, exp + 1) .. "." .. digits:sub(exp + 2)
+      end
+   else
+      s = "0." .. string.rep("0", -exp - 1) .. digits
+   end
+   if neg then
+      s = "-" .. s
+   end
+   return s
+end
+
+local b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+local function base64Encode(s)
+   local out = {}
+   for i = 1, #s, 3 do
+      local a, b, c = s:byte(i, i + 2)
+      local n = a * 65536 + (b or 0) * 256 + (c or 0)
+      local q = {}
+      for j = 3, 0, -1 do
+         local k = __builtin_math.floor(n / 64 ^ j) % 64
+         q[#q+1] = b64:sub(k + 1, k + 1)
+      end
+      if c == nil then
+         q[4] = "="
+      end
+      if b == nil then
+         q[3] = "="
+      end
+      out[#out+1] = table.concat(q)
+   end
+   return table.concat(out)
+end
+
+local function base64Decode(s)
+   s = s:gsub("[\r\n]", "")
+   if #s % 4 ~= 0 or s:find("[^%w+/=]") or s:find("=[^=]") then
+      return nil
+   end
+   local out = {}
+   for i = 1, #s, 4 do
+      local n, pad = 0, 0
+      for j = i, i + 3 do
+         local c = s:sub(j, j)
+         local k = 0
+         if c == "=" then
+            pad = pad + 1
+         else
+            k = b64:find(c, 1, true) - 1
+         end
+         n = n * 64 + k
+      end
+      local a, b, c = __builtin_math.floor(n / 65536) % 256, __builtin_math.floor(n / 256) % 256, n % 256
+      out[#out+1] = string.char(a)
+      if pad < 2 then
+         out[#out+1] = string.char(b)
+      end
+      if pad < 1 then
+         out[#out+1] = string.char(c)
+      end
+   end
+   return table.concat(out)
+end
+
+local function intText(v)
+   if type(v) == "number" then
+      return string.format("%d", v)
+   end
+   return (tostring(v):gsub("U?LL$", ""))
+end
+
+local function isNilPtr(v, typ)
+   return v == nil or v == typ.__nil or (typ.elem.kind == __kindStruct and not isStructValue(v))
+end
+
+local function isEmpty(v, typ)
+   local kind = typ.kind
+   if kind == __kindString then
+      return v == ""
+   elseif kind == __kindBool then
+      return v == false
+   elseif intBits[kind] or uintBits[kind] or isFloat(kind) then
+      return v == 0
+   elseif kind == __kindSlice then
+      return v == nil or v == typ.__nil or __lenz(v) == 0
+   elseif kind == __kindArray then
+      return typ.len == 0
+   elseif kind == __kindMap then
+      return v == nil or v.len == 0
+   elseif kind == __kindPtr then
+      return isNilPtr(v, typ)
+   elseif kind == __kindInterface then
+      return v == nil or v == __ifaceNil
+   end
+   return false
+end
+
+local parse, compact
+
+local encode
+
+local function encodeStruct(v, typ, out, seen)
+   out[#out+1] = "{"
+   local first = true
+   for _, f in ipairs(fieldsOf(typ)) do
+      local fv, ok = fieldValue(v, f)
+      if ok and not (f.omitEmpty and isEmpty(fv, f.typ)) then
+         if not first then
+            out[#out+1] = ","
+         end
+         first = false
+         quote(f.name, out)
+         out[#out+1] = ":"
+         local k = f.typ.kind
+         if f.quoted and (k == __kindString or k == __kindBool or intBits[k] or uintBits[k] or isFloat(k)) then
+            local inner = {}
+            encode(fv, f.typ, inner, seen)
+            quote(table.concat(inner), out)
+         else
+            encode(fv, f.typ, out, seen)
+         end
+      end
+   end
+   out[#out+1] = "}"
+end
+
+-- marshaler calls v's MarshalJSON, if it has one, and
+-- writes what it gives.
+local function marshaler(v, typ, out)
+   if not isStructValue(v) or type(v.MarshalJSON) ~= "function" then
+      return false
+   end
+   local b, err = v:MarshalJSON()
+   if err ~= nil and err ~= __ifaceNil then
+      fail("json: error calling MarshalJSON for type " .. typ.__str .. ": " .. err:Error())
+   end
+   local s = b ~= nil and b ~= byteSlice.__nil and __bytesToString(b) or "null"
+   local ok, e = pcall(parse, s)
+   if not ok then
+      if getmetatable(e) == jsonFailure then
+         fail("json: error calling MarshalJSON for type " .. typ.__str .. ": " .. e.msg)
+      end
+      error(e, 0)
+   end
+   out[#out+1] = compact(s)
+   return true
+end
+
+encode = function(v, typ, out, seen)
+   if typ == nil then
+      out[#out+1] = "null"
+      return
+   end
+   local kind = typ.kind
+   if kind == __kindInterface then
+      if v == nil or v == __ifaceNil then
+         out[#out+1] = "null"
+         return
+      end
+      return encode(v, dynamicType(v), out, seen)
+   end
+   if (kind == __kindStruct or kind == __kindPtr) and marshaler(v, typ, out) then
+      return
+   end
+   if kind == __kindBool then
+      out[#out+1] = v and "true" or "false"
+   elseif intBits[kind] or uintBits[kind] then
+      out[#out+1] = intText(v)
+   elseif isFloat(kind) then
+      out[#out+1] = formatFloat(tonumber(v), kind == __kindFloat32 and 32 or 64)
+   elseif kind == __kindString then
+      quote(v, out)
+   elseif kind == __kindStruct then
+      if seen[v] then
+         fail("json: unsupported value: encountered a cycle via " .. typ.__str)
+      end
+      seen[v] = true
+      encodeStruct(v, typ, out, seen)
+      seen[v] = nil
+   elseif kind == __kindPtr then
+      if isNilPtr(v, typ) then
+         out[#out+1] = "null"
+      elseif typ.elem.kind == __kindStruct then
+         encode(v, typ.elem, out, seen)
+      else
+         encode(v.__get(), typ.elem, out, seen)
+      end
+   elseif kind == __kindSlice then
+      if v == nil or v == typ.__nil then
+         out[#out+1] = "null"
+      elseif typ.elem.kind == __kindUint8 then
+         out[#out+1] = '"' .. base64Encode(__bytesToString(v)) .. '"'
+      else
+         out[#out+1] = "["
+         for i = 0, __lenz(v) - 1 do
+            if i > 0 then
+               out[#out+1] = ","
+            end
+            encode(v[i], typ.elem, out, seen)
+         end
+         out[#out+1] = "]"
+      end
+   elseif kind == __kindArray then
+      out[#out+1] = "["
+      for i = 0, typ.len - 1 do
+         if i > 0 then
+            out[#out+1] = ","
+         end
+         encode(v[i], typ.elem, out, seen)
+      end
+      out[#out+1] = "]"
+   elseif kind == __kindMap then
+      local kk = typ.key.kind
+      if kk ~= __kindString and not intBits[kk] and not uintBits[kk] then
+         fail("json: unsupported type: " .. typ.__str)
+      end
+      if v == nil then
+         out[#out+1] = "null"
+         return
+      end
+      local keys, vals = {}, {}
+      for k, e in pairs(v.__val) do
+         if kk ~= __kindString then
+            k = intText(k)
+         end
+         if e == __intentionalNilValue then
+            e = nil
+         end
+         keys[#keys+1] = k
+         vals[k] = e
+      end
+      table.sort(keys)
+      out[#out+1] = "{"
+      for i, k in ipairs(keys) do
+         if i > 1 then
+            out[#out+1] = ","
+         end
+         quote(k, out)
+         out[#out+1] = ":"
+         encode(vals[k], typ.elem, out, seen)
+      end
+      out[#out+1] = "}"
+   else
+      fail("json: unsupported type: " .. typ.__str)
+   end
+end
+
+------------------------------
+-- parsing
+------------------------------
+
+-- quoteChar names the byte c in a syntax error, as Go's
+-- scanner does.
+local function quoteChar(c)
+   if c == "'" then
+      return "'\\''"
+   elseif c == '"' then
+      return "'\"'"
+   end
+   local b = c:byte()
+   local named = {[7] = "\\a", [8] = "\\b", [9] = "\\t", [10] = "\\n", [11] = "\\v", [12] = "\\f", [13] = "\\r", [92] = "\\\\"}
+   if named[b] ~= nil then
+      return "'" .. named[b] .. "'"
+   elseif b < 0x20 or b == 0x7f then
+      return string.format("'\\x%02x'", b)
+   elseif b >= 0x80 then
+      return string.format("'\\u%04x'", b)
+   end
+   return "'" .. c .. "'"
+end
+
+local function utf8Encode(r)
+   if r < 0x80 then
+      return string.char(r)
+   elseif r < 0x800 then
+      return string.char(0xc0 + __builtin_math.floor(r / 64), 0x80 + r % 64)
+   elseif r < 0x10000 then
+      return string.char(0xe0 + __builtin_math.floor(r / 4096), 0x80 + __builtin_math.floor(r / 64) % 64, 0x80 + r % 64)
+   end
+   return string.char(0xf0 + __builtin_math.floor(r / 262144), 0x80 + __builtin_math.floor(r / 4096) % 64,
+                      0x80 + __builtin_math.floor(r / 64) % 64, 0x80 + r % 64)
+end
+
+-- parse reads the JSON text s into a tree of nodes:
+-- {k="o", keys=, vals=}, {k="a", items=}, {k="s", v=},
+-- {k="n", v=<the number's text>}, {k="b", v=} and
+-- {k="z"} for null; each with its text, as raw.
+parse = function(s)
+   local pos = 1
+   local n = #s
+
+   local function eof()
+      fail("unexpected end of JSON input")
+   end
+   local function bad(context)
+      fail("invalid character " .. quoteChar(s:sub(pos, pos)) .. " " .. context)
+   end
+   local function ws()
+      pos = s:find("[^ \t\r\n]", pos) or n + 1
+   end
+
+   local value
+
+   local function str()
+      -- s[pos] is the opening quote.
+      local b = {}
+      pos = pos + 1
+      while true do
+         if pos > n then
+            eof()
+         end
+         local c = s:byte(pos)
+         if c == 0x22 then
+            pos = pos + 1
+            return table.concat(b)
+         elseif c == 0x5c then
+            local e = s:sub(pos + 1, pos + 1)
+            if e == "" then
+               eof()
+            end
+            local simple = ({['"'] = '"', ["\\"] = "\\", ["/"] = "/", b = "\b", f = "\f", n = "\n", r = "\r", t = "\t"})[e]
+            if simple ~= nil then
+               b[#b+1] = simple
+               pos = pos + 2
+            elseif e == "u" then
+               local h = s:sub(pos + 2, pos + 5)
+               if #h < 4 and not h:find("[^%x]") then
+                  eof()
+               end
+               if h:find("[^%x]") then
+                  fail("invalid escape sequence `\\u" .. h .. "` in string")
+               end
+               local r = tonumber(h, 16)
+               pos = pos + 6
+               if r >= 0xd800 and r < 0xdc00 then
+                  local h2 = s:match("^\\u(%x%x%x%x)", pos)
+                  local r2 = h2 and tonumber(h2, 16)
+                  if r2 ~= nil and r2 >= 0xdc00 and r2 < 0xe000 then
+                     r = 0x10000 + (r - 0xd800) * 1024 + (r2 - 0xdc00)
+                     pos = pos + 6
+                  else
+                     r = 0xfffd
+                  end
+               elseif r >= 0xdc00 and r < 0xe000 then
+                  r = 0xfffd
+               end
+               b[#b+1] = utf8Encode(r)
+            else
+               fail("invalid escape sequence `\\" .. e .. "` in string")
+            end
+         elseif c < 0x20 then
+            bad("in string")
+         else
+            local j = s:find('["\\%z\1-\31]', pos) or n + 1
+            b[#b+1] = s:sub(pos, j - 1)
+            pos = j
+         end
+      end
+   end
+
+   local function digits()
+      local j = s:find("[^0-9]", pos) or n + 1
+      local got = j > pos
+      pos = j
+      return got
+   end
+
+   local function number()
+      local start = pos
+      if s:sub(pos, pos) == "-" then
+         pos = pos + 1
+         if pos > n then
+            eof()
+         end
+         if not s:sub(pos, pos):find("%d") then
+            bad("in numeric literal")
+         end
+      end
+      if s:sub(pos, pos) == "0" then
+         pos = pos + 1
+      else
+         digits()
+      end
+      if s:sub(pos, pos) == "." then
+         pos = pos + 1
+         if pos > n then
+            eof()
+         end
+         if not digits() then
+            bad("in numeric literal")
+         end
+      end
+      local e = s:sub(pos, pos)
+      if e == "e" or e == "E" then
+         pos = pos + 1
+         local sign = s:sub(pos, pos)
+         if sign == "+" or sign == "-" then
+            pos = pos + 1
+         end
+         if pos > n then
+            eof()
+         end
+         if not digits() then
+            bad("in numeric literal")
+         end
+      end
+      return s:sub(start, pos - 1)
+   end
+
+   local function literal(word, v)
+      for i = 1, #word do
+         if pos > n then
+            eof()
+         end
+         if s:sub(pos, pos) ~= word:sub(i, i) then
+            bad("in literal " .. word .. " (expecting " .. quoteChar(word:sub(i, i)) .. ")")
+         end
+         pos = pos + 1
+      end
+      return v
+   end
+
+   value = function()
+      ws()
+      if pos > n then
+         eof()
+      end
+      local start = pos
+      local c = s:sub(pos, pos)
+      local node
+      if c == "{" then
+         node = {k = "o", keys = {}, vals = {}}
+         pos = pos + 1
+         ws()
+         if s:sub(pos, pos) == "}" then
+            pos = pos + 1
+         else
+            while true do
+               ws()
+               if pos > n then
+                  eof()
+               end
+               if s:sub(pos, pos) ~= '"' then
+                  bad("looking for beginning of object key string")
+               end
+               local key = str()
+               ws()
+               if pos > n then
+                  eof()
+               end
+               if s:sub(pos, pos) ~= ":" then
+                  bad("after object key")
+               end
+               pos = pos + 1
+               node.keys[#node.keys+1] = key
+               node.vals[#node.vals+1] = value()
+               ws()
+               if pos > n then
+                  eof()
+               end
+               local d = s:sub(pos, pos)
+               pos = pos + 1
+               if d == "}" then
+                  break
+               elseif d ~= "," then
+                  pos = pos - 1
+                  bad("after object key:value pair")
+               end
+            end
+         end
+      elseif c == "[" then
+         node = {k = "a", items = {}}
+         pos = pos + 1
+         ws()
+         if s:sub(pos, pos) == "]" then
+            pos = pos + 1
+         else
+            while true do
+               node.items[#node.items+1] = value()
+               ws()
+               if pos > n then
+                  eof()
+               end
+               local d = s:sub(pos, pos)
+               pos = pos + 1
+               if d == "]" then
+                  break
+               elseif d ~= "," then
+                  pos = pos - 1
+                  bad("after array element")
+               end
+            end
+         end
+      elseif c == '"' then
+         node = {k = "s", v = str()}
+      elseif c == "-" or c:find("%d") then
+         node = {k = "n", v = number()}
+      elseif c == "t" then
+         node = {k = "b", v = literal("true", true)}
+      elseif c == "f" then
+         node = {k = "b", v = literal("false", false)}
+      elseif c == "n" then
+         node = {k = "z", v = literal("null", nil)}
+      else
+         bad("looking for beginning of value")
+      end
+      node.raw = s:sub(start, pos - 1)
+      return node
+   end
+
+   local root = value()
+   ws()
+   if pos <= n then
+      bad("after top-level value")
+   end
+   return root
+end
+
+-- compact drops the insignificant space of the valid
+-- JSON text s.
+compact = function(s)
+   local out = {}
+   local i, n = 1, #s
+   while i <= n do
+      local j = s:find('[ \t\r\n"]', i) or n + 1
+      out[#out+1] = s:sub(i, j - 1)
+      if j > n then
+         break
+      end
+      if s:sub(j, j) == '"' then
+         local k = j + 1
+         while true do
+            local c = s:sub(k, k)
+            if c == "\\" then
+               k = k + 2
+            elseif c == '"' then
+               break
+            else
+               k = k + 1
+            end
+         end
+         out[#out+1] = s:sub(j, k)
+         i = k + 1
+      else
+         i = j + 1
+      end
+   end
+   return table.concat(out)
+end
+
+-- indent lays out the compact JSON text s as Go's
+-- Indent does.
+local function indent(s, prefix, ind)
+   local out = {}
+   local depth = 0
+   local needIndent = false
+   local function newline()
+      out[#out+1] = "\n" .. prefix .. string.rep(ind, depth)
+   end
+   local i, n = 1, #s
+   while i <= n do
+      local c = s:sub(i, i)
+      if needIndent and c ~= "]" and c ~= "}" then
+         needIndent = false
+         depth = depth + 1
+         newline()
+      end
+      if c == '"' then
+         local k = i + 1
+         while true do
+            local d = s:sub(k, k)
+            if d == "\\" then
+               k = k + 2
+            elseif d == '"' then
+               break
+            else
+               k = k + 1
+            end
+         end
+         out[#out+1] = s:sub(i, k)
+         i = k
+      elseif c == "{" or c == "[" then
+         out[#out+1] = c
+         needIndent = true
+      elseif c == "," then
+         out[#out+1] = c
+         newline()
+      elseif c == ":" then
+         out[#out+1] = ": "
+      elseif c == "}" or c == "]" then
+         if needIndent then
+            needIndent = false
+         else
+            depth = depth - 1
+            newline()
+         end
+         out[#out+1] = c
+      else
+         out[#out+1] = c
+      end
+      i = i + 1
+   end
+   return table.concat(out)
+end
+
+------------------------------
+-- decoding
+------------------------------
+
+local intLimits = {
+   [8] = {-128, 127}, [16] = {-32768, 32767}, [32] = {-2147483648, 2147483647},
+}
+local uintLimits = {[8] = 255, [16] = 65535, [32] = 4294967295}
+
+-- parseInt gives the int64, or uint64 if unsigned, that
+-- the text of a JSON number denotes, if it is an integer
+-- in the range of bits.
+local function parseInt(text, bits, unsigned)
+   local neg, ds = text:match("^(%-?)(%d+)$")
+   if ds == nil then
+      return nil
+   end
+   if unsigned and neg == "-" then
+      return nil
+   end
+   if bits < 64 then
+      local x = tonumber(text)
+      if unsigned then
+         if x > uintLimits[bits] then
+            return nil
+         end
+         return uint64(x)
+      end
+      if x < intLimits[bits][1] or x > intLimits[bits][2] then
+         return nil
+      end
+      return int64(x)
+   end
+   local max = unsigned and "18446744073709551615" or (neg == "-" and "9223372036854775808" or "9223372036854775807")
+   if #ds > #max or (#ds == #max and ds > max) then
+      return nil
+   end
+   local v = unsigned and 0ULL or 0LL
+   for i = 1, #ds do
+      local d = ds:byte(i) - 48
+      if neg == "-" then
+         v = v * 10 - d
+      else
+         v = v * 10 + d
+      end
+   end
+   return v
+end
+
+local nodeWhat = {o = "object", a = "array", s = "string", n = "number", b = "bool"}
+
+-- a decoder holds the first type mismatch met, and where:
+-- the struct and the keys to it, for the message.
+local decoder = {}
+decoder.__index = decoder
+
+function decoder:mismatch(what, typ)
+   if self.err ~= nil then
+      return
+   end
+   if self.struct ~= nil then
+      self.err = "json: cannot unmarshal " .. what .. " into Go struct field " .. shortName(self.struct) ..
+         "." .. table.concat(self.keys, ".") .. " of type " .. typ.__str
+   else
+      self.err = "json: cannot unmarshal " .. what .. " into Go value of type " .. typ.__str
+   end
+end
+
+local function unmarshaler(v)
+   return isStructValue(v) and type(v.UnmarshalJSON) == "function"
+end
+
+-- value decodes node as a value of type typ, into cur
+-- where that is a struct, map or pointer to fill, and
+-- returns the value.
+-- fresh is a new zero value of typ, to decode into: a
+-- struct's zero() has no fields of its own to fill.
+local function fresh(typ)
+   if typ.kind == __kindStruct then
+      return typ.ptrToNewlyConstructed()
+   end
+   return typ.zero()
+end
+
+function decoder:value(node, typ, cur)
+   local kind = typ.kind
+   if unmarshaler(cur) then
+      local err = cur:UnmarshalJSON(byteSlice(__stringToBytes(node.raw)))
+      if err ~= nil and err ~= __ifaceNil then
+         fail(err:Error())
+      end
+      return cur
+   end
+   if node.k == "z" then
+      if kind == __kindInterface or kind == __kindPtr or kind == __kindMap or kind == __kindSlice then
+         return typ.zero()
+      end
+      return cur
+   end
+
+   if kind == __kindInterface then
+      if #typ.methods > 0 then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      return self:any(node)
+
+   elseif kind == __kindBool then
+      if node.k ~= "b" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      return node.v
+
+   elseif intBits[kind] or uintBits[kind] then
+      if node.k ~= "n" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      local x = parseInt(node.v, intBits[kind] or uintBits[kind], uintBits[kind] ~= nil)
+      if x == nil then
+         self:mismatch("number " .. node.v, typ)
+         return cur
+      end
+      return x
+
+   elseif isFloat(kind) then
+      if node.k ~= "n" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      local x = tonumber(node.v)
+      if kind == __kindFloat32 then
+         if __builtin_math.abs(x) > 3.4028234663852886e38 then
+            self:mismatch("number " .. node.v, typ)
+            return cur
+         end
+         return tonumber(float32(x))
+      end
+      if x == __builtin_math.huge or x == -__builtin_math.huge then
+         self:mismatch("number " .. node.v, typ)
+         return cur
+      end
+      return x
+
+   elseif kind == __kindString then
+      if node.k ~= "s" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      return node.v
+
+   elseif kind == __kindSlice then
+      if node.k == "s" and typ.elem.kind == __kindUint8 then
+         local b = base64Decode(node.v)
+         if b == nil then
+            fail("illegal base64 data at input byte 0")
+         end
+         return typ(__stringToBytes(b))
+      end
+      if node.k ~= "a" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      local items = {}
+      for i, item in ipairs(node.items) do
+         items[i - 1] = self:value(item, typ.elem, fresh(typ.elem))
+      end
+      if typ.elem.kind == __kindUint8 then
+         local b = {}
+         for i = 0, #node.items - 1 do
+            b[i + 1] = string.char(tonumber(items[i]))
+         end
+         return typ(__stringToBytes(table.concat(b)))
+      end
+      return typ(items)
+
+   elseif kind == __kindArray then
+      if node.k ~= "a" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      for i = 0, typ.len - 1 do
+         local item = node.items[i + 1]
+         if item ~= nil then
+            cur[i] = self:value(item, typ.elem, cur[i])
+         else
+            cur[i] = fresh(typ.elem)
+         end
+      end
+      return cur
+
+   elseif kind == __kindMap then
+      local kk = typ.key.kind
+      if node.k ~= "o" or (kk ~= __kindString and not intBits[kk] and not uintBits[kk]) then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      if cur == false then
+         -- a nil map, as the compiler writes it.
+         cur = nil
+      end
+      local entries = {}
+      for i, key in ipairs(node.keys) do
+         local nk = #self.keys
+         self.keys[nk + 1] = key
+         local k = key
+         if kk ~= __kindString then
+            k = parseInt(key, intBits[kk] or uintBits[kk], uintBits[kk] ~= nil)
+            if k == nil then
+               self:mismatch("number " .. key, typ.key)
+            end
+         end
+         if k ~= nil then
+            local e = self:value(node.vals[i], typ.elem, fresh(typ.elem))
+            if cur ~= nil then
+               cur[k] = e
+            else
+               entries[k] = e == nil and __intentionalNilValue or e
+            end
+         end
+         self.keys[nk + 1] = nil
+      end
+      if cur == nil then
+         cur = __makeMap(entries, typ.key, typ.elem, typ)
+      end
+      return cur
+
+   elseif kind == __kindStruct then
+      if node.k ~= "o" then
+         self:mismatch(nodeWhat[node.k], typ)
+         return cur
+      end
+      if not isStructValue(cur) then
+         cur = typ.ptrToNewlyConstructed()
+      end
+      self:fields(node, typ, cur)
+      return cur
+
+   elseif kind == __kindPtr then
+      if typ.elem.kind == __kindStruct then
+         if isNilPtr(cur, typ) then
+            cur = typ.elem.ptrToNewlyConstructed()
+         end
+         return self:value(node, typ.elem, cur)
+      end
+      if isNilPtr(cur, typ) then
+         return __newDataPointer(self:value(node, typ.elem, fresh(typ.elem)), typ)
+      end
+      cur.__set(self:value(node, typ.elem, cur.__get()))
+      return cur
+   end
+   fail("json: cannot unmarshal into Go value of type " .. typ.__str)
+end
+
+-- field finds the field of typ that key names: exactly,
+-- or else without regard to case.
+local function field(typ, key)
+   local folded = nil
+   local lk = key:lower()
+   for _, f in ipairs(fieldsOf(typ)) do
+      if f.name == key then
+         return f
+      end
+      if folded == nil and f.name:lower() == lk then
+         folded = f
+      end
+   end
+   return folded
+end
+
+function decoder:fields(node, typ, cur)
+   local struct, keys = self.struct, self.keys
+   for i, key in ipairs(node.keys) do
+      local f = field(typ, key)
+      if f ~= nil then
+         -- the struct named in messages is the outermost.
+         if self.struct == nil then
+            self.struct = typ
+         end
+         local nk = #self.keys
+         self.keys[nk + 1] = f.name
+         local v = cur
+         for j = 1, #f.path - 1 do
+            local inner = v[f.path[j]]
+            if not isStructValue(inner) then
+               inner = self:embedded(typ, f, j)
+               v[f.path[j]] = inner
+            end
+            v = inner
+         end
+         local prop = f.path[#f.path]
+         local vn = node.vals[i]
+         if f.quoted then
+            vn = self:unquote(vn, f)
+         end
+         if vn ~= nil then
+            v[prop] = self:value(vn, f.typ, v[prop])
+         end
+         self.keys[nk + 1] = nil
+      end
+   end
+   self.struct, self.keys = struct, keys
+end
+
+-- embedded makes the struct of the j-th embedded field on
+-- f's path, whose pointer was nil.
+function decoder:embedded(typ, f, j)
+   local st = typ
+   for k = 1, j do
+      for _, g in ipairs(st.fields) do
+         if g.__prop == f.path[k] then
+            st = g.__typ
+            break
+         end
+      end
+      if st.kind == __kindPtr then
+         st = st.elem
+      end
+   end
+   return st.ptrToNewlyConstructed()
+end
+
+-- unquote undoes the ,string option: the value is in a
+-- JSON string.
+function decoder:unquote(node, f)
+   if node.k == "z" then
+      return node
+   end
+   local k = f.typ.kind
+   local ok, inner = false, nil
+   if node.k == "s" then
+      ok, inner = pcall(parse, node.v)
+   end
+   local want = "n"
+   if k == __kindString then
+      want = "s"
+   elseif k == __kindBool then
+      want = "b"
+   end
+   if not ok or (inner.k ~= want and inner.k ~= "z") then
+      if self.err == nil then
+         local got = node.k == "s" and string.format("%q", node.v) or node.raw
+         self.err = "json: invalid use of ,string struct tag, trying to unmarshal " .. got .. " into " .. f.typ.__str
+      end
+      return nil
+   end
+   return inner
+end
+
+-- any decodes node as an interface{}: objects as
+-- map[string]interface{}, arrays as []interface{},
+-- numbers as float64.
+function decoder:any(node)
+   local k = node.k
+   if k == "o" then
+      if cur == false then
+         -- a nil map, as the compiler writes it.
+         cur = nil
+      end
+      local entries = {}
+      for i, key in ipairs(node.keys) do
+         local v = self:any(node.vals[i])
+         if v == nil then
+            v = __intentionalNilValue
+         end
+         entries[key] = v
+      end
+      return __makeMap(entries, __type__.string, __type__.emptyInterface, anyMap)
+   elseif k == "a" then
+      local items = {}
+      for i, item in ipairs(node.items) do
+         local v = self:any(item)
+         if v == nil then
+            v = __ifaceNil
+         end
+         items[i - 1] = v
+      end
+      return anySlice(items)
+   elseif k == "n" then
+      return tonumber(node.v)
+   elseif k == "z" then
+      return nil
+   end
+   return node.v
+end
+
+------------------------------
+-- the package
+------------------------------
+
+local function marshal(v)
+   local out = {}
+   encode(v, dynamicType(v), out, {})
+   return table.concat(out)
+end
+
+json.Marshal = function(v)
+   local ok, s = pcall(marshal, v)
+   if not ok then
+      return byteSlice.__nil, failure(s)
+   end
+   return byteSlice(__stringToBytes(s)), nil
+end
+
+json.MarshalIndent = function(v, prefix, ind)
+   local ok, s = pcall(marshal, v)
+   if not ok then
+      return byteSlice.__nil, failure(s)
+   end
+   return byteSlice(__stringToBytes(indent(s, prefix, ind))), nil
+end
+
+json.Unmarshal = function(data, v)
+   local ok, e = pcall(function()
+      local root = parse(__bytesToString(data))
+      local typ = dynamicType(v)
+      if typ == nil then
+         fail("json: Unmarshal(nil)")
+      end
+      local d = setmetatable({keys = {}}, decoder)
+      if isStructValue(v) then
+         d:value(root, typ, v)
+      elseif typ.kind ~= __kindPtr then
+         fail("json: Unmarshal(non-pointer " .. typ.__str .. ")")
+      elseif v == typ.__nil then
+         fail("json: Unmarshal(nil " .. typ.__str .. ")")
+      else
+         v.__set(d:value(root, typ.elem, v.__get()))
+      end
+      if d.err ~= nil then
+         fail(d.err)
+      end
+   end)
+   if not ok then
+      return failure(e)
+   end
+   return nil
+end
+
+json.Valid = function(data)
+   return (pcall(parse, __bytesToString(data)))
+end
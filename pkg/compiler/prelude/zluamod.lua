@@ -0,0 +1,99 @@
+-- zluamod.lua: the runtime for `import "lua/..."`, which
+-- requires a Lua module and shows it to Go as a package;
+-- see pkg/compiler/luamod.go.
+--
+-- Go sees the module's functions as
+--
+--    func Name(args ...interface{}) interface{}
+--
+-- and its other values as interface{} variables, each under
+-- its Lua name with the first letter upper cased, so that Go
+-- may refer to it. A function returns its first result; the
+-- others are dropped.
+
+local package, require, __ffi = package, require, __ffi -- luaLockdown may remove the globals
+
+-- __luaModArg converts a Go argument to a Lua one: Go
+-- integers, which are int64 cdata, become Lua numbers.
+local function __luaModArg(v)
+   if v == __ifaceNil then
+      return nil
+   end
+   if type(v) == "cdata" and (__ffi.istype("int64_t", v) or __ffi.istype("uint64_t", v)) then
+      return tonumber(v)
+   end
+   return v
+end
+
+-- the functions __luaModFunc made, so that importing a
+-- module again does not wrap them twice.
+local __luaModWrapped = setmetatable({}, {__mode="k"})
+
+local function __luaModFunc(f)
+   local w = function(...)
+      local a, n = __gi_spreadArgs(...)
+      for i = 1, n do
+         a[i] = __luaModArg(a[i])
+      end
+      return (f(unpack(a, 1, n)))
+   end
+   __luaModWrapped[w] = true
+   return w
+end
+
+-- __gi_luaModGoName is the Go name for a Lua member k, or
+-- nil when Go cannot refer to it.
+function __gi_luaModGoName(k)
+   if type(k) ~= "string" or not string.match(k, "^%a[%w_]*$") then
+      return nil
+   end
+   return string.upper(string.sub(k, 1, 1)) .. string.sub(k, 2)
+end
+
+-- __gi_requireLua requires the Lua module mod and fills
+-- __packages[path] with its members, by their Go names,
+-- leaving the module itself, and the globals, as they were.
+-- It leaves the members in __gi_luaModMembers, as
+-- "Name=func" and "Name=var" words. With reload, it loads
+-- mod again from its file, as :reimport does, and the table
+-- loses the members the module no longer has; if that
+-- fails, the module already loaded stays.
+function __gi_requireLua(path, mod, reload)
+   local m
+   if reload then
+      local old = package.loaded[mod]
+      package.loaded[mod] = nil
+      local ok, res = pcall(require, mod)
+      if not ok then
+         package.loaded[mod] = old
+         error(res, 0)
+      end
+      m = res
+   else
+      m = require(mod)
+   end
+   if type(m) ~= "table" then
+      error("module '" .. mod .. "' is a " .. type(m) .. ", not a table of members", 0)
+   end
+   local pkg = __gi_package(path)
+   for k in pairs(pkg) do
+      pkg[k] = nil
+   end
+   local members = {}
+   for k, v in pairs(m) do
+      local g = __gi_luaModGoName(k)
+      -- a member already upper cased wins.
+      if g ~= nil and not __luaModWrapped[v] and
+         (g == k or m[g] == nil or __luaModWrapped[m[g]]) then
+         if type(v) == "function" then
+            pkg[g] = __luaModFunc(v)
+            members[#members + 1] = g .. "=func"
+         else
+            pkg[g] = v
+            members[#members + 1] = g .. "=var"
+         end
+      end
+   end
+   table.sort(members)
+   __gi_luaModMembers = table.concat(members, " ")
+end
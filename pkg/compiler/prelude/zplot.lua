@@ -0,0 +1,183 @@
+-- zplot.lua: the runtime for the gi/plot package, charts
+-- of slices for a quick look at data; see
+-- pkg/compiler/plot.go, which draws them. It loads after
+-- zdisplay.lua, whose Data a Plot displays as.
+--
+-- A Plot keeps its series, in the order added, in a Lua
+-- list on its struct; spec describes them to Go.
+
+local plot = __gi_package("gi/plot")
+local errors = __gi_package("errors")
+local display = __gi_package("gi/display")
+__type__["gi/plot"] = __type__["gi/plot"] or {}
+
+local Plot = __newType(0, __kindStruct, "plot.Plot", true, "gi/plot", true, nil)
+Plot.init("", {
+   {__prop="Title", __name="Title", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+   {__prop="XLabel", __name="XLabel", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+   {__prop="YLabel", __name="YLabel", __anonymous=false, __exported=true, __typ=__type__.string, __tag=""},
+   {__prop="Width", __name="Width", __anonymous=false, __exported=true, __typ=__type__.int, __tag=""},
+   {__prop="Height", __name="Height", __anonymous=false, __exported=true, __typ=__type__.int, __tag=""},
+})
+Plot.__constructor = function(title, xlabel, ylabel, width, height)
+   return {Title = title or "", XLabel = xlabel or "", YLabel = ylabel or "",
+           Width = width or int(0), Height = height or int(0)}
+end
+__type__["gi/plot"].Plot = Plot
+
+-- seriesOf returns the list of the series of the Plot p.
+local function seriesOf(p)
+   local target = rawget(p, "__target") or p
+   local s = rawget(target, "__series")
+   if s == nil then
+      s = {}
+      rawset(target, "__series", s)
+   end
+   return s
+end
+
+-- floatList returns the []float64 s as a Lua list.
+local function floatList(s)
+   local list = {}
+   for i = 0, s.__length - 1 do
+      list[i+1] = tonumber(s.__array[s.__offset + i])
+   end
+   return list
+end
+
+-- oneLine keeps the tabs and newlines of s out of a spec.
+local function oneLine(s)
+   return (string.gsub(s, "[\t\r\n]", " "))
+end
+
+local function numbers(list)
+   local out = {}
+   for i, v in ipairs(list) do
+      if v ~= v then
+         out[i] = "NaN"
+      elseif v == __builtin_math.huge then
+         out[i] = "+Inf"
+      elseif v == -__builtin_math.huge then
+         out[i] = "-Inf"
+      else
+         out[i] = string.format("%.17g", v)
+      end
+   end
+   return table.concat(out, ",")
+end
+
+-- spec describes the Plot p as parsePlotSpec in plot.go
+-- reads it.
+local function spec(p)
+   local lines = {
+      "title\t" .. oneLine(p.Title),
+      "xlabel\t" .. oneLine(p.XLabel),
+      "ylabel\t" .. oneLine(p.YLabel),
+      "size\t" .. tonumber(p.Width) .. "\t" .. tonumber(p.Height),
+   }
+   for _, s in ipairs(seriesOf(p)) do
+      table.insert(lines, s.kind .. "\t" .. oneLine(s.name) .. "\t" .. s.bins .. "\t" ..
+                      numbers(s.xs) .. "\t" .. numbers(s.ys))
+   end
+   return table.concat(lines, "\n")
+end
+
+local function render(p, format)
+   local img, errmsg = __gi_plotRender(spec(p), format)
+   if errmsg ~= "" then
+      error(errmsg, 0)
+   end
+   return img
+end
+
+local function addXY(p, kind, name, xs, ys)
+   local x, y = floatList(xs), floatList(ys)
+   if #x ~= #y then
+      error("plot: " .. kind .. " " .. name .. " has " .. #x .. " xs but " .. #y .. " ys", 0)
+   end
+   table.insert(seriesOf(p), {kind = kind, name = name, bins = 0, xs = x, ys = y})
+   return p
+end
+
+Plot.ptr.prototype.Line = function(this, name, xs, ys)
+   return addXY(this, "line", name, xs, ys)
+end
+
+Plot.ptr.prototype.Scatter = function(this, name, xs, ys)
+   return addXY(this, "scatter", name, xs, ys)
+end
+
+Plot.ptr.prototype.Histogram = function(this, name, values, bins)
+   table.insert(seriesOf(this), {kind = "hist", name = name, bins = tonumber(bins),
+                                 xs = floatList(values), ys = {}})
+   return this
+end
+
+Plot.ptr.prototype.SVG = function(this)
+   return render(this, "svg")
+end
+
+Plot.ptr.prototype.PNG = function(this)
+   return __sliceType(__type__.uint8)(__stringToBytes(render(this, "png")))
+end
+
+Plot.ptr.prototype.Save = function(this, path)
+   local _, errmsg = __gi_plotSave(spec(this), path)
+   if errmsg ~= "" then
+      return errors.New(errmsg)
+   end
+   return nil
+end
+
+Plot.ptr.prototype.Display = function(this)
+   local text = "<plot"
+   if this.Title ~= "" then
+      text = text .. " " .. this.Title
+   end
+   text = text .. ">"
+   if __gi_displayRich then
+      return display.MIME("image/svg+xml", render(this, "svg"), text):With("image/png", render(this, "png64"))
+   end
+   local path, errmsg = __gi_plotSave(spec(this), "")
+   if errmsg ~= "" then
+      return display.MIME("text/plain", text .. " not saved: " .. errmsg, text .. " not saved: " .. errmsg)
+   end
+   return display.MIME("text/plain", "plot saved to " .. path, "plot saved to " .. path)
+end
+
+local function method(name, params, results)
+   return {__prop=name, __name=name, __pkg="", __typ=__funcType(params, results, false)}
+end
+local floats = __sliceType(__type__.float64)
+Plot.ptr.__addToMethods(method("Line", {__type__.string, floats, floats}, {Plot.ptr}))
+Plot.ptr.__addToMethods(method("Scatter", {__type__.string, floats, floats}, {Plot.ptr}))
+Plot.ptr.__addToMethods(method("Histogram", {__type__.string, floats, __type__.int}, {Plot.ptr}))
+Plot.ptr.__addToMethods(method("SVG", {}, {__type__.string}))
+Plot.ptr.__addToMethods(method("PNG", {}, {__sliceType(__type__.uint8)}))
+Plot.ptr.__addToMethods(method("Save", {__type__.string}, {__error}))
+Plot.ptr.__addToMethods(method("Display", {}, {__type__["gi/display"].Data}))
+
+plot.New = function(title)
+   return Plot.ptrToNewlyConstructed(title)
+end
+
+-- indexes returns the []float64 0, 1, ..., n-1.
+local function indexes(n)
+   local a = {}
+   for i = 0, n - 1 do
+      a[i] = i
+   end
+   return floats(a)
+end
+
+plot.Line = function(ys)
+   return plot.New(""):Line("", indexes(ys.__length), ys)
+end
+
+plot.Scatter = function(xs, ys)
+   return plot.New(""):Scatter("", xs, ys)
+end
+
+plot.Histogram = function(values, bins)
+   return plot.New(""):Histogram("", values, bins)
+end
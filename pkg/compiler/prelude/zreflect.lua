@@ -0,0 +1,228 @@
+-- zreflect.lua: gi's side of package reflect, which is
+-- otherwise gc's own, run on the host; see the "reflect"
+-- case in pkg/compiler/import.go.
+--
+-- The host's reflect sees gi's values as Lua values, so
+-- reflect.TypeOf gives, for the values of gi's types, a
+-- view of their type descriptors instead: their names,
+-- kinds and struct fields, tags included. Struct tags
+-- are parsed here, for StructTag's Get and Lookup, and
+-- for encoding/json.
+
+-- __gi_structTagLookup returns the value under key in
+-- the struct tag tag, and whether it is there, by the
+-- conventions of Go's reflect.StructTag.Lookup.
+function __gi_structTagLookup(tag, key)
+   local i, n = 1, #tag
+   while i <= n do
+      i = tag:find("[^ ]", i)
+      if i == nil then
+         break
+      end
+      local j = i
+      while j <= n do
+         local c = tag:byte(j)
+         if c <= 0x20 or c == 0x3a or c == 0x22 or c == 0x7f then
+            break
+         end
+         j = j + 1
+      end
+      if j == i or j + 1 > n or tag:sub(j, j + 1) ~= ':"' then
+         break
+      end
+      local name = tag:sub(i, j - 1)
+      -- the quoted value starts at j+1.
+      local k = j + 2
+      while k <= n and tag:sub(k, k) ~= '"' do
+         if tag:sub(k, k) == "\\" then
+            k = k + 1
+         end
+         k = k + 1
+      end
+      if k > n then
+         break
+      end
+      if name == key then
+         local value = tag:sub(j + 2, k - 1):gsub("\\(.)", {
+            n = "\n", t = "\t", r = "\r", ['"'] = '"', ["\\"] = "\\", ["'"] = "'",
+         })
+         return value, true
+      end
+      i = k + 1
+   end
+   return "", false
+end
+
+-- __gi_structTagGet is reflect.StructTag.Get.
+function __gi_structTagGet(tag, key)
+   return (__gi_structTagLookup(tag, key))
+end
+
+local intSlice = __sliceType(__type__.int)
+
+-- typeViews holds the reflect.Type of each gi type, so
+-- that those of a same type compare equal.
+local typeViews = setmetatable({}, {__mode = "k"})
+
+local typeView
+
+local viewMT = {}
+viewMT.__index = function(t, k)
+   local m = viewMT[k]
+   if m ~= nil then
+      return m
+   end
+   error("reflect: " .. k .. " is not supported on gi's type " .. t.__gt.__str, 2)
+end
+viewMT.__tostring = function(t)
+   return t.__gt.__str
+end
+
+function viewMT:String()
+   return self.__gt.__str
+end
+
+function viewMT:Name()
+   local gt = self.__gt
+   if not gt.named then
+      return ""
+   end
+   return (gt.__str:gsub("^.*%.", ""))
+end
+
+function viewMT:PkgPath()
+   local gt = self.__gt
+   if not gt.named then
+      return ""
+   end
+   return gt.pkg or ""
+end
+
+function viewMT:Kind()
+   return uint64(self.__gt.kind)
+end
+
+function viewMT:Comparable()
+   return self.__gt.comparable ~= false
+end
+
+-- Elem of a struct gives the struct: gi holds a struct
+-- value as a pointer to it, so TypeOf has the same view
+-- of T{} as of &T{}, and code that reads the type of a
+-- pointer to a struct by TypeOf(p).Elem() works.
+function viewMT:Elem()
+   local gt = self.__gt
+   if gt.kind == __kindStruct then
+      return self
+   end
+   if gt.elem == nil then
+      error("reflect: Elem of invalid type " .. gt.__str, 2)
+   end
+   return typeView(gt.elem)
+end
+
+function viewMT:Key()
+   local gt = self.__gt
+   if gt.kind ~= __kindMap then
+      error("reflect: Key of non-map type " .. gt.__str, 2)
+   end
+   return typeView(gt.key)
+end
+
+function viewMT:Len()
+   local gt = self.__gt
+   if gt.kind ~= __kindArray then
+      error("reflect: Len of non-array type " .. gt.__str, 2)
+   end
+   return int64(gt.len)
+end
+
+local function structFields(self, method)
+   local gt = self.__gt
+   if gt.kind ~= __kindStruct then
+      error("reflect: " .. method .. " of non-struct type " .. gt.__str, 2)
+   end
+   return gt.fields
+end
+
+function viewMT:NumField()
+   return int64(#structFields(self, "NumField"))
+end
+
+local function structField(self, i, f)
+   local pkgPath = ""
+   if not f.__exported then
+      pkgPath = self.__gt.pkgPath or ""
+   end
+   return {
+      Name = f.__name,
+      PkgPath = pkgPath,
+      Type = typeView(f.__typ),
+      Tag = f.__tag,
+      Offset = 0ULL,
+      Index = intSlice({[0] = int64(i)}),
+      Anonymous = f.__anonymous,
+   }
+end
+
+function viewMT:Field(i)
+   local fields = structFields(self, "Field")
+   i = tonumber(i)
+   if i < 0 or i >= #fields then
+      error("reflect: Field index out of bounds", 2)
+   end
+   return structField(self, i, fields[i + 1])
+end
+
+function viewMT:FieldByName(name)
+   for i, f in ipairs(structFields(self, "FieldByName")) do
+      if f.__name == name then
+         return structField(self, i - 1, f), true
+      end
+   end
+   return {Name = "", PkgPath = "", Tag = "", Offset = 0ULL, Index = intSlice.__nil, Anonymous = false}, false
+end
+
+typeView = function(gt)
+   local t = typeViews[gt]
+   if t == nil then
+      t = setmetatable({__gt = gt}, viewMT)
+      typeViews[gt] = t
+   end
+   return t
+end
+
+-- giType is the gi type of v, or nil if v is not of one.
+local function giType(v)
+   if type(v) ~= "table" then
+      return nil
+   end
+   local ok, typ = pcall(function()
+      return rawget(v, "__typ") or (getmetatable(v) ~= nil and v.__typ)
+   end)
+   if not ok or type(typ) ~= "table" or typ.kind == nil then
+      return nil
+   end
+   if typ.kind == __kindPtr and rawget(v, "__name") == "__pointerToStructValue" then
+      return typ.elem
+   end
+   return typ
+end
+
+-- __gi_reflectShim returns the reflect package: Go's,
+-- given as host, with gi's TypeOf in front of it.
+function __gi_reflectShim(host)
+   if type(host) == "table" and rawget(host, "__host") ~= nil then
+      return host
+   end
+   return setmetatable({
+      __host = host,
+      TypeOf = function(v)
+         local gt = giType(v)
+         if gt ~= nil then
+            return typeView(gt)
+         end
+         return host.TypeOf(v)
+      end,
+   }, {__index = function(t, k) return host[k] end})
+end
@@ -0,0 +1,71 @@
+-- zruntime.lua: the parts of the runtime package that
+-- must see gi's goroutines and stacks, not the host's;
+-- see pkg/compiler/runtime.go. The rest is Go's own.
+
+-- the frames runtime.Caller has handed out, by pc.
+local callerPCs = {}
+local lastPC = 0
+
+local maxDepth = 64
+
+-- funcForPC is the *runtime.Func of a pc from Caller.
+local function funcForPC(pc)
+   local f = callerPCs[tonumber(pc)]
+   if f == nil then
+      return nil
+   end
+   return {
+      Name = function() return f.fn end,
+      Entry = function() return pc end,
+      FileLine = function(_) return f.file, int64(f.line) end,
+   }
+end
+
+-- __gi_runtimeShim returns the runtime package: Go's,
+-- given as host, with gi's functions in front of it.
+function __gi_runtimeShim(host)
+   local shim = {}
+
+   shim.NumGoroutine = function()
+      return int64(__gi_numGoroutine())
+   end
+
+   shim.Gosched = function()
+      __gi_gosched()
+   end
+
+   -- GC collects the Lua heap, then Go's, where the
+   -- proxies the Lua garbage held are now unreferenced.
+   shim.GC = function()
+      collectgarbage("collect")
+      host.GC()
+   end
+
+   -- Caller(0) is the function calling Caller.
+   shim.Caller = function(skip)
+      local fn, file, line, ok = __gi_runtimeCaller(__gi_stack(2, maxDepth), tonumber(skip))
+      if not ok then
+         return 0ULL, "", 0LL, false
+      end
+      lastPC = lastPC + 1
+      callerPCs[lastPC] = {fn = fn, file = file, line = line}
+      return 0ULL + lastPC, file, int64(line), true
+   end
+
+   shim.FuncForPC = funcForPC
+
+   shim.Stack = function(buf, all)
+      local s
+      if all then
+         s = __gi_runtimeStacks(__gi_goroutines(maxDepth))
+      else
+         local notes = __gi_goroutineNotes()
+         local id = notes and notes.__goid or 0
+         local created = notes and notes.__created or ""
+         s = __gi_runtimeStack(id, __gi_stack(2, maxDepth), created)
+      end
+      return int64(__copyString(buf, s))
+   end
+
+   return setmetatable(shim, {__index = host})
+end
@@ -0,0 +1,416 @@
+-- zsort.lua: the runtime for the sort package; see
+-- pkg/compiler/sort.go. It loads after tsys.lua, whose
+-- types it needs.
+--
+-- The algorithms work on Lua number indices, through a
+-- less(i, j) and a swap(i, j); the adapters below give Go
+-- code its ints. Sort is introsort: quicksort, falling back
+-- to heapsort when it goes too deep, with insertion sort
+-- for short runs. Stable is Go's: insertion sorted blocks,
+-- merged in place by SymMerge.
+
+local sort = __gi_package("sort")
+__type__.sort = __type__.sort or {}
+
+local floor = math.floor
+
+------------------------------
+-- algorithms
+------------------------------
+
+local function insertionSort(less, swap, a, b)
+   for i = a + 1, b - 1 do
+      local j = i
+      while j > a and less(j, j - 1) do
+         swap(j, j - 1)
+         j = j - 1
+      end
+   end
+end
+
+local function siftDown(less, swap, root, hi, first)
+   while true do
+      local child = 2 * root + 1
+      if child >= hi then
+         return
+      end
+      if child + 1 < hi and less(first + child, first + child + 1) then
+         child = child + 1
+      end
+      if not less(first + root, first + child) then
+         return
+      end
+      swap(first + root, first + child)
+      root = child
+   end
+end
+
+local function heapSort(less, swap, a, b)
+   local hi = b - a
+   for i = floor((hi - 1) / 2), 0, -1 do
+      siftDown(less, swap, i, hi, a)
+   end
+   for i = hi - 1, 0, -1 do
+      swap(a, a + i)
+      siftDown(less, swap, 0, i, a)
+   end
+end
+
+-- medianOfThree leaves the median of a, m and c at a.
+local function medianOfThree(less, swap, a, m, c)
+   if less(a, m) then
+      swap(a, m)
+   end
+   if less(c, a) then
+      swap(a, c)
+      if less(a, m) then
+         swap(a, m)
+      end
+   end
+end
+
+local function quickSort(less, swap, a, b, depth)
+   while b - a > 12 do
+      if depth == 0 then
+         heapSort(less, swap, a, b)
+         return
+      end
+      depth = depth - 1
+      medianOfThree(less, swap, a, a + floor((b - a) / 2), b - 1)
+      -- the pivot is at a; elements equal to it stop both
+      -- scans, which keeps runs of them balanced.
+      local i, j = a + 1, b - 1
+      while true do
+         while i <= j and less(i, a) do
+            i = i + 1
+         end
+         while i <= j and less(a, j) do
+            j = j - 1
+         end
+         if i >= j then
+            break
+         end
+         swap(i, j)
+         i = i + 1
+         j = j - 1
+      end
+      swap(a, j)
+      -- recurse into the shorter side, loop on the longer.
+      if j - a < b - j - 1 then
+         quickSort(less, swap, a, j, depth)
+         a = j + 1
+      else
+         quickSort(less, swap, j + 1, b, depth)
+         b = j
+      end
+   end
+   insertionSort(less, swap, a, b)
+end
+
+local function pdepth(n)
+   local d = 0
+   while n > 0 do
+      d = d + 1
+      n = floor(n / 2)
+   end
+   return 2 * d
+end
+
+local function sortN(less, swap, n)
+   quickSort(less, swap, 0, n, pdepth(n))
+end
+
+local function swapRange(swap, a, b, n)
+   for i = 0, n - 1 do
+      swap(a + i, b + i)
+   end
+end
+
+local function rotate(swap, a, m, b)
+   local i, j = m - a, b - m
+   while i ~= j do
+      if i > j then
+         swapRange(swap, m - i, m, j)
+         i = i - j
+      else
+         swapRange(swap, m - i, m + j - i, i)
+         j = j - i
+      end
+   end
+   swapRange(swap, m - i, m, i)
+end
+
+local function symMerge(less, swap, a, m, b)
+   if m - a == 1 then
+      local i, j = m, b
+      while i < j do
+         local h = floor((i + j) / 2)
+         if less(h, a) then
+            i = h + 1
+         else
+            j = h
+         end
+      end
+      for k = a, i - 2 do
+         swap(k, k + 1)
+      end
+      return
+   end
+   if b - m == 1 then
+      local i, j = a, m
+      while i < j do
+         local h = floor((i + j) / 2)
+         if not less(m, h) then
+            i = h + 1
+         else
+            j = h
+         end
+      end
+      for k = m, i + 1, -1 do
+         swap(k, k - 1)
+      end
+      return
+   end
+   local mid = floor((a + b) / 2)
+   local n = mid + m
+   local start, r
+   if m > mid then
+      start, r = n - b, mid
+   else
+      start, r = a, m
+   end
+   local p = n - 1
+   while start < r do
+      local c = floor((start + r) / 2)
+      if not less(p - c, c) then
+         start = c + 1
+      else
+         r = c
+      end
+   end
+   local stop = n - start
+   if start < m and m < stop then
+      rotate(swap, start, m, stop)
+   end
+   if a < start and start < mid then
+      symMerge(less, swap, a, start, mid)
+   end
+   if mid < stop and stop < b then
+      symMerge(less, swap, mid, stop, b)
+   end
+end
+
+local function stableN(less, swap, n)
+   local blockSize = 20
+   local a, b = 0, blockSize
+   while b <= n do
+      insertionSort(less, swap, a, b)
+      a = b
+      b = b + blockSize
+   end
+   insertionSort(less, swap, a, n)
+   while blockSize < n do
+      a, b = 0, 2 * blockSize
+      while b <= n do
+         symMerge(less, swap, a, a + blockSize, b)
+         a = b
+         b = b + 2 * blockSize
+      end
+      local m = a + blockSize
+      if m < n then
+         symMerge(less, swap, a, m, n)
+      end
+      blockSize = blockSize * 2
+   end
+end
+
+local function isSortedN(less, n)
+   for i = n - 1, 1, -1 do
+      if less(i, i - 1) then
+         return false
+      end
+   end
+   return true
+end
+
+------------------------------
+-- adapters
+------------------------------
+
+local function ifaceLess(data)
+   return function(i, j) return data:Less(int(i), int(j)) end
+end
+
+local function ifaceSwap(data)
+   return function(i, j) data:Swap(int(i), int(j)) end
+end
+
+local function checkSlice(x, fn)
+   if type(x) ~= "table" or rawget(x, "__array") == nil or rawget(x, "__offset") == nil then
+      __panic("sort." .. fn .. ": argument is not a slice")
+   end
+end
+
+-- sliceSwap swaps the elements of the slice x. Struct and
+-- array elements trade values, as Go's do, so a pointer to
+-- an element still points at its index.
+local function sliceSwap(x)
+   local arr, off = x.__array, x.__offset
+   local elem = x.__constructor.elem
+   if elem ~= nil and (elem.kind == __kindStruct or elem.kind == __kindArray) then
+      return function(i, j)
+         local a, b = arr[off + i], arr[off + j]
+         local tmp = __clone(a, elem)
+         elem.copy(a, b)
+         elem.copy(b, tmp)
+      end
+   end
+   return function(i, j)
+      arr[off + i], arr[off + j] = arr[off + j], arr[off + i]
+   end
+end
+
+local function sliceLess(less)
+   return function(i, j) return less(int(i), int(j)) end
+end
+
+local function elemLess(x)
+   local arr, off = x.__array, x.__offset
+   return function(i, j) return arr[off + i] < arr[off + j] end
+end
+
+-- float64s order NaNs first.
+local function floatLess(x)
+   local arr, off = x.__array, x.__offset
+   return function(i, j)
+      local a, b = arr[off + i], arr[off + j]
+      return a < b or (a ~= a and b == b)
+   end
+end
+
+local function searchN(n, f)
+   local i, j = 0, n
+   while i < j do
+      local h = floor((i + j) / 2)
+      if not f(h) then
+         i = h + 1
+      else
+         j = h
+      end
+   end
+   return i
+end
+
+------------------------------
+-- package functions
+------------------------------
+
+sort.Sort = function(data)
+   sortN(ifaceLess(data), ifaceSwap(data), tonumber(data:Len()))
+end
+
+sort.Stable = function(data)
+   stableN(ifaceLess(data), ifaceSwap(data), tonumber(data:Len()))
+end
+
+sort.IsSorted = function(data)
+   return isSortedN(ifaceLess(data), tonumber(data:Len()))
+end
+
+sort.Slice = function(x, less)
+   checkSlice(x, "Slice")
+   sortN(sliceLess(less), sliceSwap(x), x.__length)
+end
+
+sort.SliceStable = function(x, less)
+   checkSlice(x, "SliceStable")
+   stableN(sliceLess(less), sliceSwap(x), x.__length)
+end
+
+sort.SliceIsSorted = function(x, less)
+   checkSlice(x, "SliceIsSorted")
+   return isSortedN(sliceLess(less), x.__length)
+end
+
+sort.Search = function(n, f)
+   return int(searchN(tonumber(n), function(h) return f(int(h)) end))
+end
+
+sort.Find = function(n, cmp)
+   n = tonumber(n)
+   local i = searchN(n, function(h) return cmp(int(h)) <= 0 end)
+   return int(i), i < n and cmp(int(i)) == 0
+end
+
+sort.Ints = function(x)
+   sortN(elemLess(x), sliceSwap(x), x.__length)
+end
+
+sort.Strings = sort.Ints
+
+sort.Float64s = function(x)
+   sortN(floatLess(x), sliceSwap(x), x.__length)
+end
+
+sort.IntsAreSorted = function(x)
+   return isSortedN(elemLess(x), x.__length)
+end
+
+sort.StringsAreSorted = sort.IntsAreSorted
+
+sort.Float64sAreSorted = function(x)
+   return isSortedN(floatLess(x), x.__length)
+end
+
+local function searchSorted(a, x)
+   local arr, off = a.__array, a.__offset
+   return int(searchN(a.__length, function(h) return arr[off + h] >= x end))
+end
+
+sort.SearchInts = searchSorted
+sort.SearchStrings = searchSorted
+sort.SearchFloat64s = searchSorted
+
+------------------------------
+-- types
+------------------------------
+
+local function sliceType(name, elem, less, search)
+   local t = __newType(24, __kindSlice, "sort." .. name, true, "sort", true, nil)
+   t.init(elem)
+   t.prototype.Len = function(x) return int(x.__length) end
+   t.prototype.Less = function(x, i, j) return less(x)(tonumber(i), tonumber(j)) end
+   t.prototype.Swap = function(x, i, j) sliceSwap(x)(tonumber(i), tonumber(j)) end
+   t.prototype.Sort = function(x) sortN(less(x), sliceSwap(x), x.__length) end
+   t.prototype.Search = search
+   __type__.sort[name] = t
+end
+
+sliceType("IntSlice", __type__.int, elemLess, searchSorted)
+sliceType("Float64Slice", __type__.float64, floatLess, searchSorted)
+sliceType("StringSlice", __type__.string, elemLess, searchSorted)
+
+-- reverse is what Reverse returns: data, with Less
+-- turned around.
+local reverse = __newType(0, __kindStruct, "sort.reverse", true, "sort", false, nil)
+reverse.init("", {
+   {__prop="Interface", __name="Interface", __anonymous=true, __exported=true, __typ=__type__.emptyInterface, __tag=""},
+})
+reverse.__constructor = function(data)
+   return {Interface = data}
+end
+__type__.sort.reverse = reverse
+
+reverse.ptr.prototype.Len = function(this)
+   return this.Interface:Len()
+end
+reverse.ptr.prototype.Less = function(this, i, j)
+   return this.Interface:Less(j, i)
+end
+reverse.ptr.prototype.Swap = function(this, i, j)
+   this.Interface:Swap(i, j)
+end
+
+sort.Reverse = function(data)
+   return reverse.ptrToNewlyConstructed(data)
+end
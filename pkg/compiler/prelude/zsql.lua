@@ -0,0 +1,626 @@
+-- zsql.lua: the runtime for database/sql, with SQLite as
+-- its driver, called through LuaJIT's ffi; see
+-- pkg/compiler/sql.go. It loads after tsys.lua, whose types
+-- it needs.
+--
+-- The sqlite3 library is the system's, libsqlite3, not one
+-- built into gi; it is loaded on the first Open, which
+-- fails without it. Query
+-- arguments bind by their Go type; Scan converts each
+-- column to its destination's type, as SQLite converts
+-- between storage classes.
+
+local sql = __gi_package("database/sql")
+__type__["database/sql"] = __type__["database/sql"] or {}
+
+local ffi = __ffi
+
+ffi.cdef[[
+typedef struct sqlite3 sqlite3;
+typedef struct sqlite3_stmt sqlite3_stmt;
+int sqlite3_open_v2(const char *filename, sqlite3 **ppDb, int flags, const char *zVfs);
+int sqlite3_close_v2(sqlite3 *db);
+const char *sqlite3_errmsg(sqlite3 *db);
+int sqlite3_prepare_v2(sqlite3 *db, const char *zSql, int nByte, sqlite3_stmt **ppStmt, const char **pzTail);
+int sqlite3_step(sqlite3_stmt *stmt);
+int sqlite3_finalize(sqlite3_stmt *stmt);
+int sqlite3_bind_parameter_count(sqlite3_stmt *stmt);
+int sqlite3_bind_null(sqlite3_stmt *stmt, int i);
+int sqlite3_bind_int64(sqlite3_stmt *stmt, int i, int64_t v);
+int sqlite3_bind_double(sqlite3_stmt *stmt, int i, double v);
+int sqlite3_bind_text(sqlite3_stmt *stmt, int i, const char *v, int n, void (*d)(void*));
+int sqlite3_bind_blob(sqlite3_stmt *stmt, int i, const void *v, int n, void (*d)(void*));
+int sqlite3_column_count(sqlite3_stmt *stmt);
+const char *sqlite3_column_name(sqlite3_stmt *stmt, int i);
+int sqlite3_column_type(sqlite3_stmt *stmt, int i);
+int64_t sqlite3_column_int64(sqlite3_stmt *stmt, int i);
+double sqlite3_column_double(sqlite3_stmt *stmt, int i);
+const unsigned char *sqlite3_column_text(sqlite3_stmt *stmt, int i);
+const void *sqlite3_column_blob(sqlite3_stmt *stmt, int i);
+int sqlite3_column_bytes(sqlite3_stmt *stmt, int i);
+int sqlite3_changes(sqlite3 *db);
+int64_t sqlite3_last_insert_rowid(sqlite3 *db);
+]]
+
+local SQLITE_ROW = 100
+local SQLITE_DONE = 101
+local SQLITE_INTEGER, SQLITE_FLOAT, SQLITE_TEXT, SQLITE_BLOB, SQLITE_NULL = 1, 2, 3, 4, 5
+-- read-write, create, and accept file: URIs.
+local SQLITE_OPEN_FLAGS = 0x2 + 0x4 + 0x40
+-- have SQLite copy bound text and blobs.
+local SQLITE_TRANSIENT = ffi.cast("void(*)(void*)", -1)
+
+local __sqlDrivers = {"sqlite", "sqlite3"}
+
+local __sqlite
+
+-- __gi_sqliteLib returns the sqlite3 library, loading it
+-- if need be, or nil and why it cannot be loaded.
+function __gi_sqliteLib()
+   if __sqlite == nil then
+      local ok, lib = pcall(ffi.load, "sqlite3")
+      if not ok then
+         ok, lib = pcall(ffi.load, "libsqlite3.so.0")
+      end
+      if not ok then
+         return nil, lib
+      end
+      __sqlite = lib
+   end
+   return __sqlite
+end
+
+local function __sqlLib()
+   local lib, err = __gi_sqliteLib()
+   if lib == nil then
+      error("sql: cannot load the sqlite3 library, which database/sql runs on: " .. tostring(err), 0)
+   end
+   return lib
+end
+
+------------------------------
+-- errors
+------------------------------
+
+local sqlError = __newType(0, __kindStruct, "sql.sqlError", true, "database/sql", false, nil)
+sqlError.init("", {
+   {__prop="msg", __name="msg", __anonymous=false, __exported=false, __typ=__type__.string, __tag=""},
+})
+sqlError.__constructor = function(msg)
+   return {msg = msg or ""}
+end
+sqlError.ptr.prototype.Error = function(this)
+   return this.msg
+end
+__type__["database/sql"].sqlError = sqlError
+
+local function newError(msg)
+   return sqlError.ptrToNewlyConstructed(msg)
+end
+
+sql.ErrNoRows = newError("sql: no rows in result set")
+sql.ErrTxDone = newError("sql: transaction has already been committed or rolled back")
+
+-- __sqlTry runs f, and returns its result and a nil error,
+-- or zero and the error f raised, as a Go error.
+local function __sqlTry(zero, f, ...)
+   local ok, r = pcall(f, ...)
+   if ok then
+      return r, nil
+   end
+   if type(r) ~= "table" then
+      r = newError(tostring(r))
+   end
+   return zero, r
+end
+
+-- __sqlTryErr runs f, and returns the error it raised, or
+-- nil.
+local function __sqlTryErr(f, ...)
+   local _, err = __sqlTry(nil, f, ...)
+   return err
+end
+
+local function raise(msg)
+   error(newError(msg), 0)
+end
+
+local function dbError(h)
+   raise(ffi.string(__sqlLib().sqlite3_errmsg(h)))
+end
+
+------------------------------
+-- the Null types
+------------------------------
+
+local function nullType(name, prop, typ, zero)
+   local t = __newType(0, __kindStruct, "sql." .. name, true, "database/sql", true, nil)
+   t.init("", {
+      {__prop=prop, __name=prop, __anonymous=false, __exported=true, __typ=typ, __tag=""},
+      {__prop="Valid", __name="Valid", __anonymous=false, __exported=true, __typ=__type__.bool, __tag=""},
+   })
+   t.__constructor = function(v, valid)
+      local self = {}
+      self[prop] = v
+      if v == nil then
+         self[prop] = zero()
+      end
+      self.Valid = valid or false
+      return self
+   end
+   t.__sqlProp = prop
+   __type__["database/sql"][name] = t
+   return t
+end
+
+nullType("NullString", "String", __type__.string, function() return "" end)
+nullType("NullInt64", "Int64", __type__.int64, function() return int64(0) end)
+nullType("NullFloat64", "Float64", __type__.float64, function() return 0 end)
+nullType("NullBool", "Bool", __type__.bool, function() return false end)
+
+-- __sqlNullType returns the Null type of v, a pointer to one
+-- or a value of one, or nil.
+local function __sqlNullType(v)
+   local typ = type(v) == "table" and v.__typ
+   if not typ then
+      return nil
+   end
+   if typ.kind == __kindPtr then
+      typ = typ.elem
+   end
+   if rawget(typ, "__sqlProp") ~= nil and __type__["database/sql"][typ.__str:sub(5)] == typ then
+      return typ
+   end
+   return nil
+end
+
+------------------------------
+-- statements
+------------------------------
+
+-- __sqlBind binds the query arguments args[1..n] to stmt.
+local function __sqlBind(lib, stmt, args, n)
+   local want = lib.sqlite3_bind_parameter_count(stmt)
+   if want ~= n then
+      raise("sql: expected " .. want .. " arguments, got " .. n)
+   end
+   for i = 1, n do
+      local v = args[i]
+      local nt = __sqlNullType(v)
+      if nt ~= nil then
+         if v.Valid then
+            v = v[nt.__sqlProp]
+         else
+            v = nil
+         end
+      end
+      local rc
+      local tv = type(v)
+      if v == nil or v == __ifaceNil then
+         rc = lib.sqlite3_bind_null(stmt, i)
+      elseif tv == "cdata" then
+         rc = lib.sqlite3_bind_int64(stmt, i, v)
+      elseif tv == "number" then
+         rc = lib.sqlite3_bind_double(stmt, i, v)
+      elseif tv == "boolean" then
+         rc = lib.sqlite3_bind_int64(stmt, i, v and 1 or 0)
+      elseif tv == "string" then
+         rc = lib.sqlite3_bind_text(stmt, i, v, #v, SQLITE_TRANSIENT)
+      elseif tv == "table" and v.__typ ~= nil and v.__typ.kind == __kindSlice and v.__typ.elem.kind == __kindUint8 then
+         local s = __bytesToString(v)
+         rc = lib.sqlite3_bind_blob(stmt, i, s, #s, SQLITE_TRANSIENT)
+      else
+         local tn = tv
+         if tv == "table" and v.__typ ~= nil then
+            tn = v.__typ.__str
+         end
+         raise("sql: unsupported type " .. tn .. " for argument " .. i)
+      end
+      if rc ~= 0 then
+         raise("sql: cannot bind argument " .. i)
+      end
+   end
+end
+
+local function finalize(stmt)
+   __sqlLib().sqlite3_finalize(ffi.gc(stmt, nil))
+end
+
+-- __sqlPrepare prepares the first statement of query, from
+-- byte pos on, and returns it and the position of the rest;
+-- the statement is nil when only white space or comments
+-- remain.
+local function __sqlPrepare(db, query, pos)
+   local lib = __sqlLib()
+   local pstmt = ffi.new("sqlite3_stmt*[1]")
+   local tail = ffi.new("const char*[1]")
+   local base = ffi.cast("const char*", query)
+   if lib.sqlite3_prepare_v2(db.__h, base + pos, #query - pos, pstmt, tail) ~= 0 then
+      dbError(db.__h)
+   end
+   local next = tonumber(tail[0] - base)
+   if pstmt[0] == nil then
+      return nil, next
+   end
+   return ffi.gc(pstmt[0], lib.sqlite3_finalize), next
+end
+
+-- __sqlStep steps stmt, and says whether it has a row.
+local function __sqlStep(db, stmt)
+   local rc = __sqlLib().sqlite3_step(stmt)
+   if rc == SQLITE_ROW then
+      return true
+   elseif rc == SQLITE_DONE then
+      return false
+   end
+   dbError(db.__h)
+end
+
+------------------------------
+-- Result
+------------------------------
+
+local result = __newType(0, __kindStruct, "sql.result", true, "database/sql", false, nil)
+result.init("", {})
+result.__constructor = function(id, n)
+   return {__id = id, __n = n}
+end
+result.ptr.prototype.LastInsertId = function(this)
+   return this.__id, nil
+end
+result.ptr.prototype.RowsAffected = function(this)
+   return this.__n, nil
+end
+__type__["database/sql"].result = result
+
+-- __sqlExec runs each statement of query, binding args to
+-- the first, as the Go drivers do.
+local function __sqlExec(db, query, ...)
+   local args, n = __gi_spreadArgs(...)
+   local lib = __sqlLib()
+   local pos = 0
+   local first = true
+   while pos < #query do
+      local stmt
+      stmt, pos = __sqlPrepare(db, query, pos)
+      if stmt == nil then
+         break
+      end
+      if first then
+         __sqlBind(lib, stmt, args, n)
+         first = false
+      end
+      local ok, err = pcall(function()
+         while __sqlStep(db, stmt) do
+         end
+      end)
+      finalize(stmt)
+      if not ok then
+         error(err, 0)
+      end
+   end
+   if first and n > 0 then
+      raise("sql: expected 0 arguments, got " .. n)
+   end
+   return result.ptrToNewlyConstructed(int64(lib.sqlite3_last_insert_rowid(db.__h)), int64(lib.sqlite3_changes(db.__h)))
+end
+
+------------------------------
+-- Rows and Row
+------------------------------
+
+local Rows = __newType(0, __kindStruct, "sql.Rows", true, "database/sql", true, nil)
+Rows.init("", {})
+Rows.__constructor = function(db, stmt)
+   return {__db = db, __stmt = stmt, __row = false, __err = nil}
+end
+__type__["database/sql"].Rows = Rows
+
+-- __sqlQuery prepares query and binds args to it, without
+-- stepping it yet.
+local function __sqlQuery(db, query, ...)
+   local args, n = __gi_spreadArgs(...)
+   local stmt = __sqlPrepare(db, query, 0)
+   if stmt == nil then
+      raise("sql: the query has no statement")
+   end
+   local ok, err = pcall(__sqlBind, __sqlLib(), stmt, args, n)
+   if not ok then
+      finalize(stmt)
+      error(err, 0)
+   end
+   return Rows.ptrToNewlyConstructed(db, stmt)
+end
+
+Rows.ptr.prototype.Next = function(this)
+   local stmt = this.__stmt
+   if stmt == nil then
+      return false
+   end
+   local ok, row = pcall(__sqlStep, this.__db, stmt)
+   if not ok then
+      this.__err = row
+      row = false
+   end
+   this.__row = row
+   if not row then
+      this.__stmt = nil
+      finalize(stmt)
+   end
+   return row
+end
+
+Rows.ptr.prototype.Close = function(this)
+   local stmt = this.__stmt
+   if stmt ~= nil then
+      this.__stmt = nil
+      this.__row = false
+      finalize(stmt)
+   end
+   return nil
+end
+
+Rows.ptr.prototype.Err = function(this)
+   return this.__err
+end
+
+Rows.ptr.prototype.Columns = function(this)
+   local stmt = this.__stmt
+   if stmt == nil then
+      return __sliceType(__type__.string).__nil, newError("sql: Rows are closed")
+   end
+   local lib = __sqlLib()
+   local names = {}
+   for i = 0, lib.sqlite3_column_count(stmt) - 1 do
+      names[i] = ffi.string(lib.sqlite3_column_name(stmt, i))
+   end
+   return __sliceType(__type__.string)(names), nil
+end
+
+-- __sqlColumn reads column i of stmt as the Go type typ,
+-- and says whether it was NULL.
+local function __sqlColumn(lib, stmt, i, typ)
+   local ct = lib.sqlite3_column_type(stmt, i)
+   if ct == SQLITE_NULL then
+      return nil, true
+   end
+   local k = typ.kind
+   if k == __kindString then
+      return ffi.string(lib.sqlite3_column_text(stmt, i), lib.sqlite3_column_bytes(stmt, i)), false
+   elseif k == __kindBool then
+      return lib.sqlite3_column_int64(stmt, i) ~= 0, false
+   elseif k == __kindFloat32 or k == __kindFloat64 then
+      return lib.sqlite3_column_double(stmt, i), false
+   elseif k == __kindSlice and typ.elem.kind == __kindUint8 then
+      local p = lib.sqlite3_column_blob(stmt, i)
+      local s = ffi.string(p, lib.sqlite3_column_bytes(stmt, i))
+      return typ(__stringToBytes(s)), false
+   elseif k == __kindInterface then
+      -- by the column's storage class, as the Go driver
+      -- has it: int64, float64, string, or []byte.
+      if ct == SQLITE_INTEGER then
+         return int64(lib.sqlite3_column_int64(stmt, i)), false
+      elseif ct == SQLITE_FLOAT then
+         return lib.sqlite3_column_double(stmt, i), false
+      elseif ct == SQLITE_TEXT then
+         return ffi.string(lib.sqlite3_column_text(stmt, i), lib.sqlite3_column_bytes(stmt, i)), false
+      end
+      return __sqlColumn(lib, stmt, i, __sliceType(__type__.uint8))
+   end
+   local kt = __gi_kindCtype[k]
+   if kt == nil then
+      raise("sql: unsupported Scan type " .. typ.__str)
+   end
+   if ct ~= SQLITE_INTEGER then
+      raise("sql: converting column " .. i .. " to " .. typ.__str .. " is unsupported")
+   end
+   return kt(lib.sqlite3_column_int64(stmt, i)), false
+end
+
+-- __sqlScan stores the current row of stmt into dest.
+local function __sqlScan(stmt, ...)
+   local dest, n = __gi_spreadArgs(...)
+   local lib = __sqlLib()
+   local cols = lib.sqlite3_column_count(stmt)
+   if n ~= cols then
+      raise("sql: expected " .. cols .. " destination arguments in Scan, not " .. n)
+   end
+   for i = 1, n do
+      local d = dest[i]
+      local ptyp = type(d) == "table" and d.__typ
+      if not ptyp or ptyp.kind ~= __kindPtr then
+         raise("sql: Scan destination " .. i .. " is not a pointer")
+      end
+      local nt = __sqlNullType(d)
+      if nt ~= nil then
+         local prop = nt.__sqlProp
+         local v, null = __sqlColumn(lib, stmt, i - 1, nt.fields[1].__typ)
+         d.Valid = not null
+         if null then
+            d[prop] = nt.__constructor()[prop]
+         else
+            d[prop] = v
+         end
+      else
+         local v, null = __sqlColumn(lib, stmt, i - 1, ptyp.elem)
+         if null then
+            if ptyp.elem.kind == __kindInterface then
+               d.__set(nil)
+            elseif ptyp.elem.kind == __kindSlice then
+               d.__set(ptyp.elem.__nil)
+            else
+               raise("sql: converting NULL to " .. ptyp.elem.__str .. " is unsupported, in Scan destination " .. i)
+            end
+         else
+            d.__set(v)
+         end
+      end
+   end
+   return nil
+end
+
+Rows.ptr.prototype.Scan = function(this, ...)
+   if not this.__row then
+      return newError("sql: Scan called without calling Next")
+   end
+   return __sqlTryErr(__sqlScan, this.__stmt, ...)
+end
+
+local Row = __newType(0, __kindStruct, "sql.Row", true, "database/sql", true, nil)
+Row.init("", {})
+Row.__constructor = function(rows, err)
+   return {__rows = rows, __err = err}
+end
+__type__["database/sql"].Row = Row
+
+local function newRow(rows, err)
+   return Row.ptrToNewlyConstructed(rows, err)
+end
+
+Row.ptr.prototype.Err = function(this)
+   return this.__err
+end
+
+Row.ptr.prototype.Scan = function(this, ...)
+   if this.__err ~= nil then
+      return this.__err
+   end
+   local rows = this.__rows
+   if not rows:Next() then
+      return rows.__err or sql.ErrNoRows
+   end
+   local err = rows:Scan(...)
+   rows:Close()
+   return err
+end
+
+------------------------------
+-- DB and Tx
+------------------------------
+
+local DB = __newType(0, __kindStruct, "sql.DB", true, "database/sql", true, nil)
+DB.init("", {})
+DB.__constructor = function(h)
+   return {__h = h}
+end
+__type__["database/sql"].DB = DB
+
+local function checkOpen(db)
+   if db.__h == nil then
+      raise("sql: database is closed")
+   end
+end
+
+sql.Drivers = function()
+   return __sliceType(__type__.string)({[0]=__sqlDrivers[1], __sqlDrivers[2]})
+end
+
+sql.Open = function(driverName, dataSourceName)
+   if driverName ~= "sqlite3" and driverName ~= "sqlite" then
+      return DB.ptr.__nil, newError("sql: unknown driver \"" .. driverName .. "\" (forgotten import?)")
+   end
+   return __sqlTry(DB.ptr.__nil, function()
+      local lib = __sqlLib()
+      local ph = ffi.new("sqlite3*[1]")
+      local rc = lib.sqlite3_open_v2(dataSourceName, ph, SQLITE_OPEN_FLAGS, nil)
+      local h = ph[0]
+      if rc ~= 0 then
+         local msg = "sql: cannot open " .. dataSourceName
+         if h ~= nil then
+            msg = msg .. ": " .. ffi.string(lib.sqlite3_errmsg(h))
+            lib.sqlite3_close_v2(h)
+         end
+         raise(msg)
+      end
+      return DB.ptrToNewlyConstructed(ffi.gc(h, lib.sqlite3_close_v2))
+   end)
+end
+
+DB.ptr.prototype.Close = function(this)
+   local h = this.__h
+   if h ~= nil then
+      this.__h = nil
+      __sqlLib().sqlite3_close_v2(ffi.gc(h, nil))
+   end
+   return nil
+end
+
+DB.ptr.prototype.Ping = function(this)
+   return __sqlTryErr(checkOpen, this)
+end
+
+DB.ptr.prototype.Exec = function(this, query, ...)
+   local args = {...}
+   local n = select("#", ...)
+   return __sqlTry(nil, function()
+      checkOpen(this)
+      return __sqlExec(this, query, unpack(args, 1, n))
+   end)
+end
+
+DB.ptr.prototype.Query = function(this, query, ...)
+   local args = {...}
+   local n = select("#", ...)
+   return __sqlTry(Rows.ptr.__nil, function()
+      checkOpen(this)
+      return __sqlQuery(this, query, unpack(args, 1, n))
+   end)
+end
+
+DB.ptr.prototype.QueryRow = function(this, query, ...)
+   local rows, err = this:Query(query, ...)
+   return newRow(rows, err)
+end
+
+local Tx = __newType(0, __kindStruct, "sql.Tx", true, "database/sql", true, nil)
+Tx.init("", {})
+Tx.__constructor = function(db)
+   return {__db = db, __done = false}
+end
+__type__["database/sql"].Tx = Tx
+
+DB.ptr.prototype.Begin = function(this)
+   local _, err = this:Exec("BEGIN")
+   if err ~= nil then
+      return Tx.ptr.__nil, err
+   end
+   return Tx.ptrToNewlyConstructed(this), nil
+end
+
+Tx.ptr.prototype.Exec = function(this, query, ...)
+   if this.__done then
+      return nil, sql.ErrTxDone
+   end
+   return this.__db:Exec(query, ...)
+end
+
+Tx.ptr.prototype.Query = function(this, query, ...)
+   if this.__done then
+      return Rows.ptr.__nil, sql.ErrTxDone
+   end
+   return this.__db:Query(query, ...)
+end
+
+Tx.ptr.prototype.QueryRow = function(this, query, ...)
+   if this.__done then
+      return newRow(nil, sql.ErrTxDone)
+   end
+   return this.__db:QueryRow(query, ...)
+end
+
+local function endTx(tx, stmt)
+   if tx.__done then
+      return sql.ErrTxDone
+   end
+   tx.__done = true
+   local _, err = tx.__db:Exec(stmt)
+   return err
+end
+
+Tx.ptr.prototype.Commit = function(this)
+   return endTx(this, "COMMIT")
+end
+
+Tx.ptr.prototype.Rollback = function(this)
+   return endTx(this, "ROLLBACK")
+end
@@ -0,0 +1,80 @@
+-- zstrings.lua: strings.Builder, run in Lua; see
+-- pkg/compiler/strbuild.go. The rest of the strings package
+-- is Go's, bound through its shadow package, whose Lua
+-- replaces the Builder's constructor with __gi_newBuilder
+-- when it is imported.
+--
+-- A Builder keeps the strings written to it in a table, and
+-- concatenates them when String is called, keeping the
+-- result as its only part; so building a string costs time
+-- in proportion to its length, not to its square, as adding
+-- to a Lua string does. Compiled code calls its methods with
+-- a colon; so does Go, through the io.Writer it sees for a
+-- pointer to one, as for any interpreted writer.
+
+local builderMT = {}
+builderMT.__index = builderMT
+
+-- __gi_newBuilder returns a new, empty Builder.
+function __gi_newBuilder()
+   return setmetatable({parts = {}, n = 0, len = 0}, builderMT)
+end
+
+local function add(b, s)
+   local n = b.n + 1
+   b.parts[n] = s
+   b.n = n
+   b.len = b.len + #s
+end
+
+function builderMT:WriteString(s)
+   add(self, s)
+   return int(#s), nil
+end
+
+function builderMT:WriteByte(c)
+   add(self, string.char(tonumber(c)))
+   return nil
+end
+
+function builderMT:WriteRune(r)
+   local s = __encodeRune(r)
+   add(self, s)
+   return int(#s), nil
+end
+
+function builderMT:Write(p)
+   local s = __bytesToString(p)
+   add(self, s)
+   return int(#s), nil
+end
+
+function builderMT:String()
+   if self.n == 0 then
+      return ""
+   end
+   local s = table.concat(self.parts, "", 1, self.n)
+   self.parts = {s}
+   self.n = 1
+   return s
+end
+
+function builderMT:Len()
+   return int(self.len)
+end
+
+function builderMT:Cap()
+   return int(self.len)
+end
+
+function builderMT:Grow(n)
+   if n < 0 then
+      error("strings.Builder.Grow: negative count")
+   end
+end
+
+function builderMT:Reset()
+   self.parts = {}
+   self.n = 0
+   self.len = 0
+end
@@ -0,0 +1,335 @@
+package compiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	golua "github.com/glycerine/golua/lua"
+)
+
+// profMaxDepth is the deepest stack sampled.
+const profMaxDepth = 64
+
+// LuaJIT has one profiler per process, whose state is
+// not tied to a lua_State, so only one Interp at a time
+// may use it. profOwner is that Interp.
+var (
+	profOwnerMut sync.Mutex
+	profOwner    *Interp
+)
+
+// profState is the state of an Interp's profiler.
+type profState struct {
+	running  bool
+	interval time.Duration
+	start    time.Time
+}
+
+// ProfileSample is a stack, leaf first, and
+// the number of times it was sampled.
+type ProfileSample struct {
+	Stack []Frame
+	Count int
+}
+
+// Profile is a CPU profile of evaluated code.
+type Profile struct {
+	Samples  []ProfileSample
+	Interval time.Duration // between samples
+	Start    time.Time
+	Duration time.Duration
+}
+
+// StartProfile starts LuaJIT's sampling profiler, taking a
+// sample every interval, rounded to the millisecond. The
+// samples are mapped back to the Go functions and lines
+// they were translated from. Only one Interp in a process
+// can be profiled at a time.
+func (it *Interp) StartProfile(interval time.Duration) error {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.prof.running {
+		return fmt.Errorf("the profiler is already running")
+	}
+	profOwnerMut.Lock()
+	defer profOwnerMut.Unlock()
+	if profOwner != nil {
+		return fmt.Errorf("the profiler is in use by another Interp")
+	}
+	ms := int(interval / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	if err := LuaRun(it.lvm, fmt.Sprintf("__gi_profStart(%d, %d)", ms, profMaxDepth), false); err != nil {
+		return err
+	}
+	golua.SigprofOnStack()
+	profOwner = it
+	it.prof = profState{
+		running:  true,
+		interval: time.Duration(ms) * time.Millisecond,
+		start:    time.Now(),
+	}
+	return nil
+}
+
+// StopProfile stops the profiler, and returns what it sampled.
+func (it *Interp) StopProfile() (*Profile, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if !it.prof.running {
+		return nil, fmt.Errorf("the profiler is not running")
+	}
+	it.prof.running = false
+	defer it.releaseProfiler()
+	if err := LuaRun(it.lvm, `__gi_profOut = __gi_profStop()`, false); err != nil {
+		return nil, err
+	}
+	out := luaGlobalString(it.lvm, "__gi_profOut")
+	panicOn(LuaRun(it.lvm, `__gi_profOut = nil`, false))
+
+	p := &Profile{
+		Interval: it.prof.interval,
+		Start:    it.prof.start,
+		Duration: time.Since(it.prof.start),
+	}
+	for _, ln := range strings.Split(out, "\n") {
+		sp := strings.IndexByte(ln, ' ')
+		if sp < 0 {
+			continue
+		}
+		n, err := strconv.Atoi(ln[:sp])
+		if err != nil || sp+1 == len(ln) {
+			continue
+		}
+		s := ProfileSample{Count: n}
+		for _, fr := range strings.Split(ln[sp+1:], ";") {
+			f, _ := it.inc.srcMap.frame(fr)
+			s.Stack = append(s.Stack, f)
+		}
+		p.Samples = append(p.Samples, s)
+	}
+	return p, nil
+}
+
+// releaseProfiler gives up LuaJIT's profiler, if it holds it.
+func (it *Interp) releaseProfiler() {
+	profOwnerMut.Lock()
+	defer profOwnerMut.Unlock()
+	if profOwner == it {
+		profOwner = nil
+	}
+}
+
+// Total returns the number of samples taken.
+func (p *Profile) Total() (n int) {
+	for _, s := range p.Samples {
+		n += s.Count
+	}
+	return
+}
+
+// Top lists the n functions that were sampled
+// running the most, like pprof -top.
+func (p *Profile) Top(n int) string {
+	flat := make(map[string]int)
+	cum := make(map[string]int)
+	for _, s := range p.Samples {
+		if len(s.Stack) > 0 {
+			flat[s.Stack[0].Func] += s.Count
+		}
+		seen := make(map[string]bool)
+		for _, f := range s.Stack {
+			if !seen[f.Func] {
+				seen[f.Func] = true
+				cum[f.Func] += s.Count
+			}
+		}
+	}
+	var names []string
+	for name := range cum {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if flat[a] != flat[b] {
+			return flat[a] > flat[b]
+		}
+		if cum[a] != cum[b] {
+			return cum[a] > cum[b]
+		}
+		return a < b
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+
+	total := p.Total()
+	pct := func(x int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(x) / float64(total)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d samples, every %v, over %v\n", total, p.Interval, p.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "%8s %6s %8s %6s\n", "flat", "flat%", "cum", "cum%")
+	for _, name := range names {
+		fmt.Fprintf(&b, "%8d %5.1f%% %8d %5.1f%%  %s\n", flat[name], pct(flat[name]), cum[name], pct(cum[name]), name)
+	}
+	return b.String()
+}
+
+// protoBuf encodes the few protocol buffer
+// forms that pprof's profile.proto needs.
+type protoBuf struct {
+	b []byte
+}
+
+func (p *protoBuf) varint(x uint64) {
+	for x >= 0x80 {
+		p.b = append(p.b, byte(x)|0x80)
+		x >>= 7
+	}
+	p.b = append(p.b, byte(x))
+}
+
+func (p *protoBuf) int(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	p.varint(uint64(field) << 3)
+	p.varint(uint64(x))
+}
+
+func (p *protoBuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuf) packed(field int, xs []int64) {
+	var q protoBuf
+	for _, x := range xs {
+		q.varint(uint64(x))
+	}
+	p.bytes(field, q.b)
+}
+
+func (p *protoBuf) msg(field int, f func(q *protoBuf)) {
+	var q protoBuf
+	f(&q)
+	p.bytes(field, q.b)
+}
+
+// WritePprof writes p in the gzipped protocol
+// buffer format read by go tool pprof.
+func (p *Profile) WritePprof(w io.Writer) error {
+	var strs []string
+	strIndex := make(map[string]int64)
+	str := func(s string) int64 {
+		if i, ok := strIndex[s]; ok {
+			return i
+		}
+		strIndex[s] = int64(len(strs))
+		strs = append(strs, s)
+		return strIndex[s]
+	}
+	str("")
+
+	var pb protoBuf
+	valueType := func(field int, typ, unit string) {
+		pb.msg(field, func(q *protoBuf) {
+			q.int(1, str(typ))
+			q.int(2, str(unit))
+		})
+	}
+	valueType(1, "samples", "count")
+	valueType(1, "cpu", "nanoseconds")
+
+	type funcKey struct{ name, file string }
+	funcs := make(map[funcKey]int64)
+	var funcList []funcKey
+	locs := make(map[Frame]int64)
+	var locList []Frame
+	for _, s := range p.Samples {
+		ids := make([]int64, len(s.Stack))
+		for i, f := range s.Stack {
+			fk := funcKey{f.Func, f.File}
+			if _, ok := funcs[fk]; !ok {
+				funcList = append(funcList, fk)
+				funcs[fk] = int64(len(funcList))
+			}
+			if _, ok := locs[f]; !ok {
+				locList = append(locList, f)
+				locs[f] = int64(len(locList))
+			}
+			ids[i] = locs[f]
+		}
+		pb.msg(2, func(q *protoBuf) {
+			q.packed(1, ids)
+			q.packed(2, []int64{int64(s.Count), int64(s.Count) * int64(p.Interval)})
+		})
+	}
+	for i, f := range locList {
+		pb.msg(4, func(q *protoBuf) {
+			q.int(1, int64(i+1))
+			q.msg(4, func(l *protoBuf) {
+				l.int(1, funcs[funcKey{f.Func, f.File}])
+				l.int(2, int64(f.Line))
+			})
+		})
+	}
+	for i, fk := range funcList {
+		pb.msg(5, func(q *protoBuf) {
+			q.int(1, int64(i+1))
+			q.int(2, str(fk.name))
+			q.int(3, str(fk.name))
+			q.int(4, str(fk.file))
+		})
+	}
+	// the string table is complete only once everything
+	// else that names a string has been written.
+	var tail protoBuf
+	tail.int(9, p.Start.UnixNano())
+	tail.int(10, int64(p.Duration))
+	tail.msg(11, func(q *protoBuf) {
+		q.int(1, str("cpu"))
+		q.int(2, str("nanoseconds"))
+	})
+	tail.int(12, int64(p.Interval))
+	for _, s := range strs {
+		pb.bytes(6, []byte(s))
+	}
+	pb.b = append(pb.b, tail.b...)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(pb.b)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WritePprofFile writes p to path; see WritePprof.
+func (p *Profile) WritePprofFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
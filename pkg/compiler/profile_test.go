@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1322ProfileMapsSamplesToGoFunctions(t *testing.T) {
+
+	cv.Convey(`:profile samples running code with LuaJIT's profiler, reports the samples against the Go functions and lines they came from, and writes them in pprof's format`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.StartProfile(time.Millisecond))
+		panicOn(it.Eval(`func spin(n int) float64 {
+	s := 0.0
+	for i := 0; i < n; i++ {
+		s += float64(i) * 0.5
+	}
+	return s
+}`))
+		t0 := time.Now()
+		for time.Since(t0) < 300*time.Millisecond {
+			panicOn(it.Eval(`x := spin(1000000)`))
+		}
+		prof, err := it.StopProfile()
+		panicOn(err)
+		pp("profile:\n%s", prof.Top(10))
+
+		cv.So(prof.Total(), cv.ShouldBeGreaterThan, 10)
+		inSpin := 0
+		for _, s := range prof.Samples {
+			leaf := s.Stack[0]
+			if leaf.Func == "main.spin" {
+				inSpin += s.Count
+				cv.So(leaf.File, cv.ShouldEqual, "repl[1]")
+				cv.So(leaf.Line, cv.ShouldBeBetweenOrEqual, 2, 6)
+				cv.So(s.Stack[1].Func, cv.ShouldStartWith, "main.repl[")
+			}
+		}
+		cv.So(inSpin, cv.ShouldBeGreaterThan, prof.Total()/2)
+		cv.So(prof.Top(1), cv.ShouldContainSubstring, "  main.spin\n")
+
+		_, err = it.StopProfile()
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// LuaJIT has one profiler per process.
+		other, err := NewInterp(nil)
+		panicOn(err)
+		defer other.Close()
+		panicOn(it.StartProfile(time.Millisecond))
+		cv.So(other.StartProfile(time.Millisecond), cv.ShouldNotBeNil)
+		_, err = it.StopProfile()
+		panicOn(err)
+		panicOn(other.StartProfile(time.Millisecond))
+		_, err = other.StopProfile()
+		panicOn(err)
+
+		var buf bytes.Buffer
+		panicOn(prof.WritePprof(&buf))
+		zr, err := gzip.NewReader(&buf)
+		panicOn(err)
+		raw, err := ioutil.ReadAll(zr)
+		panicOn(err)
+		cv.So(bytes.Contains(raw, []byte("main.spin")), cv.ShouldBeTrue)
+		cv.So(bytes.Contains(raw, []byte("nanoseconds")), cv.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,114 @@
+package compiler
+
+import (
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// The type checker's Info, of identifiers and expressions
+// to what they denote, is kept across the inputs of a
+// session; inputs that fail to type check, or whose eval is
+// canceled, are forgotten. The methods below query it, for
+// tools such as linters and refactorings. The maps returned
+// are copies, and the ast nodes keys are those of the
+// inputs, located in their repl[N] files by Position.
+
+// Defs returns the identifiers of the session that
+// define objects, and the objects they define.
+func (it *Interp) Defs() map[*ast.Ident]types.Object {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	m := make(map[*ast.Ident]types.Object)
+	for id, obj := range it.typesInfo().Defs {
+		m[id] = obj
+	}
+	return m
+}
+
+// Uses returns the identifiers of the session that
+// refer to objects, and the objects they refer to.
+func (it *Interp) Uses() map[*ast.Ident]types.Object {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	m := make(map[*ast.Ident]types.Object)
+	for id, obj := range it.typesInfo().Uses {
+		m[id] = obj
+	}
+	return m
+}
+
+// TypeOf returns the type of e, an expression of one
+// of the session's inputs, or nil if it is not known.
+func (it *Interp) TypeOf(e ast.Expr) types.Type {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.typesInfo().TypeOf(e)
+}
+
+// ObjectOf returns the object id defines or refers
+// to, or nil if it is not known.
+func (it *Interp) ObjectOf(id *ast.Ident) types.Object {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.typesInfo().ObjectOf(id)
+}
+
+// Position locates pos, of an ast node or object
+// of the session, in its input.
+func (it *Interp) Position(pos token.Pos) token.Position {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.inc.CurPkg.fileSet.Position(pos)
+}
+
+func (it *Interp) typesInfo() *types.Info {
+	it.inc.pkgScope()
+	return it.inc.CurPkg.Arch.TypesInfo
+}
+
+// forgetLastInput drops what the type checker recorded
+// about the input last given to Tr, which did not go in.
+func (tr *IncrState) forgetLastInput() {
+	f := tr.lastFile
+	if f == nil || tr.CurPkg.Arch == nil {
+		return
+	}
+	tr.lastFile = nil
+	in := func(n ast.Node) bool {
+		p := int(n.Pos())
+		return f.Base() <= p && p <= f.Base()+f.Size()
+	}
+	info := tr.CurPkg.Arch.TypesInfo
+	for e := range info.Types {
+		if in(e) {
+			delete(info.Types, e)
+		}
+	}
+	for id, obj := range info.Defs {
+		if in(id) {
+			delete(tr.declSrc, obj)
+			delete(info.Defs, id)
+		}
+	}
+	for id := range info.Uses {
+		if in(id) {
+			delete(info.Uses, id)
+		}
+	}
+	for n := range info.Implicits {
+		if in(n) {
+			delete(info.Implicits, n)
+		}
+	}
+	for sel := range info.Selections {
+		if in(sel) {
+			delete(info.Selections, sel)
+		}
+	}
+	for n := range info.Scopes {
+		if in(n) {
+			delete(info.Scopes, n)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gijit/gi/pkg/ast"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1334SessionQueriesTheCheckerInfo(t *testing.T) {
+
+	cv.Convey(`Defs, Uses, TypeOf and ObjectOf answer from what the checker recorded across the inputs of a session, leaving out inputs that did not go in`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`x := 1`))
+		panicOn(it.Eval(`func double(n int) int { return 2 * n }
+y := double(x)`))
+
+		// by name, where in the inputs.
+		defs := make(map[string]string)
+		for id, obj := range it.Defs() {
+			if obj != nil {
+				defs[id.Name] = it.Position(id.Pos()).String()
+			}
+		}
+		cv.So(defs["x"], cv.ShouldEqual, "repl[1]:1:1")
+		cv.So(defs["double"], cv.ShouldEqual, "repl[2]:1:6")
+		cv.So(defs["y"], cv.ShouldEqual, "repl[2]:2:1")
+
+		var useOfX *ast.Ident
+		for id := range it.Uses() {
+			if id.Name == "x" {
+				useOfX = id
+			}
+		}
+		cv.So(useOfX, cv.ShouldNotBeNil)
+		cv.So(it.Position(useOfX.Pos()).String(), cv.ShouldEqual, "repl[2]:2:13")
+		obj := it.ObjectOf(useOfX)
+		cv.So(it.Position(obj.Pos()).String(), cv.ShouldEqual, "repl[1]:1:1")
+		cv.So(it.TypeOf(useOfX).String(), cv.ShouldEqual, "int")
+
+		// neither an input that fails to check, nor one
+		// that is canceled, leaves anything behind.
+		_, err = it.Translate(`bad := "a" + 1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = it.EvalContext(ctx, `canceled := 1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		for id := range it.Defs() {
+			cv.So(id.Name, cv.ShouldNotEqual, "bad")
+			cv.So(id.Name, cv.ShouldNotEqual, "canceled")
+		}
+	})
+}
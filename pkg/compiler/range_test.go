@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1362RangeOverStringsChannelsAndIntegers(t *testing.T) {
+
+	cv.Convey("range should decode a string by rune, receive from a channel until it is closed, and count up to an integer", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		// the key is the byte offset of each rune, and an
+		// invalid byte decodes as U+FFFD.
+		panicOn(it.Eval(`offsets, n := 0, 0
+var sum rune
+for i, r := range "日本語a" {
+	offsets += i
+	sum += r
+	n++
+}
+bad := 0
+for _, r := range "a\xffb" {
+	if r == 0xFFFD {
+		bad++
+	}
+}`))
+		LuaMustInt64(it.lvm, "offsets", 0+3+6+9)
+		LuaMustInt64(it.lvm, "sum", '日'+'本'+'語'+'a')
+		LuaMustInt64(it.lvm, "n", 4)
+		LuaMustInt64(it.lvm, "bad", 1)
+
+		// the loop ends when the channel is closed, and
+		// the code after it runs.
+		panicOn(it.Eval(`ch := make(chan int)
+go func() {
+	for i := 1; i <= 4; i++ {
+		ch <- i
+	}
+	close(ch)
+}()
+got := 0
+for v := range ch {
+	got += v
+}
+last, ok := <-ch
+after := true`))
+		LuaMustInt64(it.lvm, "got", 10)
+		LuaMustInt64(it.lvm, "last", 0)
+		LuaMustBool(it.lvm, "ok", false)
+		LuaMustBool(it.lvm, "after", true)
+
+		// range n, typed or not.
+		panicOn(it.Eval(`tot := 0
+for i := range 5 {
+	tot += i
+}
+var u8 uint8 = 4
+var top uint8
+for i := range u8 {
+	top = i
+}
+const N = 3
+times := 0
+for range N {
+	times++
+}`))
+		LuaMustInt64(it.lvm, "tot", 10)
+		LuaMustInt64(it.lvm, "times", 3)
+		panicOn(it.Eval(`topIs3 := top == 3`))
+		LuaMustBool(it.lvm, "topIs3", true)
+
+		err = it.Eval(`for i, j := range 5 { _, _ = i, j }`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "range over 5 (untyped int constant) permits only one iteration variable")
+
+		err = it.Eval(`close(ch)`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "close of closed channel")
+	})
+}
@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1345Regexp(t *testing.T) {
+
+	cv.Convey("regexp matches, finds submatches, and replaces as Go's does", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "regexp"`))
+		panicOn(it.Eval(`ok, err := regexp.MatchString("^a+b$", "aaab")`))
+		LuaMustBool(it.lvm, "ok", true)
+
+		panicOn(it.Eval(`re := regexp.MustCompile("(\\w+)=(\\d+)")
+all := re.FindAllStringSubmatch("x=1, y=22, z=333", -1)
+n := len(all)
+k := all[1][1]
+v := all[2][2]
+idx := re.FindStringIndex("  q=7")
+start := idx[0]`))
+		LuaMustInt(it.lvm, "n", 3)
+		LuaMustString(it.lvm, "k", "y")
+		LuaMustString(it.lvm, "v", "333")
+		LuaMustInt64(it.lvm, "start", 2)
+
+		panicOn(it.Eval(`out := re.ReplaceAllString("a=1 b=2", "$2:$1")`))
+		LuaMustString(it.lvm, "out", "1:a 2:b")
+
+		panicOn(it.Eval(`named := regexp.MustCompile("(?P<year>\\d{4})-(?P<month>\\d{2})")
+names := named.SubexpNames()
+ni := named.SubexpIndex("month")
+m := named.FindStringSubmatch("on 2024-05")
+month := m[ni]
+name2 := names[2]`))
+		LuaMustString(it.lvm, "month", "05")
+		LuaMustString(it.lvm, "name2", "month")
+
+		panicOn(it.Eval(`parts := regexp.MustCompile(",\\s*").Split("a, b,c", -1); np := len(parts); p2 := parts[2]`))
+		LuaMustInt(it.lvm, "np", 3)
+		LuaMustString(it.lvm, "p2", "c")
+	})
+
+	cv.Convey("a bad pattern is an error from Compile", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "regexp"`))
+		panicOn(it.Eval(`_, err := regexp.Compile("a(b"); es := err.Error()`))
+		LuaMustString(it.lvm, "es", "error parsing regexp: missing closing ): `a(b`")
+	})
+}
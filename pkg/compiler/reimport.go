@@ -0,0 +1,115 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// :reimport path loads a package again after its files were
+// edited, for iterating on a library while trying it at the
+// prompt. Of the packages gi imports, only Lua modules are
+// loaded from disk; shadowed packages and gi's own are
+// compiled into gi, which must be rebuilt to change them.
+//
+// The module is required again, and its members replace
+// those of the package the type checker already holds, so
+// the import stays in effect and later inputs see the new
+// members. The funcs and methods declared at the prompt that
+// use the package are then checked again, by evaluating
+// their source anew; one that no longer type checks keeps
+// its old definition, and is reported. Values already
+// computed from the old module, such as f := mod.F, keep
+// what they hold.
+
+// reimportUser is a func or method that uses a package
+// being imported again.
+type reimportUser struct {
+	obj  types.Object
+	name string
+	text string
+}
+
+// Reimport loads the Lua module of path again from its file,
+// and checks again the funcs and methods declared at the
+// prompt that use it. It returns the names of those that
+// still type check, with their new definitions in effect,
+// and an error naming any that do not.
+func (it *Interp) Reimport(path string) (rechecked []string, err error) {
+	it.mut.Lock()
+	if it.closed {
+		it.mut.Unlock()
+		return nil, fmt.Errorf("Interp is closed")
+	}
+	users, err := it.inc.reimport(path)
+	it.mut.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var broken []string
+	for _, u := range users {
+		if err := it.Eval(u.text); err != nil {
+			broken = append(broken, fmt.Sprintf("    %s: %v", u.name, err))
+			continue
+		}
+		rechecked = append(rechecked, u.name)
+	}
+	if len(broken) > 0 {
+		return rechecked, fmt.Errorf("reimported %s, but these no longer type check, and keep their old definitions:\n%s",
+			path, strings.Join(broken, "\n"))
+	}
+	return rechecked, nil
+}
+
+// reimport requires the module of path again and replaces
+// the members of its package. It returns the funcs and
+// methods that used the old members.
+func (ic *IncrState) reimport(path string) ([]reimportUser, error) {
+	pkg := ic.CurPkg.importContext.Packages[path]
+	if pkg == nil {
+		return nil, fmt.Errorf("%s is not imported", path)
+	}
+	if !isLuaModPath(path) {
+		return nil, fmt.Errorf("%s is compiled into gi, not loaded from disk; only Lua modules, lua/..., can be imported again", path)
+	}
+	users := ic.usersOf(pkg)
+	if err := ic.requireLuaModule(pkg, true); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// usersOf lists, in the order they were declared, the funcs
+// and methods in effect whose bodies refer to members of pkg.
+func (ic *IncrState) usersOf(pkg *types.Package) []reimportUser {
+	if ic.CurPkg.Arch == nil {
+		return nil
+	}
+	var uses []token.Pos
+	for id, obj := range ic.CurPkg.Arch.TypesInfo.Uses {
+		if obj.Pkg() == pkg {
+			uses = append(uses, id.Pos())
+		}
+	}
+	scope := ic.pkgScope()
+	var users []reimportUser
+	for obj, d := range ic.declSrc {
+		f, ok := obj.(*types.Func)
+		if !ok || !inEffect(scope, f) || f.Scope() == nil {
+			continue
+		}
+		body := f.Scope()
+		for _, pos := range uses {
+			if body.Pos() <= pos && pos <= body.End() {
+				users = append(users, reimportUser{obj: f, name: depName(f), text: d.text})
+				break
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].obj.Pos() < users[j].obj.Pos() })
+	return users
+}
@@ -0,0 +1,69 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1392ReimportALuaModuleAfterEditingIt(t *testing.T) {
+
+	cv.Convey(":reimport loads a Lua module again from its file, so the members it gained or lost show, and checks again the funcs that use it", t, func() {
+		dir, err := ioutil.TempDir("", "gi-reimport")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "gitestmod.lua")
+		panicOn(ioutil.WriteFile(file, []byte(`return {
+  greet = function(who) return "hello " .. who end,
+  twice = function(x) return 2 * x end,
+}`), 0644))
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(LuaRun(it.lvm, fmt.Sprintf(`package.path = %q .. "/?.lua;" .. package.path`, dir), false))
+
+		panicOn(it.Eval(`import "lua/gitestmod"`))
+		panicOn(it.Eval(`func hi() string { return gitestmod.Greet("gopher").(string) }`))
+		panicOn(it.Eval(`func dbl(x float64) float64 { return gitestmod.Twice(x).(float64) }`))
+		panicOn(it.Eval(`func other() int { return 1 }`))
+		panicOn(it.Eval(`a := hi()`))
+		LuaMustString(it.lvm, "a", "hello gopher")
+
+		panicOn(ioutil.WriteFile(file, []byte(`return {
+  greet = function(who) return "howdy " .. who end,
+  thrice = function(x) return 3 * x end,
+}`), 0644))
+
+		rechecked, err := it.Reimport("lua/gitestmod")
+		cv.So(rechecked, cv.ShouldResemble, []string{"hi"})
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "dbl: ")
+		cv.So(err.Error(), cv.ShouldContainSubstring, "Twice not declared by package gitestmod")
+
+		panicOn(it.Eval(`b := hi()`))
+		LuaMustString(it.lvm, "b", "howdy gopher")
+		panicOn(it.Eval(`c := gitestmod.Thrice(2).(float64)`))
+		LuaMustFloat64(it.lvm, "c", 6)
+		err = it.Eval(`d := gitestmod.Twice(2)`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "Twice not declared by package gitestmod")
+
+		// a module that no longer loads leaves the old in place.
+		panicOn(ioutil.WriteFile(file, []byte(`return {`), 0644))
+		_, err = it.Reimport("lua/gitestmod")
+		cv.So(err, cv.ShouldNotBeNil)
+		panicOn(it.Eval(`e := gitestmod.Thrice(3).(float64)`))
+		LuaMustFloat64(it.lvm, "e", 9)
+
+		_, err = it.Reimport("fmt")
+		cv.So(err.Error(), cv.ShouldEqual, "fmt is not imported")
+		panicOn(it.Eval(`import "fmt"`))
+		_, err = it.Reimport("fmt")
+		cv.So(err.Error(), cv.ShouldContainSubstring, "fmt is compiled into gi")
+	})
+}
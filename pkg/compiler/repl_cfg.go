@@ -2,7 +2,12 @@ package compiler
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/gijit/gi/pkg/gostd/build"
 	"github.com/gijit/gi/pkg/verb"
 )
 
@@ -19,13 +24,132 @@ type GIConfig struct {
 	NoLuar         bool
 
 	Dev bool // dev mode, don't use statically cached prelude
+
+	// the REPL says which prelude it loads before its
+	// prompt, so the vm loading it behind the prompt
+	// does not.
+	preludeAnnounced bool
+
+	// Per-eval resource limits, for running untrusted
+	// snippets. Zero means unlimited.
+	MaxEvalInstructions int64         // Lua VM instructions
+	MaxEvalHeapKB       int64         // growth of the Lua heap, in KB
+	MaxEvalTime         time.Duration // wall clock
+
+	// Policy, if not nil, limits what Go code at the
+	// prompt may reach on the host. SandboxAllow is
+	// the -allow flag, from which ValidateConfig
+	// builds a Policy.
+	Policy       *Policy
+	SandboxAllow string
+
+	// Deterministic pins random seeds, runs time.Now
+	// and time.Sleep on a logical clock, and ranges
+	// over maps in key order, so sessions replay
+	// exactly.
+	Deterministic bool
+
+	// Strict rejects, with an *ErrStrict, any input
+	// whose translation would only approximate gc,
+	// such as int8 arithmetic that does not wrap.
+	Strict bool
+
+	// Stats measures every eval: wall time, Lua heap
+	// growth, and Go allocations and collections.
+	Stats bool
+
+	// ScopeDiff prints, after each input, the names
+	// it added, redefined, or removed; see ScopeDiff.
+	ScopeDiff bool
+
+	// ShowMaxDepth and ShowMaxWidth limit how the value
+	// of an expression is echoed at the prompt: structs,
+	// maps, slices and arrays nested deeper are elided,
+	// and of longer ones only the first ShowMaxWidth
+	// elements are shown. Zero means no limit.
+	ShowMaxDepth int
+	ShowMaxWidth int
+
+	// MonkeyPatch lets methods be declared on the types
+	// of imported packages, with a warning, for trying
+	// out a method interactively.
+	MonkeyPatch bool
+
+	// NoColor turns off the ANSI colors of diagnostics.
+	// Colors, in the style of GCC_COLORS, sets them, as
+	// in "error=01;31:locus=01:caret=01;32"; ValidateConfig
+	// parses it, or else $GI_COLORS, into colors.
+	NoColor bool
+	Colors  string
+	colors  diagColors
+
+	// ScriptArgs, under gi run, is the script's command
+	// line, its name first. The session's os.Args is this,
+	// and flag.Parse reads the rest of it.
+	ScriptArgs []string
+
+	// DumbTerminal is for a program driving gi through a
+	// pipe, as Emacs' comint and org-babel do: lines are
+	// read plainly, without liner, colors or timings, and
+	// each input's output ends in a ResultMarker line.
+	// Prompt, if not empty, replaces "gi> ", and
+	// ResultMarker, if not empty, is printed after each
+	// input, followed by " ok" or " error"; see
+	// repl_protocol.go.
+	DumbTerminal bool
+	Prompt       string
+	ResultMarker string
+
+	// GOOS, GOARCH and BuildTags select the files of a
+	// package directory that gi loads, as gc would for that
+	// platform: by their //go:build lines and their _GOOS
+	// and _GOARCH name suffixes. ValidateConfig defaults
+	// GOOS and GOARCH to $GOOS and $GOARCH, else the host's.
+	GOOS      string
+	GOARCH    string
+	BuildTags string
+
+	// ShimDir is a directory of package shims, hand-written
+	// Lua implementations that gi imports in place of its
+	// own, under their import paths; see pkg/shim. Empty
+	// means $GI_SHIMS.
+	ShimDir string
+
+	// Plugins maps import paths to the Go plugins, built
+	// by gc, that their packages are loaded from, to run
+	// natively; see plugin.go. PluginSpec is the -plugins
+	// flag, from which ValidateConfig fills Plugins.
+	Plugins    map[string]string
+	PluginSpec string
+
+	// Emit is what the import cache keeps of the Lua each
+	// import runs: "" or "lua", nothing, the Lua being run
+	// as it is; "bytecode", the Lua compiled to LuaJIT
+	// bytecode, for imports in later sessions to load without
+	// parsing. Strip leaves the debug info out of it.
+	// ImportCache is the cache's directory; empty means
+	// $GI_IMPORT_CACHE, or else ~/.gijit.cache/imports. See
+	// importcache.go.
+	Emit        string
+	Strip       bool
+	ImportCache string
+
+	// Pipeline runs a large input, such as a pasted file,
+	// a chunk at a time, each as soon as it is translated,
+	// while the next one is. A chunk that then fails to
+	// translate, other than by a type error, undoes what
+	// the chunks before it declared, but not what they did
+	// when they ran; see pipeline.go.
+	Pipeline bool
 }
 
 var defaultTestMode bool // set to true by init() for tests, in repl_test.go.
 
 func NewGIConfig() *GIConfig {
 	return &GIConfig{
-		IsTestMode: defaultTestMode, // under tests, is set to true
+		IsTestMode:   defaultTestMode, // under tests, is set to true
+		ShowMaxDepth: DefaultShowMaxDepth,
+		ShowMaxWidth: DefaultShowMaxWidth,
 	}
 }
 
@@ -40,6 +164,31 @@ func (c *GIConfig) DefineFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&c.NoLiner, "no-liner", false, "turn off liner, e.g. under emacs")
 	fs.BoolVar(&c.NoPrelude, "np", false, "no prelude; skip loading the prelude .lua files and Luar. implies -r raw mode too.")
 	fs.BoolVar(&c.Dev, "d", false, "dev mode uses the pkg/compiler/prelude/*.lua files, skipping the statically cached pkg/compiler/prelude_static.go version.")
+	fs.Int64Var(&c.MaxEvalInstructions, "max-instr", 0, "abort any single eval that runs more than this many Lua VM instructions. 0 means no limit.")
+	fs.Int64Var(&c.MaxEvalHeapKB, "max-heap-kb", 0, "abort any single eval that grows the Lua heap by more than this many KB. 0 means no limit.")
+	fs.DurationVar(&c.MaxEvalTime, "max-eval-time", 0, "abort any single eval that runs longer than this, e.g. 5s. 0 means no limit.")
+	fs.BoolVar(&c.Deterministic, "deterministic", false, "deterministic mode: seeded math/rand, a logical clock for time.Now and time.Sleep, and sorted map iteration; for reproducible replays.")
+	fs.BoolVar(&c.Strict, "strict", false, "strict fidelity: reject, rather than approximate, Go whose semantics gi does not match exactly, e.g. int8 arithmetic that would not wrap, float32 precision, or reflect.")
+	fs.BoolVar(&c.Stats, "stats", false, "report wall time, Lua heap growth, and Go allocations and GC pauses after every eval.")
+	fs.BoolVar(&c.ScopeDiff, "diff", false, "after each input, list the names it added (+), redefined (~, with the old and new type), and removed (-).")
+	fs.IntVar(&c.ShowMaxDepth, "maxdepth", DefaultShowMaxDepth, "echo values at the prompt nested at most this deep; deeper structs, maps and slices show as T{...}. 0 means no limit. Also :set maxdepth.")
+	fs.IntVar(&c.ShowMaxWidth, "maxwidth", DefaultShowMaxWidth, "echo at most this many elements of a struct, map or slice at the prompt, and a count of the rest. 0 means no limit. Also :set maxwidth.")
+	fs.BoolVar(&c.MonkeyPatch, "monkey-patch", false, "allow methods to be declared on the types of imported packages, e.g. func (f *flag.FlagSet) Names() []string. They join the type for the rest of the process.")
+	fs.BoolVar(&c.NoColor, "no-color", os.Getenv("NO_COLOR") != "", "show diagnostics without ANSI colors. Also set by a non-empty $NO_COLOR.")
+	fs.StringVar(&c.Colors, "colors", "", "colors of diagnostics, as SGR parameters for error, locus and caret, e.g. 'error=01;31:locus=01:caret=01;32'. Default is $GI_COLORS, or else that.")
+	fs.BoolVar(&c.DumbTerminal, "dumb-terminal", false, "for driving gi from another program, e.g. Emacs comint or org-babel: plain line input, no banner, colors or timings, and a -result-marker line after each input's output. Implies -q, -no-liner and -no-color.")
+	fs.StringVar(&c.Prompt, "prompt", "", "the prompt to print in place of 'gi> '.")
+	fs.StringVar(&c.ResultMarker, "result-marker", "", "print this, then ' ok' or ' error', on a line of its own when each input is done. Default under -dumb-terminal is '"+DefaultResultMarker+"'.")
+	fs.StringVar(&c.GOOS, "goos", "", "load the files of package directories as for this GOOS, e.g. windows. Default is $GOOS, or else the host's.")
+	fs.StringVar(&c.GOARCH, "goarch", "", "load the files of package directories as for this GOARCH, e.g. arm64. Default is $GOARCH, or else the host's.")
+	fs.StringVar(&c.BuildTags, "tags", "", "comma separated build tags to satisfy when loading the files of package directories, as go build -tags.")
+	fs.StringVar(&c.ShimDir, "shims", "", "directory of package shims: hand-written Lua implementations, imported in place of gi's own, each in the directory of its import path, as dir/github.com/foo/fast. Default is $GI_SHIMS.")
+	fs.StringVar(&c.PluginSpec, "plugins", "", "comma separated import/path=file.so pairs: import each package from a Go plugin, built by gc from its gi shadow, to run natively.")
+	fs.StringVar(&c.Emit, "emit", "lua", "what the import cache keeps of the Lua each import runs: lua, which is nothing, or LuaJIT bytecode, for later sessions to load faster.")
+	fs.BoolVar(&c.Strip, "strip", false, "with -emit=bytecode, leave the debug info out of the cached bytecode.")
+	fs.StringVar(&c.ImportCache, "import-cache", "", "directory of the import cache, for -emit=bytecode. Default is $GI_IMPORT_CACHE, or else ~/.gijit.cache/imports.")
+	fs.BoolVar(&c.Pipeline, "pipeline", false, "run a large input, such as a pasted file, a chunk at a time, each as soon as it is translated. A later chunk that fails to translate, other than by a type error, undoes what the earlier ones declared, but not what they did.")
+	fs.StringVar(&c.SandboxAllow, "allow", "", "sandbox: comma separated capabilities to grant, from fs, net, exec, env, ffi; or none. Default is no sandbox.")
 }
 
 // call c.ValidateConfig() after myflags.Parse()
@@ -50,6 +199,79 @@ func (c *GIConfig) ValidateConfig() error {
 		c.RawLua = true
 	}
 
+	if c.DumbTerminal {
+		c.Quiet = true
+		c.NoLiner = true
+		c.NoColor = true
+		if c.ResultMarker == "" {
+			c.ResultMarker = DefaultResultMarker
+		}
+	}
+	if strings.ContainsAny(c.Prompt+c.ResultMarker, "\r\n") {
+		return fmt.Errorf("-prompt and -result-marker must be on one line")
+	}
+
+	if c.MaxEvalInstructions < 0 || c.MaxEvalHeapKB < 0 || c.MaxEvalTime < 0 {
+		return fmt.Errorf("-max-instr, -max-heap-kb and -max-eval-time must not be negative")
+	}
+	if c.ShowMaxDepth < 0 || c.ShowMaxWidth < 0 {
+		return fmt.Errorf("-maxdepth and -maxwidth must not be negative")
+	}
+	if c.NoPrelude && c.hasLimits() {
+		return fmt.Errorf("the per-eval limits need the prelude, and cannot be used with -np")
+	}
+
+	if c.SandboxAllow != "" {
+		caps, err := ParseCapabilities(c.SandboxAllow)
+		if err != nil {
+			return err
+		}
+		c.Policy = NewPolicy(caps)
+	}
+	if c.RawLua && !c.Policy.Allows(CapFFI) {
+		return fmt.Errorf("raw Lua mode needs the ffi capability")
+	}
+	switch c.Emit {
+	case "", "lua":
+		if c.Strip {
+			return fmt.Errorf("-strip is for -emit=bytecode")
+		}
+	case "bytecode":
+		if !c.Policy.Allows(CapFFI) {
+			return fmt.Errorf("-emit=bytecode needs the ffi capability, as loading bytecode does")
+		}
+	default:
+		return fmt.Errorf("unknown -emit '%s'; gi emits lua or bytecode", c.Emit)
+	}
+
+	if c.PluginSpec != "" {
+		plugins, err := parsePlugins(c.PluginSpec)
+		if err != nil {
+			return err
+		}
+		c.Plugins = plugins
+		if !c.Policy.Allows(CapFFI) {
+			return fmt.Errorf("-plugins needs the ffi capability")
+		}
+	}
+
+	spec := c.Colors
+	if spec == "" {
+		spec = os.Getenv("GI_COLORS")
+	}
+	colors, err := parseDiagColors(spec)
+	if err != nil {
+		return fmt.Errorf("-colors: %v", err)
+	}
+	c.colors = colors
+
+	if c.GOOS == "" {
+		c.GOOS = build.Default.GOOS
+	}
+	if c.GOARCH == "" {
+		c.GOARCH = build.Default.GOARCH
+	}
+
 	if c.PreludePath == "" {
 		// just use the statically embedded prelude from build time.
 	}
@@ -58,3 +280,33 @@ func (c *GIConfig) ValidateConfig() error {
 
 	return nil
 }
+
+// shimDir is the directory of package shims: ShimDir, else
+// $GI_SHIMS.
+func (c *GIConfig) shimDir() string {
+	if c != nil && c.ShimDir != "" {
+		return c.ShimDir
+	}
+	return os.Getenv("GI_SHIMS")
+}
+
+// buildContext selects the files of package directories
+// for c's GOOS, GOARCH and BuildTags. Files that import "C"
+// are left out, as gi cannot load them.
+func (c *GIConfig) buildContext() *build.Context {
+	ctxt := build.Default
+	ctxt.CgoEnabled = false
+	if c == nil {
+		return &ctxt
+	}
+	if c.GOOS != "" {
+		ctxt.GOOS = c.GOOS
+	}
+	if c.GOARCH != "" {
+		ctxt.GOARCH = c.GOARCH
+	}
+	ctxt.BuildTags = strings.FieldsFunc(c.BuildTags, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	return &ctxt
+}
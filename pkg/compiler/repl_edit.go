@@ -0,0 +1,78 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// :edit opens the user's editor on a buffer of Go, and runs
+// what was saved in it as one input when the editor exits,
+// for code too big for the line editor. :edit f starts the
+// buffer from the source of f, as :source f shows it, so
+// that f can be changed and redefined; :edit alone reopens
+// the last buffer edited, as after an input that failed.
+
+// editorCommand is the command line of the user's editor:
+// $VISUAL, else $EDITOR, else vi.
+func editorCommand() []string {
+	for _, v := range []string{"VISUAL", "EDITOR"} {
+		if f := strings.Fields(os.Getenv(v)); len(f) > 0 {
+			return f
+		}
+	}
+	return []string{"vi"}
+}
+
+// editText runs the user's editor on a temporary .go file
+// that holds text, and returns what was saved in it.
+func editText(text string) (string, error) {
+	f, err := ioutil.TempFile("", "gi-edit-*.go")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ed := editorCommand()
+	cmd := exec.Command(ed[0], append(ed[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s: %v; nothing is run", ed[0], err)
+	}
+	by, err := ioutil.ReadFile(f.Name())
+	return string(by), err
+}
+
+// edit implements :edit [name]. It returns the input to
+// run, which is empty if there is none.
+func (r *Repl) edit(name string) (string, error) {
+	text := r.lastEdit
+	if name != "" {
+		d, err := r.interp.Source(name)
+		if err != nil {
+			return "", err
+		}
+		text = d.Text + "\n"
+	}
+	src, err := editText(text)
+	if err != nil {
+		return "", err
+	}
+	r.lastEdit = src
+	if strings.TrimSpace(src) == "" {
+		fmt.Printf("the buffer is empty; nothing is run.\n")
+		return "", nil
+	}
+	// like a :{ region, the buffer is complete.
+	r.isRegion = true
+	return src, nil
+}
@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1382EditRunsTheSavedBuffer(t *testing.T) {
+
+	cv.Convey(":edit f opens $EDITOR on the source of f, and what is saved is run as one input", t, func() {
+		dir, err := ioutil.TempDir("", "gi-edit-test")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+
+		// an editor that keeps a copy of what it was given,
+		// then changes 2 * n to 3 * n.
+		script := filepath.Join(dir, "ed.sh")
+		panicOn(ioutil.WriteFile(script, []byte("cp \"$1\" "+dir+"/given\nsed -i 's/2 \\* n/3 * n/' \"$1\"\n"), 0755))
+		defer os.Setenv("VISUAL", os.Getenv("VISUAL"))
+		os.Setenv("VISUAL", "sh "+script)
+		cv.So(editorCommand(), cv.ShouldResemble, []string{"sh", script})
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		r := &Repl{interp: it, cfg: it.cfg}
+		panicOn(it.Eval("func twice(n int) int {\n\treturn 2 * n\n}"))
+
+		src, err := r.edit("twice")
+		panicOn(err)
+		given, err := ioutil.ReadFile(filepath.Join(dir, "given"))
+		panicOn(err)
+		cv.So(string(given), cv.ShouldEqual, "func twice(n int) int {\n\treturn 2 * n\n}\n")
+		cv.So(src, cv.ShouldEqual, "func twice(n int) int {\n\treturn 3 * n\n}\n")
+		cv.So(r.isRegion, cv.ShouldBeTrue)
+		panicOn(it.Eval(src))
+		var intr interruptWatcher
+		cv.So(evalCaptured(it, &intr, "x := twice(5)\nx").Output, cv.ShouldEqual, "15\n")
+
+		// :edit alone reopens the last buffer.
+		_, err = r.edit("")
+		panicOn(err)
+		given, err = ioutil.ReadFile(filepath.Join(dir, "given"))
+		panicOn(err)
+		cv.So(string(given), cv.ShouldEqual, src)
+
+		_, err = r.edit("nope")
+		cv.So(err.Error(), cv.ShouldEqual, "nope is not declared")
+
+		os.Setenv("VISUAL", "false")
+		_, err = r.edit("twice")
+		cv.So(err.Error(), cv.ShouldEqual, "editor false: exit status 1; nothing is run")
+	})
+}
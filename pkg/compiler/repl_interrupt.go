@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interruptWatcher decides what a Ctrl-C means. While an
+// eval runs, it cancels that eval with ErrInterrupted: the
+// Lua count hook raises an error that unwinds back to the
+// prompt, and the input's definitions are rolled back. At
+// an idle prompt, a second Ctrl-C in a row means quit.
+type interruptWatcher struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc // of the running eval, if any
+	idle   int                     // Ctrl-Cs in a row at the prompt
+}
+
+// beginEval returns the context for an eval, which a Ctrl-C
+// cancels; done must be called when the eval is over.
+func (w *interruptWatcher) beginEval(parent context.Context) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	w.mu.Lock()
+	w.cancel = cancel
+	w.idle = 0
+	w.mu.Unlock()
+	return ctx, func() {
+		w.mu.Lock()
+		w.cancel = nil
+		w.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// lineRead notes that the user typed something
+// other than Ctrl-C at the prompt.
+func (w *interruptWatcher) lineRead() {
+	w.mu.Lock()
+	w.idle = 0
+	w.mu.Unlock()
+}
+
+// interrupt handles one Ctrl-C. It reports whether an eval
+// was canceled, and whether the user asked to quit.
+func (w *interruptWatcher) interrupt() (canceled, quit bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		w.cancel(ErrInterrupted)
+		w.cancel = nil
+		return true, false
+	}
+	w.idle++
+	return false, w.idle > 1
+}
+
+// watchInterrupts takes SIGINT away from the Go runtime,
+// which would end the process, and acts on it. At the
+// prompt, liner reads Ctrl-C as a key instead, and Read
+// handles it; the signal only comes there under -no-liner.
+func (r *Repl) watchInterrupts() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		for range sig {
+			canceled, quit := r.intr.interrupt()
+			switch {
+			case canceled:
+			case quit:
+				fmt.Printf("\n[exit on ^C]\n")
+				os.Exit(0)
+			default:
+				fmt.Printf("\n(^C again to quit)\n%s", r.prompt)
+			}
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+package compiler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1325CtrlCInterruptsEvalNotSession(t *testing.T) {
+
+	cv.Convey(`Ctrl-C during an eval unwinds it back to the prompt and keeps the session; at an idle prompt only a second Ctrl-C quits`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`x := 1`))
+
+		var w interruptWatcher
+		ctx, done := w.beginEval(context.Background())
+		canceled := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			c, _ := w.interrupt()
+			canceled <- c
+		}()
+		err = it.EvalContext(ctx, `y := 0; for { y++ }`)
+		done()
+		cv.So(<-canceled, cv.ShouldBeTrue)
+		ec, isCancel := err.(*ErrEvalCanceled)
+		cv.So(isCancel, cv.ShouldBeTrue)
+		cv.So(ec.Cause, cv.ShouldEqual, ErrInterrupted)
+
+		// y is undone; x is untouched.
+		_, err = it.Translate(`q := y`)
+		cv.So(err, cv.ShouldNotBeNil)
+		panicOn(it.Eval(`z := x + 1`))
+		LuaMustInt64(it.lvm, "z", 2)
+
+		// at the prompt.
+		c, quit := w.interrupt()
+		cv.So(c, cv.ShouldBeFalse)
+		cv.So(quit, cv.ShouldBeFalse)
+		w.lineRead()
+		_, quit = w.interrupt()
+		cv.So(quit, cv.ShouldBeFalse)
+		_, quit = w.interrupt()
+		cv.So(quit, cv.ShouldBeTrue)
+	})
+}
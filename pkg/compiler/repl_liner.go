@@ -1,6 +1,8 @@
 package compiler
 
 import (
+	"strings"
+
 	"github.com/glycerine/liner"
 )
 
@@ -29,7 +31,12 @@ func NewPrompter(prompt string) *Prompter {
 	}
 	p.rawMode = rawMode
 
-	p.prompter.SetCtrlCAborts(false)
+	// so the Repl can count Ctrl-Cs at the prompt.
+	p.prompter.SetCtrlCAborts(true)
+
+	// a paste of many lines arrives as one input, run
+	// once it is all in, rather than a prompt per line.
+	p.prompter.SetBracketedPaste(true)
 
 	return p
 }
@@ -56,7 +63,9 @@ func (p *Prompter) Getline(prompt *string) (line string, err error) {
 		line, err = p.prompter.Prompt(*prompt)
 	}
 	if err == nil {
-		p.prompter.AppendHistory(line)
+		for _, l := range strings.Split(line, "\n") {
+			p.prompter.AppendHistory(l)
+		}
 		return line, nil
 	}
 	return "", err
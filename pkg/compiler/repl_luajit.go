@@ -3,6 +3,7 @@ package compiler
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -12,8 +13,10 @@ import (
 	"time"
 
 	"github.com/gijit/gi/pkg/front"
+	"github.com/gijit/gi/pkg/types"
 	"github.com/gijit/gi/pkg/verb"
 	golua "github.com/glycerine/golua/lua"
+	"github.com/glycerine/liner"
 )
 
 var p = verb.P
@@ -65,11 +68,12 @@ func (cfg *GIConfig) LuajitMain() {
 		done := make(chan bool)
 		var r *Repl
 		go func() {
-			r = NewRepl(cfg)
+			r = startRepl(cfg)
 
 			// in place of defer to cleanup:
 			go func() {
 				<-done
+				r.awaitInterp()
 				r.lvm.Close()
 				close(mainShutdown)
 			}()
@@ -85,8 +89,9 @@ func (cfg *GIConfig) LuajitMain() {
 
 	} else {
 
-		r := NewRepl(cfg)
+		r := startRepl(cfg)
 		defer func() {
+			r.awaitInterp()
 			r.lvm.Close()
 			close(mainShutdown)
 		}()
@@ -95,6 +100,8 @@ func (cfg *GIConfig) LuajitMain() {
 }
 
 type Repl struct {
+	interp *Interp
+
 	inc   *IncrState
 	vmCfg *GIConfig
 	lvm   *LuaVm
@@ -115,6 +122,9 @@ type Repl struct {
 	calcPrompt   string
 	isDo         bool
 	isSource     bool
+	isRegion     bool   // the input is complete as typed, as a :{ region is
+	failed       bool   // the last input failed
+	lastEdit     string // the buffer :edit saved last
 
 	prompter *Prompter
 	cfg      *GIConfig
@@ -123,21 +133,31 @@ type Repl struct {
 	prevSrc      string
 	prompterLine string
 	reader       *bufio.Reader
+
+	intr interruptWatcher
+
+	started  chan struct{} // closed once interp is made
+	startErr error
 }
 
+// NewRepl returns a REPL for cfg, its Interp ready.
 func NewRepl(cfg *GIConfig) *Repl {
+	r := startRepl(cfg)
+	r.awaitInterp()
+	return r
+}
 
-	// give a config so it knows we are
-	// running under `gi` cmd. Otherwise
-	// tests will assume nil means they
-	// need to start a new goroutine for
-	// non main work.
-	lvm, err := NewLuaVmWithPrelude(cfg)
-
-	panicOn(err)
-	inc := NewIncrState(lvm, cfg)
+// startRepl returns a REPL for cfg, its Interp still being
+// made in the background; see repl_startup.go.
+func startRepl(cfg *GIConfig) *Repl {
+	var err error
 
-	r := &Repl{cfg: cfg, lvm: lvm, inc: inc}
+	// the Interp shares the Repl's copy of cfg, so
+	// that mode changes like :r and == reach the translator.
+	mycfg := *cfg
+	r := &Repl{
+		cfg: &mycfg,
+	}
 	r.home = os.Getenv("HOME")
 	if r.home != "" {
 		r.histFn = r.home + string(os.PathSeparator) + ".gijit.hist"
@@ -159,6 +179,9 @@ func NewRepl(cfg *GIConfig) *Repl {
 
 	r.reader = bufio.NewReader(os.Stdin)
 	r.goPrompt = "gi> "
+	if r.cfg.Prompt != "" {
+		r.goPrompt = r.cfg.Prompt
+	}
 	r.calcPrompt = "calc mode> "
 	//r.goMorePrompt = ">>>    "
 	r.luaPrompt = "raw luajit gi> "
@@ -174,6 +197,11 @@ func NewRepl(cfg *GIConfig) *Repl {
 	r.setPrompt()
 	r.prevSrc = ""
 	r.prompterLine = ""
+	r.startInterp()
+	r.watchInterrupts()
+	if r.prompter != nil {
+		r.watchBackground()
+	}
 	return r
 }
 
@@ -184,10 +212,12 @@ func (r *Repl) Loop() {
 			return
 		}
 
+		failed := err != nil
 		err = r.Eval(src)
 		if err == io.EOF {
 			return
 		}
+		r.endResult(failed || err != nil || r.failed)
 	}
 }
 
@@ -198,7 +228,7 @@ func (r *Repl) Read() (src string, err error) {
 readtop:
 	if r.cfg.NoLiner {
 		if r.prompt != "" {
-			fmt.Printf(r.prompt)
+			fmt.Print(r.prompt)
 		}
 		by, err = r.reader.ReadBytes('\n')
 	} else {
@@ -211,16 +241,38 @@ readtop:
 			// process bytes first,
 			// return next time.
 			return
+		} else if r.prevSrc != "" {
+			// ctrl-d at a continuation, or the end of
+			// the input, runs what was typed as it is,
+			// for when the parser waits for more in vain.
+			if !r.cfg.NoLiner {
+				fmt.Printf("\n")
+			}
+			r.isRegion = true
+			return "", nil
 		} else {
 			fmt.Printf("[EOF]\n")
 			return "", err
 		}
 	}
+	if err == liner.ErrPromptAborted {
+		// liner has echoed the ^C.
+		if _, quit := r.intr.interrupt(); quit {
+			return "", io.EOF
+		}
+		fmt.Printf("(^C again to quit)\n")
+		goto readtop
+	}
 	panicOn(err)
+	r.intr.lineRead()
 	use := string(by)
 	src = use
 	cmd := bytes.TrimSpace(by)
 	low := string(bytes.ToLower(cmd))
+	r.isRegion = string(cmd) == ":{"
+	if r.isRegion {
+		return r.readRegion()
+	}
 	if len(low) > 1 && low[0] == ':' {
 		if low[:2] == "::" {
 			// likely the start of a lua label for a goto, not a special : command.
@@ -272,6 +324,333 @@ readtop:
 		}
 		return "", nil
 	}
+	if low == ":edit" || strings.HasPrefix(low, ":edit ") {
+		r.awaitInterp()
+		src, err = r.edit(strings.TrimSpace(string(cmd)[len(":edit"):]))
+		if err != nil {
+			fmt.Printf("edit error: %v\n", err)
+			return "", nil
+		}
+		return src, nil
+	}
+	if low == ":fmt" || strings.HasPrefix(low, ":fmt ") {
+		// format a block, up to a :} line, or the rest of
+		// the line, without running it.
+		block := strings.TrimSpace(string(cmd)[len(":fmt"):])
+		if block == "" {
+			block, err = r.readRegion()
+			if err != nil {
+				return "", nil
+			}
+		}
+		f, err := formatInput(block)
+		if err != nil {
+			fmt.Printf("fmt error: %v\n", err)
+			return "", nil
+		}
+		fmt.Print(f)
+		return "", nil
+	}
+	if commandNeedsInterp(low) {
+		r.awaitInterp()
+	}
+	if strings.HasPrefix(low, ":save ") || strings.HasPrefix(low, ":restore ") {
+		// session images. Keep the case of the path.
+		fields := strings.Fields(string(cmd))
+		if len(fields) != 2 {
+			fmt.Printf("usage: %s <path>\n", fields[0])
+			return "", nil
+		}
+		path := fields[1]
+		if strings.HasPrefix(path, "~/") {
+			path = os.Getenv("HOME") + path[1:]
+		}
+		if low[:5] == ":save" {
+			skipped, err := r.interp.SaveImageFile(path)
+			if err != nil {
+				fmt.Printf("error saving session image: '%v'\n", err)
+				return "", nil
+			}
+			for _, s := range skipped {
+				fmt.Printf("not saved: %s\n", s)
+			}
+			fmt.Printf("session image saved to '%s'.\n", path)
+		} else {
+			if err := r.interp.LoadImageFile(path); err != nil {
+				fmt.Printf("error restoring session image: '%v'\n", err)
+				return "", nil
+			}
+			fmt.Printf("session image restored from '%s'.\n", path)
+		}
+		return "", nil
+	}
+	if strings.HasPrefix(low, ":timeit ") {
+		r.timeit(strings.TrimSpace(string(cmd)[len(":timeit "):]))
+		return "", nil
+	}
+	if low == ":goroutines" || strings.HasPrefix(low, ":goroutines ") {
+		args := strings.Fields(low[len(":goroutines"):])
+		if len(args) == 2 && args[0] == "kill" {
+			id, err := strconv.Atoi(args[1])
+			if err == nil {
+				err = r.interp.KillGoroutine(id)
+			}
+			if err != nil {
+				fmt.Printf("goroutines error: '%v'\n", err)
+			} else {
+				fmt.Printf("killed goroutine %d.\n", id)
+			}
+			return "", nil
+		}
+		if len(args) != 0 {
+			fmt.Printf("usage: :goroutines | :goroutines kill <id>\n")
+			return "", nil
+		}
+		gs, err := r.interp.Goroutines()
+		if err != nil {
+			fmt.Printf("goroutines error: '%v'\n", err)
+			return "", nil
+		}
+		if len(gs) == 0 {
+			fmt.Printf("no goroutines.\n")
+		}
+		for i := range gs {
+			fmt.Printf("%v\n", &gs[i])
+		}
+		return "", nil
+	}
+	if strings.HasPrefix(low, ":why ") {
+		expr := strings.TrimSpace(string(cmd)[len(":why "):])
+		why, err := r.interp.Why(expr)
+		fmt.Print(why)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		return "", nil
+	}
+	if strings.HasPrefix(low, ":methods ") {
+		ms, err := r.interp.Methods(strings.TrimSpace(string(cmd)[len(":methods "):]))
+		if err != nil {
+			fmt.Printf("methods error: %v\n", err)
+			return "", nil
+		}
+		fmt.Print(ms)
+		return "", nil
+	}
+	if strings.HasPrefix(low, ":implements ") {
+		args := strings.Fields(string(cmd)[len(":implements "):])
+		if len(args) != 2 {
+			fmt.Printf("usage: :implements <type> <interface>\n")
+			return "", nil
+		}
+		_, report, err := r.interp.Implements(args[0], args[1])
+		if err != nil {
+			fmt.Printf("implements error: %v\n", err)
+			return "", nil
+		}
+		fmt.Print(report)
+		return "", nil
+	}
+	if low == ":diff" || strings.HasPrefix(low, ":diff ") {
+		switch strings.TrimSpace(low[len(":diff"):]) {
+		case "on":
+			r.cfg.ScopeDiff = true
+		case "off":
+			r.cfg.ScopeDiff = false
+		case "":
+			r.cfg.ScopeDiff = !r.cfg.ScopeDiff
+		default:
+			fmt.Printf("usage: :diff [on|off]\n")
+			return "", nil
+		}
+		if r.cfg.ScopeDiff {
+			fmt.Printf("scope diffs on: each input is followed by the names it changed.\n")
+		} else {
+			fmt.Printf("scope diffs off.\n")
+		}
+		return "", nil
+	}
+	if low == ":set" || strings.HasPrefix(low, ":set ") {
+		args := strings.Fields(low[len(":set"):])
+		depth, width := r.interp.ShowLimits()
+		if len(args) > 0 {
+			n := -1
+			if len(args) == 2 {
+				if v, err := strconv.Atoi(args[1]); err == nil {
+					n = v
+				}
+			}
+			switch {
+			case n >= 0 && args[0] == "maxdepth":
+				depth = n
+			case n >= 0 && args[0] == "maxwidth":
+				width = n
+			default:
+				fmt.Printf("usage: :set [maxdepth|maxwidth <n>]\n")
+				return "", nil
+			}
+			if err := r.interp.SetShowLimits(depth, width); err != nil {
+				fmt.Printf("set error: %v\n", err)
+				return "", nil
+			}
+			r.cfg.ShowMaxDepth, r.cfg.ShowMaxWidth = depth, width
+		}
+		fmt.Printf("maxdepth %d\nmaxwidth %d\n", depth, width)
+		return "", nil
+	}
+	if strings.HasPrefix(low, ":reimport ") {
+		path := strings.Trim(strings.TrimSpace(string(cmd)[len(":reimport "):]), "\"")
+		rechecked, err := r.interp.Reimport(path)
+		if len(rechecked) > 0 {
+			fmt.Printf("checked again: %s\n", strings.Join(rechecked, ", "))
+		}
+		if err != nil {
+			fmt.Printf("reimport error: %v\n", err)
+		}
+		return "", nil
+	}
+	if low == ":packages" {
+		ps := r.interp.Packages()
+		if len(ps) == 0 {
+			fmt.Printf("no packages imported.\n")
+			return "", nil
+		}
+		fmt.Printf("%-28s %-6s %-12s %10s %10s\n", "PACKAGE", "FROM", "VERSION", "IMPORT", "LUA")
+		for i := range ps {
+			fmt.Printf("%s\n", &ps[i])
+		}
+		return "", nil
+	}
+	if low == ":jobs" {
+		gs, err := r.interp.Goroutines()
+		if err != nil {
+			fmt.Printf("jobs error: '%v'\n", err)
+			return "", nil
+		}
+		if len(gs) == 0 {
+			fmt.Printf("no jobs.\n")
+		}
+		for i := range gs {
+			fmt.Printf("%s\n", jobLine(&gs[i]))
+		}
+		return "", nil
+	}
+	if low == ":fg" || strings.HasPrefix(low, ":fg ") {
+		args := strings.Fields(low[len(":fg"):])
+		id := 0
+		if len(args) > 1 {
+			fmt.Printf("usage: :fg [id]\n")
+			return "", nil
+		}
+		if len(args) == 1 {
+			var err error
+			id, err = strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Printf("usage: :fg [id]\n")
+				return "", nil
+			}
+		}
+		ctx, done := r.intr.beginEval(context.Background())
+		err := r.interp.Foreground(ctx, id)
+		done()
+		if err != nil {
+			fmt.Printf("fg: %v\n", err)
+			return "", nil
+		}
+		if id == 0 {
+			fmt.Printf("no goroutine can run.\n")
+			return "", nil
+		}
+		gs, err := r.interp.Goroutines()
+		if err != nil {
+			fmt.Printf("fg: %v\n", err)
+			return "", nil
+		}
+		state := "done"
+		for i := range gs {
+			if gs[i].ID == id {
+				state = gs[i].State
+			}
+		}
+		fmt.Printf("goroutine %d: %s.\n", id, state)
+		return "", nil
+	}
+	if low == ":profile" || strings.HasPrefix(low, ":profile ") {
+		args := strings.Fields(string(cmd)[len(":profile"):])
+		switch {
+		case len(args) > 0 && args[0] == "start":
+			interval := time.Millisecond
+			if len(args) > 1 {
+				ms, err := strconv.Atoi(args[1])
+				if err != nil || ms < 1 {
+					fmt.Printf("profile error: bad interval '%s'; give whole milliseconds.\n", args[1])
+					return "", nil
+				}
+				interval = time.Duration(ms) * time.Millisecond
+			}
+			if err := r.interp.StartProfile(interval); err != nil {
+				fmt.Printf("profile error: '%v'\n", err)
+				return "", nil
+			}
+			fmt.Printf("profiling, every %v.\n", interval)
+		case len(args) > 0 && args[0] == "stop":
+			path := "gijit.pprof"
+			if len(args) > 1 {
+				path = args[1]
+			}
+			prof, err := r.interp.StopProfile()
+			if err == nil {
+				err = prof.WritePprofFile(path)
+			}
+			if err != nil {
+				fmt.Printf("profile error: '%v'\n", err)
+				return "", nil
+			}
+			fmt.Printf("%vprofile written to '%s'; see it with: go tool pprof %s\n", prof.Top(10), path, path)
+		default:
+			fmt.Printf("usage: :profile start [ms] | :profile stop [file]\n")
+		}
+		return "", nil
+	}
+	if low == ":explain" || strings.HasPrefix(low, ":explain ") {
+		s, err := ExplainDivergence(strings.TrimSpace(string(cmd)[len(":explain"):]))
+		if err != nil {
+			fmt.Printf("explain error: %v\n", err)
+			return "", nil
+		}
+		fmt.Print(s)
+		return "", nil
+	}
+	if low == ":coverage" || strings.HasPrefix(low, ":coverage ") {
+		arg := strings.TrimSpace(string(cmd)[len(":coverage"):])
+		switch {
+		case arg == "on":
+			r.interp.StartCoverage()
+			fmt.Printf("coverage on: statements entered from now on are counted.\n")
+		case strings.HasPrefix(arg, "html "):
+			prof, err := r.interp.Coverage()
+			if err == nil && prof == nil {
+				err = fmt.Errorf("coverage is off; turn it on with ':coverage on'")
+			}
+			if err == nil {
+				err = prof.WriteHTMLFile(strings.TrimSpace(arg[len("html "):]))
+			}
+			if err != nil {
+				fmt.Printf("coverage error: '%v'\n", err)
+			}
+		default:
+			prof, err := r.interp.Coverage()
+			switch {
+			case err != nil:
+				fmt.Printf("coverage error: '%v'\n", err)
+			case prof == nil:
+				fmt.Printf("coverage is off; turn it on with ':coverage on'\n")
+			default:
+				fmt.Printf("%v", prof)
+			}
+		}
+		return "", nil
+	}
 	switch low {
 	case ":ast":
 		r.inc.PrintAST = true
@@ -349,6 +728,10 @@ readtop:
 		showLuaStacks(r.lvm.vm)
 		goto readtop
 	case ":r":
+		if !r.cfg.Policy.Allows(CapFFI) {
+			fmt.Printf("Raw LuaJIT mode is denied by the sandbox policy.\n")
+			goto readtop
+		}
 		r.cfg.RawLua = true
 		r.cfg.CalculatorMode = false
 		r.prompt = r.luaPrompt
@@ -406,17 +789,83 @@ these special commands.
  :rm 3-4         Remove commands 3-4 from history.
  :do <path>      Run dofile(path) on a .lua file.
  :source <path>  Re-play Go code from a file.
+ :source f       Show the source of f, a func, type, var or const, or a
+                 method as T.Method, and the input that declared it.
+ :edit [f]       Edit a buffer in $EDITOR, and run it when the editor
+                 exits; :edit f starts from the source of f, and :edit
+                 alone reopens the last buffer.
+ :fmt [code]     Show code, or the lines up to a line of :}, formatted
+                 as gofmt would, without running it. Inputs are
+                 formatted so before they are run and kept.
+ :{              Start lines to run as one input, up to a line of :}.
  :ls             List all global user variables.
  :gls            List all global variables (include __ prefixed).
  :stacks         Show lua stacks for each coroutine.
+ :save <path>    Save the session's definitions and data as an image.
+ :restore <path> Restore a session image, without re-running its code.
+ :timeit <stmt>  Time a statement or expression over many runs.
+ //gi:timeit     A first line of //gi:timeit times the input below it;
+                 //gi:lua runs it as Lua, up to a blank line, and
+                 //gi:nocache keeps it out of the history.
+ //gi:lua        Above 'func f(x float64) float64 {', a body of Lua up
+ func ...        to a line of }: Go calls f by its Go signature.
+ :why <expr>     Explain how the type checker typed an expression: the
+                 parameter each argument went to, and each conversion.
+ :methods T      List the method set of T, or of *T, with signatures
+                 and the embedded fields a method was promoted from.
+ :implements T I Tell whether type T satisfies interface I, and if not,
+                 which methods are missing or have the wrong signature.
+ :goroutines     List the live goroutines, with their state and stack;
+                 ':goroutines kill <id>' stops one for good.
+ :diff [on|off]  After each input, list the names it added (+),
+                 redefined (~) and removed (-); also -diff.
+ :set maxdepth 3 Echo values nested at most 3 deep; ':set maxwidth 20'
+                 shows at most 20 elements of each. 0 is no limit.
+ :reimport path  Load the Lua module of path again from disk, after edits,
+                 and check again the funcs that use it.
+ :packages       List the packages imported: from a shadow, gi or Lua,
+                 their version, the time to import and the Lua it ran.
+ :jobs           List the goroutines, a line each. Goroutines started at
+                 the prompt run in the background while you type.
+ :fg [id]        Run goroutine id, or all goroutines, in the foreground
+                 until it blocks or is done; ctrl-c stops it.
+ :profile start  Sample running code; ':profile stop [file]' reports the
+                 top Go functions and writes a pprof file (gijit.pprof).
+ :coverage on    Count the statements run from now on; ':coverage' reports,
+                 ':coverage html <file>' writes an HTML view.
+ :explain [code] Explain a warning's divergence from gc, as GI-W001;
+                 with no code, list them.
  = 3 + 4         Calculate the expression after the '=' (one line).
  ==              Multiple entry calculator mode. ':' to exit.
+ _ or _3         The latest result echoed, or that of input 3.
  import "fmt"    Import the binary, pre-compiled package.
+ dump(x)         Builtin: show x; structs, maps and slices in full.
+ help(x)         Builtin: show the run time type of x and its methods.
+ ctrl-c          Interrupt the running code; twice at the prompt to exit.
+ ctrl-d          At a continuation line, run what was typed as it is.
  ctrl-d to exit  History is saved in ~/.gitit.hist
 `)
 		return "", nil
 	}
 
+	if strings.HasPrefix(low, ":source ") {
+		// :source f, or T.Method, shows a declaration; a
+		// path, as of a .go file, is sourced below.
+		name := strings.TrimSpace(string(cmd)[len(":source "):])
+		if _, statErr := os.Stat(name); sourceNameRE.MatchString(name) && !strings.HasSuffix(name, ".go") && statErr != nil {
+			d, err := r.interp.Source(name)
+			if err != nil {
+				fmt.Printf("source error: %v\n", err)
+				return "", nil
+			}
+			if r.cfg.NoColor {
+				fmt.Print(d)
+			} else {
+				fmt.Print(highlightGo(d.String()))
+			}
+			return "", nil
+		}
+	}
 	r.isDo = strings.HasPrefix(low, ":do")
 	r.isSource = strings.HasPrefix(low, ":source")
 	if r.isDo || r.isSource {
@@ -483,7 +932,16 @@ func (r *Repl) setPrompt() {
 func (r *Repl) Eval(src string) error {
 
 	var use string
+	var scope *types.Scope
+	var snap scopeSnapshot
+	var kept string        // the source translated
+	var chunks []chunkSpan // src cut up to pipeline, if it is large
+	var prag pragmas
+	var body string // src without its pragmas
+	r.failed = false
 	isContinuation := len(r.prevSrc) > 0
+	line := src // as typed, without the lines before it
+	blankLine := strings.TrimSpace(line) == ""
 	if !r.cfg.RawLua {
 		if isContinuation {
 			src = r.prevSrc + "\n" + src
@@ -491,42 +949,212 @@ func (r *Repl) Eval(src string) error {
 		//fmt.Printf("src = '%s'\n", src)
 		//fmt.Printf("prevSrc = '%s'\n", prevSrc)
 
+		var err error
+		prag, body, err = parsePragmas(src)
+		if err != nil {
+			r.prevSrc = ""
+			r.setPrompt()
+			fmt.Printf("oops: %v\n", err)
+			return err
+		}
+		luaDone := blankLine
+		if startsLuaFunc(body) {
+			luaDone = endsLuaFunc(line)
+		}
+		if prag.any() && !r.isRegion && (strings.TrimSpace(body) == "" || prag.lua && !luaDone) {
+			// the code, or more of the Lua, is on the lines to come.
+			r.prompt = r.goMorePrompt
+			r.prevSrc = src
+			return nil
+		}
+	}
+	var lf *luaFunc
+	hist := "" // what the history keeps, if not src
+	if prag.lua {
+		r.prevSrc = ""
+		r.setPrompt()
+		if !r.cfg.Policy.Allows(CapFFI) {
+			err := fmt.Errorf("//gi:lua needs the ffi capability")
+			fmt.Printf("oops: %v\n", err)
+			return err
+		}
+		var err error
+		lf, err = parseLuaFunc(body)
+		if err != nil {
+			fmt.Printf("oops: %v\n", err)
+			return err
+		}
+		use = body
+		if lf != nil {
+			// the Go signature is declared as Go is, then
+			// the Lua body replaces the stub.
+			hist, src = src, lf.goDecl
+		}
+	}
+	if !r.cfg.RawLua && (!prag.lua || lf != nil) {
 		eof, syntaxErr, empty, err := front.TopLevelParseGoSource([]byte(src))
 		if empty {
 			r.prevSrc = ""
 			return nil
 		}
 		//fmt.Printf("eof = %v, syntaxErr = %v\n", eof, syntaxErr)
-		if eof && !syntaxErr {
+		if eof && !syntaxErr && !r.isRegion {
 			r.prompt = r.goMorePrompt
 			// get another line of input
 			r.prevSrc = src
 			return nil
 		}
 		r.prevSrc = ""
+		// complete: it needs the Interp from here on.
+		r.awaitInterp()
+		if prag.timeit {
+			r.setPrompt()
+			return r.timeit(body)
+		}
+		if f, err := formatInput(src); err == nil {
+			src = f
+		}
 
 		r.setPrompt()
-		translation, err := translateAndCatchPanic(r.inc, []byte(src))
-		if err != nil {
-			fmt.Printf("oops: '%v' on input '%s'\n", err, strings.TrimSpace(src))
-			translation = "\n"
-			// still write, so we get another prompt
+		scope = r.inc.pkgScope()
+		snap = takeScopeSnapshot(scope)
+		if lf == nil {
+			// a large input is translated as it runs.
+			chunks = splitForPipeline(r.inc, []byte(src))
+		}
+		kept = src
+		if chunks == nil {
+			var translation string
+			translation, err = translateAndCatchPanic(r.inc, []byte(src))
+			if partial, ok := err.(*ErrPartialInput); ok {
+				fmt.Printf("%s\n", r.interp.FormatError(partial, !r.cfg.NoColor))
+				kept = partial.kept
+				err = nil
+				r.failed = true
+			}
+			if err != nil {
+				fmt.Printf("oops: %s\n", r.interp.FormatError(err, !r.cfg.NoColor))
+				translation = "\n"
+				// still write, so we get another prompt
 
-			// hmm, or maybe not
-			return err
-		} else {
-			p("got translation of line from Go into lua: '%s'\n", strings.TrimSpace(string(translation)))
+				// hmm, or maybe not
+				return err
+			} else {
+				p("got translation of line from Go into lua: '%s'\n", strings.TrimSpace(string(translation)))
+			}
+			use = translation
+			if lf != nil {
+				use += lf.lua
+			}
 		}
-		use = translation
 
-	} else {
+	} else if !prag.lua {
 		// raw mode, under :r
 		use = src
 	}
+	r.awaitInterp()
 
 	p("sending use='%v'\n", use)
 
 	// add to history as separate lines
+	if hist == "" {
+		hist = src
+	}
+	if !prag.nocache {
+		r.appendHistory(hist)
+	}
+	r.t0 = time.Now()
+
+	useEval := !r.cfg.RawLua && (!prag.lua || lf != nil)
+	ctx, done := r.intr.beginEval(context.Background())
+	defer done()
+	run := func() error {
+		return r.interp.runGuarded(ctx, use, useEval, scope, snap)
+	}
+	var piped pipelined
+	if chunks != nil {
+		run = func() (err error) {
+			piped, err = r.interp.runPipelined(ctx, []byte(src), chunks, scope, snap)
+			return
+		}
+	}
+	var stats EvalStats
+	var err error
+	if r.cfg.Stats {
+		stats, err = measure(r.lvm, run)
+	} else {
+		err = run()
+	}
+	if chunks != nil {
+		if piped.trErr != nil {
+			fmt.Printf("oops: %s\n", r.interp.FormatError(piped.trErr, !r.cfg.NoColor))
+			return piped.trErr
+		}
+		if piped.partial != nil {
+			fmt.Printf("%s\n", r.interp.FormatError(piped.partial, !r.cfg.NoColor))
+			r.failed = true
+		}
+		use, kept = piped.last, piped.kept
+	}
+	if err != nil {
+		switch err.(type) {
+		case *ErrEvalCanceled, *ErrDeadlock:
+			fmt.Printf("%v\n", err)
+			r.failed = true
+			return nil
+		}
+		fmt.Printf("error from LuaRun: supplied lua with: '%s'\nlua stack:\n%v\n", use[:len(use)-1], err)
+		r.failed = true
+		return nil
+	}
+	if useEval {
+		r.interp.recordSource(kept)
+		d := diffScope(snap, scope)
+		if r.cfg.ScopeDiff && !d.Empty() {
+			fmt.Printf("%s\n", &d)
+		}
+		if len(d.Redefined) > 0 {
+			for _, w := range r.inc.depGraph().Warnings(d) {
+				fmt.Printf("%s\n", w)
+			}
+		}
+		for _, w := range r.interp.Warnings() {
+			fmt.Printf("%s\n", w)
+		}
+		if err := r.interp.lastEvalError(); err != nil {
+			fmt.Printf("%s\n", r.interp.FormatError(err, !r.cfg.NoColor))
+			r.failed = true
+		}
+	}
+	r.t1 = time.Now()
+	if !r.cfg.DumbTerminal {
+		// under -dumb-terminal, the next input may be
+		// buffered already; keep it.
+		fmt.Printf("\n")
+		r.reader.Reset(os.Stdin)
+		fmt.Printf("elapsed: '%v'\n", r.t1.Sub(r.t0))
+	}
+	if r.cfg.Stats {
+		fmt.Printf("stats: %v\n", stats)
+	}
+
+	return nil
+}
+
+// timeit implements :timeit and //gi:timeit.
+func (r *Repl) timeit(src string) error {
+	res, err := r.interp.TimeIt(src)
+	if err != nil {
+		fmt.Printf("timeit error: '%v'\n", err)
+		return err
+	}
+	fmt.Printf("%v\n%v\n", res, res.Stats)
+	return nil
+}
+
+// appendHistory adds the lines of src, an input, to the
+// history and the history file.
+func (r *Repl) appendHistory(src string) {
 	srcLines := strings.Split(src, "\n")
 	//fmt.Printf("appending to history: src='%#v', srcLines='%#v'\n", src, srcLines)
 	lensrc := len(srcLines)
@@ -543,20 +1171,6 @@ func (r *Repl) Eval(src string) error {
 		}
 		r.histFile.Sync()
 	}
-	r.t0 = time.Now()
-
-	useEval := !r.cfg.RawLua
-	err := LuaRun(r.lvm, use, useEval)
-	if err != nil {
-		fmt.Printf("error from LuaRun: supplied lua with: '%s'\nlua stack:\n%v\n", use[:len(use)-1], err)
-		return nil
-	}
-	r.t1 = time.Now()
-	fmt.Printf("\n")
-	r.reader.Reset(os.Stdin)
-	fmt.Printf("elapsed: '%v'\n", r.t1.Sub(r.t0))
-
-	return nil
 }
 
 // :ls, :gls, :lst, :glst implementation
@@ -0,0 +1,75 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The REPL as another program sees it, under -dumb-terminal,
+// as Emacs' comint and org-babel drive it. Input is read a
+// line at a time, and each input, once complete, is answered
+// by its output, then a line of the result marker,
+//
+//	//gi:result ok
+//
+// or "//gi:result error" if it failed, then the prompt. A
+// line of an input that is not yet complete, such as the
+// first of a func, is answered with nothing.
+//
+// Code that should run as one input, whatever its lines,
+// goes between lines of ":{" and ":}":
+//
+//	:{
+//	x := 1
+//	fmt.Println(x)
+//	:}
+//
+// It is not echoed, is not prompted for, and is evaluated
+// whole once ":}" is read; if it is not complete by then,
+// that is its error.
+
+// DefaultResultMarker is the -result-marker of -dumb-terminal.
+// It is a Go comment, so that pasted back it does no harm.
+const DefaultResultMarker = "//gi:result"
+
+// readRegion reads the lines of an eval region after its
+// ":{", up to its ":}", and returns them.
+func (r *Repl) readRegion() (string, error) {
+	var lines []string
+	for {
+		var line string
+		var err error
+		if r.cfg.NoLiner {
+			var by []byte
+			by, err = r.reader.ReadBytes('\n')
+			line = string(by)
+		} else {
+			prompt := r.goMorePrompt
+			line, err = r.prompter.Getline(&prompt)
+		}
+		if strings.TrimSpace(line) == ":}" {
+			return strings.Join(lines, "\n") + "\n", nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				fmt.Printf("end of input inside a :{ region; it is not run.\n")
+			}
+			return "", err
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// endResult prints the result marker, if there is one, for
+// an input that is done, and failed if failed.
+func (r *Repl) endResult(failed bool) {
+	if r.cfg.ResultMarker == "" || r.prevSrc != "" {
+		return
+	}
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+	fmt.Printf("%s %s\n", r.cfg.ResultMarker, status)
+}
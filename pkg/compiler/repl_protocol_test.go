@@ -0,0 +1,122 @@
+package compiler
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1377DumbTerminalProtocol(t *testing.T) {
+
+	cv.Convey("under -dumb-terminal, each input ends in a result marker, and a :{ region runs as one input", t, func() {
+		// keep the test's history out of ~/.gijit.hist.
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal", "-prompt", "go$ "}))
+		panicOn(cfg.ValidateConfig())
+		cv.So(cfg.NoLiner && cfg.NoColor && cfg.Quiet, cv.ShouldBeTrue)
+		cv.So(cfg.ResultMarker, cv.ShouldEqual, DefaultResultMarker)
+
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		// all the input at once, as a pipe may deliver it.
+		r.reader = bufio.NewReader(strings.NewReader(`import "fmt"
+fmt.Println("a")
+println("b")
+func f() int {
+	return 2
+}
+y := nope
+:{
+func g() {
+	fmt.Println("in g")
+}
+
+g()
+:}
+:{
+func h() {
+:}
+fmt.Println(f())
+`))
+		pr, pw, err := os.Pipe()
+		panicOn(err)
+		saved := os.Stdout
+		os.Stdout = pw
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(pr)
+			out <- string(b)
+		}()
+		r.Loop()
+		os.Stdout = saved
+		pw.Close()
+		got := <-out
+
+		cv.So(got, cv.ShouldStartWith, "go$ //gi:result ok\ngo$ a\n//gi:result ok\ngo$ b\n//gi:result ok\n"+
+			"go$ //gi:result ok\ngo$ oops: ")
+		cv.So(got, cv.ShouldContainSubstring, "undeclared name: nope")
+		cv.So(got, cv.ShouldEndWith, "//gi:result error\ngo$ in g\n//gi:result ok\ngo$ oops: "+
+			"problem detected during Go static type checking: 'repl[7]:1:12: expected '}', found 'EOF''\n"+
+			"//gi:result error\ngo$ 2\n//gi:result ok\ngo$ [EOF]\n")
+	})
+
+	cv.Convey("-prompt and -result-marker must be single lines", t, func() {
+		cfg := NewGIConfig()
+		cfg.ResultMarker = "a\nb"
+		cv.So(cfg.ValidateConfig(), cv.ShouldNotBeNil)
+	})
+}
+
+func Test1384EndOfInputRunsAContinuation(t *testing.T) {
+
+	cv.Convey("ctrl-d, or the end of the input, at a continuation runs what was typed as it is", t, func() {
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-dumb-terminal"}))
+		panicOn(cfg.ValidateConfig())
+		r := NewRepl(cfg)
+		defer r.lvm.Close()
+
+		r.reader = bufio.NewReader(strings.NewReader("/* a comment\nstill */ x := []int{1,\n2,\n"))
+		pr, pw, err := os.Pipe()
+		panicOn(err)
+		saved := os.Stdout
+		os.Stdout = pw
+		out := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(pr)
+			out <- string(b)
+		}()
+		r.Loop()
+		os.Stdout = saved
+		pw.Close()
+		got := <-out
+
+		cv.So(got, cv.ShouldStartWith, "gi> oops: ")
+		cv.So(got, cv.ShouldContainSubstring, "expected '}', found 'EOF'")
+		cv.So(got, cv.ShouldEndWith, "//gi:result error\ngi> [EOF]\n")
+	})
+}
@@ -0,0 +1,117 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gijit/gi/pkg/importer"
+)
+
+// The prompt comes up before the REPL's Interp is ready:
+// the LuaJIT vm and its runtime prelude are made on a
+// goroutine of their own, and then the host's types of the
+// packages most sessions import are read ahead. An input
+// waits for the Interp only once it needs the vm: Go and
+// Lua as they are complete, and the commands that reach
+// into the session, but not help, history or :fmt, nor the
+// first lines of a func still being typed. An import of a
+// package still being read ahead waits for just that one.
+
+// warmPackages are the shadowed packages whose host's types
+// are read ahead at startup, in this order, for their first
+// import to find them ready.
+var warmPackages = []string{"fmt", "math", "os", "strconv", "strings", "time"}
+
+// startInterp has r's Interp made in the background, and
+// then warmPackages read.
+func (r *Repl) startInterp() {
+	r.started = make(chan struct{})
+	var warm []string
+	for _, path := range warmPackages {
+		if r.cfg.Policy.CheckImport(path) == nil {
+			warm = append(warm, path)
+		}
+	}
+	if !r.cfg.Quiet {
+		// now, and not behind the prompt.
+		fmt.Print(preludeNotice(r.cfg, usesStaticPrelude(r.cfg)))
+		r.cfg.preludeAnnounced = true
+	}
+	go func() {
+		defer func() {
+			// for awaitInterp to raise, where it is needed.
+			if rec := recover(); rec != nil {
+				r.startErr = fmt.Errorf("%v", rec)
+			}
+			close(r.started)
+		}()
+		interp, err := newInterp(r.cfg)
+		if err != nil {
+			r.startErr = err
+			return
+		}
+		if r.cfg.DumbTerminal {
+			// keep what Lua prints in order with the rest.
+			interp.mut.Lock()
+			err = interp.hookPrint()
+			interp.mut.Unlock()
+			if err != nil {
+				interp.Close()
+				r.startErr = err
+				return
+			}
+		}
+		if r.prompter != nil {
+			// see watchBackground.
+			interp.SetBackgroundGoroutines(true)
+		}
+		r.interp = interp
+		r.lvm = interp.lvm
+		r.inc = interp.inc
+		// after the prelude, which every input needs.
+		importer.Prefetch(warm...)
+	}()
+}
+
+// awaitInterp waits until r's Interp is ready; it panics if
+// it could not be made, as NewRepl always has.
+func (r *Repl) awaitInterp() {
+	if r.started == nil {
+		// made with its Interp.
+		return
+	}
+	<-r.started
+	panicOn(r.startErr)
+}
+
+// interpReady reports whether r's Interp is ready, without
+// waiting for it.
+func (r *Repl) interpReady() bool {
+	if r.started == nil {
+		return true
+	}
+	select {
+	case <-r.started:
+		return r.startErr == nil
+	default:
+		return false
+	}
+}
+
+// commandNeedsInterp reports whether low, a line as read
+// and lowercased, is a command that needs the Interp. Go
+// and Lua source wait for it in Eval instead, once they
+// are complete.
+func commandNeedsInterp(low string) bool {
+	if low == "==" {
+		return true
+	}
+	if !strings.HasPrefix(low, ":") || strings.HasPrefix(low, "::") {
+		return false
+	}
+	switch low {
+	case ":h", ":help", ":?", ":clear", ":reset":
+		return false
+	}
+	return !strings.HasPrefix(low, ":explain")
+}
@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1409ReplPromptsBeforeItsInterpIsReady(t *testing.T) {
+
+	cv.Convey("the REPL's Interp is made behind its prompt, and an input waits for it only once it needs it", t, func() {
+		// keep the test's history out of ~/.gijit.hist.
+		origHome := os.Getenv("HOME")
+		tempdir, err := ioutil.TempDir("", "gijit-test")
+		panicOn(err)
+		defer os.RemoveAll(tempdir)
+		os.Setenv("HOME", tempdir)
+		defer os.Setenv("HOME", origHome)
+
+		myflags := flag.NewFlagSet("gi", flag.ExitOnError)
+		cfg := NewGIConfig()
+		cfg.DefineFlags(myflags)
+		panicOn(myflags.Parse([]string{"-t", "-no-liner"}))
+		panicOn(cfg.ValidateConfig())
+
+		r := startRepl(cfg)
+		defer func() {
+			r.awaitInterp()
+			r.lvm.Close()
+		}()
+		// the prelude was announced before the prompt, by
+		// the REPL's copy of cfg.
+		cv.So(r.cfg.preludeAnnounced, cv.ShouldBeTrue)
+		cv.So(cfg.preludeAnnounced, cv.ShouldBeFalse)
+
+		// help, history and source not yet complete do not wait.
+		cv.So(commandNeedsInterp(":help"), cv.ShouldBeFalse)
+		cv.So(commandNeedsInterp(":h"), cv.ShouldBeFalse)
+		cv.So(commandNeedsInterp(":explain gi-w001"), cv.ShouldBeFalse)
+		cv.So(commandNeedsInterp("::done"), cv.ShouldBeFalse)
+		cv.So(commandNeedsInterp("x := 1"), cv.ShouldBeFalse)
+		cv.So(commandNeedsInterp(":3"), cv.ShouldBeTrue)
+		cv.So(commandNeedsInterp(":packages"), cv.ShouldBeTrue)
+		cv.So(commandNeedsInterp("=="), cv.ShouldBeTrue)
+
+		panicOn(r.Eval("func f() int {"))
+		cv.So(r.prevSrc, cv.ShouldNotEqual, "")
+		panicOn(r.Eval("\treturn 2"))
+		panicOn(r.Eval("}"))
+		cv.So(r.interpReady(), cv.ShouldBeTrue)
+		panicOn(r.Eval("x := f()"))
+		cv.So(evalCaptured(r.interp, &r.intr, "x").Output, cv.ShouldEqual, "2\n")
+	})
+}
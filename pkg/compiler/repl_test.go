@@ -971,13 +971,14 @@ func Test042LenAtRepl(t *testing.T) {
 	defer vm.Close()
 	inc := NewIncrState(vm, nil)
 
-	cv.Convey(`a := []int{3}; len(a)' at the repl, len(a) should give us 1, so it should get wrapped in a print()`, t, func() {
+	cv.Convey(`a := []int{3}; len(a)' at the repl, len(a) should give us 1, so it should get echoed with __gi_show(), by way of its result variable _1`, t, func() {
 
 		code := `a := []int{3}; len(a)`
 		cv.So(string(inc.trMust([]byte(code))), matchesLuaSrc, `
     __type__.anon_sliceType = __sliceType(__type__.int);   
  	a = __type__.anon_sliceType({[0]=3LL});
-    print(#a);
+ 	_1 = #a;
+    __gi_show(_1);
 `)
 	})
 }
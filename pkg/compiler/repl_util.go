@@ -10,12 +10,52 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gijit/gi/pkg/types"
 	"github.com/gijit/gi/pkg/verb"
 )
 
+// translateAndCatchPanic translates src, returning any
+// panic as an error. When src does not type check, the scope
+// is left as it was, unless only some of its top level parts
+// failed: then the rest is translated without them, and an
+// *ErrPartialInput lists what was left out.
 func translateAndCatchPanic(inc *IncrState, src []byte) (translation string, err error) {
+	scope := inc.pkgScope()
+	snap := takeScopeSnapshot(scope)
+	translation, typeErr, err := translateOnce(inc, src)
+	if err == nil {
+		return translation, nil
+	}
+	snap.restore(scope)
+	if typeErr == nil {
+		return "", err
+	}
+	return translateSkipping(inc, src, snap, typeErr, err)
+}
+
+// translateOnce translates src as a whole. If that fails on
+// a type error, typeErr is it as well.
+func translateOnce(inc *IncrState, src []byte) (translation string, typeErr *types.Error, err error) {
+	defer func() {
+		if err != nil {
+			inc.forgetLastInput()
+		}
+	}()
 	defer func() {
 		recov := recover()
+		if tce, ok := recov.(typeCheckError); ok {
+			if e, ok := tce.err.(types.Error); ok {
+				typeErr = &e
+			}
+		}
+		if ie, ok := recov.(types.InternalError); ok {
+			// a bug in the checker, not the input.
+			if verb.Verbose {
+				ie.Msg += "\n" + string(ie.Stack)
+			}
+			err = ie
+			return
+		}
 		if recov != nil {
 			msg := fmt.Sprintf("problem detected during Go static type checking: '%v'", recov)
 			if verb.Verbose {
@@ -29,7 +69,7 @@ func translateAndCatchPanic(inc *IncrState, src []byte) (translation string, err
 
 	by, err := inc.Tr([]byte(src))
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	translation = string(by)
 
@@ -41,7 +81,7 @@ func translateAndCatchPanic(inc *IncrState, src []byte) (translation string, err
 		}
 	}
 	p("go:'%s'  -->  '%s'\n", src, t2)
-	return translation, err
+	return translation, nil, nil
 }
 
 func readHistory(histFn string) (history []string, err error) {
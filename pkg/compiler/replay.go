@@ -0,0 +1,227 @@
+package compiler
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// A .girepl file is a transcript of a session: its inputs,
+// each with the output it should print, as the prompt shows
+// them. gi replay runs the inputs in a new session and
+// reports each whose output differs.
+//
+//	Anything before the first prompt is a comment.
+//	gi> import "fmt"
+//	gi> func double(n int) int {
+//	...     return 2 * n
+//	... }
+//	gi> n := double(21)
+//	gi> n
+//	42
+//	gi> fmt.Println(double(1), "and more")
+//	2 ...
+//
+// An input is a line after "gi> ", and the lines after it
+// that begin "... ", with the space; a "..." alone is
+// output. Its output is the lines up to the next
+// input, which are compared without trailing spaces or
+// trailing empty lines; a "..." in them matches any text,
+// so output that varies, such as a time, can be left out.
+// An input's output includes its errors and warnings.
+//
+// gi replay -update writes what each input printed back
+// into the file instead, so a transcript can be started
+// from its inputs alone.
+
+const (
+	replayPrompt = "gi> "
+	replayMore   = "... "
+)
+
+// replayStep is an input of a transcript and its output.
+type replayStep struct {
+	Line     int // of the input's first line, from 1
+	Input    string
+	Expected string
+}
+
+// parseTranscript splits a .girepl text into its comment
+// and its steps.
+func parseTranscript(text string) (comment string, steps []replayStep) {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var out []string
+	i := 0
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], replayPrompt); i++ {
+		comment += lines[i] + "\n"
+	}
+	for i < len(lines) {
+		step := replayStep{Line: i + 1, Input: lines[i][len(replayPrompt):]}
+		for i++; i < len(lines) && strings.HasPrefix(lines[i], replayMore); i++ {
+			step.Input += "\n" + strings.TrimPrefix(lines[i], replayMore)
+		}
+		out = out[:0]
+		for ; i < len(lines) && !strings.HasPrefix(lines[i], replayPrompt); i++ {
+			out = append(out, lines[i])
+		}
+		step.Expected = strings.Join(out, "\n")
+		steps = append(steps, step)
+	}
+	return comment, steps
+}
+
+// formatTranscript is the inverse of parseTranscript.
+func formatTranscript(comment string, steps []replayStep) string {
+	var b strings.Builder
+	b.WriteString(comment)
+	for _, s := range steps {
+		for i, l := range strings.Split(s.Input, "\n") {
+			if i == 0 {
+				b.WriteString(replayPrompt + l + "\n")
+			} else {
+				b.WriteString(replayMore + l + "\n")
+			}
+		}
+		if out := replayLines(s.Expected); len(out) > 0 {
+			b.WriteString(strings.Join(out, "\n") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// replayLines is output as it is compared: its lines,
+// without trailing spaces or trailing empty lines.
+func replayLines(output string) []string {
+	lines := strings.Split(output, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// replayMatch reports whether got is the output expected,
+// where "..." in expected matches any text, across lines.
+func replayMatch(expected, got string) bool {
+	want := strings.Join(replayLines(expected), "\n")
+	have := strings.Join(replayLines(got), "\n")
+	parts := strings.Split(want, "...")
+	if len(parts) == 1 {
+		return want == have
+	}
+	if !strings.HasPrefix(have, parts[0]) {
+		return false
+	}
+	have = have[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(have, p)
+		if i < 0 {
+			return false
+		}
+		have = have[i+len(p):]
+	}
+	return strings.HasSuffix(have, last)
+}
+
+// replayOutput is what a step printed, as the prompt would
+// show it.
+func replayOutput(res capturedEval) string {
+	out := res.Output
+	for _, w := range res.Warnings {
+		out += w + "\n"
+	}
+	if res.Error != "" {
+		out += res.Error + "\n"
+	}
+	return out
+}
+
+// ReplayFile runs the transcript in path in a new session
+// from cfg, writing to w a report of each input whose
+// output differs, and returns how many did. If update, it
+// rewrites path with the outputs printed instead.
+func ReplayFile(cfg *GIConfig, path string, update bool, w io.Writer) (failed int, err error) {
+	by, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	comment, steps := parseTranscript(string(by))
+	it, err := NewInterp(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	var intr interruptWatcher
+	for i, s := range steps {
+		got := replayOutput(evalCaptured(it, &intr, s.Input))
+		if update {
+			steps[i].Expected = got
+			continue
+		}
+		if replayMatch(s.Expected, got) {
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "%s:%d: output differs\n", path, s.Line)
+		for j, l := range strings.Split(s.Input, "\n") {
+			if j == 0 {
+				fmt.Fprintf(w, "  %s%s\n", replayPrompt, l)
+			} else {
+				fmt.Fprintf(w, "  %s%s\n", replayMore, l)
+			}
+		}
+		for _, l := range replayLines(s.Expected) {
+			fmt.Fprintf(w, "- %s\n", l)
+		}
+		for _, l := range replayLines(got) {
+			fmt.Fprintf(w, "+ %s\n", l)
+		}
+	}
+	if update {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return 0, ioutil.WriteFile(path, []byte(formatTranscript(comment, steps)), fi.Mode())
+	}
+	return failed, nil
+}
+
+// GiReplayMain implements gi replay; it returns the exit
+// code: 1 if any input's output differed.
+func GiReplayMain(cfg *GIConfig, args []string) int {
+	fs := flag.NewFlagSet("gi replay", flag.ContinueOnError)
+	update := fs.Bool("update", false, "write the outputs printed into the files, in place of those expected")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: gi replay [-update] file.girepl...\n")
+		return 2
+	}
+	cfg.Quiet = true
+	code := 0
+	for _, path := range fs.Args() {
+		failed, err := ReplayFile(cfg, path, *update, os.Stdout)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "gi replay: %v\n", err)
+			code = 1
+		case failed > 0:
+			fmt.Printf("FAIL %s: %d of its inputs differ\n", path, failed)
+			code = 1
+		case *update:
+			fmt.Printf("updated %s\n", path)
+		default:
+			fmt.Printf("ok   %s\n", path)
+		}
+	}
+	return code
+}
@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1379ReplayTranscripts(t *testing.T) {
+
+	const transcript = `A transcript of doubling.
+gi> import "fmt"
+gi> func double(n int) int {
+...     return 2 * n
+... }
+gi> n := double(21)
+gi> n
+42
+gi> fmt.Println(double(1), "and more")
+2 ...
+gi> y := nope
+...undeclared name: nope...
+`
+
+	cv.Convey("a .girepl transcript parses into inputs and their outputs, and formats back", t, func() {
+		comment, steps := parseTranscript(transcript)
+		cv.So(comment, cv.ShouldEqual, "A transcript of doubling.\n")
+		cv.So(steps, cv.ShouldResemble, []replayStep{
+			{Line: 2, Input: `import "fmt"`},
+			{Line: 3, Input: "func double(n int) int {\n    return 2 * n\n}"},
+			{Line: 6, Input: "n := double(21)"},
+			{Line: 7, Input: "n", Expected: "42"},
+			{Line: 9, Input: `fmt.Println(double(1), "and more")`, Expected: "2 ..."},
+			{Line: 11, Input: "y := nope", Expected: "...undeclared name: nope..."},
+		})
+		cv.So(formatTranscript(comment, steps), cv.ShouldEqual, transcript)
+	})
+
+	cv.Convey("... in expected output matches any text", t, func() {
+		cv.So(replayMatch("42", "42\n\n"), cv.ShouldBeTrue)
+		cv.So(replayMatch("42", "43\n"), cv.ShouldBeFalse)
+		cv.So(replayMatch("a ...\nz", "a b\nc\nz\n"), cv.ShouldBeTrue)
+		cv.So(replayMatch("a...a", "a"), cv.ShouldBeFalse)
+		cv.So(replayMatch("...", "anything"), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("gi replay reports the inputs whose output differs, and -update rewrites them", t, func() {
+		dir, err := ioutil.TempDir("", "gi-replay-test")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "double.girepl")
+		panicOn(ioutil.WriteFile(path, []byte(transcript), 0644))
+
+		var out bytes.Buffer
+		failed, err := ReplayFile(nil, path, false, &out)
+		panicOn(err)
+		cv.So(out.String(), cv.ShouldEqual, "")
+		cv.So(failed, cv.ShouldEqual, 0)
+
+		broken := transcript[:len(transcript)-len("...undeclared name: nope...\n")] + "\ngi> fmt.Println(double(2))\n5\n"
+		panicOn(ioutil.WriteFile(path, []byte(broken), 0644))
+		failed, err = ReplayFile(nil, path, false, &out)
+		panicOn(err)
+		cv.So(failed, cv.ShouldEqual, 2)
+		cv.So(out.String(), cv.ShouldContainSubstring, path+":11: output differs\n  gi> y := nope\n+ problem detected")
+		cv.So(out.String(), cv.ShouldEndWith, path+":13: output differs\n  gi> fmt.Println(double(2))\n- 5\n+ 4\n")
+
+		_, err = ReplayFile(nil, path, true, ioutil.Discard)
+		panicOn(err)
+		by, err := ioutil.ReadFile(path)
+		panicOn(err)
+		cv.So(string(by), cv.ShouldEndWith, "gi> y := nope\n"+
+			"problem detected during Go static type checking: 'where error? err = 'repl[6]:1:6: undeclared name: nope''\n"+
+			"  1 | y := nope\n    |      ^~~~\ngi> fmt.Println(double(2))\n4\n")
+		out.Reset()
+		failed, err = ReplayFile(nil, path, false, &out)
+		panicOn(err)
+		cv.So(failed, cv.ShouldEqual, 0)
+	})
+}
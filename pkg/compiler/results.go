@@ -0,0 +1,151 @@
+package compiler
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// Results. The value of an expression echoed at the prompt
+// is kept in a variable _N, N being the number of the input,
+// as in repl[N]:
+//
+//	gi> 6 * 7
+//	42
+//	gi> _2 + 1    // input 2 was 6 * 7
+//	43
+//	gi> _ * 2     // the latest result, here _3
+//	86
+//
+// Each _N is an ordinary variable of the session, typed as
+// its expression was, declared by rewriting the input's last
+// echoed expression x into _N := x, then echoing _N. A _
+// that is used as a value, which Go would reject, is read as
+// the latest _N still declared: one whose input failed is
+// rolled back with it.
+
+// resultNameRE matches the names of result variables.
+var resultNameRE = regexp.MustCompile(`^_[0-9]+$`)
+
+func resultName(n int) string {
+	return "_" + strconv.Itoa(n)
+}
+
+// echoedAtPrompt reports whether x, a top level expression
+// statement, has its value echoed by the prompt, as incr.go
+// decides; only those of its calls that incr.go decides on
+// types, such as of a display.Data, are missed.
+func echoedAtPrompt(x ast.Expr) bool {
+	switch y := x.(type) {
+	case *ast.CallExpr:
+		id, ok := y.Fun.(*ast.Ident)
+		return ok && id.Name == "len"
+	case *ast.Ident:
+		// untyped nil has no type for _N to take.
+		return y.Name != "nil" && y.Name != "_"
+	case *ast.ParenExpr:
+		return echoedAtPrompt(y.X)
+	}
+	return true
+}
+
+// bindResults rewrites file, input n: its last echoed
+// expression is kept in _n, and a _ read as a value becomes
+// the latest result.
+func (tr *IncrState) bindResults(file *ast.File, n int) {
+	if latest := tr.latestResult(); latest != "" {
+		renameBlankReads(file, latest)
+	}
+	for i := len(file.Nodes) - 1; i >= 0; i-- {
+		es, ok := file.Nodes[i].(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		if !echoedAtPrompt(es.X) {
+			return
+		}
+		name := resultName(n)
+		pos := es.X.Pos()
+		file.Nodes[i] = &ast.AssignStmt{
+			Lhs:    []ast.Expr{&ast.Ident{NamePos: pos, Name: name}},
+			TokPos: pos,
+			Tok:    token.DEFINE,
+			Rhs:    []ast.Expr{es.X},
+		}
+		echo := &ast.ExprStmt{X: &ast.Ident{NamePos: pos, Name: name}}
+		file.Nodes = append(file.Nodes[:i+1], append([]ast.Node{echo}, file.Nodes[i+1:]...)...)
+		tr.results = append(tr.results, n)
+		return
+	}
+}
+
+// latestResult is the name of the latest _N still declared.
+func (tr *IncrState) latestResult() string {
+	if len(tr.results) == 0 || tr.CurPkg.Arch == nil {
+		return ""
+	}
+	scope := tr.CurPkg.Arch.Pkg.Scope()
+	for i := len(tr.results) - 1; i >= 0; i-- {
+		name := resultName(tr.results[i])
+		if scope.Lookup(name) != nil {
+			tr.results = tr.results[:i+1]
+			return name
+		}
+	}
+	tr.results = nil
+	return ""
+}
+
+// renameBlankReads renames to name each _ in file that is
+// read as a value, leaving those that are written to or
+// declared, which are Go's blank identifier.
+func renameBlankReads(file *ast.File, name string) {
+	blank := make(map[*ast.Ident]bool)
+	mark := func(xs ...ast.Expr) {
+		for _, x := range xs {
+			if id, ok := x.(*ast.Ident); ok {
+				blank[id] = true
+			}
+		}
+	}
+	inspect := func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			mark(x.Lhs...)
+		case *ast.RangeStmt:
+			mark(x.Key, x.Value)
+		case *ast.ValueSpec:
+			for _, id := range x.Names {
+				blank[id] = true
+			}
+		case *ast.Field:
+			for _, id := range x.Names {
+				blank[id] = true
+			}
+		case *ast.TypeSpec:
+			blank[x.Name] = true
+		case *ast.FuncDecl:
+			blank[x.Name] = true
+		case *ast.ImportSpec:
+			if x.Name != nil {
+				blank[x.Name] = true
+			}
+		case *ast.SelectorExpr:
+			blank[x.Sel] = true
+		}
+		return true
+	}
+	for _, n := range file.Nodes {
+		ast.Inspect(n, inspect)
+	}
+	for _, n := range file.Nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == "_" && !blank[id] {
+				id.Name = name
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1381ResultHistoryVariables(t *testing.T) {
+
+	cv.Convey("an echoed expression's value is kept in _N, and _ reads the latest result", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		var intr interruptWatcher
+		eval := func(src string) string {
+			return replayOutput(evalCaptured(it, &intr, src))
+		}
+
+		cv.So(eval("x := 3\nx * 14"), cv.ShouldEqual, "42\n")
+		cv.So(eval("_1 + 1"), cv.ShouldEqual, "43\n")
+		cv.So(eval("_ * 2"), cv.ShouldEqual, "86\n")
+
+		// _N is typed as its expression was.
+		cv.So(eval("type P struct{ A int }\nP{A: x}"), cv.ShouldEqual, "main.P{A: 3}\n")
+		cv.So(eval("_.A + _4.A"), cv.ShouldEqual, "6\n")
+		cv.So(eval("var s string = _3"), cv.ShouldContainSubstring, "cannot use _3")
+
+		// a failed input is rolled back with its _N.
+		cv.So(eval("nope * 2"), cv.ShouldContainSubstring, "undeclared name: nope")
+		cv.So(eval("_"), cv.ShouldEqual, "6\n")
+
+		// _ that is written to or declared is still blank.
+		cv.So(eval("var _ = 3\n_ = x\nfor _, v := range []int{1, 2} {\n\t_ = v\n}"), cv.ShouldEqual, "")
+		cv.So(eval("_"), cv.ShouldEqual, "6\n")
+	})
+}
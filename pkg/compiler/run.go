@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// script is a Go program, a package main in one file, as
+// gi run and gi build take it.
+type script struct {
+	text string
+	fset *token.FileSet
+	file *ast.File
+}
+
+// parseScript reads the Go program in path.
+func parseScript(path string) (*script, error) {
+	s, err := parseGoFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.file.Name != nil && s.file.Name.Name != "main" {
+		return nil, fmt.Errorf("%s is package %s, not a command (package main)", path, s.file.Name.Name)
+	}
+	return s, nil
+}
+
+// parseGoFile reads the Go file in path, of any package.
+func parseGoFile(path string) (*script, error) {
+	by, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &script{text: string(by), fset: token.NewFileSet()}
+	s.file, err = parser.ParseFile(s.fset, path, s.text, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// of returns the source text of n.
+func (s *script) of(n ast.Node) string {
+	return s.text[s.fset.Position(n.Pos()).Offset:s.fset.Position(n.End()).Offset]
+}
+
+// imports returns the program's import block, or "".
+func (s *script) imports() string {
+	if len(s.file.Imports) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for _, spec := range s.file.Imports {
+		b.WriteString("\t" + s.of(spec) + "\n")
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// topLevel lists the program's declarations and
+// statements, imports left out.
+func (s *script) topLevel() []ast.Node {
+	var nodes []ast.Node
+	for _, node := range s.file.Nodes {
+		if ds, ok := node.(*ast.DeclStmt); ok {
+			node = ds.Decl
+		}
+		if gd, ok := node.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// loadScript reads the Go program in path and returns it as
+// a single input: its imports, then its declarations.
+// hasMain reports whether it declares func main.
+func loadScript(path string) (src string, hasMain bool, err error) {
+	s, err := parseScript(path)
+	if err != nil {
+		return "", false, err
+	}
+	var b strings.Builder
+	b.WriteString(s.imports())
+	for _, node := range s.topLevel() {
+		if isMainFunc(node) {
+			hasMain = true
+		}
+		b.WriteString(s.of(node) + "\n")
+	}
+	return b.String(), hasMain, nil
+}
+
+func isMainFunc(node ast.Node) bool {
+	fd, ok := node.(*ast.FuncDecl)
+	return ok && fd.Recv == nil && fd.Name.Name == "main"
+}
+
+// RunFile runs the Go program in path as go run would,
+// in a fresh Interp started from cfg: its declarations are
+// evaluated, then its main is called. args are the
+// program's command line after its name; with path, they
+// are its os.Args, which flag.Parse reads.
+func RunFile(cfg *GIConfig, path string, args []string) error {
+	src, hasMain, err := loadScript(path)
+	if err != nil {
+		return err
+	}
+	if !hasMain {
+		return fmt.Errorf("%s: function main is undeclared in the main package", path)
+	}
+	c := *cfg
+	c.ScriptArgs = append([]string{path}, args...)
+	it, err := NewInterp(&c)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	// nothing follows the program that could redeclare
+	// what it inlines.
+	it.inc.inline = true
+	err = it.Eval(src)
+	it.inc.inline = false
+	for _, w := range it.Warnings() {
+		fmt.Fprintln(os.Stderr, w)
+	}
+	if err != nil {
+		return err
+	}
+	return it.Eval("main()")
+}
+
+// GiRunMain implements gi run. args are those after
+// "run": the Go file, or the Lua gi build made of one, then
+// the program's own arguments.
+// It returns the exit code.
+func GiRunMain(cfg *GIConfig, args []string) int {
+	if len(args) == 0 || !(strings.HasSuffix(args[0], ".go") || strings.HasSuffix(args[0], ".lua")) {
+		fmt.Fprintf(os.Stderr, "usage: gi run file.go|file.lua [arguments...]\n")
+		return 2
+	}
+	run := RunFile
+	if strings.HasSuffix(args[0], ".lua") {
+		// as gi build made it.
+		run = RunBuilt
+	}
+	if err := run(cfg, args[0], args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gi run: %v\n", err)
+		return 1
+	}
+	return 0
+}
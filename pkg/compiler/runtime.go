@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"strings"
+)
+
+// The runtime package at the prompt is Go's, but for
+// the parts that must see gi's goroutines and stacks
+// rather than the host's: NumGoroutine, Gosched, GC,
+// Caller, FuncForPC and Stack. prelude/zruntime.lua
+// puts those in front of Go's, and asks the functions
+// here to map its Lua stacks back to Go with srcMap.
+
+// runtimeCaller gives the Go frame skip frames up the
+// Lua stack, a ';' separated list leaf first, counting
+// only frames translated from Go, as runtime.Caller does.
+func (ic *IncrState) runtimeCaller(stack string, skip int) (fn, file string, line int, ok bool) {
+	frames := ic.srcMap.goStack(stack)
+	if skip < 0 || skip >= len(frames) {
+		return "", "", 0, false
+	}
+	f := frames[skip]
+	return f.Func, f.File, f.Line, true
+}
+
+// runtimeStack formats the running goroutine id, of
+// the given Lua stack, and created at the Lua location
+// created, as Go's runtime.Stack does.
+func (ic *IncrState) runtimeStack(id int, stack, created string) string {
+	g := Goroutine{ID: id, State: "running", Stack: ic.srcMap.goStack(stack)}
+	if created != "" {
+		if f, isGo := ic.srcMap.frame(created); isGo {
+			g.CreatedBy = &f
+		}
+	}
+	return g.String()
+}
+
+// runtimeStacks formats every goroutine, from the lines
+// of __gi_goroutines, for runtime.Stack(buf, true): the
+// running one first, as Go puts it.
+func (ic *IncrState) runtimeStacks(out string) string {
+	gs := parseGoroutines(ic.srcMap, out)
+	var running, others []string
+	for i := range gs {
+		if gs[i].State == "running" {
+			running = append(running, gs[i].String())
+		} else {
+			others = append(others, gs[i].String())
+		}
+	}
+	return strings.Join(append(running, others...), "\n")
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1353RuntimeShimSeesGiGoroutinesAndGoFrames(t *testing.T) {
+
+	cv.Convey("runtime.Caller and FuncForPC give the Go function and line, from the source maps", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "runtime"`))
+		panicOn(it.Eval(`func where() (string, int) {
+	_, file, line, _ := runtime.Caller(1)
+	return file, line
+}
+func name() string {
+	pc, _, _, ok := runtime.Caller(0)
+	if !ok {
+		return ""
+	}
+	return runtime.FuncForPC(pc).Name()
+}`))
+		panicOn(it.Eval(`
+file, line := where()`))
+		LuaMustString(it.lvm, "file", "repl[3]")
+		LuaMustInt64(it.lvm, "line", 2)
+		panicOn(it.Eval(`nm := name(); _, _, _, ok := runtime.Caller(100)`))
+		LuaMustString(it.lvm, "nm", "main.name")
+		LuaMustBool(it.lvm, "ok", false)
+	})
+
+	cv.Convey("runtime.Stack writes the running goroutine's Go frames, or every goroutine's", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "runtime"
+func trace(all bool) string {
+	buf := make([]byte, 4096)
+	n := runtime.Stack(buf, all)
+	return string(buf[:n])
+}`))
+		panicOn(it.Eval(`s := trace(false)`))
+		s := luaGlobalString(it.lvm, "s")
+		cv.So(s, cv.ShouldStartWith, "goroutine ")
+		cv.So(s, cv.ShouldContainSubstring, "[running]:\nmain.trace()\n\trepl[1]:4\nmain.repl[2]()\n\trepl[2]:1\n")
+
+		panicOn(it.Eval(`block := make(chan int); go func() { <-block }(); runtime.Gosched(); s = trace(true)`))
+		s = luaGlobalString(it.lvm, "s")
+		cv.So(strings.Count(s, "goroutine "), cv.ShouldEqual, 2)
+		cv.So(s, cv.ShouldContainSubstring, "[chan receive]:\n")
+		cv.So(strings.Index(s, "[running]"), cv.ShouldBeLessThan, strings.Index(s, "[chan receive]"))
+	})
+
+	cv.Convey("NumGoroutine counts gi's goroutines, and Gosched lets the others run first", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "runtime"`))
+		panicOn(it.Eval(`block := make(chan int); go func() { <-block }(); go func() { <-block }(); n := runtime.NumGoroutine()`))
+		LuaMustInt64(it.lvm, "n", 3)
+		panicOn(it.Eval(`ran := 0; for i := 0; i < 5; i++ { go func() { ran++ }() }; runtime.Gosched(); got := ran; runtime.GC()`))
+		LuaMustInt64(it.lvm, "got", 5)
+		panicOn(it.Eval(`close(block); runtime.Gosched(); n = runtime.NumGoroutine()`))
+		LuaMustInt64(it.lvm, "n", 1)
+	})
+}
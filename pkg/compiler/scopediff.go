@@ -0,0 +1,101 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/types"
+)
+
+// ScopeDiff is what an input changed in the scope of
+// the session: the names it added, redefined, or removed.
+// Names starting with "__" are the REPL's own, and are
+// left out.
+type ScopeDiff struct {
+	Added     []types.Object
+	Redefined []Redefinition
+	Removed   []types.Object
+}
+
+// Redefinition is a name given a new definition.
+type Redefinition struct {
+	Old, New types.Object
+}
+
+// Empty reports whether nothing changed.
+func (d *ScopeDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Redefined) == 0 && len(d.Removed) == 0
+}
+
+// String sums up d a line per name: "+ var x int" for
+// a name added, "~ x: int → string" for one redefined,
+// and "- x" for one removed.
+func (d *ScopeDiff) String() string {
+	var b strings.Builder
+	for _, obj := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", types.ObjectString(obj, relativeToObj(obj)))
+	}
+	for _, r := range d.Redefined {
+		was, is := diffTypeString(r.Old), diffTypeString(r.New)
+		if was == is {
+			fmt.Fprintf(&b, "~ %s\n", types.ObjectString(r.New, relativeToObj(r.New)))
+		} else {
+			fmt.Fprintf(&b, "~ %s: %s → %s\n", r.New.Name(), was, is)
+		}
+	}
+	for _, obj := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", obj.Name())
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// relativeToObj qualifies types outside the package of obj.
+func relativeToObj(obj types.Object) types.Qualifier {
+	return types.RelativeTo(obj.Pkg())
+}
+
+// diffTypeString is the type of obj, as it reads in a diff;
+// for a type name, that is the type it stands for.
+func diffTypeString(obj types.Object) string {
+	typ := obj.Type()
+	if _, isType := obj.(*types.TypeName); isType {
+		typ = typ.Underlying()
+	}
+	return types.TypeString(typ, relativeToObj(obj))
+}
+
+// diffScope compares s to snap, taken before an input.
+func diffScope(snap scopeSnapshot, s *types.Scope) (d ScopeDiff) {
+	for _, name := range s.Names() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		obj := s.Lookup(name)
+		switch old, had := snap[name]; {
+		case !had:
+			d.Added = append(d.Added, obj)
+		case old != obj:
+			d.Redefined = append(d.Redefined, Redefinition{Old: old, New: obj})
+		}
+	}
+	var gone []string
+	for name := range snap {
+		if !strings.HasPrefix(name, "__") && s.Lookup(name) == nil {
+			gone = append(gone, name)
+		}
+	}
+	sort.Strings(gone)
+	for _, name := range gone {
+		d.Removed = append(d.Removed, snap[name])
+	}
+	return
+}
+
+// LastScopeDiff returns what the most recent Eval
+// changed in the session's scope.
+func (it *Interp) LastScopeDiff() ScopeDiff {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.lastDiff
+}
@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1333ScopeDiffAfterEachEval(t *testing.T) {
+
+	cv.Convey(`after each eval, the names it added, the ones it redefined with their old and new types, and the ones it removed are listed`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`x := 1; type T struct{ A int }; func f() int { return 1 }`))
+		d := it.LastScopeDiff()
+		cv.So(d.String(), cv.ShouldEqual, "+ type T struct{A int}\n+ func f() int\n+ var x int")
+
+		panicOn(it.Eval(`x := "one"; type T struct{ A, B int }; func f() int { return 2 }; y := 2`))
+		d = it.LastScopeDiff()
+		cv.So(d.String(), cv.ShouldEqual, "+ var y int\n~ T: struct{A int} → struct{A int; B int}\n~ func f() int\n~ x: int → string")
+
+		// a plain assignment defines nothing.
+		panicOn(it.Eval(`y = 3`))
+		d = it.LastScopeDiff()
+		cv.So(d.Empty(), cv.ShouldBeTrue)
+
+		// a name no longer in scope is removed.
+		scope := it.inc.pkgScope()
+		snap := takeScopeSnapshot(scope)
+		delete(snap, "y")
+		snap["gone"] = types.NewVar(token.NoPos, nil, "gone", types.Typ[types.Int])
+		d = diffScope(snap, scope)
+		cv.So(d.String(), cv.ShouldEqual, "+ var y int\n- gone")
+	})
+}
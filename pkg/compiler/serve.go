@@ -0,0 +1,388 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Server lets clients over HTTP or a WebSocket drive one
+// shared session: a browser frontend, a remote editor, or
+// several people pair programming. Each request is a JSON
+// ServeRequest, answered by a ServeReply:
+//
+//	POST /eval       {"code": "x := 1 + 2"}
+//	POST /complete   {"code": "fmt.Pri", "cursor": 7}
+//	POST /interrupt
+//	POST /vars       the session's variables
+//	GET  /ws         a WebSocket carrying requests with an
+//	                 "op" of "eval", "complete", "interrupt"
+//	                 or "vars"
+//	GET  /           a web frontend, webUIHTML
+//
+// Evals run one at a time, in the order they arrive; what
+// each prints comes back in its reply. An interrupt cancels
+// the eval that is running, whoever sent it. Every eval is
+// also sent, as op "evaluated", to the other WebSocket
+// clients, so that all see the session change; collab.go
+// has the rest of what they see of each other.
+//
+// A client authenticates with the server's token, in an
+// Authorization: Bearer header or, as a browser must for a
+// WebSocket, in a token query parameter.
+type Server struct {
+	it    *Interp
+	token string
+
+	evalMu sync.Mutex // serializes evals
+	intr   interruptWatcher
+
+	mu       sync.Mutex
+	clients  map[*wsConn]*ServeClient
+	clientID int
+}
+
+// ServeRequest is a request to a Server. ID is echoed in
+// the reply, for WebSocket clients to match the two.
+type ServeRequest struct {
+	ID     int64  `json:"id,omitempty"`
+	Op     string `json:"op,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Cursor *int   `json:"cursor,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// ServeReply is the answer to a ServeRequest. Output is
+// what an eval printed, Error why it failed, and Display
+// its rich outputs. Start and Candidates are a completion,
+// whose candidates replace Code from Start to the cursor.
+// Interrupted reports whether an interrupt found an eval
+// to cancel. Vars lists the session's variables. Client is
+// the WebSocket client a broadcast is about, and Clients
+// all of them.
+type ServeReply struct {
+	ID          int64         `json:"id,omitempty"`
+	Op          string        `json:"op"`
+	Code        string        `json:"code,omitempty"`
+	Output      string        `json:"output,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Warnings    []string      `json:"warnings,omitempty"`
+	Display     []DisplayData `json:"display,omitempty"`
+	Start       int           `json:"start,omitempty"`
+	Candidates  []string      `json:"candidates,omitempty"`
+	Interrupted bool          `json:"interrupted,omitempty"`
+	Vars        []Var         `json:"vars,omitempty"`
+	Client      *ServeClient  `json:"client,omitempty"`
+	Clients     []ServeClient `json:"clients,omitempty"`
+}
+
+// NewServer returns a Server for the session of it,
+// admitting clients that present token. An empty token
+// admits anyone, for a server only reachable locally.
+// The server keeps rich outputs, for its clients to show.
+func NewServer(it *Interp, token string) (*Server, error) {
+	if err := it.SetRichDisplay(true); err != nil {
+		return nil, err
+	}
+	return &Server{it: it, token: token, clients: make(map[*wsConn]*ServeClient)}, nil
+}
+
+// ServeHTTP answers the requests of the Server protocol.
+// The web frontend is given to anyone, as it holds nothing
+// of the session; it asks for the token itself.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(webUIHTML))
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gi"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	op := strings.TrimPrefix(r.URL.Path, "/")
+	if op == "ws" {
+		s.serveWS(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ServeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	req.Op = op
+	var reply ServeReply
+	switch op {
+	case "eval":
+		reply = s.eval(req, nil)
+	case "complete":
+		reply = s.complete(req)
+	case "interrupt":
+		reply = s.interrupt(req)
+	case "vars":
+		reply = s.vars(req)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// authorized reports whether r carries the server's token.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	got := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// eval runs req.Code, and tells the WebSocket clients
+// other than from about it.
+func (s *Server) eval(req ServeRequest, from *wsConn) ServeReply {
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+
+	res := evalCaptured(s.it, &s.intr, req.Code)
+	reply := ServeReply{ID: req.ID, Op: "eval", Output: res.Output, Error: res.Error,
+		Warnings: res.Warnings, Display: res.Display}
+
+	seen := reply
+	seen.ID = 0
+	seen.Op = "evaluated"
+	seen.Code = req.Code
+	seen.Client = s.client(from)
+	s.broadcast(seen, from)
+	return reply
+}
+
+// capturedEval is what an eval did, as a client is told.
+type capturedEval struct {
+	Output   string
+	Error    string
+	Warnings []string
+	Display  []DisplayData
+}
+
+// evalCaptured runs code in it, catching what it prints. intr
+// can interrupt it.
+func evalCaptured(it *Interp, intr *interruptWatcher, code string) (res capturedEval) {
+	var out bytes.Buffer
+	if err := it.SetOutput(&out); err != nil {
+		res.Error = err.Error()
+		return
+	}
+	ctx, done := intr.beginEval(context.Background())
+	err := it.EvalContext(ctx, code)
+	done()
+	it.SetOutput(nil)
+
+	res.Output = out.String()
+	if err != nil {
+		res.Error = it.FormatError(err, false)
+	}
+	res.Warnings = it.Warnings()
+	res.Display, err = it.Displayed()
+	if err != nil && res.Error == "" {
+		res.Error = err.Error()
+	}
+	return
+}
+
+func (s *Server) complete(req ServeRequest) ServeReply {
+	cursor := len(req.Code)
+	if req.Cursor != nil {
+		cursor = *req.Cursor
+	}
+	start, candidates := s.it.Complete(req.Code, cursor)
+	return ServeReply{ID: req.ID, Op: "complete", Start: start, Candidates: candidates}
+}
+
+func (s *Server) interrupt(req ServeRequest) ServeReply {
+	canceled, _ := s.intr.interrupt()
+	return ServeReply{ID: req.ID, Op: "interrupt", Interrupted: canceled}
+}
+
+func (s *Server) vars(req ServeRequest) ServeReply {
+	vs, err := s.it.Vars()
+	reply := ServeReply{ID: req.ID, Op: "vars", Vars: vs}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply
+}
+
+// serveWS runs one WebSocket client. Its evals are queued,
+// to run in order; interrupts are answered at once, even
+// while an eval runs, and completions and variables as
+// soon as the session is free.
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	var joined *wsConn
+	var me ServeClient
+	c, err := wsAccept(w, r, func(c *wsConn) {
+		joined = c
+		me = s.join(c, r.URL.Query().Get("name"))
+	})
+	if err != nil {
+		if joined != nil {
+			s.mu.Lock()
+			delete(s.clients, joined)
+			s.mu.Unlock()
+		}
+		return
+	}
+	s.announce(c, me)
+
+	evals := make(chan ServeRequest, 64)
+	defer func() {
+		close(evals)
+		s.leave(c)
+		c.Close()
+	}()
+	go func() {
+		for req := range evals {
+			s.send(c, s.eval(req, c))
+		}
+	}()
+
+	for {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req ServeRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			s.send(c, ServeReply{Op: "error", Error: "bad request: " + err.Error()})
+			continue
+		}
+		switch req.Op {
+		case "eval":
+			evals <- req
+		case "complete":
+			go func() { s.send(c, s.complete(req)) }()
+		case "vars":
+			go func() { s.send(c, s.vars(req)) }()
+		case "interrupt":
+			s.send(c, s.interrupt(req))
+		case "hello":
+			s.send(c, s.hello(c, req))
+		case "clients":
+			s.send(c, ServeReply{ID: req.ID, Op: "clients", Clients: s.clientList()})
+		case "cursor":
+			s.moveCursor(c, req)
+		default:
+			s.send(c, ServeReply{ID: req.ID, Op: "error", Error: fmt.Sprintf("unknown op '%s'", req.Op)})
+		}
+	}
+}
+
+func (s *Server) send(c *wsConn, reply ServeReply) {
+	msg, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	c.WriteMessage(msg)
+}
+
+// broadcast sends reply to every WebSocket client but from.
+func (s *Server) broadcast(reply ServeReply, from *wsConn) {
+	s.mu.Lock()
+	var to []*wsConn
+	for c := range s.clients {
+		if c != from {
+			to = append(to, c)
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range to {
+		s.send(c, reply)
+	}
+}
+
+// GiServeMain implements gi serve. args are those after
+// "serve"; it returns the exit code. Without -token, the
+// token is taken from $GI_SERVE_TOKEN, or else made up; it
+// is printed in the link to the web frontend. Whoever has
+// the token can run code on this host, so gi serve listens
+// on the loopback alone, unless -expose says otherwise.
+func GiServeMain(cfg *GIConfig, args []string) int {
+	fs := flag.NewFlagSet("gi serve", flag.ContinueOnError)
+	listen := fs.String("listen", "localhost:8998", "address to serve on")
+	expose := fs.Bool("expose", false, "let -listen be an address other hosts can reach; whoever has the token can then run code on this one")
+	token := fs.String("token", "", "token clients must present (default $GI_SERVE_TOKEN, else a random one)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*expose && !loopbackAddr(*listen) {
+		fmt.Fprintf(os.Stderr, "gi serve: -listen %s can be reached from other hosts, which could then run code on this one; add -expose if that is meant\n", *listen)
+		return 2
+	}
+	if *token == "" {
+		*token = os.Getenv("GI_SERVE_TOKEN")
+	}
+	if *token == "" {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			fmt.Fprintf(os.Stderr, "gi serve: %v\n", err)
+			return 1
+		}
+		*token = hex.EncodeToString(b[:])
+	}
+
+	it, err := NewInterp(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi serve: %v\n", err)
+		return 1
+	}
+	defer it.Close()
+	srv, err := NewServer(it, *token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi serve: %v\n", err)
+		return 1
+	}
+	host := *listen
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Fprintf(os.Stderr, "gi serve: listening on %s; the web frontend is at http://%s/#token=%s\n", *listen, host, *token)
+	if err := http.ListenAndServe(*listen, srv); err != nil {
+		fmt.Fprintf(os.Stderr, "gi serve: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// loopbackAddr reports whether addr, a host:port to listen
+// on, can be reached from this host alone.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,195 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// serveCall POSTs req to the op endpoint of srv.
+func serveCall(srv *httptest.Server, token, op string, req ServeRequest) (status int, reply ServeReply) {
+	by, _ := json.Marshal(req)
+	hr, _ := http.NewRequest("POST", srv.URL+"/"+op, bytes.NewReader(by))
+	if token != "" {
+		hr.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(hr)
+	panicOn(err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		panicOn(json.NewDecoder(resp.Body).Decode(&reply))
+	}
+	return resp.StatusCode, reply
+}
+
+// wsTimeout bounds each read of a test, so that a reply
+// that never comes fails it rather than hangs it.
+const wsTimeout = 10 * time.Second
+
+// serveDial opens a WebSocket to srv, with the query
+// parameters query.
+func serveDial(srv *httptest.Server, query string) *wsConn {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	panicOn(err)
+	conn.SetReadDeadline(time.Now().Add(wsTimeout))
+	fmt.Fprintf(conn, "GET /ws?%s HTTP/1.1\r\nHost: gi\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: %s\r\n\r\n", query, wsNewKey())
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	panicOn(err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		panic(fmt.Sprintf("handshake: %s", resp.Status))
+	}
+	return wsClient(conn, br)
+}
+
+func wsRead(c *wsConn) (reply ServeReply) {
+	c.conn.SetReadDeadline(time.Now().Add(wsTimeout))
+	msg, err := c.ReadMessage()
+	panicOn(err)
+	panicOn(json.Unmarshal(msg, &reply))
+	return
+}
+
+func Test1373ServeDrivesOneSharedSession(t *testing.T) {
+
+	cv.Convey("gi serve evals, completes and interrupts over HTTP, for clients with the token, returning what each eval printed", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		s, err := NewServer(it, "sekrit")
+		panicOn(err)
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		status, _ := serveCall(srv, "", "eval", ServeRequest{Code: "x := 1"})
+		cv.So(status, cv.ShouldEqual, http.StatusUnauthorized)
+		status, _ = serveCall(srv, "wrong", "eval", ServeRequest{Code: "x := 1"})
+		cv.So(status, cv.ShouldEqual, http.StatusUnauthorized)
+
+		status, reply := serveCall(srv, "sekrit", "eval", ServeRequest{Code: "import \"fmt\"\ntype Point struct{ X, Y int }\np := Point{X: 1}\nprintln(\"a\", \"1\")\nfmt.Printf(\"b %d\\n\", 2)\nprintln(\"c\")"})
+		cv.So(status, cv.ShouldEqual, http.StatusOK)
+		cv.So(reply.Error, cv.ShouldEqual, "")
+		cv.So(reply.Output, cv.ShouldEqual, "a\t1\nb 2\nc\n")
+
+		_, reply = serveCall(srv, "sekrit", "eval", ServeRequest{Code: "y := undefinedThing"})
+		cv.So(reply.Error, cv.ShouldContainSubstring, "undeclared name: undefinedThing")
+
+		_, reply = serveCall(srv, "sekrit", "complete", ServeRequest{Code: "fmt.Sprin"})
+		cv.So(reply.Start, cv.ShouldEqual, 4)
+		cv.So(reply.Candidates, cv.ShouldResemble, []string{"Sprint", "Sprintf", "Sprintln"})
+		cursor := 3
+		_, reply = serveCall(srv, "sekrit", "complete", ServeRequest{Code: "p.X + 1", Cursor: &cursor})
+		cv.So(reply.Start, cv.ShouldEqual, 2)
+		cv.So(reply.Candidates, cv.ShouldResemble, []string{"X"})
+		_, reply = serveCall(srv, "sekrit", "complete", ServeRequest{Code: "Poi"})
+		cv.So(reply.Candidates, cv.ShouldResemble, []string{"Point"})
+
+		done := make(chan ServeReply)
+		go func() {
+			_, r := serveCall(srv, "sekrit", "eval", ServeRequest{Code: "for {}"})
+			done <- r
+		}()
+		var interrupted bool
+		for i := 0; i < 100 && !interrupted; i++ {
+			time.Sleep(20 * time.Millisecond)
+			_, r := serveCall(srv, "sekrit", "interrupt", ServeRequest{})
+			interrupted = r.Interrupted
+		}
+		cv.So(interrupted, cv.ShouldBeTrue)
+		reply = <-done
+		cv.So(reply.Error, cv.ShouldContainSubstring, "interrupted")
+
+		// the session goes on.
+		_, reply = serveCall(srv, "sekrit", "eval", ServeRequest{Code: "fmt.Println(p.X + 41)"})
+		cv.So(reply.Output, cv.ShouldEqual, "42\n")
+	})
+
+	cv.Convey("Interps evaluating at once each catch only their own output", t, func() {
+		outs := make([]string, 4)
+		done := make(chan bool)
+		for i := range outs {
+			go func(i int) {
+				defer func() { done <- true }()
+				it, err := NewInterp(nil)
+				panicOn(err)
+				defer it.Close()
+				var intr interruptWatcher
+				res := evalCaptured(it, &intr, fmt.Sprintf("import \"fmt\"\nfor j := 0; j < 50; j++ { fmt.Print(\"%d\"); println(\"%d\") }", i, i))
+				outs[i] = res.Output
+			}(i)
+		}
+		for range outs {
+			<-done
+		}
+		for i, out := range outs {
+			d := fmt.Sprint(i)
+			cv.So(out, cv.ShouldEqual, strings.Repeat(d+d+"\n", 50))
+		}
+	})
+
+	cv.Convey("WebSocket clients share the session, and each sees the others' evals", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		s, err := NewServer(it, "sekrit")
+		panicOn(err)
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		alice := serveDial(srv, "token=sekrit")
+		defer alice.Close()
+		bob := serveDial(srv, "token=sekrit")
+		defer bob.Close()
+		cv.So(wsRead(alice).Op, cv.ShouldEqual, "joined") // bob
+
+		panicOn(alice.WriteMessage([]byte(`{"id": 1, "op": "eval", "code": "import \"fmt\"\nn := 6 * 7\nfmt.Println(n)"}`)))
+		reply := wsRead(alice)
+		cv.So(reply.ID, cv.ShouldEqual, 1)
+		cv.So(reply.Op, cv.ShouldEqual, "eval")
+		cv.So(reply.Output, cv.ShouldEqual, "42\n")
+
+		seen := wsRead(bob)
+		cv.So(seen.Op, cv.ShouldEqual, "evaluated")
+		cv.So(seen.Code, cv.ShouldEqual, "import \"fmt\"\nn := 6 * 7\nfmt.Println(n)")
+		cv.So(seen.Output, cv.ShouldEqual, "42\n")
+
+		panicOn(bob.WriteMessage([]byte(`{"id": 7, "op": "complete", "code": "n + le"}`)))
+		reply = wsRead(bob)
+		cv.So(reply.ID, cv.ShouldEqual, 7)
+		cv.So(reply.Candidates, cv.ShouldResemble, []string{"len"})
+
+		panicOn(bob.WriteMessage([]byte(`{"id": 8, "op": "frobnicate"}`)))
+		reply = wsRead(bob)
+		cv.So(reply.Error, cv.ShouldEqual, "unknown op 'frobnicate'")
+
+		conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+		panicOn(err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(wsTimeout))
+		fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: gi\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: %s\r\n\r\n", wsNewKey())
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		panicOn(err)
+		cv.So(resp.StatusCode, cv.ShouldEqual, http.StatusUnauthorized)
+	})
+
+	cv.Convey("gi serve listens on the loopback alone, unless told to -expose the session", t, func() {
+		cv.So(loopbackAddr("localhost:8998"), cv.ShouldBeTrue)
+		cv.So(loopbackAddr("127.0.0.1:8998"), cv.ShouldBeTrue)
+		cv.So(loopbackAddr("[::1]:8998"), cv.ShouldBeTrue)
+		cv.So(loopbackAddr(":8998"), cv.ShouldBeFalse)
+		cv.So(loopbackAddr("0.0.0.0:8998"), cv.ShouldBeFalse)
+		cv.So(loopbackAddr("192.168.1.5:8998"), cv.ShouldBeFalse)
+
+		cv.So(GiServeMain(NewGIConfig(), []string{"-listen", ":8998"}), cv.ShouldEqual, 2)
+	})
+}
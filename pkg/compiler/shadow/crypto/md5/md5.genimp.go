@@ -0,0 +1,20 @@
+package shadow_md5
+
+import "crypto/md5"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Pkg["BlockSize"] = md5.BlockSize
+    Pkg["New"] = md5.New
+    Pkg["Size"] = md5.Size
+    Pkg["Sum"] = md5.Sum
+
+}
+
+ func InitLua() string {
+  return `
+__type__.md5 ={};
+
+`}
\ No newline at end of file
@@ -0,0 +1,23 @@
+package shadow_sha256
+
+import "crypto/sha256"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Pkg["BlockSize"] = sha256.BlockSize
+    Pkg["New"] = sha256.New
+    Pkg["New224"] = sha256.New224
+    Pkg["Size"] = sha256.Size
+    Pkg["Size224"] = sha256.Size224
+    Pkg["Sum224"] = sha256.Sum224
+    Pkg["Sum256"] = sha256.Sum256
+
+}
+
+ func InitLua() string {
+  return `
+__type__.sha256 ={};
+
+`}
\ No newline at end of file
@@ -0,0 +1,53 @@
+package shadow_base64
+
+import "encoding/base64"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Ctor["Encoding"] = GijitShadow_NewStruct_Encoding
+    Pkg["NewDecoder"] = base64.NewDecoder
+    Pkg["NewEncoder"] = base64.NewEncoder
+    Pkg["NewEncoding"] = base64.NewEncoding
+    Pkg["NoPadding"] = base64.NoPadding
+    Pkg["RawStdEncoding"] = base64.RawStdEncoding
+    Pkg["RawURLEncoding"] = base64.RawURLEncoding
+    Pkg["StdEncoding"] = base64.StdEncoding
+    Pkg["StdPadding"] = base64.StdPadding
+    Pkg["URLEncoding"] = base64.URLEncoding
+
+}
+func GijitShadow_NewStruct_Encoding(src *base64.Encoding) *base64.Encoding {
+    if src == nil {
+	   return &base64.Encoding{}
+    }
+    a := *src
+    return &a
+}
+
+
+
+ func InitLua() string {
+  return `
+__type__.base64 ={};
+
+-----------------
+-- struct Encoding
+-----------------
+
+__type__.base64.Encoding = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Encoding",
+ __call = function(t, src)
+   return __ctor__base64.Encoding(src)
+ end,
+ -- a composite literal, &base64.Encoding{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__base64.Encoding(nil)
+ end,
+};
+setmetatable(__type__.base64.Encoding, __type__.base64.Encoding);
+
+
+`}
\ No newline at end of file
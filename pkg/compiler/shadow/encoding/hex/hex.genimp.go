@@ -0,0 +1,27 @@
+package shadow_hex
+
+import "encoding/hex"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Pkg["Decode"] = hex.Decode
+    Pkg["DecodeString"] = hex.DecodeString
+    Pkg["DecodedLen"] = hex.DecodedLen
+    Pkg["Dump"] = hex.Dump
+    Pkg["Dumper"] = hex.Dumper
+    Pkg["Encode"] = hex.Encode
+    Pkg["EncodeToString"] = hex.EncodeToString
+    Pkg["EncodedLen"] = hex.EncodedLen
+    Pkg["ErrLength"] = hex.ErrLength
+    Pkg["NewDecoder"] = hex.NewDecoder
+    Pkg["NewEncoder"] = hex.NewEncoder
+
+}
+
+ func InitLua() string {
+  return `
+__type__.hex ={};
+
+`}
\ No newline at end of file
@@ -13,6 +13,7 @@ func init() {
     Pkg["Copy"] = io.Copy
     Pkg["CopyBuffer"] = io.CopyBuffer
     Pkg["CopyN"] = io.CopyN
+    Pkg["Discard"] = io.Discard
     Pkg["EOF"] = io.EOF
     Pkg["ErrClosedPipe"] = io.ErrClosedPipe
     Pkg["ErrNoProgress"] = io.ErrNoProgress
@@ -23,13 +24,18 @@ func init() {
     Ctor["LimitedReader"] = GijitShadow_NewStruct_LimitedReader
     Pkg["MultiReader"] = io.MultiReader
     Pkg["MultiWriter"] = io.MultiWriter
+    Pkg["NewOffsetWriter"] = io.NewOffsetWriter
     Pkg["NewSectionReader"] = io.NewSectionReader
+    Pkg["NopCloser"] = io.NopCloser
+    Ctor["OffsetWriter"] = GijitShadow_NewStruct_OffsetWriter
     Pkg["Pipe"] = io.Pipe
     Ctor["PipeReader"] = GijitShadow_NewStruct_PipeReader
     Ctor["PipeWriter"] = GijitShadow_NewStruct_PipeWriter
+    Pkg["ReadAll"] = io.ReadAll
     Pkg["ReadAtLeast"] = io.ReadAtLeast
     Pkg["ReadCloser"] = GijitShadow_InterfaceConvertTo2_ReadCloser
     Pkg["ReadFull"] = io.ReadFull
+    Pkg["ReadSeekCloser"] = GijitShadow_InterfaceConvertTo2_ReadSeekCloser
     Pkg["ReadSeeker"] = GijitShadow_InterfaceConvertTo2_ReadSeeker
     Pkg["ReadWriteCloser"] = GijitShadow_InterfaceConvertTo2_ReadWriteCloser
     Pkg["ReadWriteSeeker"] = GijitShadow_InterfaceConvertTo2_ReadWriteSeeker
@@ -44,6 +50,7 @@ func init() {
     Pkg["SeekEnd"] = io.SeekEnd
     Pkg["SeekStart"] = io.SeekStart
     Pkg["Seeker"] = GijitShadow_InterfaceConvertTo2_Seeker
+    Pkg["StringWriter"] = GijitShadow_InterfaceConvertTo2_StringWriter
     Pkg["TeeReader"] = io.TeeReader
     Pkg["WriteCloser"] = GijitShadow_InterfaceConvertTo2_WriteCloser
     Pkg["WriteSeeker"] = GijitShadow_InterfaceConvertTo2_WriteSeeker
@@ -102,6 +109,15 @@ func GijitShadow_NewStruct_LimitedReader(src *io.LimitedReader) *io.LimitedReade
 }
 
 
+func GijitShadow_NewStruct_OffsetWriter(src *io.OffsetWriter) *io.OffsetWriter {
+    if src == nil {
+	   return &io.OffsetWriter{}
+    }
+    a := *src
+    return &a
+}
+
+
 func GijitShadow_NewStruct_PipeReader(src *io.PipeReader) *io.PipeReader {
     if src == nil {
 	   return &io.PipeReader{}
@@ -130,6 +146,16 @@ func GijitShadow_InterfaceConvertTo1_ReadCloser(x interface{}) io.ReadCloser {
 }
 
 
+func GijitShadow_InterfaceConvertTo2_ReadSeekCloser(x interface{}) (y io.ReadSeekCloser, b bool) {
+	y, b = x.(io.ReadSeekCloser)
+	return
+}
+
+func GijitShadow_InterfaceConvertTo1_ReadSeekCloser(x interface{}) io.ReadSeekCloser {
+	return x.(io.ReadSeekCloser)
+}
+
+
 func GijitShadow_InterfaceConvertTo2_ReadSeeker(x interface{}) (y io.ReadSeeker, b bool) {
 	y, b = x.(io.ReadSeeker)
 	return
@@ -239,6 +265,16 @@ func GijitShadow_InterfaceConvertTo1_Seeker(x interface{}) io.Seeker {
 }
 
 
+func GijitShadow_InterfaceConvertTo2_StringWriter(x interface{}) (y io.StringWriter, b bool) {
+	y, b = x.(io.StringWriter)
+	return
+}
+
+func GijitShadow_InterfaceConvertTo1_StringWriter(x interface{}) io.StringWriter {
+	return x.(io.StringWriter)
+}
+
+
 func GijitShadow_InterfaceConvertTo2_WriteCloser(x interface{}) (y io.WriteCloser, b bool) {
 	y, b = x.(io.WriteCloser)
 	return
@@ -308,6 +344,20 @@ __type__.io.LimitedReader = {
 setmetatable(__type__.io.LimitedReader, __type__.io.LimitedReader);
 
 
+-----------------
+-- struct OffsetWriter
+-----------------
+
+__type__.io.OffsetWriter = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "OffsetWriter",
+ __call = function(t, src)
+   return __ctor__io.OffsetWriter(src)
+ end,
+};
+setmetatable(__type__.io.OffsetWriter, __type__.io.OffsetWriter);
+
+
 -----------------
 -- struct PipeReader
 -----------------
@@ -0,0 +1,137 @@
+package shadow_big
+
+import "math/big"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Ctor["ErrNaN"] = GijitShadow_NewStruct_ErrNaN
+    Ctor["Float"] = GijitShadow_NewStruct_Float
+    Ctor["Int"] = GijitShadow_NewStruct_Int
+    Pkg["Jacobi"] = big.Jacobi
+    Pkg["MaxBase"] = big.MaxBase
+    Pkg["MaxExp"] = big.MaxExp
+    Pkg["MaxPrec"] = big.MaxPrec
+    Pkg["MinExp"] = big.MinExp
+    Pkg["NewFloat"] = big.NewFloat
+    Pkg["NewInt"] = big.NewInt
+    Pkg["NewRat"] = big.NewRat
+    Pkg["ParseFloat"] = big.ParseFloat
+    Ctor["Rat"] = GijitShadow_NewStruct_Rat
+
+}
+func GijitShadow_NewStruct_ErrNaN(src *big.ErrNaN) *big.ErrNaN {
+    if src == nil {
+	   return &big.ErrNaN{}
+    }
+    a := *src
+    return &a
+}
+
+
+func GijitShadow_NewStruct_Float(src *big.Float) *big.Float {
+    if src == nil {
+	   return &big.Float{}
+    }
+    a := *src
+    return &a
+}
+
+
+func GijitShadow_NewStruct_Int(src *big.Int) *big.Int {
+    if src == nil {
+	   return &big.Int{}
+    }
+    a := *src
+    return &a
+}
+
+
+func GijitShadow_NewStruct_Rat(src *big.Rat) *big.Rat {
+    if src == nil {
+	   return &big.Rat{}
+    }
+    a := *src
+    return &a
+}
+
+
+
+ func InitLua() string {
+  return `
+__type__.big ={};
+
+-----------------
+-- struct ErrNaN
+-----------------
+
+__type__.big.ErrNaN = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "ErrNaN",
+ __call = function(t, src)
+   return __ctor__big.ErrNaN(src)
+ end,
+ -- a composite literal, &big.ErrNaN{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__big.ErrNaN(nil)
+ end,
+};
+setmetatable(__type__.big.ErrNaN, __type__.big.ErrNaN);
+
+
+-----------------
+-- struct Float
+-----------------
+
+__type__.big.Float = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Float",
+ __call = function(t, src)
+   return __ctor__big.Float(src)
+ end,
+ -- a composite literal, &big.Float{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__big.Float(nil)
+ end,
+};
+setmetatable(__type__.big.Float, __type__.big.Float);
+
+
+-----------------
+-- struct Int
+-----------------
+
+__type__.big.Int = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Int",
+ __call = function(t, src)
+   return __ctor__big.Int(src)
+ end,
+ -- a composite literal, &big.Int{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__big.Int(nil)
+ end,
+};
+setmetatable(__type__.big.Int, __type__.big.Int);
+
+
+-----------------
+-- struct Rat
+-----------------
+
+__type__.big.Rat = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Rat",
+ __call = function(t, src)
+   return __ctor__big.Rat(src)
+ end,
+ -- a composite literal, &big.Rat{}, is its zero value.
+ ptrToNewlyConstructed = function()
+   return __ctor__big.Rat(nil)
+ end,
+};
+setmetatable(__type__.big.Rat, __type__.big.Rat);
+
+
+`}
\ No newline at end of file
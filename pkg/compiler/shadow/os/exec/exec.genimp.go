@@ -0,0 +1,94 @@
+package shadow_exec
+
+import "os/exec"
+
+var Pkg = make(map[string]interface{})
+var Ctor = make(map[string]interface{})
+
+func init() {
+    Ctor["Cmd"] = GijitShadow_NewStruct_Cmd
+    Pkg["Command"] = exec.Command
+    Pkg["CommandContext"] = exec.CommandContext
+    Pkg["ErrDot"] = exec.ErrDot
+    Pkg["ErrNotFound"] = exec.ErrNotFound
+    Pkg["ErrWaitDelay"] = exec.ErrWaitDelay
+    Ctor["Error"] = GijitShadow_NewStruct_Error
+    Ctor["ExitError"] = GijitShadow_NewStruct_ExitError
+    Pkg["LookPath"] = exec.LookPath
+
+}
+func GijitShadow_NewStruct_Cmd(src *exec.Cmd) *exec.Cmd {
+    if src == nil {
+	   return &exec.Cmd{}
+    }
+    a := *src
+    return &a
+}
+
+
+func GijitShadow_NewStruct_Error(src *exec.Error) *exec.Error {
+    if src == nil {
+	   return &exec.Error{}
+    }
+    a := *src
+    return &a
+}
+
+
+func GijitShadow_NewStruct_ExitError(src *exec.ExitError) *exec.ExitError {
+    if src == nil {
+	   return &exec.ExitError{}
+    }
+    a := *src
+    return &a
+}
+
+
+
+ func InitLua() string {
+  return `
+__type__.exec ={};
+
+-----------------
+-- struct Cmd
+-----------------
+
+__type__.exec.Cmd = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Cmd",
+ __call = function(t, src)
+   return __ctor__exec.Cmd(src)
+ end,
+};
+setmetatable(__type__.exec.Cmd, __type__.exec.Cmd);
+
+
+-----------------
+-- struct Error
+-----------------
+
+__type__.exec.Error = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "Error",
+ __call = function(t, src)
+   return __ctor__exec.Error(src)
+ end,
+};
+setmetatable(__type__.exec.Error, __type__.exec.Error);
+
+
+-----------------
+-- struct ExitError
+-----------------
+
+__type__.exec.ExitError = {
+ __name = "native_Go_struct_type_wrapper",
+ __native_type = "ExitError",
+ __call = function(t, src)
+   return __ctor__exec.ExitError(src)
+ end,
+};
+setmetatable(__type__.exec.ExitError, __type__.exec.ExitError);
+
+
+`}
\ No newline at end of file
@@ -13,14 +13,19 @@ func init() {
     Pkg["Chtimes"] = os.Chtimes
     Pkg["Clearenv"] = os.Clearenv
     Pkg["Create"] = os.Create
+    Pkg["CreateTemp"] = os.CreateTemp
     Pkg["DevNull"] = os.DevNull
+    Pkg["DirEntry"] = GijitShadow_InterfaceConvertTo2_DirEntry
+    Pkg["DirFS"] = os.DirFS
     Pkg["Environ"] = os.Environ
     Pkg["ErrClosed"] = os.ErrClosed
+    Pkg["ErrDeadlineExceeded"] = os.ErrDeadlineExceeded
     Pkg["ErrExist"] = os.ErrExist
     Pkg["ErrInvalid"] = os.ErrInvalid
     Pkg["ErrNoDeadline"] = os.ErrNoDeadline
     Pkg["ErrNotExist"] = os.ErrNotExist
     Pkg["ErrPermission"] = os.ErrPermission
+    Pkg["ErrProcessDone"] = os.ErrProcessDone
     Pkg["Executable"] = os.Executable
     Pkg["Exit"] = os.Exit
     Pkg["Expand"] = os.Expand
@@ -53,6 +58,7 @@ func init() {
     Pkg["Lstat"] = os.Lstat
     Pkg["Mkdir"] = os.Mkdir
     Pkg["MkdirAll"] = os.MkdirAll
+    Pkg["MkdirTemp"] = os.MkdirTemp
     Pkg["NewFile"] = os.NewFile
     Pkg["NewSyscallError"] = os.NewSyscallError
     Pkg["O_APPEND"] = os.O_APPEND
@@ -72,6 +78,8 @@ func init() {
     Ctor["ProcAttr"] = GijitShadow_NewStruct_ProcAttr
     Ctor["Process"] = GijitShadow_NewStruct_Process
     Ctor["ProcessState"] = GijitShadow_NewStruct_ProcessState
+    Pkg["ReadDir"] = os.ReadDir
+    Pkg["ReadFile"] = os.ReadFile
     Pkg["Readlink"] = os.Readlink
     Pkg["Remove"] = os.Remove
     Pkg["RemoveAll"] = os.RemoveAll
@@ -92,8 +100,22 @@ func init() {
     Pkg["TempDir"] = os.TempDir
     Pkg["Truncate"] = os.Truncate
     Pkg["Unsetenv"] = os.Unsetenv
+    Pkg["UserCacheDir"] = os.UserCacheDir
+    Pkg["UserConfigDir"] = os.UserConfigDir
+    Pkg["UserHomeDir"] = os.UserHomeDir
+    Pkg["WriteFile"] = os.WriteFile
 
 }
+func GijitShadow_InterfaceConvertTo2_DirEntry(x interface{}) (y os.DirEntry, b bool) {
+	y, b = x.(os.DirEntry)
+	return
+}
+
+func GijitShadow_InterfaceConvertTo1_DirEntry(x interface{}) os.DirEntry {
+	return x.(os.DirEntry)
+}
+
+
 func GijitShadow_NewStruct_File(src *os.File) *os.File {
     if src == nil {
 	   return &os.File{}
@@ -0,0 +1,12 @@
+//go:build go1.12
+// +build go1.12
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.12, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["ReplaceAll"] = strings.ReplaceAll
+}
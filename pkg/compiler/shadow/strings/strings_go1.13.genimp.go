@@ -0,0 +1,12 @@
+//go:build go1.13
+// +build go1.13
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.13, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["ToValidUTF8"] = strings.ToValidUTF8
+}
@@ -0,0 +1,13 @@
+//go:build go1.18
+// +build go1.18
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.18, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["Clone"] = strings.Clone
+    Pkg["Cut"] = strings.Cut
+}
@@ -0,0 +1,13 @@
+//go:build go1.20
+// +build go1.20
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.20, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["CutPrefix"] = strings.CutPrefix
+    Pkg["CutSuffix"] = strings.CutSuffix
+}
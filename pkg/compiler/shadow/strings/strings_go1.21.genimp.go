@@ -0,0 +1,12 @@
+//go:build go1.21
+// +build go1.21
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.21, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["ContainsFunc"] = strings.ContainsFunc
+}
@@ -0,0 +1,16 @@
+//go:build go1.24
+// +build go1.24
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.24, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["FieldsFuncSeq"] = strings.FieldsFuncSeq
+    Pkg["FieldsSeq"] = strings.FieldsSeq
+    Pkg["Lines"] = strings.Lines
+    Pkg["SplitAfterSeq"] = strings.SplitAfterSeq
+    Pkg["SplitSeq"] = strings.SplitSeq
+}
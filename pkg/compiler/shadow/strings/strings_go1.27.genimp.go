@@ -0,0 +1,12 @@
+//go:build go1.27
+// +build go1.27
+
+package shadow_strings
+
+import "strings"
+
+// the funcs added to strings in go1.27, bound only by a gi
+// built with it or later.
+func init() {
+    Pkg["CutLast"] = strings.CutLast
+}
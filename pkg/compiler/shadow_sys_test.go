@@ -0,0 +1,44 @@
+package compiler
+
+import (
+	"testing"
+
+	shadow_os "github.com/gijit/gi/pkg/compiler/shadow/os"
+	shadow_exec "github.com/gijit/gi/pkg/compiler/shadow/os/exec"
+	shadow_filepath "github.com/gijit/gi/pkg/compiler/shadow/path/filepath"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1343SystemScriptingShadows(t *testing.T) {
+
+	cv.Convey(`os, os/exec, and path/filepath are shadowed for file I/O, processes, and paths`, t, func() {
+		for _, name := range []string{"Open", "Create", "ReadDir", "ReadFile", "WriteFile", "Stat", "MkdirTemp", "Getenv"} {
+			cv.So(shadow_os.Pkg[name], cv.ShouldNotBeNil)
+		}
+		cv.So(shadow_os.Pkg["DirEntry"], cv.ShouldNotBeNil)
+		cv.So(shadow_exec.Pkg["Command"], cv.ShouldNotBeNil)
+		cv.So(shadow_exec.Pkg["LookPath"], cv.ShouldNotBeNil)
+		cv.So(shadow_exec.Ctor["Cmd"], cv.ShouldNotBeNil)
+		for _, name := range []string{"Join", "Base", "Dir", "Ext", "Rel", "Glob", "WalkDir"} {
+			cv.So(shadow_filepath.Pkg[name], cv.ShouldNotBeNil)
+		}
+	})
+
+	cv.Convey(`the sandbox policy gates them: exec needs exec, and only path manipulation is free`, t, func() {
+		none := NewPolicy(CapNone)
+		cv.So(none.CheckImport("os/exec"), cv.ShouldNotBeNil)
+		cv.So(none.CheckImport("path/filepath"), cv.ShouldBeNil)
+		cv.So(none.deniedMembers("path/filepath", shadow_filepath.Pkg), cv.ShouldResemble,
+			[]string{"Abs", "EvalSymlinks", "Glob", "Walk", "WalkDir"})
+		cv.So(NewPolicy(CapExec).CheckImport("os/exec"), cv.ShouldBeNil)
+
+		fs := NewPolicy(CapFilesystem)
+		drop := fs.deniedMembers("os", shadow_os.Pkg)
+		cv.So(drop, cv.ShouldContain, "UserHomeDir")
+		cv.So(drop, cv.ShouldContain, "Getenv")
+		cv.So(drop, cv.ShouldContain, "StartProcess")
+		cv.So(drop, cv.ShouldNotContain, "ReadDir")
+		cv.So(drop, cv.ShouldNotContain, "WriteFile")
+		cv.So(drop, cv.ShouldNotContain, "ErrProcessDone")
+	})
+}
@@ -0,0 +1,72 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+	"github.com/glycerine/luar"
+)
+
+func Test1340ByteSlicesShareMemoryWithGo(t *testing.T) {
+
+	cv.Convey("a []byte passed from Lua to a Go function is the same memory, so the writes of each side show on the other", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		var kept []byte
+		luar.Register(it.lvm.vm, "", luar.Map{
+			"upper": func(b []byte) int {
+				kept = b
+				for i := range b {
+					if b[i] >= 'a' && b[i] <= 'z' {
+						b[i] -= 'a' - 'A'
+					}
+				}
+				return len(b)
+			},
+		})
+
+		panicOn(it.Eval(`b := []byte("hé!abc"); b[0] = 'H'; s := string(b)`))
+		LuaMustString(it.lvm, "s", "Hé!abc")
+
+		// a reslice is passed from its offset.
+		panicOn(LuaRun(it.lvm, `n = upper(__subslice(b, 4))`, false))
+		panicOn(it.Eval(`after := string(b)`))
+		LuaMustString(it.lvm, "after", "Hé!ABC")
+		cv.So(string(kept), cv.ShouldEqual, "ABC")
+
+		// Go kept the slice; it still sees Lua's writes.
+		panicOn(it.Eval(`b[5] = 'x'`))
+		cv.So(string(kept), cv.ShouldEqual, "AxC")
+	})
+
+	cv.Convey("[]byte values on shared buffers keep Go semantics in Lua", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`b := []byte("hello"); x := b[1]; var ix interface{} = x; _, isByte := ix.(byte)`))
+		LuaMustBool(it.lvm, "isByte", true)
+
+		panicOn(it.Eval(`c := make([]byte, 3); m := copy(c, b); cs := string(c)`))
+		LuaMustInt64(it.lvm, "m", 3)
+		LuaMustString(it.lvm, "cs", "hel")
+
+		panicOn(it.Eval(`d := append(b[:2], "ppy"...); ds := string(d); bs := string(b)`))
+		LuaMustString(it.lvm, "ds", "heppy")
+		LuaMustString(it.lvm, "bs", "heppy")
+
+		panicOn(it.Eval(`var f []byte; for i := 0; i < 100; i++ { f = append(f, 'z') }; fs := string(f[97:])`))
+		LuaMustString(it.lvm, "fs", "zzz")
+
+		panicOn(it.Eval(`e := b[1:3]; es := string(e)`))
+		LuaMustString(it.lvm, "es", "ep")
+
+		panicOn(it.Eval(`l := []byte{104, 105}; ls := string(l)`))
+		LuaMustString(it.lvm, "ls", "hi")
+
+		panicOn(it.Eval(`sum := 0; for _, c := range []byte("ab") { sum += int(c) }`))
+		LuaMustInt64(it.lvm, "sum", 97+98)
+	})
+}
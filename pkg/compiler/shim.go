@@ -0,0 +1,167 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/shim"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// A package with a shim, registered with pkg/shim or found
+// in the -shims directory, is imported from it, in place of
+// any shadow or implementation of gi's own; see pkg/shim.
+
+// shimSrc is the source of a shim, as registered or as
+// found in the shim directory.
+type shimSrc struct {
+	from  string // "registered", or the directory
+	files map[string]string
+	lua   []string
+}
+
+// findShim returns the shim for path, or nil if there is
+// none: that in the shim directory, else that registered.
+func (ic *IncrState) findShim(path string) (*shimSrc, error) {
+	if dir := ic.cfg.shimDir(); dir != "" {
+		s, err := readShimDir(filepath.Join(dir, filepath.FromSlash(path)), ic.cfg)
+		if s != nil || err != nil {
+			return s, err
+		}
+	}
+	if s, ok := shim.Lookup(path); ok {
+		return &shimSrc{
+			from:  "registered",
+			files: map[string]string{"shim.go": s.Go},
+			lua:   []string{s.Lua},
+		}, nil
+	}
+	return nil, nil
+}
+
+// readShimDir reads the shim in dir: its .go files, those
+// cfg's platform and tags select, and its .lua files, in
+// name order. It returns nil if dir has no .go files.
+func readShimDir(dir string, cfg *GIConfig) (*shimSrc, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctxt := cfg.buildContext()
+	s := &shimSrc{from: dir, files: make(map[string]string)}
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go"):
+			if ok, err := ctxt.MatchFile(dir, name); err != nil || !ok {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			s.files[name] = string(b)
+		case strings.HasSuffix(name, ".lua"):
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			s.lua = append(s.lua, string(b))
+		}
+	}
+	if len(s.files) == 0 {
+		return nil, nil
+	}
+	if len(s.lua) == 0 {
+		return nil, fmt.Errorf("the shim in %s has no .lua files to implement it", dir)
+	}
+	return s, nil
+}
+
+// importShim imports path from its shim s: it type checks
+// the API, then has t run the Lua, each file with a local
+// named for the package holding its table.
+func (ic *IncrState) importShim(path string, s *shimSrc, t *ticket) (*Archive, error) {
+	pkg, err := ic.checkShim(path, s)
+	if err != nil {
+		return nil, fmt.Errorf("shim for %s: %v", path, err)
+	}
+	for _, lua := range s.lua {
+		// each file a block of its own, for its locals.
+		t.run = append(t.run, fmt.Sprintf("do\nlocal %s = __gi_package(%q)\n", pkg.Name(), path)...)
+		t.run = append(t.run, lua...)
+		t.run = append(t.run, "\nend\n"...)
+	}
+	t.run = ic.cachedImportLua(path, t.run)
+	if err := t.Do(); err != nil {
+		return nil, fmt.Errorf("shim for %s: %v", path, err)
+	}
+
+	if ic.shimmed == nil {
+		ic.shimmed = make(map[string]string)
+	}
+	ic.shimmed[path] = s.from
+	ic.CurPkg.importContext.Packages[path] = pkg
+	return &Archive{
+		Name:       pkg.Name(),
+		ImportPath: path,
+		Pkg:        pkg,
+	}, nil
+}
+
+// checkShim returns the types of the API of the shim s of
+// path, prepared already if they were, and its imports
+// installed in the session; see impgraph.go.
+func (ic *IncrState) checkShim(path string, s *shimSrc) (*types.Package, error) {
+	if p := ic.takePrepared(path); p != nil && p.shim != nil {
+		for _, imp := range p.imports {
+			if imp == "unsafe" || ic.CurPkg.importContext.Packages[imp] != nil {
+				continue
+			}
+			if _, err := ic.CurPkg.importContext.Import(imp); err != nil {
+				return nil, err
+			}
+		}
+		return p.pkg, nil
+	}
+
+	fset := token.NewFileSet()
+	var names []string
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var files []*ast.File
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, name, s.files[name], 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	var importErr error
+	conf := &types.Config{
+		Importer: packageImporter{
+			importContext: ic.CurPkg.importContext,
+			importError:   &importErr,
+		},
+	}
+	pkg, _, err := conf.Check(nil, nil, path, fset, files, nil, nil)
+	if importErr != nil {
+		err = importErr
+	}
+	return pkg, err
+}
@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gijit/gi/pkg/shim"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func init() {
+	shim.Register("example.com/gitest/fast", shim.Shim{
+		Go: `package fast
+
+const Version = "registered"
+
+// Sum adds up xs.
+func Sum(xs []int) int { return 0 }
+`,
+		Lua: `
+fast.Sum = function(xs)
+   local tot = 0LL
+   for i = 0, #xs - 1 do
+      tot = tot + xs[i]
+   end
+   return tot
+end
+`,
+	})
+}
+
+func Test1393ShimsImplementPackagesInLua(t *testing.T) {
+
+	cv.Convey("a package with a shim registered is imported from it: its Go declares the API, its Lua implements it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "example.com/gitest/fast"`))
+		panicOn(it.Eval(`a := fast.Sum([]int{1, 2, 3}); v := fast.Version`))
+		LuaMustInt64(it.lvm, "a", 6)
+		LuaMustString(it.lvm, "v", "registered")
+
+		err = it.Eval(`b := fast.Sum([]string{"x"})`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "cannot use")
+
+		ps := it.Packages()
+		cv.So(ps[len(ps)-1].Path, cv.ShouldEqual, "example.com/gitest/fast")
+		cv.So(ps[len(ps)-1].From, cv.ShouldEqual, "shim")
+	})
+
+	cv.Convey("a shim in the -shims directory wins over one registered, and its .go files are chosen by build constraints", t, func() {
+		dir, err := ioutil.TempDir("", "gi-shims")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		pkgDir := filepath.Join(dir, "example.com", "gitest", "fast")
+		panicOn(os.MkdirAll(pkgDir, 0755))
+		for name, src := range map[string]string{
+			"fast.go":          "package fast\n\nfunc Sum(xs []int) int { return 0 }\n",
+			"version.go":       "//go:build !nosuch\n\npackage fast\n\nconst Version = \"from the directory\"\n",
+			"version_other.go": "//go:build nosuch\n\npackage fast\n\nconst Version = \"left out\"\n",
+			"a.lua":            "local function twice(x) return 2LL * x end\nfast.Sum = function(xs) return twice(#xs) end\n",
+			"b.lua":            "fast.Extra = 1\n",
+		} {
+			panicOn(ioutil.WriteFile(filepath.Join(pkgDir, name), []byte(src), 0644))
+		}
+		// no .lua, no shim.
+		noLua := filepath.Join(dir, "example.com", "gitest", "nolua")
+		panicOn(os.MkdirAll(noLua, 0755))
+		panicOn(ioutil.WriteFile(filepath.Join(noLua, "nolua.go"), []byte("package nolua\n"), 0644))
+
+		cfg := NewGIConfig()
+		cfg.ShimDir = dir
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "example.com/gitest/fast"`))
+		panicOn(it.Eval(`a := fast.Sum([]int{1, 2, 3}); v := fast.Version`))
+		LuaMustInt64(it.lvm, "a", 6)
+		LuaMustString(it.lvm, "v", "from the directory")
+
+		err = it.Eval(`import "example.com/gitest/nolua"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "has no .lua files to implement it")
+	})
+}
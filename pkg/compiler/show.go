@@ -0,0 +1,46 @@
+package compiler
+
+import (
+	"fmt"
+)
+
+// DefaultShowMaxDepth and DefaultShowMaxWidth are the
+// limits within which the REPL echoes values, until
+// -maxdepth, -maxwidth or :set change them.
+const (
+	DefaultShowMaxDepth = 8
+	DefaultShowMaxWidth = 100
+)
+
+// SetShowLimits sets how the values of expressions at the
+// prompt are echoed: a struct, map, slice or array nested
+// more than maxDepth deep is elided as T{...}, and of one
+// with more than maxWidth elements, the first maxWidth are
+// shown and the rest counted. Zero means no limit.
+func (it *Interp) SetShowLimits(maxDepth, maxWidth int) error {
+	if maxDepth < 0 || maxWidth < 0 {
+		return fmt.Errorf("maxdepth and maxwidth must not be negative")
+	}
+	it.mut.Lock()
+	defer it.mut.Unlock()
+
+	err := LuaRun(it.lvm, showLimitsLua(maxDepth, maxWidth), false)
+	if err != nil {
+		return err
+	}
+	it.cfg.ShowMaxDepth = maxDepth
+	it.cfg.ShowMaxWidth = maxWidth
+	return nil
+}
+
+// ShowLimits returns the limits set by SetShowLimits.
+func (it *Interp) ShowLimits() (maxDepth, maxWidth int) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.cfg.ShowMaxDepth, it.cfg.ShowMaxWidth
+}
+
+// showLimitsLua sets the limits of __gi_show, in tsys.lua.
+func showLimitsLua(maxDepth, maxWidth int) string {
+	return fmt.Sprintf("__gi_showMaxDepth = %d; __gi_showMaxWidth = %d;\n", maxDepth, maxWidth)
+}
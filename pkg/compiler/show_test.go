@@ -0,0 +1,82 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1369ShowPrettyPrintsValues(t *testing.T) {
+
+	cv.Convey("values echoed at the prompt should be shown nested and indented, cycle safe, and within the depth and width limits", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type P struct {
+	X    int
+	Name string
+	Tags []string
+	M    map[string]int
+	Next *P
+}
+p := &P{X: 1, Name: "a\n\"b\"", Tags: []string{"x", "y"}, M: map[string]int{"k": 2, "j": 1}}
+p.Next = p
+type T struct {
+	L *T
+	V int
+}
+t := &T{V: 1, L: &T{V: 2, L: &T{V: 3}}}
+s := make([]int, 10)
+m := map[int]float64{20: 2.5, 3: 1e6, 1: 0.1}`))
+
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(p, true)`, false))
+		LuaMustString(it.lvm, "__shown", `main.P{
+  X: 1,
+  Name: "a\n\"b\"",
+  Tags: []string{"x", "y"},
+  M: map[string]int{"j": 1, "k": 2},
+  Next: <cycle &main.P>,
+}`)
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(t)`, false))
+		LuaMustString(it.lvm, "__shown", `&main.T{L: &main.T{L: &main.T{L: nil, V: 3}, V: 2}, V: 1}`)
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(m)`, false))
+		LuaMustString(it.lvm, "__shown", `map[int]float64{1: 0.1, 3: 1e+06, 20: 2.5}`)
+
+		// limits passed in, then those set on the Interp.
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(t, false, 2, 0)`, false))
+		LuaMustString(it.lvm, "__shown", `&main.T{L: &main.T{L: &main.T{...}, V: 2}, V: 1}`)
+		panicOn(it.SetShowLimits(0, 3))
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(s)`, false))
+		LuaMustString(it.lvm, "__shown", `[]int{0, 0, 0, ... (7 more)}`)
+		depth, width := it.ShowLimits()
+		cv.So(depth, cv.ShouldEqual, 0)
+		cv.So(width, cv.ShouldEqual, 3)
+		cv.So(it.SetShowLimits(-1, 3), cv.ShouldNotBeNil)
+
+		// a long slice is packed several to a line.
+		panicOn(it.SetShowLimits(0, 0))
+		panicOn(it.Eval(`long := make([]string, 30)
+for i := range long {
+	long[i] = "abcde"
+}`))
+		panicOn(LuaRun(it.lvm, `__shown = __gi_showString(long)`, false))
+		LuaMustString(it.lvm, "__shown", `[]string{
+  "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde",
+  "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde",
+  "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde", "abcde",
+  "abcde", "abcde", "abcde", "abcde", "abcde", "abcde",
+}`)
+
+		// the REPL echoes through __gi_show, telling it when
+		// a value is a struct rather than a pointer to one.
+		lua, err := it.Translate(`p`)
+		panicOn(err)
+		cv.So(lua, cv.ShouldContainSubstring, `__gi_show(`)
+		cv.So(lua, cv.ShouldNotContainSubstring, `, true)`)
+		lua, err = it.Translate(`*p`)
+		panicOn(err)
+		cv.So(strings.TrimSpace(lua), cv.ShouldEndWith, `, true);`)
+	})
+}
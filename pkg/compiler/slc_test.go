@@ -168,3 +168,24 @@ func Test091AppendSlices(t *testing.T) {
 	})
 
 }
+
+func Test1320AnonTypeFirstUsedInFuncIsDeclaredOutsideIt(t *testing.T) {
+
+	cv.Convey(`a slice type first met inside a func body is usable by later code before the func ever runs`, t, func() {
+
+		code := `
+	   func first() float64 { return []float64{1, 2}[1] }
+	   func second() float64 { return []float64{3}[0] }
+	   n := second()
+	`
+		vm, err := NewLuaVmWithPrelude(nil)
+		panicOn(err)
+		defer vm.Close()
+		inc := NewIncrState(vm, nil)
+
+		translation := inc.trMust([]byte(code))
+		LuaRunAndReport(vm, string(translation))
+
+		LuaMustFloat64(vm, "n", 3)
+	})
+}
@@ -0,0 +1,117 @@
+package compiler
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowSortSrc declares the sort package to the type
+// checker. The sorts run in Lua, in prelude/zsort.lua, so
+// that the comparisons, closures included, stay inside
+// the traces LuaJIT compiles instead of crossing into Go
+// for each one; the bodies here are never run.
+//
+//	sort.Slice(people, func(i, j int) bool { return people[i].Age < people[j].Age })
+//	i := sort.SearchInts(sorted, 42)
+const shadowSortSrc = `package sort
+
+// Interface is what Sort, Stable and IsSorted need of a
+// collection: its elements are indexed by integer.
+type Interface interface {
+	Len() int
+	Less(i, j int) bool
+	Swap(i, j int)
+}
+
+// Sort sorts data in ascending order by Less; equal
+// elements may be reordered.
+func Sort(data Interface) {}
+
+// Stable sorts data in ascending order by Less, keeping
+// equal elements in their original order.
+func Stable(data Interface) {}
+
+func IsSorted(data Interface) bool { return false }
+
+// Reverse returns data with its order reversed.
+func Reverse(data Interface) Interface { return nil }
+
+// Slice sorts the slice x by less, which reports whether
+// x[i] sorts before x[j]. It panics if x is not a slice.
+func Slice(x interface{}, less func(i, j int) bool) {}
+
+// SliceStable is Slice, keeping equal elements in order.
+func SliceStable(x interface{}, less func(i, j int) bool) {}
+
+func SliceIsSorted(x interface{}, less func(i, j int) bool) bool { return false }
+
+// Search returns the smallest index i in [0, n) at which
+// f(i) is true, assuming f is false and then true over
+// the range; it returns n if there is none.
+func Search(n int, f func(int) bool) int { return 0 }
+
+// Find returns the smallest index i in [0, n) at which
+// cmp(i) <= 0, and whether cmp(i) is 0 there.
+func Find(n int, cmp func(int) int) (i int, found bool) { return 0, false }
+
+func Ints(x []int)         {}
+func Float64s(x []float64) {}
+func Strings(x []string)   {}
+
+func IntsAreSorted(x []int) bool         { return false }
+func Float64sAreSorted(x []float64) bool { return false }
+func StringsAreSorted(x []string) bool   { return false }
+
+func SearchInts(a []int, x int) int             { return 0 }
+func SearchFloat64s(a []float64, x float64) int { return 0 }
+func SearchStrings(a []string, x string) int    { return 0 }
+
+type IntSlice []int
+
+func (x IntSlice) Len() int           { return 0 }
+func (x IntSlice) Less(i, j int) bool { return false }
+func (x IntSlice) Swap(i, j int)      {}
+func (x IntSlice) Sort()              {}
+func (x IntSlice) Search(v int) int   { return 0 }
+
+// Float64Slice orders NaNs before the other values.
+type Float64Slice []float64
+
+func (x Float64Slice) Len() int             { return 0 }
+func (x Float64Slice) Less(i, j int) bool   { return false }
+func (x Float64Slice) Swap(i, j int)        {}
+func (x Float64Slice) Sort()                {}
+func (x Float64Slice) Search(v float64) int { return 0 }
+
+type StringSlice []string
+
+func (x StringSlice) Len() int            { return 0 }
+func (x StringSlice) Less(i, j int) bool  { return false }
+func (x StringSlice) Swap(i, j int)       {}
+func (x StringSlice) Sort()               {}
+func (x StringSlice) Search(v string) int { return 0 }
+`
+
+var shadowSort struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowSortPackage returns the type checker's view of
+// sort, shared by every Interp.
+func shadowSortPackage() *types.Package {
+	shadowSort.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "sort.go", shadowSortSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "sort", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowSort.pkg = pkg
+	})
+	return shadowSort.pkg
+}
@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1347Sort(t *testing.T) {
+
+	cv.Convey("sort.Slice and sort.SliceStable sort user slices with closures", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "sort"
+type P struct { Name string; Age int }
+ps := []P{{"d", 40}, {"a", 30}, {"c", 30}, {"b", 20}, {"e", 30}}
+first := &ps[0]
+sort.SliceStable(ps, func(i, j int) bool { return ps[i].Age < ps[j].Age })
+order := ""
+for _, p := range ps { order += p.Name }
+firstName := first.Name
+sorted := sort.SliceIsSorted(ps, func(i, j int) bool { return ps[i].Age < ps[j].Age })`))
+		LuaMustString(it.lvm, "order", "baced")
+		LuaMustString(it.lvm, "firstName", "b")
+		LuaMustBool(it.lvm, "sorted", true)
+
+		panicOn(it.Eval(`xs := make([]int, 1000)
+for i := range xs { xs[i] = (i * 7919) % 1000 }
+sort.Slice(xs, func(i, j int) bool { return xs[i] > xs[j] })
+ok := true
+for i := 1; i < len(xs); i++ { if xs[i-1] != 1000-i || xs[i] > xs[i-1] { ok = false } }`))
+		LuaMustBool(it.lvm, "ok", true)
+	})
+
+	cv.Convey("sort.Sort, Stable and Reverse take user types implementing sort.Interface", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "sort"
+type ByLen []string
+func (a ByLen) Len() int { return len(a) }
+func (a ByLen) Less(i, j int) bool { return len(a[i]) < len(a[j]) }
+func (a ByLen) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+w := []string{"ccc", "a", "bb", "dd", "e"}
+sort.Stable(ByLen(w))
+s1 := w[0] + w[1] + w[2] + w[3] + w[4]
+sort.Sort(sort.Reverse(ByLen(w)))
+s2 := w[0]
+is := sort.IsSorted(ByLen(w))`))
+		LuaMustString(it.lvm, "s1", "aebbddccc")
+		LuaMustString(it.lvm, "s2", "ccc")
+		LuaMustBool(it.lvm, "is", false)
+	})
+
+	cv.Convey("sort.Ints, Strings, Float64s, and the searches, match Go's", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "sort"
+import "math"
+a := []int{5, 2, 8, 1, 9}
+sort.Ints(a)
+i8 := sort.SearchInts(a, 8)
+i3 := sort.SearchInts(a, 3)
+ss := []string{"pear", "Apple", "apple", "fig"}
+sort.Strings(ss)
+s0 := ss[0]
+s3 := ss[3]
+fs := []float64{2.5, math.NaN(), -1}
+sort.Float64s(fs)
+nanFirst := fs[0] != fs[0]
+f2 := fs[2]
+n := sort.Search(100, func(i int) bool { return i*i >= 50 })
+at, found := sort.Find(len(a), func(i int) int { return 5 - a[i] })
+is := sort.StringSlice(ss)
+is.Sort()
+idx := is.Search("fig")`))
+		LuaMustInt64(it.lvm, "i8", 3)
+		LuaMustInt64(it.lvm, "i3", 2)
+		LuaMustString(it.lvm, "s0", "Apple")
+		LuaMustString(it.lvm, "s3", "pear")
+		LuaMustBool(it.lvm, "nanFirst", true)
+		LuaMustFloat64(it.lvm, "f2", 2.5)
+		LuaMustInt64(it.lvm, "n", 8)
+		LuaMustInt64(it.lvm, "at", 2)
+		LuaMustBool(it.lvm, "found", true)
+		LuaMustInt64(it.lvm, "idx", 2)
+	})
+}
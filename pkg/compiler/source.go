@@ -0,0 +1,180 @@
+package compiler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/scanner"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// DefinedSource is the source text of a declaration at the
+// prompt, as :source name shows it.
+type DefinedSource struct {
+	Name  string // as asked for: "f", "T" or "T.Method"
+	Input int    // the N of the repl[N] that declared it
+	Text  string
+}
+
+func (d *DefinedSource) String() string {
+	return fmt.Sprintf("// %s, from input %d (repl[%d])\n%s\n", d.Name, d.Input, d.Input, d.Text)
+}
+
+// declText is what recordDecls keeps of a declaration.
+type declText struct {
+	input int
+	text  string
+}
+
+// recordDecls keeps the source of the top level
+// declarations of file, input number input, which has just
+// been type checked from src, keyed by the objects they
+// declare. An input that is then rolled back leaves its
+// objects out of the scope, so what is kept of them is
+// never found.
+func (tr *IncrState) recordDecls(file *ast.File, src []byte, input int) {
+	fset := tr.CurPkg.fileSet
+	info := tr.CurPkg.Arch.TypesInfo
+	text := func(n ast.Node) string {
+		beg := fset.Position(n.Pos()).Offset
+		end := fset.Position(n.End()).Offset
+		if beg < 0 || end > len(src) || beg > end {
+			return ""
+		}
+		return string(src[beg:end])
+	}
+	keep := func(id *ast.Ident, s string) {
+		obj := info.Defs[id]
+		if obj == nil || s == "" {
+			return
+		}
+		if tr.declSrc == nil {
+			tr.declSrc = make(map[types.Object]declText)
+		}
+		tr.declSrc[obj] = declText{input: input, text: s}
+	}
+	for _, node := range file.Nodes {
+		if ds, ok := node.(*ast.DeclStmt); ok {
+			node = ds.Decl
+		}
+		switch x := node.(type) {
+		case *ast.FuncDecl:
+			keep(x.Name, text(x))
+		case *ast.GenDecl:
+			for _, spec := range x.Specs {
+				s := text(spec)
+				if len(x.Specs) == 1 && !x.Lparen.IsValid() {
+					s = text(x)
+				} else {
+					s = x.Tok.String() + " " + s
+				}
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					keep(sp.Name, s)
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						keep(id, s)
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE {
+				continue
+			}
+			for _, lhs := range x.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					keep(id, text(x))
+				}
+			}
+		}
+	}
+}
+
+// sourceNameRE matches what :source can show: a name, or
+// a method as Type.Method.
+var sourceNameRE = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)?$`)
+
+// Source returns the source text of the declaration, at the
+// prompt, of name: a func, type, var or const, or a method
+// as Type.Method.
+func (it *Interp) Source(name string) (*DefinedSource, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+	if !sourceNameRE.MatchString(name) {
+		return nil, fmt.Errorf("'%s' is not a name, or a method as Type.Method", name)
+	}
+	scope := it.inc.pkgScope()
+	parts := strings.SplitN(name, ".", 2)
+	obj := scope.Lookup(parts[0])
+	if obj == nil {
+		return nil, fmt.Errorf("%s is not declared", parts[0])
+	}
+	if len(parts) == 2 {
+		if _, ok := obj.(*types.TypeName); !ok {
+			return nil, fmt.Errorf("%s is not a type", parts[0])
+		}
+		m, _, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), parts[1])
+		if _, ok := m.(*types.Func); !ok {
+			return nil, fmt.Errorf("%s has no method %s", parts[0], parts[1])
+		}
+		obj = m
+	}
+	d, ok := it.inc.declSrc[obj]
+	if !ok {
+		return nil, fmt.Errorf("the source of %s is not known: it was not declared at the prompt", name)
+	}
+	return &DefinedSource{Name: name, Input: d.input, Text: d.text}, nil
+}
+
+// sourceColors are the SGR parameters highlightGo paints
+// with.
+var sourceColors = struct {
+	keyword, literal, comment string
+}{keyword: "01;34", literal: "32", comment: "90"}
+
+// highlightGo returns src with its keywords, literals and
+// comments colored for a terminal.
+func highlightGo(src string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	var b strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var sgr string
+		switch {
+		case tok.IsKeyword():
+			sgr = sourceColors.keyword
+		case tok.IsLiteral() && tok != token.IDENT:
+			sgr = sourceColors.literal
+		case tok == token.COMMENT:
+			sgr = sourceColors.comment
+		default:
+			continue
+		}
+		off := file.Offset(pos)
+		end := off + len(lit)
+		if tok.IsKeyword() {
+			end = off + len(tok.String())
+		}
+		if off < last || end > len(src) {
+			continue
+		}
+		b.WriteString(src[last:off])
+		b.WriteString(paint(sgr, src[off:end]))
+		last = end
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
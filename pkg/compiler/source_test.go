@@ -0,0 +1,52 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1380SourceOfDefinitions(t *testing.T) {
+
+	cv.Convey(":source shows the declaration of a func, type, method, var or const, and the input that declared it", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval("type Celsius struct{ C float64 }\nfunc (c Celsius) F() float64 {\n\treturn c.C*9/5 + 32\n}"))
+		panicOn(it.Eval("const (\n\tBoil = 100\n\tFreeze = 0\n)"))
+		panicOn(it.Eval("func twice(n int) int { return 2 * n }"))
+		panicOn(it.Eval("x := twice(4)"))
+		panicOn(it.Eval("var y, z = 1, 2"))
+
+		src := func(name string) string {
+			d, err := it.Source(name)
+			if err != nil {
+				return err.Error()
+			}
+			return d.String()
+		}
+		cv.So(src("Celsius"), cv.ShouldEqual, "// Celsius, from input 1 (repl[1])\ntype Celsius struct{ C float64 }\n")
+		cv.So(src("Celsius.F"), cv.ShouldEqual, "// Celsius.F, from input 1 (repl[1])\nfunc (c Celsius) F() float64 {\n\treturn c.C*9/5 + 32\n}\n")
+		cv.So(src("Freeze"), cv.ShouldEqual, "// Freeze, from input 2 (repl[2])\nconst Freeze = 0\n")
+		cv.So(src("twice"), cv.ShouldEqual, "// twice, from input 3 (repl[3])\nfunc twice(n int) int { return 2 * n }\n")
+		cv.So(src("x"), cv.ShouldEqual, "// x, from input 4 (repl[4])\nx := twice(4)\n")
+		cv.So(src("z"), cv.ShouldEqual, "// z, from input 5 (repl[5])\nvar y, z = 1, 2\n")
+
+		// a redefinition replaces what is shown; one that
+		// fails does not.
+		panicOn(it.Eval("func twice(n int) int {\n\treturn n + n\n}"))
+		cv.So(it.Eval("func twice(n int) int { return nope }"), cv.ShouldNotBeNil)
+		cv.So(src("twice"), cv.ShouldEqual, "// twice, from input 6 (repl[6])\nfunc twice(n int) int {\n\treturn n + n\n}\n")
+
+		cv.So(src("nope"), cv.ShouldEqual, "nope is not declared")
+		cv.So(src("twice.F"), cv.ShouldEqual, "twice is not a type")
+		cv.So(src("Celsius.C"), cv.ShouldEqual, "Celsius has no method C")
+		cv.So(src("a b"), cv.ShouldEqual, "'a b' is not a name, or a method as Type.Method")
+	})
+
+	cv.Convey("highlightGo colors keywords, literals and comments", t, func() {
+		cv.So(highlightGo("func f() string { return \"s\" } // c"), cv.ShouldEqual,
+			"\x1b[01;34mfunc\x1b[0m f() string { \x1b[01;34mreturn\x1b[0m \x1b[32m\"s\"\x1b[0m } \x1b[90m// c\x1b[0m")
+	})
+}
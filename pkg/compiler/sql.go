@@ -0,0 +1,131 @@
+package compiler
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// shadowSQLSrc declares database/sql to the type checker:
+// the part of the standard package a REPL session needs to
+// query local data files. The only driver is SQLite's, as
+// "sqlite3" or "sqlite", reached through LuaJIT's ffi; the
+// bodies here are never run, prelude/zsql.lua is the
+// package.
+//
+//	db, err := sql.Open("sqlite3", "data.db")
+//	rows, err := db.Query("select name, n from t where n > ?", 10)
+//	for rows.Next() { rows.Scan(&name, &n) }
+const shadowSQLSrc = `package sql
+
+// ErrNoRows is returned by Row.Scan when QueryRow finds no row.
+var ErrNoRows error
+
+// ErrTxDone is returned by the operations of a transaction
+// that has been committed or rolled back.
+var ErrTxDone error
+
+// Drivers returns the names of the registered drivers.
+func Drivers() []string { return nil }
+
+// DB is a handle on a database.
+type DB struct{}
+
+// Open opens the database dataSourceName with the driver
+// driverName. For SQLite that is a file name, a file: URI,
+// or ":memory:".
+func Open(driverName, dataSourceName string) (*DB, error) { return nil, nil }
+
+func (db *DB) Close() error { return nil }
+func (db *DB) Ping() error  { return nil }
+
+// Exec runs query, which may hold several statements, and
+// does not return rows. The args fill its ? placeholders.
+func (db *DB) Exec(query string, args ...interface{}) (Result, error) { return nil, nil }
+
+// Query runs query and returns its rows.
+func (db *DB) Query(query string, args ...interface{}) (*Rows, error) { return nil, nil }
+
+// QueryRow runs query, which is expected to return at most
+// one row; its errors are deferred until Row.Scan.
+func (db *DB) QueryRow(query string, args ...interface{}) *Row { return nil }
+
+// Begin starts a transaction.
+func (db *DB) Begin() (*Tx, error) { return nil, nil }
+
+// Tx is a transaction, ended by Commit or Rollback.
+type Tx struct{}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (Result, error) { return nil, nil }
+func (tx *Tx) Query(query string, args ...interface{}) (*Rows, error) { return nil, nil }
+func (tx *Tx) QueryRow(query string, args ...interface{}) *Row        { return nil }
+func (tx *Tx) Commit() error                                          { return nil }
+func (tx *Tx) Rollback() error                                        { return nil }
+
+// Result summarizes an executed statement.
+type Result interface {
+	LastInsertId() (int64, error)
+	RowsAffected() (int64, error)
+}
+
+// Rows is the result of a query. Its cursor starts before
+// the first row; use Next to advance from row to row.
+type Rows struct{}
+
+func (rs *Rows) Next() bool                     { return false }
+func (rs *Rows) Scan(dest ...interface{}) error { return nil }
+func (rs *Rows) Columns() ([]string, error)     { return nil, nil }
+func (rs *Rows) Err() error                     { return nil }
+func (rs *Rows) Close() error                   { return nil }
+
+// Row is the result of QueryRow.
+type Row struct{}
+
+func (r *Row) Scan(dest ...interface{}) error { return nil }
+func (r *Row) Err() error                     { return nil }
+
+// NullString is a string that may be NULL; so are the
+// other Null types.
+type NullString struct {
+	String string
+	Valid  bool
+}
+
+type NullInt64 struct {
+	Int64 int64
+	Valid bool
+}
+
+type NullFloat64 struct {
+	Float64 float64
+	Valid   bool
+}
+
+type NullBool struct {
+	Bool  bool
+	Valid bool
+}
+`
+
+var shadowSQL struct {
+	once sync.Once
+	pkg  *types.Package
+}
+
+// shadowSQLPackage returns the type checker's view of
+// database/sql, shared by every Interp.
+func shadowSQLPackage() *types.Package {
+	shadowSQL.once.Do(func() {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "sql.go", shadowSQLSrc, 0)
+		panicOn(err)
+		conf := &types.Config{}
+		pkg, _, err := conf.Check(nil, nil, "database/sql", fset, []*ast.File{file}, nil, nil)
+		panicOn(err)
+		shadowSQL.pkg = pkg
+	})
+	return shadowSQL.pkg
+}
@@ -0,0 +1,108 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// sqliteMissing reports why the sqlite3 library that
+// database/sql runs on cannot be loaded, or "" if it can.
+func sqliteMissing() string {
+	it, err := NewInterp(nil)
+	panicOn(err)
+	defer it.Close()
+	panicOn(it.Eval(`import "database/sql"`))
+	panicOn(LuaRun(it.lvm, `local lib, err = __gi_sqliteLib(); __gi_sqliteMissing = lib == nil and tostring(err) or ""`, false))
+	return luaGlobalString(it.lvm, "__gi_sqliteMissing")
+}
+
+func Test1342DatabaseSQLWithSQLite(t *testing.T) {
+
+	// the library is the system's, and may not be installed.
+	withSQLite := cv.Convey
+	if why := sqliteMissing(); why != "" {
+		t.Logf("skipping the tests that need sqlite3: %s", why)
+		withSQLite = cv.SkipConvey
+	}
+
+	withSQLite("database/sql runs queries on an SQLite file, with ? arguments, and Scan converts to the destinations' types", t, func() {
+		dir, err := ioutil.TempDir("", "gi-sql")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "database/sql"`))
+		panicOn(it.Eval(fmt.Sprintf(`db, err := sql.Open("sqlite3", %q)`, filepath.Join(dir, "t.db"))))
+		panicOn(it.Eval(`_, err = db.Exec("create table t (name text, n integer, x real, note text); create index t_n on t (n)")`))
+		panicOn(it.Eval(`res, err := db.Exec("insert into t values (?, ?, ?, ?)", "a", 1, 0.5, nil); id, _ := res.LastInsertId()`))
+		LuaMustInt64(it.lvm, "id", 1)
+		panicOn(it.Eval(`_, err = db.Exec("insert into t values (?, ?, ?, ?)", "b", 20, 2.5, "hi")`))
+
+		panicOn(it.Eval(`rows, err := db.Query("select name, n, x from t where n > ? order by n", 0)
+names := ""; total := 0; sum := 0.0
+for rows.Next() {
+	var name string
+	var n int
+	var x float64
+	err = rows.Scan(&name, &n, &x)
+	names += name; total += n; sum += x
+}
+rows.Close()`))
+		LuaMustString(it.lvm, "names", "ab")
+		LuaMustInt64(it.lvm, "total", 21)
+		LuaMustFloat64(it.lvm, "sum", 3)
+
+		panicOn(it.Eval(`var note sql.NullString; err = db.QueryRow("select note from t where name = ?", "a").Scan(&note); valid := note.Valid`))
+		LuaMustBool(it.lvm, "valid", false)
+		panicOn(it.Eval(`err = db.QueryRow("select note from t where name = ?", "b").Scan(&note); valid = note.Valid; s := note.String`))
+		LuaMustBool(it.lvm, "valid", true)
+		LuaMustString(it.lvm, "s", "hi")
+
+		// an interface{} destination gets the column's own type.
+		panicOn(it.Eval(`var v interface{}; err = db.QueryRow("select n from t where name = 'b'").Scan(&v)`))
+		LuaMustInt64(it.lvm, "v", 20)
+		panicOn(it.Eval(`err = db.QueryRow("select name from t where n = 20").Scan(&v); vs, isString := v.(string)`))
+		LuaMustBool(it.lvm, "isString", true)
+		LuaMustString(it.lvm, "vs", "b")
+
+		panicOn(it.Eval(`err = db.QueryRow("select n from t where name = ?", "zz").Scan(&v); none := err == sql.ErrNoRows`))
+		LuaMustBool(it.lvm, "none", true)
+
+		panicOn(it.Eval(`_, err = db.Exec("insert into nosuch values (1)"); es := err.Error()`))
+		LuaMustString(it.lvm, "es", "no such table: nosuch")
+		panicOn(it.Eval(`db.Close()`))
+	})
+
+	withSQLite("database/sql transactions commit or roll back", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "database/sql"`))
+		panicOn(it.Eval(`db, _ := sql.Open("sqlite", ":memory:"); db.Exec("create table t (n integer)")`))
+		panicOn(it.Eval(`tx, err := db.Begin(); tx.Exec("insert into t values (1)"); err = tx.Rollback()`))
+		panicOn(it.Eval(`tx, _ = db.Begin(); tx.Exec("insert into t values (2)"); err = tx.Commit(); again := tx.Commit() == sql.ErrTxDone`))
+		LuaMustBool(it.lvm, "again", true)
+		panicOn(it.Eval(`var n int; err = db.QueryRow("select sum(n) from t").Scan(&n)`))
+		LuaMustInt64(it.lvm, "n", 2)
+	})
+
+	cv.Convey("database/sql needs the filesystem capability", t, func() {
+		cfg := NewGIConfig()
+		cfg.Policy = NewPolicy(CapNone)
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+		err = it.Eval(`import "database/sql"`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "denied by sandbox policy")
+	})
+}
@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// srcLoc is a line of Go source, and the
+// function it belongs to.
+type srcLoc struct {
+	fn   string
+	file string
+	line int
+}
+
+// Frame is one call of a Lua stack, located in the
+// Go source it was translated from when that is known.
+type Frame struct {
+	Func string
+	File string
+	Line int
+}
+
+// luaSourceMap maps the Lua lines of translations back to
+// Go, so that Lua stacks, from the profiler or of blocked
+// goroutines, can be shown as Go functions and lines. Each
+// translation it maps becomes a chunk, loaded by
+// __gijitMainEval under the name "gi#<id>".
+type luaSourceMap struct {
+	// mu guards chunks, which a pipelined eval adds to
+	// while it runs those added before.
+	mu     sync.Mutex
+	chunks [][]srcLoc // chunk id -> Lua line -> Go source
+}
+
+// chunkPrefix starts the first line of a mapped
+// chunk, and is followed by the chunk id.
+const chunkPrefix = "--gi#"
+
+var stmtMarkRE = regexp.MustCompile(`--\[\[gi:(\d+)\]\]`)
+
+// mapChunk records where the lines of lua, the translation
+// of input, came from. Statements were marked during
+// translation with their position; each Lua line is taken
+// to belong to the last statement marked before it ends.
+// It returns lua with its chunk id header.
+func (sm *luaSourceMap) mapChunk(lua []byte, input string, files []*ast.File, fset *token.FileSet) []byte {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	id := len(sm.chunks)
+	header := fmt.Sprintf("%s%d\n", chunkPrefix, id)
+	spans := funcSpans(files, "main."+input)
+
+	lines := strings.Split(header+string(lua), "\n")
+	locs := make([]srcLoc, len(lines)+1) // Lua lines count from 1.
+	cur := srcLoc{fn: "main." + input, file: input}
+	for i, ln := range lines {
+		if m := stmtMarkRE.FindStringSubmatch(ln); m != nil {
+			p, _ := strconv.Atoi(m[1])
+			pos := token.Pos(p)
+			cur = srcLoc{
+				fn:   innermostFunc(spans, pos, "main."+input),
+				file: input,
+				line: fset.Position(pos).Line,
+			}
+		}
+		locs[i+1] = cur
+	}
+	sm.chunks = append(sm.chunks, locs)
+	return append([]byte(header), lua...)
+}
+
+// funcSpan is the extent of a named function.
+type funcSpan struct {
+	pos, end token.Pos
+	name     string
+}
+
+// funcSpans lists the functions in files, outer before inner,
+// named the way Go's runtime names them: main.F, main.T.M or
+// main.(*T).M, and main.F.func1 for the first function literal
+// in F. Literals outside any function are named after top.
+func funcSpans(files []*ast.File, top string) []funcSpan {
+	var spans []funcSpan
+	nlit := make(map[string]int)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				spans = append(spans, funcSpan{n.Pos(), n.End(), "main." + funcDeclName(n)})
+			case *ast.FuncLit:
+				parent := innermostFunc(spans, n.Pos(), top)
+				nlit[parent]++
+				spans = append(spans, funcSpan{n.Pos(), n.End(), fmt.Sprintf("%s.func%d", parent, nlit[parent])})
+			}
+			return true
+		})
+	}
+	return spans
+}
+
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	switch t := fd.Recv.List[0].Type.(type) {
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			return "(*" + id.Name + ")." + fd.Name.Name
+		}
+	case *ast.Ident:
+		return t.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// innermostFunc names the deepest function of spans
+// holding pos, or returns top if there is none.
+func innermostFunc(spans []funcSpan, pos token.Pos, top string) string {
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].pos <= pos && pos < spans[i].end {
+			return spans[i].name
+		}
+	}
+	return top
+}
+
+// frame resolves one frame of a Lua stack, as written
+// by jit.profile.dumpstack: chunk:line for Lua code,
+// [builtin#N] for builtins, or @address for C functions.
+// isGo reports whether the frame was mapped back to Go.
+func (sm *luaSourceMap) frame(s string) (f Frame, isGo bool) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Frame{Func: s}, false
+	}
+	chunk := s[:i]
+	line, _ := strconv.Atoi(s[i+1:])
+	if loc, ok := sm.lookup(chunk, line); ok {
+		return Frame{Func: loc.fn, File: loc.file, Line: loc.line}, true
+	}
+	// Lua not translated from Go, such as the prelude.
+	return Frame{Func: "lua " + chunk, File: chunk, Line: line}, false
+}
+
+func (sm *luaSourceMap) lookup(chunk string, line int) (loc srcLoc, ok bool) {
+	if sm == nil || !strings.HasPrefix(chunk, "gi#") {
+		return
+	}
+	id, err := strconv.Atoi(chunk[len("gi#"):])
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if err != nil || id >= len(sm.chunks) || line < 0 || line >= len(sm.chunks[id]) {
+		return
+	}
+	return sm.chunks[id][line], true
+}
+
+var chunkLocRE = regexp.MustCompile(`gi#\d+:\d+`)
+
+// goLocs rewrites the chunk:line locations in msg, such as
+// the one Lua puts before an error message, as the input
+// and line of Go they were translated from.
+func (sm *luaSourceMap) goLocs(msg string) string {
+	return chunkLocRE.ReplaceAllStringFunc(msg, func(s string) string {
+		if f, isGo := sm.frame(s); isGo {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		return s
+	})
+}
+
+// goStack resolves a Lua stack of ';' separated frames,
+// leaf first, keeping only the frames mapped back to Go.
+func (sm *luaSourceMap) goStack(s string) (stack []Frame) {
+	if s == "" {
+		return nil
+	}
+	for _, fr := range strings.Split(s, ";") {
+		if f, isGo := sm.frame(fr); isGo {
+			stack = append(stack, f)
+		}
+	}
+	return
+}
+
+// writeStack writes stack the way Go prints a goroutine's.
+func writeStack(b *strings.Builder, stack []Frame) {
+	for _, f := range stack {
+		fmt.Fprintf(b, "%s()\n\t%s:%d\n", f.Func, f.File, f.Line)
+	}
+}
@@ -26,6 +26,26 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 
 	c.SetPos(stmt.Pos())
 
+	if label == nil && c.bufferStrings(stmt) {
+		return
+	}
+
+	if c.p.cover != nil {
+		if id := c.p.cover.add(c.p.fileSet, stmt); id >= 0 {
+			c.Printf("__gi_cov[%d] = true;", id)
+		}
+	}
+	if c.p.markStmts && stmt.Pos().IsValid() {
+		c.Printf("--[[gi:%d]]", stmt.Pos())
+	}
+
+	if s, ok := stmt.(*ast.AssignStmt); ok {
+		if add, ok := c.addToBuffer(s); ok {
+			c.Printf("%s", add)
+			return
+		}
+	}
+
 	stmt = filter.IncDecStmt(stmt, c.p.Info.Info)
 	stmt = filter.Assign(stmt, c.p.Info.Info, c.p.Info.Pkg)
 
@@ -121,7 +141,9 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 				return c.formatExpr("%s == __gi_ifaceNil", refVar)
 			}
 			// jea, type assertion place 1
-			return c.formatExpr(`__assertType(%s, %s, 1)`, refVar, c.typeName(0, c.p.TypeOf(cond)))
+			// the ok, not the value, which is the zero value
+			// on a mismatch and is true for Lua.
+			return c.formatExpr(`select(2, __assertType(%s, %s, 1))`, refVar, c.typeName(0, c.p.TypeOf(cond)))
 			//return c.formatExpr("__assertType(%s, %s, true)[1]", refVar, c.typeName(0, c.p.TypeOf(cond)))
 		}
 		var caseClauses []*ast.CaseClause
@@ -134,7 +156,8 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 				if typesutil.IsJsObject(implicit.Type().Underlying()) {
 					value += ".__val.object"
 				} else if _, ok := implicit.Type().Underlying().(*types.Interface); !ok {
-					value += ".__val"
+					// unboxes, and leaves a bare basic value.
+					value = fmt.Sprintf("__assertType(%s, %s, 0)", refVar, c.typeName(0, implicit.Type()))
 				}
 				bodyPrefix = []ast.Stmt{&ast.AssignStmt{
 					Lhs: []ast.Expr{c.newIdent(c.objectName(implicit), implicit.Type())},
@@ -155,6 +178,10 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 		c.translateBranchingStmt(caseClauses, defaultClause, true, translateCond, label, c.Flattened[s])
 
 	case *ast.ForStmt:
+		if i, xs, ok := c.flatLoop(s); ok && label == nil {
+			c.translateFlatLoop(s, i, xs)
+			break
+		}
 		if s.Init != nil {
 			c.translateStmt(s.Init, nil)
 		}
@@ -177,8 +204,28 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 		switch t := c.p.TypeOf(s.X).Underlying().(type) {
 		case *types.Basic:
 			c.Printf("%s = %s;", refVar, c.translateExpr(s.X, nil))
-			c.Printf("%s = __utf8.len(%s)", lenRefVar, refVar)
 			iVar := c.newVariable("_i")
+			if isInteger(t) {
+				// range n, as of Go 1.22: the key runs from a
+				// zero of n's type up to n-1.
+				keyType := c.p.TypeOf(s.X)
+				if t.Info()&types.IsUntyped != 0 {
+					keyType = types.Typ[types.Int]
+				}
+				c.Printf("%s = %s;", iVar, c.translateExpr(c.zeroValue(keyType), nil))
+				c.translateLoopingStmt(func() string { return iVar + " < " + refVar }, s.Body, func() {
+					if !isBlank(s.Key) {
+						c.Printf("%s", c.translateAssign(s.Key, c.newIdent(iVar, keyType), s.Tok == token.DEFINE))
+					}
+				}, func() {
+					c.Printf("%s = %s + 1;", iVar, iVar)
+				}, label, c.Flattened[s])
+				break
+			}
+			// a string, ranged over by rune: the key is the
+			// byte offset of each, and an invalid encoding
+			// gives one U+FFFD per byte.
+			c.Printf("%s = #%s;", lenRefVar, refVar)
 			c.Printf("%s = 0;", iVar)
 			runeVar := c.newVariable("_rune")
 			c.translateLoopingStmt(func() string { return iVar + " < " + lenRefVar }, s.Body, func() {
@@ -187,7 +234,7 @@ func (c *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 					c.Printf("%s", c.translateAssign(s.Key, c.newIdent(iVar, types.Typ[types.Int]), s.Tok == token.DEFINE))
 				}
 				if !isBlank(s.Value) {
-					c.Printf("%s", c.translateAssign(s.Value, c.newIdent(runeVar+"[1]", types.Typ[types.Rune]), s.Tok == token.DEFINE))
+					c.Printf("%s", c.translateAssign(s.Value, c.newIdent("int64("+runeVar+"[1])", types.Typ[types.Rune]), s.Tok == token.DEFINE))
 				}
 			}, func() {
 				c.Printf("%s = %s + %s[2];", iVar, iVar, runeVar)
@@ -445,49 +492,67 @@ __defer_func(%s)
 			c.Printf("%s", c.translateAssign(lhs, s.Rhs[0], s.Tok == token.DEFINE))
 
 		case len(s.Lhs) > 1 && len(s.Rhs) == 1:
-			/*	_tuple = <output of c.translateExpr(s.Rhs[0])>
-				a = _tuple[1];
-				ok = _tuple[2];
+			/*	_tuple1, _tuple2 = <output of c.translateExpr(s.Rhs[0])>
+				a = _tuple1;
+				ok = _tuple2;
 			*/
-
-			/*
-				tupleVar := c.newVariable("_tuple")
-				c.Printf("%s = %s;", tupleVar, c.translateExpr(s.Rhs[0]))
-				tuple := c.p.TypeOf(s.Rhs[0]).(*types.Tuple)
-				for i, lhs := range s.Lhs {
-					lhs = astutil.RemoveParens(lhs)
-					if !isBlank(lhs) {
-						c.Printf("%s", c.translateAssign(lhs, c.newIdent(fmt.Sprintf("%s[%d]", tupleVar, i+1), tuple.At(i).Type()), s.Tok == token.DEFINE))
-					}
-				}
-			*/
-			prep := []string{} // strings.Join(vars, ", ")
-			for _, lhs := range s.Lhs {
-				lhs = astutil.RemoveParens(lhs)
+			lhss := make([]ast.Expr, len(s.Lhs))
+			for i, lhs := range s.Lhs {
+				lhss[i] = c.captureLhsOperands(astutil.RemoveParens(lhs))
+			}
+			tuple, _ := c.p.TypeOf(s.Rhs[0]).(*types.Tuple)
+			tupleVars := make([]string, len(lhss))
+			for i := range lhss {
+				tupleVars[i] = c.gensym("_tuple")
+			}
+			c.Printf("%s%s = %s;", c.localPrefix(), strings.Join(tupleVars, ", "), c.translateExpr(s.Rhs[0], nil))
+			for i, lhs := range lhss {
 				if isBlank(lhs) {
-					prep = append(prep, "_")
+					continue
+				}
+				var elemType types.Type
+				if tuple != nil {
+					elemType = tuple.At(i).Type()
 				} else {
-					prep = append(prep, fmt.Sprintf("%s", c.translateExpr(lhs, nil)))
+					elemType = c.p.TypeOf(lhs)
+				}
+				if _, isIdent := lhs.(*ast.Ident); isIdent && isGoValueType(elemType) && types.Identical(elemType, c.p.TypeOf(lhs)) {
+					// a struct Go returned, as translateAssign
+					// leaves one from a single-valued call.
+					local := ""
+					if c.isDefinedHere(s, lhs) {
+						local = c.localPrefix()
+					}
+					c.Printf("%s%s = %s;", local, c.translateExpr(lhs, nil), tupleVars[i])
+					continue
 				}
+				c.Printf("%s", c.translateAssign(lhs, c.newIdent(tupleVars[i], elemType), c.isDefinedHere(s, lhs)))
 			}
-			c.Printf("%s = %s", strings.Join(prep, ", "), c.translateExpr(s.Rhs[0], nil))
 
 		case len(s.Lhs) == len(s.Rhs):
+			// spec: "First, the operands of index expressions
+			// and pointer indirections on the left and the
+			// expressions on the right are all evaluated in the
+			// usual order. Second, the assignments are carried
+			// out in left-to-right order."
+			lhss := make([]ast.Expr, len(s.Lhs))
+			for i, lhs := range s.Lhs {
+				lhss[i] = c.captureLhsOperands(astutil.RemoveParens(lhs))
+			}
 			tmpVars := make([]string, len(s.Rhs))
 			for i, rhs := range s.Rhs {
 				// jea
 				tmpVars[i] = c.gensym("_tmp") // newVariable("_tmp")
 				//tmpVars[i] = c.newVariable("_tmp")
-				if isBlank(astutil.RemoveParens(s.Lhs[i])) {
-					c.Printf("FUNKY __unused(%s);", c.translateExpr(rhs, nil))
+				if isBlank(lhss[i]) {
+					c.Printf("__unused(%s);", c.translateExpr(rhs, nil))
 					continue
 				}
-				c.Printf("%s", c.translateAssign(c.newIdent(tmpVars[i], c.p.TypeOf(s.Lhs[i])), rhs, true))
+				c.Printf("%s", c.translateAssign(c.newIdent(tmpVars[i], c.p.TypeOf(lhss[i])), rhs, true))
 			}
-			for i, lhs := range s.Lhs {
-				lhs = astutil.RemoveParens(lhs)
+			for i, lhs := range lhss {
 				if !isBlank(lhs) {
-					c.Printf("%s", c.translateAssign(lhs, c.newIdent(tmpVars[i], c.p.TypeOf(lhs)), s.Tok == token.DEFINE))
+					c.Printf("%s", c.translateAssign(lhs, c.newIdent(tmpVars[i], c.p.TypeOf(lhs)), c.isDefinedHere(s, lhs)))
 				}
 			}
 
@@ -532,6 +597,12 @@ __defer_func(%s)
 		}
 
 	case *ast.ExprStmt:
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if set, ok := c.inlineSetter(call); ok {
+				c.Printf("%s", set)
+				return
+			}
+		}
 		pp("calling c.translateExpr with s.X = '%#v'", s.X)
 		// 707here
 		expr := c.translateExpr(s.X, nil)
@@ -831,6 +902,8 @@ func (c *funcContext) translateForRangeStmt(s *ast.RangeStmt, body *ast.BlockStm
 	value := nameHelper(s.Value)
 
 	exprType := c.p.TypeOf(s.X)
+	rangeX, _ := s.X.(*ast.Ident)
+	rangeKey, _ := s.Key.(*ast.Ident)
 	isMap := false
 	switch exprType.(type) {
 	case *types.Map:
@@ -876,11 +949,33 @@ func (c *funcContext) translateForRangeStmt(s *ast.RangeStmt, body *ast.BlockStm
 
 		// for loops AND array indexes in Lua require float64
 		s := fmt.Sprintf("do  %[3]s\n\t local %[5]s = 0; local %[4]s = __lenz(%[2]s);\n\t while %[5]s < %[4]s do\n\t\n", key, target, addMe, loopLim, privateI)
+		var flat *flatSlice
+		if xs, ok := c.p.Uses[rangeX].(*types.Var); ok && rangeX != nil && isNumericSlice(exprType) {
+			// reach the elements directly; see flatloop.go.
+			flat = &flatSlice{slice: xs, arr: c.gensym("arr"), off: c.gensym("off")}
+			s = fmt.Sprintf("do  %[3]s\n\t local %[6]s, %[7]s = %[2]s.__array, tonumber(%[2]s.__offset);\n\t local %[5]s = 0; local %[4]s = __lenz(%[2]s);\n\t while %[5]s < %[4]s do\n\t\n", key, target, addMe, loopLim, privateI, flat.arr, flat.off)
+		}
 		if !keyUnder {
 			s += fmt.Sprintf("\t %[1]s = %[2]s;\n", key, privateI)
 		}
 		if !valUnder {
-			s += fmt.Sprintf("\t %s = %s[%s];\n", value, target, privateI)
+			if flat != nil {
+				s += fmt.Sprintf("\t %s = %s[%s + %s];\n", value, flat.arr, flat.off, privateI)
+			} else {
+				s += fmt.Sprintf("\t %s = %s[%s];\n", value, target, privateI)
+			}
+		}
+		if flat != nil && isDefine && !keyUnder {
+			// the key, a Lua number here, indexes the slice
+			// directly too, while neither changes.
+			i, _ := c.p.Defs[rangeKey].(*types.Var)
+			if i != nil && c.declaresHere(flat.slice) && !c.mayChange(flat.slice, body) && !c.mayChange(i, body) {
+				if c.flat == nil {
+					c.flat = make(map[*types.Var]flatSlice)
+				}
+				c.flat[i] = *flat
+				defer delete(c.flat, i)
+			}
 		}
 		c.Printf("%s", s)
 	}
@@ -978,6 +1073,16 @@ func (c *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 		panic("translateAssign with blank lhs")
 	}
 	pp("jea: in translateAssign for lhs='%#v', rhs='%#v', define=%v.  Go lhs:'%s', Go rhs:'%s'", lhs, rhs, define, c.exprToString(lhs), c.exprToString(rhs))
+	if define {
+		if decl, ok := c.scalarDecl(lhs); ok {
+			return decl
+		}
+	}
+	if sel, ok := lhs.(*ast.SelectorExpr); ok {
+		if name, ok := c.scalarField(sel); ok {
+			return fmt.Sprintf("%s = %s;", name, c.translateImplicitConversion(rhs, c.p.TypeOf(sel)))
+		}
+	}
 	if l, ok := lhs.(*ast.IndexExpr); ok {
 		if t, ok := c.p.TypeOf(l.X).Underlying().(*types.Map); ok {
 			if typesutil.IsJsObject(c.p.TypeOf(l.Index)) {
@@ -1031,6 +1136,11 @@ func (c *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 		switch lhsType.Underlying().(type) {
 		case *types.Array, *types.Struct:
 			pp("not a refelct value, underlying is array or struct")
+			if _, isCall := astutil.RemoveParens(rhs).(*ast.CallExpr); isCall && isGoValueType(lhsType) {
+				// what Go returned is already luar's copy of it,
+				// which __clone cannot always copy back to Go.
+				return fmt.Sprintf("%s%s = %s;", local, c.translateExpr(lhs, nil), rhsExpr)
+			}
 			if define {
 				pp("define is true, not a refelct value, underlying is array or struct")
 				typName, isAnon, anonType, createdNm := c.typeNameWithAnonInfo(lhsType)
@@ -1043,7 +1153,7 @@ func (c *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 
 				}
 			}
-			return fmt.Sprintf("%s.__copy(%s, %s);", c.typeName(0, lhsType), c.translateExpr(lhs, nil), rhsExpr)
+			return fmt.Sprintf("%s.copy(%s, %s);", c.typeName(0, lhsType), c.translateExpr(lhs, nil), rhsExpr)
 		}
 	}
 
@@ -1083,6 +1193,9 @@ func (c *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 			*/
 		case *types.Slice:
 			pp("in assignment to slice, statements.go")
+			if flat, ok := c.flatIndex(l); ok {
+				return fmt.Sprintf("%s = %s;", flat, rhsExpr)
+			}
 			return c.formatExpr(setRangeCheck(c.p.Types[l.Index].Value != nil, false), l.X, l.Index, rhsExpr).String() + ";"
 			//return c.formatExpr(rangeCheck("%1e.__array[%1e.__offset + %2f] = %3s", c.p.Types[l.Index].Value != nil, false), l.X, l.Index, rhsExpr).String() + ";"
 		default:
@@ -1093,6 +1206,59 @@ func (c *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 	}
 }
 
+// localPrefix is "local " inside a function, where
+// translateAssign declares locals, and "" at the top level.
+func (c *funcContext) localPrefix() string {
+	if c.parent == nil {
+		return ""
+	}
+	return "local "
+}
+
+// isDefinedHere reports whether lhs is a variable that the
+// assignment s declares; in `a, err := f()` an err already
+// in scope is only assigned to.
+func (c *funcContext) isDefinedHere(s *ast.AssignStmt, lhs ast.Expr) bool {
+	if s.Tok != token.DEFINE {
+		return false
+	}
+	id, ok := lhs.(*ast.Ident)
+	return ok && c.p.Defs[id] != nil
+}
+
+// captureLhsOperands evaluates the operands of an index
+// expression or pointer indirection on the left of an
+// assignment into temporaries, and returns the lhs over
+// them, so that assigning to it later is not affected by
+// the assignments before it in the same statement.
+func (c *funcContext) captureLhsOperands(lhs ast.Expr) ast.Expr {
+	capture := func(e ast.Expr) ast.Expr {
+		e = astutil.RemoveParens(e)
+		if _, isIdent := e.(*ast.Ident); isIdent && isBlank(e) {
+			return e
+		}
+		if c.p.Types[e].Value != nil {
+			return e
+		}
+		tmp := c.newIdent(c.gensym("_lhs"), c.p.TypeOf(e))
+		c.Printf("%s%s = %s;", c.localPrefix(), tmp.Name, c.translateExpr(e, nil))
+		return tmp
+	}
+	switch l := lhs.(type) {
+	case *ast.IndexExpr:
+		x := l.X
+		if _, isArray := c.p.TypeOf(l.X).Underlying().(*types.Array); !isArray {
+			// an array is a value, so its variable, and
+			// not what it holds now, is indexed.
+			x = capture(l.X)
+		}
+		return c.setType(&ast.IndexExpr{X: x, Index: capture(l.Index)}, c.p.TypeOf(l))
+	case *ast.StarExpr:
+		return c.setType(&ast.StarExpr{X: capture(l.X)}, c.p.TypeOf(l))
+	}
+	return lhs
+}
+
 func (c *funcContext) translateResults(results []ast.Expr) string {
 	values := c.translateResultsPreJoin(results)
 	return "  " + strings.Join(values, ", ") + " "
@@ -0,0 +1,218 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/parser"
+)
+
+// EvalStats describes the resources used by one evaluation.
+// LuaJIT does not count allocations or time its collector,
+// so on the Lua side only the net heap change is known. The
+// Go side, which runs the shadow packages, is measured fully.
+type EvalStats struct {
+	Wall time.Duration
+
+	// LuaHeapKB is the change in the Lua heap size, in KB.
+	// It is negative if a collection freed more than was allocated.
+	LuaHeapKB float64
+
+	GoAllocs     uint64 // Go heap objects allocated
+	GoAllocBytes uint64
+	GoGCs        uint32 // Go collections completed
+	GoGCPause    time.Duration
+}
+
+func (s EvalStats) String() string {
+	return fmt.Sprintf("wall %v; lua heap %+.1f KB; go allocs %d (%s); go gc %d, paused %v",
+		s.Wall, s.LuaHeapKB, s.GoAllocs, byteSize(s.GoAllocBytes), s.GoGCs, s.GoGCPause)
+}
+
+func byteSize(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// luaGlobalString returns the string value of a Lua global.
+func luaGlobalString(lvm *LuaVm, name string) string {
+	t := lvm.goro.newTicket("", false)
+	t.gettyp = GetString
+	t.varname[name] = nil
+	panicOn(t.Do())
+	s, _ := t.varname[name].(string)
+	return s
+}
+
+// luaHeapKB returns the current size of the Lua heap.
+func luaHeapKB(lvm *LuaVm) float64 {
+	panicOn(LuaRun(lvm, `__gi_heapKB = tostring(collectgarbage("count"))`, false))
+	kb, err := strconv.ParseFloat(luaGlobalString(lvm, "__gi_heapKB"), 64)
+	panicOn(err)
+	return kb
+}
+
+// goMallocs returns the number of Go heap objects
+// allocated so far. Benchmarks call it from Lua.
+func goMallocs() float64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return float64(m.Mallocs)
+}
+
+// measure runs f, and returns what it used.
+func measure(lvm *LuaVm, f func() error) (st EvalStats, err error) {
+	var m0, m1 runtime.MemStats
+	heap0 := luaHeapKB(lvm)
+	runtime.ReadMemStats(&m0)
+	t0 := time.Now()
+
+	err = f()
+
+	st.Wall = time.Since(t0)
+	runtime.ReadMemStats(&m1)
+	st.LuaHeapKB = luaHeapKB(lvm) - heap0
+	st.GoAllocs = m1.Mallocs - m0.Mallocs
+	st.GoAllocBytes = m1.TotalAlloc - m0.TotalAlloc
+	st.GoGCs = m1.NumGC - m0.NumGC
+	st.GoGCPause = time.Duration(m1.PauseTotalNs - m0.PauseTotalNs)
+	return
+}
+
+// LastStats returns the resources used by the most recent
+// Eval. It is only recorded when the Interp's GIConfig has
+// Stats set, since measuring has a small cost of its own.
+func (it *Interp) LastStats() EvalStats {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	return it.lastStats
+}
+
+// TimeItResult reports the timing of a snippet, run Loops
+// times in a row, in each of len(PerLoop) repeats.
+type TimeItResult struct {
+	Loops   int
+	PerLoop []time.Duration // mean time per loop, one per repeat
+
+	Best   time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+
+	// Stats covers all the repeats together.
+	Stats EvalStats
+}
+
+func (r *TimeItResult) String() string {
+	return fmt.Sprintf("%d loops, best of %d: %v per loop (mean %v ± %v)",
+		r.Loops, len(r.PerLoop), r.Best, r.Mean, r.StdDev)
+}
+
+const (
+	timeitRepeats = 5
+
+	// timeitTarget is the least time one repeat should
+	// take; the number of loops grows until it does.
+	timeitTarget = 200 * time.Millisecond
+
+	timeitMaxLoops = 10000000
+)
+
+// timeitBodyName is the Lua global holding the
+// function under test during TimeIt.
+const timeitBodyName = "gijitTimeitBody"
+
+// TimeIt measures how long the Go statement or expression
+// src takes to run, like Python's timeit: src is run in
+// batches of loops, growing the batch by tens until one takes
+// at least 200ms, and then timed over several repeats. src
+// is compiled once, as the body of a function, so variables
+// it defines do not escape into the session.
+func (it *Interp) TimeIt(src string) (*TimeItResult, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+
+	scope := it.inc.pkgScope()
+	snap := takeScopeSnapshot(scope)
+	defer func() {
+		snap.restore(scope)
+		LuaRun(it.lvm, timeitBodyName+" = nil", false)
+	}()
+
+	body := src
+	if x, err := parser.ParseExpr(strings.TrimSpace(src)); err == nil {
+		if _, isCall := x.(*ast.CallExpr); !isCall {
+			body = "_ = " + src
+		}
+	}
+	wrapped := fmt.Sprintf("%s := func() {\n%s\n}", timeitBodyName, body)
+	translation, err := translateAndCatchPanic(it.inc, []byte(wrapped))
+	if err != nil {
+		return nil, err
+	}
+	if err := LuaRun(it.lvm, translation, false); err != nil {
+		return nil, err
+	}
+
+	run := func(loops int) (time.Duration, error) {
+		code := fmt.Sprintf("local f = %s; for i = 1, %d do f() end", timeitBodyName, loops)
+		t0 := time.Now()
+		err := LuaRun(it.lvm, code, false)
+		return time.Since(t0), err
+	}
+
+	res := &TimeItResult{Loops: 1}
+	for res.Loops < timeitMaxLoops {
+		d, err := run(res.Loops)
+		if err != nil {
+			return nil, err
+		}
+		if d >= timeitTarget {
+			break
+		}
+		res.Loops *= 10
+	}
+
+	res.Stats, err = measure(it.lvm, func() error {
+		for i := 0; i < timeitRepeats; i++ {
+			d, err := run(res.Loops)
+			if err != nil {
+				return err
+			}
+			res.PerLoop = append(res.PerLoop, d/time.Duration(res.Loops))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sum, sumsq float64
+	res.Best = res.PerLoop[0]
+	for _, d := range res.PerLoop {
+		if d < res.Best {
+			res.Best = d
+		}
+		sum += float64(d)
+		sumsq += float64(d) * float64(d)
+	}
+	n := float64(len(res.PerLoop))
+	mean := sum / n
+	res.Mean = time.Duration(mean)
+	res.StdDev = time.Duration(math.Sqrt(math.Max(0, sumsq/n-mean*mean)))
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1318TimeItAndEvalStats(t *testing.T) {
+
+	cv.Convey(`TimeIt runs a snippet over many loops and repeats, without leaking into the session; with Stats set, each Eval records what it used`, t, func() {
+		cfg := NewGIConfig()
+		cfg.Stats = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`calls := 0; func bump(k int) int { calls++; return k * 2 }`))
+
+		res, err := it.TimeIt(`y := bump(3); _ = y`)
+		panicOn(err)
+		cv.So(res.Loops >= 1, cv.ShouldBeTrue)
+		cv.So(len(res.PerLoop), cv.ShouldEqual, timeitRepeats)
+		cv.So(res.Best > 0, cv.ShouldBeTrue)
+		cv.So(res.Best <= res.Mean, cv.ShouldBeTrue)
+		cv.So(res.Stats.Wall >= res.Best*timeitRepeats, cv.ShouldBeTrue)
+
+		// the snippet really ran, against the session's state.
+		panicOn(it.Eval(`enough := calls >= 6`))
+		LuaMustBool(it.lvm, "enough", true)
+
+		// but neither y nor the body function stayed behind.
+		scope := it.inc.pkgScope()
+		cv.So(scope.Lookup("y"), cv.ShouldBeNil)
+		cv.So(scope.Lookup(timeitBodyName), cv.ShouldBeNil)
+
+		// a bare expression is timed too.
+		_, err = it.TimeIt(`calls + 1`)
+		panicOn(err)
+
+		panicOn(it.Eval(`big := make([]string, 20000)`))
+		st := it.LastStats()
+		cv.So(st.Wall > 0, cv.ShouldBeTrue)
+		cv.So(st.LuaHeapKB > 0, cv.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,218 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// A Go string is a Lua string, which Lua never changes, so
+//
+//	s := ""
+//	for _, w := range words {
+//		s += w
+//	}
+//
+// copies all of s each time around, which is quadratic in
+// the length of what it builds. So a loop that only ever
+// adds to a string local, and nothing in it reads the string
+// meanwhile, keeps what it adds in a table instead, and
+// concatenates it once the loop is done:
+//
+//	do local buf, n = {s}, 1;
+//		for ... do
+//			n = n + 1; buf[n] = w;
+//		end
+//	s = table.concat(buf, "", 1, n); end;
+//
+// The string must be a local of the function, and not one
+// of its results, used by no func literal and never having
+// its address taken; the loop must not leave by goto or a
+// labeled branch, which could skip the concatenation.
+//
+// A strings.Builder is built the same way, in Lua: while
+// the rest of the strings package is Go's, bound through its
+// shadow package, a Builder is a table of what was written
+// to it, which prelude/zstrings.lua concatenates when String
+// is called, instead of a Go value that every WriteString
+// would have to cross into Go to reach.
+
+// stringBuffer is a string local whose additions a loop
+// keeps in a table.
+type stringBuffer struct {
+	buf, n string
+}
+
+// bufferStrings translates loop, a for or range statement,
+// keeping what it adds to string locals in tables, if it
+// adds to any that can be; it reports whether it did.
+func (c *funcContext) bufferStrings(loop ast.Stmt) bool {
+	switch loop.(type) {
+	case *ast.ForStmt, *ast.RangeStmt:
+	default:
+		return false
+	}
+	vars := c.bufferableStrings(loop)
+	if len(vars) == 0 {
+		return false
+	}
+	if c.strBufs == nil {
+		c.strBufs = make(map[*types.Var]stringBuffer)
+	}
+	var decls, inits []string
+	for _, v := range vars {
+		b := stringBuffer{buf: c.gensym("buf"), n: c.gensym("n")}
+		c.strBufs[v] = b
+		decls = append(decls, b.buf, b.n)
+		inits = append(inits, "{"+c.objectName(v)+"}", "1")
+	}
+	c.Printf("do local %s = %s;", strings.Join(decls, ", "), strings.Join(inits, ", "))
+	c.translateStmt(loop, nil)
+	for _, v := range vars {
+		b := c.strBufs[v]
+		delete(c.strBufs, v)
+		c.Printf("%s = table.concat(%s, \"\", 1, %s);", c.objectName(v), b.buf, b.n)
+	}
+	c.Printf("end;")
+	return true
+}
+
+// bufferableStrings returns the string locals that loop
+// only adds to, and that can be built in a table while it
+// runs, in the order they are declared.
+func (c *funcContext) bufferableStrings(loop ast.Stmt) []*types.Var {
+	if c.body == nil || len(c.Flattened) != 0 {
+		return nil
+	}
+	adds := make(map[*types.Var]bool)
+	addLhs := make(map[*ast.Ident]bool)
+	escapes := false
+	ast.Inspect(loop, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// what it does is checked below, for the
+			// whole function.
+			return false
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO || n.Label != nil {
+				escapes = true
+			}
+		case *ast.AssignStmt:
+			if n.Tok != token.ADD_ASSIGN || len(n.Lhs) != 1 {
+				break
+			}
+			id, ok := n.Lhs[0].(*ast.Ident)
+			if !ok {
+				break
+			}
+			v, ok := c.p.Uses[id].(*types.Var)
+			if !ok || !c.bufferable(v, loop) {
+				break
+			}
+			adds[v] = true
+			addLhs[id] = true
+		}
+		return true
+	})
+	if escapes || len(adds) == 0 {
+		return nil
+	}
+
+	// every other use of them, in the loop or in a func
+	// literal of the function, or of their address
+	// anywhere, rules them out.
+	var inspect func(n ast.Node, inLoop bool) bool
+	inspect = func(n ast.Node, inLoop bool) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(m ast.Node) bool { return inspect(m, true) })
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				if id, ok := n.X.(*ast.Ident); ok {
+					if v, ok := c.p.Uses[id].(*types.Var); ok {
+						delete(adds, v)
+					}
+				}
+			}
+		case *ast.Ident:
+			if v, ok := c.p.Uses[n].(*types.Var); ok && inLoop && !addLhs[n] {
+				delete(adds, v)
+			}
+		}
+		return true
+	}
+	ast.Inspect(c.body, func(n ast.Node) bool {
+		if n == loop {
+			ast.Inspect(loop, func(m ast.Node) bool { return inspect(m, true) })
+			return false
+		}
+		return inspect(n, false)
+	})
+
+	var vars []*types.Var
+	for v := range adds {
+		vars = append(vars, v)
+	}
+	sortVarsByPos(vars)
+	return vars
+}
+
+// bufferable reports whether v is a string local that a
+// loop could build in a table.
+func (c *funcContext) bufferable(v *types.Var, loop ast.Stmt) bool {
+	if _, done := c.strBufs[v]; done {
+		return false
+	}
+	b, ok := v.Type().Underlying().(*types.Basic)
+	if !ok || b.Info()&types.IsString == 0 || !c.declaresHere(v) || v.Pos() >= loop.Pos() {
+		return false
+	}
+	results := c.sig.Results()
+	for i := 0; i < results.Len(); i++ {
+		if results.At(i) == v {
+			return false
+		}
+	}
+	return true
+}
+
+// addToBuffer returns the Lua of s, an addition to a string
+// whose loop keeps what it adds in a table, if it is one.
+func (c *funcContext) addToBuffer(s *ast.AssignStmt) (string, bool) {
+	if s.Tok != token.ADD_ASSIGN || len(s.Lhs) != 1 || len(s.Rhs) != 1 || c.strBufs == nil {
+		return "", false
+	}
+	id, ok := s.Lhs[0].(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	v, _ := c.p.Uses[id].(*types.Var)
+	b, ok := c.strBufs[v]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s = %s + 1; %s[%s] = %s;", b.n, b.n, b.buf, b.n, c.translateImplicitConversion(s.Rhs[0], v.Type())), true
+}
+
+// sortVarsByPos sorts vars in the order they are declared.
+func sortVarsByPos(vars []*types.Var) {
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Pos() < vars[j].Pos() })
+}
+
+// isStringsBuilder reports whether t is strings.Builder, or
+// a pointer to one, whose methods run in Lua.
+func isStringsBuilder(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return omitAnyShadowPathPrefix(named.Obj().Pkg().Path()) == "strings" && named.Obj().Name() == "Builder"
+}
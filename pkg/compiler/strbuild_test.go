@@ -0,0 +1,87 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1406StringsBuiltInLoopsAreConcatenatedOnce(t *testing.T) {
+
+	cv.Convey("a loop that only adds to a string keeps what it adds in a table and concatenates it once the loop is done; one that reads the string meanwhile adds to it as before", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		src := `
+func join(words []string) string {
+	s := ""
+	for _, w := range words {
+		s += w
+		s += ","
+	}
+	return s
+}
+
+func capped(n int) string {
+	t := "a"
+	for i := 0; i < n; i++ {
+		if len(t) > 3 {
+			break
+		}
+		t += "b"
+	}
+	return t
+}
+`
+		tr, err := it.Translate(src)
+		panicOn(err)
+		cv.So(strings.Count(tr, "table.concat("), cv.ShouldEqual, 1)
+		cv.So(tr, cv.ShouldContainSubstring, "[__gensym_")
+
+		it2, err := NewInterp(nil)
+		panicOn(err)
+		defer it2.Close()
+		panicOn(it2.Eval(src + `
+a := join([]string{"x", "yy", "z"})
+b := capped(10)
+c := join(nil)
+`))
+		LuaMustString(it2.lvm, "a", "x,yy,z,")
+		LuaMustString(it2.lvm, "b", "abbb")
+		LuaMustString(it2.lvm, "c", "")
+	})
+}
+
+func Test1406StringsBuilderRunsInLua(t *testing.T) {
+
+	cv.Convey("a strings.Builder is built in Lua, and can be written to by Go as an io.Writer", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import (
+	"fmt"
+	"strings"
+)
+var b strings.Builder
+for i := 0; i < 3; i++ {
+	b.WriteString("ab")
+	b.WriteByte('c')
+	b.WriteRune('é')
+}
+fmt.Fprintf(&b, "%d", 42)
+s := b.String()
+n := b.Len()
+b.Reset()
+b.WriteString("again")
+r := b.String()
+u := strings.ToUpper(r)
+`))
+		LuaMustString(it.lvm, "s", "abcéabcéabcé42")
+		LuaMustInt64(it.lvm, "n", 17)
+		LuaMustString(it.lvm, "r", "again")
+		LuaMustString(it.lvm, "u", "AGAIN")
+	})
+}
@@ -0,0 +1,48 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1346Strconv(t *testing.T) {
+
+	cv.Convey("strconv parses and formats exactly as Go does", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "strconv"`))
+		panicOn(it.Eval(`a := strconv.FormatFloat(0.1, 'g', -1, 64)
+b := strconv.FormatFloat(1e21, 'f', -1, 64)
+c := strconv.FormatFloat(float64(float32(0.1)), 'g', -1, 32)
+d := strconv.FormatFloat(123.456, 'e', 3, 64)
+e := strconv.FormatFloat(2.0/3.0, 'g', -1, 64)`))
+		LuaMustString(it.lvm, "a", "0.1")
+		LuaMustString(it.lvm, "b", "1000000000000000000000")
+		LuaMustString(it.lvm, "c", "0.1")
+		LuaMustString(it.lvm, "d", "1.235e+02")
+		LuaMustString(it.lvm, "e", "0.6666666666666666")
+
+		panicOn(it.Eval(`i, err := strconv.ParseInt("-9223372036854775808", 10, 64)
+h, _ := strconv.ParseInt("ff", 16, 64)
+f, _ := strconv.ParseFloat("1.7976931348623157e308", 64)
+back := strconv.FormatFloat(f, 'g', -1, 64)
+n, _ := strconv.Atoi("42")
+s := strconv.Itoa(n + 1)`))
+		LuaMustInt64(it.lvm, "i", -9223372036854775808)
+		LuaMustBeNil(it.lvm, "err")
+		LuaMustInt64(it.lvm, "h", 255)
+		LuaMustString(it.lvm, "back", "1.7976931348623157e+308")
+		LuaMustString(it.lvm, "s", "43")
+
+		panicOn(it.Eval(`_, err = strconv.ParseInt("12a", 10, 64); es := err.Error()`))
+		LuaMustString(it.lvm, "es", `strconv.ParseInt: parsing "12a": invalid syntax`)
+
+		panicOn(it.Eval(`q := strconv.Quote("tab\there \"quoted\" é\x01")
+u, _ := strconv.Unquote("\"a\\nb\"")`))
+		LuaMustString(it.lvm, "q", `"tab\there \"quoted\" é\x01"`)
+		LuaMustString(it.lvm, "u", "a\nb")
+	})
+}
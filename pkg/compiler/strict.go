@@ -0,0 +1,43 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// ErrStrict is returned, under GIConfig.Strict, for an
+// input that uses a construct whose gi translation only
+// approximates gc: the input is not run.
+type ErrStrict struct {
+	// Errs holds each such construct, with its position
+	// in the input.
+	Errs []types.Error
+}
+
+func (e *ErrStrict) Error() string {
+	var b strings.Builder
+	for i, err := range e.Errs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// strictCheck returns an *ErrStrict listing those of sites
+// that -strict rejects, or nil if there are none.
+func strictCheck(sites []divergenceSite, fset *token.FileSet) error {
+	var errs []types.Error
+	for _, s := range sites {
+		if s.d.strict {
+			errs = append(errs, types.Error{Fset: fset, Pos: s.pos, Msg: "strict: " + s.msg})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ErrStrict{Errs: errs}
+}
@@ -0,0 +1,56 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1355StrictRejectsWhatGiOnlyApproximates(t *testing.T) {
+
+	cv.Convey("under Strict, constructs that would silently differ from gc are errors, and the input is not run", t, func() {
+		cfg := NewGIConfig()
+		cfg.Strict = true
+		it, err := NewInterp(cfg)
+		panicOn(err)
+		defer it.Close()
+
+		for _, c := range []struct{ src, want string }{
+			{`var a int8 = 127; a++`, "repl[1]:1:20: strict: int8 arithmetic is done in 64 bits, and does not wrap at 8 bits"},
+			{`var b uint16 = 9; b = b * b`, "repl[2]:1:25: strict: uint16 arithmetic is done in 64 bits, and does not wrap at 16 bits"},
+			{`var c int = 300; d := uint8(c)`, "repl[3]:1:28: strict: conversion from int to uint8 keeps the value, and does not reinterpret its bits"},
+			{`var e int64 = 1; f := int32(e)`, "repl[4]:1:28: strict: conversion from int64 to int32 keeps the value, and does not truncate it to 32 bits"},
+			{`var g float32 = 1; g += 0.1`, "repl[5]:1:22: strict: float32 arithmetic is done in float64 precision"},
+			{`s := "héllo"; h := s[1]`, "repl[6]:1:22: strict: indexing a string yields the character there, not its byte"},
+			{`import "reflect"`, "repl[7]:1:8: strict: package reflect sees gi's Lua values, not their Go types"},
+		} {
+			err = it.Eval(c.src)
+			cv.So(err, cv.ShouldNotBeNil)
+			_, ok := err.(*ErrStrict)
+			cv.So(ok, cv.ShouldBeTrue)
+			cv.So(err.Error(), cv.ShouldEqual, c.want)
+		}
+
+		// every such construct of the input is listed.
+		err = it.Eval(`var i, j int32 = 1, 2; k := i*j - 1`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(len(err.(*ErrStrict).Errs), cv.ShouldEqual, 2)
+
+		// rejected inputs declared nothing.
+		panicOn(it.Eval(`a := 5`))
+		LuaMustInt64(it.lvm, "a", 5)
+
+		// what gi does exactly still runs: 64-bit integers,
+		// float64, constants, widening conversions and
+		// string slicing.
+		panicOn(it.Eval(`var n int = 7; n = n*3 + 1; var x float64 = 1.5; x *= 2
+const m int8 = 100
+var p = m / 2
+var q int8 = 3; var r = int64(q)
+t := "héllo"[1:3]`))
+		LuaMustInt64(it.lvm, "n", 22)
+		LuaMustFloat64(it.lvm, "x", 3)
+		LuaMustInt64(it.lvm, "r", 3)
+		LuaMustString(it.lvm, "t", "é")
+	})
+}
@@ -227,3 +227,66 @@ func Test125AnonymousStructType(t *testing.T) {
 		cv.So(true, cv.ShouldBeTrue)
 	})
 }
+
+func Test1358CompositeLiteralsAsWrittenForGc(t *testing.T) {
+
+	cv.Convey("table driven test code and config structs, pasted as written for gc, should run unmodified", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`func add(a, b int) int { return a + b }
+tests := []struct {
+	name string
+	a, b int
+	want struct{ sum int }
+}{
+	{"zeros", 0, 0, struct{ sum int }{0}},
+	{name: "ones", a: 1, b: 1, want: struct{ sum int }{sum: 2}},
+	{name: "mixed", a: -3, b: 5, want: struct{ sum int }{2}},
+}
+passed := 0
+for _, tt := range tests {
+	if add(tt.a, tt.b) == tt.want.sum {
+		passed++
+	}
+}
+last := tests[len(tests)-1].name`))
+		LuaMustInt64(it.lvm, "passed", 3)
+		LuaMustString(it.lvm, "last", "mixed")
+
+		panicOn(it.Eval(`type Config struct {
+	Name   string
+	Server struct {
+		Host string
+		Port int
+	}
+	Limits *struct{ Max, Min int }
+}
+cfg := Config{Name: "svc", Limits: &struct{ Max, Min int }{Max: 9}}
+cfg.Server.Port = 8080
+port := cfg.Server.Port
+max := cfg.Limits.Max`))
+		LuaMustInt64(it.lvm, "port", 8080)
+		LuaMustInt64(it.lvm, "max", 9)
+
+		// maps of structs, with the element types elided.
+		panicOn(it.Eval(`type V struct{ a, b int }
+byName := map[string]V{"k": {1, 2}}
+byName["z"] = V{a: 4}
+anon := map[string]struct{ x, y int }{"p": {x: 3}}
+got := byName["k"].b + byName["z"].a + anon["p"].x + byName["missing"].a`))
+		LuaMustInt64(it.lvm, "got", 9)
+
+		// anonymous interface types, and a method with an
+		// unnamed receiver.
+		panicOn(it.Eval(`type W struct{}
+func (W) Write(p []byte) (int, error) { return len(p), nil }`))
+		panicOn(it.Eval(`var sink struct {
+	out interface{ Write(p []byte) (int, error) }
+}
+sink.out = W{}
+n, _ := sink.out.Write([]byte("abc"))`))
+		LuaMustInt(it.lvm, "n", 3)
+	})
+}
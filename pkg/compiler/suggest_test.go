@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1330DidYouMean(t *testing.T) {
+
+	cv.Convey(`undeclared names, and unknown fields, methods and package members, come with up to three near names to try`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`type Point struct{ X, Y int }
+func (p *Point) Scale(k int) { p.X *= k; p.Y *= k }
+count := 1
+cout := 2
+pt := &Point{1, 2}`))
+		panicOn(it.Eval(`import "testing"`))
+
+		_, err = it.Translate(`a := coutn + 1`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "undeclared name: coutn (did you mean count or cout?)")
+
+		_, err = it.Translate(`pt.Scal(2)`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "has no field or method Scal (did you mean Scale?)")
+		_, err = it.Translate(`b := pt.x`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "has no field or method x (did you mean X?)")
+
+		_, err = it.Translate(`var tt *testing.TT`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "TT not declared by package testing (did you mean T?)")
+		_, err = it.Translate(`var tt *testing.T; tt.Errof("x")`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "has no field or method Errof (did you mean Error or Errorf?)")
+
+		// nothing near: no note.
+		_, err = it.Translate(`c := zzzzzz`)
+		cv.So(err.Error(), cv.ShouldContainSubstring, "undeclared name: zzzzzz'")
+	})
+}
@@ -0,0 +1,19 @@
+package sum
+
+// Sum is the function under test.
+func Sum(xs []int) int {
+	tot := 0
+	for _, x := range xs {
+		tot += x
+	}
+	return tot
+}
+
+// Mean is never called by the tests, so that
+// coverage has statements to report as not run.
+func Mean(xs []int) int {
+	if len(xs) == 0 {
+		return 0
+	}
+	return Sum(xs) / len(xs)
+}
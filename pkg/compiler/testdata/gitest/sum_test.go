@@ -0,0 +1,76 @@
+package sum
+
+import "testing"
+
+var order []string
+
+func TestSum(t *testing.T) {
+	if got := Sum([]int{1, 2, 3}); got != 6 {
+		t.Errorf("Sum = %d, want 6", got)
+	}
+	t.Log("sum ok")
+}
+
+func TestFails(t *testing.T) {
+	t.Errorf("first %s, %d", "problem", 42)
+	t.Fatal("fatal", 7)
+	t.Error("never reached")
+}
+
+func TestSubtests(t *testing.T) {
+	t.Run("small", func(t *testing.T) {
+		if Sum([]int{1}) != 1 {
+			t.Fail()
+		}
+	})
+	t.Run("bad", func(t *testing.T) {
+		t.Errorf("bad sub")
+	})
+}
+
+func TestParallel(t *testing.T) {
+	t.Run("a", func(t *testing.T) {
+		t.Parallel()
+		order = append(order, "a")
+	})
+	t.Run("b", func(t *testing.T) {
+		t.Parallel()
+		order = append(order, "b")
+	})
+	order = append(order, "body")
+	t.Cleanup(func() { order = append(order, "cleanup") })
+}
+
+func TestSkipped(t *testing.T) {
+	if testing.Short() {
+		t.Skip("short mode")
+	}
+}
+
+func TestPanics(t *testing.T) {
+	var m map[string]int
+	m["x"] = 1
+}
+
+func BenchmarkSum(b *testing.B) {
+	xs := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Sum(xs)
+	}
+}
+
+func BenchmarkAlloc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = make([]int, 100)
+	}
+}
+
+func BenchmarkSizes(b *testing.B) {
+	b.Run("n=1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Sum([]int{1})
+		}
+	})
+}
@@ -38,6 +38,10 @@ func NewIncrState(lvm *LuaVm, cfg *GIConfig) *IncrState {
 		goro:   lvm.goro,
 		pkgMap: make(map[string]*IncrPkg),
 		cfg:    cfg,
+		out:    &outputSwitch{},
+	}
+	if cfg.Deterministic {
+		ic.det = newDetWorld()
 	}
 	pack := &build.Package{
 		Name:       "main",
@@ -59,6 +63,10 @@ func NewIncrState(lvm *LuaVm, cfg *GIConfig) *IncrState {
 		*/
 	}
 
+	if cfg.MonkeyPatch {
+		importContext.ForeignMethods = ic.foreignMethod
+	}
+
 	key := "main"
 	pk := newIncrPkg(key, pack, fileSet, importContext, nil)
 
@@ -118,6 +126,75 @@ type IncrState struct {
 
 	cfg *GIConfig
 
+	// det holds the virtual clock and random
+	// source when cfg.Deterministic is set.
+	det *detWorld
+
+	// jitVersion is the LuaJIT's, for the import cache;
+	// see importcache.go.
+	jitVersion string
+
+	// out is where print and fmt's Print functions
+	// write; see Interp.SetOutput.
+	out *outputSwitch
+
+	// cover, when set, instruments translations
+	// for statement coverage.
+	cover *coverage
+
+	// srcMap, when set, maps the Lua lines of
+	// translations back to Go; see luaSourceMap.
+	srcMap *luaSourceMap
+
+	// inline has small funcs inlined where they are
+	// called. It is for a whole program translated at
+	// once, which nothing can redeclare; see inline.go.
+	inline bool
+
+	// nInput counts the non-empty inputs to Tr. Each
+	// is parsed as its own file, named "repl[N]", so
+	// that positions in reports name the input.
+	nInput int
+
+	// lastFile is the file of the last input,
+	// for forgetLastInput.
+	lastFile *token.File
+
+	// lastStarts has where the translation of each top
+	// level node of the last input begins in it, as a
+	// byte offset, not counting a source map's header.
+	lastStarts []codeOffset
+
+	// inputs holds the source of each input
+	// by name, for showing it in diagnostics.
+	inputs map[string][]byte
+
+	// warnings are those of the divergences the inputs
+	// touched, not yet taken by Warnings; warned has
+	// the codes warned about once already.
+	warnings []string
+	warned   map[string]bool
+
+	// results are the N of the _N variables declared,
+	// in order; see results.go.
+	results []int
+
+	// imported lists the packages imported, for :packages.
+	imported []ImportedPackage
+
+	// shimmed has where the shim of each package imported
+	// from one came from; see shim.go.
+	shimmed map[string]string
+
+	// prepared has the types of the packages the input
+	// being translated brings in, made ready for their
+	// imports; see impgraph.go.
+	prepared map[string]*preparedImport
+
+	// declSrc is the source of each declaration at the
+	// prompt, by the object it declared; see Source.
+	declSrc map[types.Object]declText
+
 	minify   bool
 	PrintAST bool
 }
@@ -145,12 +222,33 @@ func (tr *IncrState) Tr(src []byte) ([]byte, error) {
 
 	pp("after prependAns, src = '%s'", src)
 
+	if len(bytes.TrimSpace(src)) > 0 {
+		tr.nInput++
+	}
+	inputName := fmt.Sprintf("repl[%d]", tr.nInput)
+	if _, seen := tr.inputs[inputName]; !seen {
+		// a retry of the same input, less parts
+		// that failed, keeps the original.
+		if tr.inputs == nil {
+			tr.inputs = make(map[string][]byte)
+		}
+		tr.inputs[inputName] = append([]byte(nil), src...)
+	}
+
 	// classic
-	file, err := parser.ParseFile(tr.CurPkg.fileSet, "", src, 0)
+	tr.lastFile = nil
+	base := tr.CurPkg.fileSet.Base()
+	var mode parser.Mode
+	if tr.inline {
+		// for the //go:noinline of a func's doc.
+		mode = parser.ParseComments
+	}
+	file, err := parser.ParseFile(tr.CurPkg.fileSet, inputName, src, mode)
 	if err != nil {
 		pp("we got an error on the ParseFile: '%v'", err)
 	}
 	panicOn(err)
+	tr.lastFile = tr.CurPkg.fileSet.File(token.Pos(base))
 	pp("we got past the ParseFile !")
 
 	if tr.PrintAST {
@@ -164,6 +262,10 @@ func (tr *IncrState) Tr(src []byte) ([]byte, error) {
 		Name: "", // jea: was "/repl", but that seemed to cause scope issues.
 	}
 
+	if !tr.cfg.CalculatorMode {
+		tr.bindResults(file, tr.nInput)
+	}
+
 	hasBadId, whichBad := checkAllowedIdents(file)
 	if hasBadId {
 		msg := fmt.Sprintf("bad identifier: cannot "+
@@ -173,8 +275,26 @@ func (tr *IncrState) Tr(src []byte) ([]byte, error) {
 		return nil, fmt.Errorf(msg)
 	}
 
-	tr.CurPkg.Arch, err = IncrementallyCompile(tr.CurPkg.Arch, tr.CurPkg.pack.ImportPath, files, tr.CurPkg.fileSet, tr.CurPkg.importContext, tr.minify)
+	if tr.cover != nil {
+		tr.cover.beginInput(inputName, src)
+		defer tr.cover.endInput()
+	}
+
+	tr.prepareImports(files)
+	tr.CurPkg.Arch, err = incrementallyCompile(tr.CurPkg.Arch, tr.CurPkg.pack.ImportPath, files, tr.CurPkg.fileSet, tr.CurPkg.importContext, tr.minify, tr.cover, tr.srcMap != nil, tr.inline)
+	tr.prepared = nil
 	panicOn(err)
+	tr.recordDecls(file, src, tr.nInput)
+	var sites []divergenceSite
+	if tr.cfg != nil {
+		sites = findDivergences(files, tr.CurPkg.Arch.TypesInfo, !tr.cfg.Deterministic)
+		if tr.cfg.Strict {
+			if err := strictCheck(sites, tr.CurPkg.fileSet); err != nil {
+				tr.CurPkg.Arch.NewCodeText = nil
+				return nil, err
+			}
+		}
+	}
 	//pp("archive = '%#v'", tr.CurPkg.Arch)
 	//pp("len(tr.CurPkg.Arch.Declarations)= '%v'", len(tr.CurPkg.Arch.Declarations))
 	//pp("len(tr.CurPkg.Arch.NewCode)= '%v'", len(tr.CurPkg.Arch.NewCodeText))
@@ -182,12 +302,23 @@ func (tr *IncrState) Tr(src []byte) ([]byte, error) {
 	pp("got past config.Check")
 
 	var res bytes.Buffer
+	offsets := make([]int, len(tr.CurPkg.Arch.NewCodeText)+1)
 	for i, d := range tr.CurPkg.Arch.NewCodeText {
 		pp("writing tr.CurPkg.Arch.NewCode[i=%v].Code = '%v'", i, string(tr.CurPkg.Arch.NewCodeText[i]))
+		offsets[i] = res.Len()
 		res.Write(d)
 	}
+	offsets[len(offsets)-1] = res.Len()
+	tr.lastStarts = tr.lastStarts[:0]
+	for _, cs := range tr.CurPkg.Arch.NewCodeStarts {
+		tr.lastStarts = append(tr.lastStarts, codeOffset{pos: cs.Pos, offset: offsets[cs.Text]})
+	}
 	tr.CurPkg.Arch.NewCodeText = nil
+	tr.warnings = append(tr.warnings, tr.divergenceWarnings(sites)...)
 
+	if tr.srcMap != nil {
+		return tr.srcMap.mapChunk(res.Bytes(), inputName, files, tr.CurPkg.fileSet), nil
+	}
 	return res.Bytes(), nil
 }
 
@@ -454,15 +585,26 @@ func (tr *IncrState) prependAns(src []byte) []byte {
 	leftdiff := nsrc - n
 	if tr.cfg.CalculatorMode {
 		middle := removeTrailingSemicolon(trimmed[leftdiff:])
-		return append(gijitAnsPrefix, append(middle, gijitAnsSuffix...)...)
+		return wrapAns(middle)
 	}
 	if n > 1 && leftTrimmed[0] == '=' && leftTrimmed[1] != '=' {
 		middle := removeTrailingSemicolon(trimmed[leftdiff+1:])
-		return append(gijitAnsPrefix, append(middle, gijitAnsSuffix...)...)
+		return wrapAns(middle)
 	}
 	return src
 }
 
+// wrapAns builds a fresh slice every time. Appending
+// directly to gijitAnsPrefix could scribble on its
+// spare capacity, which is shared by every IncrState
+// in the process.
+func wrapAns(middle []byte) []byte {
+	res := make([]byte, 0, len(gijitAnsPrefix)+len(middle)+len(gijitAnsSuffix))
+	res = append(res, gijitAnsPrefix...)
+	res = append(res, middle...)
+	return append(res, gijitAnsSuffix...)
+}
+
 // full package
 
 // FullPackage: translate a full package from go to Lua.
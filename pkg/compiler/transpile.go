@@ -0,0 +1,232 @@
+package compiler
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// gi transpile translates a Go file to Lua meant to be read
+// and kept, as when moving code into a Lua code base:
+//
+//	gi transpile -o file.lua file.go
+//
+// Unlike gi build, it keeps every declaration, and the file
+// may be of any package, with or without a main. The Go
+// comments are carried over, as Lua comments, above the Lua
+// of the declaration or statement they were before; the
+// marks gi leaves for its source maps are taken out, as
+// are the notes its code generator leaves on some lines,
+// saying which of its files made them. A header says what
+// the Lua needs to run.
+
+// codeOffset is where the translation of a top level
+// node, at pos in the Go, begins in the Lua.
+type codeOffset struct {
+	pos    token.Pos
+	offset int
+}
+
+// genNoteRE matches the note, such as "-- incr.go:873",
+// that ends some lines of generated Lua.
+var genNoteRE = regexp.MustCompile(`;[ \t]*--[^"\n]*\.go:\d+[^"\n]*$`)
+
+// TranspileFile translates the Go file in path to Lua with
+// its comments, in a fresh Interp started from cfg.
+func TranspileFile(cfg *GIConfig, path string) ([]byte, error) {
+	s, err := parseGoFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// blank out the package clause, which an input at the
+	// prompt does not have, keeping the lines where they
+	// are, so that a line of the input is one of the file.
+	src := []byte(s.text)
+	if s.file.Name != nil {
+		from := s.fset.Position(s.file.Package).Offset
+		to := s.fset.Position(s.file.Name.End()).Offset
+		for i := from; i < to; i++ {
+			src[i] = ' '
+		}
+	}
+
+	c := *cfg
+	c.Quiet = true
+	it, err := NewInterp(&c)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	it.inc.inline = true
+	tr, err := it.Translate(string(src))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(tr, chunkPrefix) {
+		tr = tr[strings.Index(tr, "\n")+1:]
+	}
+	fset := it.inc.CurPkg.fileSet
+
+	// the comments inside a declaration other than a
+	// func's go with it, since its Lua has no marks.
+	lastLine := make(map[int]int)
+	for _, node := range s.file.Nodes {
+		line := s.fset.Position(node.Pos()).Line
+		lastLine[line] = line
+		if _, ok := node.(*ast.GenDecl); ok {
+			lastLine[line] = s.fset.Position(node.End()).Line
+		}
+	}
+
+	var b strings.Builder
+	writeTranspileHeader(&b, path, s)
+	pending := s.file.Comments
+	// flush writes the comments that begin on line or
+	// before, indented by indent.
+	flush := func(line int, indent string) {
+		for len(pending) > 0 && (line < 0 || s.fset.Position(pending[0].Pos()).Line <= line) {
+			for _, c := range pending[0].List {
+				for _, ln := range luaComment(c.Text) {
+					b.WriteString(indent + ln + "\n")
+				}
+			}
+			pending = pending[1:]
+		}
+	}
+
+	starts := it.inc.lastStarts
+	for off := 0; off < len(tr); {
+		end := strings.Index(tr[off:], "\n")
+		if end < 0 {
+			end = len(tr)
+		} else {
+			end += off + 1
+		}
+		ln := tr[off:end]
+		if strings.TrimSpace(ln) == "" {
+			// what starts here is indented on a later line.
+			b.WriteString(ln)
+			off = end
+			continue
+		}
+		indent := ln[:len(ln)-len(strings.TrimLeft(ln, " \t"))]
+
+		for len(starts) > 0 && starts[0].offset < end {
+			if starts[0].pos.IsValid() {
+				line := fset.Position(starts[0].pos).Line
+				if last, ok := lastLine[line]; ok {
+					line = last
+				}
+				flush(line, indent)
+			}
+			starts = starts[1:]
+		}
+		if m := stmtMarkRE.FindStringSubmatch(ln); m != nil {
+			p, _ := strconv.Atoi(m[1])
+			flush(fset.Position(token.Pos(p)).Line, indent)
+			ln = stmtMarkRE.ReplaceAllString(ln, "")
+			if strings.TrimSpace(ln) == "" {
+				off = end
+				continue
+			}
+		}
+		if strings.HasSuffix(ln, "\n") {
+			ln = genNoteRE.ReplaceAllString(ln[:len(ln)-1], ";") + "\n"
+		} else {
+			ln = genNoteRE.ReplaceAllString(ln, ";")
+		}
+		b.WriteString(ln)
+		off = end
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	flush(-1, "")
+	return []byte(b.String()), nil
+}
+
+// writeTranspileHeader writes the header of the Lua that gi
+// transpile makes of the file s, read from path.
+func writeTranspileHeader(b *strings.Builder, path string, s *script) {
+	base := filepath.Base(path)
+	fmt.Fprintf(b, "-- %s: translated by gi transpile from %s.\n",
+		strings.TrimSuffix(base, ".go")+".lua", base)
+	b.WriteString(`--
+-- It needs gi's runtime: LuaJIT 2.1, with its ffi and bit
+-- modules, and gi's prelude, the Lua files of
+-- pkg/compiler/prelude, loaded first. Go's integers are
+-- LuaJIT's int64 cdata, and Go's slices, maps and structs
+-- are the prelude's tables.
+`)
+	var paths []string
+	for _, spec := range s.file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err == nil {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) > 0 {
+		sort.Strings(paths)
+		b.WriteString("-- The packages it imports, and their types, are reached through\n-- __packages and __type__, by import path, which must hold them:\n")
+		for _, p := range paths {
+			fmt.Fprintf(b, "--   %q\n", p)
+		}
+	}
+	for _, node := range s.file.Nodes {
+		if isMainFunc(node) {
+			b.WriteString("-- It declares main, but does not call it.\n")
+			break
+		}
+	}
+	b.WriteString("\n")
+}
+
+// luaComment returns the Go comment text, // or /* */, as
+// lines of a Lua comment.
+func luaComment(text string) []string {
+	if strings.HasPrefix(text, "//") {
+		return []string{"--" + text[2:]}
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	var lines []string
+	for _, ln := range strings.Split(text, "\n") {
+		lines = append(lines, strings.TrimRight("-- "+strings.TrimSpace(ln), " "))
+	}
+	return lines
+}
+
+// GiTranspileMain implements gi transpile. args are those
+// after "transpile". It returns the exit code.
+func GiTranspileMain(cfg *GIConfig, args []string) int {
+	fs := flag.NewFlagSet("gi transpile", flag.ContinueOnError)
+	out := fs.String("o", "", "write the Lua here; by default, the Go file's name with .lua")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || !strings.HasSuffix(fs.Arg(0), ".go") {
+		fmt.Fprintf(os.Stderr, "usage: gi transpile [-o file.lua] file.go\n")
+		return 2
+	}
+	path := fs.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(path, ".go") + ".lua"
+	}
+	lua, err := TranspileFile(cfg, path)
+	if err == nil {
+		err = ioutil.WriteFile(*out, lua, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gi transpile: %v\n", err)
+		return 1
+	}
+	return 0
+}
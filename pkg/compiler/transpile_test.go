@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1402TranspileKeepsTheComments(t *testing.T) {
+
+	cv.Convey("gi transpile writes Lua with the Go comments above what they were about, a header saying what it needs, and no marks or notes of gi's own", t, func() {
+		dir, err := ioutil.TempDir("", "gi-transpile")
+		panicOn(err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "geo.go")
+		panicOn(ioutil.WriteFile(path, []byte(`package main
+
+import "math"
+
+/* Point is a place
+   on the plane. */
+type Point struct {
+	X, Y float64 // where
+}
+
+// Dist is how far p is from q.
+func (p Point) Dist(q Point) float64 {
+	// Pythagoras
+	dx := p.X - q.X
+	dy := p.Y - q.Y
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// calls counts the calls.
+var calls int
+
+func main() {
+	calls++
+	if (Point{3, 4}).Dist(Point{}) != 5 {
+		panic("wrong")
+	}
+}
+
+// the end
+`), 0644))
+
+		lua, err := TranspileFile(NewGIConfig(), path)
+		panicOn(err)
+		s := string(lua)
+		cv.So(s, cv.ShouldStartWith, "-- geo.lua: translated by gi transpile from geo.go.\n")
+		cv.So(s, cv.ShouldContainSubstring, "LuaJIT 2.1")
+		cv.So(s, cv.ShouldContainSubstring, "--   \"math\"\n")
+		cv.So(s, cv.ShouldContainSubstring, "-- It declares main, but does not call it.\n")
+		cv.So(s, cv.ShouldNotContainSubstring, "--[[gi:")
+		cv.So(s, cv.ShouldNotContainSubstring, "incr.go:")
+
+		// each comment comes before the Lua of what it was about.
+		order := []string{
+			"-- Point is a place\n", "-- on the plane.\n", "-- where\n", "__type__.Point = ",
+			"-- Dist is how far p is from q.\n", "Dist = function(p,q)",
+			"\t\t-- Pythagoras\n", "local dx",
+			"-- calls counts the calls.\n", "calls = 0LL",
+			"main = function()", "-- the end\n",
+		}
+		at := 0
+		for _, want := range order {
+			i := strings.Index(s[at:], want)
+			cv.So(i, cv.ShouldBeGreaterThanOrEqualTo, 0)
+			at += i + len(want)
+		}
+
+		// and it runs, on gi's runtime.
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		panicOn(it.Eval(`import "math"`))
+		cv.So(it.runBuilt(s+"\nmain()\n"), cv.ShouldBeNil)
+		LuaMustInt64(it.lvm, "calls", 1)
+	})
+}
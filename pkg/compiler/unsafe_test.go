@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1337UnsafeSizesAndPointers(t *testing.T) {
+
+	cv.Convey(`unsafe.Sizeof, Alignof and Offsetof give the layout LuaJIT's ffi gives, and a conversion through unsafe.Pointer views the same variable as the new type`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`import "unsafe"`))
+		panicOn(it.Eval(`type S struct{ A int8; B int64; C int16 }`))
+		panicOn(it.Eval(`type R struct{ X int8; Y int64; Z int16 }`))
+
+		// padded to the alignment of B, as in C.
+		panicOn(it.Eval(`var s S; size := int(unsafe.Sizeof(s)); align := int(unsafe.Alignof(s.B)); off := int(unsafe.Offsetof(s.C))`))
+		LuaMustInt64(it.lvm, "size", 24)
+		LuaMustInt64(it.lvm, "align", 8)
+		LuaMustInt64(it.lvm, "off", 16)
+		cv.So(it.Eval(`const K = unsafe.Sizeof(int32(0)); var arr [K]byte; k := len(arr)`), cv.ShouldBeNil)
+		LuaMustInt64(it.lvm, "k", 4)
+
+		// the same struct, and one of the same layout.
+		panicOn(it.Eval(`p := unsafe.Pointer(&s); q := (*S)(p); q.A = 5; a := s.A`))
+		LuaMustInt64(it.lvm, "a", 5)
+		panicOn(it.Eval(`r := (*R)(p); r.Z = 7; c := s.C; x := r.X`))
+		LuaMustInt64(it.lvm, "c", 7)
+		LuaMustInt64(it.lvm, "x", 5)
+
+		// the bits of a float64.
+		panicOn(it.Eval(`var f float64 = 1.5; bits := *(*uint64)(unsafe.Pointer(&f)); isBits := bits == 0x3FF8000000000000`))
+		LuaMustBool(it.lvm, "isBits", true)
+		panicOn(it.Eval(`pu := (*uint64)(unsafe.Pointer(&f)); *pu = 0x4000000000000000; g := f`))
+		LuaMustFloat64(it.lvm, "g", 2)
+
+		// a uintptr is the address of its variable, and back.
+		panicOn(it.Eval(`u := uintptr(p); same := u == uintptr(unsafe.Pointer(q)); back := (*S)(unsafe.Pointer(u)); b := back.A`))
+		LuaMustBool(it.lvm, "same", true)
+		LuaMustInt64(it.lvm, "b", 5)
+
+		panicOn(it.Eval(`var np unsafe.Pointer; isNil := (*S)(np) == nil; zero := uintptr(np) == 0`))
+		LuaMustBool(it.lvm, "isNil", true)
+		LuaMustBool(it.lvm, "zero", true)
+	})
+}
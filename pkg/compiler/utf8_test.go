@@ -60,13 +60,13 @@ func Test041RangeOverUtf8BytesInString(t *testing.T) {
 
 		// since we don't have fmt/imports online yet, we'll settle
 		// for just taking the string apart at the utf8 separation
-		// points. No printing f
+		// points, each three bytes along. No printing f
 
 		code := `
     runes := []rune{0,0,0}
     const nihongo = "日本語"  // translated, means "Japanese"
     for i, runeValue := range nihongo {
-        runes[i] = runeValue
+        runes[i/3] = runeValue
     }
     r0 := runes[0]
     r1 := runes[1]
@@ -83,8 +83,8 @@ func Test041RangeOverUtf8BytesInString(t *testing.T) {
 
 		LuaRunAndReport(vm, string(translation))
 		fmt.Printf("\n past LuaRunAndReport \n")
-		LuaMustString(vm, "r0", "日")
-		LuaMustString(vm, "r1", "本")
-		LuaMustString(vm, "r2", "語")
+		LuaMustRune(vm, "r0", '日')
+		LuaMustRune(vm, "r1", '本')
+		LuaMustRune(vm, "r2", '語')
 	})
 }
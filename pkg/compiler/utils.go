@@ -8,7 +8,6 @@ import (
 	"github.com/gijit/gi/pkg/constant"
 	"github.com/gijit/gi/pkg/token"
 	"github.com/gijit/gi/pkg/types"
-	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -380,11 +379,32 @@ func isPkgLevel(o types.Object) bool {
 	return o.Parent() != nil && o.Parent().Parent() == types.Universe
 }
 
+// typeRef is the Lua that reaches the type o in __type__,
+// where the types of other packages are kept by import path.
+func (c *funcContext) typeRef(o *types.TypeName) string {
+	if isPkgLevel(o) && o.Pkg() != c.p.Pkg {
+		c.p.dependencies[o] = true
+		return fmt.Sprintf("__type__[%q].%s", omitAnyShadowPathPrefix(o.Pkg().Path()), o.Name())
+	}
+	return "__type__." + c.objectName(o)
+}
+
 func (c *funcContext) objectName(o types.Object) (nam string) {
 	defer func() {
 		pp("objectName called for o='%#v', returning '%s'", o, nam)
 	}()
 
+	if f, ok := o.(*types.Func); ok {
+		if _, builtin := f.IsBuiltin(); builtin {
+			return "__gi_builtins." + o.Name()
+		}
+		// fmt.Errorf is Lua's, so that %w can wrap
+		// interpreted errors; see prelude/zerrors.lua.
+		if o.Pkg() != nil && omitAnyShadowPathPrefix(o.Pkg().Path()) == "fmt" && o.Name() == "Errorf" {
+			return "__gi_errorf"
+		}
+	}
+
 	if isPkgLevel(o) {
 		c.p.dependencies[o] = true
 
@@ -405,7 +425,11 @@ func (c *funcContext) objectName(o types.Object) (nam string) {
 	name, ok := c.p.objectNames[o]
 	pp("utils.go:307, name='%v', ok='%v'", name, ok)
 	if !ok {
-		name = c.newVariableWithLevel(o.Name(), isPkgLevel(o), false)
+		if isSimplifierTemp(o) {
+			name = c.newVariableWithLevel("__tmp"+o.Name(), false, false)
+		} else {
+			name = c.newVariableWithLevel(luaName(o.Name()), isPkgLevel(o), false)
+		}
 		pp("name='%#v', o.Name()='%v'", name, o.Name())
 		c.p.objectNames[o] = name
 	}
@@ -459,7 +483,7 @@ func (c *funcContext) typeNameReport(level int, ty types.Type) string {
 		if t.Obj().Name() == "error" {
 			return "__type__.error"
 		}
-		return "__type__." + c.objectName(t.Obj())
+		return c.typeRef(t.Obj())
 	case *types.Interface:
 		if t.Empty() {
 			return "__type__.emptyInterface"
@@ -514,7 +538,7 @@ func (c *funcContext) typeNameWithAnonInfo(
 			res = "__type__.error"
 			return
 		}
-		res = "__type__." + c.objectName(t.Obj())
+		res = c.typeRef(t.Obj())
 		return
 	case *types.Interface:
 		if t.Empty() {
@@ -558,6 +582,10 @@ func (c *funcContext) typeNameWithAnonInfo(
 				c.Printf(anonTypePrint)
 			})
 		case IMMEDIATE:
+			if c.p.hoistedAnon != nil {
+				*c.p.hoistedAnon = append(*c.p.hoistedAnon, anonTypePrint...)
+				break
+			}
 			c.Printf(anonTypePrint)
 			pp("done with IMMEDIATE printing of anonTypePrint='%v'", anonTypePrint)
 		case SKIP_ANON:
@@ -605,7 +633,7 @@ func (c *funcContext) handleEscapingVars(n ast.Node) {
 	}
 	sort.Strings(names)
 	for _, name := range names {
-		c.Printf("%s = [%s];", name, name)
+		c.Printf("%s = {[0]=%s};", name, name)
 	}
 }
 
@@ -766,6 +794,29 @@ func isWrapped(ty types.Type) bool {
 	return false
 }
 
+// isBoxed reports whether a value of type ty is boxed with
+// its type, as ty(v), when it goes into an interface. Basic
+// values are plain Lua values, and every signed integer is
+// an int64_t, every float a number; so only the type a plain
+// value stands for by itself -- bool, int, uint, float64,
+// complex128 or string -- goes in bare. The box keeps
+// MyInt(1), int64(1) and 1 apart in comparisons, map keys
+// and type assertions.
+func isBoxed(ty types.Type) bool {
+	b, ok := ty.Underlying().(*types.Basic)
+	if !ok || b.Info()&types.IsUntyped != 0 || b.Kind() == types.UnsafePointer {
+		return false
+	}
+	if _, named := ty.(*types.Named); named {
+		return true
+	}
+	switch b.Kind() {
+	case types.Bool, types.Int, types.Uint, types.Float64, types.Complex128, types.String:
+		return false
+	}
+	return true
+}
+
 func encodeString(s string) string {
 	pp("jea debug: encodeString called with s='%s'", s)
 	buffer := bytes.NewBuffer(nil)
@@ -931,10 +982,6 @@ func endsWithReturn(stmts []ast.Stmt) bool {
 	return false
 }
 
-func encodeIdent(name string) string {
-	return strings.Replace(url.QueryEscape(name), "%", "_", -1)
-}
-
 func stripOuterParen(s string) (r string) {
 	r = strings.TrimSpace(s)
 	n := len(r)
@@ -0,0 +1,65 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Var is a variable of the session, as a variable
+// explorer lists it: its type, and its value in short.
+type Var struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Vars limit how much of a value a Var shows.
+const (
+	varsMaxDepth = 2
+	varsMaxWidth = 10
+	varsMaxLen   = 200 // bytes
+)
+
+// Vars returns the session's variables, in order of name,
+// each with its value as the REPL would echo it, but cut
+// off after varsMaxDepth levels and varsMaxWidth elements,
+// and at varsMaxLen bytes.
+func (it *Interp) Vars() ([]Var, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+	if it.closed {
+		return nil, fmt.Errorf("Interp is closed")
+	}
+
+	scope := it.inc.pkgScope()
+	qual := imageQualifier(it.inc.CurPkg.Arch.Pkg)
+	var vs []Var
+	for _, name := range scope.Names() {
+		v, ok := scope.Lookup(name).(*types.Var)
+		if !ok || name == "_" || strings.HasPrefix(name, "__") {
+			continue
+		}
+		// struct values are held by pointer; show them as
+		// the REPL echoes them.
+		_, isStruct := v.Type().Underlying().(*types.Struct)
+		code := fmt.Sprintf("__gi_varOut = __gi_showString(_G[%q], %v, %d, %d)",
+			luaName(name), isStruct, varsMaxDepth, varsMaxWidth)
+		value := "?"
+		if err := LuaRun(it.lvm, code, false); err == nil {
+			value = luaGlobalString(it.lvm, "__gi_varOut")
+		}
+		if len(value) > varsMaxLen {
+			cut := varsMaxLen
+			for cut > 0 && !utf8.RuneStart(value[cut]) {
+				cut--
+			}
+			value = value[:cut] + "..."
+		}
+		vs = append(vs, Var{Name: name, Type: types.TypeString(v.Type(), qual), Value: value})
+	}
+	panicOn(LuaRun(it.lvm, "__gi_varOut = nil", false))
+	return vs, nil
+}
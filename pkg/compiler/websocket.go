@@ -0,0 +1,269 @@
+package compiler
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A minimal WebSocket, RFC 6455, for gi serve: text
+// messages, fragmented or not, ping and close; no
+// extensions. The server side is wsAccept; wsClient
+// gives the client side over a connection already
+// upgraded, for tests and tools.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage bounds a message, so a client cannot make
+// the server buffer without end.
+const wsMaxMessage = 16 << 20
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+type wsConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // masks what it sends, as a client must
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// wsAccept completes the opening handshake of r, a request
+// to upgrade to a WebSocket, and takes over its connection.
+// open, if not nil, is called with the connection before
+// the handshake is answered, so that what it sets up is in
+// place by the time the client can act; anything sent on
+// the connection meanwhile waits for the answer.
+func wsAccept(w http.ResponseWriter, r *http.Request, open func(*wsConn)) (*wsConn, error) {
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade", http.StatusInternalServerError)
+		return nil, errors.New("websocket: connection cannot be hijacked")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	c := &wsConn{conn: conn, br: brw.Reader}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if open != nil {
+		open(c)
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(key))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		c.closed = true
+		return nil, err
+	}
+	return c, nil
+}
+
+// wsClient is the client side of conn, whose handshake has
+// been done; br reads what the server sent after it.
+func wsClient(conn net.Conn, br *bufio.Reader) *wsConn {
+	return &wsConn{conn: conn, br: br, client: true}
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsNewKey makes a Sec-WebSocket-Key for a client.
+func wsNewKey() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// headerHas reports whether the comma separated header
+// name of h lists token, without regard to case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message,
+// answering pings on the way. It returns io.EOF once the
+// peer closes.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	inMessage := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, payload)
+			c.Close()
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if inMessage {
+				return nil, errors.New("websocket: new message inside a fragmented one")
+			}
+			inMessage = true
+			msg = payload
+		case wsContinuation:
+			if !inMessage {
+				return nil, errors.New("websocket: continuation outside a message")
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+		if len(msg) > wsMaxMessage {
+			return nil, errors.New("websocket: message too large")
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		err = errors.New("websocket: frame masked wrongly")
+		return
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > wsMaxMessage {
+		err = errors.New("websocket: message too large")
+		return
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// WriteMessage sends msg as one text message. It is safe
+// to call from several goroutines.
+func (c *wsConn) WriteMessage(msg []byte) error {
+	return c.writeFrame(wsText, msg)
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+	frame := []byte{0x80 | op}
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		payload = masked
+	}
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+// Close closes the connection, without the closing
+// handshake.
+func (c *wsConn) Close() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
@@ -0,0 +1,307 @@
+package compiler
+
+// webUIHTML is the web frontend gi serve gives at /: one
+// page, without outside dependencies, speaking the Server
+// protocol over /ws. It has an editor, where Shift-Enter
+// evaluates and Tab completes; the output of each eval, as
+// a cell, with its rich displays; the session's variables,
+// refreshed after each eval, whoever made it; and the other
+// people in the session, with what each is typing.
+//
+// The token comes from the page's #token= fragment, which
+// gi serve prints a link with, or else is asked for; a
+// #name= there names the user to the others.
+const webUIHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gi</title>
+<style>
+  * { box-sizing: border-box; }
+  body { margin: 0; font: 14px system-ui, sans-serif; color: #222; background: #f6f6f4;
+         display: grid; grid-template-columns: 1fr 22em; grid-template-rows: auto 1fr auto;
+         height: 100vh; }
+  header { grid-column: 1 / 3; display: flex; align-items: center; gap: 1em;
+           padding: .4em 1em; background: #2b2b2b; color: #eee; }
+  header h1 { font-size: 1em; margin: 0; }
+  header .status { margin-left: auto; font-size: .85em; color: #aaa; }
+  #output { overflow-y: auto; padding: .5em 1em; }
+  #vars { grid-row: 2 / 4; grid-column: 2; overflow-y: auto; border-left: 1px solid #ddd;
+          background: #fff; padding: .5em; }
+  #vars h2 { font-size: .9em; margin: .2em 0 .5em; color: #666; }
+  #vars table { width: 100%; border-collapse: collapse; font: 12px monospace; }
+  #vars td { border-bottom: 1px solid #eee; padding: .2em; vertical-align: top; }
+  #vars td.type { color: #07a; }
+  #vars td.value { white-space: pre-wrap; word-break: break-all; }
+  #people .person { font-size: 12px; margin-bottom: .5em; }
+  #people .person b { color: #c90; }
+  #people pre { margin: .2em 0; padding: .2em; background: #f6f6f4; font: 11px monospace;
+                white-space: pre-wrap; word-break: break-all; }
+  #people .caret { border-left: 2px solid #c90; }
+  #editor { padding: .5em 1em 1em; }
+  #code { width: 100%; height: 8em; font: 13px monospace; padding: .5em; resize: vertical; }
+  #editor .bar { display: flex; gap: .5em; align-items: center; margin-top: .3em;
+                 font-size: .85em; color: #666; }
+  #completions { font: 12px monospace; color: #07a; }
+  .cell { background: #fff; border: 1px solid #e2e2e2; border-radius: 4px; margin: .5em 0;
+          padding: .4em .6em; }
+  .cell.other { border-left: 3px solid #c90; }
+  .cell pre { margin: .2em 0; white-space: pre-wrap; font: 13px monospace; }
+  .cell .code { color: #555; }
+  .cell .code::before { content: "gi> "; color: #aaa; }
+  .cell .who { font-size: 11px; color: #c90; }
+  .cell .error { color: #b00; }
+  .cell .warning { color: #a60; }
+  .cell img { max-width: 100%; }
+  .cell iframe { width: 100%; border: 0; min-height: 4em; }
+</style>
+</head>
+<body>
+<header>
+  <h1>gi</h1>
+  <button id="interrupt" disabled>Interrupt</button>
+  <span class="status" id="status">connecting...</span>
+</header>
+<div id="output"></div>
+<div id="vars">
+  <h2>Variables</h2><table id="varTable"></table>
+  <h2>People</h2><div id="people"></div>
+</div>
+<div id="editor">
+  <textarea id="code" spellcheck="false" autofocus placeholder="Go code; Shift-Enter runs it, Tab completes"></textarea>
+  <div class="bar">
+    <button id="run">Run</button>
+    <span id="completions"></span>
+  </div>
+</div>
+<script>
+(function() {
+  "use strict";
+  var $ = function(id) { return document.getElementById(id); };
+  var code = $("code"), output = $("output"), statusEl = $("status");
+  var ws, nextID = 1, pending = {}, running = 0, history = [], histPos = 0;
+
+  var token = (location.hash.match(/token=([^&]*)/) || [])[1];
+  if (!token) {
+    token = window.prompt("gi serve token:") || "";
+  }
+  token = decodeURIComponent(token);
+  var name = decodeURIComponent((location.hash.match(/name=([^&]*)/) || [])[1] || "");
+  var people = {}, me = 0;
+
+  function el(tag, cls, text) {
+    var e = document.createElement(tag);
+    if (cls) { e.className = cls; }
+    if (text !== undefined) { e.textContent = text; }
+    return e;
+  }
+
+  function send(req, done) {
+    req.id = nextID++;
+    pending[req.id] = done;
+    ws.send(JSON.stringify(req));
+  }
+
+  // displayNode renders one rich output, by its richest MIME type.
+  function displayNode(d) {
+    if (d["image/png"]) {
+      var img = el("img");
+      img.src = "data:image/png;base64," + d["image/png"];
+      img.alt = d["text/plain"] || "";
+      return img;
+    }
+    if (d["image/svg+xml"]) {
+      var svg = el("img");
+      svg.src = "data:image/svg+xml;charset=utf-8," + encodeURIComponent(d["image/svg+xml"]);
+      svg.alt = d["text/plain"] || "";
+      return svg;
+    }
+    if (d["text/html"]) {
+      var frame = el("iframe");
+      frame.setAttribute("sandbox", "");
+      frame.srcdoc = d["text/html"];
+      return frame;
+    }
+    if (d["text/markdown"]) {
+      return el("pre", "", d["text/markdown"]);
+    }
+    return el("pre", "", d["text/plain"] || "");
+  }
+
+  function addCell(reply, mine) {
+    var cell = el("div", mine ? "cell" : "cell other");
+    if (!mine && reply.client) { cell.appendChild(el("div", "who", reply.client.name)); }
+    cell.appendChild(el("pre", "code", reply.code));
+    if (reply.output) { cell.appendChild(el("pre", "out", reply.output)); }
+    (reply.display || []).forEach(function(d) { cell.appendChild(displayNode(d)); });
+    (reply.warnings || []).forEach(function(w) { cell.appendChild(el("pre", "warning", w)); });
+    if (reply.error) { cell.appendChild(el("pre", "error", reply.error)); }
+    output.appendChild(cell);
+    output.scrollTop = output.scrollHeight;
+  }
+
+  function refreshVars() {
+    send({op: "vars"}, function(reply) {
+      var table = $("varTable");
+      table.textContent = "";
+      (reply.vars || []).forEach(function(v) {
+        var tr = el("tr");
+        tr.appendChild(el("td", "name", v.name));
+        tr.appendChild(el("td", "type", v.type));
+        tr.appendChild(el("td", "value", v.value));
+        table.appendChild(tr);
+      });
+    });
+  }
+
+  // showPeople lists the others, each with what they are
+  // typing, and their cursor in it.
+  function showPeople() {
+    var box = $("people");
+    box.textContent = "";
+    Object.keys(people).forEach(function(id) {
+      var p = people[id];
+      if (+id === me) { return; }
+      var div = el("div", "person");
+      div.appendChild(el("b", "", p.name));
+      if (p.code) {
+        var draft = new TextEncoder().encode(p.code);
+        var head = new TextDecoder().decode(draft.slice(0, p.cursor || 0));
+        var pre = el("pre");
+        pre.appendChild(document.createTextNode(head));
+        pre.appendChild(el("span", "caret"));
+        pre.appendChild(document.createTextNode(p.code.slice(head.length)));
+        div.appendChild(pre);
+      }
+      box.appendChild(div);
+    });
+  }
+
+  var cursorTimer = null;
+  function shareCursor() {
+    if (cursorTimer) { return; }
+    cursorTimer = setTimeout(function() {
+      cursorTimer = null;
+      var at = new TextEncoder().encode(code.value.slice(0, code.selectionStart)).length;
+      ws.send(JSON.stringify({op: "cursor", code: code.value, cursor: at}));
+    }, 150);
+  }
+
+  function setRunning(n) {
+    running = n;
+    $("interrupt").disabled = running === 0;
+    statusEl.textContent = running ? "running..." : "ready";
+  }
+
+  function run() {
+    var src = code.value;
+    if (!src.trim()) { return; }
+    history.push(src);
+    histPos = history.length;
+    code.value = "";
+    $("completions").textContent = "";
+    setRunning(running + 1);
+    send({op: "eval", code: src}, function(reply) {
+      reply.code = src;
+      addCell(reply, true);
+      setRunning(running - 1);
+      refreshVars();
+    });
+  }
+
+  function complete() {
+    var cursor = code.selectionStart;
+    var src = code.value;
+    // the server counts the cursor in bytes.
+    var bytes = new TextEncoder().encode(src.slice(0, cursor)).length;
+    send({op: "complete", code: src, cursor: bytes}, function(reply) {
+      var cands = reply.candidates || [];
+      if (cands.length === 0) { $("completions").textContent = ""; return; }
+      var head = new TextDecoder().decode(new TextEncoder().encode(src).slice(0, reply.start || 0));
+      var prefix = src.slice(head.length, cursor);
+      var common = cands.reduce(function(a, b) {
+        var i = 0;
+        while (i < a.length && a[i] === b[i]) { i++; }
+        return a.slice(0, i);
+      });
+      if (common.length > prefix.length) {
+        code.value = src.slice(0, head.length) + common + src.slice(cursor);
+        code.selectionStart = code.selectionEnd = head.length + common.length;
+      }
+      $("completions").textContent = cands.length === 1 ? "" : cands.slice(0, 40).join("  ");
+    });
+  }
+
+  code.addEventListener("keydown", function(e) {
+    if (e.key === "Enter" && (e.shiftKey || e.ctrlKey)) {
+      e.preventDefault();
+      run();
+    } else if (e.key === "Tab") {
+      e.preventDefault();
+      complete();
+    } else if (e.key === "ArrowUp" && e.altKey && histPos > 0) {
+      code.value = history[--histPos];
+    } else if (e.key === "ArrowDown" && e.altKey && histPos < history.length) {
+      histPos++;
+      code.value = histPos < history.length ? history[histPos] : "";
+    }
+  });
+  code.addEventListener("input", shareCursor);
+  code.addEventListener("keyup", shareCursor);
+  code.addEventListener("click", shareCursor);
+  $("run").addEventListener("click", run);
+  $("interrupt").addEventListener("click", function() {
+    send({op: "interrupt"}, function() {});
+  });
+
+  function connect() {
+    var scheme = location.protocol === "https:" ? "wss:" : "ws:";
+    ws = new WebSocket(scheme + "//" + location.host + "/ws?token=" + encodeURIComponent(token) +
+                       "&name=" + encodeURIComponent(name));
+    ws.onopen = function() {
+      setRunning(0);
+      refreshVars();
+      send({op: "hello"}, function(reply) {
+        me = reply.client.id;
+        people = {};
+        (reply.clients || []).forEach(function(p) { people[p.id] = p; });
+        showPeople();
+      });
+    };
+    ws.onmessage = function(e) {
+      var reply = JSON.parse(e.data);
+      switch (reply.op) {
+      case "evaluated":
+        addCell(reply, false);
+        refreshVars();
+        return;
+      case "joined":
+      case "renamed":
+      case "cursor":
+        people[reply.client.id] = reply.client;
+        showPeople();
+        return;
+      case "left":
+        delete people[reply.client.id];
+        showPeople();
+        return;
+      }
+      var done = pending[reply.id];
+      delete pending[reply.id];
+      if (done) {
+        done(reply);
+      } else if (reply.error) {
+        addCell({code: "", error: reply.error}, true);
+      }
+    };
+    ws.onclose = function() {
+      statusEl.textContent = "disconnected; reload to reconnect";
+      $("interrupt").disabled = true;
+    };
+  }
+  connect();
+})();
+</script>
+</body>
+</html>
+`
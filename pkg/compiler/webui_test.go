@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1374WebFrontendAndVariableExplorer(t *testing.T) {
+
+	cv.Convey("gi serve gives its web frontend at /, and lists the session's variables, in short, for its explorer", t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		s, err := NewServer(it, "sekrit")
+		panicOn(err)
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/")
+		panicOn(err)
+		page, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		panicOn(err)
+		cv.So(resp.StatusCode, cv.ShouldEqual, http.StatusOK)
+		cv.So(resp.Header.Get("Content-Type"), cv.ShouldStartWith, "text/html")
+		cv.So(string(page), cv.ShouldContainSubstring, `<textarea id="code"`)
+		cv.So(string(page), cv.ShouldContainSubstring, `"/ws?token="`)
+
+		_, reply := serveCall(srv, "sekrit", "eval", ServeRequest{Code: "type P struct{ X int }\np := P{X: 3}\nptr := &p\nxs := []int{1, 2, 3}\nlong := \"\"\nfor i := 0; i < 300; i++ {\n\tlong += \"é\"\n}\nconst c = 1\nfunc f() {}"})
+		cv.So(reply.Error, cv.ShouldEqual, "")
+
+		status, _ := serveCall(srv, "", "vars", ServeRequest{})
+		cv.So(status, cv.ShouldEqual, http.StatusUnauthorized)
+		_, reply = serveCall(srv, "sekrit", "vars", ServeRequest{})
+		cv.So(reply.Error, cv.ShouldEqual, "")
+		var names []string
+		for _, v := range reply.Vars {
+			names = append(names, v.Name)
+		}
+		cv.So(names, cv.ShouldResemble, []string{"long", "p", "ptr", "xs"})
+		cv.So(reply.Vars[0].Type, cv.ShouldEqual, "string")
+		cv.So(reply.Vars[0].Value, cv.ShouldEndWith, "...")
+		cv.So(len(reply.Vars[0].Value), cv.ShouldBeLessThanOrEqualTo, varsMaxLen+3)
+		cv.So(reply.Vars[0].Value, cv.ShouldStartWith, `"éé`)
+		cv.So(reply.Vars[1], cv.ShouldResemble, Var{Name: "p", Type: "P", Value: "main.P{X: 3}"})
+		cv.So(reply.Vars[2].Type, cv.ShouldEqual, "*P")
+		cv.So(reply.Vars[3], cv.ShouldResemble, Var{Name: "xs", Type: "[]int", Value: "[]int{1, 2, 3}"})
+	})
+}
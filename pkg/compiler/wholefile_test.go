@@ -0,0 +1,49 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/gijit/gi/pkg/front"
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1386WholeGoFileAtThePrompt(t *testing.T) {
+
+	cv.Convey("a whole .go file, package clause and all, is one input: its declarations join the session, and each func init runs", t, func() {
+		const file = `// Package main counts.
+package main
+
+import (
+	"fmt"
+)
+
+var n = 1
+
+func init() { n++ }
+func init() {
+	n *= 10
+	fmt.Println("init", n)
+}
+
+func main() {
+	fmt.Println("main", n)
+}
+`
+		eof, syntaxErr, _, _ := front.TopLevelParseGoSource([]byte(file))
+		cv.So(eof || syntaxErr, cv.ShouldBeFalse)
+		eof, syntaxErr, _, _ = front.TopLevelParseGoSource([]byte("package main\n\nfunc f() {\n"))
+		cv.So(syntaxErr, cv.ShouldBeFalse)
+		cv.So(eof, cv.ShouldBeTrue)
+
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+		var intr interruptWatcher
+		cv.So(replayOutput(evalCaptured(it, &intr, file)), cv.ShouldEqual, "init 20\n")
+		cv.So(replayOutput(evalCaptured(it, &intr, "main()")), cv.ShouldEqual, "main 20\n")
+
+		// another file of the same package, or not, adds to it.
+		cv.So(replayOutput(evalCaptured(it, &intr, "package other\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"other\", n) }\n")), cv.ShouldEqual, "")
+		cv.So(replayOutput(evalCaptured(it, &intr, "main()")), cv.ShouldEqual, "other 20\n")
+	})
+}
@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// whyStep is a step of the type checker's reasoning;
+// see types.Config.Explain.
+type whyStep struct {
+	depth int
+	msg   string
+}
+
+// Why type checks expr in the session's scope, without
+// translating or running it, and explains how the checker
+// arrived at its type, for :why. Each expression is shown
+// with its operand mode and type, nested under the one it
+// is part of; calls show the signature used and the
+// parameter each argument was passed to, and conversions
+// and assignments show why they were allowed. The error
+// is that of expr, if it does not type check; the steps
+// up to it are still explained.
+func (it *Interp) Why(expr string) (string, error) {
+	it.mut.Lock()
+	defer it.mut.Unlock()
+
+	var steps []whyStep
+	var firstErr error
+	conf := &types.Config{
+		Explain: func(pos token.Pos, depth int, msg string) {
+			steps = append(steps, whyStep{depth, msg})
+		},
+		// go on past errors, to explain the rest.
+		Error: func(err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		},
+	}
+	it.inc.pkgScope()
+	_, err := types.EvalWith(conf, it.inc.CurPkg.fileSet, it.inc.CurPkg.Arch.Pkg, token.NoPos, expr)
+	if err == nil {
+		err = firstErr
+	}
+	return formatWhy(steps), err
+}
+
+// formatWhy indents steps by depth. An expression that is
+// checked without further steps is shown once, as its
+// operand, rather than before and after.
+func formatWhy(steps []whyStep) string {
+	var b strings.Builder
+	for i := 0; i < len(steps); i++ {
+		s := steps[i]
+		if i+1 < len(steps) && steps[i+1].depth == s.depth && strings.HasPrefix(steps[i+1].msg, "=> ") && !strings.HasPrefix(s.msg, "=> ") {
+			i++
+			s.msg = steps[i].msg[len("=> "):]
+		}
+		b.WriteString(strings.Repeat("  ", s.depth))
+		b.WriteString(s.msg)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
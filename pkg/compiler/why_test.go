@@ -0,0 +1,52 @@
+package compiler
+
+import (
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func Test1332WhyExplainsTheChecker(t *testing.T) {
+
+	cv.Convey(`:why shows the operands of an expression, the signature a call used and the parameter each argument matched, and goes on past an error`, t, func() {
+		it, err := NewInterp(nil)
+		panicOn(err)
+		defer it.Close()
+
+		panicOn(it.Eval(`func add(a int, rest ...float64) float64 { return float64(a) + rest[0] }
+type T struct{}
+func (t *T) M(a int) int { return a }
+var t T`))
+
+		why, err := it.Why(`add(1, 2)`)
+		panicOn(err)
+		cv.So(why, cv.ShouldEqual, `add(1, 2)
+  add (value of type func(a int, rest ...float64) float64)
+  calling add, of type func(a int, rest ...float64) float64
+  1 (untyped int constant)
+  argument 1, 1, is passed to parameter 1, a int
+  1 (untyped int constant) takes the type int in argument to add
+  1 (constant of type int) is assignable to int in argument to add
+  2 (untyped int constant)
+  argument 2, 2, is an element of variadic parameter rest []float64, so must be assignable to float64
+  2 (untyped int constant) takes the type float64 in argument to add
+  2 (constant of type float64) is assignable to float64 in argument to add
+=> add(1, 2) (value of type float64)
+`)
+
+		// the method a redefinition left in place is named.
+		panicOn(it.Eval(`func (t *T) M(a, b int) int { return a + b }`))
+		why, err = it.Why(`t.M(1, 2, 3)`)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(err.Error(), cv.ShouldEndWith, "too many arguments")
+		cv.So(why, cv.ShouldContainSubstring, "    M selects func (*T).M(a int, b int) int, declared at repl[2]:1:13\n")
+		cv.So(why, cv.ShouldContainSubstring, "  argument 3 has no parameter: func(a int, b int) int takes only 2\n  error: too many arguments\n")
+
+		// nothing is translated or defined.
+		n := it.inc.nInput
+		_, err = it.Why(`float32(add(1))`)
+		panicOn(err)
+		cv.So(it.inc.nInput, cv.ShouldEqual, n)
+		cv.So(it.inc.pkgScope().Lookup("__gijit_ans"), cv.ShouldBeNil)
+	})
+}
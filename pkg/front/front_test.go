@@ -356,3 +356,24 @@ func Test022GoroutineLaunchAnonFunc(t *testing.T) {
 		cv.So(empty, cv.ShouldBeFalse)
 	})
 }
+
+func Test023MoreVsSyntaxErr(t *testing.T) {
+
+	cv.Convey("open block comments, composite literals and trailing operators need more input; an open interpreted string is an error", t, func() {
+
+		for _, src := range []string{"/* a", "x := 1 /* a\n b", "x := []int{\n1,\n2,", "x := T{A: 1,\n", "x := a &&\n", "x := 1 +\n// c\n", "y := x.("} {
+			eof, syntaxErr, empty, _ := TopLevelParseGoSource([]byte(src))
+			cv.So(syntaxErr, cv.ShouldBeFalse)
+			cv.So(eof, cv.ShouldBeTrue)
+			cv.So(empty, cv.ShouldBeFalse)
+		}
+		for _, src := range []string{"/* a\n */ x := 1", "x := []int{\n1,\n2,\n}", "x := `a\nb`"} {
+			eof, syntaxErr, _, _ := TopLevelParseGoSource([]byte(src))
+			cv.So(syntaxErr, cv.ShouldBeFalse)
+			cv.So(eof, cv.ShouldBeFalse)
+		}
+		eof, syntaxErr, _, _ := TopLevelParseGoSource([]byte(`s := "abc`))
+		cv.So(syntaxErr, cv.ShouldBeTrue)
+		cv.So(eof, cv.ShouldBeFalse)
+	})
+}
@@ -287,6 +287,12 @@ func (p *parser) fileOrNil() *File {
 		f.PkgName = p.name()
 		p.want(_Semi)
 	*/
+	// a whole file's package clause is allowed, and
+	// ignored: the input is complete, or not, as without it.
+	if p.got(_Package) {
+		f.PkgName = p.name()
+		p.want(_Semi)
+	}
 	// don't bother continuing if package clause has errors
 	if p.first != nil {
 		pp("p.first != nil, is '%#v', returning early. 'don't bother continuing if package clause has errors' <- but may not have to do with package, now at the repl...", p.first)
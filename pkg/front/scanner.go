@@ -621,8 +621,9 @@ func (s *scanner) fullComment() {
 			}
 		}
 		if r < 0 {
-			s.errh(s.line, s.col, "comment not terminated")
-			return
+			// like a raw string, the comment may end
+			// on a line still to come.
+			panic(ErrMoreInput)
 		}
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/gijit/gi/pkg/doc"
 	"github.com/gijit/gi/pkg/parser"
 	"github.com/gijit/gi/pkg/token"
+	"go/build/constraint"
 	"io"
 	"io/ioutil"
 	"log"
@@ -1190,8 +1191,11 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 	content = content[:end]
 
 	// Pass 2.  Process each line in the run.
+	// A //go:build line, if there is one, decides alone;
+	// the // +build lines are then only its older spelling.
 	p = content
 	allok := true
+	goBuildOK, sawGoBuild := true, false
 	for len(p) > 0 {
 		line := p
 		if i := bytes.IndexByte(line, '\n'); i >= 0 {
@@ -1206,6 +1210,14 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 		if bytes.Equal(line, binaryOnlyComment) {
 			sawBinaryOnly = true
 		}
+		if constraint.IsGoBuild(string(line)) {
+			x, err := constraint.Parse(string(line))
+			if !sawGoBuild {
+				sawGoBuild = true
+				goBuildOK = err == nil && x.Eval(func(tag string) bool { return ctxt.match(tag, allTags) })
+			}
+			continue
+		}
 		line = bytes.TrimSpace(line[len(slashslash):])
 		if len(line) > 0 && line[0] == '+' {
 			// Looks like a comment +line.
@@ -1228,6 +1240,9 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 		*binaryOnly = true
 	}
 
+	if sawGoBuild {
+		return goBuildOK
+	}
 	return allok
 }
 
@@ -209,6 +209,10 @@ var matchFileTests = []struct {
 	{ctxtAndroid, "plan9_test.go", "", true},
 	{ctxtAndroid, "arm.s", "", true},
 	{ctxtAndroid, "amd64.s", "", true},
+	{ctxtP9, "foo2.go", "//go:build plan9 && !linux\n\npackage main\n", true},
+	{ctxtP9, "foo3.go", "//go:build linux || windows\n\npackage main\n", false},
+	{ctxtP9, "foo4.go", "//go:build plan9\n// +build linux\n\npackage main\n", true},
+	{ctxtAndroid, "foo5.go", "//go:build (linux && arm) || ignore\n\npackage main\n", true},
 }
 
 func TestMatchFile(t *testing.T) {
@@ -0,0 +1,388 @@
+package importer
+
+import (
+	"fmt"
+	goconstant "go/constant"
+	goimporter "go/importer"
+	gotoken "go/token"
+	gotypes "go/types"
+	"strings"
+	"sync"
+
+	"github.com/gijit/gi/pkg/constant"
+	"github.com/gijit/gi/pkg/token"
+	"github.com/gijit/gi/pkg/types"
+)
+
+// Go releases since 1.20 ship no compiled packages in GOROOT,
+// so the gc importer above finds no export data for the
+// standard library. importFromHost is its fallback: the
+// go/types of the Go that built gijit loads the package, by
+// its export data or failing that from source, and the
+// result is converted to our types.
+//
+// Our type checker predates generics, so generic functions
+// and types are left out; an instantiated type becomes its
+// underlying type.
+
+var host struct {
+	mu    sync.Mutex
+	fset  *gotoken.FileSet
+	loads map[string]*hostLoad // by path, those imported or being
+	src   gotypes.ImporterFrom
+	srcMu sync.Mutex // the source importer's
+}
+
+// hostLoad is an import by the host's go/types, done or
+// under way.
+type hostLoad struct {
+	done chan struct{}
+	pkg  *gotypes.Package
+	err  error
+}
+
+// hostImport may be called by several goroutines at once,
+// as for the imports a session prepares together, or reads
+// ahead: a path being imported already is waited for, not
+// imported again. Each import reads export data with a gc
+// importer of its own, since one is not safe for concurrent
+// use; only the source importer, the slow fallback, is
+// shared, one at a time. A failed import is tried again.
+func hostImport(path, srcDir string) (*gotypes.Package, error) {
+	host.mu.Lock()
+	if host.fset == nil {
+		host.fset = gotoken.NewFileSet()
+		host.loads = make(map[string]*hostLoad)
+		host.src, _ = goimporter.ForCompiler(host.fset, "source", nil).(gotypes.ImporterFrom)
+	}
+	l := host.loads[path]
+	if l != nil {
+		host.mu.Unlock()
+		<-l.done
+		return l.pkg, l.err
+	}
+	l = &hostLoad{done: make(chan struct{})}
+	host.loads[path] = l
+	host.mu.Unlock()
+
+	l.pkg, l.err = loadFromHost(path, srcDir)
+	if l.err != nil {
+		host.mu.Lock()
+		delete(host.loads, path)
+		host.mu.Unlock()
+	}
+	close(l.done)
+	return l.pkg, l.err
+}
+
+func loadFromHost(path, srcDir string) (*gotypes.Package, error) {
+	var err error
+	if gc, ok := goimporter.ForCompiler(host.fset, "gc", nil).(gotypes.ImporterFrom); ok {
+		var pkg *gotypes.Package
+		if pkg, err = gc.ImportFrom(path, srcDir, 0); err == nil {
+			return pkg, nil
+		}
+	}
+	if host.src == nil {
+		return nil, err
+	}
+	host.srcMu.Lock()
+	defer host.srcMu.Unlock()
+	return host.src.ImportFrom(path, srcDir, 0)
+}
+
+// Prefetch has the host's types of the packages paths read
+// in the background, for a Lazy package of each, or another
+// import, to find them ready. They are read one after
+// another, in order, the first soonest.
+func Prefetch(paths ...string) {
+	go func() {
+		for _, path := range paths {
+			hostImport(path, "")
+		}
+	}()
+}
+
+// Lazy returns the package path, named name, whose objects
+// are made from the host's types only as the type checker
+// first looks each up, and whose types are read only when
+// it first looks one up, or asks for its names. Where that
+// fails, as where the host has no Go to read them with,
+// the package has no members, and the type checker says
+// why where one is used.
+func Lazy(path, name string) *types.Package {
+	pkg := types.NewPackage(path, name)
+	pkg.Scope().SetLazy(&lazyScope{path: path, pkg: pkg})
+	pkg.MarkComplete()
+	return pkg
+}
+
+// lazyScope makes the objects of a Lazy package.
+type lazyScope struct {
+	path string // the host's, whatever pkg's is made
+	pkg  *types.Package
+	gp   *gotypes.Package
+	c    *hostConverter
+}
+
+func (l *lazyScope) Names() ([]string, error) {
+	gp, err := hostImport(l.path, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not import %s (%v)", l.path, err)
+	}
+	l.gp = gp
+	l.c = &hostConverter{
+		packages: map[string]*types.Package{gp.Path(): l.pkg},
+		named:    make(map[*gotypes.TypeName]*types.Named),
+	}
+	var imports []*types.Package
+	for _, ip := range gp.Imports() {
+		imports = append(imports, l.c.pkg(ip))
+	}
+	l.pkg.SetImports(imports)
+
+	var names []string
+	scope := gp.Scope()
+	for _, name := range scope.Names() {
+		if !isGeneric(scope.Lookup(name)) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (l *lazyScope) Resolve(name string) types.Object {
+	o := l.gp.Scope().Lookup(name)
+	if o == nil {
+		return nil
+	}
+	return l.c.object(o)
+}
+
+// importFromHost imports path with the host's go/types, and
+// converts it into packages, which holds the packages
+// already converted by this importer; those the package
+// only refers to are entered incomplete, with just the
+// objects it needs.
+func importFromHost(packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if pkg := packages[path]; pkg != nil && pkg.Complete() {
+		return pkg, nil
+	}
+	gp, err := hostImport(path, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	c := &hostConverter{packages: packages, named: make(map[*gotypes.TypeName]*types.Named)}
+	pkg := c.pkg(gp)
+	scope := gp.Scope()
+	for _, name := range scope.Names() {
+		c.object(scope.Lookup(name))
+	}
+	var imports []*types.Package
+	for _, ip := range gp.Imports() {
+		imports = append(imports, c.pkg(ip))
+	}
+	pkg.SetImports(imports)
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+type hostConverter struct {
+	packages map[string]*types.Package
+	named    map[*gotypes.TypeName]*types.Named
+}
+
+func (c *hostConverter) pkg(gp *gotypes.Package) *types.Package {
+	if gp == nil {
+		return nil
+	}
+	pkg := c.packages[gp.Path()]
+	if pkg == nil {
+		pkg = types.NewPackage(gp.Path(), gp.Name())
+		c.packages[gp.Path()] = pkg
+	}
+	return pkg
+}
+
+// object converts the package level object o and enters it
+// in its package's scope, unless it is generic.
+func (c *hostConverter) object(o gotypes.Object) types.Object {
+	pkg := c.pkg(o.Pkg())
+	if prev := pkg.Scope().Lookup(o.Name()); prev != nil {
+		return prev
+	}
+	if isGeneric(o) {
+		return nil
+	}
+	var obj types.Object
+	switch o := o.(type) {
+	case *gotypes.TypeName:
+		if o.IsAlias() {
+			obj = types.NewTypeName(token.NoPos, pkg, o.Name(), c.typ(o.Type()))
+		} else {
+			// entered by typ, before its underlying type, so
+			// that recursive types find it.
+			c.typ(o.Type())
+			return pkg.Scope().Lookup(o.Name())
+		}
+	case *gotypes.Const:
+		obj = types.NewConst(token.NoPos, pkg, o.Name(), c.typ(o.Type()), c.constant(o.Val()))
+	case *gotypes.Var:
+		obj = types.NewVar(token.NoPos, pkg, o.Name(), c.typ(o.Type()))
+	case *gotypes.Func:
+		obj = types.NewFunc(token.NoPos, pkg, o.Name(), c.signature(o.Type().(*gotypes.Signature), nil))
+	default:
+		return nil
+	}
+	pkg.Scope().Insert(obj)
+	return obj
+}
+
+func isGeneric(o gotypes.Object) bool {
+	switch o := o.(type) {
+	case *gotypes.Func:
+		return o.Type().(*gotypes.Signature).TypeParams().Len() > 0
+	case *gotypes.TypeName:
+		if n, ok := gotypes.Unalias(o.Type()).(*gotypes.Named); ok {
+			return n.TypeParams().Len() > 0
+		}
+	}
+	return false
+}
+
+func (c *hostConverter) typ(t gotypes.Type) types.Type {
+	switch t := t.(type) {
+	case *gotypes.Alias:
+		return c.typ(gotypes.Unalias(t))
+	case *gotypes.Basic:
+		switch t.Name() {
+		case "byte", "rune":
+			return types.Universe.Lookup(t.Name()).Type()
+		}
+		return types.Typ[types.BasicKind(t.Kind())]
+	case *gotypes.Named:
+		return c.namedType(t)
+	case *gotypes.Pointer:
+		return types.NewPointer(c.typ(t.Elem()))
+	case *gotypes.Slice:
+		return types.NewSlice(c.typ(t.Elem()))
+	case *gotypes.Array:
+		return types.NewArray(c.typ(t.Elem()), t.Len())
+	case *gotypes.Map:
+		return types.NewMap(c.typ(t.Key()), c.typ(t.Elem()))
+	case *gotypes.Chan:
+		dir := types.SendRecv
+		switch t.Dir() {
+		case gotypes.SendOnly:
+			dir = types.SendOnly
+		case gotypes.RecvOnly:
+			dir = types.RecvOnly
+		}
+		return types.NewChan(dir, c.typ(t.Elem()))
+	case *gotypes.Signature:
+		return c.signature(t, nil)
+	case *gotypes.Struct:
+		var fields []*types.Var
+		var tags []string
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			fields = append(fields, types.NewField(token.NoPos, c.pkg(f.Pkg()), f.Name(), c.typ(f.Type()), f.Embedded()))
+			tags = append(tags, t.Tag(i))
+		}
+		return types.NewStruct(fields, tags)
+	case *gotypes.Interface:
+		// flattened: our interfaces embed only named types.
+		var methods []*types.Func
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			methods = append(methods, types.NewFunc(token.NoPos, c.pkg(m.Pkg()), m.Name(),
+				c.signature(m.Type().(*gotypes.Signature), nil)))
+		}
+		return types.NewInterface(methods, nil).Complete()
+	case *gotypes.Tuple:
+		return c.tuple(t)
+	}
+	panic(fmt.Sprintf("importer: cannot convert the type %v (%T)", t, t))
+}
+
+func (c *hostConverter) namedType(t *gotypes.Named) types.Type {
+	o := t.Obj()
+	if o.Pkg() == nil {
+		// error, the only named type of the universe
+		// outside constraints.
+		return types.Universe.Lookup(o.Name()).Type()
+	}
+	if t.TypeArgs().Len() > 0 {
+		return c.typ(t.Underlying())
+	}
+	if n := c.named[o]; n != nil {
+		return n
+	}
+	pkg := c.pkg(o.Pkg())
+	if prev, ok := pkg.Scope().Lookup(o.Name()).(*types.TypeName); ok {
+		if n, ok := prev.Type().(*types.Named); ok {
+			c.named[o] = n
+			return n
+		}
+	}
+	tn := types.NewTypeName(token.NoPos, pkg, o.Name(), nil)
+	n := types.NewNamed(tn, nil, nil)
+	c.named[o] = n
+	pkg.Scope().Insert(tn)
+	n.SetUnderlying(c.typ(t.Underlying()))
+	for i := 0; i < t.NumMethods(); i++ {
+		m := t.Method(i)
+		recv := m.Type().(*gotypes.Signature).Recv()
+		var rt types.Type = n
+		if _, ok := recv.Type().(*gotypes.Pointer); ok {
+			rt = types.NewPointer(n)
+		}
+		rv := types.NewParam(token.NoPos, pkg, recv.Name(), rt)
+		n.AddMethod(types.NewFunc(token.NoPos, c.pkg(m.Pkg()), m.Name(),
+			c.signature(m.Type().(*gotypes.Signature), rv)))
+	}
+	return n
+}
+
+func (c *hostConverter) tuple(t *gotypes.Tuple) *types.Tuple {
+	var vars []*types.Var
+	for i := 0; i < t.Len(); i++ {
+		v := t.At(i)
+		vars = append(vars, types.NewParam(token.NoPos, c.pkg(v.Pkg()), v.Name(), c.typ(v.Type())))
+	}
+	return types.NewTuple(vars...)
+}
+
+func (c *hostConverter) signature(s *gotypes.Signature, recv *types.Var) *types.Signature {
+	return types.NewSignature(recv, c.tuple(s.Params()), c.tuple(s.Results()), s.Variadic())
+}
+
+func (c *hostConverter) constant(v goconstant.Value) constant.Value {
+	switch v.Kind() {
+	case goconstant.Bool:
+		return constant.MakeBool(goconstant.BoolVal(v))
+	case goconstant.String:
+		return constant.MakeString(goconstant.StringVal(v))
+	case goconstant.Int:
+		return intConstant(v.ExactString())
+	case goconstant.Float:
+		num := intConstant(goconstant.Num(v).ExactString())
+		den := intConstant(goconstant.Denom(v).ExactString())
+		return constant.BinaryOp(num, token.QUO, den)
+	case goconstant.Complex:
+		re := c.constant(goconstant.Real(v))
+		im := c.constant(goconstant.Imag(v))
+		return constant.BinaryOp(re, token.ADD, constant.MakeImag(im))
+	}
+	return constant.MakeUnknown()
+}
+
+func intConstant(s string) constant.Value {
+	if strings.HasPrefix(s, "-") {
+		return constant.UnaryOp(token.SUB, constant.MakeFromLiteral(s[1:], token.INT, 0), 0)
+	}
+	return constant.MakeFromLiteral(s, token.INT, 0)
+}
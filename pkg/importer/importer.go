@@ -91,7 +91,14 @@ func (m *gcimports) ImportFrom(path, srcDir string, mode types.ImportMode) (*typ
 	if mode != 0 {
 		panic("mode must be 0")
 	}
-	return gcimporter.Import(m.packages, path, srcDir, m.lookup)
+	pkg, err := gcimporter.Import(m.packages, path, srcDir, m.lookup)
+	if err != nil && m.lookup == nil {
+		// no export data, as from Go 1.20 on.
+		if hpkg, herr := importFromHost(m.packages, path, srcDir); herr == nil {
+			return hpkg, nil
+		}
+	}
+	return pkg, err
 }
 
 // gccgo importer
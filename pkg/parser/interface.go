@@ -62,6 +62,7 @@ const (
 	Trace                                          // print a trace of parsed productions
 	DeclarationErrors                              // report declaration errors
 	SpuriousErrors                                 // same as AllErrors, for backward-compatibility
+	Tolerant                                       // parse source being typed: close what is left open, and report all errors
 	AllErrors         = SpuriousErrors             // report all errors (not just the first 10 on different lines)
 )
 
@@ -120,6 +121,12 @@ func ParseFile(fset *token.FileSet, filename string, src interface{}, mode Mode)
 	}()
 
 	// parse source
+	if mode&Tolerant != 0 {
+		// the AST then has what can be made of the
+		// source, for completion as it is typed.
+		text = closeOpen(text)
+		mode |= AllErrors
+	}
 	p.init(fset, filename, text, mode)
 	f = p.parseFile()
 
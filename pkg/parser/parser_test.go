@@ -548,3 +548,33 @@ type x int // comment
 		t.Errorf("got %q, want %q", comment, "// comment")
 	}
 }
+
+// TestTolerant ensures that source being typed, with what it
+// has opened left open, parses in Tolerant mode to the nodes
+// it would have once completed.
+func TestTolerant(t *testing.T) {
+	for _, test := range []struct{ src, want string }{
+		{"func f(p P) {\n\tp.", "*ast.SelectorExpr"},
+		{"x := T{A: 1", "*ast.CompositeLit"},
+		{"if a > 0 {\n\tg(a, b", "*ast.CallExpr"},
+		{"y := m[k", "*ast.IndexExpr"},
+		{"z := 1 + /* half a comment", "*ast.BinaryExpr"},
+	} {
+		fset := token.NewFileSet()
+		f, err := ParseFile(fset, "", test.src, Tolerant)
+		if f == nil {
+			t.Errorf("ParseFile(%q) returned no file: %v", test.src, err)
+			continue
+		}
+		found := false
+		for _, n := range f.Nodes {
+			ast.Inspect(n, func(n ast.Node) bool {
+				found = found || fmt.Sprintf("%T", n) == test.want
+				return true
+			})
+		}
+		if !found {
+			t.Errorf("ParseFile(%q) has no %s (err %v)", test.src, test.want, err)
+		}
+	}
+}
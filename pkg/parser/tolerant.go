@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"github.com/gijit/gi/pkg/scanner"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// closeOpen returns src, source being typed, completed as
+// far as can be told from its tokens for the Tolerant mode:
+// an open comment or raw string is ended, an operand missing
+// at the end, as after x. or a +, is given as _, and what is
+// still open of parentheses, brackets and braces is closed.
+// What is added comes after the end of src, so the positions
+// within src are those of src alone.
+func closeOpen(src []byte) []byte {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var suffix string
+	eh := func(pos token.Position, msg string) {
+		switch msg {
+		case "comment not terminated":
+			suffix = "*/"
+		case "raw string literal not terminated":
+			suffix = "`"
+		}
+	}
+	s.Init(file, src, eh, scanner.ScanComments)
+
+	var open []token.Token
+	last := token.ILLEGAL
+	lineComment := false
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.COMMENT {
+			lineComment = len(lit) > 1 && lit[1] == '/'
+			continue
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// inserted at a newline, or at the end.
+			continue
+		}
+		lineComment = false
+		last = tok
+		switch tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			// a mismatched closer closes what it matches.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tok-(token.RPAREN-token.LPAREN) {
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+
+	out := append([]byte(nil), src...)
+	out = append(out, suffix...)
+	if lineComment {
+		out = append(out, '\n')
+	}
+	if suffix == "" && needsOperand(last) {
+		out = append(out, " _"...)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		switch open[i] {
+		case token.LPAREN:
+			out = append(out, ')')
+		case token.LBRACK:
+			out = append(out, ']')
+		case token.LBRACE:
+			out = append(out, '}')
+		}
+	}
+	return out
+}
+
+// needsOperand reports whether an operand must follow tok.
+func needsOperand(tok token.Token) bool {
+	switch tok {
+	case token.PERIOD, token.ASSIGN, token.DEFINE, token.NOT, token.ARROW:
+		return true
+	}
+	return tok.Precedence() > 0 ||
+		tok >= token.ADD_ASSIGN && tok <= token.AND_NOT_ASSIGN
+}
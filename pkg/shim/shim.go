@@ -0,0 +1,88 @@
+// Package shim lets the author of a Go package ship a
+// hand-written Lua implementation of it, which gi imports in
+// place of its own: the package's API, declared in Go, and
+// Lua, free to use LuaJIT's FFI, that implements it.
+//
+// A program that embeds gi registers shims from an init
+// func, as database/sql drivers are registered:
+//
+//	func init() {
+//		shim.Register("github.com/foo/fast", shim.Shim{
+//			Go:  "package fast\n\nfunc Sum(xs []float64) float64 { return 0 }\n",
+//			Lua: "fast.Sum = function(xs) ... end",
+//		})
+//	}
+//
+// gi also finds shims in a directory, given by its -shims
+// flag or $GI_SHIMS, under the import path: the .go files of
+// dir/github.com/foo/fast declare the API, and its .lua files,
+// run in name order, implement it. A shim found there wins
+// over one registered, so that it can be tried out without
+// rebuilding gi.
+package shim
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A Shim is a hand-written implementation of a package.
+type Shim struct {
+	// Go is the source of a Go file, package clause and
+	// all, that declares the package's API to the type
+	// checker. The bodies of its funcs are never run;
+	// return zero values from them.
+	Go string
+
+	// Lua implements the API. It is run once, at the first
+	// import, with a local named for the package set to the
+	// package's table, which it fills with the package's
+	// funcs and vars under their Go names. Constants need
+	// nothing.
+	// Values are as gi represents them: an int is an int64
+	// cdata, a slice a table indexed from 0, a string a Lua
+	// string.
+	Lua string
+}
+
+var (
+	mu    sync.Mutex
+	shims = make(map[string]Shim)
+)
+
+// Register makes s the shim for the package of import
+// path path. It panics if path is empty, if s has no Go,
+// or if path already has a shim registered.
+func Register(path string, s Shim) {
+	mu.Lock()
+	defer mu.Unlock()
+	if path == "" || s.Go == "" {
+		panic("shim: Register needs an import path and the Go of its API")
+	}
+	if _, dup := shims[path]; dup {
+		panic(fmt.Sprintf("shim: Register called twice for %s", path))
+	}
+	shims[path] = s
+}
+
+// Lookup returns the shim registered for path, if any.
+func Lookup(path string) (Shim, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := shims[path]
+	return s, ok
+}
+
+// Paths lists, sorted, the import paths of the shims
+// registered.
+func Paths() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var paths []string
+	for path := range shims {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
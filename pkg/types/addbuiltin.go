@@ -0,0 +1,73 @@
+package types
+
+import (
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// A BuiltinKind says where a call of a function added with
+// Checker.AddBuiltin may appear.
+type BuiltinKind int
+
+const (
+	// An ExprBuiltin call is a value, like one of len: it
+	// may not stand alone as a statement.
+	ExprBuiltin BuiltinKind = iota
+
+	// A StmtBuiltin call may stand alone, like one of print.
+	StmtBuiltin
+)
+
+// AddBuiltin declares a function name, of signature sig, that
+// every input checked from now on sees the way it sees the
+// language's builtins: as if in the universe, so that any
+// other declaration of name shadows it. The function belongs
+// to no package and it is up to the back end to provide it;
+// Func.IsBuiltin tells such a function apart. Adding a name
+// again replaces the earlier function.
+func (check *Checker) AddBuiltin(name string, sig *Signature, kind BuiltinKind) *Func {
+	obj := NewFunc(token.NoPos, nil, name, sig)
+	obj.builtin = true
+	obj.kind = kind
+	if check.builtins == nil {
+		check.builtins = make(map[string]*Func)
+	}
+	check.builtins[name] = obj
+	return obj
+}
+
+// Builtins lists the functions added with AddBuiltin.
+func (check *Checker) Builtins() []*Func {
+	var list []*Func
+	for _, obj := range check.builtins {
+		list = append(list, obj)
+	}
+	return list
+}
+
+// calledBuiltin returns the function added with AddBuiltin that
+// fun, the function of a call, names, or nil if it names none.
+func (check *Checker) calledBuiltin(fun ast.Expr) *Func {
+	id, _ := unparen(fun).(*ast.Ident)
+	if id == nil {
+		return nil
+	}
+	obj := check.builtins[id.Name]
+	if obj == nil || check.scope == nil {
+		return nil
+	}
+	if _, shadow := check.scope.LookupParent(id.Name, check.pos); shadow != nil {
+		return nil
+	}
+	return obj
+}
+
+// builtinNames lists the names of the functions added with
+// AddBuiltin.
+func (check *Checker) builtinNames() []string {
+	var names []string
+	for name := range check.builtins {
+		names = append(names, name)
+	}
+	return names
+}
@@ -52,6 +52,31 @@ func (err Error) Error() string {
 	return fmt.Sprintf("%s: %s", err.Fset.Position(err.Pos), err.Msg)
 }
 
+// An InternalError describes a broken invariant of the checker
+// itself: a bug in gijit rather than in the code being checked.
+// Checker.Files and Eval return it instead of panicking, so that
+// the REPL can carry on.
+type InternalError struct {
+	Fset  *token.FileSet // file set for interpretation of Pos
+	Pos   token.Pos      // where checking was, if known
+	Msg   string         // what went wrong
+	Stack []byte         // the checker's stack at the failure
+}
+
+// BugReportURL is where to report an InternalError.
+const BugReportURL = "https://github.com/gijit/gi/issues"
+
+// Error returns the message, and asks for a bug report.
+func (err InternalError) Error() string {
+	where := ""
+	if err.Pos.IsValid() {
+		where = fmt.Sprintf(" at %s", err.Fset.Position(err.Pos))
+	}
+	return fmt.Sprintf("internal error in the type checker%s: %s\n"+
+		"This is a bug in gijit, not in your code. Please report it, with the input that caused it, at %s",
+		where, err.Msg, BugReportURL)
+}
+
 // An Importer resolves import paths to Packages.
 //
 // CAUTION: This interface does not support the import of locally
@@ -130,6 +155,23 @@ type Config struct {
 	// If DisableUnusedImportCheck is set, packages are not checked
 	// for unused imports.
 	DisableUnusedImportCheck bool
+
+	// If Explain != nil, it is called with each step of the
+	// checker's reasoning about expressions: an expression
+	// before it is checked, and its operand, prefixed by
+	// "=> ", after; the function chosen by a call and the
+	// parameter each argument is passed to; conversions and
+	// assignability decisions; and errors. depth is the
+	// nesting of expressions, from 0.
+	Explain func(pos token.Pos, depth int, msg string)
+
+	// If ForeignMethods != nil, a method may be declared on
+	// a named type of another package, and joins that type's
+	// method set for every package that imports it. This is
+	// for monkey-patching at the REPL. ForeignMethods is
+	// called with the receiver base type of each such method;
+	// if it returns an error, the receiver is invalid.
+	ForeignMethods func(recv *Named) error
 }
 
 // Info holds result type information for a type-checked package.
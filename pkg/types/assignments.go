@@ -14,6 +14,13 @@ import (
 
 var pp = verb.PP
 
+// ppOn reports whether pp prints. The checks made most
+// call pp only if it does, since its arguments are made,
+// and escape to the heap, whether or not it prints them.
+func ppOn() bool {
+	return verb.VerboseVerbose
+}
+
 // assignment reports whether x can be assigned to a variable of type T,
 // if necessary by attempting to convert untyped values to the appropriate
 // type. context describes the context in which the assignment takes place.
@@ -45,6 +52,11 @@ func (check *Checker) assignment(x *operand, T Type, context string) {
 				return
 			}
 			target = Default(x.typ)
+			if check.conf.Explain != nil {
+				check.explain(x.pos(), "%s takes its default type, %s, in %s", x, target, context)
+			}
+		} else if check.conf.Explain != nil {
+			check.explain(x.pos(), "%s takes the type %s in %s", x, target, context)
 		}
 		check.convertUntyped(x, target)
 		if x.mode == invalid {
@@ -67,6 +79,10 @@ func (check *Checker) assignment(x *operand, T Type, context string) {
 			check.errorf(x.pos(), "cannot use %s as %s value in %s", x, T, context)
 		}
 		x.mode = invalid
+		return
+	}
+	if check.conf.Explain != nil {
+		check.explain(x.pos(), "%s is assignable to %s in %s", x, T, context)
 	}
 }
 
@@ -133,7 +149,9 @@ func (check *Checker) initVar(lhs *Var, x *operand, context string) Type {
 }
 
 func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
-	pp("jea debug: Checker.assignVar() singular: lhs='%#v', x='%#v'", lhs, x)
+	if ppOn() {
+		pp("jea debug: Checker.assignVar() singular: lhs='%#v', x='%#v'", lhs, x)
+	}
 	if x.mode == invalid || x.typ == Typ[Invalid] {
 		return nil
 	}
@@ -165,8 +183,9 @@ func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
 		}
 	}
 
-	var z operand
-	check.expr(&z, lhs)
+	z := getOperand()
+	defer putOperand(z)
+	check.expr(z, lhs)
 	if v != nil {
 		v.used = v_used // restore v.used
 	}
@@ -191,7 +210,7 @@ func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
 				return nil
 			}
 		}
-		check.errorf(z.pos(), "cannot assign to %s", &z)
+		check.errorf(z.pos(), "cannot assign to %s", z)
 		return nil
 	}
 
@@ -232,30 +251,37 @@ func (check *Checker) initVars(lhs []*Var, rhs []ast.Expr, returnPos token.Pos)
 		context = "return statement"
 	}
 
-	var x operand
+	x := getOperand()
+	defer putOperand(x)
 	if commaOk {
 		var a [2]Type
 		for i := range a {
-			get(&x, i)
-			a[i] = check.initVar(lhs[i], &x, context)
+			get(x, i)
+			a[i] = check.initVar(lhs[i], x, context)
 		}
 		check.recordCommaOkTypes(rhs[0], a)
 		return
 	}
 
 	for i, lhs := range lhs {
-		get(&x, i)
-		check.initVar(lhs, &x, context)
+		get(x, i)
+		check.initVar(lhs, x, context)
 	}
 }
 
 func (check *Checker) assignVars(lhs, rhs []ast.Expr, s *ast.AssignStmt) (ok bool) {
-	pp("jea: assignVars() starting regular assignment, lhs='%#v', rhs='%#v'", lhs, rhs)
+	if ppOn() {
+		pp("jea: assignVars() starting regular assignment, lhs='%#v', rhs='%#v'", lhs, rhs)
+	}
 	scope := check.scope
 	defer func() {
-		pp("jea: assignment ok, scope='%#v', check.pkg.scope='%#v'", scope, check.pkg.scope)
+		if ppOn() {
+			pp("jea: assignment ok, scope='%#v', check.pkg.scope='%#v'", scope, check.pkg.scope)
+		}
 		if ok && (scope == nil || scope == check.pkg.scope) {
-			pp("jea: assignment at package scope, making NewCode")
+			if ppOn() {
+				pp("jea: assignment at package scope, making NewCode")
+			}
 			check.NewCode = append(check.NewCode,
 				&NewStuff{
 					Scope:      scope,
@@ -275,20 +301,21 @@ func (check *Checker) assignVars(lhs, rhs []ast.Expr, s *ast.AssignStmt) (ok boo
 		return check.firstErr == nil
 	}
 
-	var x operand
+	x := getOperand()
+	defer putOperand(x)
 	if commaOk {
 		var a [2]Type
 		for i := range a {
-			get(&x, i)
-			a[i] = check.assignVar(lhs[i], &x)
+			get(x, i)
+			a[i] = check.assignVar(lhs[i], x)
 		}
 		check.recordCommaOkTypes(rhs[0], a)
 		return check.firstErr == nil
 	}
 
 	for i, lhs := range lhs {
-		get(&x, i)
-		check.assignVar(lhs, &x)
+		get(x, i)
+		check.assignVar(lhs, x)
 	}
 	return check.firstErr == nil
 }
@@ -299,8 +326,12 @@ func (check *Checker) shortVarDecl(pos token.Pos, lhs, rhs []ast.Expr, stmt ast.
 
 	// collect lhs variables
 	var newVars []*Var
-	var lhsVars = make([]*Var, len(lhs))
-	for i, lhs := range lhs {
+	var lhsBuf [4]*Var // most declare few
+	lhsVars := lhsBuf[:0]
+	if len(lhs) > len(lhsBuf) {
+		lhsVars = make([]*Var, 0, len(lhs))
+	}
+	for _, lhs := range lhs {
 		var obj *Var
 		if ident, _ := lhs.(*ast.Ident); ident != nil {
 			// Use the correct obj if the ident is redeclared. The
@@ -329,7 +360,9 @@ func (check *Checker) shortVarDecl(pos token.Pos, lhs, rhs []ast.Expr, stmt ast.
 				check.recordUse(ident, alt)
 			} else {
 				// declare new variable, possibly a blank (_) variable
-				pp("shortVarDecl: declaring new variable name='%s', obj='%#v'/%T", name, obj, obj)
+				if ppOn() {
+					pp("shortVarDecl: declaring new variable name='%s', obj='%#v'/%T", name, obj, obj)
+				}
 				obj = NewVar(ident.Pos(), check.pkg, name, nil)
 				if name != "_" {
 					newVars = append(newVars, obj)
@@ -342,7 +375,7 @@ func (check *Checker) shortVarDecl(pos token.Pos, lhs, rhs []ast.Expr, stmt ast.
 		if obj == nil {
 			obj = NewVar(lhs.Pos(), check.pkg, "_", nil) // dummy variable
 		}
-		lhsVars[i] = obj
+		lhsVars = append(lhsVars, obj)
 	}
 
 	check.initVars(lhsVars, rhs, token.NoPos)
@@ -329,6 +329,20 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 		x.mode = value
 		x.typ = Typ[Int]
 
+	case _Clear:
+		// clear(m) or clear(s)
+		switch x.typ.Underlying().(type) {
+		case *Map, *Slice:
+		default:
+			check.invalidArg(x.pos(), "cannot clear %s: argument must be (or constrained by) map or slice", x)
+			return
+		}
+
+		x.mode = novalue
+		if check.Types != nil {
+			check.recordBuiltinType(call.Fun, makeSig(nil, x.typ))
+		}
+
 	case _Delete:
 		// delete(m, k)
 		m, _ := x.typ.Underlying().(*Map)
@@ -454,6 +468,80 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 			check.recordBuiltinType(call.Fun, makeSig(x.typ, params[:1+len(sizes)]...))
 		}
 
+	case _Max, _Min:
+		// max(x T, y ...T) T
+		// min(x T, y ...T) T
+		// spec (Go 1.21): "The built-in functions min and max
+		// compute the smallest—or largest, respectively—value
+		// of a fixed number of arguments of ordered types."
+		op := token.LSS
+		if id == _Max {
+			op = token.GTR
+		}
+
+		first := *x
+		args := []*operand{&first}
+		for i := 1; i < nargs; i++ {
+			a := new(operand)
+			arg(a, i)
+			if a.mode == invalid {
+				return
+			}
+			args = append(args, a)
+		}
+		for _, a := range args {
+			if !isOrdered(a.typ) {
+				check.invalidArg(a.pos(), "%s cannot be ordered", a)
+				return
+			}
+		}
+
+		for _, a := range args[1:] {
+			// an untyped operand takes the type of the other,
+			// unless one is a string and the other a number.
+			if isString(x.typ) == isString(a.typ) {
+				check.convertUntyped(x, a.typ)
+				if x.mode == invalid {
+					return
+				}
+				check.convertUntyped(a, x.typ)
+				if a.mode == invalid {
+					return
+				}
+			}
+			if !Identical(x.typ, a.typ) {
+				check.invalidArg(a.pos(), "mismatched types %s (previous argument) and %s (type of %s)", x.typ, a.typ, a.expr)
+				return
+			}
+			if x.mode == constant_ && a.mode == constant_ {
+				if constant.Compare(a.val, op, x.val) {
+					*x = *a
+				}
+			} else {
+				x.mode = value
+			}
+		}
+
+		// a result that is not constant has a type.
+		if x.mode != constant_ {
+			x.mode = value
+			if isUntyped(x.typ) {
+				check.convertUntyped(x, Default(x.typ))
+				if x.mode == invalid {
+					return
+				}
+			}
+		}
+
+		params := make([]Type, len(args))
+		for i, a := range args {
+			check.updateExprType(a.expr, x.typ, true)
+			params[i] = x.typ
+		}
+		if check.Types != nil && x.mode != constant_ {
+			check.recordBuiltinType(call.Fun, makeSig(x.typ, params...))
+		}
+
 	case _New:
 		// new(T)
 		// (no argument evaluated yet)
@@ -629,16 +717,19 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 // makeSig makes a signature for the given argument and result types.
 // Default types are used for untyped arguments, and res may be nil.
 func makeSig(res Type, args ...Type) *Signature {
-	pp("jea debug: makeSig called to make new Signature! args='%#v'\n", args)
-	list := make([]*Var, len(args))
-	for i, param := range args {
-		list[i] = NewVar(token.NoPos, nil, "", Default(param))
+	if ppOn() {
+		pp("jea debug: makeSig called to make new Signature! args='%#v'\n", args)
+	}
+	var buf [3]Type // most builtins take few
+	typs := buf[:0]
+	for _, param := range args {
+		typs = append(typs, Default(param))
 	}
-	params := NewTuple(list...)
+	params := newTupleOf(token.NoPos, nil, typs...)
 	var result *Tuple
 	if res != nil {
 		assert(!isUntyped(res))
-		result = NewTuple(NewVar(token.NoPos, nil, "", res))
+		result = newTupleOf(token.NoPos, nil, res)
 	}
 	return &Signature{params: params, results: result}
 }
@@ -38,6 +38,9 @@ var builtinCalls = []struct {
 	{"len", `var c chan<-bool; _ = len(c)`, `func(chan<- bool) int`},
 	{"len", `var m map[string]float32; _ = len(m)`, `func(map[string]float32) int`},
 
+	{"clear", `var m map[float64]int; clear(m)`, `func(map[float64]int)`},
+	{"clear", `var s []byte; clear(s)`, `func([]byte)`},
+
 	{"close", `var c chan int; close(c)`, `func(chan int)`},
 	{"close", `var c chan<- chan string; close(c)`, `func(chan<- chan string)`},
 
@@ -71,6 +74,15 @@ var builtinCalls = []struct {
 	{"make", `_ = make([]int, 10)`, `func([]int, int) []int`},
 	{"make", `type T []byte; _ = make(T, 10, 20)`, `func(p.T, int, int) p.T`},
 
+	{"max", `_ = max(0, 1)`, `invalid type`}, // constant
+	{"max", `var x int; _ = max(x)`, `func(int) int`},
+	{"max", `var x float32; _ = max(x, 1.5)`, `func(float32, float32) float32`},
+	{"max", `type S string; var a, b S; _ = max(a, b, "z")`, `func(p.S, p.S, p.S) p.S`},
+
+	{"min", `_ = min("a", "b")`, `invalid type`}, // constant
+	{"min", `var x uint8; _ = min(x, 2)`, `func(uint8, uint8) uint8`},
+	{"min", `var x, y int64; _ = min(1, x, y)`, `func(int64, int64, int64) int64`},
+
 	{"new", `_ = new(int)`, `func(int) *int`},
 	{"new", `type T struct{}; _ = new(T)`, `func(p.T) *p.T`},
 
@@ -14,7 +14,9 @@ import (
 func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 	switch x.typ.Underlying().(type) {
 	case *Signature:
-		pp("Checker.call called with e = '%s', x = '%#v', sig='%s'", e, x, x.typ.Underlying().(*Signature))
+		if ppOn() {
+			pp("Checker.call called with e = '%s', x = '%#v', sig='%s'", e, x, x.typ.Underlying().(*Signature))
+		}
 	}
 	check.exprOrType(x, e.Fun)
 
@@ -33,6 +35,9 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 		case 0:
 			check.errorf(e.Rparen, "missing argument in conversion to %s", T)
 		case 1:
+			if check.conf.Explain != nil {
+				check.explain(e.Lparen, "converting to %s", T)
+			}
 			check.expr(x, e.Args[0])
 			if x.mode != invalid {
 				check.conversion(x, T)
@@ -58,9 +63,15 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 	default:
 		// function/method call
 		sig, _ := x.typ.Underlying().(*Signature)
-		pp("on redef, sig is wrong here. got sig = '%s'", sig)
-		pp("x.typ = '%#v'", x.typ.Underlying())
-		pp("x.typ.Underlying() = '%#v'", x.typ.Underlying())
+		if ppOn() {
+			pp("on redef, sig is wrong here. got sig = '%s'", sig)
+		}
+		if ppOn() {
+			pp("x.typ = '%#v'", x.typ.Underlying())
+		}
+		if ppOn() {
+			pp("x.typ.Underlying() = '%#v'", x.typ.Underlying())
+		}
 		if sig == nil {
 			check.invalidOp(x.pos(), "cannot call non-function %s", x)
 			x.mode = invalid
@@ -68,9 +79,14 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 			return statement
 		}
 
+		if check.conf.Explain != nil {
+			check.explain(e.Lparen, "calling %s, of type %s", e.Fun, sig)
+		}
 		arg, n, _ := unpack(func(x *operand, i int) { check.multiExpr(x, e.Args[i]) }, len(e.Args), false)
 		if arg != nil {
-			pp("before check.aruments(), in call.go arg = '%#v'", arg)
+			if ppOn() {
+				pp("before check.aruments(), in call.go arg = '%#v'", arg)
+			}
 			check.arguments(x, e, sig, arg, n)
 		} else {
 			x.mode = invalid
@@ -91,6 +107,9 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 		x.expr = e
 		check.hasCallOrRecv = true
 
+		if f := check.calledBuiltin(e.Fun); f != nil && f.kind == ExprBuiltin {
+			return expression
+		}
 		return statement
 	}
 }
@@ -147,17 +166,21 @@ func unpack(get getter, n int, allowCommaOk bool) (getter, int, bool) {
 		return get, n, false
 	}
 	// possibly result of an n-valued function call or comma,ok value
-	var x0 operand
-	get(&x0, 0)
+	x0 := getOperand()
+	defer putOperand(x0)
+	get(x0, 0)
 	if x0.mode == invalid {
 		return nil, 0, false
 	}
+	// the getters below keep copies of what they need of
+	// x0, in themselves, rather than x0.
+	expr := x0.expr
 
 	if t, ok := x0.typ.(*Tuple); ok {
 		// result of an n-valued function call
 		return func(x *operand, i int) {
 			x.mode = value
-			x.expr = x0.expr
+			x.expr = expr
 			x.typ = t.At(i).typ
 		}, t.Len(), false
 	}
@@ -168,7 +191,7 @@ func unpack(get getter, n int, allowCommaOk bool) (getter, int, bool) {
 			a := [2]Type{x0.typ, Typ[UntypedBool]}
 			return func(x *operand, i int) {
 				x.mode = value
-				x.expr = x0.expr
+				x.expr = expr
 				x.typ = a[i]
 			}, 2, true
 		}
@@ -176,18 +199,21 @@ func unpack(get getter, n int, allowCommaOk bool) (getter, int, bool) {
 	}
 
 	// single value
+	v := *x0
 	return func(x *operand, i int) {
 		if i != 0 {
 			unreachable()
 		}
-		*x = x0
+		*x = v
 	}, 1, false
 }
 
 // arguments checks argument passing for the call with the given signature.
 // The arg function provides the operand for the i'th argument.
 func (check *Checker) arguments(x *operand, call *ast.CallExpr, sig *Signature, arg getter, n int) {
-	pp("top of Checker.arguments: sig = '%s'", sig)
+	if ppOn() {
+		pp("top of Checker.arguments: sig = '%s'", sig)
+	}
 	if call.Ellipsis.IsValid() {
 		// last argument is of the form x...
 		if !sig.variadic {
@@ -230,7 +256,9 @@ func (check *Checker) arguments(x *operand, call *ast.CallExpr, sig *Signature,
 // argument checks passing of argument x to the i'th parameter of the given signature.
 // If ellipsis is valid, the argument is followed by ... at that position in the call.
 func (check *Checker) argument(fun ast.Expr, sig *Signature, i int, x *operand, ellipsis token.Pos) {
-	pp("check.Checker argument top: sig = '%s'", sig.String())
+	if ppOn() {
+		pp("check.Checker argument top: sig = '%s'", sig.String())
+	}
 	check.singleValue(x)
 	if x.mode == invalid {
 		return
@@ -254,6 +282,7 @@ func (check *Checker) argument(fun ast.Expr, sig *Signature, i int, x *operand,
 		// jea: after re-defining a method in 069 repl_test, and
 		// trying to call with the new method that has 1 more arg,
 		// we are failing here.
+		check.explain(x.pos(), "argument %d has no parameter: %s takes only %d", i+1, sig, n)
 		check.errorf(x.pos(), "too many arguments")
 		return
 	}
@@ -272,6 +301,19 @@ func (check *Checker) argument(fun ast.Expr, sig *Signature, i int, x *operand,
 		// use the variadic parameter slice's element type
 		typ = typ.(*Slice).elem
 	}
+	p := sig.params.vars[n-1]
+	if i < n {
+		p = sig.params.vars[i]
+	}
+	switch {
+	case check.conf.Explain == nil:
+	case ellipsis.IsValid():
+		check.explain(x.pos(), "argument %d, %s..., is the slice of variadic parameter %s %s", i+1, x.expr, p.name, p.typ)
+	case sig.variadic && i >= n-1:
+		check.explain(x.pos(), "argument %d, %s, is an element of variadic parameter %s %s, so must be assignable to %s", i+1, x.expr, p.name, p.typ, typ)
+	default:
+		check.explain(x.pos(), "argument %d, %s, is passed to parameter %d, %s %s", i+1, x.expr, i+1, p.name, p.typ)
+	}
 
 	check.assignment(x, typ, check.sprintf("argument to %s", fun))
 }
@@ -285,7 +327,9 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 	)
 
 	sel := e.Sel.Name
-	pp("jea debug: check.selector top. sel='%s'", sel)
+	if ppOn() {
+		pp("jea debug: check.selector top. sel='%s'", sel)
+	}
 
 	// If the identifier refers to a package, handle everything here
 	// so we don't need a "package" mode for operands: package names
@@ -293,29 +337,39 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 	// selector expressions.
 	if check.scope == nil {
 		// yep, this is the problem!
-		pp("jea debug problem! check.scope is nil in call.go:283, check.selector()")
+		if ppOn() {
+			pp("jea debug problem! check.scope is nil in call.go:283, check.selector()")
+		}
 		//check.scope.Dump()
-		panic("scope should not be nil!")
+		check.internalErrorf(e.Pos(), "scope should not be nil, checking %s", ExprString(e))
 	} else {
-		pp("call.go:282 we think fmt.Sprintf lookup is failing b/c check.scope isn't set right. check.scope = %p = '%s', with %v children", check.scope, check.scope, len(check.scope.children))
+		if ppOn() {
+			pp("call.go:282 we think fmt.Sprintf lookup is failing b/c check.scope isn't set right. check.scope = %p = '%s', with %v children", check.scope, check.scope, len(check.scope.children))
+		}
 	}
 	if ident, ok := e.X.(*ast.Ident); ok {
 		//pp("here!! jea: this is the lookup that is going wrong for out-of-date s.inc call")
 		//check.scope.Dump()
 		_, obj := check.scope.LookupParent(ident.Name, check.pos)
 
-		pp("jea debug: identifier refers to a package or struct? ok was true, ident.Name='%#v', sel='%#v', obj='%#v'", ident.Name, sel, obj) // ident.Name="s", sel="inc", obj='&types.Var{object:types.object{parent:(*types.Scope)(0xc4200608a0), pos:215, pkg:(*types.Package)(0xc4200b8690), name:"s", typ:types.Type(nil), order_:0x4, scopePos_:0}, anonymous:false, visited:false, isField:false, used:false}'
+		if ppOn() {
+			pp("jea debug: identifier refers to a package or struct? ok was true, ident.Name='%#v', sel='%#v', obj='%#v'", ident.Name, sel, obj) // ident.Name="s", sel="inc", obj='&types.Var{object:types.object{parent:(*types.Scope)(0xc4200608a0), pos:215, pkg:(*types.Package)(0xc4200b8690), name:"s", typ:types.Type(nil), order_:0x4, scopePos_:0}, anonymous:false, visited:false, isField:false, used:false}'
+		}
 
 		if pname, _ := obj.(*PkgName); pname != nil {
-			pp("inside the obj was *PkgName path...") // fmt.Sprintf not getting here, obj nil.
+			if ppOn() {
+				pp("inside the obj was *PkgName path...") // fmt.Sprintf not getting here, obj nil.
+			}
 			assert(pname.pkg == check.pkg)
 			check.recordUse(ident, pname)
 			pname.used = true
 			pkg := pname.imported
 			exp := pkg.scope.Lookup(sel)
 			if exp == nil {
-				if !pkg.fake {
-					check.errorf(e.Pos(), "%s not declared by package %s", sel, pkg.name)
+				if err := pkg.scope.lazyErr; err != nil {
+					check.errorf(e.Pos(), "%v", err)
+				} else if !pkg.fake {
+					check.errorf(e.Pos(), "%s not declared by package %s%s", sel, pkg.name, didYouMean(sel, exportedNames(pkg)))
 				}
 				goto Error
 			}
@@ -342,7 +396,9 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 			case *Func:
 				x.mode = value
 				x.typ = exp.typ
-				pp("wrote x.typ = exp.typ = '%#v'", exp.typ)
+				if ppOn() {
+					pp("wrote x.typ = exp.typ = '%#v'", exp.typ)
+				}
 			case *Builtin:
 				x.mode = builtin
 				x.typ = exp.typ
@@ -370,11 +426,16 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 		case indirect:
 			check.invalidOp(e.Pos(), "%s is not in method set of %s", sel, x.typ)
 		default:
-			check.invalidOp(e.Pos(), "%s has no field or method %s", x, sel)
+			check.invalidOp(e.Pos(), "%s has no field or method %s%s", x, sel, didYouMean(sel, check.selectableNames(x.typ)))
 		}
 		goto Error
 	}
 
+	if check.conf.Explain != nil {
+		check.explain(e.Sel.Pos(), "%s selects %s, declared at %s", sel, obj, check.fset.Position(obj.Pos()))
+	}
+	check.recordRef(obj)
+
 	if x.mode == typexpr {
 		// method expression
 		m, _ := obj.(*Func)
@@ -417,7 +478,9 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 		case *Func:
 			// TODO(gri) If we needed to take into account the receiver's
 			// addressability, should we report the type &(x.typ) instead?
-			pp("prior to recordSelection, x.typ='%#v", x.typ)
+			if ppOn() {
+				pp("prior to recordSelection, x.typ='%#v", x.typ)
+			}
 			check.recordSelection(e, MethodVal, x.typ, obj, index, indirect)
 
 			if debug {
@@ -443,11 +506,13 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 				// lookup.
 				mset := NewMethodSet(typ)
 				if m := mset.Lookup(check.pkg, sel); m == nil || m.obj != obj {
-					pp("sel='%v'; m == nil? : %v", sel, m == nil) // true here
+					if ppOn() {
+						pp("sel='%v'; m == nil? : %v", sel, m == nil) // true here
+					}
 					check.dump("e.Pos(): %s: (%s).%v -> %s", e.Pos(), typ, obj.name, m)
 					check.dump("mset: %s\n", mset)
 					// jea debug
-					panic("method sets and lookup don't agree")
+					check.internalErrorf(e.Pos(), "method sets and lookup don't agree on (%s).%s", typ, sel)
 				}
 			} // end debug
 
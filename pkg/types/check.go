@@ -7,9 +7,11 @@
 package types
 
 import (
+	"fmt"
 	"github.com/gijit/gi/pkg/ast"
 	"github.com/gijit/gi/pkg/constant"
 	"github.com/gijit/gi/pkg/token"
+	runtimedebug "runtime/debug"
 )
 
 // debugging/development support
@@ -45,6 +47,7 @@ type funcInfo struct {
 	decl *DeclInfo // for cycle detection
 	sig  *Signature
 	body *ast.BlockStmt
+	refs objSet // of the function's declaration, for the dependency graph
 }
 
 // A context represents the context within which an object is type-checked.
@@ -55,6 +58,7 @@ type context struct {
 	sig           *Signature     // function signature if inside a function; nil otherwise
 	hasLabel      bool           // set if a function makes use of labels (only ~1% of functions); unused outside functions
 	hasCallOrRecv bool           // set if an expression contains a function call or channel receive operation
+	refs          objSet         // objects the package-level declaration being checked refers to; nil otherwise
 }
 
 // An importKey identifies an imported package by import path and source directory
@@ -79,6 +83,9 @@ type Checker struct {
 	ObjMap map[Object]*DeclInfo   // maps package-level object to declaration info
 	impMap map[importKey]*Package // maps (import path, source directory) to (complete or fake) package
 
+	builtins map[string]*Func // functions added with AddBuiltin
+	depGraph map[Object]objSet // see DepGraph
+
 	// information collected during type-checking of a set of package files
 	// (initialized by Files, valid only for the duration of check.Files;
 	// maps and lists are allocated on demand)
@@ -87,6 +94,7 @@ type Checker struct {
 
 	firstErr error                 // first error encountered
 	Methods  map[string][]*Func    // maps type names to associated methods
+	foreign  []foreignMethod       // methods on imported types; see Config.ForeignMethods
 	untyped  map[ast.Expr]exprInfo // map of expressions without final type
 	funcs    []funcInfo            // list of functions to type-check
 	delayed  []func()              // delayed checks requiring fully setup types
@@ -147,7 +155,7 @@ func (check *Checker) rememberUntyped(e ast.Expr, lhs bool, mode operandMode, ty
 }
 
 func (check *Checker) later(name string, decl *DeclInfo, sig *Signature, body *ast.BlockStmt) {
-	check.funcs = append(check.funcs, funcInfo{name, decl, sig, body})
+	check.funcs = append(check.funcs, funcInfo{name, decl, sig, body, check.refs})
 }
 
 func (check *Checker) delay(f func()) {
@@ -186,6 +194,7 @@ func (check *Checker) initFiles(files []*ast.File) {
 
 	check.firstErr = nil
 	check.Methods = nil
+	check.foreign = nil
 	check.untyped = nil
 	check.funcs = nil
 	check.delayed = nil
@@ -235,12 +244,35 @@ func (check *Checker) handleBailout(err *error) {
 	case nil, bailout:
 		// normal return or early exit
 		*err = check.firstErr
-	default:
+	case errorCallbackPanic:
 		// re-panic
-		panic(p)
+		panic(p.val)
+	case InternalError:
+		check.resetContext()
+		*err = p
+	default:
+		// an assertion, or a runtime error, in the checker.
+		check.resetContext()
+		*err = InternalError{
+			Fset:  check.fset,
+			Pos:   token.NoPos,
+			Msg:   fmt.Sprint(p),
+			Stack: runtimedebug.Stack(),
+		}
 	}
 }
 
+// resetContext puts the checker back at package level
+// after it stopped part way, so it can check more files.
+func (check *Checker) resetContext() {
+	check.context = context{}
+	if check.pkg != nil {
+		check.context.scope = check.pkg.scope
+	}
+	check.pos = token.NoPos
+	check.indent = 0
+}
+
 // Files checks the provided files as part of the checker's package.
 func (check *Checker) Files(files []*ast.File) error { return check.checkFiles(files) }
 
@@ -289,7 +321,9 @@ func (check *Checker) recordUntyped() {
 }
 
 func (check *Checker) recordTypeAndValue(x ast.Expr, mode operandMode, typ Type, val constant.Value) {
-	pp("check.recordTypeAndValue recording x='%s', typ='%s'", x, typ)
+	if ppOn() {
+		pp("check.recordTypeAndValue recording x='%s', typ='%s'", x, typ)
+	}
 	assert(x != nil)
 	assert(typ != nil)
 	if mode == invalid {
@@ -334,10 +368,7 @@ func (check *Checker) recordCommaOkTypes(x ast.Expr, a [2]Type) {
 			tv := m[x]
 			assert(tv.Type != nil) // should have been recorded already
 			pos := x.Pos()
-			tv.Type = NewTuple(
-				NewVar(pos, check.pkg, "", a[0]),
-				NewVar(pos, check.pkg, "", a[1]),
-			)
+			tv.Type = newTupleOf(pos, check.pkg, a[0], a[1])
 			m[x] = tv
 			// if x is a parenthesized expression (p.X), update p.X
 			p, _ := x.(*ast.ParenExpr)
@@ -352,7 +383,9 @@ func (check *Checker) recordCommaOkTypes(x ast.Expr, a [2]Type) {
 func (check *Checker) recordDefAtScope(id *ast.Ident, obj Object, scope *Scope, node ast.Node) {
 	assert(id != nil)
 	check.recordDef(id, obj)
-	pp("adding NewCode for id='%#v', obj.Name()='%v'", id, obj.Name())
+	if ppOn() {
+		pp("adding NewCode for id='%#v', obj.Name()='%v'", id, obj.Name())
+	}
 	check.NewCode = append(check.NewCode,
 		&NewStuff{
 			Obj:        obj,
@@ -383,6 +416,9 @@ func (check *Checker) recordDef(id *ast.Ident, obj Object) {
 			//case *Var:
 			case *TypeName:
 				check.deleteFromObjMapPriorTypeName(oname)
+				if t, _ := prior.Type().(*Named); t != nil {
+					forgetLookups(t)
+				}
 			}
 		} else {
 			//pp("prior was nil, for obj.Name()='%s'!, here is stack:\n%s\n", obj.Name(), string(runtimedebug.Stack()))
@@ -395,6 +431,17 @@ func (check *Checker) recordDef(id *ast.Ident, obj Object) {
 	}
 }
 
+// recordMemberDef records the definition of a field or a
+// method. It redefines nothing at package level, even when
+// named like a type there, so it skips recordDef's REPL
+// redefinition: an embedded T would otherwise drop T from
+// the ObjMap, and with it the methods later added to T.
+func (check *Checker) recordMemberDef(id *ast.Ident, obj Object) {
+	if m := check.Defs; m != nil {
+		m[id] = obj
+	}
+}
+
 func (check *Checker) deleteFromObjMapPriorTypeName(name string) {
 	m := check.ObjMap
 	for k := range m {
@@ -37,6 +37,12 @@ func (check *Checker) conversion(x *operand, T Type) {
 		ok = true
 	}
 
+	if check.conf.Explain == nil {
+	} else if ok && constArg && isConstType(T) {
+		check.explain(x.pos(), "constant %s is representable as %s", x, T)
+	} else if ok {
+		check.explain(x.pos(), "%s is convertible to %s", x, T)
+	}
 	if !ok {
 		check.errorf(x.pos(), "cannot convert %s to %s", x, T)
 		x.mode = invalid
@@ -5,8 +5,6 @@
 package types
 
 import (
-	"fmt"
-
 	"github.com/gijit/gi/pkg/ast"
 	"github.com/gijit/gi/pkg/constant"
 	"github.com/gijit/gi/pkg/token"
@@ -24,7 +22,9 @@ func (check *Checker) reportAltDecl(obj Object) {
 }
 
 func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token.Pos) {
-	pp("declare called for obj.Name()='%s', id='%#v', scope='%#v'", obj.Name(), id, scope)
+	if ppOn() {
+		pp("declare called for obj.Name()='%s', id='%#v', scope='%#v'", obj.Name(), id, scope)
+	}
 
 	// spec: "The blank identifier, represented by the underscore
 	// character _, may be used in a declaration like any other
@@ -42,7 +42,9 @@ func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token
 			// jea replace:
 			// alt = scope.Insert(obj)
 			alt = scope.Replace(obj)
-			pp("types/decl.go:40 we did scope.Replace obj for obs = '%#v'", obj)
+			if ppOn() {
+				pp("types/decl.go:40 we did scope.Replace obj for obs = '%#v'", obj)
+			}
 		} else {
 			// at top level package scope
 			//jea: alt = check.pkg.scope.Insert(obj)
@@ -50,10 +52,14 @@ func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token
 		}
 		if alt != nil {
 			// re-declaration of variables, such as `a:=1;a:=1` errors out here.
-			pp("previous re-declaration errors now never happen at the repl, so we detect for repl purposes: alt.Name()='%s'. obj='%T'/'%#v'", alt.Name(), obj, obj)
+			if ppOn() {
+				pp("previous re-declaration errors now never happen at the repl, so we detect for repl purposes: alt.Name()='%s'. obj='%T'/'%#v'", alt.Name(), obj, obj)
+			}
 			switch alt.(type) {
 			case *TypeName:
-				pp("we have a TypeName being re-declared: '%s'", alt.Name())
+				if ppOn() {
+					pp("we have a TypeName being re-declared: '%s'", alt.Name())
+				}
 			}
 			/*		panic("should never reach")
 					check.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
@@ -63,7 +69,9 @@ func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token
 		}
 		obj.setScopePos(pos)
 	}
-	pp("declare: just before id != nil check. id='%#v'", id)
+	if ppOn() {
+		pp("declare: just before id != nil check. id='%#v'", id)
+	}
 	if id != nil {
 		//check.recordDef(id, obj)
 		check.recordDefAtScope(id, obj, scope, nil)
@@ -102,6 +110,7 @@ func (check *Checker) objDecl(obj Object, def *Named, path []*TypeName) {
 	}(check.context)
 	check.context = context{
 		scope: d.File,
+		refs:  check.newRefs(obj),
 	}
 
 	// Const and var declarations must not have initialization
@@ -261,6 +270,7 @@ func (n *Named) setUnderlying(typ Type) {
 	}
 	if n != nil {
 		n.underlying = typ
+		forgetLookups(n)
 	}
 }
 
@@ -299,7 +309,7 @@ func (check *Checker) typeDecl(obj *TypeName, typ ast.Expr, def *Named, path []*
 		// Determine the (final, unnamed) underlying type by resolving
 		// any forward chain (they always end in an unnamed type).
 		named.underlying = underlying(named.underlying)
-
+		forgetLookups(named)
 	}
 
 	// check and add associated methods
@@ -317,7 +327,53 @@ func (check *Checker) addMethodDecls(obj *TypeName) {
 		return // no methods
 	}
 	delete(check.Methods, obj.name)
+	check.addMethods(obj, methods)
+}
 
+// foreignMethod is a method declared on a type of another
+// package, with the file scope to resolve the package name
+// of its receiver in.
+type foreignMethod struct {
+	scope *Scope
+	recv  *ast.SelectorExpr
+	meth  *Func
+}
+
+// addForeignMethodDecls adds the methods declared on imported
+// types to them. A receiver that does not name an imported
+// type is left alone here, to be reported with the rest of
+// the method's signature.
+func (check *Checker) addForeignMethodDecls() {
+	var order []*TypeName
+	byType := make(map[*TypeName][]*Func)
+	for _, f := range check.foreign {
+		x, _ := f.recv.X.(*ast.Ident)
+		if x == nil {
+			continue
+		}
+		_, obj := f.scope.LookupParent(x.Name, token.NoPos)
+		pname, _ := obj.(*PkgName)
+		if pname == nil {
+			continue
+		}
+		tname, _ := pname.imported.scope.Lookup(f.recv.Sel.Name).(*TypeName)
+		if tname == nil {
+			continue
+		}
+		if byType[tname] == nil {
+			order = append(order, tname)
+		}
+		byType[tname] = append(byType[tname], f.meth)
+	}
+	check.foreign = nil
+	for _, tname := range order {
+		check.addMethods(tname, byType[tname])
+	}
+}
+
+// addMethods type checks the methods declared on obj and
+// adds them to its method set.
+func (check *Checker) addMethods(obj *TypeName, methods []*Func) {
 	// use an objset to check for name conflicts
 	var mset objset
 
@@ -373,18 +429,11 @@ func (check *Checker) addMethodDecls(obj *TypeName) {
 						// Hmm... we have an Object, not an *ast.Ident.
 						//delete(check.Defs, prior)
 
-						pp("check.Methods has len %v", len(check.Methods))
-						for i, slc := range check.Methods {
-							fmt.Printf("methods[%v] = \n", i)
-							for j, fn := range slc {
-								fmt.Printf("   [%v] = '%s'\n", j, fn.String())
-							}
-						}
-
 						// need to delete the method from the type too.
 						for i, curm := range base.methods {
 							if curm == prior {
 								base.methods = append(base.methods[:i], base.methods[i+1:]...)
+								forgetLookups(base)
 								break
 							}
 						}
@@ -408,6 +457,7 @@ func (check *Checker) addMethodDecls(obj *TypeName) {
 		// methods with blank _ names cannot be found - don't keep them
 		if base != nil && m.name != "_" {
 			base.methods = append(base.methods, m)
+			forgetLookups(base)
 		}
 	}
 }
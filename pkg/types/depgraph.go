@@ -0,0 +1,92 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/gijit/gi/pkg/ast"
+	"github.com/gijit/gi/pkg/token"
+)
+
+// The dependency graph records, for each package-level object
+// and each method of the package, the package-level objects and
+// methods its declaration refers to. Unlike the deps of a
+// DeclInfo, which only order initialization, it covers types
+// and methods, and the variables that top-level statements
+// such as x := f() declare; and it is kept across calls of
+// Files, so that a REPL can tell what a redefinition breaks.
+
+// newRefs starts the set of objects the declaration of obj
+// refers to.
+func (check *Checker) newRefs(obj Object) objSet {
+	if check.depGraph == nil {
+		check.depGraph = make(map[Object]objSet)
+	}
+	refs := make(objSet)
+	check.depGraph[obj] = refs
+	return refs
+}
+
+// recordRef notes that the declaration being checked refers
+// to obj, if obj belongs in the dependency graph.
+func (check *Checker) recordRef(obj Object) {
+	if check.refs != nil && check.inDepGraph(obj) {
+		check.refs[obj] = true
+	}
+}
+
+// recordDefineRefs gives refs, the objects top-level statement
+// s refers to, to the variables it declares, if any.
+func (check *Checker) recordDefineRefs(s *ast.AssignStmt, refs objSet) {
+	if s.Tok != token.DEFINE {
+		return
+	}
+	for _, lhs := range s.Lhs {
+		id, _ := lhs.(*ast.Ident)
+		if id == nil {
+			continue
+		}
+		obj := check.pkg.scope.Lookup(id.Name)
+		if obj == nil || obj.Pos() != id.Pos() {
+			continue // not declared here
+		}
+		own := check.newRefs(obj)
+		for ref := range refs {
+			own[ref] = true
+		}
+	}
+}
+
+// inDepGraph reports whether obj is a package-level object
+// or a method of the package being checked.
+func (check *Checker) inDepGraph(obj Object) bool {
+	if obj.Pkg() != check.pkg || check.pkg == nil {
+		return false
+	}
+	if f, _ := obj.(*Func); f != nil {
+		if sig, _ := f.typ.(*Signature); sig != nil && sig.recv != nil {
+			return true
+		}
+	}
+	return obj.Parent() == check.pkg.scope
+}
+
+// DepGraph returns the dependency graph of the declarations
+// checked so far: for each package-level object and method,
+// the package-level objects and methods its declaration
+// refers to, in the order they were declared. Objects that
+// have since been redeclared are included; it is up to the
+// caller to tell them apart. The map is the caller's.
+func (check *Checker) DepGraph() map[Object][]Object {
+	g := make(map[Object][]Object, len(check.depGraph))
+	for obj, refs := range check.depGraph {
+		list := make([]Object, 0, len(refs))
+		for ref := range refs {
+			if ref != obj {
+				list = append(list, ref)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Pos() < list[j].Pos() })
+		g[obj] = list
+	}
+	return g
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/gijit/gi/pkg/ast"
 	"github.com/gijit/gi/pkg/token"
+	runtimedebug "runtime/debug"
 	"strings"
 )
 
@@ -61,6 +62,14 @@ func (check *Checker) trace(pos token.Pos, format string, args ...interface{}) {
 	)
 }
 
+// explain reports a step of the checker's
+// reasoning to Config.Explain, if set.
+func (check *Checker) explain(pos token.Pos, format string, args ...interface{}) {
+	if check.conf.Explain != nil {
+		check.conf.Explain(pos, check.indent, check.sprintf(format, args...))
+	}
+}
+
 // dump is only needed for debugging
 func (check *Checker) dump(format string, args ...interface{}) {
 	fmt.Println(check.sprintf(format, args...))
@@ -68,6 +77,7 @@ func (check *Checker) dump(format string, args ...interface{}) {
 
 func (check *Checker) err(pos token.Pos, msg string, soft bool) {
 	err := Error{check.fset, pos, msg, soft}
+	check.explain(pos, "error: %s", msg)
 	if check.firstErr == nil {
 		check.firstErr = err
 	}
@@ -75,9 +85,30 @@ func (check *Checker) err(pos token.Pos, msg string, soft bool) {
 	if f == nil {
 		panic(bailout{}) // report only first error
 	}
+	defer func() {
+		// a panic of the client's own is passed on as is.
+		if p := recover(); p != nil {
+			panic(errorCallbackPanic{p})
+		}
+	}()
 	f(err)
 }
 
+// errorCallbackPanic carries a panic out of Config.Error.
+type errorCallbackPanic struct {
+	val interface{}
+}
+
+// internalErrorf reports a broken invariant of the checker at pos.
+func (check *Checker) internalErrorf(pos token.Pos, format string, args ...interface{}) {
+	panic(InternalError{
+		Fset:  check.fset,
+		Pos:   pos,
+		Msg:   check.sprintf(format, args...),
+		Stack: runtimedebug.Stack(),
+	})
+}
+
 func (check *Checker) error(pos token.Pos, msg string) {
 	check.err(pos, msg, false)
 }
@@ -6,6 +6,7 @@ package types
 
 import (
 	"fmt"
+	"github.com/gijit/gi/pkg/ast"
 	"github.com/gijit/gi/pkg/parser"
 	"github.com/gijit/gi/pkg/token"
 )
@@ -35,6 +36,12 @@ import (
 // respective context-specific type.
 //
 func Eval(fset *token.FileSet, pkg *Package, pos token.Pos, expr string) (TypeAndValue, error) {
+	return EvalWith(nil, fset, pkg, pos, expr)
+}
+
+// EvalWith is Eval, checking expr with the Config conf,
+// which may be nil.
+func EvalWith(conf *Config, fset *token.FileSet, pkg *Package, pos token.Pos, expr string) (_ TypeAndValue, err error) {
 	// determine scope
 	var scope *Scope
 	if pkg == nil {
@@ -71,7 +78,7 @@ func Eval(fset *token.FileSet, pkg *Package, pos token.Pos, expr string) (TypeAn
 	}
 
 	// initialize checker
-	check := NewChecker(nil, fset, pkg, nil)
+	check := NewChecker(conf, fset, pkg, nil)
 	check.scope = scope
 	check.pos = pos
 	defer check.handleBailout(&err)
@@ -81,3 +88,32 @@ func Eval(fset *token.FileSet, pkg *Package, pos token.Pos, expr string) (TypeAn
 	check.rawExpr(&x, node, nil)
 	return TypeAndValue{x.mode, x.typ, x.val}, err
 }
+
+// CheckExpr type checks expr, already parsed, in the package
+// scope of pkg, and records in info what Checker.Files
+// would: the types of its expressions, the objects of its
+// identifiers and its scopes, within the bodies of its
+// function literals too. With conf.Error set, checking goes
+// on past errors, so an expression being typed, parsed in
+// the parser's Tolerant mode, is checked as far as it can
+// be. The package scope is left as it was.
+func CheckExpr(conf *Config, fset *token.FileSet, pkg *Package, expr ast.Expr, info *Info) (_ TypeAndValue, err error) {
+	scope := pkg.scope
+	nchild := len(scope.children)
+	defer func() {
+		// drop the scopes of expr's function literals.
+		scope.children = scope.children[:nchild]
+	}()
+
+	check := NewChecker(conf, fset, pkg, info)
+	check.scope = scope
+	defer check.handleBailout(&err)
+
+	var x operand
+	check.rawExpr(&x, expr, nil)
+	for _, f := range check.delayed {
+		f()
+	}
+	check.recordUntyped()
+	return TypeAndValue{x.mode, x.typ, x.val}, err
+}
@@ -761,10 +761,11 @@ var binaryOpPredicates = opPredicates{
 
 // The binary expression e may be nil. It's passed in for better error messages only.
 func (check *Checker) binary(x *operand, e *ast.BinaryExpr, lhs, rhs ast.Expr, op token.Token) {
-	var y operand
+	y := getOperand()
+	defer putOperand(y)
 
 	check.expr(x, lhs)
-	check.expr(&y, rhs)
+	check.expr(y, rhs)
 
 	if x.mode == invalid {
 		return
@@ -776,7 +777,7 @@ func (check *Checker) binary(x *operand, e *ast.BinaryExpr, lhs, rhs ast.Expr, o
 	}
 
 	if isShift(op) {
-		check.shift(x, &y, e, op)
+		check.shift(x, y, e, op)
 		return
 	}
 
@@ -784,14 +785,14 @@ func (check *Checker) binary(x *operand, e *ast.BinaryExpr, lhs, rhs ast.Expr, o
 	if x.mode == invalid {
 		return
 	}
-	check.convertUntyped(&y, x.typ)
+	check.convertUntyped(y, x.typ)
 	if y.mode == invalid {
 		x.mode = invalid
 		return
 	}
 
 	if isComparison(op) {
-		check.comparison(x, &y, op)
+		check.comparison(x, y, op)
 		return
 	}
 
@@ -957,16 +958,22 @@ const (
 // If hint != nil, it is the type of a composite literal element.
 //
 func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type) exprKind {
-	pp("Checker.rawExpr() called, with e='%#v', x='%#v", e, x)
+	if ppOn() {
+		pp("Checker.rawExpr() called, with e='%#v', x='%#v", e, x)
+	}
 	if x != nil && x.typ != nil {
-		pp("Checker.rawExpr() called, x.typ='%v'", x.typ.String())
+		if ppOn() {
+			pp("Checker.rawExpr() called, x.typ='%v'", x.typ.String())
+		}
 	}
 	if x.typ != nil {
 		u := x.typ.Underlying()
 		if u != nil {
 			switch u.(type) {
 			case *Signature:
-				pp("sig at Checker.rawExpr() called, x.typ.Underlying().(*Signature)='%s'", x.typ.Underlying().(*Signature)) /// func(b int) int, wrong here.
+				if ppOn() {
+					pp("sig at Checker.rawExpr() called, x.typ.Underlying().(*Signature)='%s'", x.typ.Underlying().(*Signature)) /// func(b int) int, wrong here.
+				}
 			}
 		}
 	}
@@ -977,6 +984,13 @@ func (check *Checker) rawExpr(x *operand, e ast.Expr, hint Type) exprKind {
 			check.indent--
 			check.trace(e.Pos(), "=> %s", x)
 		}()
+	} else if check.conf.Explain != nil {
+		check.explain(e.Pos(), "%s", e)
+		check.indent++
+		defer func() {
+			check.indent--
+			check.explain(e.Pos(), "=> %s", x)
+		}()
 	}
 
 	kind := check.exprInternal(x, e, hint)
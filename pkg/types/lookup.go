@@ -58,7 +58,9 @@ func LookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 //           types always have only one representation (even when imported
 //           indirectly via different packages.)
 
-func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (obj Object, index []int, indirect bool) {
+// walkFieldOrMethod does the lookup of lookupFieldOrMethod,
+// and returns as well the named types it went through.
+func walkFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (obj Object, index []int, indirect bool, seen map[*Named]bool) {
 	// WARNING: The code in this function is extremely subtle - do not modify casually!
 	//          This function and NewMethodSet should be kept in sync.
 
@@ -76,14 +78,13 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 	// Start with typ as single entry at shallowest depth.
 	current := []embeddedType{{typ, nil, isPtr, false}}
 
-	// Named types that we have seen already, allocated lazily.
+	// Named types that we have seen already, in seen, allocated lazily.
 	// Used to avoid endless searches in case of recursive types.
 	// Since only Named types can be used for recursive types, we
 	// only need to track those.
 	// (If we ever allow type aliases to construct recursive types,
 	// we must use type identity rather than pointer equality for
 	// the map key comparison, as we do in consolidateMultiples.)
-	var seen map[*Named]bool
 
 	// search current depth
 	for len(current) > 0 {
@@ -115,7 +116,7 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 					assert(m.typ != nil)
 					index = concat(e.index, i)
 					if obj != nil || e.multiples {
-						return nil, index, false // collision
+						return nil, index, false, seen // collision
 					}
 					obj = m
 					indirect = e.indirect
@@ -134,7 +135,7 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 						assert(f.typ != nil)
 						index = concat(e.index, i)
 						if obj != nil || e.multiples {
-							return nil, index, false // collision
+							return nil, index, false, seen // collision
 						}
 						obj = f
 						indirect = e.indirect
@@ -165,7 +166,7 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 					assert(m.typ != nil)
 					index = concat(e.index, i)
 					if obj != nil || e.multiples {
-						return nil, index, false // collision
+						return nil, index, false, seen // collision
 					}
 					obj = m
 					indirect = e.indirect
@@ -180,7 +181,7 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 			//        list of m. If x is addressable and &x's method set contains m, x.m()
 			//        is shorthand for (&x).m()".
 			if f, _ := obj.(*Func); f != nil && ptrRecv(f) && !indirect && !addressable {
-				return nil, nil, true // pointer/addressable receiver required
+				return nil, nil, true, seen // pointer/addressable receiver required
 			}
 			return
 		}
@@ -188,7 +189,7 @@ func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (o
 		current = consolidateMultiples(next)
 	}
 
-	return nil, nil, false // not found
+	return nil, nil, false, seen // not found
 }
 
 // embeddedType represents an embedded type
@@ -248,6 +249,32 @@ func lookupType(m map[Type]int, typ Type) (int, bool) {
 // x is of interface type V).
 //
 func MissingMethod(V Type, T *Interface, static bool) (method *Func, wrongType bool) {
+	missingMethods(V, T, static, func(m *Func, wrong bool) bool {
+		method, wrongType = m, wrong
+		return false
+	})
+	return
+}
+
+// MissingMethods is like MissingMethod, but reports every method
+// of T that V does not have, in missing, and every one it has
+// with another type, in wrongType. For :implements.
+func MissingMethods(V Type, T *Interface, static bool) (missing, wrongType []*Func) {
+	missingMethods(V, T, static, func(m *Func, wrong bool) bool {
+		if wrong {
+			wrongType = append(wrongType, m)
+		} else {
+			missing = append(missing, m)
+		}
+		return true
+	})
+	return
+}
+
+// missingMethods calls report with each method of T that V is
+// missing or has with the wrong type, in the order of T's
+// methods, until report returns false.
+func missingMethods(V Type, T *Interface, static bool, report func(m *Func, wrongType bool) bool) {
 	// fast path for common case
 	if T.Empty() {
 		return
@@ -261,11 +288,13 @@ func MissingMethod(V Type, T *Interface, static bool) (method *Func, wrongType b
 			_, obj := lookupMethod(ityp.allMethods, m.pkg, m.name)
 			switch {
 			case obj == nil:
-				if static {
-					return m, false
+				if static && !report(m, false) {
+					return
 				}
 			case !Identical(obj.Type(), m.typ):
-				return m, true
+				if !report(m, true) {
+					return
+				}
 			}
 		}
 		return
@@ -277,15 +306,18 @@ func MissingMethod(V Type, T *Interface, static bool) (method *Func, wrongType b
 
 		f, _ := obj.(*Func)
 		if f == nil {
-			return m, false
+			if !report(m, false) {
+				return
+			}
+			continue
 		}
 
 		if !Identical(f.typ, m.typ) {
-			return m, true
+			if !report(m, true) {
+				return
+			}
 		}
 	}
-
-	return
 }
 
 // assertableTo reports whether a value of type V can be asserted to have type T.
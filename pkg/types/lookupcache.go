@@ -0,0 +1,109 @@
+package types
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/ast"
+)
+
+// At the REPL the same fields and methods of the same types
+// are selected eval after eval, and every lookup walks the
+// embedding chain of its type afresh. So lookupFieldOrMethod
+// keeps its results in lookups, shared by the Checkers of all
+// goroutines and by the callers of LookupFieldOrMethod. A
+// result depends only on the named types its walk went
+// through: when one of those gets another underlying type or
+// another method, or its name is redefined, the results that
+// went through it are forgotten.
+
+// lookupKey identifies a lookup. Exported names are found
+// from any package, so pkg is kept only for the others.
+type lookupKey struct {
+	typ         Type // T, or the base type of pointer T
+	ptr         bool
+	addressable bool
+	pkg         *Package
+	name        string
+}
+
+type lookupResult struct {
+	obj      Object
+	index    []int
+	indirect bool
+}
+
+// lookupCacheMax bounds the results, and the dependencies on
+// them, that are kept; past it the cache starts over.
+const lookupCacheMax = 1 << 15
+
+type lookupCache struct {
+	mu      sync.Mutex
+	gen     uint64 // counts forgets; a walk that spans one is not kept
+	results map[lookupKey]lookupResult
+	deps    map[*Named][]lookupKey
+	ndeps   int
+}
+
+var lookups lookupCache
+
+func lookupFieldOrMethod(T Type, addressable bool, pkg *Package, name string) (obj Object, index []int, indirect bool) {
+	k := lookupKey{typ: T, addressable: addressable, name: name}
+	if p, _ := T.(*Pointer); p != nil {
+		k.typ, k.ptr = p.base, true
+	}
+	if !ast.IsExported(name) {
+		k.pkg = pkg
+	}
+
+	lookups.mu.Lock()
+	r, ok := lookups.results[k]
+	gen := lookups.gen
+	lookups.mu.Unlock()
+	if ok {
+		return r.obj, r.index, r.indirect
+	}
+
+	obj, index, indirect, seen := walkFieldOrMethod(T, addressable, pkg, name)
+	// the index is shared from here on: an append copies it.
+	index = index[:len(index):len(index)]
+
+	lookups.mu.Lock()
+	if lookups.gen == gen {
+		lookups.put(k, lookupResult{obj, index, indirect}, seen)
+	}
+	lookups.mu.Unlock()
+	return
+}
+
+// put keeps r, for k, until one of the named types seen, or
+// that of k, is forgotten.
+func (c *lookupCache) put(k lookupKey, r lookupResult, seen map[*Named]bool) {
+	if c.results == nil || len(c.results) >= lookupCacheMax || c.ndeps >= lookupCacheMax {
+		c.results = make(map[lookupKey]lookupResult)
+		c.deps = make(map[*Named][]lookupKey)
+		c.ndeps = 0
+	}
+	c.results[k] = r
+	if n, _ := k.typ.(*Named); n != nil && !seen[n] {
+		c.deps[n] = append(c.deps[n], k)
+		c.ndeps++
+	}
+	for n := range seen {
+		c.deps[n] = append(c.deps[n], k)
+		c.ndeps++
+	}
+}
+
+// forgetLookups drops the results of the lookups that went
+// through n. It is called whenever n is changed or redefined.
+func forgetLookups(n *Named) {
+	c := &lookups
+	c.mu.Lock()
+	c.gen++
+	for _, k := range c.deps[n] {
+		delete(c.results, k)
+	}
+	c.ndeps -= len(c.deps[n])
+	delete(c.deps, n)
+	c.mu.Unlock()
+}
@@ -232,6 +232,8 @@ func (*Var) isDependency() {} // a variable may be a dependency of an initializa
 // An abstract method may belong to many interfaces due to embedding.
 type Func struct {
 	object
+	builtin bool        // added with Checker.AddBuiltin
+	kind    BuiltinKind // if builtin
 }
 
 // NewFunc returns a new function with the given signature, representing
@@ -243,9 +245,13 @@ func NewFunc(pos token.Pos, pkg *Package, name string, sig *Signature) *Func {
 	if sig != nil {
 		typ = sig
 	}
-	return &Func{object{nil, pos, pkg, name, typ, 0, token.NoPos}}
+	return &Func{object: object{nil, pos, pkg, name, typ, 0, token.NoPos}}
 }
 
+// IsBuiltin reports whether obj was added with Checker.AddBuiltin,
+// and if so, where a call of it may appear.
+func (obj *Func) IsBuiltin() (kind BuiltinKind, ok bool) { return obj.kind, obj.builtin }
+
 // FullName returns the package- or receiver-type-qualified name of
 // function or method obj.
 func (obj *Func) FullName() string {
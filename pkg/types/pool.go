@@ -0,0 +1,66 @@
+package types
+
+import (
+	"sync"
+
+	"github.com/gijit/gi/pkg/token"
+)
+
+// The checker makes and drops an operand for nearly every
+// expression and statement; most escape to the heap, as
+// the errors that print them take their address. At the
+// REPL a large paste is checked in one go, and that garbage
+// made for GC pauses. So the operands of the statements,
+// assignments and binary expressions that are checked most
+// come from operandPool, shared by the Checkers of all
+// goroutines, and go back to it when their check is done.
+// The tuples made for calls of builtins and for comma-ok
+// values, of one or two variables, are made whole, in one
+// allocation each.
+
+var operandPool = sync.Pool{
+	New: func() interface{} { return new(operand) },
+}
+
+// getOperand returns a zero operand from the pool. It goes
+// back with putOperand once nothing refers to it.
+func getOperand() *operand {
+	return operandPool.Get().(*operand)
+}
+
+// putOperand zeroes x, and returns it to the pool.
+func putOperand(x *operand) {
+	*x = operand{}
+	operandPool.Put(x)
+}
+
+// smallTuple is a tuple of up to two variables, with them.
+type smallTuple struct {
+	tuple Tuple
+	ptrs  [2]*Var
+	vars  [2]Var
+}
+
+// newTupleOf returns a tuple of unnamed variables of pkg at
+// pos, of the types typs; nil if there are none.
+func newTupleOf(pos token.Pos, pkg *Package, typs ...Type) *Tuple {
+	var t *Tuple
+	var vars []Var
+	switch n := len(typs); {
+	case n == 0:
+		return nil
+	case n <= 2:
+		s := new(smallTuple)
+		t = &s.tuple
+		t.vars = s.ptrs[:n]
+		vars = s.vars[:n]
+	default:
+		t = &Tuple{vars: make([]*Var, n)}
+		vars = make([]Var, n)
+	}
+	for i, typ := range typs {
+		vars[i].object = object{nil, pos, pkg, "", typ, 0, token.NoPos}
+		t.vars[i] = &vars[i]
+	}
+	return t
+}
@@ -118,10 +118,41 @@ func (check *Checker) declarePkgObj(ident *ast.Ident, obj Object, d *DeclInfo) {
 		return
 	}
 
+	alt := check.pkg.scope.Lookup(ident.Name)
 	check.declare(check.pkg.scope, ident, obj, token.NoPos)
 	pp("REDECLARE jea debug. check.ObjMap[obj] being assigned d. obj.Id()='%s', d='%#v'", obj.Id(), d)
 	check.ObjMap[obj] = d
 	obj.setOrder(uint32(len(check.ObjMap)))
+
+	if alt, ok := alt.(*TypeName); ok {
+		check.followAliases(alt, ident, d)
+	}
+}
+
+// followAliases, at the REPL, redeclares each package level
+// alias of the type name alt, which ident now redeclares,
+// as an alias of ident: an alias goes on meaning its target
+// as that is redefined. The aliases are replaced in the
+// scope, rather than changed, so that an input that fails
+// can be rolled back.
+func (check *Checker) followAliases(alt *TypeName, ident *ast.Ident, d *DeclInfo) {
+	if alt.typ == nil || alt.IsAlias() {
+		// an alias of an alias is one of the type itself.
+		return
+	}
+	scope := check.pkg.scope
+	for _, name := range scope.Names() {
+		a, ok := scope.Lookup(name).(*TypeName)
+		if !ok || a.typ != alt.typ || !a.IsAlias() {
+			continue
+		}
+		follow := NewTypeName(a.pos, a.pkg, a.name, nil)
+		follow.setScopePos(a.scopePos())
+		scope.Replace(follow)
+		target := &ast.Ident{NamePos: ident.NamePos, Name: ident.Name}
+		check.ObjMap[follow] = &DeclInfo{File: d.File, Typ: target, Alias: true}
+		follow.setOrder(uint32(len(check.ObjMap)))
+	}
 }
 
 // filename returns a filename suitable for debugging output.
@@ -272,7 +303,12 @@ func (check *Checker) collectObjects() {
 			// jea: check the ExprStmt below, handle other Stmt here.
 			case ast.Stmt:
 				// jea: allow all statements at top level.
+				check.refs = make(objSet)
 				check.simpleStmt(d)
+				if a, ok := d.(*ast.AssignStmt); ok {
+					check.recordDefineRefs(a, check.refs)
+				}
+				check.refs = nil
 				continue
 			}
 
@@ -342,6 +378,7 @@ func (check *Checker) collectObjects() {
 						// add import to file scope
 						if name == "." {
 							// merge imported scope with file scope
+							imp.scope.resolveAll()
 							for _, obj := range imp.scope.elems {
 								// A package scope may contain non-exported objects,
 								// do not import them!
@@ -468,7 +505,7 @@ func (check *Checker) collectObjects() {
 					}
 				} else {
 					// method
-					check.recordDef(d.Name, obj)
+					check.recordMemberDef(d.Name, obj)
 					// Associate method with receiver base type name, if possible.
 					// Ignore methods that have an invalid receiver, or a blank _
 					// receiver name. They will be type-checked later, with regular
@@ -481,6 +518,12 @@ func (check *Checker) collectObjects() {
 						if base, _ := typ.(*ast.Ident); base != nil && base.Name != "_" {
 							check.assocMethod(base.Name, obj)
 						}
+						// gi: a method on an imported type, as in
+						// func (d *sql.DB) Tables(), is associated once
+						// the import is resolved; see Config.ForeignMethods.
+						if sel, _ := typ.(*ast.SelectorExpr); sel != nil && check.conf.ForeignMethods != nil {
+							check.foreign = append(check.foreign, foreignMethod{fileScope, sel, obj})
+						}
 					}
 				}
 				info := &DeclInfo{File: fileScope, Fdecl: d}
@@ -579,6 +622,8 @@ func (check *Checker) packageObjects(objList []Object) {
 		}
 	}
 
+	check.addForeignMethodDecls()
+
 	// pre-allocate space for type declaration paths so that the underlying array is reused
 	typePath := make([]*TypeName, 0, 8)
 
@@ -596,8 +641,10 @@ func (check *Checker) packageObjects(objList []Object) {
 // functionBodies typechecks all function bodies.
 func (check *Checker) functionBodies() {
 	for _, f := range check.funcs {
+		check.refs = f.refs
 		check.funcBody(f.decl, f.name, f.sig, f.body)
 	}
+	check.refs = nil
 }
 
 // unusedImports checks for unused imports.
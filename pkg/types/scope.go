@@ -31,24 +31,71 @@ type Scope struct {
 	comment    string            // for debugging only
 	isFunc     bool              // set if this is a function scope (internal use only)
 	methodName string            // function name; or method name with struct type-name prefix
+
+	// lazy, if set, supplies the objects of a package's
+	// scope as they are first looked up; see SetLazy.
+	lazy       LazyScope
+	unresolved map[string]bool // names not looked up yet; nil until lazy.Names is
+	gone       map[string]bool // names deleted before lazy.Names was called
+	lazyErr    error           // from lazy.Names
 }
 
-// jea debug
-var funcScopesMade int
+// A LazyScope supplies the objects of a package's scope one
+// at a time, as they are first looked up, for gi to import
+// a big package without converting what it never uses.
+type LazyScope interface {
+	// Names returns the names of every object the scope
+	// will have. It is called once, on the first use of
+	// the scope; an error is reported where a member of
+	// the package is used.
+	Names() ([]string, error)
+
+	// Resolve returns the object named name, or nil. It
+	// may insert it, and those it refers to, in the scope.
+	Resolve(name string) Object
+}
 
-// NewScope returns a new, empty scope contained in the given parent
-// scope, if any. The comment is for debugging only.
-func NewScope(parent *Scope, pos, end token.Pos, comment, methodName string) (sc *Scope) {
-	// jea debug
-	if comment == "function" {
-		funcScopesMade++
-		pp("funcScopesMade=%v", funcScopesMade)
-		if funcScopesMade == 2 {
-			//panic("where second?")
+// SetLazy has s take its objects from lazy, as they are
+// first looked up, in addition to those inserted in it.
+func (s *Scope) SetLazy(lazy LazyScope) {
+	s.lazy = lazy
+	s.unresolved = nil
+}
+
+// load calls lazy.Names, if it has not been.
+func (s *Scope) load() {
+	if s.lazy == nil || s.unresolved != nil {
+		return
+	}
+	s.unresolved = make(map[string]bool)
+	names, err := s.lazy.Names()
+	s.lazyErr = err
+	for _, name := range names {
+		if s.elems[name] == nil && !s.gone[name] {
+			s.unresolved[name] = true
 		}
 	}
+	s.gone = nil
+}
+
+// resolveAll looks up every object of s not looked up yet,
+// as for iterating over elems.
+func (s *Scope) resolveAll() {
+	s.load()
+	var names []string
+	for name := range s.unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.Lookup(name)
+	}
+}
 
-	s := &Scope{parent, nil, nil, pos, end, comment, false, methodName}
+// NewScope returns a new, empty scope contained in the given parent
+// scope, if any. The comment is for debugging only.
+func NewScope(parent *Scope, pos, end token.Pos, comment, methodName string) (sc *Scope) {
+	s := &Scope{parent: parent, pos: pos, end: end, comment: comment, methodName: methodName}
 	// don't add children to Universe scope!
 	if parent != nil && parent != Universe {
 		parent.children = append(parent.children, s)
@@ -80,16 +127,23 @@ func (s *Scope) DeleteChild(target *Scope) {
 func (s *Scope) Parent() *Scope { return s.parent }
 
 // Len() returns the number of scope elements.
-func (s *Scope) Len() int { return len(s.elems) }
+func (s *Scope) Len() int {
+	s.load()
+	return len(s.elems) + len(s.unresolved)
+}
 
 // Names returns the scope's element names in sorted order.
 func (s *Scope) Names() []string {
-	names := make([]string, len(s.elems))
+	s.load()
+	names := make([]string, len(s.elems), len(s.elems)+len(s.unresolved))
 	i := 0
 	for name := range s.elems {
 		names[i] = name
 		i++
 	}
+	for name := range s.unresolved {
+		names = append(names, name)
+	}
 	sort.Strings(names)
 	return names
 }
@@ -103,6 +157,19 @@ func (s *Scope) Child(i int) *Scope { return s.children[i] }
 // Lookup returns the object in scope s with the given name if such an
 // object exists; otherwise the result is nil.
 func (s *Scope) Lookup(name string) Object {
+	if obj := s.elems[name]; obj != nil || s.lazy == nil {
+		return obj
+	}
+	s.load()
+	if !s.unresolved[name] {
+		return nil
+	}
+	// deleted first, for the lookups Resolve makes of
+	// name itself to find nothing.
+	delete(s.unresolved, name)
+	if obj := s.lazy.Resolve(name); obj != nil {
+		s.Insert(obj)
+	}
 	return s.elems[name]
 }
 
@@ -110,6 +177,16 @@ func (s *Scope) Lookup(name string) Object {
 func (s *Scope) DeleteByName(name string) Object {
 	obj := s.elems[name]
 	delete(s.elems, name)
+	if s.lazy != nil {
+		if s.unresolved == nil {
+			// not loaded yet, nor need be.
+			if s.gone == nil {
+				s.gone = make(map[string]bool)
+			}
+			s.gone[name] = true
+		}
+		delete(s.unresolved, name)
+	}
 	return obj
 }
 
@@ -149,6 +226,7 @@ func (s *Scope) Insert(obj Object) Object {
 		s.elems = make(map[string]Object)
 	}
 	s.elems[name] = obj
+	delete(s.unresolved, name)
 	if obj.Parent() == nil {
 		obj.setParent(s) // obj.parent = s
 	}
@@ -168,6 +246,7 @@ func (s *Scope) Replace(obj Object) Object {
 	}
 	alt := s.elems[name]
 	s.elems[name] = obj
+	delete(s.unresolved, name)
 	if obj.Parent() == nil {
 		obj.setParent(s)
 	}
@@ -233,7 +312,7 @@ func (s *Scope) WriteTo(w io.Writer, n int, recurse bool) {
 	fmt.Fprintln(w)
 	indn1 := indn + ind
 	for _, name := range s.Names() {
-		fmt.Fprintf(w, "%s%s\n", indn1, s.elems[name])
+		fmt.Fprintf(w, "%s%s\n", indn1, s.Lookup(name))
 	}
 
 	if recurse {
@@ -146,7 +146,9 @@ func (s *StdSizes) Sizeof(T Type) int64 {
 			return 0
 		}
 		offsets := s.Offsetsof(t.fields)
-		return offsets[n-1] + s.Sizeof(t.fields[n-1].typ)
+		// a struct is padded to its alignment, as
+		// gc and C, and so LuaJIT's ffi, lay it out.
+		return align(offsets[n-1]+s.Sizeof(t.fields[n-1].typ), s.Alignof(t))
 	case *Interface:
 		return s.WordSize * 2
 	}
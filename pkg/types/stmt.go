@@ -37,6 +37,7 @@ func (check *Checker) funcBody(decl *DeclInfo, name string, sig *Signature, body
 		decl:  decl,
 		scope: sig.scope,
 		sig:   sig,
+		refs:  check.refs,
 	}
 	check.indent = 0
 
@@ -300,7 +301,9 @@ L:
 
 // stmt typechecks statement s.
 func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
-	pp("jea Checker.stmt() started. s='%#v'", s)
+	if ppOn() {
+		pp("jea Checker.stmt() started. s='%#v'", s)
+	}
 	// statements cannot use iota in general
 	// (constant declarations set it explicitly)
 	assert(check.iota == nil)
@@ -318,7 +321,9 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 
 	inner := ctxt &^ (fallthroughOk | finalSwitchCase)
 
-	pp("Checker.stmt: s has type %T/val='%v'", s, s)
+	if ppOn() {
+		pp("Checker.stmt: s has type %T/val='%v'", s, s)
+	}
 	switch s := s.(type) {
 	case *ast.BadStmt, *ast.EmptyStmt:
 		// ignore
@@ -334,8 +339,9 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 		// spec: "With the exception of specific built-in functions,
 		// function and method calls and receive operations can appear
 		// in statement context. Such statements may be parenthesized."
-		var x operand
-		kind := check.rawExpr(&x, s.X, nil)
+		x := getOperand()
+		defer putOperand(x)
+		kind := check.rawExpr(x, s.X, nil)
 		var msg string
 		switch x.mode {
 		default:
@@ -348,7 +354,7 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 		case typexpr:
 			msg = "is not an expression"
 		}
-		check.errorf(x.pos(), "%s %s", &x, msg)
+		check.errorf(x.pos(), "%s %s", x, msg)
 
 	case *ast.SendStmt:
 		var ch, x operand
@@ -383,8 +389,9 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 			return
 		}
 
-		var x operand
-		check.expr(&x, s.X)
+		x := getOperand()
+		defer putOperand(x)
+		check.expr(x, s.X)
 		if x.mode == invalid {
 			return
 		}
@@ -394,11 +401,11 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 		}
 
 		Y := &ast.BasicLit{ValuePos: s.X.Pos(), Kind: token.INT, Value: "1"} // use x's position
-		check.binary(&x, nil, s.X, Y, op)
+		check.binary(x, nil, s.X, Y, op)
 		if x.mode == invalid {
 			return
 		}
-		check.assignVar(s.X, &x)
+		check.assignVar(s.X, x)
 
 	case *ast.AssignStmt:
 		switch s.Tok {
@@ -425,12 +432,13 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 				check.invalidAST(s.TokPos, "unknown assignment operation %s", s.Tok)
 				return
 			}
-			var x operand
-			check.binary(&x, nil, s.Lhs[0], s.Rhs[0], op)
+			x := getOperand()
+			defer putOperand(x)
+			check.binary(x, nil, s.Lhs[0], s.Rhs[0], op)
 			if x.mode == invalid {
 				return
 			}
-			check.assignVar(s.Lhs[0], &x)
+			check.assignVar(s.Lhs[0], x)
 		}
 
 	case *ast.GoStmt:
@@ -501,11 +509,12 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 		defer check.closeScope()
 
 		check.simpleStmt(s.Init)
-		var x operand
-		check.expr(&x, s.Cond)
+		x := getOperand()
+		check.expr(x, s.Cond)
 		if x.mode != invalid && !isBoolean(x.typ) {
 			check.error(s.Cond.Pos(), "non-boolean condition in if statement")
 		}
+		putOperand(x)
 		check.stmt(inner, s.Body)
 		// The parser produces a correct AST but if it was modified
 		// elsewhere the else branch may be invalid. Check again.
@@ -729,11 +738,12 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 
 		check.simpleStmt(s.Init)
 		if s.Cond != nil {
-			var x operand
-			check.expr(&x, s.Cond)
+			x := getOperand()
+			check.expr(x, s.Cond)
 			if x.mode != invalid && !isBoolean(x.typ) {
 				check.error(s.Cond.Pos(), "non-boolean condition in for statement")
 			}
+			putOperand(x)
 		}
 		check.simpleStmt(s.Post)
 		// spec: "The init statement may be a short variable
@@ -761,6 +771,20 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 				if isString(typ) {
 					key = Typ[Int]
 					val = universeRune // use 'rune' name
+				} else if isInteger(typ) {
+					// spec (Go 1.22): "For an integer value n,
+					// the iteration values 0 through n-1 are
+					// produced in increasing order."
+					if s.Value != nil {
+						check.errorf(s.Value.Pos(), "range over %s permits only one iteration variable", &x)
+						// ok to continue
+					}
+					if isUntyped(typ) {
+						check.convertUntyped(&x, Typ[Int])
+					}
+					if x.mode != invalid {
+						key = x.typ
+					}
 				}
 			case *Array:
 				key = Typ[Int]
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the most names a "did you mean" offers.
+const maxSuggestions = 3
+
+// didYouMean returns, for an unknown name, a note naming up
+// to maxSuggestions of cands that are a few edits away from
+// it, closest first, or "" if there are none.
+func didYouMean(name string, cands []string) string {
+	// allow about one edit in three letters; a one
+	// letter name is only matched ignoring case.
+	max := (len(name) + 1) / 3
+	type cand struct {
+		name string
+		dist int
+	}
+	var near []cand
+	seen := make(map[string]bool)
+	for _, c := range cands {
+		if c == name || c == "_" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		d := editDistance(name, c)
+		if strings.EqualFold(name, c) {
+			d = 0
+		}
+		if d <= max {
+			near = append(near, cand{c, d})
+		}
+	}
+	if len(near) == 0 {
+		return ""
+	}
+	sort.Slice(near, func(i, j int) bool {
+		if near[i].dist != near[j].dist {
+			return near[i].dist < near[j].dist
+		}
+		return near[i].name < near[j].name
+	})
+	if len(near) > maxSuggestions {
+		near = near[:maxSuggestions]
+	}
+	var b strings.Builder
+	b.WriteString(" (did you mean ")
+	for i, c := range near {
+		switch {
+		case i == 0:
+		case i == len(near)-1:
+			b.WriteString(" or ")
+		default:
+			b.WriteString(", ")
+		}
+		b.WriteString(c.name)
+	}
+	b.WriteString("?)")
+	return b.String()
+}
+
+// editDistance is the number of edits that turn a into b:
+// inserting, deleting or replacing a letter, or swapping
+// two letters next to each other, the commonest typo.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// namesInScope lists the names visible from s:
+// those of s and of all its parents.
+func namesInScope(s *Scope) []string {
+	var names []string
+	for ; s != nil; s = s.Parent() {
+		names = append(names, s.Names()...)
+	}
+	return names
+}
+
+// exportedNames lists the exported names of pkg.
+func exportedNames(pkg *Package) []string {
+	var names []string
+	for _, name := range pkg.scope.Names() {
+		if pkg.scope.Lookup(name).Exported() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectableNames lists the fields and methods of T, promoted
+// ones included, that can be selected from package pkg.
+func (check *Checker) selectableNames(T Type) []string {
+	var names []string
+	add := func(obj Object) {
+		if obj.Exported() || obj.Pkg() == check.pkg {
+			names = append(names, obj.Name())
+		}
+	}
+
+	// fields, down through embedded structs.
+	seen := make(map[Type]bool)
+	var fields func(T Type)
+	fields = func(T Type) {
+		if p, ok := T.(*Pointer); ok {
+			T = p.base
+		}
+		if seen[T] {
+			return
+		}
+		seen[T] = true
+		if s, ok := T.Underlying().(*Struct); ok {
+			for _, f := range s.fields {
+				add(f)
+				if f.anonymous {
+					fields(f.typ)
+				}
+			}
+		}
+	}
+	fields(T)
+
+	// methods, of *T as well unless that cannot have any.
+	mT := T
+	if _, isPtr := T.(*Pointer); !isPtr && !IsInterface(T) {
+		mT = NewPointer(T)
+	}
+	mset := NewMethodSet(mT)
+	for i := 0; i < mset.Len(); i++ {
+		add(mset.At(i).Obj())
+	}
+	return names
+}
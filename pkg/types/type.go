@@ -430,6 +430,7 @@ func (t *Named) SetUnderlying(underlying Type) {
 		panic("types.Named.SetUnderlying: underlying type must not be *Named")
 	}
 	t.underlying = underlying
+	forgetLookups(t)
 }
 
 // AddMethod adds method m unless it is already in the method list.
@@ -437,6 +438,7 @@ func (t *Named) SetUnderlying(underlying Type) {
 func (t *Named) AddMethod(m *Func) {
 	if i, _ := lookupMethod(t.methods, m.pkg, m.name); i < 0 {
 		t.methods = append(t.methods, m)
+		forgetLookups(t)
 	}
 }
 
@@ -35,6 +35,13 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, path []*TypeNa
 	//check.scope.Dump() // jea add
 	//pp("end of check.scope Dump")
 	scope, obj = check.scope.LookupParent(e.Name, check.pos)
+	if obj == nil {
+		// a function added with AddBuiltin is seen last,
+		// as if declared in the universe.
+		if f := check.builtins[e.Name]; f != nil {
+			scope, obj = Universe, f
+		}
+	}
 	if e != nil && obj != nil {
 		//pp("check.scope.LookupParent for e.Name='%s' got obj = '%s'", e.Name, obj.Name())
 	}
@@ -68,7 +75,7 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, path []*TypeNa
 				//}
 
 			}
-			check.errorf(e.Pos(), "undeclared name: %s", e.Name)
+			check.errorf(e.Pos(), "undeclared name: %s%s", e.Name, didYouMean(e.Name, append(namesInScope(check.scope), check.builtinNames()...)))
 		}
 		return
 	}
@@ -77,6 +84,7 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, path []*TypeNa
 	check.objDecl(obj, def, path)
 	typ := obj.Type()
 	assert(typ != nil)
+	check.recordRef(obj)
 
 	// The object may be dot-imported: If so, remove its package from
 	// the map of unused dot imports for the respective file scope.
@@ -248,7 +256,9 @@ func (check *Checker) funcType(
 				// spec: "The type denoted by T is called the receiver base type; it must not
 				// be a pointer or interface type and it must be declared in the same package
 				// as the method."
-				if T.obj.pkg != check.pkg {
+				// gi: unless Config.ForeignMethods allows it.
+				foreign := T.obj.pkg != check.pkg
+				if foreign && (T.obj.pkg == nil || check.conf.ForeignMethods == nil) {
 					err = "type not defined in this package"
 				} else {
 					// TODO(gri) This is not correct if the underlying type is unknown yet.
@@ -261,6 +271,11 @@ func (check *Checker) funcType(
 					case *Pointer, *Interface:
 						err = "pointer or interface type"
 					}
+					if err == "" && foreign {
+						if e := check.conf.ForeignMethods(T); e != nil {
+							err = e.Error()
+						}
+					}
 				}
 			} else {
 				err = "basic or unnamed type"
@@ -587,7 +602,7 @@ func (check *Checker) interfaceType(iface *Interface, ityp *ast.InterfaceType, d
 				iface.methods = append(iface.methods, m)
 				iface.allMethods = append(iface.allMethods, m)
 				signatures = append(signatures, f.Type)
-				check.recordDef(name, m)
+				check.recordMemberDef(name, m)
 			}
 		} else {
 			// embedded type
@@ -725,7 +740,7 @@ func (check *Checker) structType(styp *Struct, e *ast.StructType, path []*TypeNa
 		// spec: "Within a struct, non-blank field names must be unique."
 		if name == "_" || check.declareInSet(&fset, pos, fld) {
 			fields = append(fields, fld)
-			check.recordDef(ident, fld)
+			check.recordMemberDef(ident, fld)
 		}
 	}
 